@@ -0,0 +1,377 @@
+// Package cache embeds a cache node in another program: the same store, Raft
+// replication and service that cmd/server runs, without its HTTP and gRPC
+// servers. Embedded nodes form clusters with each other, or with servers, over
+// Raft's TCP transport.
+//
+//	node, err := cache.NewNode(cache.Config{NodeID: "node1", RaftDir: dir, Bootstrap: true})
+//	if err != nil {
+//		return err
+//	}
+//	defer node.Close()
+//	if err := node.WaitForLeader(10 * time.Second); err != nil {
+//		return err
+//	}
+//	err = node.Set(ctx, "greeting", "hello", time.Minute)
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	"distributed-cache-service/internal/consensus"
+	coreerrors "distributed-cache-service/internal/core/errors"
+	"distributed-cache-service/internal/core/ports"
+	"distributed-cache-service/internal/core/service"
+	"distributed-cache-service/internal/store"
+	"distributed-cache-service/internal/store/boltstore"
+	"distributed-cache-service/internal/store/policy"
+
+	"github.com/hashicorp/raft"
+)
+
+var (
+	// ErrNotFound is returned by Get when the key does not exist or has expired.
+	ErrNotFound = coreerrors.ErrNotFound
+	// ErrNotLeader is returned by writes, strongly consistent reads and
+	// membership changes on a node that is not the leader.
+	ErrNotLeader = coreerrors.ErrNotLeader
+	// ErrUnsupported is returned by membership changes on a standalone node.
+	ErrUnsupported = coreerrors.ErrUnsupported
+	// ErrClosed is returned by every call on a node after Close.
+	ErrClosed = errors.New("cache: node closed")
+)
+
+// Config configures an embedded node. Zero values take the defaults of the
+// matching cmd/server flags.
+type Config struct {
+	// NodeID identifies the node in the cluster. Required.
+	NodeID string
+	// Standalone runs the node without Raft, as the server's -standalone
+	// does: writes apply directly to the store, and the Raft settings,
+	// Bootstrap included, are ignored. It suits unit tests.
+	Standalone bool
+	// RaftDir holds the Raft log and snapshots; it is created if missing.
+	// Required unless Standalone.
+	RaftDir string
+	// RaftAddr is the address Raft listens on. Default 127.0.0.1:0, a free
+	// port on the loopback interface, which suits single-node use and tests.
+	RaftAddr string
+	// AdvertiseAddr is the address peers reach this node at. Default the
+	// address Raft listens on, which must then name a specific interface.
+	AdvertiseAddr string
+	// Bootstrap starts a new cluster with this node as its only voter. It is
+	// ignored if RaftDir already holds a cluster's state. Other nodes are
+	// added with AddVoter on the leader.
+	Bootstrap bool
+
+	// Consistency is the read consistency, "strong" or "eventual". Default
+	// strong.
+	Consistency string
+	// StoragePath keeps the data in a BoltDB file instead of in memory.
+	StoragePath string
+	// MaxItems bounds the number of keys; the leader evicts by
+	// EvictionPolicy (lru, lfu, fifo or random, default lru) beyond it.
+	// Zero means unbounded. Only the in-memory store supports it.
+	MaxItems       int
+	EvictionPolicy string
+	// LFUHalfLife is how often the lfu policy halves its access counts, so
+	// that keys no longer read become evictable. Default 1m; negative never
+	// halves them.
+	LFUHalfLife time.Duration
+	// OnEvict, if set, is called with every value that leaves this node's
+	// store, and why: "capacity", "ttl", "explicit" (deleted) or "replaced"
+	// (overwritten). Every node calls it as it applies the change. It runs
+	// with the store locked, so it must be quick and must not use the node.
+	// Only the in-memory store supports it.
+	OnEvict func(key, value, reason string)
+	// CleanupInterval is how often expired keys are purged. Default 1m;
+	// negative disables purging, leaving expired keys to be dropped on read.
+	CleanupInterval time.Duration
+
+	// Raft tunes elections and snapshots; zero values keep the server's
+	// defaults.
+	HeartbeatTimeout  time.Duration
+	ElectionTimeout   time.Duration
+	SnapshotThreshold uint64
+	SnapshotInterval  time.Duration
+	TrailingLogs      uint64
+	// ApplyTimeout bounds writes whose context has no deadline. Default 2s.
+	ApplyTimeout time.Duration
+}
+
+// Node is a running cache node. Its methods are safe for concurrent use.
+type Node struct {
+	id        string
+	advertise string
+	cluster   cluster
+	raft      *consensus.RaftNode // nil if standalone
+	svc       *service.ServiceImpl
+	kv        ports.SnapshotStorage
+
+	closeOnce sync.Once
+	closeErr  error
+	closed    chan struct{}
+}
+
+// cluster is a Raft node or a consensus.Standalone.
+type cluster interface {
+	ports.Consensus
+	ports.ClusterAdmin
+}
+
+// NewNode starts a node from cfg. Unless it bootstraps a cluster or restarts
+// as a member of one, it waits to be added by the leader's AddVoter.
+func NewNode(cfg Config) (*Node, error) {
+	if cfg.NodeID == "" || cfg.RaftDir == "" && !cfg.Standalone {
+		return nil, errors.New("cache: NodeID and RaftDir are required")
+	}
+	consistency := service.ConsistencyStrong
+	if cfg.Consistency != "" {
+		mode, err := service.ParseConsistencyMode(cfg.Consistency)
+		if err != nil {
+			return nil, fmt.Errorf("cache: %w", err)
+		}
+		consistency = mode
+	}
+	kv, err := openStore(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("cache: %w", err)
+	}
+	if cfg.Standalone {
+		return newNode(cfg, "", nil, consensus.NewStandalone(cfg.NodeID, consensus.NewFSM(kv)), kv, consistency), nil
+	}
+	closeStore := func() {
+		if c, ok := kv.(interface{ Close() error }); ok {
+			c.Close()
+		}
+	}
+	if err := os.MkdirAll(cfg.RaftDir, 0700); err != nil {
+		closeStore()
+		return nil, fmt.Errorf("cache: create raft directory: %w", err)
+	}
+
+	bind := cfg.RaftAddr
+	if bind == "" {
+		bind = "127.0.0.1:0"
+	}
+	ln, err := net.Listen("tcp", bind)
+	if err != nil {
+		closeStore()
+		return nil, fmt.Errorf("cache: listen for raft: %w", err)
+	}
+	advertise := cfg.AdvertiseAddr
+	if advertise == "" {
+		advertise = ln.Addr().String()
+		if addr, ok := ln.Addr().(*net.TCPAddr); ok && addr.IP.IsUnspecified() {
+			ln.Close()
+			closeStore()
+			return nil, fmt.Errorf("cache: AdvertiseAddr is required when RaftAddr %q binds every interface", bind)
+		}
+	}
+
+	rn, err := consensus.SetupRaft(cfg.RaftDir, cfg.NodeID, ln, advertise, consensus.NewFSM(kv), consensus.RaftConfig{
+		SnapshotThreshold: cfg.SnapshotThreshold,
+		SnapshotInterval:  cfg.SnapshotInterval,
+		TrailingLogs:      cfg.TrailingLogs,
+		HeartbeatTimeout:  cfg.HeartbeatTimeout,
+		ElectionTimeout:   cfg.ElectionTimeout,
+	})
+	if err != nil {
+		ln.Close()
+		closeStore()
+		return nil, fmt.Errorf("cache: %w", err)
+	}
+	rn.ApplyTimeout = cfg.ApplyTimeout
+
+	n := newNode(cfg, advertise, rn, rn, kv, consistency)
+	if cfg.Bootstrap {
+		// A node restarting with existing state is already a member.
+		member, err := rn.LocalAddress()
+		if err == nil && member == "" {
+			err = rn.Bootstrap(raft.Configuration{Servers: []raft.Server{{
+				ID:      raft.ServerID(cfg.NodeID),
+				Address: raft.ServerAddress(advertise),
+			}}})
+		}
+		if err != nil {
+			n.Close()
+			return nil, fmt.Errorf("cache: bootstrap cluster: %w", err)
+		}
+	}
+	return n, nil
+}
+
+func newNode(cfg Config, advertise string, rn *consensus.RaftNode, c cluster, kv ports.SnapshotStorage, consistency service.ConsistencyMode) *Node {
+	n := &Node{
+		id:        cfg.NodeID,
+		advertise: advertise,
+		cluster:   c,
+		raft:      rn,
+		svc:       service.New(kv, c, consistency),
+		kv:        kv,
+		closed:    make(chan struct{}),
+	}
+	cleanup := cfg.CleanupInterval
+	if cleanup == 0 {
+		cleanup = time.Minute
+	}
+	n.svc.StartPurge(cleanup)
+	return n
+}
+
+func openStore(cfg Config) (ports.SnapshotStorage, error) {
+	if cfg.StoragePath != "" {
+		if cfg.MaxItems > 0 || cfg.OnEvict != nil {
+			return nil, errors.New("MaxItems and OnEvict require the in-memory store")
+		}
+		return boltstore.Open(cfg.StoragePath)
+	}
+	// The leader chooses eviction victims and replicates their deletion, so
+	// stores never evict on their own.
+	opts := []store.Option{store.WithDeferredEviction()}
+	if cfg.MaxItems > 0 {
+		name := cfg.EvictionPolicy
+		if name == "" {
+			name = "lru"
+		}
+		halfLife := cfg.LFUHalfLife
+		if halfLife == 0 {
+			halfLife = policy.DefaultHalfLife
+		}
+		p, err := policy.New(name, policy.WithHalfLife(halfLife))
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, store.WithCapacity(cfg.MaxItems))
+		if p != nil {
+			opts = append(opts, store.WithPolicy(p))
+		}
+	}
+	s := store.New(opts...)
+	if cfg.OnEvict != nil {
+		s.OnEvict(func(key, value string, reason store.EvictReason) {
+			_, stored := service.DecodeVersion(value)
+			cfg.OnEvict(key, stored, string(reason))
+		})
+	}
+	return s, nil
+}
+
+// ID returns the node's ID.
+func (n *Node) ID() string {
+	return n.id
+}
+
+// Addr returns the Raft address peers reach this node at, which the leader
+// passes to AddVoter, or "" if the node is standalone.
+func (n *Node) Addr() string {
+	return n.advertise
+}
+
+// Get returns the value of key, or ErrNotFound.
+func (n *Node) Get(ctx context.Context, key string) (string, error) {
+	if err := n.check(); err != nil {
+		return "", err
+	}
+	return n.svc.Get(ctx, key)
+}
+
+// Set stores value under key, expiring after ttl if it is positive. It
+// returns once the write is committed and applied on this node.
+func (n *Node) Set(ctx context.Context, key, value string, ttl time.Duration) error {
+	if err := n.check(); err != nil {
+		return err
+	}
+	return n.svc.Set(ctx, key, value, ttl)
+}
+
+// Delete removes key. Deleting a missing key is not an error.
+func (n *Node) Delete(ctx context.Context, key string) error {
+	if err := n.check(); err != nil {
+		return err
+	}
+	return n.svc.Delete(ctx, key)
+}
+
+// IsLeader reports whether the node currently leads the cluster.
+func (n *Node) IsLeader() bool {
+	return n.check() == nil && n.cluster.IsLeader()
+}
+
+// Leader returns the Raft address of the leader, or "" if there is none or
+// the node is standalone.
+func (n *Node) Leader() string {
+	if n.check() != nil {
+		return ""
+	}
+	return n.cluster.Leader()
+}
+
+// WaitForLeader blocks until the cluster has a leader, this node or another,
+// or the timeout elapses. A standalone node always leads.
+func (n *Node) WaitForLeader(timeout time.Duration) error {
+	if n.raft == nil {
+		return n.check()
+	}
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if err := n.check(); err != nil {
+			return err
+		}
+		if n.raft.Leader() != "" {
+			return nil
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return fmt.Errorf("%w: no leader after %s", coreerrors.ErrTimeout, timeout)
+}
+
+// AddVoter adds the node id, reachable at the Raft address addr, to the
+// cluster. It must be called on the leader.
+func (n *Node) AddVoter(id, addr string) error {
+	if err := n.check(); err != nil {
+		return err
+	}
+	return n.cluster.AddVoter(id, addr)
+}
+
+// RemoveServer removes the node id from the cluster. It must be called on
+// the leader.
+func (n *Node) RemoveServer(id string) error {
+	if err := n.check(); err != nil {
+		return err
+	}
+	return n.cluster.RemoveServer(id)
+}
+
+// Close stops the node. Its Raft state stays in RaftDir, so a node created
+// from the same Config rejoins the cluster with its data. A leader should
+// hand over first, or be removed, to avoid an election. Peers know the node
+// by its address, so RaftAddr must name a fixed port for it to rejoin.
+func (n *Node) Close() error {
+	n.closeOnce.Do(func() {
+		close(n.closed)
+		n.svc.StartPurge(0)
+		if n.raft != nil {
+			n.closeErr = n.raft.Shutdown()
+		}
+		if c, ok := n.kv.(interface{ Close() error }); ok {
+			n.closeErr = errors.Join(n.closeErr, c.Close())
+		}
+	})
+	return n.closeErr
+}
+
+func (n *Node) check() error {
+	select {
+	case <-n.closed:
+		return ErrClosed
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,167 @@
+package cache
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testConfig(t *testing.T, id string) Config {
+	return Config{
+		NodeID:           id,
+		RaftDir:          t.TempDir(),
+		HeartbeatTimeout: 100 * time.Millisecond,
+		ElectionTimeout:  100 * time.Millisecond,
+	}
+}
+
+func startNode(t *testing.T, cfg Config) *Node {
+	t.Helper()
+	n, err := NewNode(cfg)
+	require.NoError(t, err)
+	t.Cleanup(func() { n.Close() })
+	return n
+}
+
+func TestNode_SingleNode(t *testing.T) {
+	cfg := testConfig(t, "node1")
+	cfg.Bootstrap = true
+	n := startNode(t, cfg)
+	require.NoError(t, n.WaitForLeader(5*time.Second))
+	assert.True(t, n.IsLeader())
+	assert.Equal(t, n.Addr(), n.Leader())
+
+	ctx := context.Background()
+	require.NoError(t, n.Set(ctx, "key", "value", 0))
+	val, err := n.Get(ctx, "key")
+	require.NoError(t, err)
+	assert.Equal(t, "value", val)
+
+	require.NoError(t, n.Delete(ctx, "key"))
+	_, err = n.Get(ctx, "key")
+	assert.ErrorIs(t, err, ErrNotFound)
+}
+
+func TestNode_Restart(t *testing.T) {
+	cfg := testConfig(t, "node1")
+	cfg.Bootstrap = true
+	n := startNode(t, cfg)
+	require.NoError(t, n.WaitForLeader(5*time.Second))
+	require.NoError(t, n.Set(context.Background(), "key", "value", 0))
+	require.NoError(t, n.Close())
+
+	_, err := n.Get(context.Background(), "key")
+	assert.ErrorIs(t, err, ErrClosed)
+	assert.NoError(t, n.Close())
+
+	// The same directory and address: Close released both.
+	cfg.RaftAddr = n.Addr()
+	n = startNode(t, cfg)
+	require.NoError(t, n.WaitForLeader(5*time.Second))
+	require.Eventually(t, n.IsLeader, 5*time.Second, 10*time.Millisecond)
+	val, err := n.Get(context.Background(), "key")
+	require.NoError(t, err)
+	assert.Equal(t, "value", val)
+}
+
+func TestNode_Cluster(t *testing.T) {
+	cfg := testConfig(t, "node1")
+	cfg.Bootstrap = true
+	leader := startNode(t, cfg)
+	require.Eventually(t, leader.IsLeader, 5*time.Second, 10*time.Millisecond)
+
+	var followers []*Node
+	for i := 2; i <= 3; i++ {
+		cfg := testConfig(t, fmt.Sprintf("node%d", i))
+		cfg.Consistency = "eventual"
+		f := startNode(t, cfg)
+		require.NoError(t, leader.AddVoter(f.ID(), f.Addr()))
+		followers = append(followers, f)
+	}
+
+	ctx := context.Background()
+	require.NoError(t, leader.Set(ctx, "key", "value", 0))
+	for _, f := range followers {
+		assert.ErrorIs(t, f.Set(ctx, "other", "value", 0), ErrNotLeader)
+		require.Eventually(t, func() bool {
+			val, err := f.Get(ctx, "key")
+			return err == nil && val == "value"
+		}, 5*time.Second, 10*time.Millisecond)
+	}
+
+	// The followers elect a new leader once the old one is gone.
+	require.NoError(t, leader.Close())
+	var next *Node
+	require.Eventually(t, func() bool {
+		for _, f := range followers {
+			if f.IsLeader() {
+				next = f
+			}
+		}
+		return next != nil
+	}, 5*time.Second, 10*time.Millisecond)
+	require.NoError(t, next.Set(ctx, "key", "changed", 0))
+	for _, f := range followers {
+		require.Eventually(t, func() bool {
+			val, err := f.Get(ctx, "key")
+			return err == nil && val == "changed"
+		}, 5*time.Second, 10*time.Millisecond)
+	}
+}
+
+func TestNode_Standalone(t *testing.T) {
+	n := startNode(t, Config{NodeID: "node1", Standalone: true})
+	require.NoError(t, n.WaitForLeader(time.Second))
+	assert.True(t, n.IsLeader())
+
+	ctx := context.Background()
+	require.NoError(t, n.Set(ctx, "key", "value", 0))
+	val, err := n.Get(ctx, "key")
+	require.NoError(t, err)
+	assert.Equal(t, "value", val)
+	assert.ErrorIs(t, n.AddVoter("node2", "127.0.0.1:11001"), ErrUnsupported)
+
+	require.NoError(t, n.Close())
+	assert.ErrorIs(t, n.Set(ctx, "key", "value", 0), ErrClosed)
+}
+
+func TestNode_OnEvict(t *testing.T) {
+	var (
+		mu  sync.Mutex
+		got []string
+	)
+	n := startNode(t, Config{NodeID: "node1", Standalone: true, OnEvict: func(key, value, reason string) {
+		mu.Lock()
+		defer mu.Unlock()
+		got = append(got, key+"="+value+" "+reason)
+	}})
+	require.NoError(t, n.WaitForLeader(time.Second))
+
+	ctx := context.Background()
+	require.NoError(t, n.Set(ctx, "key", "v1", 0))
+	require.NoError(t, n.Set(ctx, "key", "v2", 0))
+	require.NoError(t, n.Delete(ctx, "key"))
+	mu.Lock()
+	defer mu.Unlock()
+	assert.Equal(t, []string{"key=v1 replaced", "key=v2 explicit"}, got)
+}
+
+func TestNewNode_InvalidConfig(t *testing.T) {
+	_, err := NewNode(Config{NodeID: "node1"})
+	assert.Error(t, err)
+
+	cfg := testConfig(t, "node1")
+	cfg.Consistency = "sometimes"
+	_, err = NewNode(cfg)
+	assert.Error(t, err)
+
+	cfg = testConfig(t, "node1")
+	cfg.RaftAddr = ":0"
+	_, err = NewNode(cfg)
+	assert.ErrorContains(t, err, "AdvertiseAddr")
+}
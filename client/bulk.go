@@ -0,0 +1,123 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strconv"
+	"time"
+
+	pb "distributed-cache-service/proto"
+
+	"google.golang.org/grpc/metadata"
+)
+
+// Each message of a bulk load carries up to bulkMessageEntries entries and
+// about bulkMessageBytes bytes, well under gRPC's default 4MB message limit.
+const (
+	bulkMessageEntries = 512
+	bulkMessageBytes   = 1 << 20
+)
+
+// trailerBulkLoaded carries how many entries a BulkLoad call wrote.
+const trailerBulkLoaded = "x-bulk-loaded"
+
+// BulkLoadResult reports the entries a bulk load wrote and the replicated
+// writes they took.
+type BulkLoadResult struct {
+	Loaded  int
+	Batches int
+}
+
+// BulkLoader streams entries to the cluster, which writes them in large
+// batches: far faster than one Set per key when warming up a cache. It is not
+// safe for concurrent use.
+//
+//	l, err := c.BulkLoad(ctx)
+//	for _, row := range rows {
+//		if err := l.Add(row.Key, row.Value, time.Hour); err != nil {
+//			break // Close reports the error
+//		}
+//	}
+//	result, err := l.Close()
+type BulkLoader struct {
+	c       *Client
+	stream  pb.CacheService_BulkLoadClient
+	entries []*pb.BulkLoadEntry
+	size    int
+	err     error
+}
+
+// BulkLoad starts a bulk load on the leader. ctx bounds the whole load. Each
+// batch of entries is applied atomically and in the order added; the load
+// stops at the first batch that fails, keeping the batches before it. Close
+// must be called to finish the load.
+func (c *Client) BulkLoad(ctx context.Context) (*BulkLoader, error) {
+	stream, err := c.primary.Load().pick().BulkLoad(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &BulkLoader{c: c, stream: stream}, nil
+}
+
+// Add queues key for writing. A ttl of 0 means no expiration; otherwise it is
+// rounded down to whole seconds. Once the load has failed, Add returns the
+// error, as does Close.
+func (l *BulkLoader) Add(key, value string, ttl time.Duration) error {
+	if l.err != nil {
+		return l.err
+	}
+	if l.c.near != nil {
+		l.c.near.invalidate(key)
+	}
+	l.entries = append(l.entries, &pb.BulkLoadEntry{Key: key, Value: value, Ttl: int64(ttl / time.Second)})
+	l.size += len(key) + len(value)
+	if len(l.entries) >= bulkMessageEntries || l.size >= bulkMessageBytes {
+		return l.send()
+	}
+	return nil
+}
+
+// send sends the queued entries.
+func (l *BulkLoader) send() error {
+	if len(l.entries) == 0 {
+		return nil
+	}
+	// The message may still be read after Send returns, so it is not reused.
+	if err := l.stream.Send(&pb.BulkLoadRequest{Entries: l.entries}); err != nil {
+		if errors.Is(err, io.EOF) {
+			// The server ended the call; its status tells why.
+			_, err = l.stream.CloseAndRecv()
+		}
+		l.err = err
+		return err
+	}
+	l.entries, l.size = nil, 0
+	return nil
+}
+
+// Close sends the remaining entries and waits for the cluster to write them.
+// If the load failed, the result counts the entries written before the
+// failure, so that it can be resumed from there.
+func (l *BulkLoader) Close() (BulkLoadResult, error) {
+	if l.err == nil {
+		l.send()
+	}
+	if l.err == nil {
+		resp, err := l.stream.CloseAndRecv()
+		if err == nil {
+			return BulkLoadResult{Loaded: int(resp.Loaded), Batches: int(resp.Batches)}, nil
+		}
+		l.err = err
+	}
+	return BulkLoadResult{Loaded: loadedFrom(l.stream.Trailer())}, l.err
+}
+
+// loadedFrom reads how many entries were written from a BulkLoad trailer.
+func loadedFrom(md metadata.MD) int {
+	if v := md.Get(trailerBulkLoaded); len(v) > 0 {
+		n, _ := strconv.Atoi(v[0])
+		return n
+	}
+	return 0
+}
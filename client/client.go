@@ -0,0 +1,587 @@
+// Package client is the Go SDK for the distributed cache's gRPC API.
+//
+// A Client can optionally keep a near cache: an in-process L1 cache for hot
+// keys that is invalidated by the server's keyspace event stream (Watch), so
+// repeated reads of the same key skip the network entirely.
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"distributed-cache-service/internal/compression/grpczstd"
+	pb "distributed-cache-service/proto"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/encoding/gzip"
+	"google.golang.org/grpc/status"
+)
+
+var (
+	// ErrNotFound is returned by Get when the key does not exist.
+	ErrNotFound = errors.New("key not found")
+	// ErrVersionMismatch is returned by a conditional write whose expected
+	// version no longer matches the key's.
+	ErrVersionMismatch = errors.New("version mismatch")
+)
+
+type requestIDKey struct{}
+
+// ContextWithRequestID attaches an idempotency key to a Set or Delete. If a call
+// fails ambiguously (e.g. times out), retrying it with the same ID is safe: the
+// cluster applies each ID at most once within its dedup window.
+func ContextWithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+func requestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// Consistency selects how up to date a read must be.
+type Consistency int
+
+const (
+	// ConsistencyDefault uses the server's -consistency.
+	ConsistencyDefault Consistency = iota
+	// ConsistencyStrong reads the latest committed value from the leader.
+	// Get skips the near cache.
+	ConsistencyStrong
+	// ConsistencyEventual reads from the node's local state, which may lag.
+	ConsistencyEventual
+)
+
+type consistencyKey struct{}
+
+// ContextWithConsistency overrides the server's consistency mode for the reads
+// (Get, GetVersioned, StrLen, TTL, ZRange, ZRangeByScore, ZScore) made with ctx.
+func ContextWithConsistency(ctx context.Context, c Consistency) context.Context {
+	return context.WithValue(ctx, consistencyKey{}, c)
+}
+
+func consistency(ctx context.Context) pb.Consistency {
+	switch c, _ := ctx.Value(consistencyKey{}).(Consistency); c {
+	case ConsistencyStrong:
+		return pb.Consistency_CONSISTENCY_STRONG
+	case ConsistencyEventual:
+		return pb.Consistency_CONSISTENCY_EVENTUAL
+	}
+	return pb.Consistency_CONSISTENCY_DEFAULT
+}
+
+// Ack selects how far a Set or Delete must get before it returns.
+type Ack int
+
+const (
+	// AckQuorum waits until a quorum has committed the write.
+	AckQuorum Ack = iota
+	// AckLeader returns once the leader has queued the write for replication.
+	// It is faster, but the write is lost if the leader fails before it
+	// commits, and reads may not see it yet. SetIfVersion refuses it, since it
+	// could not report a version mismatch.
+	AckLeader
+)
+
+type ackKey struct{}
+
+// ContextWithAck sets the ack level of the Set and Delete calls made with ctx.
+func ContextWithAck(ctx context.Context, ack Ack) context.Context {
+	return context.WithValue(ctx, ackKey{}, ack)
+}
+
+func ack(ctx context.Context) pb.Ack {
+	if a, _ := ctx.Value(ackKey{}).(Ack); a == AckLeader {
+		return pb.Ack_ACK_LEADER
+	}
+	return pb.Ack_ACK_QUORUM
+}
+
+// Client talks to a cache node over gRPC. It is safe for concurrent use.
+type Client struct {
+	// primary is the node calls go to: the target, or the leader it pointed
+	// to during an election (see WithElectionRetry).
+	primary  atomic.Pointer[pool]
+	target   string // the node the client was created with
+	poolSize int
+	poolsMu  sync.Mutex
+	pools    map[string]*pool // by target, closed by Close
+
+	dialOpts []grpc.DialOption
+	near     *nearCache
+	stop     context.CancelFunc
+	done     chan struct{}
+
+	retryInitial time.Duration
+	retryMax     time.Duration
+	retryBudget  time.Duration
+
+	hedgeDelay   time.Duration
+	hedgeTargets []string
+	replicas     []*pool
+	nextReplica  atomic.Uint64
+	observe      func(ReadStats)
+
+	pipeline *pipeline
+	inflight sync.WaitGroup // unbatched asynchronous writes
+}
+
+// Option configures a Client.
+type Option func(*Client)
+
+// WithNearCache enables an in-process cache of up to maxEntries values, each
+// kept for at most ttl. Entries are invalidated when the key changes on the
+// cluster; ttl bounds staleness if an invalidation is delayed.
+func WithNearCache(maxEntries int, ttl time.Duration) Option {
+	return func(c *Client) {
+		c.near = newNearCache(maxEntries, ttl)
+	}
+}
+
+// WithDialOptions adds gRPC dial options, e.g. transport credentials.
+// Connections are insecure unless credentials are provided here.
+func WithDialOptions(opts ...grpc.DialOption) Option {
+	return func(c *Client) {
+		c.dialOpts = append(c.dialOpts, opts...)
+	}
+}
+
+// WithGzip compresses requests with gzip and asks the server to compress its
+// responses, trading CPU for bandwidth on large values.
+func WithGzip() Option {
+	return func(c *Client) {
+		c.dialOpts = append(c.dialOpts, grpc.WithDefaultCallOptions(grpc.UseCompressor(gzip.Name)))
+	}
+}
+
+// WithZstd is WithGzip with zstd, which saves more for about the same CPU.
+// The server has to be recent enough to know it.
+func WithZstd() Option {
+	return func(c *Client) {
+		c.dialOpts = append(c.dialOpts, grpc.WithDefaultCallOptions(grpc.UseCompressor(grpczstd.Name)))
+	}
+}
+
+// New connects to the cache node at target (host:port).
+func New(target string, opts ...Option) (*Client, error) {
+	c := &Client{
+		dialOpts:     []grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())},
+		retryInitial: DefaultRetryInitial,
+		retryMax:     DefaultRetryMax,
+		retryBudget:  DefaultRetryBudget,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	// Last in the chain, so that interceptors given as options see a retried
+	// call once.
+	c.dialOpts = append(c.dialOpts, grpc.WithChainUnaryInterceptor(c.retryElection))
+
+	c.target = target
+	if _, err := c.follow(target); err != nil {
+		return nil, err
+	}
+	for _, t := range c.hedgeTargets {
+		r, err := dialPool(t, c.poolSize, c.dialOpts)
+		if err != nil {
+			c.closeConns()
+			return nil, err
+		}
+		c.replicas = append(c.replicas, r)
+	}
+
+	if c.near != nil {
+		ctx, cancel := context.WithCancel(context.Background())
+		c.stop = cancel
+		c.done = make(chan struct{})
+		go c.watch(ctx)
+	}
+	return c, nil
+}
+
+// Close waits for pending asynchronous writes, stops invalidation and closes
+// the connections.
+func (c *Client) Close() error {
+	if c.pipeline != nil {
+		c.pipeline.close(c)
+	}
+	c.inflight.Wait()
+	if c.stop != nil {
+		c.stop()
+		<-c.done
+	}
+	return c.closeConns()
+}
+
+func (c *Client) closeConns() error {
+	c.poolsMu.Lock()
+	defer c.poolsMu.Unlock()
+	var err error
+	for _, p := range c.pools {
+		err = errors.Join(err, p.close())
+	}
+	for _, r := range c.replicas {
+		err = errors.Join(err, r.close())
+	}
+	return err
+}
+
+// Get returns the value for key, or ErrNotFound.
+func (c *Client) Get(ctx context.Context, key string) (string, error) {
+	if c.near == nil || consistency(ctx) == pb.Consistency_CONSISTENCY_STRONG {
+		return c.get(ctx, key)
+	}
+	if v, ok := c.near.get(key); ok {
+		return v, nil
+	}
+	epoch := c.near.epochNow()
+	v, err := c.get(ctx, key)
+	if err != nil {
+		return "", err
+	}
+	c.near.put(key, v, epoch)
+	return v, nil
+}
+
+func (c *Client) get(ctx context.Context, key string) (string, error) {
+	val, _, err := c.GetVersioned(ctx, key)
+	return val, err
+}
+
+// GetVersioned returns the value for key and its version, for use with
+// SetIfVersion. It always reads from the server, bypassing the near cache.
+func (c *Client) GetVersioned(ctx context.Context, key string) (string, uint64, error) {
+	req := &pb.GetRequest{Key: key, Consistency: consistency(ctx)}
+	resp, err := hedged(ctx, c, "Get", func(ctx context.Context, cache pb.CacheServiceClient) (*pb.GetResponse, error) {
+		return cache.Get(ctx, req)
+	})
+	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			return "", 0, ErrNotFound
+		}
+		return "", 0, err
+	}
+	return resp.Value, resp.Version, nil
+}
+
+// Set stores value under key. A ttl of 0 means no expiration; otherwise it is
+// rounded down to whole seconds.
+func (c *Client) Set(ctx context.Context, key, value string, ttl time.Duration) error {
+	if c.near != nil {
+		c.near.invalidate(key)
+	}
+	_, err := c.primary.Load().pick().Set(ctx, &pb.SetRequest{
+		Key:       key,
+		Value:     value,
+		Ttl:       int64(ttl / time.Second),
+		RequestId: requestID(ctx),
+		Ack:       ack(ctx),
+	})
+	return err
+}
+
+// SetIfVersion stores value only if key is still at version, as returned by
+// GetVersioned; a version of 0 requires that the key does not exist. It returns
+// the key's new version, or ErrVersionMismatch if another write got there first.
+func (c *Client) SetIfVersion(ctx context.Context, key, value string, ttl time.Duration, version uint64) (uint64, error) {
+	if c.near != nil {
+		c.near.invalidate(key)
+	}
+	resp, err := c.primary.Load().pick().Set(ctx, &pb.SetRequest{
+		Key:       key,
+		Value:     value,
+		Ttl:       int64(ttl / time.Second),
+		RequestId: requestID(ctx),
+		Ack:       ack(ctx),
+		IfVersion: version,
+		IfAbsent:  version == 0,
+	})
+	if err != nil {
+		if status.Code(err) == codes.FailedPrecondition {
+			return 0, ErrVersionMismatch
+		}
+		return 0, err
+	}
+	return resp.Version, nil
+}
+
+// GetSet stores value under key and returns the value it replaced; found is
+// false if the key did not exist. The swap is atomic on the cluster.
+func (c *Client) GetSet(ctx context.Context, key, value string, ttl time.Duration) (old string, found bool, err error) {
+	if c.near != nil {
+		c.near.invalidate(key)
+	}
+	resp, err := c.primary.Load().pick().GetSet(ctx, &pb.GetSetRequest{
+		Key:       key,
+		Value:     value,
+		Ttl:       int64(ttl / time.Second),
+		RequestId: requestID(ctx),
+	})
+	if err != nil {
+		return "", false, err
+	}
+	return resp.OldValue, resp.Found, nil
+}
+
+// GetDel deletes key and returns the value it held, or ErrNotFound.
+func (c *Client) GetDel(ctx context.Context, key string) (string, error) {
+	if c.near != nil {
+		c.near.invalidate(key)
+	}
+	resp, err := c.primary.Load().pick().GetDel(ctx, &pb.GetDelRequest{Key: key, RequestId: requestID(ctx)})
+	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			return "", ErrNotFound
+		}
+		return "", err
+	}
+	return resp.Value, nil
+}
+
+// GetOrSet returns the value of key, or stores value with ttl and returns it
+// if the key does not exist; loaded reports whether the value was already
+// there. The check and the write are atomic on the cluster, so concurrent
+// callers all get the same value.
+func (c *Client) GetOrSet(ctx context.Context, key, value string, ttl time.Duration) (actual string, loaded bool, err error) {
+	if c.near != nil {
+		c.near.invalidate(key)
+	}
+	resp, err := c.primary.Load().pick().GetOrSet(ctx, &pb.GetOrSetRequest{
+		Key:       key,
+		Value:     value,
+		Ttl:       int64(ttl / time.Second),
+		RequestId: requestID(ctx),
+	})
+	if err != nil {
+		return "", false, err
+	}
+	return resp.Value, resp.Loaded, nil
+}
+
+// Append appends suffix to the value of key, creating the key if it does not
+// exist, and returns the new length in bytes. Appends from concurrent clients
+// are applied one after another, so none are lost.
+func (c *Client) Append(ctx context.Context, key, suffix string) (int, error) {
+	if c.near != nil {
+		c.near.invalidate(key)
+	}
+	resp, err := c.primary.Load().pick().Append(ctx, &pb.AppendRequest{Key: key, Suffix: suffix, RequestId: requestID(ctx)})
+	if err != nil {
+		return 0, err
+	}
+	return int(resp.Length), nil
+}
+
+// StrLen returns the length in bytes of the value of key, or 0 if it does not exist.
+func (c *Client) StrLen(ctx context.Context, key string) (int, error) {
+	req := &pb.StrLenRequest{Key: key, Consistency: consistency(ctx)}
+	resp, err := hedged(ctx, c, "StrLen", func(ctx context.Context, cache pb.CacheServiceClient) (*pb.StrLenResponse, error) {
+		return cache.StrLen(ctx, req)
+	})
+	if err != nil {
+		return 0, err
+	}
+	return int(resp.Length), nil
+}
+
+// TTL returns the remaining lifetime of key, or 0 if it does not expire.
+// It returns ErrNotFound if the key does not exist.
+func (c *Client) TTL(ctx context.Context, key string) (time.Duration, error) {
+	req := &pb.TTLRequest{Key: key, Consistency: consistency(ctx)}
+	resp, err := hedged(ctx, c, "TTL", func(ctx context.Context, cache pb.CacheServiceClient) (*pb.TTLResponse, error) {
+		return cache.TTL(ctx, req)
+	})
+	if err != nil {
+		if status.Code(err) == codes.NotFound {
+			return 0, ErrNotFound
+		}
+		return 0, err
+	}
+	return time.Duration(resp.TtlMs) * time.Millisecond, nil
+}
+
+// Expire makes key expire after ttl, rounded down to whole seconds, keeping its
+// value. It returns ErrNotFound if the key does not exist.
+func (c *Client) Expire(ctx context.Context, key string, ttl time.Duration) error {
+	if c.near != nil {
+		c.near.invalidate(key)
+	}
+	_, err := c.primary.Load().pick().Expire(ctx, &pb.ExpireRequest{Key: key, Ttl: int64(ttl / time.Second), RequestId: requestID(ctx)})
+	if status.Code(err) == codes.NotFound {
+		return ErrNotFound
+	}
+	return err
+}
+
+// Persist removes key's expiration, keeping its value.
+// It returns ErrNotFound if the key does not exist.
+func (c *Client) Persist(ctx context.Context, key string) error {
+	if c.near != nil {
+		c.near.invalidate(key)
+	}
+	_, err := c.primary.Load().pick().Persist(ctx, &pb.PersistRequest{Key: key, RequestId: requestID(ctx)})
+	if status.Code(err) == codes.NotFound {
+		return ErrNotFound
+	}
+	return err
+}
+
+// ScoredMember is a member of a sorted set with its score.
+type ScoredMember struct {
+	Member string
+	Score  float64
+}
+
+// ZAdd adds members to the sorted set at key, creating it if needed, and
+// updates the scores of members already present. It returns how many were new.
+func (c *Client) ZAdd(ctx context.Context, key string, members ...ScoredMember) (int, error) {
+	req := &pb.ZAddRequest{Key: key, Members: make([]*pb.ScoredMember, len(members)), RequestId: requestID(ctx)}
+	for i, m := range members {
+		req.Members[i] = &pb.ScoredMember{Member: m.Member, Score: m.Score}
+	}
+	resp, err := c.primary.Load().pick().ZAdd(ctx, req)
+	if err != nil {
+		return 0, err
+	}
+	return int(resp.Added), nil
+}
+
+// ZRange returns the members of the sorted set at key ranked start to stop
+// inclusive, lowest score first. Ranks are 0-based and negative ranks count
+// from the end, so ZRange(ctx, key, 0, -1) returns the whole set.
+func (c *Client) ZRange(ctx context.Context, key string, start, stop int) ([]ScoredMember, error) {
+	return c.zrange(ctx, &pb.ZRangeRequest{Key: key, Start: int64(start), Stop: int64(stop)})
+}
+
+// ZRangeByScore returns the members of the sorted set at key with
+// min <= score <= max, lowest score first, at most limit of them if limit > 0.
+// Use math.Inf for open-ended ranges.
+func (c *Client) ZRangeByScore(ctx context.Context, key string, min, max float64, limit int) ([]ScoredMember, error) {
+	return c.zrange(ctx, &pb.ZRangeRequest{Key: key, ByScore: true, Min: min, Max: max, Limit: int64(limit)})
+}
+
+func (c *Client) zrange(ctx context.Context, req *pb.ZRangeRequest) ([]ScoredMember, error) {
+	req.Consistency = consistency(ctx)
+	resp, err := hedged(ctx, c, "ZRange", func(ctx context.Context, cache pb.CacheServiceClient) (*pb.ZRangeResponse, error) {
+		return cache.ZRange(ctx, req)
+	})
+	if err != nil {
+		return nil, err
+	}
+	members := make([]ScoredMember, len(resp.Members))
+	for i, m := range resp.Members {
+		members[i] = ScoredMember{Member: m.Member, Score: m.Score}
+	}
+	return members, nil
+}
+
+// ZScore returns the score of member in the sorted set at key; found is false
+// if it is not a member.
+func (c *Client) ZScore(ctx context.Context, key, member string) (score float64, found bool, err error) {
+	req := &pb.ZScoreRequest{Key: key, Member: member, Consistency: consistency(ctx)}
+	resp, err := hedged(ctx, c, "ZScore", func(ctx context.Context, cache pb.CacheServiceClient) (*pb.ZScoreResponse, error) {
+		return cache.ZScore(ctx, req)
+	})
+	if err != nil {
+		return 0, false, err
+	}
+	return resp.Score, resp.Found, nil
+}
+
+// ZRemRangeByScore removes the members of the sorted set at key with
+// min <= score <= max and returns how many were removed.
+func (c *Client) ZRemRangeByScore(ctx context.Context, key string, min, max float64) (int, error) {
+	resp, err := c.primary.Load().pick().ZRemRangeByScore(ctx, &pb.ZRemRangeByScoreRequest{Key: key, Min: min, Max: max, RequestId: requestID(ctx)})
+	if err != nil {
+		return 0, err
+	}
+	return int(resp.Removed), nil
+}
+
+// Eval runs a Lua script atomically on the cluster against keys, the only keys
+// it may access, and returns its result decoded from JSON: nil, bool, float64,
+// string, []interface{} or map[string]interface{}. A script error fails with
+// codes.InvalidArgument and applies none of the script's writes.
+func (c *Client) Eval(ctx context.Context, script string, keys []string, args ...string) (interface{}, error) {
+	if c.near != nil {
+		for _, key := range keys {
+			c.near.invalidate(key)
+		}
+	}
+	resp, err := c.primary.Load().pick().Eval(ctx, &pb.EvalRequest{Script: script, Keys: keys, Args: args, RequestId: requestID(ctx)})
+	if err != nil {
+		return nil, err
+	}
+	var result interface{}
+	if err := json.Unmarshal([]byte(resp.Result), &result); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Delete removes key.
+func (c *Client) Delete(ctx context.Context, key string) error {
+	if c.near != nil {
+		c.near.invalidate(key)
+	}
+	_, err := c.primary.Load().pick().Delete(ctx, &pb.DeleteRequest{Key: key, RequestId: requestID(ctx), Ack: ack(ctx)})
+	return err
+}
+
+// watch keeps a Watch stream open and applies invalidations to the near cache.
+// The near cache only serves reads while the stream is connected; on any
+// disconnect it is cleared, since changes may have been missed.
+func (c *Client) watch(ctx context.Context) {
+	defer close(c.done)
+	backoff := 100 * time.Millisecond
+	for {
+		err := c.watchOnce(ctx)
+		c.near.reset(false)
+		if ctx.Err() != nil {
+			return
+		}
+		if err == nil {
+			backoff = 100 * time.Millisecond
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, 5*time.Second)
+	}
+}
+
+// watchOnce runs a single Watch stream. It returns nil if the stream was
+// established before failing, so the caller can reset its backoff.
+func (c *Client) watchOnce(ctx context.Context) error {
+	stream, err := c.primary.Load().pick().Watch(ctx, &pb.WatchRequest{})
+	if err != nil {
+		return err
+	}
+	subscribed := false
+	for {
+		e, err := stream.Recv()
+		if err != nil {
+			if subscribed {
+				return nil
+			}
+			return err
+		}
+		switch e.Type {
+		case pb.KeyEvent_SUBSCRIBED:
+			subscribed = true
+			c.near.reset(true)
+		case pb.KeyEvent_SET, pb.KeyEvent_DELETE:
+			c.near.invalidate(e.Key)
+		case pb.KeyEvent_FLUSH:
+			c.near.reset(true)
+		}
+	}
+}
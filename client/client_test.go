@@ -0,0 +1,1064 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net"
+	"reflect"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	coreerrors "distributed-cache-service/internal/core/errors"
+	"distributed-cache-service/internal/core/ports"
+	"distributed-cache-service/internal/core/service"
+	"distributed-cache-service/internal/events"
+	grpcAdapter "distributed-cache-service/internal/grpc"
+	"distributed-cache-service/internal/script"
+	"distributed-cache-service/internal/store"
+	pb "distributed-cache-service/proto"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// fakeService is an in-memory CacheService that publishes events like the FSM.
+type fakeService struct {
+	mu       sync.Mutex
+	data     map[string]string
+	versions map[string]uint64
+	gets     atomic.Int64
+	index    uint64
+	events   *events.Broker
+	zsets    *store.Store // backs the sorted set methods
+	ttls     map[string]time.Duration
+	// consistency is the mode requested by the last GetVersioned, if any.
+	consistency service.ConsistencyMode
+	// ack is the level requested by the last SetIf or DeleteIf, if any.
+	ack service.AckLevel
+}
+
+func (f *fakeService) Get(ctx context.Context, key string) (string, error) {
+	v, _, err := f.GetVersioned(ctx, key)
+	return v, err
+}
+
+func (f *fakeService) GetVersioned(ctx context.Context, key string) (string, uint64, error) {
+	f.gets.Add(1)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.consistency, _ = service.ConsistencyFromContext(ctx)
+	v, ok := f.data[key]
+	if !ok {
+		return "", 0, coreerrors.ErrNotFound
+	}
+	return v, f.versions[key], nil
+}
+
+func (f *fakeService) Set(ctx context.Context, key, value string, ttl time.Duration) error {
+	_, err := f.SetIf(ctx, key, value, ttl, ports.Precondition{})
+	return err
+}
+
+func (f *fakeService) SetIf(ctx context.Context, key, value string, ttl time.Duration, cond ports.Precondition) (uint64, error) {
+	f.mu.Lock()
+	f.ack, _ = service.AckFromContext(ctx)
+	if err := f.check(key, cond); err != nil {
+		f.mu.Unlock()
+		return 0, err
+	}
+	f.index++
+	f.data[key] = value
+	f.versions[key] = f.index
+	e := events.Event{Type: events.Set, Key: key, Index: f.index}
+	f.mu.Unlock()
+	f.events.Publish(e)
+	return e.Index, nil
+}
+
+func (f *fakeService) Delete(ctx context.Context, key string) error {
+	return f.DeleteIf(ctx, key, ports.Precondition{})
+}
+
+func (f *fakeService) DeleteIf(ctx context.Context, key string, cond ports.Precondition) error {
+	f.mu.Lock()
+	f.ack, _ = service.AckFromContext(ctx)
+	if err := f.check(key, cond); err != nil {
+		f.mu.Unlock()
+		return err
+	}
+	delete(f.data, key)
+	delete(f.versions, key)
+	f.index++
+	e := events.Event{Type: events.Delete, Key: key, Index: f.index}
+	f.mu.Unlock()
+	f.events.Publish(e)
+	return nil
+}
+
+func (f *fakeService) GetSet(ctx context.Context, key, value string, ttl time.Duration) (string, bool, error) {
+	f.mu.Lock()
+	old, found := f.data[key]
+	f.mu.Unlock()
+	_, err := f.SetIf(ctx, key, value, ttl, ports.Precondition{})
+	return old, found, err
+}
+
+func (f *fakeService) GetOrSet(ctx context.Context, key, value string, ttl time.Duration) (string, bool, error) {
+	f.mu.Lock()
+	old, found := f.data[key]
+	f.mu.Unlock()
+	if found {
+		return old, true, nil
+	}
+	_, err := f.SetIf(ctx, key, value, ttl, ports.Precondition{})
+	return value, false, err
+}
+
+func (f *fakeService) GetDel(ctx context.Context, key string) (string, error) {
+	f.mu.Lock()
+	old, found := f.data[key]
+	f.mu.Unlock()
+	if !found {
+		return "", coreerrors.ErrNotFound
+	}
+	return old, f.Delete(ctx, key)
+}
+
+func (f *fakeService) Append(ctx context.Context, key, suffix string) (int, error) {
+	f.mu.Lock()
+	value := f.data[key] + suffix
+	f.mu.Unlock()
+	_, err := f.SetIf(ctx, key, value, 0, ports.Precondition{})
+	return len(value), err
+}
+
+func (f *fakeService) StrLen(ctx context.Context, key string) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return len(f.data[key]), nil
+}
+
+// check evaluates cond like the FSM does. f.mu must be held.
+func (f *fakeService) check(key string, cond ports.Precondition) error {
+	version, exists := f.versions[key]
+	if (cond.IfAbsent && exists) || (cond.IfVersion != 0 && version != cond.IfVersion) {
+		return coreerrors.ErrVersionMismatch
+	}
+	return nil
+}
+
+func (f *fakeService) ZAdd(ctx context.Context, key string, members ...ports.ScoredMember) (int, error) {
+	return f.zsets.ZAdd(key, time.Now(), members...)
+}
+
+func (f *fakeService) ZRemRangeByScore(ctx context.Context, key string, min, max float64) (int, error) {
+	return f.zsets.ZRemRangeByScore(key, min, max, time.Now())
+}
+
+func (f *fakeService) ZScore(ctx context.Context, key, member string) (float64, bool, error) {
+	return f.zsets.ZScore(key, member)
+}
+
+func (f *fakeService) ZRange(ctx context.Context, key string, start, stop int) ([]ports.ScoredMember, error) {
+	return f.zsets.ZRange(key, start, stop)
+}
+
+func (f *fakeService) ZRangeByScore(ctx context.Context, key string, min, max float64, limit int) ([]ports.ScoredMember, error) {
+	return f.zsets.ZRangeByScore(key, min, max, limit)
+}
+
+func (f *fakeService) Eval(ctx context.Context, src string, keys, args []string) (interface{}, error) {
+	s, err := script.Compile(src)
+	if err != nil {
+		return nil, err
+	}
+	return s.Run(fakeEnv{f}, keys, args)
+}
+
+// fakeEnv runs scripts directly against a fakeService, without the FSM's rollback.
+type fakeEnv struct{ f *fakeService }
+
+func (e fakeEnv) Get(key string) (string, bool) {
+	e.f.mu.Lock()
+	defer e.f.mu.Unlock()
+	v, ok := e.f.data[key]
+	return v, ok
+}
+
+func (e fakeEnv) Set(key, value string, ttl time.Duration) {
+	_, _ = e.f.SetIf(context.Background(), key, value, ttl, ports.Precondition{})
+}
+
+func (e fakeEnv) Delete(key string) bool {
+	_, ok := e.Get(key)
+	_ = e.f.Delete(context.Background(), key)
+	return ok
+}
+
+func (e fakeEnv) Now() time.Time { return time.Now() }
+
+func (f *fakeService) TTL(ctx context.Context, key string) (time.Duration, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.data[key]; !ok {
+		return 0, coreerrors.ErrNotFound
+	}
+	return f.ttls[key], nil
+}
+
+func (f *fakeService) Expire(ctx context.Context, key string, ttl time.Duration) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.data[key]; !ok {
+		return coreerrors.ErrNotFound
+	}
+	f.ttls[key] = ttl
+	return nil
+}
+
+func (f *fakeService) Persist(ctx context.Context, key string) error {
+	return f.Expire(ctx, key, 0)
+}
+
+func (f *fakeService) Txn(ctx context.Context, txn ports.Txn) (ports.TxnResult, error) {
+	f.mu.Lock()
+	result := ports.TxnResult{Succeeded: true}
+	for _, cmp := range txn.Compares {
+		v, found := f.data[cmp.Key]
+		equal := f.versions[cmp.Key] == cmp.Version
+		if cmp.Target == ports.CompareValue {
+			equal = found && v == cmp.Value
+		}
+		if equal == cmp.NotEqual {
+			result.Succeeded = false
+		}
+	}
+	ops := txn.Success
+	if !result.Succeeded {
+		ops = txn.Failure
+	}
+	f.index++
+	var published []events.Event
+	for _, op := range ops {
+		var r ports.TxnOpResult
+		switch op.Type {
+		case ports.TxnGet:
+			r.Value, r.Found = f.data[op.Key]
+			r.Version = f.versions[op.Key]
+		case ports.TxnSet:
+			f.data[op.Key], f.versions[op.Key] = op.Value, f.index
+			r.Version = f.index
+			published = append(published, events.Event{Type: events.Set, Key: op.Key, Index: f.index})
+		case ports.TxnDelete:
+			_, r.Found = f.data[op.Key]
+			delete(f.data, op.Key)
+			delete(f.versions, op.Key)
+			published = append(published, events.Event{Type: events.Delete, Key: op.Key, Index: f.index})
+		}
+		result.Results = append(result.Results, r)
+	}
+	f.mu.Unlock()
+	for _, e := range published {
+		f.events.Publish(e)
+	}
+	return result, nil
+}
+
+// BulkLoad sets each entry, failing at a key named "full" like a quota would.
+func (f *fakeService) BulkLoad(ctx context.Context, next func() (ports.BulkEntry, error)) (ports.BulkLoadResult, error) {
+	var result ports.BulkLoadResult
+	for {
+		e, err := next()
+		if err == io.EOF {
+			result.Batches = 1
+			return result, nil
+		}
+		if err != nil {
+			return result, err
+		}
+		if e.Key == "full" {
+			return result, coreerrors.ErrQuotaExceeded
+		}
+		if err := f.Set(ctx, e.Key, e.Value, e.TTL); err != nil {
+			return result, err
+		}
+		result.Loaded++
+	}
+}
+
+func (f *fakeService) Join(ctx context.Context, id, addr, grpcAddr string) error { return nil }
+
+func startServer(t *testing.T) (*fakeService, func(opts ...Option) *Client) {
+	t.Helper()
+	broker := events.NewBroker()
+	svc := &fakeService{data: map[string]string{}, versions: map[string]uint64{}, ttls: map[string]time.Duration{}, events: broker, zsets: store.New()}
+
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer()
+	pb.RegisterCacheServiceServer(srv, grpcAdapter.New(svc, grpcAdapter.WithEvents(broker), grpcAdapter.WithMaxStreamValueSize(16<<20)))
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	dial := WithDialOptions(grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+		return lis.DialContext(ctx)
+	}))
+	return svc, func(opts ...Option) *Client {
+		c, err := New("passthrough:///bufnet", append([]Option{dial}, opts...)...)
+		if err != nil {
+			t.Fatalf("new client: %v", err)
+		}
+		t.Cleanup(func() { c.Close() })
+		return c
+	}
+}
+
+func waitFor(t *testing.T, cond func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for !cond() {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for condition")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func isLive(n *nearCache) bool {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.live
+}
+
+func TestClient_GetSetDelete(t *testing.T) {
+	_, newClient := startServer(t)
+	c := newClient()
+	ctx := context.Background()
+
+	if err := c.Set(ctx, "k", "v", 0); err != nil {
+		t.Fatalf("set: %v", err)
+	}
+	if v, err := c.Get(ctx, "k"); err != nil || v != "v" {
+		t.Fatalf("expected v, got %q (%v)", v, err)
+	}
+	if err := c.Delete(ctx, "k"); err != nil {
+		t.Fatalf("delete: %v", err)
+	}
+	if _, err := c.Get(ctx, "k"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestClient_SetIfVersion(t *testing.T) {
+	_, newClient := startServer(t)
+	c := newClient()
+	ctx := context.Background()
+
+	v1, err := c.SetIfVersion(ctx, "k", "a", 0, 0)
+	if err != nil {
+		t.Fatalf("create: %v", err)
+	}
+	if _, err := c.SetIfVersion(ctx, "k", "b", 0, 0); !errors.Is(err, ErrVersionMismatch) {
+		t.Fatalf("expected ErrVersionMismatch for existing key, got %v", err)
+	}
+
+	val, version, err := c.GetVersioned(ctx, "k")
+	if err != nil || val != "a" || version != v1 {
+		t.Fatalf("expected a at version %d, got %q at %d (%v)", v1, val, version, err)
+	}
+	v2, err := c.SetIfVersion(ctx, "k", "b", 0, version)
+	if err != nil || v2 <= v1 {
+		t.Fatalf("expected a newer version than %d, got %d (%v)", v1, v2, err)
+	}
+	// A writer holding the old version loses.
+	if _, err := c.SetIfVersion(ctx, "k", "c", 0, v1); !errors.Is(err, ErrVersionMismatch) {
+		t.Fatalf("expected ErrVersionMismatch for stale version, got %v", err)
+	}
+}
+
+func TestClient_GetSetAndGetDel(t *testing.T) {
+	_, newClient := startServer(t)
+	c := newClient()
+	ctx := context.Background()
+
+	if _, found, err := c.GetSet(ctx, "k", "a", 0); err != nil || found {
+		t.Fatalf("expected no previous value, got found=%v (%v)", found, err)
+	}
+	if old, found, err := c.GetSet(ctx, "k", "b", 0); err != nil || !found || old != "a" {
+		t.Fatalf("expected a, got %q found=%v (%v)", old, found, err)
+	}
+	if v, err := c.GetDel(ctx, "k"); err != nil || v != "b" {
+		t.Fatalf("expected b, got %q (%v)", v, err)
+	}
+	if _, err := c.GetDel(ctx, "k"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestClient_GetOrSet(t *testing.T) {
+	_, newClient := startServer(t)
+	c := newClient()
+	ctx := context.Background()
+
+	if v, loaded, err := c.GetOrSet(ctx, "k", "a", 0); err != nil || loaded || v != "a" {
+		t.Fatalf("expected a to be stored, got %q loaded=%v (%v)", v, loaded, err)
+	}
+	if v, loaded, err := c.GetOrSet(ctx, "k", "b", 0); err != nil || !loaded || v != "a" {
+		t.Fatalf("expected a to be loaded, got %q loaded=%v (%v)", v, loaded, err)
+	}
+	if v, err := c.Get(ctx, "k"); err != nil || v != "a" {
+		t.Fatalf("expected a, got %q (%v)", v, err)
+	}
+}
+
+func TestClient_BulkLoad(t *testing.T) {
+	svc, newClient := startServer(t)
+	c := newClient()
+	ctx := context.Background()
+
+	// Enough entries for several messages.
+	l, err := c.BulkLoad(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	n := 2*bulkMessageEntries + 10
+	for i := 0; i < n; i++ {
+		if err := l.Add(fmt.Sprintf("k%d", i), "v", time.Minute); err != nil {
+			t.Fatal(err)
+		}
+	}
+	result, err := l.Close()
+	if err != nil || result.Loaded != n || result.Batches != 1 {
+		t.Fatalf("expected %d entries loaded, got %+v (%v)", n, result, err)
+	}
+	if v, err := c.Get(ctx, fmt.Sprintf("k%d", n-1)); err != nil || v != "v" {
+		t.Errorf("expected the last entry, got %q (%v)", v, err)
+	}
+
+	// A failed load reports how far it got.
+	l, err = c.BulkLoad(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, key := range []string{"a", "b", "full", "c"} {
+		l.Add(key, "v", 0)
+	}
+	result, err = l.Close()
+	if status.Code(err) != codes.ResourceExhausted || result.Loaded != 2 {
+		t.Errorf("expected ResourceExhausted after 2 entries, got %+v (%v)", result, err)
+	}
+	svc.mu.Lock()
+	_, stored := svc.data["c"]
+	svc.mu.Unlock()
+	if stored {
+		t.Error("expected the load to stop at the failure")
+	}
+}
+
+func TestClient_Streams(t *testing.T) {
+	_, newClient := startServer(t)
+	c := newClient()
+	ctx := context.Background()
+
+	// Larger than the default 4MB message limit, and not a whole number of chunks.
+	big := strings.Repeat("0123456789", (5<<20)/10+7)
+	if err := c.Set(ctx, "big", big, 0); status.Code(err) != codes.ResourceExhausted {
+		t.Fatalf("expected a plain Set to exceed the message limit, got %v", err)
+	}
+	version, err := c.SetStream(ctx, "big", strings.NewReader(big), time.Minute)
+	if err != nil || version == 0 {
+		t.Fatalf("set stream: version %d (%v)", version, err)
+	}
+	var got strings.Builder
+	if v, err := c.GetStream(ctx, "big", &got); err != nil || v != version {
+		t.Fatalf("expected version %d, got %d (%v)", version, v, err)
+	}
+	if got.String() != big {
+		t.Errorf("expected the %d byte value back, got %d bytes", len(big), got.Len())
+	}
+
+	// A reader without a length, and an empty value.
+	if _, err := c.SetStream(ctx, "small", io.MultiReader(strings.NewReader("a"), strings.NewReader("b")), 0); err != nil {
+		t.Fatal(err)
+	}
+	if v, err := c.Get(ctx, "small"); err != nil || v != "ab" {
+		t.Errorf("expected ab, got %q (%v)", v, err)
+	}
+	if _, err := c.SetStream(ctx, "empty", strings.NewReader(""), 0); err != nil {
+		t.Fatal(err)
+	}
+	got.Reset()
+	if _, err := c.GetStream(ctx, "empty", &got); err != nil || got.Len() != 0 {
+		t.Errorf("expected an empty value, got %q (%v)", got.String(), err)
+	}
+
+	if _, err := c.GetStream(ctx, "missing", io.Discard); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestClient_AppendAndStrLen(t *testing.T) {
+	_, newClient := startServer(t)
+	c := newClient()
+	ctx := context.Background()
+
+	if n, err := c.StrLen(ctx, "log"); err != nil || n != 0 {
+		t.Fatalf("expected 0 for a missing key, got %d (%v)", n, err)
+	}
+	for _, part := range []string{"a", "bc", "def"} {
+		if _, err := c.Append(ctx, "log", part); err != nil {
+			t.Fatalf("append: %v", err)
+		}
+	}
+	if n, err := c.StrLen(ctx, "log"); err != nil || n != 6 {
+		t.Fatalf("expected 6, got %d (%v)", n, err)
+	}
+	if v, err := c.Get(ctx, "log"); err != nil || v != "abcdef" {
+		t.Fatalf("expected abcdef, got %q (%v)", v, err)
+	}
+}
+
+func TestClient_Expiration(t *testing.T) {
+	_, newClient := startServer(t)
+	c := newClient()
+	ctx := context.Background()
+
+	if err := c.Expire(ctx, "k", time.Minute); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	if _, err := c.TTL(ctx, "k"); !errors.Is(err, ErrNotFound) {
+		t.Fatalf("expected ErrNotFound, got %v", err)
+	}
+	if err := c.Set(ctx, "k", "v", 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Expire(ctx, "k", time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if ttl, err := c.TTL(ctx, "k"); err != nil || ttl != time.Minute {
+		t.Fatalf("expected 1m, got %v (%v)", ttl, err)
+	}
+	if err := c.Persist(ctx, "k"); err != nil {
+		t.Fatal(err)
+	}
+	if ttl, err := c.TTL(ctx, "k"); err != nil || ttl != 0 {
+		t.Fatalf("expected no expiration, got %v (%v)", ttl, err)
+	}
+}
+
+func TestClient_SortedSets(t *testing.T) {
+	_, newClient := startServer(t)
+	c := newClient()
+	ctx := context.Background()
+
+	added, err := c.ZAdd(ctx, "board", ScoredMember{"alice", 30}, ScoredMember{"bob", 10}, ScoredMember{"carol", 20})
+	if err != nil || added != 3 {
+		t.Fatalf("expected 3 added, got %d (%v)", added, err)
+	}
+	top, err := c.ZRange(ctx, "board", -2, -1)
+	if err != nil || len(top) != 2 || top[1] != (ScoredMember{"alice", 30}) {
+		t.Fatalf("unexpected top two %v (%v)", top, err)
+	}
+	window, err := c.ZRangeByScore(ctx, "board", math.Inf(-1), 20, 0)
+	if err != nil || len(window) != 2 || window[0].Member != "bob" {
+		t.Fatalf("unexpected score window %v (%v)", window, err)
+	}
+	if score, found, err := c.ZScore(ctx, "board", "carol"); err != nil || !found || score != 20 {
+		t.Fatalf("expected carol at 20, got %v %v (%v)", score, found, err)
+	}
+	if n, err := c.ZRemRangeByScore(ctx, "board", 0, 15); err != nil || n != 1 {
+		t.Fatalf("expected 1 removed, got %d (%v)", n, err)
+	}
+}
+
+func TestClient_Eval(t *testing.T) {
+	_, newClient := startServer(t)
+	c := newClient()
+	ctx := context.Background()
+
+	src := "local n = (tonumber(cache.get(KEYS[1])) or 0) + ARGV[1]\ncache.set(KEYS[1], n)\nreturn {n, 'ok'}"
+	got, err := c.Eval(ctx, src, []string{"counter"}, "5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []interface{}{float64(5), "ok"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("expected %v, got %v", want, got)
+	}
+	if v, err := c.Get(ctx, "counter"); err != nil || v != "5" {
+		t.Fatalf("expected counter 5, got %q (%v)", v, err)
+	}
+
+	if _, err := c.Eval(ctx, "error('boom')", nil); status.Code(err) != codes.InvalidArgument {
+		t.Fatalf("expected InvalidArgument, got %v", err)
+	}
+}
+
+func TestClient_Txn(t *testing.T) {
+	_, newClient := startServer(t)
+	c := newClient()
+	ctx := context.Background()
+
+	version, err := c.SetIfVersion(ctx, "balance", "100", 0, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := c.Txn(ctx).
+		If(VersionIs("balance", version), ValueIs("frozen", "yes").Not()).
+		Then(OpSet("balance", "90", 0), OpDelete("pending"), OpGet("balance")).
+		Else(OpGet("balance")).
+		Commit()
+	if err != nil || !resp.Succeeded || len(resp.Results) != 3 || resp.Results[2].Value != "90" {
+		t.Fatalf("unexpected response %+v (%v)", resp, err)
+	}
+
+	// The version moved on, so the else branch runs.
+	resp, err = c.Txn(ctx).If(VersionIs("balance", version)).Then(OpSet("balance", "0", 0)).Else(OpGet("balance")).Commit()
+	if err != nil || resp.Succeeded || resp.Results[0].Value != "90" || resp.Results[0].Version <= version {
+		t.Fatalf("unexpected response %+v (%v)", resp, err)
+	}
+}
+
+func TestClient_NearCacheInvalidation(t *testing.T) {
+	svc, newClient := startServer(t)
+	near := newClient(WithNearCache(100, time.Minute))
+	writer := newClient()
+	ctx := context.Background()
+
+	waitFor(t, func() bool { return isLive(near.near) })
+	epoch := near.near.epochNow()
+	if err := writer.Set(ctx, "hot", "v1", 0); err != nil {
+		t.Fatalf("set: %v", err)
+	}
+	waitFor(t, func() bool { return near.near.epochNow() > epoch })
+
+	// First read goes to the server, the second is served locally.
+	for i := 0; i < 2; i++ {
+		if v, err := near.Get(ctx, "hot"); err != nil || v != "v1" {
+			t.Fatalf("expected v1, got %q (%v)", v, err)
+		}
+	}
+	if n := svc.gets.Load(); n != 1 {
+		t.Fatalf("expected 1 server read, got %d", n)
+	}
+
+	// A write from another client invalidates the near cache entry.
+	if err := writer.Set(ctx, "hot", "v2", 0); err != nil {
+		t.Fatalf("set: %v", err)
+	}
+	waitFor(t, func() bool { return near.near.len() == 0 })
+	if v, err := near.Get(ctx, "hot"); err != nil || v != "v2" {
+		t.Fatalf("expected v2, got %q (%v)", v, err)
+	}
+}
+
+func TestClient_Consistency(t *testing.T) {
+	svc, newClient := startServer(t)
+	c := newClient(WithNearCache(100, time.Minute))
+	ctx := context.Background()
+	if err := c.Set(ctx, "k", "v", 0); err != nil {
+		t.Fatalf("set: %v", err)
+	}
+
+	if _, err := c.Get(ctx, "k"); err != nil || svc.consistency != "" {
+		t.Fatalf("expected the server default, got %q (%v)", svc.consistency, err)
+	}
+	if _, _, err := c.GetVersioned(ContextWithConsistency(ctx, ConsistencyEventual), "k"); err != nil || svc.consistency != service.ConsistencyEventual {
+		t.Fatalf("expected eventual, got %q (%v)", svc.consistency, err)
+	}
+
+	// Strong reads skip the near cache.
+	gets := svc.gets.Load()
+	if _, err := c.Get(ContextWithConsistency(ctx, ConsistencyStrong), "k"); err != nil || svc.consistency != service.ConsistencyStrong {
+		t.Fatalf("expected strong, got %q (%v)", svc.consistency, err)
+	}
+	if svc.gets.Load() != gets+1 {
+		t.Error("expected a strong read to go to the server")
+	}
+}
+
+func TestClient_Ack(t *testing.T) {
+	svc, newClient := startServer(t)
+	c := newClient()
+	ctx := context.Background()
+
+	if err := c.Set(ctx, "k", "v", 0); err != nil || svc.ack != "" {
+		t.Fatalf("expected a quorum ack by default, got %q (%v)", svc.ack, err)
+	}
+	leader := ContextWithAck(ctx, AckLeader)
+	if err := c.Set(leader, "k", "v", 0); err != nil || svc.ack != service.AckLeader {
+		t.Fatalf("expected ack=leader, got %q (%v)", svc.ack, err)
+	}
+	svc.ack = ""
+	if err := c.Delete(leader, "k"); err != nil || svc.ack != service.AckLeader {
+		t.Fatalf("expected ack=leader, got %q (%v)", svc.ack, err)
+	}
+}
+
+func TestNearCache_BoundsAndTTL(t *testing.T) {
+	n := newNearCache(2, 20*time.Millisecond)
+	n.reset(true)
+
+	for _, k := range []string{"a", "b", "c"} {
+		n.put(k, k, n.epochNow())
+	}
+	if _, ok := n.get("a"); ok {
+		t.Error("expected least recently used entry to be evicted")
+	}
+	if n.len() != 2 {
+		t.Errorf("expected 2 entries, got %d", n.len())
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	if _, ok := n.get("c"); ok {
+		t.Error("expected entry to expire")
+	}
+}
+
+func TestNearCache_RejectsStaleFetch(t *testing.T) {
+	n := newNearCache(10, time.Minute)
+	n.reset(true)
+
+	epoch := n.epochNow()
+	n.invalidate("k") // remote write lands while the fetch is in flight
+	n.put("k", "stale", epoch)
+	if _, ok := n.get("k"); ok {
+		t.Error("expected fetch that raced an invalidation to be dropped")
+	}
+
+	n.reset(false)
+	n.put("k", "v", n.epochNow())
+	if _, ok := n.get("k"); ok {
+		t.Error("expected near cache to be bypassed while not watching")
+	}
+}
+
+// startCluster serves a fakeService per name, each holding key "k" set to its
+// name, and returns a client whose target and replicas dial them by name. The
+// first server is the client's target and runs primary on every RPC.
+func startCluster(t *testing.T, primary grpc.UnaryServerInterceptor, names ...string) func(opts ...Option) *Client {
+	t.Helper()
+	return startClusterWith(t, map[string]grpc.UnaryServerInterceptor{names[0]: primary}, names...)
+}
+
+// startClusterWith is startCluster with interceptors for any of the nodes.
+func startClusterWith(t *testing.T, interceptors map[string]grpc.UnaryServerInterceptor, names ...string) func(opts ...Option) *Client {
+	t.Helper()
+	listeners := map[string]*bufconn.Listener{}
+	for _, name := range names {
+		svc := &fakeService{data: map[string]string{"k": name}, versions: map[string]uint64{"k": 1}, ttls: map[string]time.Duration{}, events: events.NewBroker(), zsets: store.New()}
+		var opts []grpc.ServerOption
+		if interceptor, ok := interceptors[name]; ok {
+			opts = append(opts, grpc.UnaryInterceptor(interceptor))
+		}
+		lis := bufconn.Listen(1 << 20)
+		srv := grpc.NewServer(opts...)
+		pb.RegisterCacheServiceServer(srv, grpcAdapter.New(svc))
+		go srv.Serve(lis)
+		t.Cleanup(srv.Stop)
+		listeners[name] = lis
+	}
+
+	dial := WithDialOptions(grpc.WithContextDialer(func(ctx context.Context, addr string) (net.Conn, error) {
+		lis, ok := listeners[addr]
+		if !ok {
+			return nil, fmt.Errorf("dial %s: connection refused", addr)
+		}
+		return lis.DialContext(ctx)
+	}))
+	return func(opts ...Option) *Client {
+		c, err := New("passthrough:///"+names[0], append([]Option{dial}, opts...)...)
+		if err != nil {
+			t.Fatalf("new client: %v", err)
+		}
+		t.Cleanup(func() { c.Close() })
+		return c
+	}
+}
+
+func TestClient_Hedging(t *testing.T) {
+	ctx := context.Background()
+	var stats []ReadStats
+	var mu sync.Mutex
+	observe := WithReadObserver(func(s ReadStats) {
+		mu.Lock()
+		defer mu.Unlock()
+		stats = append(stats, s)
+	})
+	last := func() ReadStats {
+		mu.Lock()
+		defer mu.Unlock()
+		return stats[len(stats)-1]
+	}
+
+	t.Run("FastPrimary", func(t *testing.T) {
+		newClient := startCluster(t, func(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			return handler(ctx, req)
+		}, "primary", "replica")
+		c := newClient(WithHedging(time.Second, "passthrough:///replica"), observe)
+		if v, err := c.Get(ctx, "k"); err != nil || v != "primary" {
+			t.Fatalf("expected the primary's answer, got %q (%v)", v, err)
+		}
+		if s := last(); s.Hedged || s.Method != "Get" || s.Target != "passthrough:///primary" {
+			t.Errorf("unexpected stats %+v", s)
+		}
+	})
+
+	t.Run("SlowPrimary", func(t *testing.T) {
+		newClient := startCluster(t, func(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			select {
+			case <-time.After(5 * time.Second):
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			}
+			return handler(ctx, req)
+		}, "primary", "replica")
+		c := newClient(WithHedging(20*time.Millisecond, "passthrough:///replica"), observe)
+		start := time.Now()
+		if v, err := c.Get(ctx, "k"); err != nil || v != "replica" {
+			t.Fatalf("expected the replica's answer, got %q (%v)", v, err)
+		}
+		if time.Since(start) > 2*time.Second {
+			t.Error("expected the hedge to answer before the primary")
+		}
+		if s := last(); !s.Hedged || s.Target != "passthrough:///replica" || s.Latency < 20*time.Millisecond {
+			t.Errorf("unexpected stats %+v", s)
+		}
+	})
+
+	t.Run("UnavailablePrimary", func(t *testing.T) {
+		newClient := startCluster(t, func(context.Context, interface{}, *grpc.UnaryServerInfo, grpc.UnaryHandler) (interface{}, error) {
+			return nil, status.Error(codes.Unavailable, "node is not the leader")
+		}, "primary", "replica")
+		c := newClient(WithHedging(time.Minute, "passthrough:///replica"), observe)
+		if n, err := c.StrLen(ctx, "k"); err != nil || n != len("replica") {
+			t.Fatalf("expected the replica's answer, got %d (%v)", n, err)
+		}
+		if s := last(); !s.Hedged || s.Method != "StrLen" {
+			t.Errorf("unexpected stats %+v", s)
+		}
+
+		// Answers other than Unavailable are final, including NotFound.
+		if _, err := c.TTL(ctx, "missing"); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("expected ErrNotFound, got %v", err)
+		}
+	})
+}
+
+func TestClient_AsyncWrites(t *testing.T) {
+	svc, newClient := startServer(t)
+	ctx := context.Background()
+	index := func() uint64 {
+		svc.mu.Lock()
+		defer svc.mu.Unlock()
+		return svc.index
+	}
+
+	t.Run("Unbatched", func(t *testing.T) {
+		c := newClient(WithPoolSize(3))
+		if err := c.SetAsync(ctx, "a", "1", 0).Wait(ctx); err != nil {
+			t.Fatalf("set async: %v", err)
+		}
+		if err := c.DeleteAsync(ctx, "a").Wait(ctx); err != nil {
+			t.Fatalf("delete async: %v", err)
+		}
+		if _, err := c.Get(ctx, "a"); !errors.Is(err, ErrNotFound) {
+			t.Fatalf("expected the key to be deleted, got %v", err)
+		}
+	})
+
+	t.Run("FullBatch", func(t *testing.T) {
+		c := newClient(WithPipelining(3, time.Minute))
+		before := index()
+		futures := []*Future{c.SetAsync(ctx, "x", "1", 0), c.SetAsync(ctx, "y", "2", 0), c.DeleteAsync(ctx, "x")}
+		for _, f := range futures {
+			if err := f.Wait(ctx); err != nil {
+				t.Fatalf("write: %v", err)
+			}
+		}
+		if n := index() - before; n != 1 {
+			t.Errorf("expected one replicated batch, got %d", n)
+		}
+		if _, err := c.Get(ctx, "x"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("expected writes to apply in order, got %v", err)
+		}
+		if v, err := c.Get(ctx, "y"); err != nil || v != "2" {
+			t.Errorf("expected y=2, got %q (%v)", v, err)
+		}
+	})
+
+	t.Run("Linger", func(t *testing.T) {
+		c := newClient(WithPipelining(100, 20*time.Millisecond))
+		f := c.SetAsync(ctx, "l", "1", 0)
+		select {
+		case <-f.Done():
+			t.Fatal("expected the write to wait for linger")
+		case <-time.After(5 * time.Millisecond):
+		}
+		if err := f.Wait(ctx); err != nil {
+			t.Fatalf("write: %v", err)
+		}
+	})
+
+	t.Run("FlushAndCancel", func(t *testing.T) {
+		c := newClient(WithPipelining(100, time.Minute))
+		cancelled, cancel := context.WithCancel(ctx)
+		dropped := c.SetAsync(cancelled, "dropped", "1", 0)
+		cancel()
+		kept := c.SetAsync(ctx, "kept", "1", 0)
+		c.Flush()
+		if err := kept.Err(); err != nil {
+			t.Fatalf("expected Flush to send the batch, got %v", err)
+		}
+		if err := dropped.Err(); !errors.Is(err, context.Canceled) {
+			t.Errorf("expected the cancelled write to be dropped, got %v", err)
+		}
+		if _, err := c.Get(ctx, "dropped"); !errors.Is(err, ErrNotFound) {
+			t.Errorf("expected the cancelled write not to apply, got %v", err)
+		}
+
+		// Close sends what is still queued.
+		f := c.SetAsync(ctx, "closed", "1", 0)
+		c.Close()
+		if err := f.Err(); err != nil {
+			t.Errorf("expected Close to send the batch, got %v", err)
+		}
+	})
+}
+
+func TestClient_Compression(t *testing.T) {
+	for name, opt := range map[string]Option{"gzip": WithGzip(), "zstd": WithZstd()} {
+		t.Run(name, func(t *testing.T) {
+			_, newClient := startServer(t)
+			c := newClient(opt)
+			ctx := context.Background()
+			value := strings.Repeat("compressible ", 1000)
+			if err := c.Set(ctx, "big", value, 0); err != nil {
+				t.Fatalf("set: %v", err)
+			}
+			if v, err := c.Get(ctx, "big"); err != nil || v != value {
+				t.Fatalf("expected the value back, got %d bytes (%v)", len(v), err)
+			}
+		})
+	}
+}
+
+// notLeader returns the status a follower refuses a call with, naming leader
+// if it is not empty.
+func notLeader(leader string, after time.Duration) error {
+	info := &errdetails.ErrorInfo{Reason: grpcAdapter.ReasonNotLeader, Domain: grpcAdapter.ErrorDomain}
+	if leader != "" {
+		info.Metadata = map[string]string{grpcAdapter.MetadataLeader: leader}
+	}
+	st, _ := status.New(codes.FailedPrecondition, "not leader").WithDetails(info, &errdetails.RetryInfo{RetryDelay: durationpb.New(after)})
+	return st.Err()
+}
+
+func TestClient_ElectionRetry(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("FollowsLeader", func(t *testing.T) {
+		var refused atomic.Int64
+		newClient := startCluster(t, func(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			refused.Add(1)
+			return nil, notLeader("passthrough:///leader", 0)
+		}, "follower", "leader")
+		c := newClient()
+		for range 2 {
+			if v, err := c.Get(ctx, "k"); err != nil || v != "leader" {
+				t.Fatalf("expected the leader's answer, got %q (%v)", v, err)
+			}
+		}
+		if n := refused.Load(); n != 1 {
+			t.Errorf("expected later calls to go to the leader, the follower refused %d", n)
+		}
+		if target := c.primary.Load().target; target != "passthrough:///leader" {
+			t.Errorf("expected the leader to be the primary, got %s", target)
+		}
+	})
+
+	t.Run("UnreachableLeader", func(t *testing.T) {
+		var calls atomic.Int64
+		newClient := startCluster(t, func(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			if calls.Add(1) == 1 {
+				return nil, notLeader("passthrough:///gone", 0)
+			}
+			return handler(ctx, req)
+		}, "node")
+		c := newClient(WithElectionRetry(time.Millisecond, 2*time.Millisecond, time.Second))
+		if v, err := c.Get(ctx, "k"); err != nil || v != "node" {
+			t.Fatalf("expected the call to be retried on the target, got %q (%v)", v, err)
+		}
+		if target := c.primary.Load().target; target != "passthrough:///node" {
+			t.Errorf("expected the target to stay the primary, got %s", target)
+		}
+	})
+
+	t.Run("LeaderAnswersUnavailable", func(t *testing.T) {
+		// A leader that loses leadership while applying a write answers
+		// UNAVAILABLE without a hint: the write may have been applied, so it
+		// must not be sent again.
+		var refused, lost atomic.Int64
+		newClient := startClusterWith(t, map[string]grpc.UnaryServerInterceptor{
+			"follower": func(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+				refused.Add(1)
+				return nil, notLeader("passthrough:///leader", 0)
+			},
+			"leader": func(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+				lost.Add(1)
+				return nil, status.Error(codes.Unavailable, "leadership lost, outcome unknown")
+			},
+		}, "follower", "leader")
+		c := newClient(WithElectionRetry(time.Millisecond, 2*time.Millisecond, time.Second))
+		if _, err := c.Append(ctx, "k", "x"); status.Code(err) != codes.Unavailable {
+			t.Fatalf("expected the leader's UNAVAILABLE, got %v", err)
+		}
+		if r, l := refused.Load(), lost.Load(); r != 1 || l != 1 {
+			t.Errorf("expected one call to each node, got %d to the follower and %d to the leader", r, l)
+		}
+	})
+
+	t.Run("BacksOffDuringElection", func(t *testing.T) {
+		var calls atomic.Int64
+		newClient := startCluster(t, func(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			if calls.Add(1) <= 3 {
+				return nil, notLeader("", 0)
+			}
+			return handler(ctx, req)
+		}, "node")
+		c := newClient(WithElectionRetry(time.Millisecond, 2*time.Millisecond, time.Second))
+		if err := c.Set(ctx, "k", "v", 0); err != nil {
+			t.Fatalf("expected the write to succeed once a leader is elected, got %v", err)
+		}
+		if n := calls.Load(); n != 4 {
+			t.Errorf("expected 4 attempts, got %d", n)
+		}
+	})
+
+	t.Run("Budget", func(t *testing.T) {
+		var calls atomic.Int64
+		newClient := startCluster(t, func(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			calls.Add(1)
+			return nil, notLeader("", time.Hour)
+		}, "node")
+		c := newClient()
+		if err := c.Set(ctx, "k", "v", 0); status.Code(err) != codes.FailedPrecondition {
+			t.Errorf("expected the refusal once the wait exceeds the budget, got %v", err)
+		}
+		c = newClient(WithElectionRetry(time.Millisecond, time.Millisecond, 0))
+		if err := c.Set(ctx, "k", "v", 0); err == nil {
+			t.Error("expected no retries with a budget of 0")
+		}
+		if n := calls.Load(); n != 2 {
+			t.Errorf("expected one attempt per call, got %d", n)
+		}
+	})
+}
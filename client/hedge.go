@@ -0,0 +1,123 @@
+package client
+
+import (
+	"context"
+	"time"
+
+	pb "distributed-cache-service/proto"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// ReadStats describes one read sent to the cluster, for tuning the hedge delay.
+type ReadStats struct {
+	// Method is the RPC, e.g. "Get" or "ZRange".
+	Method string
+	// Target is the node whose response was used.
+	Target string
+	// Latency is the time from sending the read to receiving that response.
+	Latency time.Duration
+	// Hedged is true if the read was also sent to a replica.
+	Hedged bool
+	// Err is the error returned to the caller, if any.
+	Err error
+}
+
+// WithHedging sends a read to the next of replicas (host:port, in turn) if the
+// primary target has not answered within delay, and returns whichever answer
+// arrives first. A read that fails with codes.Unavailable, e.g. a follower
+// asked for a strong read, is hedged at once and does not count as an answer.
+// Hedged reads add load: pick a delay around the p95 read latency.
+func WithHedging(delay time.Duration, replicas ...string) Option {
+	return func(c *Client) {
+		c.hedgeDelay = delay
+		c.hedgeTargets = replicas
+	}
+}
+
+// WithReadObserver calls fn after every read sent to the cluster (Get,
+// GetVersioned, StrLen, TTL, ZRange, ZRangeByScore, ZScore), hedged or not.
+// Reads served from the near cache are not reported. fn must not block.
+func WithReadObserver(fn func(ReadStats)) Option {
+	return func(c *Client) {
+		c.observe = fn
+	}
+}
+
+// answered reports whether err, returned by a node, is its answer to the read
+// rather than a sign that another node should be asked.
+func answered(err error) bool {
+	return status.Code(err) != codes.Unavailable
+}
+
+// hedged runs call against the primary target and, if it is slow or
+// unavailable, against a replica, returning the first answer.
+func hedged[T any](ctx context.Context, c *Client, method string, call func(context.Context, pb.CacheServiceClient) (T, error)) (T, error) {
+	start := time.Now()
+	if len(c.replicas) == 0 {
+		p := c.primary.Load()
+		resp, err := call(ctx, p.pick())
+		c.report(ReadStats{Method: method, Target: p.target, Latency: time.Since(start), Err: err})
+		return resp, err
+	}
+
+	// Cancelling ctx abandons the losing request once an answer is in.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type result struct {
+		resp   T
+		err    error
+		target string
+	}
+	results := make(chan result, 2)
+	send := func(target string, cache pb.CacheServiceClient) {
+		go func() {
+			resp, err := call(ctx, cache)
+			results <- result{resp, err, target}
+		}()
+	}
+
+	p := c.primary.Load()
+	send(p.target, p.pick())
+	pending, hedge := 1, false
+	startHedge := func() {
+		r := c.replicas[int(c.nextReplica.Add(1)-1)%len(c.replicas)]
+		send(r.target, r.pick())
+		pending, hedge = pending+1, true
+	}
+
+	timer := time.NewTimer(c.hedgeDelay)
+	defer timer.Stop()
+	var failed *result
+	for {
+		select {
+		case r := <-results:
+			pending--
+			if answered(r.err) {
+				c.report(ReadStats{Method: method, Target: r.target, Latency: time.Since(start), Hedged: hedge, Err: r.err})
+				return r.resp, r.err
+			}
+			if failed == nil {
+				failed = &r
+			}
+			if !hedge {
+				startHedge()
+			} else if pending == 0 {
+				c.report(ReadStats{Method: method, Target: failed.target, Latency: time.Since(start), Hedged: hedge, Err: failed.err})
+				return failed.resp, failed.err
+			}
+		case <-timer.C:
+			if !hedge {
+				startHedge()
+			}
+		}
+	}
+}
+
+func (c *Client) report(stats ReadStats) {
+	if c.observe != nil {
+		c.observe(stats)
+	}
+}
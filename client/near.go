@@ -0,0 +1,109 @@
+package client
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// nearCache is a bounded LRU with per-entry TTL.
+//
+// Values fetched from the server are only admitted if no invalidation happened
+// while the fetch was in flight (tracked by epoch), and only while the watch
+// stream is live. Otherwise a racing remote write could leave a stale value
+// cached until its TTL expires.
+type nearCache struct {
+	mu         sync.Mutex
+	maxEntries int
+	ttl        time.Duration
+	ll         *list.List
+	items      map[string]*list.Element
+	epoch      uint64
+	live       bool
+}
+
+type nearEntry struct {
+	key     string
+	value   string
+	expires time.Time
+}
+
+func newNearCache(maxEntries int, ttl time.Duration) *nearCache {
+	return &nearCache{
+		maxEntries: maxEntries,
+		ttl:        ttl,
+		ll:         list.New(),
+		items:      make(map[string]*list.Element),
+	}
+}
+
+func (n *nearCache) get(key string) (string, bool) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	elem, ok := n.items[key]
+	if !ok {
+		return "", false
+	}
+	e := elem.Value.(*nearEntry)
+	if time.Now().After(e.expires) {
+		n.remove(elem)
+		return "", false
+	}
+	n.ll.MoveToFront(elem)
+	return e.value, true
+}
+
+// epochNow returns the current epoch, to be passed to put after a fetch.
+func (n *nearCache) epochNow() uint64 {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.epoch
+}
+
+// put admits a fetched value unless the cache was invalidated since epoch.
+func (n *nearCache) put(key, value string, epoch uint64) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if !n.live || epoch != n.epoch || n.maxEntries <= 0 {
+		return
+	}
+	if elem, ok := n.items[key]; ok {
+		n.remove(elem)
+	}
+	n.items[key] = n.ll.PushFront(&nearEntry{key: key, value: value, expires: time.Now().Add(n.ttl)})
+	for n.ll.Len() > n.maxEntries {
+		n.remove(n.ll.Back())
+	}
+}
+
+// invalidate drops key and fences off fetches that started before the change.
+func (n *nearCache) invalidate(key string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.epoch++
+	if elem, ok := n.items[key]; ok {
+		n.remove(elem)
+	}
+}
+
+// reset drops every entry and records whether invalidations are being received.
+func (n *nearCache) reset(live bool) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.epoch++
+	n.live = live
+	n.ll.Init()
+	clear(n.items)
+}
+
+// len returns the number of cached entries, including expired ones.
+func (n *nearCache) len() int {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return n.ll.Len()
+}
+
+func (n *nearCache) remove(elem *list.Element) {
+	n.ll.Remove(elem)
+	delete(n.items, elem.Value.(*nearEntry).key)
+}
@@ -0,0 +1,188 @@
+package client
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	pb "distributed-cache-service/proto"
+)
+
+// maxBatch is the server's limit on the ops of a transaction.
+const maxBatch = 128
+
+// pipelineTimeout bounds the RPC that sends a batch.
+const pipelineTimeout = 10 * time.Second
+
+// Future is the outcome of an asynchronous write.
+type Future struct {
+	done chan struct{}
+	err  error
+}
+
+func newFuture() *Future {
+	return &Future{done: make(chan struct{})}
+}
+
+func (f *Future) resolve(err error) {
+	f.err = err
+	close(f.done)
+}
+
+// Done is closed once the write has completed.
+func (f *Future) Done() <-chan struct{} {
+	return f.done
+}
+
+// Wait blocks until the write has completed and returns its error, or until
+// ctx is done. Giving up on the wait does not cancel the write.
+func (f *Future) Wait(ctx context.Context) error {
+	select {
+	case <-f.done:
+		return f.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Err returns the write's error. It must only be called after Done is closed.
+func (f *Future) Err() error {
+	return f.err
+}
+
+// WithPipelining makes SetAsync and DeleteAsync queue their writes and send
+// them together as one transaction, a single Raft round trip, once batchSize
+// writes are queued (at most 128) or linger has passed since the first. The
+// writes of a batch land atomically and in the order they were queued; if the
+// batch fails, every write in it fails with the same error.
+func WithPipelining(batchSize int, linger time.Duration) Option {
+	return func(c *Client) {
+		c.pipeline = &pipeline{batchSize: min(max(batchSize, 1), maxBatch), linger: linger}
+	}
+}
+
+type queuedOp struct {
+	ctx context.Context
+	op  *pb.TxnOp
+	f   *Future
+}
+
+// pipeline batches asynchronous writes into transactions.
+type pipeline struct {
+	batchSize int
+	linger    time.Duration
+
+	mu     sync.Mutex
+	queue  []queuedOp
+	timer  *time.Timer
+	flying sync.WaitGroup // batches being sent
+}
+
+// SetAsync stores value under key like Set, without waiting for the result.
+// With WithPipelining, the write is batched with others; writes carrying a
+// request ID (ContextWithRequestID) are sent on their own.
+func (c *Client) SetAsync(ctx context.Context, key, value string, ttl time.Duration) *Future {
+	return c.async(ctx, &pb.TxnOp{Type: pb.TxnOp_SET, Key: key, Value: value, Ttl: int64(ttl / time.Second)}, func() error {
+		return c.Set(ctx, key, value, ttl)
+	})
+}
+
+// DeleteAsync removes key like Delete, without waiting for the result.
+func (c *Client) DeleteAsync(ctx context.Context, key string) *Future {
+	return c.async(ctx, &pb.TxnOp{Type: pb.TxnOp_DELETE, Key: key}, func() error {
+		return c.Delete(ctx, key)
+	})
+}
+
+func (c *Client) async(ctx context.Context, op *pb.TxnOp, direct func() error) *Future {
+	f := newFuture()
+	if c.pipeline == nil || requestID(ctx) != "" {
+		c.inflight.Add(1)
+		go func() {
+			defer c.inflight.Done()
+			f.resolve(direct())
+		}()
+		return f
+	}
+	if c.near != nil {
+		c.near.invalidate(op.Key)
+	}
+	c.pipeline.enqueue(c, queuedOp{ctx: ctx, op: op, f: f})
+	return f
+}
+
+// Flush sends the queued asynchronous writes without waiting for linger.
+func (c *Client) Flush() {
+	if c.pipeline != nil {
+		c.pipeline.flush(c)
+	}
+}
+
+func (p *pipeline) enqueue(c *Client, q queuedOp) {
+	p.mu.Lock()
+	p.queue = append(p.queue, q)
+	if len(p.queue) >= p.batchSize {
+		batch := p.take()
+		p.mu.Unlock()
+		go p.send(c, batch)
+		return
+	}
+	if len(p.queue) == 1 {
+		p.timer = time.AfterFunc(p.linger, func() { p.flush(c) })
+	}
+	p.mu.Unlock()
+}
+
+func (p *pipeline) flush(c *Client) {
+	p.mu.Lock()
+	batch := p.take()
+	p.mu.Unlock()
+	if len(batch) > 0 {
+		p.send(c, batch)
+	}
+}
+
+// take empties the queue and registers it as in flight. p.mu must be held.
+func (p *pipeline) take() []queuedOp {
+	if p.timer != nil {
+		p.timer.Stop()
+		p.timer = nil
+	}
+	batch := p.queue
+	p.queue = nil
+	if len(batch) > 0 {
+		p.flying.Add(1)
+	}
+	return batch
+}
+
+func (p *pipeline) send(c *Client, batch []queuedOp) {
+	defer p.flying.Done()
+	req := &pb.TxnRequest{}
+	var live []queuedOp
+	for _, q := range batch {
+		// Writes abandoned while queued are dropped.
+		if err := q.ctx.Err(); err != nil {
+			q.f.resolve(err)
+			continue
+		}
+		req.Success = append(req.Success, q.op)
+		live = append(live, q)
+	}
+	if len(live) == 0 {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), pipelineTimeout)
+	defer cancel()
+	_, err := c.primary.Load().pick().Txn(ctx, req)
+	for _, q := range live {
+		q.f.resolve(err)
+	}
+}
+
+// close sends what is queued and waits for every batch to complete.
+func (p *pipeline) close(c *Client) {
+	p.flush(c)
+	p.flying.Wait()
+}
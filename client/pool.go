@@ -0,0 +1,67 @@
+package client
+
+import (
+	"errors"
+	"sync/atomic"
+
+	pb "distributed-cache-service/proto"
+
+	"google.golang.org/grpc"
+)
+
+// WithPoolSize opens n connections to each node and spreads calls over them.
+// One connection is enough for most clients; more help writers that keep
+// hundreds of calls in flight.
+func WithPoolSize(n int) Option {
+	return func(c *Client) {
+		c.poolSize = n
+	}
+}
+
+// pool is a set of connections to one node, used in turn. A single HTTP/2
+// connection caps the number of concurrent streams and serialises writes on
+// one socket; spreading calls over several lifts both limits.
+type pool struct {
+	target string
+	conns  []*grpc.ClientConn
+	stubs  []pb.CacheServiceClient
+	next   atomic.Uint64
+}
+
+func dialPool(target string, size int, opts []grpc.DialOption) (*pool, error) {
+	p := &pool{target: target}
+	for range max(size, 1) {
+		conn, err := grpc.NewClient(target, opts...)
+		if err != nil {
+			p.close()
+			return nil, err
+		}
+		p.conns = append(p.conns, conn)
+		p.stubs = append(p.stubs, pb.NewCacheServiceClient(conn))
+	}
+	return p, nil
+}
+
+// pick returns the stub for the next connection.
+func (p *pool) pick() pb.CacheServiceClient {
+	if len(p.stubs) == 1 {
+		return p.stubs[0]
+	}
+	return p.stubs[(p.next.Add(1)-1)%uint64(len(p.stubs))]
+}
+
+// conn returns the next connection, like pick.
+func (p *pool) conn() *grpc.ClientConn {
+	if len(p.conns) == 1 {
+		return p.conns[0]
+	}
+	return p.conns[(p.next.Add(1)-1)%uint64(len(p.conns))]
+}
+
+func (p *pool) close() error {
+	var err error
+	for _, conn := range p.conns {
+		err = errors.Join(err, conn.Close())
+	}
+	return err
+}
@@ -0,0 +1,178 @@
+package client
+
+import (
+	"context"
+	"time"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/status"
+)
+
+// Defaults of WithElectionRetry.
+const (
+	DefaultRetryInitial = 50 * time.Millisecond
+	DefaultRetryMax     = time.Second
+	DefaultRetryBudget  = 5 * time.Second
+)
+
+// The retry hints a node attaches to calls it refused during a leader change,
+// as a google.rpc.ErrorInfo with one of these reasons.
+const (
+	errorDomain     = "distributed-cache-service"
+	reasonNotLeader = "NOT_LEADER"
+	reasonNoQuorum  = "NO_QUORUM"
+	metadataLeader  = "leader"
+)
+
+// WithElectionRetry sets how calls refused during a leader election are
+// retried. Such calls were never carried out, so they are retried, writes
+// included, until they succeed or budget has passed. When the node names the
+// new leader, the call goes there at once, and so do later calls. Otherwise it
+// is retried after a backoff that starts at initial and doubles up to max, or
+// after the wait the node asked for, if longer. A budget of 0 turns retries
+// off. Streaming calls (BulkLoad, GetStream, SetStream) are not retried.
+func WithElectionRetry(initial, max, budget time.Duration) Option {
+	return func(c *Client) {
+		c.retryInitial = initial
+		c.retryMax = max
+		c.retryBudget = budget
+	}
+}
+
+// retryHint is what a node said about where and when to retry a call.
+type retryHint struct {
+	leader string
+	after  time.Duration
+}
+
+// electionHint returns the retry hint of err, if it is a call refused during
+// a leader change.
+func electionHint(err error) (retryHint, bool) {
+	st, ok := status.FromError(err)
+	if !ok || err == nil {
+		return retryHint{}, false
+	}
+	var h retryHint
+	election := false
+	for _, d := range st.Details() {
+		switch d := d.(type) {
+		case *errdetails.ErrorInfo:
+			if d.Domain == errorDomain && (d.Reason == reasonNotLeader || d.Reason == reasonNoQuorum) {
+				election = true
+				h.leader = d.Metadata[metadataLeader]
+			}
+		case *errdetails.RetryInfo:
+			h.after = d.RetryDelay.AsDuration()
+		}
+	}
+	return h, election
+}
+
+// retryElection is a unary interceptor that retries calls refused during a
+// leader change, following the new leader when it is named. A leader that
+// cannot be reached, for example at an address only its peers can dial, is
+// not followed, but a leader that answers is, whatever its answer: the call is retried on the primary node, and a primary that
+// was a followed leader gives way to the target the client was created with.
+func (c *Client) retryElection(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	err := invoker(ctx, method, req, reply, cc, opts...)
+	if c.retryBudget <= 0 {
+		return err
+	}
+	deadline := time.Now().Add(c.retryBudget)
+	backoff := c.retryInitial
+	var (
+		leader      *pool // the leader the call was last sent to, if redirected
+		unreachable string
+	)
+	for {
+		h, ok := electionHint(err)
+		if !ok && unreachableNode(err, cc) && cc.Target() != c.target &&
+			(leader != nil || cc.Target() == c.primary.Load().target) {
+			unreachable = cc.Target()
+			p, derr := c.follow(c.target)
+			if derr != nil || leader == nil {
+				// Only a call sent to the leader on a hint is known not to
+				// have been carried out; others fail, and later calls go to
+				// the target.
+				return err
+			}
+			cc, h, ok = p.conn(), retryHint{}, true
+		} else if leader != nil {
+			// The leader answered: later calls go to it too.
+			c.primary.Store(leader)
+		}
+		if !ok {
+			return err
+		}
+		var wait time.Duration
+		if h.leader != "" && h.leader != cc.Target() && h.leader != unreachable && leader == nil {
+			// Go straight to the leader, but only once in a row, so that
+			// nodes with stale views cannot bounce the call back and forth.
+			p, derr := c.connect(h.leader)
+			if derr != nil {
+				return err
+			}
+			cc, wait, leader = p.conn(), h.after, p
+		} else {
+			wait = max(backoff, h.after)
+			backoff = min(backoff*2, c.retryMax)
+			leader = nil
+		}
+		if time.Until(deadline) < wait {
+			return err
+		}
+		if wait > 0 {
+			t := time.NewTimer(wait)
+			select {
+			case <-ctx.Done():
+				t.Stop()
+				return status.FromContextError(ctx.Err()).Err()
+			case <-t.C:
+			}
+		}
+		err = invoker(ctx, method, req, reply, cc, opts...)
+	}
+}
+
+// unreachableNode reports whether err is a failure to reach the node behind cc,
+// rather than its answer. A node that answers UNAVAILABLE may have started
+// the call, for example when it lost leadership while applying a write, so
+// only a call that failed to get through may be sent elsewhere.
+func unreachableNode(err error, cc *grpc.ClientConn) bool {
+	st, _ := status.FromError(err)
+	return st.Code() == codes.Unavailable && len(st.Details()) == 0 &&
+		cc.GetState() == connectivity.TransientFailure
+}
+
+// follow makes target the primary node, connecting to it unless it already is
+// connected, and returns its pool.
+func (c *Client) follow(target string) (*pool, error) {
+	p, err := c.connect(target)
+	if err != nil {
+		return nil, err
+	}
+	c.primary.Store(p)
+	return p, nil
+}
+
+// connect returns the pool of target, connecting to it unless it already is
+// connected.
+func (c *Client) connect(target string) (*pool, error) {
+	c.poolsMu.Lock()
+	defer c.poolsMu.Unlock()
+	p, ok := c.pools[target]
+	if !ok {
+		var err error
+		if p, err = dialPool(target, c.poolSize, c.dialOpts); err != nil {
+			return nil, err
+		}
+		if c.pools == nil {
+			c.pools = make(map[string]*pool)
+		}
+		c.pools[target] = p
+	}
+	return p, nil
+}
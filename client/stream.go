@@ -0,0 +1,103 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"io"
+	"time"
+
+	pb "distributed-cache-service/proto"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// streamChunkSize is how much of a value each message of GetStream and
+// SetStream carries, well under gRPC's default 4MB message limit.
+const streamChunkSize = 1 << 20
+
+// GetStream writes the value of key to w as it arrives, in chunks, and returns
+// its version, or ErrNotFound. Unlike Get it works for values larger than
+// the message size limit, and never holds the whole value in memory. It always
+// reads from the server, bypassing the near cache. If it fails after writing
+// to w, w holds part of the value.
+func (c *Client) GetStream(ctx context.Context, key string, w io.Writer) (uint64, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stream, err := c.primary.Load().pick().GetStream(ctx, &pb.GetStreamRequest{Key: key, Consistency: consistency(ctx), ChunkSize: streamChunkSize})
+	if err != nil {
+		return 0, err
+	}
+	var version uint64
+	for first := true; ; first = false {
+		chunk, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			return version, nil
+		}
+		if err != nil {
+			if status.Code(err) == codes.NotFound {
+				return 0, ErrNotFound
+			}
+			return 0, err
+		}
+		if first {
+			version = chunk.Version
+		}
+		if _, err := w.Write(chunk.Data); err != nil {
+			return 0, err
+		}
+	}
+}
+
+// SetStream stores the contents of r under key, sending them in chunks, and
+// returns the key's new version. Unlike Set it works for values larger than
+// the message size limit, up to the server's -grpc_max_stream_value_size. If r
+// has a Len method, as bytes.Reader and strings.Reader do, a value over that
+// limit is refused on its first chunk. A ttl of 0 means no expiration;
+// otherwise it is rounded down to whole seconds.
+func (c *Client) SetStream(ctx context.Context, key string, r io.Reader, ttl time.Duration) (uint64, error) {
+	if c.near != nil {
+		c.near.invalidate(key)
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stream, err := c.primary.Load().pick().SetStream(ctx)
+	if err != nil {
+		return 0, err
+	}
+	req := &pb.SetStreamRequest{Header: &pb.SetRequest{
+		Key:       key,
+		Ttl:       int64(ttl / time.Second),
+		RequestId: requestID(ctx),
+		Ack:       ack(ctx),
+	}}
+	if l, ok := r.(interface{ Len() int }); ok {
+		req.Size = int64(l.Len())
+	}
+	for {
+		// The message may still be read after Send returns, so each chunk
+		// is read into a buffer of its own.
+		buf := make([]byte, streamChunkSize)
+		n, rerr := io.ReadFull(r, buf)
+		if rerr != nil && !errors.Is(rerr, io.EOF) && !errors.Is(rerr, io.ErrUnexpectedEOF) {
+			return 0, rerr
+		}
+		req.Data = buf[:n]
+		if err := stream.Send(req); err != nil {
+			if errors.Is(err, io.EOF) {
+				// The server ended the call; its status tells why.
+				_, err = stream.CloseAndRecv()
+			}
+			return 0, err
+		}
+		if rerr != nil {
+			break
+		}
+		req = &pb.SetStreamRequest{}
+	}
+	resp, err := stream.CloseAndRecv()
+	if err != nil {
+		return 0, err
+	}
+	return resp.Version, nil
+}
@@ -0,0 +1,135 @@
+package client
+
+import (
+	"context"
+	"time"
+
+	pb "distributed-cache-service/proto"
+)
+
+// Cmp is a condition of a transaction, built with VersionIs or ValueIs.
+type Cmp struct {
+	cmp *pb.Compare
+}
+
+// VersionIs holds if key is at version; a version of 0 means the key does not exist.
+func VersionIs(key string, version uint64) Cmp {
+	return Cmp{&pb.Compare{Key: key, Target: pb.Compare_VERSION, Version: version}}
+}
+
+// ValueIs holds if key exists with value.
+func ValueIs(key, value string) Cmp {
+	return Cmp{&pb.Compare{Key: key, Target: pb.Compare_VALUE, Value: value}}
+}
+
+// Not inverts the condition.
+func (c Cmp) Not() Cmp {
+	result := pb.Compare_NOT_EQUAL
+	if c.cmp.Result == pb.Compare_NOT_EQUAL {
+		result = pb.Compare_EQUAL
+	}
+	return Cmp{&pb.Compare{Key: c.cmp.Key, Target: c.cmp.Target, Result: result, Version: c.cmp.Version, Value: c.cmp.Value}}
+}
+
+// Op is an operation of a transaction, built with OpGet, OpSet or OpDelete.
+type Op struct {
+	op *pb.TxnOp
+}
+
+// OpGet reads key.
+func OpGet(key string) Op {
+	return Op{&pb.TxnOp{Type: pb.TxnOp_GET, Key: key}}
+}
+
+// OpSet stores value under key, with ttl rounded down to whole seconds.
+func OpSet(key, value string, ttl time.Duration) Op {
+	return Op{&pb.TxnOp{Type: pb.TxnOp_SET, Key: key, Value: value, Ttl: int64(ttl / time.Second)}}
+}
+
+// OpDelete removes key.
+func OpDelete(key string) Op {
+	return Op{&pb.TxnOp{Type: pb.TxnOp_DELETE, Key: key}}
+}
+
+// OpResult is the outcome of an Op. For OpGet, Found, Value and Version
+// describe the key; for OpSet, Version is its new version; for OpDelete, Found
+// reports whether the key existed.
+type OpResult struct {
+	Value   string
+	Found   bool
+	Version uint64
+}
+
+// TxnResponse reports which branch of a transaction ran, with one result per op.
+type TxnResponse struct {
+	Succeeded bool
+	Results   []OpResult
+}
+
+// Txn is a compare-then-ops transaction under construction, as in etcd:
+//
+//	resp, err := c.Txn(ctx).
+//		If(client.VersionIs("balance", v)).
+//		Then(client.OpSet("balance", "90", 0), client.OpSet("ledger:17", "-10", 0)).
+//		Else(client.OpGet("balance")).
+//		Commit()
+//
+// The cluster applies it as a single step: no other write can land between the
+// comparisons and the ops.
+type Txn struct {
+	c   *Client
+	ctx context.Context
+	req pb.TxnRequest
+}
+
+// Txn starts a transaction.
+func (c *Client) Txn(ctx context.Context) *Txn {
+	return &Txn{c: c, ctx: ctx}
+}
+
+// If adds conditions, which must all hold for the Then ops to run.
+func (t *Txn) If(cmps ...Cmp) *Txn {
+	for _, cmp := range cmps {
+		t.req.Compare = append(t.req.Compare, cmp.cmp)
+	}
+	return t
+}
+
+// Then adds ops to run if every condition holds.
+func (t *Txn) Then(ops ...Op) *Txn {
+	for _, op := range ops {
+		t.req.Success = append(t.req.Success, op.op)
+	}
+	return t
+}
+
+// Else adds ops to run if any condition fails.
+func (t *Txn) Else(ops ...Op) *Txn {
+	for _, op := range ops {
+		t.req.Failure = append(t.req.Failure, op.op)
+	}
+	return t
+}
+
+// Commit sends the transaction.
+func (t *Txn) Commit() (TxnResponse, error) {
+	if near := t.c.near; near != nil {
+		for _, ops := range [][]*pb.TxnOp{t.req.Success, t.req.Failure} {
+			for _, op := range ops {
+				if op.Type != pb.TxnOp_GET {
+					near.invalidate(op.Key)
+				}
+			}
+		}
+	}
+	t.req.RequestId = requestID(t.ctx)
+	resp, err := t.c.primary.Load().pick().Txn(t.ctx, &t.req)
+	if err != nil {
+		return TxnResponse{}, err
+	}
+	out := TxnResponse{Succeeded: resp.Succeeded, Results: make([]OpResult, len(resp.Results))}
+	for i, r := range resp.Results {
+		out.Results[i] = OpResult{Value: r.Value, Found: r.Found, Version: r.Version}
+	}
+	return out, nil
+}
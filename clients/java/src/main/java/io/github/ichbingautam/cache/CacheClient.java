@@ -0,0 +1,137 @@
+package io.github.ichbingautam.cache;
+
+import io.github.ichbingautam.cache.proto.CacheServiceGrpc;
+import io.github.ichbingautam.cache.proto.CacheServiceGrpc.CacheServiceBlockingStub;
+import io.github.ichbingautam.cache.proto.DeleteRequest;
+import io.github.ichbingautam.cache.proto.GetRequest;
+import io.github.ichbingautam.cache.proto.GetResponse;
+import io.github.ichbingautam.cache.proto.SetRequest;
+import io.grpc.ManagedChannel;
+import io.grpc.ManagedChannelBuilder;
+import io.grpc.Status;
+import io.grpc.StatusRuntimeException;
+import java.time.Duration;
+import java.util.ArrayList;
+import java.util.List;
+import java.util.Optional;
+import java.util.UUID;
+import java.util.concurrent.TimeUnit;
+import java.util.concurrent.atomic.AtomicInteger;
+import java.util.function.Function;
+
+/**
+ * A client for a cache cluster: the generated CacheService stub plus leader routing.
+ *
+ * <p>Every node serves reads, but writes and strong reads must reach the Raft leader; other nodes
+ * reject them with UNAVAILABLE. Each call goes to the node that last accepted one and, on
+ * UNAVAILABLE, moves on to the next endpoint until a node accepts or the retry timeout passes.
+ * Writes sent through {@link #set} and {@link #delete} carry a fresh request ID, so a retry on
+ * another node cannot apply them twice.
+ */
+public final class CacheClient implements AutoCloseable {
+  private final List<ManagedChannel> channels = new ArrayList<>();
+  private final List<CacheServiceBlockingStub> stubs = new ArrayList<>();
+  private final Duration timeout;
+  private final Duration retryTimeout;
+  private final AtomicInteger current = new AtomicInteger();
+
+  /** Connects, without TLS, to endpoints: host:port gRPC addresses of the cluster's nodes. */
+  public CacheClient(List<String> endpoints) {
+    this(endpoints, Duration.ofSeconds(5), Duration.ofSeconds(10));
+  }
+
+  public CacheClient(List<String> endpoints, Duration timeout, Duration retryTimeout) {
+    if (endpoints.isEmpty()) {
+      throw new IllegalArgumentException("at least one endpoint is required");
+    }
+    for (String endpoint : endpoints) {
+      ManagedChannel channel = ManagedChannelBuilder.forTarget(endpoint).usePlaintext().build();
+      channels.add(channel);
+      stubs.add(CacheServiceGrpc.newBlockingStub(channel));
+    }
+    this.timeout = timeout;
+    this.retryTimeout = retryTimeout;
+  }
+
+  /**
+   * Runs rpc against the leader, e.g. {@code call(s -> s.zAdd(req))}.
+   *
+   * @throws NoLeaderException if no node accepts the call within the retry timeout
+   */
+  public <T> T call(Function<CacheServiceBlockingStub, T> rpc) {
+    long deadline = System.nanoTime() + retryTimeout.toNanos();
+    int i = current.get();
+    StatusRuntimeException last = null;
+    while (true) {
+      for (int n = 0; n < stubs.size(); n++) {
+        try {
+          T resp = rpc.apply(stubs.get(i).withDeadlineAfter(timeout.toMillis(), TimeUnit.MILLISECONDS));
+          current.set(i);
+          return resp;
+        } catch (StatusRuntimeException e) {
+          if (e.getStatus().getCode() != Status.Code.UNAVAILABLE) {
+            throw e;
+          }
+          last = e;
+          i = (i + 1) % stubs.size();
+        }
+      }
+      // Every node refused: an election is probably under way.
+      if (System.nanoTime() >= deadline) {
+        throw new NoLeaderException(last);
+      }
+      try {
+        Thread.sleep(100);
+      } catch (InterruptedException e) {
+        Thread.currentThread().interrupt();
+        throw new NoLeaderException(last);
+      }
+    }
+  }
+
+  /** Returns the value of key, or empty if it does not exist. */
+  public Optional<String> get(String key) {
+    try {
+      GetResponse resp = call(s -> s.get(GetRequest.newBuilder().setKey(key).build()));
+      return Optional.of(resp.getValue());
+    } catch (StatusRuntimeException e) {
+      if (e.getStatus().getCode() == Status.Code.NOT_FOUND) {
+        return Optional.empty();
+      }
+      throw e;
+    }
+  }
+
+  /** Stores value under key, expiring after ttl (whole seconds; zero for none). Returns its version. */
+  public long set(String key, String value, Duration ttl) {
+    SetRequest req =
+        SetRequest.newBuilder()
+            .setKey(key)
+            .setValue(value)
+            .setTtl(ttl.getSeconds())
+            .setRequestId(UUID.randomUUID().toString())
+            .build();
+    return call(s -> s.set(req)).getVersion();
+  }
+
+  /** Removes key. */
+  public void delete(String key) {
+    DeleteRequest req =
+        DeleteRequest.newBuilder().setKey(key).setRequestId(UUID.randomUUID().toString()).build();
+    call(s -> s.delete(req));
+  }
+
+  @Override
+  public void close() {
+    for (ManagedChannel channel : channels) {
+      channel.shutdown();
+    }
+  }
+
+  /** No node accepted a call within the retry timeout. */
+  public static final class NoLeaderException extends RuntimeException {
+    NoLeaderException(StatusRuntimeException cause) {
+      super("no node accepted the call", cause);
+    }
+  }
+}
@@ -0,0 +1,157 @@
+package io.github.ichbingautam.cache;
+
+import static org.junit.jupiter.api.Assertions.assertEquals;
+import static org.junit.jupiter.api.Assertions.assertFalse;
+import static org.junit.jupiter.api.Assertions.assertThrows;
+
+import io.github.ichbingautam.cache.proto.CacheServiceGrpc;
+import io.github.ichbingautam.cache.proto.DeleteRequest;
+import io.github.ichbingautam.cache.proto.DeleteResponse;
+import io.github.ichbingautam.cache.proto.GetRequest;
+import io.github.ichbingautam.cache.proto.GetResponse;
+import io.github.ichbingautam.cache.proto.SetRequest;
+import io.github.ichbingautam.cache.proto.SetResponse;
+import io.grpc.Grpc;
+import io.grpc.InsecureServerCredentials;
+import io.grpc.Server;
+import io.grpc.Status;
+import io.grpc.StatusRuntimeException;
+import io.grpc.stub.StreamObserver;
+import java.io.IOException;
+import java.time.Duration;
+import java.util.ArrayList;
+import java.util.List;
+import java.util.Map;
+import java.util.Optional;
+import java.util.concurrent.ConcurrentHashMap;
+import java.util.concurrent.atomic.AtomicInteger;
+import org.junit.jupiter.api.AfterEach;
+import org.junit.jupiter.api.Test;
+
+/** Smoke tests for CacheClient against in-process servers, run by {@code make clients-java}. */
+class CacheClientTest {
+  private final List<Server> servers = new ArrayList<>();
+
+  /**
+   * A node that serves get, set and delete from a map, or, if it is not the leader, refuses them
+   * with UNAVAILABLE as a follower does.
+   */
+  static final class Node extends CacheServiceGrpc.CacheServiceImplBase {
+    final boolean leader;
+    final Map<String, String> data = new ConcurrentHashMap<>();
+    final AtomicInteger calls = new AtomicInteger();
+    final List<String> requestIds = new ArrayList<>();
+
+    Node(boolean leader) {
+      this.leader = leader;
+    }
+
+    private boolean refuse(StreamObserver<?> out) {
+      calls.incrementAndGet();
+      if (!leader) {
+        out.onError(Status.UNAVAILABLE.withDescription("node is not the leader").asRuntimeException());
+      }
+      return !leader;
+    }
+
+    @Override
+    public void get(GetRequest req, StreamObserver<GetResponse> out) {
+      if (refuse(out)) {
+        return;
+      }
+      String value = data.get(req.getKey());
+      if (value == null) {
+        out.onError(Status.NOT_FOUND.withDescription("key not found").asRuntimeException());
+        return;
+      }
+      out.onNext(GetResponse.newBuilder().setValue(value).setFound(true).setVersion(1).build());
+      out.onCompleted();
+    }
+
+    @Override
+    public void set(SetRequest req, StreamObserver<SetResponse> out) {
+      if (refuse(out)) {
+        return;
+      }
+      if (req.getKey().equals("bad")) {
+        out.onError(Status.INVALID_ARGUMENT.withDescription("bad key").asRuntimeException());
+        return;
+      }
+      synchronized (requestIds) {
+        requestIds.add(req.getRequestId());
+      }
+      data.put(req.getKey(), req.getValue());
+      out.onNext(SetResponse.newBuilder().setSuccess(true).setVersion(1).build());
+      out.onCompleted();
+    }
+
+    @Override
+    public void delete(DeleteRequest req, StreamObserver<DeleteResponse> out) {
+      if (refuse(out)) {
+        return;
+      }
+      data.remove(req.getKey());
+      out.onNext(DeleteResponse.newBuilder().setSuccess(true).build());
+      out.onCompleted();
+    }
+  }
+
+  /** Starts node and returns its endpoint. */
+  private String start(Node node) throws IOException {
+    Server server =
+        Grpc.newServerBuilderForPort(0, InsecureServerCredentials.create())
+            .addService(node)
+            .build()
+            .start();
+    servers.add(server);
+    return "127.0.0.1:" + server.getPort();
+  }
+
+  @AfterEach
+  void stop() {
+    for (Server server : servers) {
+      server.shutdownNow();
+    }
+  }
+
+  @Test
+  void followsLeader() throws IOException {
+    Node follower = new Node(false);
+    Node leader = new Node(true);
+    try (CacheClient client = new CacheClient(List.of(start(follower), start(leader)))) {
+      assertEquals(1, client.set("k", "v", Duration.ZERO));
+      assertEquals(Optional.of("v"), client.get("k"));
+      client.delete("k");
+      assertEquals(Optional.empty(), client.get("k"));
+    }
+    // Only the first call tried the follower; later ones went to the leader.
+    assertEquals(1, follower.calls.get());
+    assertEquals(4, leader.calls.get());
+    assertFalse(leader.requestIds.get(0).isEmpty(), "expected a generated request ID");
+  }
+
+  @Test
+  void noLeader() throws IOException {
+    List<String> endpoints = List.of(start(new Node(false)), start(new Node(false)));
+    try (CacheClient client =
+        new CacheClient(endpoints, Duration.ofSeconds(5), Duration.ofMillis(300))) {
+      CacheClient.NoLeaderException e =
+          assertThrows(
+              CacheClient.NoLeaderException.class, () -> client.set("k", "v", Duration.ZERO));
+      assertEquals(Status.Code.UNAVAILABLE, ((StatusRuntimeException) e.getCause()).getStatus().getCode());
+    }
+  }
+
+  @Test
+  void otherErrorsAreNotRetried() throws IOException {
+    Node leader = new Node(true);
+    Node other = new Node(true);
+    try (CacheClient client = new CacheClient(List.of(start(leader), start(other)))) {
+      StatusRuntimeException e =
+          assertThrows(StatusRuntimeException.class, () -> client.set("bad", "v", Duration.ZERO));
+      assertEquals(Status.Code.INVALID_ARGUMENT, e.getStatus().getCode());
+    }
+    assertEquals(1, leader.calls.get());
+    assertEquals(0, other.calls.get(), "the error was retried on another node");
+  }
+}
@@ -0,0 +1,9 @@
+"""Python client for the distributed cache's gRPC API.
+
+The generated stubs (cache_pb2, cache_pb2_grpc) are built by `make clients`.
+CacheClient wraps them and routes writes to the Raft leader.
+"""
+
+from distributed_cache.client import CacheClient, NoLeaderError
+
+__all__ = ["CacheClient", "NoLeaderError"]
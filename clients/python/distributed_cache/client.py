@@ -0,0 +1,105 @@
+"""CacheClient: the generated CacheService stub plus leader routing.
+
+Every node serves reads, but writes and strong reads must reach the Raft
+leader; other nodes reject them with UNAVAILABLE. CacheClient sends each call
+to the node that last accepted one and, on UNAVAILABLE, moves on to the next
+endpoint until a node accepts or retry_timeout passes.
+"""
+
+import threading
+import time
+import uuid
+
+import grpc
+
+from distributed_cache import cache_pb2, cache_pb2_grpc
+
+
+class NoLeaderError(Exception):
+    """No endpoint accepted the call within retry_timeout."""
+
+
+class CacheClient:
+    """A client for a cache cluster.
+
+    endpoints are host:port gRPC addresses of the cluster's nodes; any subset
+    that includes the leader works. Calls that support request_id get a fresh
+    one when the caller did not set it, so a retry on another node cannot
+    apply a write twice.
+    """
+
+    def __init__(self, endpoints, credentials=None, timeout=5.0, retry_timeout=10.0):
+        if not endpoints:
+            raise ValueError("at least one endpoint is required")
+        self._endpoints = list(endpoints)
+        self._channels = [
+            grpc.secure_channel(e, credentials) if credentials else grpc.insecure_channel(e)
+            for e in self._endpoints
+        ]
+        self._stubs = [cache_pb2_grpc.CacheServiceStub(ch) for ch in self._channels]
+        self._timeout = timeout
+        self._retry_timeout = retry_timeout
+        self._lock = threading.Lock()
+        self._current = 0
+
+    def close(self):
+        for ch in self._channels:
+            ch.close()
+
+    def __enter__(self):
+        return self
+
+    def __exit__(self, *exc):
+        self.close()
+
+    def call(self, method, request):
+        """Calls the CacheService RPC named method, e.g. "ZAdd", following the leader."""
+        fields = request.DESCRIPTOR.fields_by_name
+        if "request_id" in fields and not request.request_id:
+            request.request_id = uuid.uuid4().hex
+
+        deadline = time.monotonic() + self._retry_timeout
+        with self._lock:
+            i = self._current
+        last = None
+        while True:
+            for _ in range(len(self._stubs)):
+                try:
+                    resp = getattr(self._stubs[i], method)(request, timeout=self._timeout)
+                except grpc.RpcError as err:
+                    if err.code() != grpc.StatusCode.UNAVAILABLE:
+                        raise
+                    last = err
+                    i = (i + 1) % len(self._stubs)
+                    continue
+                with self._lock:
+                    self._current = i
+                return resp
+            # Every node refused: an election is probably under way.
+            if time.monotonic() >= deadline:
+                raise NoLeaderError(f"no node accepted {method}: {last.details()}") from last
+            time.sleep(0.1)
+
+    def get(self, key, consistency=cache_pb2.CONSISTENCY_DEFAULT):
+        """Returns (value, version), or None if key does not exist."""
+        try:
+            resp = self.call("Get", cache_pb2.GetRequest(key=key, consistency=consistency))
+        except grpc.RpcError as err:
+            if err.code() == grpc.StatusCode.NOT_FOUND:
+                return None
+            raise
+        return resp.value, resp.version
+
+    def set(self, key, value, ttl=0, if_version=0, if_absent=False, request_id="", ack=cache_pb2.ACK_QUORUM):
+        """Stores value under key for ttl seconds (0: no expiry); returns its new version.
+
+        With ack=ACK_LEADER the call returns before the write commits, and the
+        version is 0.
+        """
+        req = cache_pb2.SetRequest(
+            key=key, value=value, ttl=ttl, if_version=if_version, if_absent=if_absent, request_id=request_id, ack=ack
+        )
+        return self.call("Set", req).version
+
+    def delete(self, key, request_id="", ack=cache_pb2.ACK_QUORUM):
+        self.call("Delete", cache_pb2.DeleteRequest(key=key, request_id=request_id, ack=ack))
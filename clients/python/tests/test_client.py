@@ -0,0 +1,92 @@
+"""Smoke tests for CacheClient against in-process servers.
+
+Run by `make clients-python`, once the stubs are generated.
+"""
+
+import unittest
+from concurrent import futures
+
+import grpc
+
+from distributed_cache import CacheClient, NoLeaderError, cache_pb2, cache_pb2_grpc
+
+
+class Node(cache_pb2_grpc.CacheServiceServicer):
+    """A node that serves Get, Set and Delete from a dict, or, if it is not
+    the leader, refuses them with UNAVAILABLE as a follower does."""
+
+    def __init__(self, leader):
+        self.leader = leader
+        self.data = {}
+        self.calls = 0
+        self.request_ids = []
+
+    def _check(self, context):
+        self.calls += 1
+        if not self.leader:
+            context.abort(grpc.StatusCode.UNAVAILABLE, "node is not the leader")
+
+    def Get(self, request, context):
+        self._check(context)
+        if request.key not in self.data:
+            context.abort(grpc.StatusCode.NOT_FOUND, "key not found")
+        return cache_pb2.GetResponse(value=self.data[request.key], version=1)
+
+    def Set(self, request, context):
+        self._check(context)
+        if request.key == "bad":
+            context.abort(grpc.StatusCode.INVALID_ARGUMENT, "bad key")
+        self.request_ids.append(request.request_id)
+        self.data[request.key] = request.value
+        return cache_pb2.SetResponse(version=1)
+
+    def Delete(self, request, context):
+        self._check(context)
+        self.data.pop(request.key, None)
+        return cache_pb2.DeleteResponse()
+
+
+class CacheClientTest(unittest.TestCase):
+    def start(self, *leaders):
+        """Starts a node per flag in leaders; returns the nodes and their endpoints."""
+        nodes, endpoints = [], []
+        for leader in leaders:
+            node = Node(leader)
+            server = grpc.server(futures.ThreadPoolExecutor(max_workers=4))
+            cache_pb2_grpc.add_CacheServiceServicer_to_server(node, server)
+            port = server.add_insecure_port("127.0.0.1:0")
+            server.start()
+            self.addCleanup(server.stop, None)
+            nodes.append(node)
+            endpoints.append(f"127.0.0.1:{port}")
+        return nodes, endpoints
+
+    def test_follows_leader(self):
+        (follower, leader), endpoints = self.start(False, True)
+        with CacheClient(endpoints) as client:
+            self.assertEqual(client.set("k", "v"), 1)
+            self.assertEqual(client.get("k"), ("v", 1))
+            client.delete("k")
+            self.assertIsNone(client.get("k"))
+        # Only the first call tried the follower; later ones went to the leader.
+        self.assertEqual(follower.calls, 1)
+        self.assertEqual(leader.calls, 4)
+        self.assertTrue(leader.request_ids[0], "expected a generated request_id")
+
+    def test_no_leader(self):
+        _, endpoints = self.start(False, False)
+        with CacheClient(endpoints, retry_timeout=0.3) as client:
+            with self.assertRaises(NoLeaderError):
+                client.set("k", "v")
+
+    def test_other_errors_are_not_retried(self):
+        (leader, other), endpoints = self.start(True, True)
+        with CacheClient(endpoints) as client:
+            with self.assertRaises(grpc.RpcError) as ctx:
+                client.set("bad", "v")
+        self.assertEqual(ctx.exception.code(), grpc.StatusCode.INVALID_ARGUMENT)
+        self.assertEqual((leader.calls, other.calls), (1, 0))
+
+
+if __name__ == "__main__":
+    unittest.main()
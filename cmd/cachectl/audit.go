@@ -0,0 +1,27 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"distributed-cache-service/internal/audit"
+)
+
+// runAudit checks a node's audit log file rather than talking to the node.
+func runAudit(c *cli, args []string) error {
+	if len(args) != 2 || args[0] != "verify" {
+		return errUsage
+	}
+	f, err := os.Open(args[1])
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	n, err := audit.Verify(f)
+	if err != nil {
+		return fmt.Errorf("%s: %d record(s) verified, then %w", args[1], n, err)
+	}
+	fmt.Fprintf(c.stdout, "%d record(s) verified.\n", n)
+	return nil
+}
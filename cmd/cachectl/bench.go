@@ -0,0 +1,300 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"distributed-cache-service/client"
+)
+
+// benchConfig is what a benchmark run does, echoed in its JSON report so
+// that results can be compared across runs.
+type benchConfig struct {
+	Ops       int           `json:"ops,omitempty"`
+	Duration  time.Duration `json:"duration_ns,omitempty"`
+	Workers   int           `json:"workers"`
+	Keys      int           `json:"keys"`
+	Size      int           `json:"value_size"`
+	Reads     float64       `json:"read_ratio"`
+	Dist      string        `json:"distribution"`
+	ZipfS     float64       `json:"zipf_s,omitempty"`
+	Preload   bool          `json:"preload"`
+	ReadAddrs []string      `json:"read_addrs,omitempty"`
+}
+
+// keyPicker returns the index of the next key a worker touches.
+type keyPicker func() int
+
+// picker builds an independent key picker for one worker; pickers are not
+// safe for concurrent use.
+func (cfg benchConfig) picker(seed uint64) keyPicker {
+	r := rand.New(rand.NewPCG(seed, uint64(time.Now().UnixNano())))
+	if cfg.Dist == "zipf" {
+		// Key 0 is the hottest; s controls how skewed the accesses are.
+		z := rand.NewZipf(r, cfg.ZipfS, 1, uint64(cfg.Keys-1))
+		return func() int { return int(z.Uint64()) }
+	}
+	return func() int { return r.IntN(cfg.Keys) }
+}
+
+// opStats collects the latencies of one kind of operation.
+type opStats struct {
+	latencies []time.Duration
+	errors    int
+	firstErr  error
+}
+
+func (s *opStats) record(start time.Time, err error) {
+	if err != nil {
+		s.errors++
+		if s.firstErr == nil {
+			s.firstErr = err
+		}
+		return
+	}
+	s.latencies = append(s.latencies, time.Since(start))
+}
+
+func (s *opStats) merge(o *opStats) {
+	s.latencies = append(s.latencies, o.latencies...)
+	s.errors += o.errors
+	if s.firstErr == nil {
+		s.firstErr = o.firstErr
+	}
+}
+
+// opReport summarizes opStats; latencies are in microseconds.
+type opReport struct {
+	OK     int     `json:"ok"`
+	Errors int     `json:"errors"`
+	P50    float64 `json:"p50_us"`
+	P90    float64 `json:"p90_us"`
+	P99    float64 `json:"p99_us"`
+	P999   float64 `json:"p999_us"`
+	Max    float64 `json:"max_us"`
+}
+
+func (s *opStats) report() opReport {
+	slices.Sort(s.latencies)
+	pct := func(p float64) float64 {
+		if len(s.latencies) == 0 {
+			return 0
+		}
+		return float64(s.latencies[int(p*float64(len(s.latencies)-1))]) / float64(time.Microsecond)
+	}
+	return opReport{OK: len(s.latencies), Errors: s.errors, P50: pct(0.5), P90: pct(0.9), P99: pct(0.99), P999: pct(0.999), Max: pct(1)}
+}
+
+type benchReport struct {
+	Config    benchConfig `json:"config"`
+	Elapsed   float64     `json:"elapsed_s"`
+	OpsPerSec float64     `json:"ops_per_sec"`
+	Get       opReport    `json:"get"`
+	Set       opReport    `json:"set"`
+}
+
+// runBench drives a mix of gets and sets against the cluster, over keys
+// prefixed with "bench:", and reports throughput and latency percentiles.
+// Sets go to -addr, which must be the leader; gets are spread over
+// -read_addrs when given.
+func runBench(c *cli, args []string) error {
+	var cfg benchConfig
+	fs := c.flags("bench")
+	fs.IntVar(&cfg.Ops, "n", 10000, "Total operations, unless -duration is set")
+	fs.DurationVar(&cfg.Duration, "duration", 0, "Run for this long instead of -n operations")
+	fs.IntVar(&cfg.Workers, "c", 16, "Concurrent workers")
+	fs.IntVar(&cfg.Keys, "keys", 1000, "Distinct keys")
+	fs.IntVar(&cfg.Size, "size", 128, "Value size in bytes")
+	fs.Float64Var(&cfg.Reads, "reads", 0.8, "Fraction of operations that are gets")
+	fs.StringVar(&cfg.Dist, "dist", "uniform", "Key distribution: uniform or zipf")
+	fs.Float64Var(&cfg.ZipfS, "zipf_s", 1.1, "Skew of the zipf distribution (> 1)")
+	fs.BoolVar(&cfg.Preload, "preload", false, "Set every key once before measuring, so that gets hit")
+	readAddrs := fs.String("read_addrs", "", "Comma-separated gRPC addresses to spread gets over (default -addr)")
+	asJSON := fs.Bool("json", false, "Print the report as JSON")
+	if err := fs.Parse(args); err != nil {
+		return errUsage
+	}
+	if *readAddrs != "" {
+		cfg.ReadAddrs = strings.Split(*readAddrs, ",")
+	}
+	if cfg.Duration > 0 {
+		cfg.Ops = 0
+	}
+	if cfg.Dist != "zipf" {
+		cfg.ZipfS = 0
+	}
+	if err := cfg.validate(); err != nil {
+		fmt.Fprintf(c.stderr, "cachectl bench: %v\n", err)
+		return errUsage
+	}
+	if fs.NArg() != 0 {
+		return errUsage
+	}
+
+	writer, err := c.client()
+	if err != nil {
+		return err
+	}
+	defer writer.Close()
+	readers := []*client.Client{writer}
+	if len(cfg.ReadAddrs) > 0 {
+		readers = readers[:0]
+		for _, addr := range cfg.ReadAddrs {
+			r, err := client.New(addr)
+			if err != nil {
+				return err
+			}
+			defer r.Close()
+			readers = append(readers, r)
+		}
+	}
+	value := strings.Repeat("x", cfg.Size)
+
+	if cfg.Preload {
+		if err := preload(c, writer, cfg, value); err != nil {
+			return fmt.Errorf("preload: %s", describe(err))
+		}
+	}
+
+	var (
+		mu         sync.Mutex
+		gets, sets opStats
+		wg         sync.WaitGroup
+		remaining  atomic.Int64
+		deadline   time.Time
+	)
+	remaining.Store(int64(cfg.Ops))
+	start := time.Now()
+	if cfg.Duration > 0 {
+		deadline = start.Add(cfg.Duration)
+	}
+	// next reports whether a worker should run another operation.
+	next := func() bool {
+		if !deadline.IsZero() {
+			return time.Now().Before(deadline)
+		}
+		return remaining.Add(-1) >= 0
+	}
+	for w := 0; w < cfg.Workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			pick := cfg.picker(uint64(w))
+			ops := rand.New(rand.NewPCG(uint64(w), uint64(start.UnixNano())))
+			reader := readers[w%len(readers)]
+			var g, s opStats
+			for next() {
+				key := fmt.Sprintf("bench:%d", pick())
+				ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+				t := time.Now()
+				if ops.Float64() < cfg.Reads {
+					_, err := reader.Get(ctx, key)
+					if errors.Is(err, client.ErrNotFound) {
+						err = nil
+					}
+					g.record(t, err)
+				} else {
+					s.record(t, writer.Set(ctx, key, value, 0))
+				}
+				cancel()
+			}
+			mu.Lock()
+			gets.merge(&g)
+			sets.merge(&s)
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+	elapsed := time.Since(start)
+
+	rep := benchReport{Config: cfg, Elapsed: elapsed.Seconds(), Get: gets.report(), Set: sets.report()}
+	rep.OpsPerSec = float64(rep.Get.OK+rep.Set.OK) / elapsed.Seconds()
+	if *asJSON {
+		enc := json.NewEncoder(c.stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(rep); err != nil {
+			return err
+		}
+	} else {
+		printBench(c, rep)
+	}
+	if failed := gets.errors + sets.errors; failed > 0 {
+		first := gets.firstErr
+		if first == nil {
+			first = sets.firstErr
+		}
+		return fmt.Errorf("%d operations failed, first: %s", failed, describe(first))
+	}
+	return nil
+}
+
+func (cfg benchConfig) validate() error {
+	switch {
+	case cfg.Ops <= 0 && cfg.Duration <= 0:
+		return errors.New("-n or -duration must be positive")
+	case cfg.Workers <= 0 || cfg.Keys <= 0 || cfg.Size < 0:
+		return errors.New("-c and -keys must be positive and -size not negative")
+	case cfg.Reads < 0 || cfg.Reads > 1:
+		return errors.New("-reads must be between 0 and 1")
+	case cfg.Dist != "uniform" && cfg.Dist != "zipf":
+		return fmt.Errorf("unknown distribution %q: want uniform or zipf", cfg.Dist)
+	case cfg.Dist == "zipf" && cfg.ZipfS <= 1:
+		return errors.New("-zipf_s must be greater than 1")
+	}
+	return nil
+}
+
+// preload sets every key once, with the benchmark's concurrency.
+func preload(c *cli, cl *client.Client, cfg benchConfig, value string) error {
+	var (
+		wg       sync.WaitGroup
+		next     atomic.Int64
+		firstErr atomic.Pointer[error]
+	)
+	for w := 0; w < cfg.Workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := int(next.Add(1) - 1); i < cfg.Keys && firstErr.Load() == nil; i = int(next.Add(1) - 1) {
+				ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+				err := cl.Set(ctx, fmt.Sprintf("bench:%d", i), value, 0)
+				cancel()
+				if err != nil {
+					firstErr.CompareAndSwap(nil, &err)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if err := firstErr.Load(); err != nil {
+		return *err
+	}
+	return nil
+}
+
+func printBench(c *cli, rep benchReport) {
+	fmt.Fprintf(c.stdout, "%d operations in %.3fs with %d workers (%s keys): %.0f ops/s\n",
+		rep.Get.OK+rep.Set.OK, rep.Elapsed, rep.Config.Workers, rep.Config.Dist, rep.OpsPerSec)
+	for _, op := range []struct {
+		name string
+		r    opReport
+	}{{"get", rep.Get}, {"set", rep.Set}} {
+		if op.r.OK+op.r.Errors == 0 {
+			continue
+		}
+		fmt.Fprintf(c.stdout, "  %s: %d ok, %d failed  p50 %s  p90 %s  p99 %s  p99.9 %s  max %s\n",
+			op.name, op.r.OK, op.r.Errors, us(op.r.P50), us(op.r.P90), us(op.r.P99), us(op.r.P999), us(op.r.Max))
+	}
+}
+
+func us(v float64) time.Duration {
+	return (time.Duration(v * float64(time.Microsecond))).Round(time.Microsecond)
+}
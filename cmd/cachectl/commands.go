@@ -0,0 +1,238 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"text/tabwriter"
+	"time"
+
+	pb "distributed-cache-service/proto"
+)
+
+func runGet(c *cli, args []string) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+	cl, err := c.client()
+	if err != nil {
+		return err
+	}
+	defer cl.Close()
+	ctx, cancel := c.context()
+	defer cancel()
+	val, err := cl.Get(ctx, args[0])
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(c.stdout, val)
+	return nil
+}
+
+func runSet(c *cli, args []string) error {
+	fs := c.flags("set")
+	ttl := fs.Duration("ttl", 0, "Expire the key after this long (0 = never)")
+	if err := fs.Parse(args); err != nil {
+		return errUsage
+	}
+	if fs.NArg() != 2 {
+		return errUsage
+	}
+	cl, err := c.client()
+	if err != nil {
+		return err
+	}
+	defer cl.Close()
+	ctx, cancel := c.context()
+	defer cancel()
+	if err := cl.Set(ctx, fs.Arg(0), fs.Arg(1), *ttl); err != nil {
+		return err
+	}
+	fmt.Fprintln(c.stdout, "OK")
+	return nil
+}
+
+func runDel(c *cli, args []string) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+	cl, err := c.client()
+	if err != nil {
+		return err
+	}
+	defer cl.Close()
+	ctx, cancel := c.context()
+	defer cancel()
+	if err := cl.Delete(ctx, args[0]); err != nil {
+		return err
+	}
+	fmt.Fprintln(c.stdout, "OK")
+	return nil
+}
+
+func runStatus(c *cli, args []string) error {
+	if len(args) != 0 {
+		return errUsage
+	}
+	admin, closeConn, err := c.admin()
+	if err != nil {
+		return err
+	}
+	defer closeConn()
+	ctx, cancel := c.context()
+	defer cancel()
+	resp, err := admin.Stats(ctx, &pb.StatsRequest{})
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(c.stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "state\t%s\n", resp.State)
+	fmt.Fprintf(w, "leader\t%s\n", resp.Leader)
+	fmt.Fprintf(w, "keys\t%d\n", resp.KeyCount)
+	keys := make([]string, 0, len(resp.Raft))
+	for k := range resp.Raft {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		fmt.Fprintf(w, "%s\t%s\n", k, resp.Raft[k])
+	}
+	return w.Flush()
+}
+
+func runMembers(c *cli, args []string) error {
+	if len(args) != 0 {
+		return errUsage
+	}
+	admin, closeConn, err := c.admin()
+	if err != nil {
+		return err
+	}
+	defer closeConn()
+	ctx, cancel := c.context()
+	defer cancel()
+	resp, err := admin.Members(ctx, &pb.MembersRequest{})
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(c.stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tRAFT ADDRESS\tSUFFRAGE\tLEADER")
+	for _, m := range resp.Members {
+		suffrage := "nonvoter"
+		if m.Voter {
+			suffrage = "voter"
+		}
+		leader := ""
+		if m.Leader {
+			leader = "*"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", m.Id, m.Addr, suffrage, leader)
+	}
+	return w.Flush()
+}
+
+func runJoin(c *cli, args []string) error {
+	if len(args) != 2 {
+		return errUsage
+	}
+	admin, closeConn, err := c.admin()
+	if err != nil {
+		return err
+	}
+	defer closeConn()
+	ctx, cancel := c.context()
+	defer cancel()
+	if _, err := admin.Join(ctx, &pb.JoinRequest{NodeId: args[0], Addr: args[1]}); err != nil {
+		return err
+	}
+	fmt.Fprintf(c.stdout, "Added %s at %s\n", args[0], args[1])
+	return nil
+}
+
+func runRemove(c *cli, args []string) error {
+	if len(args) != 1 {
+		return errUsage
+	}
+	admin, closeConn, err := c.admin()
+	if err != nil {
+		return err
+	}
+	defer closeConn()
+	ctx, cancel := c.context()
+	defer cancel()
+	if _, err := admin.Remove(ctx, &pb.RemoveRequest{NodeId: args[0]}); err != nil {
+		return err
+	}
+	fmt.Fprintf(c.stdout, "Removed %s\n", args[0])
+	return nil
+}
+
+func runSnapshot(c *cli, args []string) error {
+	if len(args) != 0 {
+		return errUsage
+	}
+	admin, closeConn, err := c.admin()
+	if err != nil {
+		return err
+	}
+	defer closeConn()
+	ctx, cancel := c.context()
+	defer cancel()
+	resp, err := admin.Snapshot(ctx, &pb.SnapshotRequest{})
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(c.stdout, "Snapshot %s at index %d, term %d (%d bytes)\n", resp.Id, resp.Index, resp.Term, resp.Size)
+	return nil
+}
+
+func runBackup(c *cli, args []string) error {
+	if len(args) > 1 {
+		return errUsage
+	}
+	var dest string
+	if len(args) == 1 {
+		dest = args[0]
+	}
+	admin, closeConn, err := c.admin()
+	if err != nil {
+		return err
+	}
+	defer closeConn()
+	ctx, cancel := c.context()
+	defer cancel()
+	start := time.Now()
+	resp, err := admin.Backup(ctx, &pb.BackupRequest{Dest: dest})
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(c.stdout, "Backup written to %s in %s\n", resp.Location, time.Since(start).Round(time.Millisecond))
+	return nil
+}
+
+func runRestore(c *cli, args []string) error {
+	fs := c.flags("restore")
+	yes := fs.Bool("yes", false, "Confirm that the cluster's current data is replaced")
+	if err := fs.Parse(args); err != nil {
+		return errUsage
+	}
+	if fs.NArg() != 1 {
+		return errUsage
+	}
+	if !*yes {
+		return fmt.Errorf("restoring replaces every key in the cluster; pass -yes to confirm")
+	}
+	admin, closeConn, err := c.admin()
+	if err != nil {
+		return err
+	}
+	defer closeConn()
+	ctx, cancel := c.context()
+	defer cancel()
+	if _, err := admin.Restore(ctx, &pb.RestoreRequest{Source: fs.Arg(0)}); err != nil {
+		return err
+	}
+	fmt.Fprintf(c.stdout, "Cluster restored from %s\n", fs.Arg(0))
+	return nil
+}
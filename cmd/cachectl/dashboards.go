@@ -0,0 +1,59 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"distributed-cache-service/internal/observability"
+)
+
+// Files written by dashboards export.
+const (
+	dashboardFile = "grafana-dashboard.json"
+	alertsFile    = "alerts.yml"
+)
+
+// runDashboards generates monitoring config from the server's metric
+// definitions rather than talking to a node.
+func runDashboards(c *cli, args []string) error {
+	if len(args) == 0 || args[0] != "export" {
+		return errUsage
+	}
+	defaults := observability.DefaultThresholds()
+	fs := c.flags("dashboards export")
+	dir := fs.String("dir", ".", "Directory to write "+dashboardFile+" and "+alertsFile+" to")
+	missRatio := fs.Float64("miss_ratio", defaults.MissRatio, "Share of lookups that may miss before CacheHighMissRatio fires")
+	lag := fs.Int("lag_entries", defaults.LagEntries, "Entries a node may trail the most up-to-date one by before CacheReplicationLag fires")
+	evictions := fs.Float64("evictions_per_second", defaults.EvictionsPerSecond, "Eviction rate at which CacheEvictionStorm fires")
+	if err := fs.Parse(args[1:]); err != nil || fs.NArg() != 0 {
+		return errUsage
+	}
+
+	dashboard, err := observability.Dashboard()
+	if err != nil {
+		return err
+	}
+	rules, err := observability.AlertRules(observability.Thresholds{
+		MissRatio:          *missRatio,
+		LagEntries:         *lag,
+		EvictionsPerSecond: *evictions,
+	})
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(*dir, 0755); err != nil {
+		return err
+	}
+	for _, f := range []struct {
+		name string
+		data []byte
+	}{{dashboardFile, dashboard}, {alertsFile, rules}} {
+		path := filepath.Join(*dir, f.name)
+		if err := os.WriteFile(path, f.data, 0644); err != nil {
+			return err
+		}
+		fmt.Fprintf(c.stdout, "Wrote %s\n", path)
+	}
+	return nil
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"distributed-cache-service/client"
+	"distributed-cache-service/internal/migrate"
+	"distributed-cache-service/internal/resp"
+
+	"golang.org/x/sync/errgroup"
+)
+
+// importStats counts what an import did.
+type importStats struct {
+	strings, sets, expired, setTTLs atomic.Int64
+}
+
+func runImport(c *cli, args []string) error {
+	fs := c.flags("import")
+	fromRedis := fs.String("from_redis", "", "Read keys from the Redis server at host:port with SCAN")
+	file := fs.String("file", "", "Read keys from a dump of SET and ZADD commands, e.g. from /admin/export (- for stdin)")
+	password := fs.String("password", os.Getenv("REDIS_PASSWORD"), "Redis password (env REDIS_PASSWORD)")
+	db := fs.Int("db", 0, "Redis database number")
+	match := fs.String("match", "", "Only import Redis keys matching this glob pattern")
+	workers := fs.Int("c", 16, "Concurrent writes to the cluster")
+	if err := fs.Parse(args); err != nil {
+		return errUsage
+	}
+	if fs.NArg() != 0 || (*fromRedis == "") == (*file == "") || *workers < 1 {
+		return errUsage
+	}
+
+	cl, err := c.client()
+	if err != nil {
+		return err
+	}
+	defer cl.Close()
+
+	var stats importStats
+	records := make(chan migrate.Record, *workers)
+	g, ctx := errgroup.WithContext(context.Background())
+	for i := 0; i < *workers; i++ {
+		g.Go(func() error {
+			for rec := range records {
+				if err := c.write(ctx, cl, rec, &stats); err != nil {
+					return fmt.Errorf("import %q: %w", rec.Key, err)
+				}
+			}
+			return nil
+		})
+	}
+	send := func(rec migrate.Record) error {
+		select {
+		case records <- rec:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	var skipped map[string]int
+	g.Go(func() error {
+		defer close(records)
+		if *fromRedis != "" {
+			var err error
+			skipped, err = c.scanRedis(ctx, *fromRedis, *password, *db, *match, send)
+			return err
+		}
+		var r io.Reader = os.Stdin
+		if *file != "-" {
+			f, err := os.Open(*file)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			r = f
+		}
+		return migrate.ReadDump(r, send)
+	})
+	if err := g.Wait(); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(c.stdout, "Imported %d strings and %d sorted sets\n", stats.strings.Load(), stats.sets.Load())
+	if n := stats.expired.Load(); n > 0 {
+		fmt.Fprintf(c.stdout, "Skipped %d keys that expired before they were written\n", n)
+	}
+	if n := stats.setTTLs.Load(); n > 0 {
+		fmt.Fprintf(c.stdout, "Dropped the TTL of %d sorted sets, which do not expire in the cache\n", n)
+	}
+	types := make([]string, 0, len(skipped))
+	for typ := range skipped {
+		types = append(types, typ)
+	}
+	sort.Strings(types)
+	for _, typ := range types {
+		fmt.Fprintf(c.stdout, "Skipped %d keys of unsupported type %s\n", skipped[typ], typ)
+	}
+	return nil
+}
+
+// scanRedis connects to the Redis server at addr and sends each key it holds.
+func (c *cli) scanRedis(ctx context.Context, addr, password string, db int, match string, send func(migrate.Record) error) (map[string]int, error) {
+	dialCtx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	conn, err := resp.Dial(dialCtx, addr)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if password != "" {
+		if _, err := conn.Do(dialCtx, "AUTH", password); err != nil {
+			return nil, fmt.Errorf("redis auth: %w", err)
+		}
+	}
+	if db != 0 {
+		if _, err := conn.Do(dialCtx, "SELECT", fmt.Sprint(db)); err != nil {
+			return nil, fmt.Errorf("redis select: %w", err)
+		}
+	}
+	return migrate.ScanRedis(ctx, conn, migrate.ScanOptions{Match: match, Timeout: c.timeout}, send)
+}
+
+// write stores rec in the cluster with the TTL it has left.
+func (c *cli) write(ctx context.Context, cl *client.Client, rec migrate.Record, stats *importStats) error {
+	var ttl time.Duration
+	if !rec.ExpiresAt.IsZero() {
+		if ttl = time.Until(rec.ExpiresAt); ttl <= 0 {
+			stats.expired.Add(1)
+			return nil
+		}
+	}
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+	defer cancel()
+	if rec.Members == nil {
+		if err := cl.Set(ctx, rec.Key, rec.Value, ttl); err != nil {
+			return err
+		}
+		stats.strings.Add(1)
+		return nil
+	}
+	members := make([]client.ScoredMember, len(rec.Members))
+	for i, m := range rec.Members {
+		members[i] = client.ScoredMember{Member: m.Member, Score: m.Score}
+	}
+	if _, err := cl.ZAdd(ctx, rec.Key, members...); err != nil {
+		return err
+	}
+	stats.sets.Add(1)
+	if ttl > 0 {
+		stats.setTTLs.Add(1)
+	}
+	return nil
+}
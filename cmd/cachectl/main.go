@@ -0,0 +1,167 @@
+// Command cachectl administers a cache cluster over gRPC and inspects the
+// on-disk state of stopped nodes.
+//
+//	cachectl [-addr host:port] [-token T] <command> [args]
+//
+// Run cachectl without arguments for the list of commands.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"distributed-cache-service/client"
+	pb "distributed-cache-service/proto"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// errUsage makes cachectl print the usage of a command and exit with 2.
+var errUsage = errors.New("usage")
+
+// cli holds the global flags and the output streams shared by every command.
+type cli struct {
+	addr    string
+	token   string
+	timeout time.Duration
+	stdout  io.Writer
+	stderr  io.Writer
+}
+
+type command struct {
+	usage string
+	run   func(c *cli, args []string) error
+}
+
+var commands = map[string]command{
+	"get":             {"get <key>", runGet},
+	"set":             {"set [-ttl duration] <key> <value>", runSet},
+	"del":             {"del <key>", runDel},
+	"status":          {"status", runStatus},
+	"members":         {"members", runMembers},
+	"join":            {"join <node_id> <raft_addr>", runJoin},
+	"remove":          {"remove <node_id>", runRemove},
+	"snapshot":        {"snapshot", runSnapshot},
+	"backup":          {"backup [dest]", runBackup},
+	"restore":         {"restore -yes <source>", runRestore},
+	"rolling-restart": {"rolling-restart -exec <command> [-nodes id=host:port,...] [-wait d]", runRollingRestart},
+	"bench":           {"bench [-n ops | -duration d] [-c workers] [-keys n] [-dist uniform|zipf] [-size bytes] [-reads ratio] [-preload] [-json]", runBench},
+	"import":          {"import [-c workers] [-match pattern] [-password p] [-db n] -from_redis host:port | -file dump", runImport},
+	"raft":            {"raft verify|recover -dir <raft_dir> [-discard_logs]", runRaft},
+	"audit":           {"audit verify <audit_log>", runAudit},
+	"dashboards":      {"dashboards export [-dir path] [-miss_ratio r] [-lag_entries n] [-evictions_per_second n]", runDashboards},
+}
+
+// order lists the commands in the order usage prints them.
+var order = []string{"get", "set", "del", "status", "members", "join", "remove", "snapshot", "backup", "restore", "rolling-restart", "bench", "import", "raft", "audit", "dashboards"}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdout, os.Stderr))
+}
+
+func run(args []string, stdout, stderr io.Writer) int {
+	c := &cli{stdout: stdout, stderr: stderr}
+	fs := flag.NewFlagSet("cachectl", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.StringVar(&c.addr, "addr", envOr("CACHE_ADDR", "localhost:50051"), "gRPC address of a cache node (env CACHE_ADDR)")
+	fs.StringVar(&c.token, "token", os.Getenv("ADMIN_TOKEN"), "Admin token (env ADMIN_TOKEN)")
+	fs.DurationVar(&c.timeout, "timeout", 10*time.Second, "Timeout of each call")
+	fs.Usage = func() { printUsage(stderr, fs) }
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return 2
+	}
+
+	name := fs.Arg(0)
+	cmd, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(stderr, "cachectl: unknown command %q\n", name)
+		fs.Usage()
+		return 2
+	}
+	switch err := cmd.run(c, fs.Args()[1:]); {
+	case err == nil:
+		return 0
+	case errors.Is(err, errUsage):
+		fmt.Fprintf(stderr, "Usage: cachectl %s\n", cmd.usage)
+		return 2
+	default:
+		fmt.Fprintf(stderr, "cachectl %s: %s\n", name, describe(err))
+		return 1
+	}
+}
+
+func printUsage(w io.Writer, fs *flag.FlagSet) {
+	fmt.Fprintln(w, "Usage: cachectl [flags] <command> [args]")
+	fmt.Fprintln(w, "\nCommands:")
+	for _, name := range order {
+		fmt.Fprintf(w, "  %s\n", commands[name].usage)
+	}
+	fmt.Fprintln(w, "\nFlags:")
+	fs.PrintDefaults()
+}
+
+// describe turns a gRPC status into a message with a hint for the errors
+// operators run into most.
+func describe(err error) string {
+	st, ok := status.FromError(err)
+	if !ok {
+		return err.Error()
+	}
+	msg := fmt.Sprintf("%s (%s)", st.Message(), st.Code())
+	switch st.Code() {
+	case codes.Unauthenticated:
+		msg += "; pass the server's -admin_token with -token or ADMIN_TOKEN"
+	case codes.Unavailable:
+		msg += "; writes and cluster changes go to the leader, see `cachectl status`"
+	}
+	return msg
+}
+
+func envOr(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
+
+// flags returns a flag set for a command whose errors are reported by run.
+func (c *cli) flags(name string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(c.stderr)
+	return fs
+}
+
+// context returns the context of one call, carrying the admin token.
+func (c *cli) context() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	if c.token != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+c.token)
+	}
+	return ctx, cancel
+}
+
+// admin connects to the AdminService of the node at -addr.
+func (c *cli) admin() (pb.AdminServiceClient, func() error, error) {
+	conn, err := grpc.NewClient(c.addr, grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		return nil, nil, err
+	}
+	return pb.NewAdminServiceClient(conn), conn.Close, nil
+}
+
+func (c *cli) client() (*client.Client, error) {
+	return client.New(c.addr)
+}
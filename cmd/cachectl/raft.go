@@ -0,0 +1,101 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"distributed-cache-service/internal/consensus"
+)
+
+// runRaft works on the data directory of a stopped node rather than over gRPC.
+func runRaft(c *cli, args []string) error {
+	if len(args) == 0 {
+		return errUsage
+	}
+	switch args[0] {
+	case "verify":
+		return raftVerify(c, args[1:])
+	case "recover":
+		return raftRecover(c, args[1:])
+	default:
+		return errUsage
+	}
+}
+
+func raftVerify(c *cli, args []string) error {
+	fs := c.flags("raft verify")
+	dir := fs.String("dir", "raft_data", "Raft data directory of a stopped node")
+	if err := fs.Parse(args); err != nil {
+		return errUsage
+	}
+
+	report, err := consensus.VerifyDataDir(*dir)
+	if err != nil {
+		return err
+	}
+	printReport(c.stdout, report)
+	if !report.OK() {
+		return fmt.Errorf("%d problem(s) found in %s", len(report.Problems), *dir)
+	}
+	return nil
+}
+
+func raftRecover(c *cli, args []string) error {
+	fs := c.flags("raft recover")
+	dir := fs.String("dir", "raft_data", "Raft data directory of a stopped node")
+	discard := fs.Bool("discard_logs", false, "Drop every log entry and restart from the newest valid snapshot alone")
+	if err := fs.Parse(args); err != nil {
+		return errUsage
+	}
+
+	res, err := consensus.RecoverDataDir(*dir, *discard)
+	if err != nil {
+		return err
+	}
+	if len(res.Moved) == 0 {
+		fmt.Fprintln(c.stdout, "Nothing to recover.")
+		return nil
+	}
+	fmt.Fprintf(c.stdout, "Moved to %s:\n", res.Quarantine)
+	for _, m := range res.Moved {
+		fmt.Fprintf(c.stdout, "  %s\n", m)
+	}
+	if res.Snapshot != nil {
+		fmt.Fprintf(c.stdout, "Raft will restore snapshot %s (index %d, term %d).\n", res.Snapshot.ID, res.Snapshot.Index, res.Snapshot.Term)
+	}
+	if res.LogFirst > 0 {
+		fmt.Fprintf(c.stdout, "Log holds entries %d-%d.\n", res.LogFirst, res.LogLast)
+	} else {
+		fmt.Fprintln(c.stdout, "Log is empty.")
+	}
+	fmt.Fprintln(c.stdout, "Delete the quarantine directory once the node has rejoined the cluster.")
+	return nil
+}
+
+func printReport(w io.Writer, r *consensus.VerifyReport) {
+	fmt.Fprintf(w, "Raft data: %s\n", r.Dir)
+	switch {
+	case r.DBErr != nil:
+		fmt.Fprintf(w, "  raft.db:   unreadable: %v\n", r.DBErr)
+	case r.LogFirst == 0:
+		fmt.Fprintf(w, "  raft.db:   empty log, term %d\n", r.CurrentTerm)
+	default:
+		fmt.Fprintf(w, "  raft.db:   entries %d-%d, term %d, %d unreadable\n", r.LogFirst, r.LogLast, r.CurrentTerm, len(r.BadLogs))
+	}
+	for _, s := range r.Snapshots {
+		if s.Err != nil {
+			fmt.Fprintf(w, "  snapshot:  %s BAD: %v\n", s.ID, s.Err)
+		} else {
+			fmt.Fprintf(w, "  snapshot:  %s index %d, term %d\n", s.ID, s.Index, s.Term)
+		}
+	}
+	if r.OK() {
+		fmt.Fprintln(w, "OK")
+		return
+	}
+	fmt.Fprintln(w, "Problems:")
+	for _, p := range r.Problems {
+		fmt.Fprintf(w, "  - %s\n", p)
+	}
+	fmt.Fprintln(w, r.Guidance())
+}
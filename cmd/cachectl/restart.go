@@ -0,0 +1,255 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	pb "distributed-cache-service/proto"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+// pollInterval is how often rolling-restart checks on a node it waits for.
+const pollInterval = 500 * time.Millisecond
+
+// runRollingRestart restarts every member of the cluster in turn with the
+// -exec command: followers first, then the leader once it has handed over
+// leadership. It only moves on to the next member once the last one has
+// rejoined and applied every entry the leader had committed when it came
+// back, so the cluster never has more than one member down.
+func runRollingRestart(c *cli, args []string) error {
+	fs := c.flags("rolling-restart")
+	command := fs.String("exec", "", "Shell command restarting one node, run with NODE_ID, NODE_RAFT_ADDR and NODE_GRPC_ADDR set; it must return once the old process has stopped")
+	nodes := fs.String("nodes", "", "Comma-separated id=host:port gRPC addresses of members not reachable at their Raft host and -addr's port")
+	wait := fs.Duration("wait", 5*time.Minute, "How long a restarted node may take to rejoin and catch up")
+	if err := fs.Parse(args); err != nil {
+		return errUsage
+	}
+	if fs.NArg() != 0 || *command == "" {
+		return errUsage
+	}
+
+	r := &roller{cli: c, grpcAddrs: make(map[string]string), conns: make(map[string]*grpc.ClientConn)}
+	defer r.close()
+	for _, pair := range strings.Split(*nodes, ",") {
+		if pair == "" {
+			continue
+		}
+		id, addr, ok := strings.Cut(pair, "=")
+		if !ok || id == "" || addr == "" {
+			return fmt.Errorf("invalid -nodes entry %q, want id=host:port", pair)
+		}
+		r.grpcAddrs[id] = addr
+	}
+
+	admin, err := r.admin(c.addr)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := c.context()
+	resp, err := admin.Members(ctx, &pb.MembersRequest{})
+	cancel()
+	if err != nil {
+		return err
+	}
+	members := resp.Members
+	// The leader goes last, so that leadership moves once rather than with
+	// every restart.
+	sort.Slice(members, func(i, j int) bool {
+		if members[i].Leader != members[j].Leader {
+			return members[j].Leader
+		}
+		return members[i].Id < members[j].Id
+	})
+	if err := r.resolve(members); err != nil {
+		return err
+	}
+
+	start := time.Now()
+	for i, m := range members {
+		fmt.Fprintf(c.stdout, "[%d/%d] %s\n", i+1, len(members), m.Id)
+		if err := r.restart(m, members, *command, *wait); err != nil {
+			return fmt.Errorf("%s: %w; the members after it were not restarted", m.Id, err)
+		}
+	}
+	fmt.Fprintf(c.stdout, "Restarted %d members in %s\n", len(members), time.Since(start).Round(time.Second))
+	return nil
+}
+
+// roller holds the connections of a rolling restart, one per member.
+type roller struct {
+	*cli
+	// grpcAddrs maps member IDs, and their Raft addresses, to gRPC addresses.
+	grpcAddrs map[string]string
+	conns     map[string]*grpc.ClientConn
+}
+
+// resolve finds the gRPC address of every member not given with -nodes: the
+// one it advertised, or else the host of its Raft address and the port of
+// -addr.
+func (r *roller) resolve(members []*pb.ClusterMember) error {
+	_, port, err := net.SplitHostPort(r.addr)
+	if err != nil {
+		return fmt.Errorf("-addr: %w", err)
+	}
+	for _, m := range members {
+		if _, ok := r.grpcAddrs[m.Id]; !ok && m.GrpcAddr != "" {
+			r.grpcAddrs[m.Id] = m.GrpcAddr
+		}
+		if _, ok := r.grpcAddrs[m.Id]; !ok {
+			host, _, err := net.SplitHostPort(m.Addr)
+			if err != nil {
+				return fmt.Errorf("raft address of %s: %w", m.Id, err)
+			}
+			r.grpcAddrs[m.Id] = net.JoinHostPort(host, port)
+		}
+		r.grpcAddrs[m.Addr] = r.grpcAddrs[m.Id]
+	}
+	return nil
+}
+
+func (r *roller) admin(addr string) (pb.AdminServiceClient, error) {
+	conn, ok := r.conns[addr]
+	if !ok {
+		var err error
+		if conn, err = grpc.NewClient(addr, grpc.WithTransportCredentials(insecure.NewCredentials())); err != nil {
+			return nil, err
+		}
+		r.conns[addr] = conn
+	}
+	return pb.NewAdminServiceClient(conn), nil
+}
+
+func (r *roller) close() {
+	for _, conn := range r.conns {
+		conn.Close()
+	}
+}
+
+// stats returns the status of the member with the given ID or Raft address.
+func (r *roller) stats(member string) (*pb.StatsResponse, error) {
+	admin, err := r.admin(r.grpcAddrs[member])
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := r.context()
+	defer cancel()
+	return admin.Stats(ctx, &pb.StatsRequest{})
+}
+
+// restart restarts m and waits for it to catch up.
+func (r *roller) restart(m *pb.ClusterMember, members []*pb.ClusterMember, command string, wait time.Duration) error {
+	// Taking m down must not cost the quorum.
+	for _, other := range members {
+		if other.Id == m.Id {
+			continue
+		}
+		s, err := r.stats(other.Id)
+		if err != nil {
+			return fmt.Errorf("member %s is not answering, so restarting this one could lose the quorum: %v", other.Id, err)
+		}
+		if s.Leader == "" {
+			return fmt.Errorf("member %s sees no leader", other.Id)
+		}
+	}
+
+	s, err := r.stats(m.Id)
+	if err != nil {
+		return err
+	}
+	if s.State == "Leader" {
+		fmt.Fprintf(r.stdout, "  transferring leadership\n")
+		if err := r.handOver(m, wait); err != nil {
+			return err
+		}
+	}
+
+	fmt.Fprintf(r.stdout, "  restarting\n")
+	start := time.Now()
+	cmd := exec.Command("sh", "-c", command)
+	cmd.Env = append(os.Environ(), "NODE_ID="+m.Id, "NODE_RAFT_ADDR="+m.Addr, "NODE_GRPC_ADDR="+r.grpcAddrs[m.Id])
+	cmd.Stdout, cmd.Stderr = r.stdout, r.stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("-exec: %w", err)
+	}
+
+	index, err := r.catchUp(m, time.Now().Add(wait))
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(r.stdout, "  caught up at index %d after %s\n", index, time.Since(start).Round(time.Second))
+	return nil
+}
+
+// handOver moves leadership from m to another member.
+func (r *roller) handOver(m *pb.ClusterMember, wait time.Duration) error {
+	admin, err := r.admin(r.grpcAddrs[m.Id])
+	if err != nil {
+		return err
+	}
+	ctx, cancel := r.context()
+	_, err = admin.TransferLeadership(ctx, &pb.TransferLeadershipRequest{})
+	cancel()
+	if err != nil {
+		return fmt.Errorf("transfer leadership: %w", err)
+	}
+	return poll(wait, func() (bool, error) {
+		s, err := r.stats(m.Id)
+		return err == nil && s.State != "Leader" && s.Leader != "" && s.Leader != m.Addr, nil
+	})
+}
+
+// catchUp waits until m answers, follows a leader and has applied every
+// entry the leader had committed when m first answered. It returns that
+// index.
+func (r *roller) catchUp(m *pb.ClusterMember, deadline time.Time) (uint64, error) {
+	var target uint64
+	sampled := false
+	err := poll(time.Until(deadline), func() (bool, error) {
+		s, err := r.stats(m.Id)
+		if err != nil || s.Leader == "" || s.State == "Candidate" {
+			return false, nil
+		}
+		if !sampled {
+			ls, err := r.stats(s.Leader)
+			if err != nil {
+				return false, nil
+			}
+			if target, err = strconv.ParseUint(ls.Raft["commit_index"], 10, 64); err != nil {
+				return false, fmt.Errorf("leader's commit index: %w", err)
+			}
+			sampled = true
+		}
+		applied, err := strconv.ParseUint(s.Raft["applied_index"], 10, 64)
+		return err == nil && applied >= target, nil
+	})
+	return target, err
+}
+
+// poll calls done every pollInterval until it reports true or fails, or
+// wait has passed.
+func poll(wait time.Duration, done func() (bool, error)) error {
+	ctx, cancel := context.WithTimeout(context.Background(), wait)
+	defer cancel()
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+	for {
+		ok, err := done()
+		if ok || err != nil {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("gave up after %s", wait)
+		case <-ticker.C:
+		}
+	}
+}
@@ -1,29 +1,59 @@
 package main
 
 import (
+	"context"
+	"encoding/json"
 	"flag"
 	"fmt"
+	"io"
 	"log"
+	"log/slog"
+	"maps"
 	"net"
 	"net/http"
+	"net/url"
 	"os"
+	"slices"
 	"strings" // Added for strings.ToLower
+	"sync/atomic"
 	"time"
 
+	"distributed-cache-service/internal/audit"
+	"distributed-cache-service/internal/auth"
+	"distributed-cache-service/internal/backup"
+	"distributed-cache-service/internal/cdc"
+	"distributed-cache-service/internal/compression"
+	_ "distributed-cache-service/internal/compression/grpczstd"
+	"distributed-cache-service/internal/config"
 	"distributed-cache-service/internal/consensus"
+	"distributed-cache-service/internal/core/ports"
 	"distributed-cache-service/internal/core/service"
+	"distributed-cache-service/internal/diagnostics"
+	"distributed-cache-service/internal/discovery"
+	"distributed-cache-service/internal/encryption"
+	"distributed-cache-service/internal/events"
+	"distributed-cache-service/internal/migrate"
+	"distributed-cache-service/internal/observability"
+	"distributed-cache-service/internal/peers"
+	"distributed-cache-service/internal/position"
+	"distributed-cache-service/internal/ratelimit"
+	"distributed-cache-service/internal/router"
 	"distributed-cache-service/internal/sharding"
 	"distributed-cache-service/internal/store"
+	"distributed-cache-service/internal/store/boltstore"
 	"distributed-cache-service/internal/store/policy" // Added for eviction policies
-
-	_ "net/http/pprof" // Register pprof handlers
+	"distributed-cache-service/internal/writebehind"
 
 	"github.com/hashicorp/raft"
-	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"google.golang.org/grpc"
+	grpcgzip "google.golang.org/grpc/encoding/gzip"
+	"google.golang.org/grpc/reflection"
 
 	// Added for raft-boltdb
 	grpcAdapter "distributed-cache-service/internal/grpc"
+	httpAdapter "distributed-cache-service/internal/http"
+	"distributed-cache-service/internal/loader"
+	"distributed-cache-service/internal/mux"
 	pb "distributed-cache-service/proto"
 )
 
@@ -35,13 +65,99 @@ func main() {
 		raftAddr     = flag.String("raft_addr", ":11000", "Raft communication address")
 		raftAdv      = flag.String("raft_advertise", "", "Advertised Raft address (defaults to local IP if raft_addr is generic)")
 		raftDir      = flag.String("raft_dir", "raft_data", "Raft data directory")
+		raftSnapN    = flag.Uint64("raft_snapshot_threshold", 0, "Entries committed since the last Raft snapshot before another is taken (0 = 8192)")
+		raftSnapIvl  = flag.Duration("raft_snapshot_interval", 0, "How often the Raft snapshot threshold is checked (0 = 2m)")
+		raftTrailing = flag.Uint64("raft_trailing_logs", 0, "Log entries kept after a Raft snapshot for lagging followers (0 = 10240)")
+		raftHBWait   = flag.Duration("raft_heartbeat_timeout", 0, "Time a follower waits for the leader before starting an election (0 = 1s)")
+		raftElection = flag.Duration("raft_election_timeout", 0, "Time a candidate waits for votes before retrying (0 = 1s)")
+		raftLogMax   = flag.Int64("raft_log_max_bytes", 0, "Compact the Raft log once its entries reach this size in bytes (0 = unbounded)")
+		raftPreVote  = flag.Bool("raft_prevote", true, "Run a pre-vote before Raft elections so rejoining nodes cannot depose a healthy leader")
+		raftLease    = flag.Duration("raft_leader_lease_timeout", 0, "Time a Raft leader cut off from a quorum keeps leading before stepping down (0 = 500ms, at most the heartbeat timeout)")
+		raftVerify   = flag.Bool("raft_verify", true, "Verify the Raft log and snapshots in -raft_dir before starting")
+		raftReap     = flag.Duration("raft_reap_after", 0, "Remove members the leader has lost contact with for this long from the Raft configuration (0 = off)")
+		reapConfirm  = flag.Bool("raft_reap_confirm", false, "List dead members at /admin/cluster/dead for an operator to remove instead of removing them")
+		reapMin      = flag.Int("raft_reap_min_voters", 3, "Fewest voters automatic reaping leaves in the cluster")
 		bootstrap    = flag.Bool("bootstrap", false, "Bootstrap the cluster (only for the first node)")
-		joinAddr     = flag.String("join", "", "Address of the leader to join")
+		standalone   = flag.Bool("standalone", false, "Run a single node without Raft: writes apply directly to the store and -raft_dir is unused (local development)")
+		witness      = flag.Bool("witness", false, "Vote in Raft elections without holding data, e.g. as a tie-breaker in a third site; every read and write fails with Witness")
+		joinAddr     = flag.String("join", "", "Comma-separated HTTP addresses of cluster nodes to join through")
+		discoverDNS  = flag.String("discovery", "", "Find the -bootstrap_expect peers via DNS: dns:<name> (A records) or srv:<name> (empty = off)")
+		bootstrapN   = flag.Int("bootstrap_expect", 0, "Form a cluster of this many nodes, found via -join or -discovery, without -bootstrap (0 = off)")
 		maxItems     = flag.Int("max_items", 0, "Maximum number of items in the cache (0 = unlimited)")
 		evictionPol  = flag.String("eviction_policy", "lru", "Eviction policy: lru, fifo, lfu, random, none")
+		lfuHalfLife  = flag.Duration("lfu_half_life", policy.DefaultHalfLife, "How often the lfu policy halves its access counts, so that keys no longer read become evictable (0 = never)")
 		grpcAddr     = flag.String("grpc_addr", ":50051", "gRPC Server address")
+		grpcAdv      = flag.String("grpc_advertise", "", "gRPC address members and clients reach this node at (defaults to the Raft advertise host and the grpc_addr port)")
+		grpcKATime   = flag.Duration("grpc_keepalive_time", 0, "Idle time after which the gRPC server pings a client (0 = 2h)")
+		grpcKAWait   = flag.Duration("grpc_keepalive_timeout", 0, "Time the gRPC server waits for a ping ack before closing the connection (0 = 20s)")
+		grpcKAMin    = flag.Duration("grpc_keepalive_min_time", 0, "Minimum interval between client keepalive pings (0 = 5m)")
+		grpcKANoCall = flag.Bool("grpc_keepalive_permit_without_stream", false, "Allow client keepalive pings with no call in flight")
+		grpcMaxIdle  = flag.Duration("grpc_max_connection_idle", 0, "Close gRPC connections idle for this long (0 = never)")
+		grpcMaxAge   = flag.Duration("grpc_max_connection_age", 0, "Close gRPC connections after this long so clients rebalance (0 = never)")
+		grpcAgeGrace = flag.Duration("grpc_max_connection_age_grace", 0, "Time calls may run after -grpc_max_connection_age (0 = unlimited)")
+		grpcStreams  = flag.Uint("grpc_max_concurrent_streams", 0, "Maximum concurrent calls per gRPC connection (0 = unlimited)")
+		grpcMaxRecv  = flag.Int("grpc_max_recv_msg_size", 0, "Maximum gRPC request size in bytes (0 = 4 MiB)")
+		grpcMaxSend  = flag.Int("grpc_max_send_msg_size", 0, "Maximum gRPC response size in bytes (0 = unlimited)")
+		grpcMaxValue = flag.Int("grpc_max_stream_value_size", 0, "Largest value SetStream accepts in bytes; it is replicated as one Raft entry (0 = -grpc_max_recv_msg_size)")
 		virtualNodes = flag.Int("virtual_nodes", 100, "Number of virtual nodes for consistent hashing")
+		ringHash     = flag.String("ring_hash", "crc32", "Hash function of the consistent hashing ring: crc32, xxhash, murmur3")
 		consistency  = flag.String("consistency", "strong", "Consistency mode: strong, eventual")
+		metricPfx    = flag.String("metrics_prefixes", "", "Comma-separated key prefixes that get their own hit/miss/latency metrics (empty = none)")
+		hotKeyCap    = flag.Int("hot_keys", 0, "Track the most read keys in this many slots, for /v1/admin/hotkeys and the admin UI (0 = off)")
+		ttlJitter    = flag.Float64("ttl_jitter", 0, "Random ±percentage applied to each TTL written, to spread out expirations (0 = off)")
+		defaultTTL   = flag.String("default_ttl", "", "TTL of writes that give none: comma-separated [prefix=]duration, e.g. 1h,session:=30m (empty = none)")
+		maxTTL       = flag.String("max_ttl", "", "Longest TTL a write may set: comma-separated [prefix=]duration; writes without a TTL count as over it (empty = unbounded)")
+		maxTTLReject = flag.Bool("max_ttl_reject", false, "Reject writes over -max_ttl instead of lowering their TTL to it")
+		quotaKeys    = flag.String("quota_keys", "", "Most keys per namespace: comma-separated [prefix=]count, e.g. user:=100000 (empty = unlimited)")
+		quotaBytes   = flag.String("quota_bytes", "", "Most bytes of keys and values per namespace: comma-separated [prefix=]bytes (empty = unlimited)")
+		quotaRate    = flag.String("quota_write_rate", "", "Most writes per second per namespace: comma-separated [prefix=]rate (empty = unlimited)")
+		maxLag       = flag.Uint64("max_lag", 0, "Committed entries an eventual read may lag behind the leader (0 = unbounded)")
+		configFile   = flag.String("config", "", "Path to a JSON runtime config file, re-read on SIGHUP")
+		logLevel     = flag.String("log_level", "info", "Log level: debug, info, warn, error")
+		otlpEndpoint = flag.String("otlp_endpoint", "", "OTLP/gRPC collector URL that gRPC call spans are exported to, e.g. http://localhost:4317 (empty = off)")
+		rateLimit    = flag.Float64("rate_limit", 0, "Maximum client requests per second (0 = unlimited)")
+		rateBurst    = flag.Int("rate_burst", 0, "Burst size for the rate limiter (defaults to rate_limit)")
+		cleanupEvery = flag.Duration("cleanup_interval", time.Minute, "Interval for purging expired keys (0 = disabled)")
+		storageKind  = flag.String("storage", "memory", "Storage backend: memory, bolt")
+		storagePath  = flag.String("storage_path", "cache.db", "Database file for on-disk storage backends")
+		compressAlg  = flag.String("compression", "none", "Value compression codec: none, deflate, snappy, zstd")
+		compressMin  = flag.Int("compression_threshold", 1024, "Minimum value size in bytes to compress")
+		encryptEnv   = flag.String("encryption_keys_env", "", "Environment variable holding the id:base64-key pairs values are encrypted at rest with, current key first (empty = off)")
+		gzipLevel    = flag.Int("gzip_level", 1, "gzip level (1-9) for compressed gRPC messages and HTTP responses")
+		httpGzipMin  = flag.Int("http_gzip_min_size", 1024, "Minimum HTTP response size in bytes to gzip for clients that accept it (0 = off)")
+		wsOrigins    = flag.String("ws_origins", "", "Comma-separated origins of browser pages allowed to open /v1/ws besides the server's own, e.g. https://dashboard.example.com")
+		loaderURL    = flag.String("loader_url", "", "Read-through loader endpoint; {key} is replaced by the key (empty = off)")
+		loaderTTL    = flag.Duration("loader_ttl", 5*time.Minute, "TTL for loaded values without Cache-Control max-age")
+		loaderWait   = flag.Duration("loader_timeout", 2*time.Second, "Timeout for each loader request")
+		loaderEarly  = flag.String("loader_early_beta", "", "Probabilistic early refresh before expiry: comma-separated [prefix=]beta, e.g. 1,session:=0 (empty = off)")
+		loaderStale  = flag.Duration("loader_stale", 0, "How long after expiry a value is still served while the loader refreshes it in the background (0 = off)")
+		wbSink       = flag.String("writebehind_url", "", "Write-behind sink: http(s):// webhook or kafka://rest-proxy/topic (empty = off)")
+		wbQueue      = flag.Int("writebehind_queue", 10000, "Max mutations waiting for write-behind delivery")
+		wbRetries    = flag.Int("writebehind_retries", 5, "Delivery retries before a batch is dead-lettered")
+		cdcSink      = flag.String("cdc_url", "", "Change-data-capture export: kafka://rest-proxy/topic or nats://host:port/subject (empty = off)")
+		applyTimeout = flag.Duration("apply_timeout", consensus.DefaultApplyTimeout, "Default time a write waits for Raft confirmation when the request has no deadline")
+		quorumWait   = flag.Duration("quorum_timeout", consensus.DefaultQuorumTimeout, "How long a node may go without hearing from a leader before it fails writes and strong reads with NoQuorum and /readyz fails")
+		readTimeout  = flag.Duration("read_timeout", service.DefaultConfig.ReadTimeout, "Default time a read waits for a leadership check or the loader when the request has no deadline")
+		retries      = flag.Int("retry_attempts", service.DefaultConfig.Retry.Attempts, "Most times a write or strong read is tried on a leader during a leader change (1 = no retries)")
+		retryBackoff = flag.Duration("retry_backoff", service.DefaultConfig.Retry.Backoff, "Wait before the first retry during a leader change; doubles for each retry")
+		brkFailures  = flag.Int("breaker_failures", 5, "Failed writes in a row after which writes fail fast with Unavailable (0 = off)")
+		brkCooldown  = flag.Duration("breaker_cooldown", 5*time.Second, "How long writes fail fast before one is let through to probe replication")
+		batchWindow  = flag.Duration("batch_window", 0, "How long the leader collects concurrent writes into one Raft entry (0 = off)")
+		batchMax     = flag.Int("batch_max_commands", 128, "Most writes per batched Raft entry")
+		batchBytes   = flag.Int("batch_max_bytes", 1<<20, "Most bytes of keys and values per batched Raft entry")
+		dedupWindow  = flag.Duration("dedup_window", consensus.DefaultDedupWindow, "How long write request IDs are remembered for deduplication (0 = off; must match on all nodes)")
+		tombstoneRet = flag.Duration("tombstone_retention", 0, "How long deletes leave tombstones that refuse older writes copied from elsewhere (0 = off)")
+		offHeap      = flag.Bool("off_heap", false, "Store values in pointer-free slab pages to reduce GC pauses (memory backend)")
+		bloomKeys    = flag.Int("bloom_keys", 0, "Expected number of keys for a Bloom filter that short-circuits misses (memory backend, 0 = off)")
+		aofPath      = flag.String("aof_path", "", "Append-only file for local durability independent of Raft (empty = disabled)")
+		aofFsync     = flag.String("aof_fsync", "everysec", "AOF fsync policy: always, everysec, no")
+		backupDest   = flag.String("backup_dest", "", "Default backup location (path, file:// or s3://bucket/key)")
+		restoreFrom  = flag.String("restore_from", "", "Backup location to restore the cluster from after bootstrap")
+		warmupFrom   = flag.String("warmup_from", "", "Exported dataset or backup to load into a new cluster before serving (path, file://, s3:// or http(s)://)")
+		adminToken   = flag.String("admin_token", os.Getenv("ADMIN_TOKEN"), "Bearer token required for admin endpoints (empty = no auth)")
+		auditPath    = flag.String("audit_log", "", "Append-only, hash-chained log of admin and membership operations (empty = off)")
+		auditHook    = flag.String("audit_webhook", "", "URL each audit record is also POSTed to as JSON (empty = off)")
+		debugAddr    = flag.String("debug_addr", "", "Address serving pprof, expvar, GC and store statistics, behind -admin_token (empty = off)")
 	)
 	// -------------------------------------------------------------------------
 	// 1. Parsing Configuration
@@ -53,225 +169,1062 @@ func main() {
 		*httpAddr = ":" + port
 	}
 
-	if err := os.MkdirAll(*raftDir, 0700); err != nil {
+	if *witness && (*standalone || *bootstrap || *restoreFrom != "" || *warmupFrom != "") {
+		log.Fatalf("-witness holds no data and must join a Raft cluster of data nodes; drop -standalone, -bootstrap, -restore_from and -warmup_from")
+	}
+	if *standalone {
+		if *bootstrap || *joinAddr != "" || *discoverDNS != "" || *bootstrapN > 0 {
+			log.Fatalf("-standalone runs without a cluster; drop -bootstrap, -join, -discovery and -bootstrap_expect")
+		}
+	} else if err := os.MkdirAll(*raftDir, 0700); err != nil {
 		log.Fatalf("Failed to create raft directory: %v", err)
 	}
 
-	// Configure Store with options
-	var storeOpts []store.Option
+	// Configure Store with options. The leader chooses eviction victims and
+	// replicates their deletion, so stores never evict on their own.
+	storeOpts := []store.Option{store.WithDeferredEviction()}
 	if *maxItems > 0 {
 		storeOpts = append(storeOpts, store.WithCapacity(*maxItems))
-		var p policy.EvictionPolicy
-		switch strings.ToLower(*evictionPol) {
-		case "lru":
-			p = policy.NewLRU()
-		case "fifo":
-			p = policy.NewFIFO()
-		case "lfu":
-			p = policy.NewLFU()
-		case "random":
-			p = policy.NewRandom()
-		case "none":
-			p = nil
-		default:
-			log.Printf("Unknown eviction policy '%s', defaulting to LRU", *evictionPol)
+		p, err := policy.New(*evictionPol, policy.WithHalfLife(*lfuHalfLife))
+		if err != nil {
+			log.Printf("%v, defaulting to LRU", err)
 			p = policy.NewLRU()
 		}
 		if p != nil {
 			storeOpts = append(storeOpts, store.WithPolicy(p))
 		}
 	}
+	if *offHeap {
+		storeOpts = append(storeOpts, store.WithOffHeap())
+	}
+	if *bloomKeys > 0 {
+		storeOpts = append(storeOpts, store.WithBloomFilter(*bloomKeys, store.DefaultBloomFalsePositiveRate))
+	}
+	quotas, err := service.ParseQuotas(*quotaKeys, *quotaBytes, *quotaRate)
+	if err != nil {
+		log.Fatalf("Invalid quota: %v", err)
+	}
+	if len(quotas) > 0 {
+		if (*quotaKeys != "" || *quotaBytes != "") && strings.ToLower(*storageKind) != "memory" {
+			log.Fatalf("-quota_keys and -quota_bytes need the memory storage backend")
+		}
+		storeOpts = append(storeOpts, store.WithUsagePrefixes(slices.Collect(maps.Keys(quotas))...))
+	}
 
 	// -------------------------------------------------------------------------
 	// 2. Core Domain & Storage Setup
 	// -------------------------------------------------------------------------
 	// Initialize Sharding Ring (Virtual Nodes)
 	// Note: Currently local-only view, but prepared for Smart Client / Partitioning
-	_ = sharding.New(*virtualNodes, nil)
+	hashFn, err := sharding.ParseHash(*ringHash)
+	if err != nil {
+		log.Fatalf("Invalid ring_hash: %v", err)
+	}
+	_ = sharding.New(*virtualNodes, hashFn)
 
-	// Initialize Store and FSM
-	kvStore := store.New(storeOpts...)
-	fsm := consensus.NewFSM(kvStore)
+	// Initialize Storage Backend and FSM
+	var kvStore ports.SnapshotStorage
+	switch strings.ToLower(*storageKind) {
+	case "memory":
+		memStore := store.New(storeOpts...)
+		if *aofPath != "" {
+			fsync, err := store.ParseFsyncPolicy(*aofFsync)
+			if err != nil {
+				log.Fatalf("Invalid aof_fsync: %v", err)
+			}
+			if err := memStore.OpenAOF(*aofPath, fsync); err != nil {
+				log.Fatalf("Failed to open AOF: %v", err)
+			}
+			log.Printf("AOF enabled at %s (fsync=%s), recovered %d keys", *aofPath, *aofFsync, memStore.Len())
+		}
+		for _, reason := range store.EvictReasons {
+			observability.StoreRemovalsTotal.WithLabelValues(string(reason))
+		}
+		memStore.OnEvict(func(_, _ string, reason store.EvictReason) {
+			observability.StoreRemovalsTotal.WithLabelValues(string(reason)).Inc()
+		})
+		kvStore = memStore
+	case "bolt":
+		boltStore, err := boltstore.Open(*storagePath)
+		if err != nil {
+			log.Fatalf("Failed to open bolt storage: %v", err)
+		}
+		log.Printf("Using bolt storage at %s (%d keys)", *storagePath, boltStore.Len())
+		kvStore = boltStore
+	default:
+		log.Fatalf("Unknown storage backend '%s'", *storageKind)
+	}
+	// Values are sealed before they reach the Raft log, so the keys are
+	// loaded before Raft replays its log into the FSM.
+	var valueCipher *encryption.Cipher
+	if *encryptEnv != "" {
+		var err error
+		if valueCipher, err = encryption.New(context.Background(), encryption.EnvKeys(*encryptEnv)); err != nil {
+			log.Fatalf("Invalid encryption keys: %v", err)
+		}
+		log.Printf("Encrypting values at rest with keys from $%s", *encryptEnv)
+	}
 
-	// Determine advertise address
-	// Determine advertise address and bind address
-	var bindAddr string
-	advertiseAddr := *raftAdv
+	keyspaceEvents := events.NewBroker()
+	fsmOpts := []consensus.FSMOption{
+		consensus.WithEvents(keyspaceEvents),
+		consensus.WithDedupWindow(*dedupWindow),
+		consensus.WithEncryption(valueCipher),
+	}
 
-	host, port, err := net.SplitHostPort(*raftAddr)
-	if err != nil {
-		log.Fatalf("Invalid raft_addr: %v", err)
+	// Write-behind delivers from the leader only. Raft may apply entries before
+	// SetupRaft returns, hence the atomic handle. A standalone node always leads.
+	var leaderNode atomic.Pointer[consensus.RaftNode]
+	isLeader := func() bool {
+		n := leaderNode.Load()
+		return *standalone || n != nil && n.IsLeader()
 	}
+	if *wbSink != "" {
+		sink, err := writebehind.ParseSink(*wbSink)
+		if err != nil {
+			log.Fatalf("Invalid writebehind_url: %v", err)
+		}
+		wbQueue := writebehind.New(sink,
+			writebehind.WithQueueSize(*wbQueue),
+			writebehind.WithRetries(*wbRetries, 100*time.Millisecond),
+			writebehind.WithLeaderCheck(isLeader),
+			writebehind.WithEncryption(valueCipher),
+		)
+		fsmOpts = append(fsmOpts, consensus.WithWriteBehind(wbQueue))
+	}
+	if *cdcSink != "" {
+		sink, err := cdc.ParseSink(*cdcSink)
+		if err != nil {
+			log.Fatalf("Invalid cdc_url: %v", err)
+		}
+		cdcQueue := writebehind.New(sink, writebehind.WithLeaderCheck(isLeader), writebehind.WithEncryption(valueCipher))
+		fsmOpts = append(fsmOpts, consensus.WithWriteBehind(cdcQueue))
+	}
+	if *witness {
+		fsmOpts = append(fsmOpts, consensus.WithWitness())
+	}
+	fsm := consensus.NewFSM(kvStore, fsmOpts...)
 
-	if host == "" || host == "0.0.0.0" {
-		// Resolve local IP
-		addr, err := getLocalIP()
+	// Admin endpoints are token protected, and changes to the cluster audited.
+	authenticator := auth.New(*adminToken)
+	if !authenticator.Enabled() {
+		log.Printf("WARNING: admin endpoints are unauthenticated; set -admin_token to protect them")
+	}
+	var auditLog *audit.Log
+	if *auditPath != "" {
+		opts := []audit.Option{audit.WithPrincipal(authenticator.Principal)}
+		if *auditHook != "" {
+			opts = append(opts, audit.WithWebhook(*auditHook))
+		}
+		var err error
+		if auditLog, err = audit.Open(*auditPath, opts...); err != nil {
+			log.Fatalf("Failed to open audit log: %v", err)
+		}
+		defer auditLog.Close()
+	}
+
+	// Runtime configuration (hot-reloadable via SIGHUP or /admin/config)
+	logLevelVar := new(slog.LevelVar)
+	slog.SetDefault(slog.New(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: logLevelVar})))
+	limiter := ratelimit.New(*rateLimit, *rateBurst)
+	// Expired keys are purged by the service, through Raft, which only exists once
+	// Raft is set up; until then the interval is only recorded in runtimeCfg.
+	var purger atomic.Pointer[service.ServiceImpl]
+	runtimeCfg := config.NewManager(config.Runtime{
+		MaxItems:        *maxItems,
+		EvictionPolicy:  *evictionPol,
+		LFUHalfLife:     config.Duration{Duration: *lfuHalfLife},
+		LogLevel:        *logLevel,
+		RateLimit:       *rateLimit,
+		RateBurst:       *rateBurst,
+		CleanupInterval: config.Duration{Duration: *cleanupEvery},
+
+		RaftSnapshotThreshold: *raftSnapN,
+		RaftSnapshotInterval:  config.Duration{Duration: *raftSnapIvl},
+		RaftTrailingLogs:      *raftTrailing,
+		RaftHeartbeatTimeout:  config.Duration{Duration: *raftHBWait},
+		RaftElectionTimeout:   config.Duration{Duration: *raftElection},
+		RaftLogMaxBytes:       *raftLogMax,
+	}, *configFile, func(prev, next config.Runtime) error {
+		return applyRuntimeConfig(prev, next, kvStore, purger.Load(), leaderNode.Load(), logLevelVar, limiter)
+	})
+	if lvl, err := config.ParseLogLevel(*logLevel); err == nil {
+		logLevelVar.Set(lvl)
+	} else {
+		log.Printf("%v, defaulting to info", err)
+	}
+	// Spans of gRPC calls. The process has no orderly exit to flush them on;
+	// the batch in flight when it is stopped is lost.
+	if *otlpEndpoint != "" {
+		if _, err := observability.SetupTracing(context.Background(), *otlpEndpoint, "distributed-cache-service", *nodeID); err != nil {
+			log.Fatalf("Failed to set up tracing: %v", err)
+		}
+	}
+	if *configFile != "" {
+		if err := runtimeCfg.Reload(); err != nil {
+			log.Fatalf("Failed to load config file: %v", err)
+		}
+	}
+	runtimeCfg.WatchSignals(func(err error) {
+		rec := audit.Record{Principal: "signal", Action: "SIGHUP reload " + *configFile, Result: "ok"}
 		if err != nil {
-			log.Fatalf("Could not determine local IP: %v", err)
+			rec.Result, rec.Error = "error", err.Error()
 		}
-		// Bind to the specific local IP to avoid unwanted traffic on 0.0.0.0 from LB health checks
-		bindAddr = fmt.Sprintf("%s:%s", addr, port)
-		if advertiseAddr == "" {
-			advertiseAddr = bindAddr
+		if err := auditLog.Record(rec); err != nil {
+			log.Printf("audit: %v", err)
 		}
+	})
+
+	// A standalone node has no Raft port; the others resolve where Raft binds
+	// and the address peers reach it at.
+	var bindAddr, advertiseAddr, grpcAdvertise string
+	raftListen := *raftAddr
+	if *standalone {
+		raftListen = ""
 	} else {
-		bindAddr = *raftAddr
-		if advertiseAddr == "" {
-			advertiseAddr = *raftAddr
+		var err error
+		if bindAddr, advertiseAddr, err = raftAddresses(*raftAddr, *raftAdv); err != nil {
+			log.Fatalf("Invalid raft_addr: %v", err)
 		}
+		if grpcAdvertise, err = grpcAdvertiseAddr(*grpcAddr, *grpcAdv, advertiseAddr); err != nil {
+			log.Fatalf("Invalid grpc_addr: %v", err)
+		}
+	}
+
+	httpLn, grpcLn, raftLn, err := listen(*httpAddr, *grpcAddr, raftListen, bindAddr)
+	if err != nil {
+		log.Fatalf("Failed to listen: %v", err)
 	}
 
 	// -------------------------------------------------------------------------
 	// 3. Raft Consensus Setup
 	// -------------------------------------------------------------------------
-	// Setup Raft
-	raftSys, err := consensus.SetupRaft(*raftDir, *nodeID, bindAddr, advertiseAddr, fsm)
-	if err != nil {
-		log.Fatalf("Failed to setup Raft: %v", err)
+	// cluster is Raft, or the local applier of a standalone node; raftNode is
+	// nil for the latter.
+	var (
+		cluster  clusterNode
+		raftNode *consensus.RaftNode
+	)
+	if *standalone {
+		cluster = consensus.NewStandalone(*nodeID, fsm)
+		log.Printf("Running standalone: writes apply directly to the store, without Raft")
+	} else {
+		tuning := raftTuning(runtimeCfg.Current())
+		tuning.DisablePreVote = !*raftPreVote
+		tuning.LeaderLeaseTimeout = *raftLease
+		tuning.SkipVerify = !*raftVerify
+		tuning.ReapAfter = *raftReap
+		tuning.ReapConfirm = *reapConfirm
+		tuning.ReapMinVoters = *reapMin
+		raftNode, err = consensus.SetupRaft(*raftDir, *nodeID, raftLn, advertiseAddr, fsm, tuning)
+		if err != nil {
+			log.Fatalf("Failed to setup Raft: %v", err)
+		}
+		raftNode.ApplyTimeout = *applyTimeout
+		raftNode.QuorumTimeout = *quorumWait
+		leaderNode.Store(raftNode)
+		cluster = raftNode
 	}
 
 	// Validate Consistency Mode
-	var consistencyMode service.ConsistencyMode
-	switch strings.ToLower(*consistency) {
-	case "strong":
-		consistencyMode = service.ConsistencyStrong
-	case "eventual":
-		consistencyMode = service.ConsistencyEventual
-	default:
+	consistencyMode, err := service.ParseConsistencyMode(*consistency)
+	if err != nil {
 		log.Printf("Unknown consistency mode '%s', defaulting to strong", *consistency)
 		consistencyMode = service.ConsistencyStrong
 	}
 
-	// Create consensus adapter and service
-	raftNode := &consensus.RaftNode{Raft: raftSys}
-	svc := service.New(kvStore, raftNode, consistencyMode)
+	grpcOpts, err := grpcAdapter.ServerConfig{
+		KeepaliveTime:         *grpcKATime,
+		KeepaliveTimeout:      *grpcKAWait,
+		KeepaliveMinTime:      *grpcKAMin,
+		PermitWithoutStream:   *grpcKANoCall,
+		MaxConnectionIdle:     *grpcMaxIdle,
+		MaxConnectionAge:      *grpcMaxAge,
+		MaxConnectionAgeGrace: *grpcAgeGrace,
+		MaxConcurrentStreams:  uint32(*grpcStreams),
+		MaxRecvMsgSize:        *grpcMaxRecv,
+		MaxSendMsgSize:        *grpcMaxSend,
+	}.ServerOptions()
+	if err != nil {
+		log.Fatalf("Invalid gRPC server settings: %v", err)
+	}
+	// gRPC clients opt into compression per call; importing grpcgzip and
+	// grpczstd lets the server decode gzip and zstd requests and answer in kind.
+	if err := grpcgzip.SetLevel(*gzipLevel); err != nil {
+		log.Fatalf("Invalid -gzip_level: %v", err)
+	}
+	// Responses carry the node's applied index and term for session tokens.
+	stamper := position.New(cluster)
+	// The API has a router of its own: packages such as net/http/pprof and
+	// expvar register debug handlers on http.DefaultServeMux when imported.
+	api := router.New("Distributed Cache HTTP API", "1",
+		router.WithAuth(authenticator.Middleware),
+		router.WithAudit(auditLog.Middleware),
+		router.WithRateLimit(limiter.Middleware),
+		router.WithMiddleware(httpAdapter.Middleware()...),
+	)
+	handler := stamper.Middleware(api)
+	if *httpGzipMin > 0 {
+		if handler, err = compression.GzipHandler(handler, *httpGzipMin, *gzipLevel); err != nil {
+			log.Fatalf("Invalid -gzip_level: %v", err)
+		}
+	}
 
-	// Bootstrap if requested
-	if *bootstrap {
-		cfg := raft.Configuration{
-			Servers: []raft.Server{
-				{
-					ID:      raft.ServerID(*nodeID),
-					Address: raft.ServerAddress(*raftAddr),
-				},
-			},
+	// Create service
+	svcOpts := []service.Option{
+		service.WithEncryption(valueCipher),
+		service.WithConfig(service.Config{
+			ReadTimeout:  *readTimeout,
+			ApplyTimeout: *applyTimeout,
+			Retry:        service.RetryPolicy{Attempts: *retries, Backoff: *retryBackoff},
+		}),
+	}
+	codec, err := compression.ParseCodec(*compressAlg)
+	if err != nil {
+		log.Fatalf("Invalid compression: %v", err)
+	}
+	if codec != compression.None {
+		svcOpts = append(svcOpts, service.WithCompression(compression.New(codec, *compressMin)))
+	}
+	if *metricPfx != "" {
+		var prefixes []string
+		for _, p := range strings.Split(*metricPfx, ",") {
+			if p = strings.TrimSpace(p); p != "" {
+				prefixes = append(prefixes, p)
+			}
+		}
+		if len(prefixes) > service.MaxMetricPrefixes {
+			log.Fatalf("Invalid metrics_prefixes: at most %d prefixes are allowed, got %d", service.MaxMetricPrefixes, len(prefixes))
+		}
+		svcOpts = append(svcOpts, service.WithMetricPrefixes(prefixes...))
+	}
+	if *hotKeyCap > 0 {
+		svcOpts = append(svcOpts, service.WithHotKeys(*hotKeyCap))
+	}
+	if *ttlJitter < 0 || *ttlJitter >= 100 {
+		log.Fatalf("Invalid ttl_jitter %v: must be at least 0 and below 100", *ttlJitter)
+	}
+	if *ttlJitter > 0 {
+		svcOpts = append(svcOpts, service.WithTTLJitter(*ttlJitter/100))
+	}
+	if *tombstoneRet < 0 {
+		log.Fatalf("Invalid tombstone_retention %v: must not be negative", *tombstoneRet)
+	}
+	if *tombstoneRet > 0 {
+		svcOpts = append(svcOpts, service.WithTombstoneRetention(*tombstoneRet))
+	}
+	if *defaultTTL != "" || *maxTTL != "" {
+		limits := service.TTLLimits{Reject: *maxTTLReject}
+		var err error
+		if limits.Default, err = service.ParseTTLs(*defaultTTL); err != nil {
+			log.Fatalf("Invalid default_ttl: %v", err)
+		}
+		if limits.Max, err = service.ParseTTLs(*maxTTL); err != nil {
+			log.Fatalf("Invalid max_ttl: %v", err)
+		}
+		svcOpts = append(svcOpts, service.WithTTLLimits(limits))
+	}
+	if len(quotas) > 0 {
+		svcOpts = append(svcOpts, service.WithQuotas(quotas))
+	}
+	if *maxLag > 0 {
+		svcOpts = append(svcOpts, service.WithMaxLag(*maxLag))
+	}
+	if *brkFailures > 0 {
+		svcOpts = append(svcOpts, service.WithBreaker(service.Breaker{Failures: *brkFailures, Cooldown: *brkCooldown}))
+	}
+	if *batchWindow > 0 {
+		svcOpts = append(svcOpts, service.WithBatching(service.Batching{Window: *batchWindow, MaxCommands: *batchMax, MaxBytes: *batchBytes}))
+	}
+	if *loaderURL != "" {
+		l, err := loader.NewHTTP(*loaderURL, *loaderTTL, *loaderWait)
+		if err != nil {
+			log.Fatalf("Invalid loader_url: %v", err)
+		}
+		svcOpts = append(svcOpts, service.WithLoader(l))
+		if *loaderEarly != "" {
+			betas, err := service.ParseEarlyRefresh(*loaderEarly)
+			if err != nil {
+				log.Fatalf("Invalid loader_early_beta: %v", err)
+			}
+			svcOpts = append(svcOpts, service.WithEarlyRefresh(betas))
+		}
+		if *loaderStale > 0 {
+			svcOpts = append(svcOpts, service.WithStaleWhileRevalidate(*loaderStale))
+		}
+	}
+	if *witness {
+		svcOpts = append(svcOpts, service.WithWitness())
+	}
+	svc := service.New(kvStore, cluster, consistencyMode, svcOpts...)
+	purger.Store(svc)
+	svc.StartPurge(runtimeCfg.Current().CleanupInterval.Duration)
+
+	// storedValue turns a value as kept in the store back into the one written.
+	storedValue := func(raw string) (string, error) {
+		_, stored := service.DecodeVersion(raw)
+		return valueCipher.Decode(stored)
+	}
+
+	if *standalone {
+		if *restoreFrom != "" {
+			if err := restoreCluster(cluster, *restoreFrom, auditLog); err != nil {
+				log.Fatalf("Failed to restore from backup: %v", err)
+			}
+		}
+		if *warmupFrom != "" {
+			warmUp(cluster, svc, *warmupFrom, storedValue)
+		}
+	} else {
+		// A node restarting with existing state is already a member; only new nodes join.
+		member, err := raftNode.LocalAddress()
+		if err != nil {
+			log.Fatalf("Failed to read cluster configuration: %v", err)
+		}
+		var peers discovery.Source
+		if *joinAddr != "" {
+			peers = discovery.Static(strings.Split(*joinAddr, ","))
 		}
-		f := raftSys.BootstrapCluster(cfg)
-		if err := f.Error(); err != nil {
-			log.Printf("Failed to bootstrap cluster: %v", err)
+		if *discoverDNS != "" {
+			if *joinAddr != "" {
+				log.Fatalf("-discovery and -join are mutually exclusive")
+			}
+			if *bootstrapN <= 0 {
+				log.Fatalf("-discovery requires -bootstrap_expect")
+			}
+			_, port, err := net.SplitHostPort(*httpAddr)
+			if err != nil {
+				log.Fatalf("Invalid http_addr: %v", err)
+			}
+			if peers, err = discovery.Parse(*discoverDNS, port); err != nil {
+				log.Fatalf("Invalid discovery: %v", err)
+			}
+		}
+		if *bootstrap && *bootstrapN > 0 {
+			log.Fatalf("-bootstrap and -bootstrap_expect are mutually exclusive")
+		}
+		var joinAddrs func() []string
+		if peers != nil {
+			joinAddrs = func() []string {
+				addrs, err := peers.Peers(context.Background())
+				if err != nil {
+					log.Printf("Peer discovery failed: %v", err)
+				}
+				return addrs
+			}
+		}
+		// bootstrapCluster starts a cluster with members as its voters, or with
+		// this node alone if there are none.
+		bootstrapCluster := func(members []discovery.Member) error {
+			if len(members) == 0 {
+				members = []discovery.Member{{ID: *nodeID, RaftAddr: advertiseAddr}}
+			}
+			var cfg raft.Configuration
+			for _, m := range members {
+				cfg.Servers = append(cfg.Servers, raft.Server{
+					ID:      raft.ServerID(m.ID),
+					Address: raft.ServerAddress(m.RaftAddr),
+				})
+			}
+			if err := raftNode.Bootstrap(cfg); err != nil {
+				log.Printf("Failed to bootstrap cluster: %v", err)
+			}
+			if *restoreFrom != "" {
+				if err := restoreCluster(raftNode, *restoreFrom, auditLog); err != nil {
+					return fmt.Errorf("restore from backup: %w", err)
+				}
+			}
+			if *warmupFrom != "" && member == "" {
+				warmUp(raftNode, svc, *warmupFrom, storedValue)
+			}
+			return nil
+		}
+		// Only the node that starts a cluster warms it up: loading keys into a
+		// member's store alone would leave it out of step with the others.
+		if *warmupFrom != "" && (member != "" || !*bootstrap && *bootstrapN == 0) {
+			log.Printf("Skipping warm-up: this node takes its data from the cluster")
 		}
-	} else if *joinAddr != "" {
-		// Try to join an existing cluster
-		if err := joinCluster(*nodeID, *raftAddr, *joinAddr); err != nil {
-			log.Fatalf("Failed to join cluster: %v", err)
+
+		// Bootstrap if requested
+		switch {
+		case *bootstrap:
+			if err := bootstrapCluster(nil); err != nil {
+				log.Fatalf("Failed to bootstrap cluster: %v", err)
+			}
+		case member != "":
+			// Already a member: nothing to join.
+		case *bootstrapN > 0:
+			if peers == nil {
+				log.Fatalf("-bootstrap_expect requires -join or -discovery")
+			}
+			// Peers identify and join each other through their HTTP servers, which
+			// start below.
+			go func() {
+				err := discovery.Form(context.Background(), peers, discovery.Formation{
+					Self:      *nodeID,
+					Expect:    *bootstrapN,
+					Join:      func(addrs []string) error { return joinCluster(*nodeID, advertiseAddr, grpcAdvertise, addrs) },
+					Identify:  identifyNode,
+					Bootstrap: bootstrapCluster,
+				})
+				if err != nil {
+					log.Fatalf("Failed to form cluster: %v", err)
+				}
+				log.Printf("Cluster formed")
+			}()
+		case peers != nil:
+			// Try to join an existing cluster
+			if err := joinCluster(*nodeID, advertiseAddr, grpcAdvertise, joinAddrs()); err != nil {
+				log.Fatalf("Failed to join cluster: %v", err)
+			}
 		}
+		if member != "" && member != advertiseAddr {
+			go readvertise(raftNode, *nodeID, member, advertiseAddr, grpcAdvertise, joinAddrs)
+		}
+		go advertiseGRPC(raftNode, fsm, svc, *nodeID, grpcAdvertise, joinAddrs)
 	}
 
 	// -------------------------------------------------------------------------
 	// 4. HTTP API & Server Start
 	// -------------------------------------------------------------------------
-	// HTTP handlers
-	http.HandleFunc("/set", func(w http.ResponseWriter, r *http.Request) {
-		key := r.URL.Query().Get("key")
-		val := r.URL.Query().Get("value")
-		if key == "" {
-			http.Error(w, "missing key", http.StatusBadRequest)
-			return
+	// HTTP routes. Lets discovering nodes tell a node that has yet to join
+	// from a member that is not the leader.
+	var isMember func() bool
+	if raftNode != nil {
+		isMember = func() bool {
+			addr, err := raftNode.LocalAddress()
+			return err != nil || addr != ""
 		}
+	}
+	var origins []string
+	for _, o := range strings.Split(*wsOrigins, ",") {
+		if o = strings.TrimSpace(o); o != "" {
+			origins = append(origins, o)
+		}
+	}
+	httpOpts := []httpAdapter.Option{
+		httpAdapter.WithNode(discovery.Member{ID: *nodeID, RaftAddr: advertiseAddr}, isMember),
+		httpAdapter.WithConfig(runtimeCfg),
+		httpAdapter.WithAdmin(cluster, kvStore),
+		httpAdapter.WithBackupDest(*backupDest),
+		httpAdapter.WithDecoder(storedValue),
+		httpAdapter.WithClusterVersion(svc),
+		httpAdapter.WithEvents(keyspaceEvents),
+		httpAdapter.WithOrigins(origins),
+		httpAdapter.WithReadiness(svc.CheckQuorum),
+		httpAdapter.WithFrameLimit(limiter.Allow),
+	}
+	if *hotKeyCap > 0 {
+		httpOpts = append(httpOpts, httpAdapter.WithHotKeys(svc.HotKeys))
+	}
+	// Cluster-wide stats are gathered from every member's admin gRPC API.
+	members := peers.New(cluster, *nodeID, *grpcAddr, peers.WithToken(*adminToken))
+	defer members.Close()
+	httpOpts = append(httpOpts, httpAdapter.WithClusterStats(func(ctx context.Context) ([]peers.Reply[ports.NodeStats], error) {
+		return members.Stats(ctx, svc)
+	}))
+	if len(quotas) > 0 {
+		httpOpts = append(httpOpts, httpAdapter.WithNamespaceStats(func(ctx context.Context) ([]peers.Reply[[]ports.NamespaceStats], error) {
+			return members.NamespaceStats(ctx, svc)
+		}))
+	}
+	httpAdapter.New(svc, httpOpts...).Register(api)
+	api.HandleDocs()
 
-		err := svc.Set(r.Context(), key, val, 0)
+	// Profiles, runtime and store statistics, on their own port (token protected)
+	if *debugAddr != "" {
+		var opts []diagnostics.Option
+		if memStore, ok := kvStore.(*store.Store); ok {
+			opts = append(opts, diagnostics.WithStore(func() interface{} { return memStore.Stats() }))
+		}
+		debugLn, err := net.Listen("tcp", *debugAddr)
 		if err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
+			log.Fatalf("Failed to listen on -debug_addr: %v", err)
+		}
+		go func() {
+			log.Printf("Debug server listening on %s", *debugAddr)
+			if err := http.Serve(debugLn, authenticator.Middleware(diagnostics.Handler(opts...))); err != nil {
+				log.Printf("Debug server failed: %v", err)
+			}
+		}()
+	}
+
+	// -------------------------------------------------------------------------
+	// 5. gRPC Server Start
+	// -------------------------------------------------------------------------
+	// Assuming I fix flag definition separately.
+	adminPrefix := "/" + pb.AdminService_ServiceDesc.ServiceName + "/"
+	go func() {
+		grpcServer := grpc.NewServer(append(grpcOpts, grpcAdapter.Interceptors{
+			Unary: []grpc.UnaryServerInterceptor{
+				auditLog.UnaryServerInterceptor(
+					pb.AdminService_Join_FullMethodName,
+					pb.AdminService_Remove_FullMethodName,
+					pb.AdminService_TransferLeadership_FullMethodName,
+					pb.AdminService_Snapshot_FullMethodName,
+					pb.AdminService_Compact_FullMethodName,
+					pb.AdminService_Backup_FullMethodName,
+					pb.AdminService_Restore_FullMethodName,
+				),
+				authenticator.UnaryServerInterceptor(adminPrefix),
+				limiter.UnaryServerInterceptor(),
+				stamper.UnaryServerInterceptor("/" + pb.CacheService_ServiceDesc.ServiceName + "/"),
+			},
+			Stream: []grpc.StreamServerInterceptor{
+				authenticator.StreamServerInterceptor(adminPrefix),
+				limiter.StreamServerInterceptor(),
+			},
+		}.ServerOptions()...)...)
+		electionWait := runtimeCfg.Current().RaftElectionTimeout.Duration
+		if electionWait <= 0 {
+			electionWait = time.Second
+		}
+		maxStreamValue := *grpcMaxValue
+		if maxStreamValue <= 0 {
+			maxStreamValue = *grpcMaxRecv
 		}
+		pb.RegisterCacheServiceServer(grpcServer, grpcAdapter.New(svc,
+			grpcAdapter.WithEvents(keyspaceEvents),
+			grpcAdapter.WithMaxStreamValueSize(maxStreamValue),
+			grpcAdapter.WithRetryHints(leaderGRPCAddr(cluster, *grpcAddr), electionWait),
+		))
+		pb.RegisterAdminServiceServer(grpcServer, grpcAdapter.NewAdmin(cluster, kvStore,
+			grpcAdapter.WithBackupDest(*backupDest),
+			grpcAdapter.WithNamespaceStats(svc),
+			grpcAdapter.WithNodeStats(svc),
+		))
+		// Enable server reflection so tools like grpcurl can discover services
+		reflection.Register(grpcServer)
+		log.Printf("gRPC server listening on %s", *grpcAddr)
+		if err := grpcServer.Serve(grpcLn); err != nil {
+			log.Fatalf("failed to serve: %v", err)
+		}
+	}()
 
-		if _, err := w.Write([]byte("ok")); err != nil {
-			log.Printf("Failed to write response: %v", err)
+	if *standalone {
+		log.Printf("Server listening on %s (standalone)...", *httpAddr)
+	} else {
+		log.Printf("Server listening on %s (Raft: %s)...", *httpAddr, *raftAddr)
+	}
+	log.Fatal(http.Serve(httpLn, handler))
+}
+
+// listen opens the HTTP, gRPC and Raft listeners. Services given the same
+// address share its port, and each connection is routed by its first bytes:
+// Raft RPCs open with a binary RPC type, gRPC with the HTTP/2 preface and the
+// HTTP API with an HTTP/1 request line. raftBind is where a Raft port of its
+// own binds; it still answers HTTP health checks with 200 OK. An empty
+// raftAddr opens no Raft listener.
+func listen(httpAddr, grpcAddr, raftAddr, raftBind string) (httpLn, grpcLn, raftLn net.Listener, err error) {
+	if raftAddr == httpAddr || raftAddr == grpcAddr {
+		raftBind = raftAddr
+	}
+	muxes := map[string]*mux.Mux{}
+	open := func(addr string) (*mux.Mux, error) {
+		if m, ok := muxes[addr]; ok {
+			return m, nil
 		}
-	})
+		l, err := net.Listen("tcp", addr)
+		if err != nil {
+			return nil, err
+		}
+		muxes[addr] = mux.New(l)
+		return muxes[addr], nil
+	}
 
-	http.HandleFunc("/get", func(w http.ResponseWriter, r *http.Request) {
-		key := r.URL.Query().Get("key")
-		if key == "" {
-			http.Error(w, "missing key", http.StatusBadRequest)
-			return
+	// Matchers are tried in the order they are registered.
+	var raftMux *mux.Mux
+	if raftAddr != "" {
+		if raftMux, err = open(raftBind); err != nil {
+			return nil, nil, nil, err
 		}
+		raftLn = raftMux.Match(consensus.MatchRaft())
+	}
+	grpcMux, err := open(grpcAddr)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	grpcLn = grpcMux.Match(mux.HTTP2())
+	httpMux, err := open(httpAddr)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	httpLn = httpMux.Match(mux.HTTP1())
+	if raftMux != nil && raftMux != httpMux {
+		// Load balancer health checks often probe every port of a task.
+		health := raftMux.Match(mux.HTTP1())
+		go http.Serve(health, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("ok"))
+		}))
+	}
+	for addr, m := range muxes {
+		go func() {
+			if err := m.Serve(); err != nil {
+				log.Printf("Listener on %s failed: %v", addr, err)
+			}
+		}()
+	}
+	return httpLn, grpcLn, raftLn, nil
+}
 
-		val, err := svc.Get(r.Context(), key)
+// applyRuntimeConfig pushes changed runtime settings into the running components.
+// Capacity and eviction policy only apply to the in-memory backend.
+// Raft tuning is recorded but not applied until Raft is set up.
+func applyRuntimeConfig(prev, next config.Runtime, kvStore ports.SnapshotStorage, svc *service.ServiceImpl, raftNode *consensus.RaftNode, level *slog.LevelVar, limiter *ratelimit.Limiter) error {
+	memStore, isMemory := kvStore.(*store.Store)
+	policyChanged := next.EvictionPolicy != prev.EvictionPolicy || next.LFUHalfLife != prev.LFUHalfLife
+	if (policyChanged || next.MaxItems != prev.MaxItems) && !isMemory {
+		return fmt.Errorf("max_items, eviction_policy and lfu_half_life are only supported by the memory storage backend")
+	}
+	if policyChanged {
+		p, err := policy.New(next.EvictionPolicy, policy.WithHalfLife(next.LFUHalfLife.Duration))
 		if err != nil {
-			http.Error(w, "not found", http.StatusNotFound)
-			return
+			return err
 		}
-		if _, err := w.Write([]byte(val)); err != nil {
-			log.Printf("Failed to write response: %v", err)
+		memStore.SetPolicy(p)
+	}
+	if next.MaxItems != prev.MaxItems {
+		memStore.SetCapacity(next.MaxItems)
+	}
+	if (policyChanged || next.MaxItems != prev.MaxItems) && svc != nil {
+		svc.EvictIfFull()
+	}
+	if next.LogLevel != prev.LogLevel {
+		lvl, err := config.ParseLogLevel(next.LogLevel)
+		if err != nil {
+			return err
 		}
-	})
+		level.Set(lvl)
+	}
+	if next.RateLimit != prev.RateLimit || next.RateBurst != prev.RateBurst {
+		limiter.SetLimit(next.RateLimit, next.RateBurst)
+	}
+	if next.CleanupInterval != prev.CleanupInterval && svc != nil {
+		svc.StartPurge(next.CleanupInterval.Duration)
+	}
+	if tuning := raftTuning(next); tuning != raftTuning(prev) && raftNode != nil {
+		if err := raftNode.Tune(tuning); err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
-	http.HandleFunc("/join", func(w http.ResponseWriter, r *http.Request) {
-		nodeID := r.URL.Query().Get("node_id")
-		remoteAddr := r.URL.Query().Get("addr")
+// raftTuning extracts the Raft settings from a runtime configuration.
+func raftTuning(r config.Runtime) consensus.RaftConfig {
+	return consensus.RaftConfig{
+		SnapshotThreshold: r.RaftSnapshotThreshold,
+		SnapshotInterval:  r.RaftSnapshotInterval.Duration,
+		TrailingLogs:      r.RaftTrailingLogs,
+		HeartbeatTimeout:  r.RaftHeartbeatTimeout.Duration,
+		ElectionTimeout:   r.RaftElectionTimeout.Duration,
+		LogMaxBytes:       r.RaftLogMaxBytes,
+	}
+}
 
-		if nodeID == "" || remoteAddr == "" {
-			http.Error(w, "missing node_id or addr", http.StatusBadRequest)
-			return
-		}
+// clusterNode is the consensus layer the server runs on: a Raft node, or a
+// consensus.Standalone.
+type clusterNode interface {
+	ports.Consensus
+	ports.ClusterAdmin
+	ports.PositionReporter
+	WaitForLeader(timeout time.Duration) error
+}
 
-		if err := svc.Join(r.Context(), nodeID, remoteAddr); err != nil {
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-			return
+// restoreCluster waits for this node to lead the freshly bootstrapped cluster and
+// then forces Raft to adopt the backup at uri as its state, recording the
+// outcome in auditLog.
+func restoreCluster(node clusterNode, uri string, auditLog *audit.Log) (err error) {
+	defer func() {
+		rec := audit.Record{Principal: "startup", Action: "restore_from", Params: uri, Result: "ok"}
+		if err != nil {
+			rec.Result, rec.Error = "error", err.Error()
 		}
-		if _, err := w.Write([]byte("joined")); err != nil {
-			log.Printf("Failed to write response: %v", err)
+		if err := auditLog.Record(rec); err != nil {
+			log.Printf("audit: %v", err)
 		}
-	})
+	}()
+	loc, err := backup.ParseLocation(uri)
+	if err != nil {
+		return err
+	}
+	if err := node.WaitForLeader(30 * time.Second); err != nil {
+		return err
+	}
+	rc, err := loc.Open(context.Background())
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+	if err := node.RestoreFrom(rc); err != nil {
+		return err
+	}
+	log.Printf("Cluster restored from %s", loc)
+	return nil
+}
 
-	// Health Check
-	http.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusOK)
-		if _, err := w.Write([]byte("ok")); err != nil {
-			log.Printf("Failed to write response: %v", err)
-		}
-	})
+// warmUp waits for this node to lead the cluster it just started and loads the
+// dataset at uri into it, through the same replicated writes as clients: a
+// dump from /admin/export or a backup, whose stored values decode turns back
+// into the values written. Keys keep the TTLs they had left. A failed warm-up
+// keeps the keys loaded so far and the node serves on, only colder.
+func warmUp(node clusterNode, svc ports.CacheService, uri string, decode func(string) (string, error)) {
+	if err := node.WaitForLeader(30 * time.Second); err != nil {
+		log.Printf("Skipping warm-up from %s: %v", uri, err)
+		return
+	}
+	start := time.Now()
+	keys, sets, err := loadDataset(svc, uri, decode)
+	if err != nil {
+		log.Printf("Warm-up from %s stopped after %d keys and %d sorted sets: %v", uri, keys, sets, err)
+		return
+	}
+	log.Printf("Warmed up %d keys and %d sorted sets from %s in %s", keys, sets, uri, time.Since(start).Round(time.Millisecond))
+}
 
-	// Prometheus Metrics
-	http.Handle("/metrics", promhttp.Handler())
+// loadDataset writes the keys of the dataset at uri to svc, strings in bulk
+// and sorted sets one at a time, and counts those written.
+func loadDataset(svc ports.CacheService, uri string, decode func(string) (string, error)) (keys, sets int, err error) {
+	loc, err := backup.ParseLocation(uri)
+	if err != nil {
+		return 0, 0, err
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	rc, err := loc.Open(ctx)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer rc.Close()
 
-	// -------------------------------------------------------------------------
-	// 5. gRPC Server Start
-	// -------------------------------------------------------------------------
-	// Assuming I fix flag definition separately.
+	// The dataset is read on its own goroutine, which BulkLoad pulls from.
+	entries := make(chan ports.BulkEntry, service.BulkLoadBatchKeys)
+	readErr := make(chan error, 1)
 	go func() {
-		lis, err := net.Listen("tcp", *grpcAddr)
+		defer close(entries)
+		readErr <- migrate.ReadDataset(rc, decode, func(rec migrate.Record) error {
+			var ttl time.Duration
+			if !rec.ExpiresAt.IsZero() {
+				if ttl = time.Until(rec.ExpiresAt); ttl <= 0 {
+					return nil
+				}
+			}
+			if rec.Members != nil {
+				if _, err := svc.ZAdd(ctx, rec.Key, rec.Members...); err != nil {
+					return fmt.Errorf("zadd %q: %w", rec.Key, err)
+				}
+				sets++
+				return nil
+			}
+			select {
+			case entries <- ports.BulkEntry{Key: rec.Key, Value: rec.Value, TTL: ttl}:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+	}()
+	result, err := svc.BulkLoad(ctx, func() (ports.BulkEntry, error) {
+		e, ok := <-entries
+		if !ok {
+			return e, io.EOF
+		}
+		return e, nil
+	})
+	cancel()
+	if rerr := <-readErr; err == nil {
+		err = rerr
+	}
+	return result.Loaded, sets, err
+}
+
+// joinCluster sends a request to existing nodes to add this node to the cluster,
+// or to update its addresses if it is already a member. It hits the /join
+// endpoint of each of joinAddrs in turn until one, the leader, accepts.
+// If every node answers that it is not a member itself, it returns
+// discovery.ErrNoCluster.
+func joinCluster(nodeID, raftAddr, grpcAddr string, joinAddrs []string) error {
+	client := http.Client{Timeout: 5 * time.Second}
+	query := url.Values{"node_id": {nodeID}, "addr": {raftAddr}, "grpc_addr": {grpcAddr}}.Encode()
+	if len(joinAddrs) == 0 {
+		return fmt.Errorf("no nodes to join through")
+	}
+	var lastErr error
+	noCluster := true
+	for _, joinAddr := range joinAddrs {
+		resp, err := client.Get(fmt.Sprintf("http://%s/join?%s", strings.TrimSpace(joinAddr), query))
 		if err != nil {
-			log.Fatalf("failed to listen: %v", err)
+			lastErr = err
+			noCluster = false
+			continue
 		}
-		grpcServer := grpc.NewServer()
-		pb.RegisterCacheServiceServer(grpcServer, grpcAdapter.New(svc))
-		log.Printf("gRPC server listening on %s", *grpcAddr)
-		if err := grpcServer.Serve(lis); err != nil {
-			log.Fatalf("failed to serve: %v", err)
+		resp.Body.Close()
+		if resp.StatusCode == http.StatusOK {
+			return nil
 		}
-	}()
+		lastErr = fmt.Errorf("failed to join via %s: %s", joinAddr, resp.Status)
+		noCluster = noCluster && resp.StatusCode == http.StatusConflict
+	}
+	if noCluster {
+		return discovery.ErrNoCluster
+	}
+	return lastErr
+}
+
+// leaderGRPCAddr returns a function that returns the gRPC address of node's
+// leader: the one it advertised, or else the host of its Raft address and the
+// port of grpcAddr, as for a leader of a cluster that cannot record it yet. It
+// returns "" while there is no leader.
+func leaderGRPCAddr(node clusterNode, grpcAddr string) func() string {
+	_, port, _ := net.SplitHostPort(grpcAddr)
+	return func() string {
+		leader := node.Leader()
+		if leader == "" {
+			return ""
+		}
+		if members, err := node.Members(); err == nil {
+			for _, m := range members {
+				if m.Leader && m.GRPCAddr != "" {
+					return m.GRPCAddr
+				}
+			}
+		}
+		host, _, err := net.SplitHostPort(leader)
+		if err != nil || host == "" || port == "" {
+			return ""
+		}
+		return net.JoinHostPort(host, port)
+	}
+}
+
+// grpcAdvertiseAddr returns the gRPC address this node advertises: advertise
+// if set, or else grpcAddr, on the host of raftAdvertise if grpcAddr listens
+// on every interface.
+func grpcAdvertiseAddr(grpcAddr, advertise, raftAdvertise string) (string, error) {
+	if advertise != "" {
+		return advertise, nil
+	}
+	host, port, err := net.SplitHostPort(grpcAddr)
+	if err != nil {
+		return "", err
+	}
+	if ip := net.ParseIP(host); host == "" || ip != nil && ip.IsUnspecified() {
+		if host, _, err = net.SplitHostPort(raftAdvertise); err != nil {
+			return "", err
+		}
+	}
+	return net.JoinHostPort(host, port), nil
+}
 
-	log.Printf("Server listening on %s (Raft: %s)...", *httpAddr, *raftAddr)
-	log.Fatal(http.ListenAndServe(*httpAddr, nil))
+// advertiseGRPC records grpcAddr as this node's gRPC address in the cluster
+// (see ports.Member), for members and clients to reach it at. The leader
+// records its own; other nodes ask it through joinAddrs, as they do to join,
+// or wait until they lead. It waits for the cluster to reach
+// service.CommandVersionMembers, and returns once the address is recorded.
+func advertiseGRPC(node *consensus.RaftNode, fsm *consensus.FSM, svc *service.ServiceImpl, nodeID, grpcAddr string, joinAddrs func() []string) {
+	backoff := time.Second
+	for {
+		time.Sleep(backoff)
+		backoff = min(2*backoff, 30*time.Second)
+		if addr, _ := fsm.MemberAddr(nodeID); addr == grpcAddr {
+			return
+		}
+		member, err := node.LocalAddress()
+		if err != nil || member == "" || svc.ClusterVersion() < service.CommandVersionMembers {
+			continue
+		}
+		switch {
+		case node.IsLeader():
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			err = svc.AdvertiseMember(ctx, nodeID, grpcAddr)
+			cancel()
+		case joinAddrs != nil:
+			err = joinCluster(nodeID, member, grpcAddr, joinAddrs())
+		default:
+			continue
+		}
+		if err != nil {
+			log.Printf("Failed to advertise gRPC address %s: %v", grpcAddr, err)
+		} else {
+			log.Printf("Advertised gRPC address %s", grpcAddr)
+		}
+	}
 }
 
-// joinCluster sends a request to an existing node to add this node to the cluster.
-// It hits the /join endpoint of the target leader.
-func joinCluster(nodeID, raftAddr, joinAddr string) error {
-	url := fmt.Sprintf("http://%s/join?node_id=%s&addr=%s", joinAddr, nodeID, raftAddr)
+// identifyNode asks the node serving HTTP at addr for its ID and Raft address.
+func identifyNode(addr string) (discovery.Member, error) {
 	client := http.Client{Timeout: 5 * time.Second}
-	resp, err := client.Get(url)
+	resp, err := client.Get(fmt.Sprintf("http://%s/node", strings.TrimSpace(addr)))
 	if err != nil {
-		return err
+		return discovery.Member{}, err
 	}
 	defer resp.Body.Close()
-
 	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("failed to join: %s", resp.Status)
+		return discovery.Member{}, fmt.Errorf("identify %s: %s", addr, resp.Status)
 	}
-	return nil
+	var m discovery.Member
+	if err := json.NewDecoder(resp.Body).Decode(&m); err != nil {
+		return discovery.Member{}, fmt.Errorf("identify %s: %w", addr, err)
+	}
+	return m, nil
+}
+
+// readvertise updates this node's address in the cluster configuration after
+// it restarted with a new one, as pods rescheduled on Kubernetes do. Peers
+// cannot reach the node at its old address, so it asks the leader, through
+// joinAddrs, to re-add it; if this node leads, it updates the address itself.
+// It retries until the new address is in the configuration; joinAddrs is
+// called on each attempt, so that discovered peers are current.
+func readvertise(node *consensus.RaftNode, nodeID, oldAddr, newAddr, grpcAddr string, joinAddrs func() []string) {
+	log.Printf("Raft address changed from %s to %s, re-advertising", oldAddr, newAddr)
+	backoff := time.Second
+	for {
+		addr, err := node.LocalAddress()
+		switch {
+		case err != nil:
+			log.Printf("Failed to read cluster configuration: %v", err)
+		case addr == newAddr:
+			log.Printf("Raft address updated to %s", newAddr)
+			return
+		case addr == "":
+			log.Printf("Node was removed from the cluster, not re-advertising")
+			return
+		case node.IsLeader():
+			err = node.AddVoter(nodeID, newAddr)
+		case joinAddrs != nil:
+			err = joinCluster(nodeID, newAddr, grpcAddr, joinAddrs())
+		default:
+			log.Printf("Raft address changed but neither -join nor -discovery is set: peers keep dialing %s", oldAddr)
+			return
+		}
+		if err != nil {
+			log.Printf("Failed to re-advertise Raft address: %v", err)
+		}
+		time.Sleep(backoff)
+		backoff = min(2*backoff, 30*time.Second)
+	}
+}
+
+// raftAddresses returns the address Raft binds to and the one it advertises
+// to peers. A raft_addr on every interface binds to the first private IP, so
+// that load balancer health checks sent to 0.0.0.0 do not reach Raft.
+func raftAddresses(raftAddr, advertise string) (bind, adv string, err error) {
+	host, port, err := net.SplitHostPort(raftAddr)
+	if err != nil {
+		return "", "", err
+	}
+	bind = raftAddr
+	if host == "" || host == "0.0.0.0" {
+		ip, err := getLocalIP()
+		if err != nil {
+			return "", "", fmt.Errorf("could not determine local IP: %w", err)
+		}
+		bind = net.JoinHostPort(ip, port)
+	}
+	if advertise == "" {
+		advertise = bind
+	}
+	return bind, advertise, nil
 }
 
 // getLocalIP returns the first non-loopback private IP address of the machine.
@@ -0,0 +1,379 @@
+// Package audit records administrative and membership operations, with the
+// principal that performed them, in an append-only log file.
+//
+// Each record carries the SHA-256 hash of the one before it, so editing,
+// removing or reordering records breaks the chain, which Verify detects. A
+// chain rewritten from the edit onwards still verifies, so records can also be
+// sent to a webhook as they are written, to keep a copy off the node.
+package audit
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+)
+
+// maxDetail bounds the request body or message kept in a record.
+const maxDetail = 4 << 10
+
+// webhookQueue bounds the records waiting for webhook delivery; beyond it,
+// records are only written to the file.
+const webhookQueue = 1000
+
+// Record is one audited operation.
+type Record struct {
+	Seq  uint64    `json:"seq"`
+	Time time.Time `json:"time"`
+	// Principal is who performed the operation: "admin" for a valid admin
+	// token, "anonymous" when admin endpoints are unauthenticated,
+	// "unauthenticated" for a missing or invalid token, or the process
+	// itself ("signal", "startup").
+	Principal string `json:"principal"`
+	Remote    string `json:"remote,omitempty"`
+	// Action is the HTTP method and path, or the gRPC method.
+	Action string `json:"action"`
+	// Params holds the query string or the gRPC request, Body a request body.
+	Params string `json:"params,omitempty"`
+	Body   string `json:"body,omitempty"`
+	// Result is "ok", "denied" or "error", with the reason in Error.
+	Result string `json:"result"`
+	Error  string `json:"error,omitempty"`
+	// Prev is the Hash of the previous record, empty for the first.
+	Prev string `json:"prev"`
+	Hash string `json:"hash"`
+}
+
+// hash returns the hex SHA-256 of r's JSON encoding without its Hash.
+func (r Record) hash() string {
+	r.Hash = ""
+	data, _ := json.Marshal(r)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// Log appends records to a file. A nil *Log records nothing, so callers can
+// wrap handlers whether or not auditing is enabled.
+type Log struct {
+	principal func(authorization string) string
+
+	mu   sync.Mutex
+	f    *os.File
+	seq  uint64
+	last string
+
+	webhook string
+	client  *http.Client
+	queue   chan []byte
+	done    chan struct{}
+}
+
+// Option configures a Log.
+type Option func(*Log)
+
+// WithPrincipal names the principal of a request from its Authorization
+// header, returning "" if it does not authenticate. By default every request
+// is "anonymous".
+func WithPrincipal(fn func(authorization string) string) Option {
+	return func(l *Log) {
+		l.principal = fn
+	}
+}
+
+// WithWebhook also POSTs each record, as JSON, to url. Delivery is best
+// effort and in the background; records are dropped from delivery, though not
+// from the file, if the webhook falls behind.
+func WithWebhook(url string) Option {
+	return func(l *Log) {
+		l.webhook = url
+	}
+}
+
+// Open opens the audit log at path, creating it if needed, and continues its
+// chain. It fails if the existing records do not verify, so that tampering
+// is noticed rather than buried under new records. A last record cut short
+// by a crash is dropped.
+func Open(path string, opts ...Option) (*Log, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, err
+	}
+	l := &Log{
+		principal: func(string) string { return "anonymous" },
+		f:         f,
+		client:    &http.Client{Timeout: 10 * time.Second},
+	}
+	for _, opt := range opts {
+		opt(l)
+	}
+	if err := l.recover(); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("audit log %s: %w", path, err)
+	}
+	if l.webhook != "" {
+		l.queue = make(chan []byte, webhookQueue)
+		l.done = make(chan struct{})
+		go l.deliver()
+	}
+	return l, nil
+}
+
+// recover verifies the file, truncates a torn last line and continues the
+// chain from the last record.
+func (l *Log) recover() error {
+	data, err := io.ReadAll(l.f)
+	if err != nil {
+		return err
+	}
+	complete := bytes.LastIndexByte(data, '\n') + 1
+	last, err := verify(bytes.NewReader(data[:complete]))
+	if err != nil {
+		return err
+	}
+	if complete < len(data) {
+		log.Printf("audit: dropping an incomplete last record")
+		if err := l.f.Truncate(int64(complete)); err != nil {
+			return err
+		}
+	}
+	l.seq, l.last = last.Seq, last.Hash
+	return nil
+}
+
+// Record appends r, filling in its sequence number, time and hashes. The
+// file is synced before Record returns.
+func (l *Log) Record(r Record) error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.f == nil {
+		return errors.New("audit log closed")
+	}
+
+	r.Seq = l.seq + 1
+	if r.Time.IsZero() {
+		r.Time = time.Now()
+	}
+	// UTC, so that the time reads back exactly as it was hashed.
+	r.Time = r.Time.UTC()
+	r.Prev = l.last
+	r.Hash = r.hash()
+	line, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+	if _, err := l.f.Write(line); err != nil {
+		return err
+	}
+	if err := l.f.Sync(); err != nil {
+		return err
+	}
+	l.seq, l.last = r.Seq, r.Hash
+
+	if l.queue != nil {
+		select {
+		case l.queue <- line:
+		default:
+			log.Printf("audit: webhook queue full, record %d not delivered", r.Seq)
+		}
+	}
+	return nil
+}
+
+// record writes r, logging rather than failing the audited operation if the
+// log cannot be written.
+func (l *Log) record(r Record) {
+	if err := l.Record(r); err != nil {
+		log.Printf("audit: failed to record %s by %s: %v", r.Action, r.Principal, err)
+	}
+}
+
+func (l *Log) deliver() {
+	defer close(l.done)
+	for line := range l.queue {
+		resp, err := l.client.Post(l.webhook, "application/json", bytes.NewReader(line))
+		if err != nil {
+			log.Printf("audit: webhook delivery failed: %v", err)
+			continue
+		}
+		io.Copy(io.Discard, resp.Body)
+		resp.Body.Close()
+		if resp.StatusCode/100 != 2 {
+			log.Printf("audit: webhook delivery failed: %s", resp.Status)
+		}
+	}
+}
+
+// Close flushes pending webhook deliveries and closes the file.
+func (l *Log) Close() error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	f := l.f
+	l.f = nil
+	if l.queue != nil && f != nil {
+		close(l.queue)
+	}
+	l.mu.Unlock()
+	if f == nil {
+		return nil
+	}
+	if l.done != nil {
+		<-l.done
+	}
+	return f.Close()
+}
+
+func (l *Log) principalOf(authorization string) string {
+	if p := l.principal(authorization); p != "" {
+		return p
+	}
+	return "unauthenticated"
+}
+
+// Middleware records every HTTP request it passes to next, once next has
+// answered. Install it outside authentication so that rejected attempts are
+// recorded too.
+func (l *Log) Middleware(next http.Handler) http.Handler {
+	if l == nil {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body []byte
+		if r.Body != nil {
+			body, _ = io.ReadAll(io.LimitReader(r.Body, maxDetail))
+			r.Body = struct {
+				io.Reader
+				io.Closer
+			}{io.MultiReader(bytes.NewReader(body), r.Body), r.Body}
+		}
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(sw, r)
+
+		rec := Record{
+			Principal: l.principalOf(r.Header.Get("Authorization")),
+			Remote:    r.RemoteAddr,
+			Action:    r.Method + " " + r.URL.Path,
+			Params:    r.URL.RawQuery,
+			Body:      strings.TrimSpace(string(body)),
+			Result:    "ok",
+		}
+		switch {
+		case sw.status == http.StatusUnauthorized || sw.status == http.StatusForbidden:
+			rec.Result, rec.Error = "denied", http.StatusText(sw.status)
+		case sw.status >= 400:
+			rec.Result, rec.Error = "error", http.StatusText(sw.status)
+		}
+		l.record(rec)
+	})
+}
+
+// statusWriter remembers the status code of a response.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(code int) {
+	w.status = code
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// UnaryServerInterceptor records calls to the given full gRPC method names
+// (e.g. "/cache.AdminService/Join"). Install it before authentication so that
+// rejected calls are recorded too.
+func (l *Log) UnaryServerInterceptor(methods ...string) grpc.UnaryServerInterceptor {
+	audited := make(map[string]bool, len(methods))
+	for _, m := range methods {
+		audited[m] = true
+	}
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if l == nil || !audited[info.FullMethod] {
+			return handler(ctx, req)
+		}
+		resp, err := handler(ctx, req)
+
+		rec := Record{Action: info.FullMethod, Result: "ok"}
+		var authorization string
+		if md, ok := metadata.FromIncomingContext(ctx); ok {
+			if v := md.Get("authorization"); len(v) > 0 {
+				authorization = v[0]
+			}
+		}
+		rec.Principal = l.principalOf(authorization)
+		if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+			rec.Remote = p.Addr.String()
+		}
+		if m, ok := req.(proto.Message); ok {
+			if params, err := protojson.Marshal(m); err == nil && len(params) <= maxDetail {
+				rec.Params = string(params)
+			}
+		}
+		if err != nil {
+			st := status.Convert(err)
+			rec.Result, rec.Error = "error", st.Message()
+			if st.Code() == codes.Unauthenticated || st.Code() == codes.PermissionDenied {
+				rec.Result = "denied"
+			}
+		}
+		l.record(rec)
+		return resp, err
+	}
+}
+
+// Verify reads an audit log and checks that its records are numbered in
+// order and chained by their hashes. It returns how many records verified,
+// and an error naming the first that does not.
+func Verify(r io.Reader) (int, error) {
+	last, err := verify(r)
+	return int(last.Seq), err
+}
+
+func verify(r io.Reader) (Record, error) {
+	var last Record
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64<<10), 1<<20)
+	for line := 1; sc.Scan(); line++ {
+		var rec Record
+		if err := json.Unmarshal(sc.Bytes(), &rec); err != nil {
+			return last, fmt.Errorf("line %d: %w", line, err)
+		}
+		switch {
+		case rec.Seq != last.Seq+1:
+			return last, fmt.Errorf("line %d: record %d follows record %d", line, rec.Seq, last.Seq)
+		case rec.Prev != last.Hash:
+			return last, fmt.Errorf("line %d: record %d does not chain to the record before it", line, rec.Seq)
+		case rec.Hash != rec.hash():
+			return last, fmt.Errorf("line %d: record %d has been modified", line, rec.Seq)
+		}
+		last = rec
+	}
+	return last, sc.Err()
+}
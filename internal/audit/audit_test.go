@@ -0,0 +1,172 @@
+package audit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	pb "distributed-cache-service/proto"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func principal(header string) string {
+	if header == "Bearer secret" {
+		return "admin"
+	}
+	return ""
+}
+
+func readRecords(t *testing.T, path string) []Record {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	var records []Record
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		var r Record
+		require.NoError(t, json.Unmarshal([]byte(line), &r))
+		records = append(records, r)
+	}
+	return records
+}
+
+func TestLog_Middleware(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	l, err := Open(path, WithPrincipal(principal))
+	require.NoError(t, err)
+	defer l.Close()
+
+	var seen string
+	h := l.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		seen = string(body)
+		if r.Header.Get("Authorization") == "" {
+			http.Error(w, "missing token", http.StatusUnauthorized)
+		}
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "/admin/config?x=1", strings.NewReader(`{"max_items": 10}`))
+	req.Header.Set("Authorization", "Bearer secret")
+	h.ServeHTTP(httptest.NewRecorder(), req)
+	assert.Equal(t, `{"max_items": 10}`, seen, "the handler still reads the whole body")
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/join?node_id=n2", nil))
+
+	records := readRecords(t, path)
+	require.Len(t, records, 2)
+	assert.Equal(t, "admin", records[0].Principal)
+	assert.Equal(t, "POST /admin/config", records[0].Action)
+	assert.Equal(t, "x=1", records[0].Params)
+	assert.Equal(t, `{"max_items": 10}`, records[0].Body)
+	assert.Equal(t, "ok", records[0].Result)
+	assert.Equal(t, "unauthenticated", records[1].Principal)
+	assert.Equal(t, "denied", records[1].Result)
+	assert.Equal(t, records[0].Hash, records[1].Prev)
+}
+
+func TestLog_UnaryServerInterceptor(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	l, err := Open(path, WithPrincipal(principal))
+	require.NoError(t, err)
+	defer l.Close()
+
+	intercept := l.UnaryServerInterceptor(pb.AdminService_Join_FullMethodName)
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer secret"))
+	failing := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return nil, status.Error(codes.Unavailable, "not the leader")
+	}
+	_, err = intercept(ctx, &pb.JoinRequest{NodeId: "n2", Addr: "10.0.0.2:7000"},
+		&grpc.UnaryServerInfo{FullMethod: pb.AdminService_Join_FullMethodName}, failing)
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+	// Methods not listed are not recorded.
+	_, err = intercept(ctx, &pb.StatsRequest{}, &grpc.UnaryServerInfo{FullMethod: pb.AdminService_Stats_FullMethodName},
+		func(ctx context.Context, req interface{}) (interface{}, error) { return nil, nil })
+	assert.NoError(t, err)
+
+	records := readRecords(t, path)
+	require.Len(t, records, 1)
+	assert.Equal(t, "admin", records[0].Principal)
+	assert.Equal(t, pb.AdminService_Join_FullMethodName, records[0].Action)
+	assert.JSONEq(t, `{"nodeId":"n2","addr":"10.0.0.2:7000"}`, records[0].Params)
+	assert.Equal(t, "error", records[0].Result)
+	assert.Equal(t, "not the leader", records[0].Error)
+}
+
+func TestLog_TamperEvident(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	l, err := Open(path)
+	require.NoError(t, err)
+	for _, action := range []string{"join", "remove", "backup"} {
+		require.NoError(t, l.Record(Record{Principal: "admin", Action: action, Result: "ok"}))
+	}
+	require.NoError(t, l.Close())
+
+	// Reopening continues the chain, dropping a record torn by a crash.
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0)
+	require.NoError(t, err)
+	_, err = f.WriteString(`{"seq":4,"time":`)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	l, err = Open(path)
+	require.NoError(t, err)
+	require.NoError(t, l.Record(Record{Principal: "signal", Action: "config reload", Result: "ok"}))
+	require.NoError(t, l.Close())
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	n, err := Verify(bytes.NewReader(data))
+	require.NoError(t, err)
+	assert.Equal(t, 4, n)
+
+	// Edit the second record, or remove it.
+	lines := bytes.SplitAfter(data, []byte("\n"))
+	tampered := [][]byte{
+		bytes.Replace(data, []byte(`"principal":"admin","action":"remove"`), []byte(`"principal":"nobody","action":"remove"`), 1),
+		bytes.Join(append(lines[:1:1], lines[2:]...), nil),
+	}
+	for _, bad := range tampered {
+		_, err := Verify(bytes.NewReader(bad))
+		assert.ErrorContains(t, err, "line 2")
+		require.NoError(t, os.WriteFile(path, bad, 0600))
+		_, err = Open(path)
+		assert.Error(t, err, "a log that does not verify must not be extended")
+	}
+}
+
+func TestLog_Webhook(t *testing.T) {
+	received := make(chan Record, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var rec Record
+		assert.NoError(t, json.NewDecoder(r.Body).Decode(&rec))
+		received <- rec
+	}))
+	defer srv.Close()
+
+	l, err := Open(filepath.Join(t.TempDir(), "audit.log"), WithWebhook(srv.URL))
+	require.NoError(t, err)
+	require.NoError(t, l.Record(Record{Principal: "admin", Action: "backup", Result: "ok"}))
+	require.NoError(t, l.Close())
+
+	rec := <-received
+	assert.Equal(t, uint64(1), rec.Seq)
+	assert.Equal(t, rec.hash(), rec.Hash)
+}
+
+func TestLog_Nil(t *testing.T) {
+	var l *Log
+	next := http.NotFoundHandler()
+	assert.NoError(t, l.Record(Record{}))
+	assert.NotNil(t, l.Middleware(next))
+	assert.NoError(t, l.Close())
+}
@@ -0,0 +1,84 @@
+package auth
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestAuthenticator_Check(t *testing.T) {
+	a := New("secret")
+	assert.NoError(t, a.Check("Bearer secret"))
+	assert.ErrorIs(t, a.Check("Bearer wrong"), ErrUnauthenticated)
+	assert.ErrorIs(t, a.Check("secret"), ErrUnauthenticated)
+	assert.ErrorIs(t, a.Check(""), ErrUnauthenticated)
+
+	assert.NoError(t, New("").Check(""), "disabled authenticator allows everything")
+}
+
+func TestAuthenticator_Principal(t *testing.T) {
+	a := New("secret")
+	assert.Equal(t, "admin", a.Principal("Bearer secret"))
+	assert.Equal(t, "", a.Principal("Bearer wrong"))
+	assert.Equal(t, "anonymous", New("").Principal(""))
+}
+
+func TestAuthenticator_Middleware(t *testing.T) {
+	h := New("secret").Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/admin/config", nil))
+	assert.Equal(t, http.StatusUnauthorized, rec.Code)
+
+	req := httptest.NewRequest(http.MethodGet, "/admin/config", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusOK, rec.Code)
+}
+
+func TestAuthenticator_UnaryServerInterceptor(t *testing.T) {
+	interceptor := New("secret").UnaryServerInterceptor("/cache.AdminService/")
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) { return "ok", nil }
+
+	// Unprotected method passes through
+	_, err := interceptor(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: "/cache.CacheService/Get"}, handler)
+	assert.NoError(t, err)
+
+	// Protected method without token is rejected
+	_, err = interceptor(context.Background(), nil, &grpc.UnaryServerInfo{FullMethod: "/cache.AdminService/Stats"}, handler)
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	// Protected method with token succeeds
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer secret"))
+	_, err = interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/cache.AdminService/Stats"}, handler)
+	assert.NoError(t, err)
+}
+
+// stream is a grpc.ServerStream carrying ctx.
+type stream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s stream) Context() context.Context { return s.ctx }
+
+func TestAuthenticator_StreamServerInterceptor(t *testing.T) {
+	interceptor := New("secret").StreamServerInterceptor("/cache.AdminService/")
+	handler := func(srv interface{}, ss grpc.ServerStream) error { return nil }
+	info := &grpc.StreamServerInfo{FullMethod: "/cache.AdminService/Tail"}
+
+	err := interceptor(nil, stream{ctx: context.Background()}, &grpc.StreamServerInfo{FullMethod: "/cache.CacheService/Watch"}, handler)
+	assert.NoError(t, err)
+	err = interceptor(nil, stream{ctx: context.Background()}, info, handler)
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("authorization", "Bearer secret"))
+	assert.NoError(t, interceptor(nil, stream{ctx: ctx}, info, handler))
+}
@@ -0,0 +1,81 @@
+// Package backup streams consistent snapshots of the store to external storage
+// (a local file or an S3-compatible object store) and reads them back for restore.
+//
+// Raft's local snapshots live on the same disk as the node, so they do not help
+// when every node in the cluster loses its data directory. Backups written here
+// can be used to re-seed a fresh cluster via the -restore_from flag.
+package backup
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+)
+
+// Snapshotter produces a consistent, serialized view of the state machine.
+type Snapshotter interface {
+	Snapshot(w io.Writer) error
+}
+
+// Location is a destination for backup artifacts.
+type Location interface {
+	// Write stores the artifact read from r, replacing any existing artifact.
+	Write(ctx context.Context, r io.Reader) error
+	// Open returns a reader for a previously written artifact.
+	Open(ctx context.Context) (io.ReadCloser, error)
+	// String returns the location URI.
+	String() string
+}
+
+// ParseLocation resolves a backup URI into a Location.
+// Supported forms are a plain path or file:///path for local files,
+// s3://bucket/key for S3-compatible object stores (see NewS3FromEnv), and
+// http:// or https:// URLs, which can only be read.
+func ParseLocation(uri string) (Location, error) {
+	if uri == "" {
+		return nil, fmt.Errorf("backup location is empty")
+	}
+	if !strings.Contains(uri, "://") {
+		return &fileLocation{path: uri}, nil
+	}
+
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, fmt.Errorf("parse backup location: %w", err)
+	}
+	switch u.Scheme {
+	case "file":
+		return &fileLocation{path: u.Path}, nil
+	case "s3":
+		key := strings.TrimPrefix(u.Path, "/")
+		if u.Host == "" || key == "" {
+			return nil, fmt.Errorf("s3 location must be s3://bucket/key")
+		}
+		return NewS3FromEnv(u.Host, key)
+	case "http", "https":
+		if u.Host == "" {
+			return nil, fmt.Errorf("http location must have a host")
+		}
+		return &httpLocation{url: uri}, nil
+	default:
+		return nil, fmt.Errorf("unsupported backup scheme %q", u.Scheme)
+	}
+}
+
+// Backup streams a snapshot from src into loc.
+// The snapshot is produced concurrently with the upload through a pipe, so the
+// full state is never buffered in memory by this function.
+func Backup(ctx context.Context, src Snapshotter, loc Location) error {
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(src.Snapshot(pw))
+	}()
+
+	if err := loc.Write(ctx, pr); err != nil {
+		pr.CloseWithError(err)
+		return fmt.Errorf("write backup to %s: %w", loc, err)
+	}
+	return nil
+}
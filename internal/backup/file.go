@@ -0,0 +1,48 @@
+package backup
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// fileLocation stores the backup as a single file on the local filesystem.
+type fileLocation struct {
+	path string
+}
+
+// Write writes to a temporary file in the same directory and renames it into
+// place, so a failed backup never clobbers the previous one.
+func (l *fileLocation) Write(ctx context.Context, r io.Reader) error {
+	dir := filepath.Dir(l.path)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, filepath.Base(l.path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), l.path)
+}
+
+func (l *fileLocation) Open(ctx context.Context) (io.ReadCloser, error) {
+	return os.Open(l.path)
+}
+
+func (l *fileLocation) String() string {
+	return "file://" + l.path
+}
@@ -0,0 +1,41 @@
+package backup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// httpLocation reads an artifact served over HTTP(S), such as a presigned
+// object URL or a file server. It is read-only.
+type httpLocation struct {
+	url string
+}
+
+func (l *httpLocation) Write(ctx context.Context, r io.Reader) error {
+	return errors.New("http locations are read-only")
+}
+
+// Open downloads the artifact. The caller must close the returned reader.
+func (l *httpLocation) Open(ctx context.Context) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, l.url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 != 2 {
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("http get: %s: %s", resp.Status, body)
+	}
+	return resp.Body, nil
+}
+
+func (l *httpLocation) String() string {
+	return l.url
+}
@@ -0,0 +1,209 @@
+package backup
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// S3Location stores the backup as a single object in an S3-compatible store
+// (AWS S3, MinIO, Ceph RGW, ...). Requests are signed with AWS Signature V4
+// and use path-style addressing so custom endpoints work without DNS setup.
+type S3Location struct {
+	Endpoint  string // e.g. https://s3.us-east-1.amazonaws.com or http://minio:9000
+	Region    string
+	Bucket    string
+	Key       string
+	AccessKey string
+	SecretKey string
+
+	client *http.Client
+	now    func() time.Time
+}
+
+// NewS3FromEnv creates an S3Location using credentials from the standard
+// AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and AWS_REGION variables.
+// S3_ENDPOINT overrides the endpoint for S3-compatible stores.
+func NewS3FromEnv(bucket, key string) (*S3Location, error) {
+	region := os.Getenv("AWS_REGION")
+	if region == "" {
+		region = "us-east-1"
+	}
+	endpoint := os.Getenv("S3_ENDPOINT")
+	if endpoint == "" {
+		endpoint = fmt.Sprintf("https://s3.%s.amazonaws.com", region)
+	}
+	l := &S3Location{
+		Endpoint:  strings.TrimSuffix(endpoint, "/"),
+		Region:    region,
+		Bucket:    bucket,
+		Key:       key,
+		AccessKey: os.Getenv("AWS_ACCESS_KEY_ID"),
+		SecretKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+	}
+	if l.AccessKey == "" || l.SecretKey == "" {
+		return nil, fmt.Errorf("s3 backup requires AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY")
+	}
+	return l, nil
+}
+
+// Write uploads the artifact with a single PUT.
+// S3 requires a Content-Length and payload hash up front, so the stream is
+// spooled to a temporary file first.
+func (l *S3Location) Write(ctx context.Context, r io.Reader) error {
+	tmp, err := os.CreateTemp("", "cache-backup-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	h := sha256.New()
+	size, err := io.Copy(io.MultiWriter(tmp, h), r)
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, l.objectURL(), tmp)
+	if err != nil {
+		return err
+	}
+	req.ContentLength = size
+	l.sign(req, hex.EncodeToString(h.Sum(nil)))
+
+	resp, err := l.httpClient().Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("s3 put: %s: %s", resp.Status, body)
+	}
+	return nil
+}
+
+// Open downloads the artifact. The caller must close the returned reader.
+func (l *S3Location) Open(ctx context.Context) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, l.objectURL(), nil)
+	if err != nil {
+		return nil, err
+	}
+	l.sign(req, emptyPayloadHash)
+
+	resp, err := l.httpClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode/100 != 2 {
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return nil, fmt.Errorf("s3 get: %s: %s", resp.Status, body)
+	}
+	return resp.Body, nil
+}
+
+func (l *S3Location) String() string {
+	return fmt.Sprintf("s3://%s/%s", l.Bucket, l.Key)
+}
+
+func (l *S3Location) objectURL() string {
+	return fmt.Sprintf("%s/%s/%s", l.Endpoint, l.Bucket, escapePath(l.Key))
+}
+
+func (l *S3Location) httpClient() *http.Client {
+	if l.client != nil {
+		return l.client
+	}
+	return http.DefaultClient
+}
+
+const emptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// sign adds AWS Signature V4 headers to req for the s3 service.
+func (l *S3Location) sign(req *http.Request, payloadHash string) {
+	now := time.Now
+	if l.now != nil {
+		now = l.now
+	}
+	t := now().UTC()
+	amzDate := t.Format("20060102T150405Z")
+	date := t.Format("20060102")
+
+	req.Header.Set("x-amz-date", amzDate)
+	req.Header.Set("x-amz-content-sha256", payloadHash)
+
+	// Canonical headers: host plus every header set on the request, lower-cased and sorted.
+	headers := map[string]string{"host": req.URL.Host}
+	for k, v := range req.Header {
+		headers[strings.ToLower(k)] = strings.TrimSpace(strings.Join(v, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+	var canonicalHeaders strings.Builder
+	for _, k := range names {
+		canonicalHeaders.WriteString(k + ":" + headers[k] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.Query().Encode(),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := date + "/" + l.Region + "/s3/aws4_request"
+	stringToSign := strings.Join([]string{
+		"AWS4-HMAC-SHA256",
+		amzDate,
+		scope,
+		hashHex([]byte(canonicalRequest)),
+	}, "\n")
+
+	key := hmacSHA256([]byte("AWS4"+l.SecretKey), date)
+	key = hmacSHA256(key, l.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		l.AccessKey, scope, signedHeaders, signature))
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	m := hmac.New(sha256.New, key)
+	m.Write([]byte(data))
+	return m.Sum(nil)
+}
+
+func hashHex(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+// escapePath URI-encodes each segment of an object key, keeping the separators.
+func escapePath(key string) string {
+	parts := strings.Split(key, "/")
+	for i, p := range parts {
+		parts[i] = url.PathEscape(p)
+	}
+	return strings.Join(parts, "/")
+}
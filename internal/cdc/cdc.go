@@ -0,0 +1,82 @@
+// Package cdc exports a change-data-capture stream of applied Raft commands.
+//
+// Each mutation is published as an Event carrying a hash of the value rather
+// than the value itself, which is enough for analytics and for invalidating
+// caches in other systems. Delivery runs on a writebehind.Queue, so it inherits
+// batching, retries, dead-letter metrics and leader-only publishing.
+package cdc
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"distributed-cache-service/internal/writebehind"
+)
+
+// Event is the CDC record for one applied command.
+type Event struct {
+	Index     uint64    `json:"index"`
+	Op        string    `json:"op"`
+	Key       string    `json:"key"`
+	ValueHash string    `json:"value_hash,omitempty"` // hex SHA-256 of the value, SET only
+	Timestamp time.Time `json:"ts"`
+}
+
+// Publisher sends a batch of events to a message system.
+type Publisher interface {
+	Publish(ctx context.Context, events []Event) error
+}
+
+// Sink adapts a Publisher to writebehind.Sink.
+type Sink struct {
+	publisher Publisher
+}
+
+// ensure implementation
+var _ writebehind.Sink = (*Sink)(nil)
+
+// NewSink creates a Sink publishing through p.
+func NewSink(p Publisher) *Sink {
+	return &Sink{publisher: p}
+}
+
+// Deliver implements writebehind.Sink.
+func (s *Sink) Deliver(ctx context.Context, batch []writebehind.Mutation) error {
+	events := make([]Event, len(batch))
+	for i, m := range batch {
+		events[i] = Event{Index: m.Index, Op: m.Op, Key: m.Key, Timestamp: m.Timestamp}
+		if m.Op == "SET" {
+			sum := sha256.Sum256([]byte(m.Value))
+			events[i].ValueHash = hex.EncodeToString(sum[:])
+		}
+	}
+	return s.publisher.Publish(ctx, events)
+}
+
+// ParseSink builds a CDC sink from a URI:
+//
+//	kafka://rest-proxy:8082/topic   Kafka topic via a Confluent REST Proxy
+//	nats://host:4222/subject        NATS subject
+func ParseSink(uri string) (*Sink, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, fmt.Errorf("invalid cdc sink: %w", err)
+	}
+	target := strings.Trim(u.Path, "/")
+	if u.Host == "" || target == "" {
+		return nil, fmt.Errorf("invalid cdc sink %q: expected scheme://host:port/topic", uri)
+	}
+	switch u.Scheme {
+	case "kafka":
+		return NewSink(NewKafka("http://"+u.Host, target)), nil
+	case "nats":
+		return NewSink(NewNATS(u.Host, target)), nil
+	default:
+		return nil, fmt.Errorf("unsupported cdc sink scheme %q", u.Scheme)
+	}
+}
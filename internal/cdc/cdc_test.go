@@ -0,0 +1,143 @@
+package cdc
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"distributed-cache-service/internal/writebehind"
+)
+
+type capturePublisher struct {
+	events []Event
+}
+
+func (p *capturePublisher) Publish(ctx context.Context, events []Event) error {
+	p.events = append(p.events, events...)
+	return nil
+}
+
+func TestSink_HashesValues(t *testing.T) {
+	pub := &capturePublisher{}
+	ts := time.Unix(1700000000, 0)
+	err := NewSink(pub).Deliver(context.Background(), []writebehind.Mutation{
+		{Op: "SET", Key: "k", Value: "hello", Index: 1, Timestamp: ts},
+		{Op: "DELETE", Key: "k", Index: 2, Timestamp: ts},
+	})
+	if err != nil {
+		t.Fatalf("deliver: %v", err)
+	}
+
+	// sha256("hello")
+	const want = "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"
+	if pub.events[0].ValueHash != want {
+		t.Errorf("expected hash %s, got %s", want, pub.events[0].ValueHash)
+	}
+	if pub.events[1].ValueHash != "" || pub.events[1].Index != 2 || !pub.events[1].Timestamp.Equal(ts) {
+		t.Errorf("unexpected delete event %+v", pub.events[1])
+	}
+}
+
+// fakeNATS accepts one connection and returns the payloads published to subject.
+func fakeNATS(t *testing.T, subject string) (addr string, published <-chan string) {
+	t.Helper()
+	lis, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	t.Cleanup(func() { lis.Close() })
+
+	ch := make(chan string, 10)
+	go func() {
+		conn, err := lis.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		conn.Write([]byte("INFO {\"server_id\":\"test\"}\r\n"))
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			fields := strings.Fields(line)
+			switch {
+			case len(fields) == 3 && fields[0] == "PUB" && fields[1] == subject:
+				n, _ := strconv.Atoi(fields[2])
+				buf := make([]byte, n+2)
+				if _, err := io.ReadFull(r, buf); err != nil {
+					return
+				}
+				ch <- string(buf[:n])
+			case len(fields) == 1 && fields[0] == "PING":
+				conn.Write([]byte("PONG\r\n"))
+			}
+		}
+	}()
+	return lis.Addr().String(), ch
+}
+
+func TestNATS_Publish(t *testing.T) {
+	addr, published := fakeNATS(t, "cache.cdc")
+	sink, err := ParseSink("nats://" + addr + "/cache.cdc")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := sink.Deliver(ctx, []writebehind.Mutation{{Op: "SET", Key: "k", Value: "v", Index: 5}}); err != nil {
+		t.Fatalf("deliver: %v", err)
+	}
+
+	var e Event
+	if err := json.Unmarshal([]byte(<-published), &e); err != nil {
+		t.Fatalf("decode: %v", err)
+	}
+	if e.Key != "k" || e.Index != 5 || e.Op != "SET" {
+		t.Errorf("unexpected event %+v", e)
+	}
+}
+
+func TestKafka_Publish(t *testing.T) {
+	var body struct {
+		Records []struct {
+			Key   string
+			Value Event
+		} `json:"records"`
+	}
+	var path string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		json.NewDecoder(r.Body).Decode(&body)
+	}))
+	defer srv.Close()
+
+	sink, err := ParseSink("kafka://" + srv.Listener.Addr().String() + "/changes")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if err := sink.Deliver(context.Background(), []writebehind.Mutation{{Op: "DELETE", Key: "k", Index: 3}}); err != nil {
+		t.Fatalf("deliver: %v", err)
+	}
+	if path != "/topics/changes" || len(body.Records) != 1 || body.Records[0].Value.Index != 3 {
+		t.Errorf("unexpected request %s %+v", path, body.Records)
+	}
+}
+
+func TestParseSink_Errors(t *testing.T) {
+	for _, uri := range []string{"nats://host:4222", "kafka:///topic", "amqp://host/q"} {
+		if _, err := ParseSink(uri); err == nil {
+			t.Errorf("expected error for %q", uri)
+		}
+	}
+}
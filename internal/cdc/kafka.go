@@ -0,0 +1,27 @@
+package cdc
+
+import (
+	"context"
+
+	"distributed-cache-service/internal/kafkarest"
+)
+
+// Kafka produces events through the Confluent REST Proxy v2 API, keyed by
+// cache key so each key's changes stay ordered within a partition.
+type Kafka struct {
+	producer *kafkarest.Producer
+}
+
+// NewKafka creates a publisher for topic on the REST proxy at baseURL.
+func NewKafka(baseURL, topic string) *Kafka {
+	return &Kafka{producer: kafkarest.New(baseURL, topic)}
+}
+
+// Publish implements Publisher.
+func (k *Kafka) Publish(ctx context.Context, events []Event) error {
+	records := make([]kafkarest.Record, len(events))
+	for i, e := range events {
+		records[i] = kafkarest.Record{Key: e.Key, Value: e}
+	}
+	return k.producer.Produce(ctx, records)
+}
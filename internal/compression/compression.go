@@ -0,0 +1,213 @@
+// Package compression implements transparent compression of cache values.
+//
+// Encoded values start with a short header naming the codec, so values written
+// with different settings (or before compression was enabled) can always be read
+// back. Values below the size threshold, or that do not shrink, are stored as-is.
+package compression
+
+import (
+	"bytes"
+	"compress/flate"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"distributed-cache-service/internal/observability"
+
+	"github.com/klauspost/compress/snappy"
+	"github.com/klauspost/compress/zstd"
+)
+
+// header prefixes every encoded value. It starts with a NUL byte, which makes
+// collisions with ordinary text values unlikely; raw values that happen to
+// start with it are escaped with the None codec.
+const header = "\x00dcz"
+
+// Codec identifies the compression algorithm in the value header.
+type Codec byte
+
+const (
+	// None marks a raw value that was escaped because it starts with the header.
+	None Codec = 'n'
+	// Deflate compresses with DEFLATE (RFC 1951) at its fastest level.
+	Deflate Codec = 'f'
+	// Snappy compresses with Snappy's block format: the fastest, with the
+	// least savings.
+	Snappy Codec = 's'
+	// Zstd compresses with Zstandard (RFC 8878) at its fastest level: about
+	// as fast as DEFLATE, with better savings.
+	Zstd Codec = 'z'
+	// Encrypted marks a value sealed by package encryption, which has to open
+	// it before Decode can read it.
+	Encrypted Codec = 'e'
+)
+
+// ErrEncrypted is returned by Decode for an encrypted value.
+var ErrEncrypted = errors.New("value is encrypted")
+
+// Header returns the prefix of values stored with codec c.
+func Header(c Codec) string {
+	return header + string(c)
+}
+
+// ParseCodec converts a codec name ("none", "deflate", "snappy", "zstd") to a
+// Codec.
+func ParseCodec(name string) (Codec, error) {
+	switch strings.ToLower(name) {
+	case "", "none":
+		return None, nil
+	case "deflate", "flate":
+		return Deflate, nil
+	case "snappy":
+		return Snappy, nil
+	case "zstd":
+		return Zstd, nil
+	default:
+		return 0, fmt.Errorf("unknown compression codec '%s'", name)
+	}
+}
+
+// String returns the codec name.
+func (c Codec) String() string {
+	switch c {
+	case None:
+		return "none"
+	case Deflate:
+		return "deflate"
+	case Snappy:
+		return "snappy"
+	case Zstd:
+		return "zstd"
+	case Encrypted:
+		return "encrypted"
+	default:
+		return fmt.Sprintf("codec(%q)", byte(c))
+	}
+}
+
+// Compressor compresses values of at least Threshold bytes with its codec.
+// A nil Compressor leaves values untouched apart from escaping.
+type Compressor struct {
+	codec     Codec
+	threshold int
+	writers   sync.Pool
+}
+
+// New creates a Compressor. A threshold <= 0 compresses every value.
+func New(codec Codec, threshold int) *Compressor {
+	return &Compressor{codec: codec, threshold: threshold}
+}
+
+// Encode prepares value for storage. compressed reports whether the result
+// holds compressed (binary) data; otherwise it is value, escaped if necessary.
+func (c *Compressor) Encode(value string) (encoded string, compressed bool) {
+	if c != nil && c.codec != None && len(value) >= c.threshold {
+		if out, ok := c.compress(value); ok {
+			observability.CompressionBytesTotal.WithLabelValues("raw").Add(float64(len(value)))
+			observability.CompressionBytesTotal.WithLabelValues("compressed").Add(float64(len(out)))
+			observability.CompressionRatio.Observe(float64(len(out)) / float64(len(value)))
+			return out, true
+		}
+		observability.CompressionSkippedTotal.Inc()
+	}
+	if strings.HasPrefix(value, header) {
+		return header + string(None) + value, false
+	}
+	return value, false
+}
+
+// Escape returns value in stored form without compressing it.
+func Escape(value string) string {
+	encoded, _ := (*Compressor)(nil).Encode(value)
+	return encoded
+}
+
+// zstd encoders and decoders are safe for concurrent EncodeAll and DecodeAll
+// calls, so one of each serves every Compressor.
+var (
+	zstdEncoder, _ = zstd.NewWriter(nil, zstd.WithEncoderLevel(zstd.SpeedFastest), zstd.WithEncoderConcurrency(1))
+	zstdDecoder, _ = zstd.NewReader(nil, zstd.WithDecoderConcurrency(0))
+)
+
+func (c *Compressor) compress(value string) (string, bool) {
+	var out []byte
+	switch c.codec {
+	case Snappy:
+		out = append([]byte(Header(c.codec)), snappy.Encode(nil, []byte(value))...)
+	case Zstd:
+		out = zstdEncoder.EncodeAll([]byte(value), append(make([]byte, 0, len(value)/2), Header(c.codec)...))
+	default:
+		return c.deflate(value)
+	}
+	if len(out) >= len(value) {
+		return "", false
+	}
+	return string(out), true
+}
+
+func (c *Compressor) deflate(value string) (string, bool) {
+	var buf bytes.Buffer
+	buf.Grow(len(value) / 2)
+	buf.WriteString(header)
+	buf.WriteByte(byte(c.codec))
+
+	w, _ := c.writers.Get().(*flate.Writer)
+	if w == nil {
+		w, _ = flate.NewWriter(&buf, flate.BestSpeed)
+	} else {
+		w.Reset(&buf)
+	}
+	defer c.writers.Put(w)
+
+	if _, err := io.WriteString(w, value); err != nil {
+		return "", false
+	}
+	if err := w.Close(); err != nil {
+		return "", false
+	}
+	// Not worth it if the header and DEFLATE framing eat the savings.
+	if buf.Len() >= len(value) {
+		return "", false
+	}
+	return buf.String(), true
+}
+
+// Decode returns the original value for a stored value produced by Encode.
+// Values without a header are returned unchanged.
+func Decode(stored string) (string, error) {
+	if len(stored) <= len(header) || !strings.HasPrefix(stored, header) {
+		return stored, nil
+	}
+	payload := stored[len(header)+1:]
+	switch Codec(stored[len(header)]) {
+	case None:
+		return payload, nil
+	case Deflate:
+		r := flate.NewReader(strings.NewReader(payload))
+		defer r.Close()
+		var out strings.Builder
+		if _, err := io.Copy(&out, r); err != nil {
+			return "", fmt.Errorf("decompress value: %w", err)
+		}
+		return out.String(), nil
+	case Snappy:
+		out, err := snappy.Decode(nil, []byte(payload))
+		if err != nil {
+			return "", fmt.Errorf("decompress value: %w", err)
+		}
+		return string(out), nil
+	case Zstd:
+		out, err := zstdDecoder.DecodeAll([]byte(payload), nil)
+		if err != nil {
+			return "", fmt.Errorf("decompress value: %w", err)
+		}
+		return string(out), nil
+	case Encrypted:
+		return "", ErrEncrypted
+	default:
+		// Not one of ours; treat it as a raw value.
+		return stored, nil
+	}
+}
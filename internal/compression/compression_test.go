@@ -0,0 +1,88 @@
+package compression
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestEncodeDecode(t *testing.T) {
+	for _, codec := range []Codec{Deflate, Snappy, Zstd} {
+		t.Run(codec.String(), func(t *testing.T) {
+			testEncodeDecode(t, New(codec, 32))
+		})
+	}
+}
+
+func testEncodeDecode(t *testing.T, c *Compressor) {
+	tests := []struct {
+		name       string
+		value      string
+		compressed bool
+	}{
+		{"below threshold", "short", false},
+		{"compressible", strings.Repeat("abc", 100), true},
+		{"incompressible", "0123456789abcdefghijklmnopqrstuvwxyzABCDEF", false},
+		{"looks like header", header + "f" + "not deflate", false},
+		{"empty", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			encoded, compressed := c.Encode(tt.value)
+			if compressed && !strings.HasPrefix(encoded, Header(c.codec)) {
+				t.Errorf("expected the %s header, got %q", c.codec, encoded[:len(header)+1])
+			}
+			if compressed != tt.compressed {
+				t.Errorf("expected compressed=%v, got %v", tt.compressed, compressed)
+			}
+			if compressed && len(encoded) >= len(tt.value) {
+				t.Errorf("compressed value is not smaller: %d >= %d", len(encoded), len(tt.value))
+			}
+			got, err := Decode(encoded)
+			if err != nil {
+				t.Fatalf("decode: %v", err)
+			}
+			if got != tt.value {
+				t.Errorf("expected %q, got %q", tt.value, got)
+			}
+		})
+	}
+}
+
+func TestNilCompressorEscapes(t *testing.T) {
+	var c *Compressor
+	value := header + "f" + "raw"
+	encoded, compressed := c.Encode(value)
+	if compressed {
+		t.Fatal("nil compressor should not compress")
+	}
+	if got, _ := Decode(encoded); got != value {
+		t.Errorf("expected %q, got %q", value, got)
+	}
+}
+
+func TestDecodeCorrupt(t *testing.T) {
+	for _, codec := range []Codec{Deflate, Snappy, Zstd} {
+		if _, err := Decode(Header(codec) + "\xff\xff\xff"); err == nil {
+			t.Errorf("expected error for corrupt %s payload", codec)
+		}
+	}
+	if _, err := Decode(Header(Encrypted) + "sealed"); !errors.Is(err, ErrEncrypted) {
+		t.Errorf("expected ErrEncrypted, got %v", err)
+	}
+}
+
+func TestParseCodec(t *testing.T) {
+	for name, want := range map[string]Codec{"deflate": Deflate, "snappy": Snappy, "ZSTD": Zstd} {
+		if c, err := ParseCodec(name); err != nil || c != want {
+			t.Errorf("expected %v for %q, got %v (%v)", want, name, c, err)
+		}
+	}
+	if c, err := ParseCodec(""); err != nil || c != None {
+		t.Errorf("expected None, got %v (%v)", c, err)
+	}
+	if _, err := ParseCodec("lz4"); err == nil {
+		t.Error("expected error for unknown codec")
+	}
+}
@@ -0,0 +1,50 @@
+package grpczstd
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"google.golang.org/grpc/encoding"
+)
+
+func TestCompressor(t *testing.T) {
+	c := encoding.GetCompressor(Name)
+	if c == nil {
+		t.Fatalf("expected a %q compressor to be registered", Name)
+	}
+	// Twice, so that the second round reuses the pooled encoder and decoder.
+	for i := 0; i < 2; i++ {
+		message := strings.Repeat("compressible ", 1000) + string(rune('a'+i))
+		var buf bytes.Buffer
+		w, err := c.Compress(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := io.WriteString(w, message); err != nil {
+			t.Fatal(err)
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if buf.Len() >= len(message)/10 {
+			t.Errorf("expected the message to shrink, got %d bytes from %d", buf.Len(), len(message))
+		}
+
+		r, err := c.Decompress(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := io.ReadAll(r)
+		if err != nil || string(got) != message {
+			t.Fatalf("expected the message back, got %d bytes (%v)", len(got), err)
+		}
+	}
+
+	if r, err := c.Decompress(strings.NewReader("not zstd")); err == nil {
+		if _, err := io.ReadAll(r); err == nil {
+			t.Error("expected garbage to fail to decompress")
+		}
+	}
+}
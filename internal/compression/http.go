@@ -0,0 +1,120 @@
+package compression
+
+import (
+	"compress/gzip"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// GzipHandler compresses the responses of next with gzip for clients that
+// accept it, once a response reaches minSize bytes; smaller responses are sent
+// as-is. Responses that already carry a Content-Encoding are left alone, and
+// so are requests to upgrade the connection, such as WebSocket handshakes.
+func GzipHandler(next http.Handler, minSize, level int) (http.Handler, error) {
+	if _, err := gzip.NewWriterLevel(nil, level); err != nil {
+		return nil, fmt.Errorf("invalid gzip level %d", level)
+	}
+	pool := &sync.Pool{New: func() any {
+		w, _ := gzip.NewWriterLevel(nil, level)
+		return w
+	}}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+		if !acceptsGzip(r.Header.Get("Accept-Encoding")) || r.Header.Get("Upgrade") != "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+		gw := &gzipWriter{ResponseWriter: w, minSize: minSize, pool: pool, status: http.StatusOK}
+		defer gw.close()
+		next.ServeHTTP(gw, r)
+	}), nil
+}
+
+// acceptsGzip reports whether an Accept-Encoding header allows gzip.
+func acceptsGzip(header string) bool {
+	for _, part := range strings.Split(header, ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if coding = strings.TrimSpace(coding); coding != "gzip" && coding != "*" {
+			continue
+		}
+		q, ok := strings.CutPrefix(strings.ReplaceAll(params, " ", ""), "q=")
+		if !ok {
+			return true
+		}
+		v, err := strconv.ParseFloat(q, 64)
+		return err == nil && v > 0
+	}
+	return false
+}
+
+// gzipWriter buffers a response until it reaches minSize, then decides whether
+// to compress it. The status code is held back until the decision is made,
+// since compressing changes the headers.
+type gzipWriter struct {
+	http.ResponseWriter
+	minSize int
+	pool    *sync.Pool
+	status  int
+	buf     []byte
+	gz      *gzip.Writer
+	decided bool
+}
+
+func (g *gzipWriter) WriteHeader(status int) {
+	if !g.decided {
+		g.status = status
+	}
+}
+
+func (g *gzipWriter) Write(p []byte) (int, error) {
+	if g.decided {
+		if g.gz != nil {
+			return g.gz.Write(p)
+		}
+		return g.ResponseWriter.Write(p)
+	}
+	g.buf = append(g.buf, p...)
+	if len(g.buf) >= g.minSize {
+		if err := g.decide(); err != nil {
+			return 0, err
+		}
+	}
+	return len(p), nil
+}
+
+// decide sends the headers and the buffered body, compressed if worthwhile.
+func (g *gzipWriter) decide() error {
+	g.decided = true
+	h := g.Header()
+	if len(g.buf) >= g.minSize && len(g.buf) > 0 && h.Get("Content-Encoding") == "" && bodyAllowed(g.status) {
+		h.Set("Content-Encoding", "gzip")
+		h.Del("Content-Length")
+		g.gz = g.pool.Get().(*gzip.Writer)
+		g.gz.Reset(g.ResponseWriter)
+		g.ResponseWriter.WriteHeader(g.status)
+		_, err := g.gz.Write(g.buf)
+		g.buf = nil
+		return err
+	}
+	g.ResponseWriter.WriteHeader(g.status)
+	_, err := g.ResponseWriter.Write(g.buf)
+	g.buf = nil
+	return err
+}
+
+func (g *gzipWriter) close() {
+	if !g.decided {
+		g.decide()
+	}
+	if g.gz != nil {
+		g.gz.Close()
+		g.pool.Put(g.gz)
+	}
+}
+
+func bodyAllowed(status int) bool {
+	return status >= 200 && status != http.StatusNoContent && status != http.StatusNotModified
+}
@@ -0,0 +1,76 @@
+package compression
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestGzipHandler(t *testing.T) {
+	big := strings.Repeat("value ", 500)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/big", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "3000")
+		w.WriteHeader(http.StatusCreated)
+		io.WriteString(w, big[:1000])
+		io.WriteString(w, big[1000:])
+	})
+	mux.HandleFunc("/small", func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	})
+	mux.HandleFunc("/encoded", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Encoding", "br")
+		io.WriteString(w, big)
+	})
+	h, err := GzipHandler(mux, 1024, gzip.BestSpeed)
+	if err != nil {
+		t.Fatalf("new handler: %v", err)
+	}
+
+	get := func(path, accept string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		if accept != "" {
+			req.Header.Set("Accept-Encoding", accept)
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := get("/big", "br, gzip;q=0.8")
+	if rec.Code != http.StatusCreated || rec.Header().Get("Content-Encoding") != "gzip" || rec.Header().Get("Content-Length") != "" {
+		t.Fatalf("expected a gzipped 201, got %d %v", rec.Code, rec.Header())
+	}
+	if rec.Body.Len() >= len(big) {
+		t.Errorf("expected a smaller body, got %d bytes", rec.Body.Len())
+	}
+	zr, err := gzip.NewReader(rec.Body)
+	if err != nil {
+		t.Fatalf("gzip reader: %v", err)
+	}
+	if body, _ := io.ReadAll(zr); string(body) != big {
+		t.Errorf("body did not round-trip")
+	}
+
+	for _, tt := range []struct{ path, accept, body string }{
+		{"/big", "", big},
+		{"/big", "gzip;q=0", big},
+		{"/small", "gzip", "ok"},
+		{"/encoded", "gzip", big},
+	} {
+		rec := get(tt.path, tt.accept)
+		if rec.Body.String() != tt.body {
+			t.Errorf("%s (Accept-Encoding %q): expected the body as-is", tt.path, tt.accept)
+		}
+		if tt.path != "/encoded" && rec.Header().Get("Content-Encoding") != "" {
+			t.Errorf("%s (Accept-Encoding %q): unexpected Content-Encoding %q", tt.path, tt.accept, rec.Header().Get("Content-Encoding"))
+		}
+	}
+
+	if _, err := GzipHandler(mux, 1024, 42); err == nil {
+		t.Error("expected an invalid level to be rejected")
+	}
+}
@@ -0,0 +1,219 @@
+// Package config manages runtime configuration that can be changed without
+// restarting a node, either via SIGHUP (re-reading a JSON file) or the
+// /admin/config HTTP endpoint.
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Runtime holds the parameters that can safely change while the node is running.
+type Runtime struct {
+	MaxItems        int      `json:"max_items"`
+	EvictionPolicy  string   `json:"eviction_policy"`
+	LFUHalfLife     Duration `json:"lfu_half_life"` // 0 = lfu never halves its counts
+	LogLevel        string   `json:"log_level"`
+	RateLimit       float64  `json:"rate_limit"` // Requests per second (0 = unlimited)
+	RateBurst       int      `json:"rate_burst"`
+	CleanupInterval Duration `json:"cleanup_interval"`
+
+	// Raft tuning; zero values keep Raft's defaults.
+	RaftSnapshotThreshold uint64   `json:"raft_snapshot_threshold"`
+	RaftSnapshotInterval  Duration `json:"raft_snapshot_interval"`
+	RaftTrailingLogs      uint64   `json:"raft_trailing_logs"`
+	RaftHeartbeatTimeout  Duration `json:"raft_heartbeat_timeout"`
+	RaftElectionTimeout   Duration `json:"raft_election_timeout"`
+	RaftLogMaxBytes       int64    `json:"raft_log_max_bytes"` // 0 = unbounded
+}
+
+// Validate checks that the configuration values are usable.
+func (r Runtime) Validate() error {
+	if r.MaxItems < 0 {
+		return fmt.Errorf("max_items must be >= 0")
+	}
+	if r.RateLimit < 0 || r.RateBurst < 0 {
+		return fmt.Errorf("rate_limit and rate_burst must be >= 0")
+	}
+	if r.CleanupInterval.Duration < 0 {
+		return fmt.Errorf("cleanup_interval must be >= 0")
+	}
+	if r.LFUHalfLife.Duration < 0 {
+		return fmt.Errorf("lfu_half_life must be >= 0")
+	}
+	if r.RaftSnapshotInterval.Duration < 0 || r.RaftHeartbeatTimeout.Duration < 0 || r.RaftElectionTimeout.Duration < 0 {
+		return fmt.Errorf("raft_snapshot_interval, raft_heartbeat_timeout and raft_election_timeout must be >= 0")
+	}
+	if r.RaftLogMaxBytes < 0 {
+		return fmt.Errorf("raft_log_max_bytes must be >= 0")
+	}
+	if _, err := ParseLogLevel(r.LogLevel); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Duration wraps time.Duration so it can be expressed as a string (e.g. "30s") in JSON.
+type Duration struct {
+	time.Duration
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+func (d *Duration) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	v, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	d.Duration = v
+	return nil
+}
+
+// ParseLogLevel converts a level name (debug, info, warn, error) into a slog.Level.
+func ParseLogLevel(name string) (slog.Level, error) {
+	switch strings.ToLower(name) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "", "info":
+		return slog.LevelInfo, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	default:
+		return 0, fmt.Errorf("unknown log level %q", name)
+	}
+}
+
+// ApplyFunc pushes a new configuration into the running components.
+// It receives the previous configuration so unchanged settings can be skipped.
+type ApplyFunc func(prev, next Runtime) error
+
+// Manager holds the active runtime configuration and applies updates to it.
+type Manager struct {
+	mu      sync.Mutex
+	current Runtime
+	path    string
+	apply   ApplyFunc
+}
+
+// NewManager creates a manager with the given initial configuration.
+// path is the JSON file re-read on Reload; it may be empty if only HTTP updates are used.
+func NewManager(initial Runtime, path string, apply ApplyFunc) *Manager {
+	return &Manager{
+		current: initial,
+		path:    path,
+		apply:   apply,
+	}
+}
+
+// Current returns a copy of the active configuration.
+func (m *Manager) Current() Runtime {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.current
+}
+
+// Update validates and applies a full configuration.
+func (m *Manager) Update(next Runtime) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.updateLocked(next)
+}
+
+// Patch decodes a partial JSON configuration from r on top of the active one and applies it.
+// Fields absent from the document keep their current value.
+func (m *Manager) Patch(r io.Reader) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	next := m.current
+	if err := json.NewDecoder(r).Decode(&next); err != nil {
+		return fmt.Errorf("decode config: %w", err)
+	}
+	return m.updateLocked(next)
+}
+
+// Reload re-reads the configuration file and applies it.
+func (m *Manager) Reload() error {
+	if m.path == "" {
+		return fmt.Errorf("no config file configured")
+	}
+	f, err := os.Open(m.path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	return m.Patch(f)
+}
+
+func (m *Manager) updateLocked(next Runtime) error {
+	if err := next.Validate(); err != nil {
+		return err
+	}
+	if m.apply != nil {
+		if err := m.apply(m.current, next); err != nil {
+			return err
+		}
+	}
+	m.current = next
+	return nil
+}
+
+// WatchSignals reloads the configuration file whenever the process receives
+// SIGHUP, then calls onReload, if not nil, with the outcome.
+// It returns immediately; reloading happens in a background goroutine.
+func (m *Manager) WatchSignals(onReload func(err error)) {
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, syscall.SIGHUP)
+	go func() {
+		for range ch {
+			err := m.Reload()
+			if err != nil {
+				log.Printf("Config reload failed: %v", err)
+			} else {
+				log.Printf("Config reloaded from %s", m.path)
+			}
+			if onReload != nil {
+				onReload(err)
+			}
+		}
+	}()
+}
+
+// ServeHTTP exposes the configuration: GET returns it, POST/PUT applies a partial update.
+func (m *Manager) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost, http.MethodPut:
+		if err := m.Patch(r.Body); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		log.Printf("Config updated via %s", r.URL.Path)
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(m.Current()); err != nil {
+		log.Printf("Failed to write response: %v", err)
+	}
+}
@@ -0,0 +1,60 @@
+package config
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestManager_Patch(t *testing.T) {
+	var applied []Runtime
+	m := NewManager(Runtime{MaxItems: 10, EvictionPolicy: "lru"}, "", func(prev, next Runtime) error {
+		applied = append(applied, next)
+		return nil
+	})
+
+	err := m.Patch(strings.NewReader(`{"max_items": 20, "cleanup_interval": "5s"}`))
+	require.NoError(t, err)
+
+	cur := m.Current()
+	assert.Equal(t, 20, cur.MaxItems)
+	assert.Equal(t, "lru", cur.EvictionPolicy, "unspecified fields are kept")
+	assert.Equal(t, 5*time.Second, cur.CleanupInterval.Duration)
+	assert.Len(t, applied, 1)
+}
+
+func TestManager_RejectsInvalid(t *testing.T) {
+	m := NewManager(Runtime{MaxItems: 10}, "", nil)
+
+	assert.Error(t, m.Patch(strings.NewReader(`{"max_items": -1}`)))
+	assert.Error(t, m.Patch(strings.NewReader(`{"log_level": "loud"}`)))
+	assert.Error(t, m.Patch(strings.NewReader(`{"raft_heartbeat_timeout": "-1s"}`)))
+	assert.Error(t, m.Patch(strings.NewReader(`{"lfu_half_life": "-1m"}`)))
+	assert.Error(t, m.Patch(strings.NewReader(`{"raft_log_max_bytes": -1}`)))
+	assert.Equal(t, 10, m.Current().MaxItems, "invalid update must not be applied")
+}
+
+func TestManager_Reload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"log_level": "debug"}`), 0600))
+
+	m := NewManager(Runtime{}, path, nil)
+	require.NoError(t, m.Reload())
+	assert.Equal(t, "debug", m.Current().LogLevel)
+}
+
+func TestManager_ServeHTTP(t *testing.T) {
+	m := NewManager(Runtime{}, "", nil)
+
+	rec := httptest.NewRecorder()
+	m.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/admin/config", strings.NewReader(`{"rate_limit": 100}`)))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"rate_limit":100`)
+}
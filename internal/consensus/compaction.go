@@ -0,0 +1,245 @@
+package consensus
+
+import (
+	"fmt"
+	"log"
+	"sync/atomic"
+	"time"
+
+	"distributed-cache-service/internal/observability"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/raft"
+)
+
+// RaftConfig tunes log compaction and failure detection. Zero values keep
+// Raft's defaults: a snapshot every 2m if 8192 entries were committed since
+// the last one, 10240 entries kept after it, 1s heartbeat and election
+// timeouts, and no bound on the log's size.
+type RaftConfig struct {
+	// SnapshotThreshold is how many entries must be committed since the last
+	// snapshot before another is taken.
+	SnapshotThreshold uint64
+	// SnapshotInterval is how often the threshold is checked.
+	SnapshotInterval time.Duration
+	// TrailingLogs is how many entries a snapshot leaves in the log, so that
+	// slightly lagging followers catch up without a full InstallSnapshot.
+	TrailingLogs uint64
+	// HeartbeatTimeout and ElectionTimeout are how long a follower and a
+	// candidate wait without hearing from a leader before starting an election.
+	HeartbeatTimeout time.Duration
+	ElectionTimeout  time.Duration
+	// LogMaxBytes compacts the log, taking a snapshot that truncates every
+	// entry it covers, once the entries held in the log store reach this size.
+	LogMaxBytes int64
+
+	// The settings below only take effect at startup.
+
+	// DisablePreVote makes candidates start real elections straight away.
+	// With pre-vote, a node that lost contact with the cluster first asks
+	// whether it could win, so it cannot depose a healthy leader by bumping
+	// the term when it reconnects.
+	DisablePreVote bool
+	// LeaderLeaseTimeout is how long a leader that cannot reach a quorum
+	// keeps leading before it steps down. It may not exceed the heartbeat
+	// timeout; zero uses 500ms, or the heartbeat timeout if that is lower.
+	LeaderLeaseTimeout time.Duration
+	// SkipVerify starts without checking the data directory, which reads
+	// every log entry and snapshot once.
+	SkipVerify bool
+	// ReapAfter is how long the leader may go without contact with a member
+	// before it removes the member from the cluster; zero disables reaping.
+	ReapAfter time.Duration
+	// ReapConfirm leaves the removal to an operator, who confirms it with
+	// Reap, instead of removing dead members automatically.
+	ReapConfirm bool
+	// ReapMinVoters is the fewest voters automatic reaping leaves in the
+	// configuration; zero uses 3. It keeps the majority side of a partition
+	// from stripping the cluster of the live nodes it cannot reach, one at a
+	// time. An operator's Reap is not limited.
+	ReapMinVoters int
+	// Logger receives Raft's own logs; nil writes them to stderr.
+	Logger hclog.Logger
+}
+
+// reloadable merges the non-zero fields of c onto Raft's defaults.
+func (c RaftConfig) reloadable() raft.ReloadableConfig {
+	var rc raft.ReloadableConfig
+	def := raft.DefaultConfig()
+	rc.SnapshotThreshold = def.SnapshotThreshold
+	rc.SnapshotInterval = def.SnapshotInterval
+	rc.TrailingLogs = def.TrailingLogs
+	rc.HeartbeatTimeout = def.HeartbeatTimeout
+	rc.ElectionTimeout = def.ElectionTimeout
+	if c.SnapshotThreshold > 0 {
+		rc.SnapshotThreshold = c.SnapshotThreshold
+	}
+	if c.SnapshotInterval > 0 {
+		rc.SnapshotInterval = c.SnapshotInterval
+	}
+	if c.TrailingLogs > 0 {
+		rc.TrailingLogs = c.TrailingLogs
+	}
+	if c.HeartbeatTimeout > 0 {
+		rc.HeartbeatTimeout = c.HeartbeatTimeout
+	}
+	if c.ElectionTimeout > 0 {
+		rc.ElectionTimeout = c.ElectionTimeout
+	}
+	return rc
+}
+
+// apply sets the tunables of c on a Raft configuration. The default leader
+// lease may not exceed the heartbeat timeout, so it shrinks along with it.
+func (c RaftConfig) apply(conf *raft.Config) {
+	rc := c.reloadable()
+	conf.SnapshotThreshold = rc.SnapshotThreshold
+	conf.SnapshotInterval = rc.SnapshotInterval
+	conf.TrailingLogs = rc.TrailingLogs
+	conf.HeartbeatTimeout = rc.HeartbeatTimeout
+	conf.ElectionTimeout = rc.ElectionTimeout
+	conf.PreVoteDisabled = c.DisablePreVote
+	if c.Logger != nil {
+		conf.Logger = c.Logger
+	}
+	if c.LeaderLeaseTimeout > 0 {
+		conf.LeaderLeaseTimeout = c.LeaderLeaseTimeout
+	} else if conf.LeaderLeaseTimeout > conf.HeartbeatTimeout {
+		conf.LeaderLeaseTimeout = conf.HeartbeatTimeout
+	}
+}
+
+// Tune applies cfg to the running node, except for the settings that only
+// take effect at startup. The leader lease cannot change, so the heartbeat
+// timeout cannot drop below it.
+func (n *RaftNode) Tune(cfg RaftConfig) error {
+	n.tuneMu.Lock()
+	defer n.tuneMu.Unlock()
+	if err := n.Raft.ReloadConfig(cfg.reloadable()); err != nil {
+		return fmt.Errorf("reload raft config: %w", err)
+	}
+	if n.logs != nil {
+		n.logs.maxBytes.Store(cfg.LogMaxBytes)
+	}
+	return nil
+}
+
+// autoCompact compacts the log in the background unless a compaction is
+// already running.
+func (n *RaftNode) autoCompact() {
+	if !n.compacting.CompareAndSwap(false, true) {
+		return
+	}
+	go func() {
+		defer n.compacting.Store(false)
+		size := n.logs.size.Load()
+		index, err := n.Compact()
+		if err != nil {
+			log.Printf("Automatic log compaction failed: %v", err)
+			return
+		}
+		observability.RaftCompactionsTotal.Inc()
+		log.Printf("Compacted Raft log of %d bytes up to index %d", size, index)
+	}()
+}
+
+// sizedLogStore tracks how many bytes of entries a log store holds and calls
+// the full callback when that reaches maxBytes. Entries are not all the same size, so
+// truncating a range subtracts its share of the total.
+type sizedLogStore struct {
+	raft.LogStore
+	size     atomic.Int64
+	maxBytes atomic.Int64
+	// full is set once Raft is running, since Raft stores logs from its own
+	// goroutines as soon as it starts.
+	full atomic.Pointer[func()]
+}
+
+// newSizedLogStore wraps store, measuring the entries it already holds.
+func newSizedLogStore(store raft.LogStore, maxBytes int64) (*sizedLogStore, error) {
+	s := &sizedLogStore{LogStore: store}
+	s.maxBytes.Store(maxBytes)
+	first, err := store.FirstIndex()
+	if err != nil {
+		return nil, err
+	}
+	last, err := store.LastIndex()
+	if err != nil {
+		return nil, err
+	}
+	var entry raft.Log
+	for i := first; first > 0 && i <= last; i++ {
+		if err := store.GetLog(i, &entry); err != nil {
+			if err == raft.ErrLogNotFound {
+				continue
+			}
+			return nil, err
+		}
+		s.size.Add(entrySize(&entry))
+	}
+	observability.RaftLogBytes.Set(float64(s.size.Load()))
+	return s, nil
+}
+
+func entrySize(l *raft.Log) int64 {
+	return int64(len(l.Data) + len(l.Extensions))
+}
+
+func (s *sizedLogStore) StoreLog(l *raft.Log) error {
+	return s.StoreLogs([]*raft.Log{l})
+}
+
+func (s *sizedLogStore) StoreLogs(logs []*raft.Log) error {
+	if err := s.LogStore.StoreLogs(logs); err != nil {
+		return err
+	}
+	var n int64
+	for _, l := range logs {
+		n += entrySize(l)
+	}
+	size := s.size.Add(n)
+	observability.RaftLogBytes.Set(float64(size))
+	if full := s.full.Load(); full != nil {
+		if max := s.maxBytes.Load(); max > 0 && size >= max {
+			(*full)()
+		}
+	}
+	return nil
+}
+
+func (s *sizedLogStore) DeleteRange(min, max uint64) error {
+	first, err := s.FirstIndex()
+	if err != nil {
+		return err
+	}
+	last, err := s.LastIndex()
+	if err != nil {
+		return err
+	}
+	if err := s.LogStore.DeleteRange(min, max); err != nil {
+		return err
+	}
+	if first == 0 || last < first {
+		return nil
+	}
+	min, max = clamp(min, first, last), clamp(max, first, last)
+	if max < min {
+		return nil
+	}
+	total, deleted := last-first+1, max-min+1
+	for {
+		size := s.size.Load()
+		next := int64(0)
+		if deleted < total {
+			next = size - int64(float64(size)*float64(deleted)/float64(total))
+		}
+		if s.size.CompareAndSwap(size, next) {
+			observability.RaftLogBytes.Set(float64(next))
+			return nil
+		}
+	}
+}
+
+func clamp(v, lo, hi uint64) uint64 {
+	return min(max(v, lo), hi)
+}
@@ -0,0 +1,258 @@
+package consensus
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+)
+
+// dedupWindow remembers recently applied request IDs so that retried writes are
+// applied exactly once.
+//
+// Every replica must make the same decision for the same log entry, so expiry
+// is driven by the leader-assigned raft.Log.AppendedAt rather than the local
+// clock, and the window is carried in FSM snapshots.
+type dedupWindow struct {
+	window     time.Duration
+	maxEntries int
+	seen       map[string]struct{}
+	order      []dedupEntry // in apply order, oldest first
+}
+
+type dedupEntry struct {
+	id string
+	at int64 // AppendedAt in Unix nanoseconds
+}
+
+func newDedupWindow(window time.Duration, maxEntries int) *dedupWindow {
+	return &dedupWindow{window: window, maxEntries: maxEntries, seen: make(map[string]struct{})}
+}
+
+// contains reports whether id was applied within the window as of appendedAt.
+func (d *dedupWindow) contains(id string, appendedAt time.Time) bool {
+	// Entries from old Raft versions carry no timestamp; they are only bounded by maxEntries.
+	if !appendedAt.IsZero() {
+		d.prune(appendedAt.UnixNano())
+	}
+	_, ok := d.seen[id]
+	return ok
+}
+
+// record remembers id as applied. Only writes that took effect are recorded, so
+// a retry of a rejected conditional write is evaluated again.
+func (d *dedupWindow) record(id string, appendedAt time.Time) {
+	var at int64
+	if !appendedAt.IsZero() {
+		at = appendedAt.UnixNano()
+	}
+	d.seen[id] = struct{}{}
+	d.order = append(d.order, dedupEntry{id: id, at: at})
+	if len(d.order) > d.maxEntries {
+		d.evict(len(d.order) - d.maxEntries)
+	}
+}
+
+func (d *dedupWindow) prune(now int64) {
+	cutoff := now - d.window.Nanoseconds()
+	n := 0
+	for n < len(d.order) && d.order[n].at < cutoff {
+		n++
+	}
+	d.evict(n)
+}
+
+func (d *dedupWindow) evict(n int) {
+	for _, e := range d.order[:n] {
+		delete(d.seen, e.id)
+	}
+	d.order = d.order[n:]
+}
+
+func (d *dedupWindow) clone() []dedupEntry {
+	return append([]dedupEntry(nil), d.order...)
+}
+
+func (d *dedupWindow) reset(entries []dedupEntry) {
+	d.seen = make(map[string]struct{}, len(entries))
+	d.order = entries
+	for _, e := range entries {
+		d.seen[e.id] = struct{}{}
+	}
+}
+
+// FSM snapshot framing
+//
+// FSM snapshots prefix the store snapshot with FSM-level state:
+//
+//	magic "DCFSM" | version (uvarint) | [cluster version (uvarint)] | entry count (uvarint) | entries | [tombstone count (uvarint) | tombstones] | [member count (uvarint) | members] | store snapshot
+//	entry: id length (uvarint) | id | appended at (varint, Unix nanoseconds)
+//	tombstone: key length (uvarint) | key | deleted at (varint, Unix nanoseconds)
+//	member: id length (uvarint) | id | gRPC address length (uvarint) | gRPC address
+//
+// The cluster version is only present from version 2, tombstones from
+// version 3 and members from version 4. Each snapshot is written in the oldest
+// version that holds its state, so that a cluster at command version 0 writes
+// version 1, which older nodes can read, and one without tombstones or
+// members writes version 2. Snapshots
+// without the prefix (older nodes, or backups of the store alone) are passed
+// to the store unchanged with an empty dedup window.
+const (
+	fsmSnapshotMagic   = "DCFSM"
+	fsmSnapshotVersion = 4
+)
+
+// fsmHeader is the FSM-level state carried in a snapshot.
+type fsmHeader struct {
+	dedup          []dedupEntry
+	clusterVersion uint32
+	tombstones     []tombstone
+	members        []memberAddr
+}
+
+func writeFSMHeader(w *bufio.Writer, h fsmHeader) error {
+	var buf [binary.MaxVarintLen64]byte
+	w.WriteString(fsmSnapshotMagic)
+	var version uint64
+	switch {
+	case len(h.members) > 0:
+		version = 4
+	case len(h.tombstones) > 0:
+		version = 3
+	case h.clusterVersion != 0:
+		version = 2
+	default:
+		version = 1
+	}
+	writeString := func(s string) {
+		w.Write(buf[:binary.PutUvarint(buf[:], uint64(len(s)))])
+		w.WriteString(s)
+	}
+	w.Write(buf[:binary.PutUvarint(buf[:], version)])
+	if version >= 2 {
+		w.Write(buf[:binary.PutUvarint(buf[:], uint64(h.clusterVersion))])
+	}
+	w.Write(buf[:binary.PutUvarint(buf[:], uint64(len(h.dedup)))])
+	for _, e := range h.dedup {
+		writeString(e.id)
+		w.Write(buf[:binary.PutVarint(buf[:], e.at)])
+	}
+	if version >= 3 {
+		w.Write(buf[:binary.PutUvarint(buf[:], uint64(len(h.tombstones)))])
+		for _, t := range h.tombstones {
+			writeString(t.key)
+			w.Write(buf[:binary.PutVarint(buf[:], t.at)])
+		}
+	}
+	if version >= 4 {
+		w.Write(buf[:binary.PutUvarint(buf[:], uint64(len(h.members)))])
+		for _, m := range h.members {
+			writeString(m.id)
+			writeString(m.addr)
+		}
+	}
+	return w.Flush()
+}
+
+// readFSMHeader consumes the FSM prefix if present and returns the state it
+// holds, which is empty if the snapshot has none.
+func readFSMHeader(r *bufio.Reader) (fsmHeader, error) {
+	var h fsmHeader
+	head, err := r.Peek(len(fsmSnapshotMagic))
+	if err != nil && !errors.Is(err, io.EOF) {
+		return h, err
+	}
+	if string(head) != fsmSnapshotMagic {
+		return h, nil
+	}
+	r.Discard(len(fsmSnapshotMagic))
+
+	version, err := binary.ReadUvarint(r)
+	if err != nil {
+		return h, fmt.Errorf("read fsm snapshot version: %w", err)
+	}
+	if version > fsmSnapshotVersion {
+		return h, fmt.Errorf("unsupported fsm snapshot version %d (max %d)", version, fsmSnapshotVersion)
+	}
+	if version >= 2 {
+		clusterVersion, err := binary.ReadUvarint(r)
+		if err != nil {
+			return h, fmt.Errorf("read cluster version: %w", err)
+		}
+		h.clusterVersion = uint32(clusterVersion)
+	}
+	count, err := binary.ReadUvarint(r)
+	if err != nil {
+		return h, fmt.Errorf("read dedup window: %w", err)
+	}
+	for i := uint64(0); i < count; i++ {
+		id, at, err := readHeaderEntry(r)
+		if err != nil {
+			return h, fmt.Errorf("read dedup window: %w", err)
+		}
+		h.dedup = append(h.dedup, dedupEntry{id: id, at: at})
+	}
+	if version >= 3 {
+		count, err := binary.ReadUvarint(r)
+		if err != nil {
+			return h, fmt.Errorf("read tombstones: %w", err)
+		}
+		for i := uint64(0); i < count; i++ {
+			key, at, err := readHeaderEntry(r)
+			if err != nil {
+				return h, fmt.Errorf("read tombstones: %w", err)
+			}
+			h.tombstones = append(h.tombstones, tombstone{key: key, at: at})
+		}
+	}
+	if version >= 4 {
+		count, err := binary.ReadUvarint(r)
+		if err != nil {
+			return h, fmt.Errorf("read members: %w", err)
+		}
+		for i := uint64(0); i < count; i++ {
+			id, err := readHeaderString(r)
+			if err != nil {
+				return h, fmt.Errorf("read members: %w", err)
+			}
+			addr, err := readHeaderString(r)
+			if err != nil {
+				return h, fmt.Errorf("read members: %w", err)
+			}
+			h.members = append(h.members, memberAddr{id: id, addr: addr})
+		}
+	}
+	return h, nil
+}
+
+// readHeaderEntry reads a string and a timestamp, the form of both dedup
+// entries and tombstones.
+func readHeaderEntry(r *bufio.Reader) (string, int64, error) {
+	s, err := readHeaderString(r)
+	if err != nil {
+		return "", 0, err
+	}
+	at, err := binary.ReadVarint(r)
+	if err != nil {
+		return "", 0, err
+	}
+	return s, at, nil
+}
+
+// readHeaderString reads a length-prefixed string.
+func readHeaderString(r *bufio.Reader) (string, error) {
+	n, err := binary.ReadUvarint(r)
+	if err != nil {
+		return "", err
+	}
+	if n > 1<<16 {
+		return "", fmt.Errorf("length %d exceeds limit", n)
+	}
+	s := make([]byte, n)
+	if _, err := io.ReadFull(r, s); err != nil {
+		return "", err
+	}
+	return string(s), nil
+}
@@ -1,12 +1,22 @@
 package consensus
 
 import (
+	"bufio"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	stdlog "log"
+	"sync/atomic"
+	"time"
 
+	coreerrors "distributed-cache-service/internal/core/errors"
+	"distributed-cache-service/internal/core/ports"
 	"distributed-cache-service/internal/core/service"
-	"distributed-cache-service/internal/store"
+	"distributed-cache-service/internal/encryption"
+	"distributed-cache-service/internal/events"
+	"distributed-cache-service/internal/observability"
+	"distributed-cache-service/internal/writebehind"
 
 	"github.com/hashicorp/raft"
 )
@@ -16,59 +26,495 @@ import (
 // It is responsible for applying committed log entries to the underlying key-value store
 // and managing snapshots of the state.
 type FSM struct {
-	store *store.Store
+	store       ports.SnapshotStorage
+	events      *events.Broker
+	writeBehind []*writebehind.Queue
+	dedup       *dedupWindow
+	tombstones  tombstones
+	cipher      *encryption.Cipher
+	witness     bool
+	// clusterVersion is the replicated command version (see
+	// service.MaxCommandVersion), read by the service outside the FSM goroutine.
+	clusterVersion atomic.Uint32
+	// members maps member IDs to their advertised gRPC addresses (see
+	// MemberAddr), read outside the FSM goroutine.
+	members atomic.Pointer[map[string]string]
+	timings applyTimings
+}
+
+// Default dedup window for commands carrying a request ID.
+const (
+	DefaultDedupWindow = 5 * time.Minute
+	maxDedupEntries    = 100000
+)
+
+// WithDedupWindow sets how long request IDs are remembered for deduplicating
+// retried writes. A window <= 0 disables deduplication. All nodes of a cluster
+// must use the same window, or their state can diverge.
+func WithDedupWindow(window time.Duration) FSMOption {
+	return func(f *FSM) {
+		f.dedup = nil
+		if window > 0 {
+			f.dedup = newDedupWindow(window, maxDedupEntries)
+		}
+	}
+}
+
+// FSMOption configures optional FSM behaviour.
+type FSMOption func(*FSM)
+
+// WithEvents publishes every applied change to b, feeding the keyspace event stream.
+func WithEvents(b *events.Broker) FSMOption {
+	return func(f *FSM) {
+		f.events = b
+	}
+}
+
+// WithWriteBehind enqueues every applied mutation on q for delivery to an external system.
+// It may be given several times, e.g. for a write-behind sink and a CDC export.
+func WithWriteBehind(q *writebehind.Queue) FSMOption {
+	return func(f *FSM) {
+		f.writeBehind = append(f.writeBehind, q)
+	}
+}
+
+// WithEncryption opens and seals values the FSM rewrites itself, such as the
+// result of APPEND, with c. It must hold the same keys as the leader's service.
+func WithEncryption(c *encryption.Cipher) FSMOption {
+	return func(f *FSM) {
+		f.cipher = c
+	}
+}
+
+// WithWitness makes the FSM a witness's: it applies no entries and keeps no
+// data, so that the node votes in elections without holding a replica. Its
+// snapshots are empty and marked as a witness's, so that a data node never
+// restores one should the witness lead for a moment; see RaftNode.
+func WithWitness() FSMOption {
+	return func(f *FSM) {
+		f.witness = true
+	}
 }
 
 // NewFSM creates a new FSM instance backed by the provided store.
-func NewFSM(s *store.Store) *FSM {
-	return &FSM{
-		store: s,
+// Any ports.SnapshotStorage backend (in-memory or on-disk) can be used.
+func NewFSM(s ports.SnapshotStorage, opts ...FSMOption) *FSM {
+	f := &FSM{
+		store:      s,
+		dedup:      newDedupWindow(DefaultDedupWindow, maxDedupEntries),
+		tombstones: make(tombstones),
 	}
+	for _, opt := range opts {
+		opt(f)
+	}
+	return f
 }
 
 // Apply applies a committed Raft log entry to the key-value store.
 // It unmarshals the command (Set/Delete) and executes it against the backend store.
 // This method is invoked by the Raft leader after consensus is reached.
 func (f *FSM) Apply(log *raft.Log) interface{} {
+	started := time.Now()
+	defer func() {
+		f.timings.record(applyTiming{index: log.Index, appended: log.AppendedAt, started: started, finished: time.Now()})
+		observability.RaftAppliedIndex.Set(float64(log.Index))
+	}()
+	if f.witness {
+		return nil
+	}
+
 	var c service.Command
-	if err := json.Unmarshal(log.Data, &c); err != nil {
+	if err := service.DecodeCommand(log.Data, &c); err != nil {
 		return fmt.Errorf("failed to unmarshal command: %w", err)
 	}
+	if c.Version > service.MaxCommandVersion {
+		// The cluster was moved to a version this node does not know: it
+		// cannot keep its state in step with the others until upgraded.
+		stdlog.Printf("Raft entry %d is at command version %d, newer than this node's %d: upgrade the node", log.Index, c.Version, service.MaxCommandVersion)
+		return fmt.Errorf("%w: command version %d is newer than this node's %d", coreerrors.ErrUnsupported, c.Version, service.MaxCommandVersion)
+	}
+	upgradeCommand(&c, log)
+	if c.Op == service.BatchOp {
+		// Each command of a batch is applied, and answered, as though it were
+		// an entry of its own.
+		results := make(service.BatchResult, len(c.Batch))
+		for i := range c.Batch {
+			if c.Batch[i].Op == service.BatchOp {
+				results[i] = fmt.Errorf("%w: batches cannot be nested", coreerrors.ErrInvalidArgument)
+				continue
+			}
+			results[i] = f.apply(&c.Batch[i], log)
+		}
+		return results
+	}
+	return f.apply(&c, log)
+}
+
+// apply applies a decoded command, or one command of a batch, and
+// returns its ApplyResult or the error it was rejected with.
+func (f *FSM) apply(c *service.Command, log *raft.Log) interface{} {
+	// A retried write that already committed is acknowledged without re-applying it.
+	dedup := c.RequestID != "" && f.dedup != nil
+	if dedup && f.dedup.contains(c.RequestID, log.AppendedAt) {
+		observability.DuplicateCommandsTotal.Inc()
+		return nil
+	}
+	// Whether a key has expired is judged at the entry's timestamp rather
+	// than by this node's clock, so that every replica reaches the same
+	// result.
+	now := appliedAt(log)
+	if err := f.checkPrecondition(c, now); err != nil {
+		return err
+	}
+	if err := f.checkTombstone(c); err != nil {
+		return err
+	}
 
+	var result service.ApplyResult
+	if c.Op == service.GetSetOp || c.Op == service.GetDelOp || c.Op == service.GetOrSetOp {
+		result.Previous, result.Found = f.current(c.Key, now)
+	}
+
+	// Sinks and watchers only see the resulting SET or DELETE.
+	var op service.CommandType
+	stored := c.StoredValue()
 	switch c.Op {
-	case service.SetOp:
-		f.store.Set(c.Key, c.Value, c.TTL)
-	case service.DeleteOp:
+	case service.SetOp, service.GetSetOp, service.GetOrSetOp:
+		if c.Op == service.GetOrSetOp && result.Found {
+			// The key exists: nothing is written, published or enqueued.
+			break
+		}
+		// The log index is the key's version: unique and increasing, and the same on every node.
+		f.store.SetExpiresAt(c.Key, service.EncodeVersion(log.Index, stored), c.Expiry())
+		f.publish(events.Set, c.Key, log.Index)
+		result.Version = log.Index
+		op = service.SetOp
+	case service.AppendOp:
+		var err error
+		suffix := c.Value
+		if c.Compressed != nil {
+			if suffix, err = f.cipher.Decode(string(c.Compressed)); err != nil {
+				return err
+			}
+		}
+		if stored, result.Length, err = f.appendValue(c.Key, suffix, log.Index, now); err != nil {
+			return err
+		}
+		f.publish(events.Set, c.Key, log.Index)
+		result.Version = log.Index
+		op = service.SetOp
+	case service.ZAddOp, service.ZRemRangeByScoreOp:
+		var err error
+		if stored, result.Count, err = f.applySortedSet(c, now); err != nil {
+			return err
+		}
+		f.publish(events.Set, c.Key, log.Index)
+		op = c.Op
+	case service.DeleteOp, service.GetDelOp:
+		if c.OriginTime != 0 {
+			// A delete of an absent key still refuses older writes.
+			f.tombstones.record(c.Key, c.OriginTime)
+		}
 		f.store.Delete(c.Key)
+		if c.Op == service.GetDelOp && !result.Found {
+			// Nothing was removed: nothing is published or enqueued.
+			break
+		}
+		f.publish(events.Delete, c.Key, log.Index)
+		op = service.DeleteOp
+	case service.ExpireOp, service.PersistOp:
+		// The value, and so the version, is unchanged; watchers still see a SET
+		// so that cached copies pick up the new expiration.
+		if !f.store.ExpireAt(c.Key, c.Expiry(), now) {
+			return coreerrors.ErrNotFound
+		}
+		f.publish(events.Set, c.Key, log.Index)
+		op = c.Op
+	case service.PurgeOp:
+		// Like a script, a purge publishes and enqueues each delete itself.
+		result.Count = f.purge(c.Keys, time.Unix(0, c.ExpiresAt), log)
+	case service.EvictOp:
+		var err error
+		if result.Count, err = f.evict(c.Keys, log); err != nil {
+			return err
+		}
+	case service.TxnOp:
+		// Like a script, a transaction publishes and enqueues each write itself.
+		var err error
+		if result.Txn, err = f.applyTxn(c.Txn, log, now); err != nil {
+			return err
+		}
+	case service.EvalOp:
+		// A script publishes and enqueues each of its writes itself.
+		var err error
+		if result.Reply, err = f.eval(c, log); err != nil {
+			return err
+		}
+	case service.MemberOp:
+		f.setMemberAddr(c.Key, c.Value)
+	case service.TombstoneGCOp:
+		result.Count = f.tombstones.gc(c.OriginTime)
+	case service.ClusterVersionOp:
+		if c.Version > f.clusterVersion.Load() {
+			f.setClusterVersion(c.Version)
+		}
 	default:
 		return fmt.Errorf("unknown command op: %s", c.Op)
 	}
+	if dedup {
+		f.dedup.record(c.RequestID, log.AppendedAt)
+	}
+	if op != "" {
+		f.enqueue(op, c.Key, stored, c.TTL, log)
+	}
+	return result
+}
+
+// appliedAt returns the time at which an entry takes effect: when the leader
+// appended it, or this node's clock for entries appended before Raft recorded
+// timestamps.
+func appliedAt(log *raft.Log) time.Time {
+	if log.AppendedAt.IsZero() {
+		return time.Now()
+	}
+	return log.AppendedAt
+}
+
+// upgradeCommand brings a command written at an older version up to the
+// current schema.
+func upgradeCommand(c *service.Command, log *raft.Log) {
+	if c.Version != service.CommandVersionLegacy {
+		return
+	}
+	// Early legacy leaders sent only the TTL, which each node counted from its
+	// own apply. Counting it from when the leader appended the entry instead
+	// expires the key at the same moment on every replica and every replay.
+	var stamp func(c *service.Command)
+	stamp = func(c *service.Command) {
+		if c.ExpiresAt == 0 && c.TTL > 0 && !log.AppendedAt.IsZero() {
+			c.ExpiresAt = log.AppendedAt.Add(c.TTL).UnixNano()
+		}
+		if c.Txn != nil {
+			for _, ops := range [][]service.Command{c.Txn.Success, c.Txn.Failure} {
+				for i := range ops {
+					stamp(&ops[i])
+				}
+			}
+		}
+	}
+	stamp(c)
+}
+
+// ClusterVersion returns the command version the cluster writes at, as
+// replicated through the log.
+func (f *FSM) ClusterVersion() uint32 {
+	return f.clusterVersion.Load()
+}
+
+func (f *FSM) setClusterVersion(v uint32) {
+	f.clusterVersion.Store(v)
+	observability.ClusterCommandVersion.Set(float64(v))
+}
+
+// purge deletes those of keys that had expired by now. The leader chose now and
+// expirations are absolute, so every replica removes the same keys.
+func (f *FSM) purge(keys []string, now time.Time, log *raft.Log) int {
+	n := 0
+	for _, key := range keys {
+		if !f.store.DeleteExpired(key, now) {
+			continue
+		}
+		f.publish(events.Delete, key, log.Index)
+		f.enqueue(service.DeleteOp, key, "", 0, log)
+		n++
+	}
+	observability.ExpiredKeysTotal.Add(float64(n))
+	return n
+}
+
+// evict deletes keys chosen by the leader's eviction policy. Watchers see the
+// deletes, but write-behind sinks do not: evicting a key drops it from the
+// cache, not from the system of record.
+func (f *FSM) evict(keys []string, log *raft.Log) (int, error) {
+	es, ok := f.store.(ports.EvictionStorage)
+	if !ok {
+		return 0, fmt.Errorf("eviction: %w by this storage backend", coreerrors.ErrUnsupported)
+	}
+	n := 0
+	for _, key := range keys {
+		if !es.Evict(key) {
+			continue
+		}
+		f.publish(events.Delete, key, log.Index)
+		n++
+	}
+	observability.EvictionsTotal.Add(float64(n))
+	return n, nil
+}
+
+// enqueue hands an applied mutation to every write-behind queue.
+func (f *FSM) enqueue(op service.CommandType, key, stored string, ttl time.Duration, log *raft.Log) {
+	for _, q := range f.writeBehind {
+		q.Enqueue(writebehind.Mutation{
+			Op:        string(op),
+			Key:       key,
+			Value:     stored,
+			TTLMillis: ttl.Milliseconds(),
+			Index:     log.Index,
+			Timestamp: log.AppendedAt,
+		})
+	}
+}
+
+// appendValue appends suffix to key's value, keeping its expiration, and
+// returns the new stored value and its length. A key missing at now is created
+// without expiration. The result is stored uncompressed, so repeated appends do
+// not recompress the whole value each time, though it is encrypted if
+// configured.
+func (f *FSM) appendValue(key, suffix string, version uint64, now time.Time) (string, int, error) {
+	prev, found := f.current(key, now)
+	value, err := f.cipher.Decode(prev)
+	if err != nil {
+		return "", 0, err
+	}
+	value += suffix
+	stored := f.cipher.Escape(value)
+	if !found || !f.store.Replace(key, service.EncodeVersion(version, stored), now) {
+		f.store.Set(key, service.EncodeVersion(version, stored), 0)
+	}
+	return stored, len(value), nil
+}
+
+// applySortedSet runs a sorted set command and returns its JSON-encoded
+// arguments, which is what write-behind sinks receive as the value.
+func (f *FSM) applySortedSet(c *service.Command, now time.Time) (string, int, error) {
+	zs, ok := f.store.(ports.SortedSetStorage)
+	if !ok {
+		return "", 0, fmt.Errorf("sorted sets: %w by this storage backend", coreerrors.ErrUnsupported)
+	}
+	var (
+		n    int
+		err  error
+		args interface{}
+	)
+	if c.Op == service.ZAddOp {
+		n, err = zs.ZAdd(c.Key, now, c.Members...)
+		args = c.Members
+	} else {
+		n, err = zs.ZRemRangeByScore(c.Key, c.Min, c.Max, now)
+		args = map[string]float64{"min": c.Min, "max": c.Max}
+	}
+	if err != nil {
+		return "", 0, err
+	}
+	data, err := json.Marshal(args)
+	return string(data), n, err
+}
+
+// current returns the stored (possibly compressed) value of key at now,
+// without its version.
+func (f *FSM) current(key string, now time.Time) (string, bool) {
+	raw, found := f.store.GetAt(key, now)
+	if !found {
+		return "", false
+	}
+	_, stored := service.DecodeVersion(raw)
+	return stored, true
+}
+
+// checkPrecondition evaluates a conditional write against the key's version at
+// now, the entry's timestamp, so that every node accepts or rejects it alike.
+func (f *FSM) checkPrecondition(c *service.Command, now time.Time) error {
+	if c.IfVersion == 0 && !c.IfAbsent {
+		return nil
+	}
+	raw, found := f.store.GetAt(c.Key, now)
+	if !found {
+		if c.IfVersion != 0 {
+			return fmt.Errorf("%w: key does not exist", coreerrors.ErrVersionMismatch)
+		}
+		return nil
+	}
+	version, _ := service.DecodeVersion(raw)
+	if c.IfAbsent || version != c.IfVersion {
+		return fmt.Errorf("%w: key is at version %d", coreerrors.ErrVersionMismatch, version)
+	}
 	return nil
 }
 
-// Snapshot returns a snapshot object
-func (f *FSM) Snapshot() (raft.FSMSnapshot, error) {
+func (f *FSM) publish(t events.Type, key string, index uint64) {
+	if f.events != nil {
+		f.events.Publish(events.Event{Type: t, Key: key, Index: index})
+	}
+}
 
-	// In a real system, we might want to copy the map efficiently.
-	// For now, we rely on the store's Snapshot method which locks the store.
-	return &Snapshot{store: f.store}, nil
+// Snapshot captures a point-in-time view of the store.
+// Raft calls Snapshot on the FSM goroutine and Persist concurrently with Apply,
+// so only the cheap copy happens here; serialization happens in Persist without
+// holding the store lock.
+func (f *FSM) Snapshot() (raft.FSMSnapshot, error) {
+	if f.witness {
+		return witnessSnapshot{}, nil
+	}
+	snap := &Snapshot{view: f.store.PointInTime()}
+	snap.header.clusterVersion = f.clusterVersion.Load()
+	snap.header.tombstones = f.tombstones.clone()
+	snap.header.members = f.cloneMembers()
+	if f.dedup != nil {
+		snap.header.dedup = f.dedup.clone()
+	}
+	return snap, nil
 }
 
 // Restore restores the key-value store from a snapshot.
 func (f *FSM) Restore(rc io.ReadCloser) error {
 	defer rc.Close()
-	return f.store.Restore(rc)
+	if f.witness {
+		_, err := io.Copy(io.Discard, rc)
+		return err
+	}
+	r := bufio.NewReader(rc)
+	if head, _ := r.Peek(len(witnessSnapshotMagic)); string(head) == witnessSnapshotMagic {
+		return errors.New("refusing to restore a witness's snapshot, which holds no data")
+	}
+	header, err := readFSMHeader(r)
+	if err != nil {
+		return err
+	}
+	// Snapshots without a version, such as backups of the store alone, leave it
+	// unchanged: versions only increase.
+	if header.clusterVersion > f.clusterVersion.Load() {
+		f.setClusterVersion(header.clusterVersion)
+	}
+	if err := f.store.Restore(r); err != nil {
+		return err
+	}
+	if f.dedup != nil {
+		f.dedup.reset(header.dedup)
+	}
+	f.tombstones.reset(header.tombstones)
+	// Member addresses describe the cluster rather than its data: backups and
+	// snapshots without any keep those already known.
+	if header.members != nil {
+		f.resetMembers(header.members)
+	}
+	f.publish(events.Flush, "", 0)
+	return nil
 }
 
 // Snapshot implementation
 type Snapshot struct {
-	store *store.Store
+	view   ports.StateView
+	header fsmHeader
 }
 
 func (s *Snapshot) Persist(sink raft.SnapshotSink) error {
 	err := func() error {
-		// Encode data from the store into the sink
-		if err := s.store.Snapshot(sink); err != nil {
+		if err := writeFSMHeader(bufio.NewWriter(sink), s.header); err != nil {
+			return err
+		}
+		// Encode the point-in-time view into the sink
+		if err := s.view.Snapshot(sink); err != nil {
 			return err
 		}
 		return nil
@@ -83,5 +529,21 @@ func (s *Snapshot) Persist(sink raft.SnapshotSink) error {
 }
 
 func (s *Snapshot) Release() {
-	// No-op
+	s.view.Release()
+}
+
+// witnessSnapshotMagic is the whole content of a witness's snapshots.
+const witnessSnapshotMagic = "DCWITNESS"
+
+// witnessSnapshot is the snapshot of a witness's FSM, which holds no data.
+type witnessSnapshot struct{}
+
+func (witnessSnapshot) Persist(sink raft.SnapshotSink) error {
+	if _, err := io.WriteString(sink, witnessSnapshotMagic); err != nil {
+		_ = sink.Cancel()
+		return err
+	}
+	return sink.Close()
 }
+
+func (witnessSnapshot) Release() {}
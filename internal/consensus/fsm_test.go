@@ -1,11 +1,23 @@
 package consensus
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
+	"io"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"distributed-cache-service/internal/compression"
+	coreerrors "distributed-cache-service/internal/core/errors"
+	"distributed-cache-service/internal/core/ports"
 	"distributed-cache-service/internal/core/service"
+	"distributed-cache-service/internal/encryption"
+	"distributed-cache-service/internal/events"
 	"distributed-cache-service/internal/store"
+	"distributed-cache-service/internal/writebehind"
 
 	"github.com/hashicorp/raft"
 	"github.com/stretchr/testify/assert"
@@ -23,13 +35,15 @@ func TestFSM_Apply(t *testing.T) {
 		TTL:   0,
 	}
 	data, _ := json.Marshal(cmdSet)
-	logEntry := &raft.Log{Data: data}
+	logEntry := &raft.Log{Index: 5, Data: data}
 
-	fsm.Apply(logEntry)
+	assert.Equal(t, service.ApplyResult{Version: 5}, fsm.Apply(logEntry))
 
-	val, found := memStore.Get("key1")
+	raw, found := memStore.Get("key1")
 	assert.True(t, found)
+	version, val := service.DecodeVersion(raw)
 	assert.Equal(t, "val1", val)
+	assert.Equal(t, uint64(5), version)
 
 	// Test Delete Command
 	cmdDel := service.Command{
@@ -44,3 +58,790 @@ func TestFSM_Apply(t *testing.T) {
 	_, found = memStore.Get("key1")
 	assert.False(t, found)
 }
+
+// A log written during a rolling upgrade mixes JSON and binary entries.
+func TestFSM_ApplyMixedCommandFormats(t *testing.T) {
+	memStore := store.New()
+	fsm := NewFSM(memStore)
+
+	legacy, err := service.EncodeCommand(&service.Command{Op: service.SetOp, Key: "old", Value: "json"})
+	assert.NoError(t, err)
+	binary, err := service.EncodeCommand(&service.Command{Op: service.SetOp, Key: "new", Value: "proto", Version: service.CommandVersionBinary})
+	assert.NoError(t, err)
+	assert.Equal(t, service.ApplyResult{Version: 1}, fsm.Apply(&raft.Log{Index: 1, Data: legacy}))
+	assert.Equal(t, service.ApplyResult{Version: 2}, fsm.Apply(&raft.Log{Index: 2, Data: binary}))
+
+	for key, want := range map[string]string{"old": "json", "new": "proto"} {
+		raw, found := memStore.Get(key)
+		assert.True(t, found)
+		_, val := service.DecodeVersion(raw)
+		assert.Equal(t, want, val)
+	}
+}
+
+func TestFSM_PublishesEvents(t *testing.T) {
+	broker := events.NewBroker()
+	sub := broker.Subscribe("", 10)
+	defer sub.Close()
+	fsm := NewFSM(store.New(), WithEvents(broker))
+
+	data, _ := json.Marshal(service.Command{Op: service.SetOp, Key: "key1", Value: "val1"})
+	fsm.Apply(&raft.Log{Index: 7, Data: data})
+	data, _ = json.Marshal(service.Command{Op: service.DeleteOp, Key: "key1"})
+	fsm.Apply(&raft.Log{Index: 8, Data: data})
+
+	assert.Equal(t, events.Event{Type: events.Set, Key: "key1", Index: 7}, <-sub.Events())
+	assert.Equal(t, events.Event{Type: events.Delete, Key: "key1", Index: 8}, <-sub.Events())
+}
+
+func TestFSM_SnapshotIsPointInTime(t *testing.T) {
+	memStore := store.New()
+	memStore.Set("key1", "val1", 0)
+	fsm := NewFSM(memStore)
+
+	snap, err := fsm.Snapshot()
+	assert.NoError(t, err)
+
+	// Writes after Snapshot() must not appear in the persisted data
+	memStore.Set("key2", "val2", 0)
+
+	sink := &memorySink{}
+	assert.NoError(t, snap.Persist(sink))
+	snap.Release()
+
+	restored := store.New()
+	assert.NoError(t, NewFSM(restored).Restore(io.NopCloser(&sink.Buffer)))
+	assert.Equal(t, 1, restored.Len())
+}
+
+func applyCommand(f *FSM, index uint64, at time.Time, c service.Command) interface{} {
+	data, _ := json.Marshal(c)
+	return f.Apply(&raft.Log{Index: index, AppendedAt: at, Data: data})
+}
+
+// storedValue returns the value held for key without its version.
+func storedValue(s *store.Store, key string) string {
+	raw, _ := s.Get(key)
+	_, val := service.DecodeVersion(raw)
+	return val
+}
+
+func TestFSM_DeduplicatesRequestIDs(t *testing.T) {
+	memStore := store.New()
+	fsm := NewFSM(memStore, WithDedupWindow(time.Minute))
+	start := time.Unix(1700000000, 0)
+
+	applyCommand(fsm, 1, start, service.Command{Op: service.SetOp, Key: "k", Value: "v1", RequestID: "req-1"})
+	applyCommand(fsm, 2, start, service.Command{Op: service.SetOp, Key: "k", Value: "v2"})
+	// Retry of req-1 after it committed must not clobber v2
+	applyCommand(fsm, 3, start.Add(30*time.Second), service.Command{Op: service.SetOp, Key: "k", Value: "v1", RequestID: "req-1"})
+
+	assert.Equal(t, "v2", storedValue(memStore, "k"))
+
+	// The dedup window survives a snapshot/restore cycle
+	snap, err := fsm.Snapshot()
+	assert.NoError(t, err)
+	sink := &memorySink{}
+	assert.NoError(t, snap.Persist(sink))
+	follower := NewFSM(store.New(), WithDedupWindow(time.Minute))
+	assert.NoError(t, follower.Restore(io.NopCloser(&sink.Buffer)))
+	assert.True(t, follower.dedup.contains("req-1", start.Add(40*time.Second)))
+
+	// Outside the window the ID is applied again
+	applyCommand(fsm, 4, start.Add(2*time.Minute), service.Command{Op: service.SetOp, Key: "k", Value: "v3", RequestID: "req-1"})
+	assert.Equal(t, "v3", storedValue(memStore, "k"))
+}
+
+func TestFSM_ConditionalWrites(t *testing.T) {
+	memStore := store.New()
+	fsm := NewFSM(memStore)
+
+	// IfAbsent creates the key once.
+	assert.Equal(t, service.ApplyResult{Version: 1},
+		applyCommand(fsm, 1, time.Time{}, service.Command{Op: service.SetOp, Key: "k", Value: "v1", IfAbsent: true}))
+	resp := applyCommand(fsm, 2, time.Time{}, service.Command{Op: service.SetOp, Key: "k", Value: "v2", IfAbsent: true})
+	assert.ErrorIs(t, resp.(error), coreerrors.ErrVersionMismatch)
+
+	// IfVersion succeeds only against the current version.
+	resp = applyCommand(fsm, 3, time.Time{}, service.Command{Op: service.SetOp, Key: "k", Value: "v3", IfVersion: 2})
+	assert.ErrorIs(t, resp.(error), coreerrors.ErrVersionMismatch)
+	assert.Equal(t, service.ApplyResult{Version: 4},
+		applyCommand(fsm, 4, time.Time{}, service.Command{Op: service.SetOp, Key: "k", Value: "v4", IfVersion: 1}))
+	assert.Equal(t, "v4", storedValue(memStore, "k"))
+
+	// Conditional deletes too.
+	resp = applyCommand(fsm, 5, time.Time{}, service.Command{Op: service.DeleteOp, Key: "k", IfVersion: 1})
+	assert.ErrorIs(t, resp.(error), coreerrors.ErrVersionMismatch)
+	applyCommand(fsm, 6, time.Time{}, service.Command{Op: service.DeleteOp, Key: "k", IfVersion: 4})
+	_, found := memStore.Get("k")
+	assert.False(t, found)
+	resp = applyCommand(fsm, 7, time.Time{}, service.Command{Op: service.SetOp, Key: "k", Value: "v", IfVersion: 4})
+	assert.ErrorIs(t, resp.(error), coreerrors.ErrVersionMismatch)
+}
+
+// skewedStore is a store on a node whose clock is off by skew: its Get judges
+// expiry by that clock.
+type skewedStore struct {
+	*store.Store
+	skew time.Duration
+}
+
+func (s skewedStore) Get(key string) (string, bool) {
+	return s.GetAt(key, time.Now().Add(s.skew))
+}
+
+func TestFSM_ConditionalWritesWithSkewedClocks(t *testing.T) {
+	// The same entries applied by two nodes around the key's expiry, one
+	// with a clock an hour behind the leader's and one an hour ahead. Each
+	// precondition is judged at the entry's timestamp, so both agree.
+	leader := time.Now()
+	type entry struct {
+		at time.Duration
+		c  service.Command
+	}
+	entries := []entry{
+		{0, service.Command{Op: service.SetOp, Key: "k", Value: "v1", ExpiresAt: leader.Add(time.Second).UnixNano()}},
+		{500 * time.Millisecond, service.Command{Op: service.SetOp, Key: "k", Value: "v2", IfVersion: 1, ExpiresAt: leader.Add(2 * time.Second).UnixNano()}},
+		{time.Second, service.Command{Op: service.SetOp, Key: "k", Value: "v3", IfAbsent: true}},
+		{3 * time.Second, service.Command{Op: service.SetOp, Key: "k", Value: "v4", IfVersion: 2}},
+		{3 * time.Second, service.Command{Op: service.SetOp, Key: "k", Value: "v5", IfAbsent: true}},
+	}
+
+	var results [2][]interface{}
+	var stores [2]skewedStore
+	for n, skew := range []time.Duration{-time.Hour, time.Hour} {
+		stores[n] = skewedStore{store.New(), skew}
+		fsm := NewFSM(stores[n])
+		for i, e := range entries {
+			results[n] = append(results[n], applyCommand(fsm, uint64(i+1), leader.Add(e.at), e.c))
+		}
+	}
+	assert.Equal(t, service.ApplyResult{Version: 2}, results[0][1])
+	for _, i := range []int{2, 3} {
+		err, _ := results[0][i].(error)
+		assert.ErrorIs(t, err, coreerrors.ErrVersionMismatch)
+	}
+	assert.Equal(t, service.ApplyResult{Version: 5}, results[0][4])
+	assert.Equal(t, results[0], results[1])
+
+	raw, _, _ := stores[0].GetStale("k")
+	other, _, _ := stores[1].GetStale("k")
+	assert.Equal(t, raw, other)
+}
+
+func TestFSM_RejectedWriteIsNotDeduplicated(t *testing.T) {
+	memStore := store.New()
+	fsm := NewFSM(memStore, WithDedupWindow(time.Minute))
+	start := time.Unix(1700000000, 0)
+
+	applyCommand(fsm, 1, start, service.Command{Op: service.SetOp, Key: "k", Value: "v1"})
+	resp := applyCommand(fsm, 2, start, service.Command{Op: service.SetOp, Key: "k", Value: "v2", IfVersion: 9, RequestID: "req-1"})
+	assert.ErrorIs(t, resp.(error), coreerrors.ErrVersionMismatch)
+
+	// A retry is evaluated again rather than acknowledged as a duplicate.
+	resp = applyCommand(fsm, 3, start, service.Command{Op: service.SetOp, Key: "k", Value: "v2", IfVersion: 9, RequestID: "req-1"})
+	assert.ErrorIs(t, resp.(error), coreerrors.ErrVersionMismatch)
+	assert.Equal(t, "v1", storedValue(memStore, "k"))
+}
+
+func TestFSM_Batch(t *testing.T) {
+	memStore := store.New()
+	fsm := NewFSM(memStore, WithDedupWindow(time.Minute))
+	start := time.Unix(1700000000, 0)
+	applyCommand(fsm, 1, start, service.Command{Op: service.SetOp, Key: "k", Value: "v1", RequestID: "req-1"})
+
+	// Each command is answered on its own; a rejected one does not stop the rest.
+	resp := applyCommand(fsm, 2, start, service.Command{Op: service.BatchOp, Batch: []service.Command{
+		{Op: service.SetOp, Key: "a", Value: "1"},
+		{Op: service.SetOp, Key: "k", Value: "v2", IfVersion: 9},
+		{Op: service.SetOp, Key: "k", Value: "v1", RequestID: "req-1"},
+		{Op: service.DeleteOp, Key: "b"},
+		{Op: service.BatchOp},
+	}})
+	results, ok := resp.(service.BatchResult)
+	if !assert.True(t, ok, "expected a BatchResult, got %v", resp) || !assert.Len(t, results, 5) {
+		return
+	}
+	assert.Equal(t, service.ApplyResult{Version: 2}, results[0])
+	assert.ErrorIs(t, results[1].(error), coreerrors.ErrVersionMismatch)
+	assert.Nil(t, results[2], "expected the retry to be deduplicated")
+	assert.Equal(t, service.ApplyResult{}, results[3])
+	assert.ErrorIs(t, results[4].(error), coreerrors.ErrInvalidArgument)
+	assert.Equal(t, "1", storedValue(memStore, "a"))
+	assert.Equal(t, "v1", storedValue(memStore, "k"))
+}
+
+func TestFSM_RestoresStoreOnlySnapshot(t *testing.T) {
+	src := store.New()
+	src.Set("key1", "val1", 0)
+	var buf bytes.Buffer
+	assert.NoError(t, src.Snapshot(&buf))
+
+	restored := store.New()
+	assert.NoError(t, NewFSM(restored).Restore(io.NopCloser(&buf)))
+	assert.Equal(t, 1, restored.Len())
+}
+
+func TestFSM_Witness(t *testing.T) {
+	witnessStore := store.New()
+	witness := NewFSM(witnessStore, WithWitness())
+	assert.Nil(t, applyCommand(witness, 1, time.Now(), service.Command{Op: service.SetOp, Key: "k", Value: "v"}))
+	assert.Equal(t, 0, witnessStore.Len())
+
+	// A witness discards the snapshots of data nodes it is sent...
+	src := store.New()
+	src.Set("key1", "val1", 0)
+	snap, err := NewFSM(src).Snapshot()
+	assert.NoError(t, err)
+	sink := &memorySink{}
+	assert.NoError(t, snap.Persist(sink))
+	assert.NoError(t, witness.Restore(io.NopCloser(&sink.Buffer)))
+	assert.Equal(t, 0, witnessStore.Len())
+
+	// ...and a data node refuses the witness's own, which would wipe it.
+	snap, err = witness.Snapshot()
+	assert.NoError(t, err)
+	sink = &memorySink{}
+	assert.NoError(t, snap.Persist(sink))
+	assert.Error(t, NewFSM(src).Restore(io.NopCloser(&sink.Buffer)))
+	assert.Equal(t, 1, src.Len())
+}
+
+// memorySink is an in-memory raft.SnapshotSink for tests.
+type memorySink struct {
+	bytes.Buffer
+}
+
+func (s *memorySink) ID() string    { return "test" }
+func (s *memorySink) Cancel() error { return nil }
+func (s *memorySink) Close() error  { return nil }
+
+func TestFSM_GetSetAndGetDel(t *testing.T) {
+	broker := events.NewBroker()
+	sub := broker.Subscribe("", 10)
+	defer sub.Close()
+	memStore := store.New()
+	sink := &recordingSink{}
+	q := writebehind.New(sink)
+	fsm := NewFSM(memStore, WithEvents(broker), WithWriteBehind(q))
+
+	assert.Equal(t, service.ApplyResult{Version: 1},
+		applyCommand(fsm, 1, time.Time{}, service.Command{Op: service.GetSetOp, Key: "k", Value: "v1"}))
+	assert.Equal(t, service.ApplyResult{Version: 2, Previous: "v1", Found: true},
+		applyCommand(fsm, 2, time.Time{}, service.Command{Op: service.GetSetOp, Key: "k", Value: "v2"}))
+	assert.Equal(t, "v2", storedValue(memStore, "k"))
+
+	assert.Equal(t, service.ApplyResult{Previous: "v2", Found: true},
+		applyCommand(fsm, 3, time.Time{}, service.Command{Op: service.GetDelOp, Key: "k"}))
+	_, found := memStore.Get("k")
+	assert.False(t, found)
+	assert.Equal(t, service.ApplyResult{},
+		applyCommand(fsm, 4, time.Time{}, service.Command{Op: service.GetDelOp, Key: "k"}))
+
+	// Watchers and sinks see plain SET and DELETE events, and nothing for
+	// the GETDEL that found no key.
+	assert.Equal(t, events.Set, (<-sub.Events()).Type)
+	assert.Equal(t, events.Set, (<-sub.Events()).Type)
+	assert.Equal(t, events.Delete, (<-sub.Events()).Type)
+	select {
+	case e := <-sub.Events():
+		t.Errorf("unexpected event %+v", e)
+	default:
+	}
+	q.Close()
+	var ops []string
+	for _, m := range sink.got {
+		ops = append(ops, m.Op)
+	}
+	assert.Equal(t, []string{"SET", "SET", "DELETE"}, ops)
+}
+
+// recordingSink is a writebehind.Sink that keeps what it is delivered.
+type recordingSink struct {
+	mu  sync.Mutex
+	got []writebehind.Mutation
+}
+
+func (s *recordingSink) Deliver(_ context.Context, batch []writebehind.Mutation) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.got = append(s.got, batch...)
+	return nil
+}
+
+func TestFSM_GetOrSet(t *testing.T) {
+	broker := events.NewBroker()
+	sub := broker.Subscribe("", 10)
+	defer sub.Close()
+	memStore := store.New()
+	fsm := NewFSM(memStore, WithEvents(broker))
+	now := time.Now()
+
+	assert.Equal(t, service.ApplyResult{Version: 1},
+		applyCommand(fsm, 1, now, service.Command{Op: service.GetOrSetOp, Key: "k", Value: "v1", TTL: time.Minute, ExpiresAt: now.Add(time.Minute).UnixNano()}))
+	assert.Equal(t, service.ApplyResult{Previous: "v1", Found: true},
+		applyCommand(fsm, 2, now, service.Command{Op: service.GetOrSetOp, Key: "k", Value: "v2"}))
+	assert.Equal(t, "v1", storedValue(memStore, "k"))
+	raw, _ := memStore.Get("k")
+	version, _ := service.DecodeVersion(raw)
+	assert.Equal(t, uint64(1), version)
+	ttl, _ := memStore.TTL("k")
+	assert.Greater(t, ttl, 50*time.Second)
+
+	// Only the write is published.
+	assert.Equal(t, events.Set, (<-sub.Events()).Type)
+	select {
+	case e := <-sub.Events():
+		t.Errorf("unexpected event %+v", e)
+	default:
+	}
+}
+
+func TestFSM_Append(t *testing.T) {
+	memStore := store.New()
+	fsm := NewFSM(memStore)
+
+	assert.Equal(t, service.ApplyResult{Version: 1, Length: 3},
+		applyCommand(fsm, 1, time.Time{}, service.Command{Op: service.AppendOp, Key: "log", Value: "abc"}))
+	assert.Equal(t, service.ApplyResult{Version: 2, Length: 6},
+		applyCommand(fsm, 2, time.Time{}, service.Command{Op: service.AppendOp, Key: "log", Value: "def"}))
+	assert.Equal(t, "abcdef", storedValue(memStore, "log"))
+
+	// Appending to a compressed value decompresses it first.
+	comp := compression.New(compression.Deflate, 0)
+	big := strings.Repeat("x", 200)
+	encoded, compressed := comp.Encode(big)
+	assert.True(t, compressed)
+	applyCommand(fsm, 3, time.Time{}, service.Command{Op: service.SetOp, Key: "big", Compressed: []byte(encoded)})
+	assert.Equal(t, service.ApplyResult{Version: 4, Length: 201},
+		applyCommand(fsm, 4, time.Time{}, service.Command{Op: service.AppendOp, Key: "big", Value: "y"}))
+	val, err := compression.Decode(storedValue(memStore, "big"))
+	assert.NoError(t, err)
+	assert.Equal(t, big+"y", val)
+}
+
+func TestFSM_AppendKeepsTTL(t *testing.T) {
+	memStore := store.New()
+	fsm := NewFSM(memStore)
+
+	applyCommand(fsm, 1, time.Time{}, service.Command{Op: service.SetOp, Key: "k", Value: "a", TTL: 50 * time.Millisecond})
+	applyCommand(fsm, 2, time.Time{}, service.Command{Op: service.AppendOp, Key: "k", Value: "b"})
+	assert.Equal(t, "ab", storedValue(memStore, "k"))
+
+	time.Sleep(100 * time.Millisecond)
+	_, found := memStore.Get("k")
+	assert.False(t, found)
+}
+
+func TestFSM_Encryption(t *testing.T) {
+	cipher, err := encryption.New(context.Background(), encryption.StaticKeys("k1", map[string][]byte{"k1": make([]byte, 32)}))
+	assert.NoError(t, err)
+	memStore := store.New()
+	fsm := NewFSM(memStore, WithEncryption(cipher))
+	read := func(key string) string {
+		t.Helper()
+		val, err := cipher.Decode(storedValue(memStore, key))
+		assert.NoError(t, err)
+		return val
+	}
+
+	applyCommand(fsm, 1, time.Time{}, service.Command{Op: service.SetOp, Key: "k", Compressed: []byte(cipher.Escape("secret"))})
+	assert.Equal(t, service.ApplyResult{Version: 2, Length: 9},
+		applyCommand(fsm, 2, time.Time{}, service.Command{Op: service.AppendOp, Key: "k", Compressed: []byte(cipher.Escape("-x-"))}))
+	assert.NotContains(t, storedValue(memStore, "k"), "secret")
+	assert.Equal(t, "secret-x-", read("k"))
+
+	// Transactions compare, and scripts read, the original values.
+	result := applyCommand(fsm, 3, time.Time{}, service.Command{Op: service.TxnOp, Txn: &service.TxnCommand{
+		Compares: []ports.Compare{{Key: "k", Target: ports.CompareValue, Value: "secret-x-"}},
+	}}).(service.ApplyResult)
+	assert.True(t, result.Txn.Succeeded)
+	applyCommand(fsm, 4, time.Time{}, service.Command{Op: service.EvalOp, Script: `cache.set(KEYS[1], cache.get(KEYS[1]) .. '!')`, Keys: []string{"k"}})
+	assert.NotContains(t, storedValue(memStore, "k"), "secret")
+	assert.Equal(t, "secret-x-!", read("k"))
+
+	// Snapshots hold only ciphertext.
+	snap, err := fsm.Snapshot()
+	assert.NoError(t, err)
+	sink := &memorySink{}
+	assert.NoError(t, snap.Persist(sink))
+	assert.NotContains(t, sink.String(), "secret")
+}
+
+func TestFSM_SortedSets(t *testing.T) {
+	memStore := store.New()
+	fsm := NewFSM(memStore)
+
+	members := []ports.ScoredMember{{Member: "a", Score: 1}, {Member: "b", Score: 2}, {Member: "c", Score: 3}}
+	assert.Equal(t, service.ApplyResult{Count: 3},
+		applyCommand(fsm, 1, time.Time{}, service.Command{Op: service.ZAddOp, Key: "z", Members: members}))
+	assert.Equal(t, service.ApplyResult{Count: 2},
+		applyCommand(fsm, 2, time.Time{}, service.Command{Op: service.ZRemRangeByScoreOp, Key: "z", Min: 1, Max: 2}))
+	got, err := memStore.ZRange("z", 0, -1)
+	assert.NoError(t, err)
+	assert.Equal(t, members[2:], got)
+
+	applyCommand(fsm, 3, time.Time{}, service.Command{Op: service.SetOp, Key: "s", Value: "v"})
+	err, _ = applyCommand(fsm, 4, time.Time{}, service.Command{Op: service.ZAddOp, Key: "s", Members: members}).(error)
+	assert.ErrorIs(t, err, coreerrors.ErrWrongType)
+}
+
+func TestFSM_Eval(t *testing.T) {
+	memStore := store.New()
+	fsm := NewFSM(memStore)
+	now := time.Now()
+
+	// A fixed-window rate limiter: at most 2 calls per window.
+	limiter := `
+local n = tonumber(cache.get(KEYS[1]) or '0') + 1
+if n > tonumber(ARGV[1]) then
+  error('rate limited')
+end
+cache.set(KEYS[1], n, 60)
+return n
+`
+	limit := func(index uint64) interface{} {
+		return applyCommand(fsm, index, now, service.Command{Op: service.EvalOp, Script: limiter, Keys: []string{"rl"}, Args: []string{"2"}})
+	}
+	assert.Equal(t, service.ApplyResult{Reply: float64(1)}, limit(1))
+	assert.Equal(t, service.ApplyResult{Reply: float64(2)}, limit(2))
+	err, _ := limit(3).(error)
+	assert.ErrorIs(t, err, coreerrors.ErrScript)
+	assert.Equal(t, "2", storedValue(memStore, "rl"))
+	raw, _ := memStore.Get("rl")
+	version, _ := service.DecodeVersion(raw)
+	assert.Equal(t, uint64(2), version)
+
+	// A multi-key transfer that fails part-way applies none of its writes.
+	applyCommand(fsm, 4, now, service.Command{Op: service.SetOp, Key: "a", Value: "10"})
+	transfer := `
+cache.set(KEYS[1], tonumber(cache.get(KEYS[1])) - ARGV[1])
+cache.set(KEYS[2], (tonumber(cache.get(KEYS[2])) or 0) + ARGV[1])
+if tonumber(cache.get(KEYS[1])) < 0 then error('insufficient funds') end
+cache.del(KEYS[3])
+return cache.get(KEYS[2])
+`
+	err, _ = applyCommand(fsm, 5, now, service.Command{Op: service.EvalOp, Script: transfer, Keys: []string{"a", "b", "c"}, Args: []string{"15"}}).(error)
+	assert.ErrorIs(t, err, coreerrors.ErrScript)
+	assert.Equal(t, "10", storedValue(memStore, "a"))
+	_, found := memStore.Get("b")
+	assert.False(t, found)
+
+	applyCommand(fsm, 6, now, service.Command{Op: service.SetOp, Key: "c", Value: "x"})
+	assert.Equal(t, service.ApplyResult{Reply: "4"},
+		applyCommand(fsm, 7, now, service.Command{Op: service.EvalOp, Script: transfer, Keys: []string{"a", "b", "c"}, Args: []string{"4"}}))
+	assert.Equal(t, "6", storedValue(memStore, "a"))
+	assert.Equal(t, "4", storedValue(memStore, "b"))
+	_, found = memStore.Get("c")
+	assert.False(t, found)
+}
+
+func TestFSM_Txn(t *testing.T) {
+	memStore := store.New()
+	fsm := NewFSM(memStore)
+	applyCommand(fsm, 1, time.Time{}, service.Command{Op: service.SetOp, Key: "a", Value: "1"})
+
+	txn := func(index uint64, compares ...ports.Compare) interface{} {
+		return applyCommand(fsm, index, time.Time{}, service.Command{Op: service.TxnOp, Txn: &service.TxnCommand{
+			Compares: compares,
+			Success: []service.Command{
+				{Op: service.SetOp, Key: "a", Value: "2"},
+				{Op: service.DeleteOp, Key: "b"},
+				{Op: service.GetOp, Key: "a"},
+			},
+			Failure: []service.Command{{Op: service.GetOp, Key: "a"}},
+		}})
+	}
+
+	// A failed comparison runs the failure branch and writes nothing.
+	assert.Equal(t, service.ApplyResult{Txn: ports.TxnResult{
+		Results: []ports.TxnOpResult{{Value: "1", Found: true, Version: 1}},
+	}}, txn(2, ports.Compare{Key: "a", Version: 1}, ports.Compare{Key: "b", Target: ports.CompareValue, Value: "x"}))
+
+	// Ops see the writes of earlier ops, and all writes share the entry's index.
+	assert.Equal(t, service.ApplyResult{Txn: ports.TxnResult{
+		Succeeded: true,
+		Results:   []ports.TxnOpResult{{Version: 3}, {}, {Value: "2", Found: true, Version: 3}},
+	}}, txn(3, ports.Compare{Key: "a", Target: ports.CompareValue, Value: "1"}, ports.Compare{Key: "b", Version: 0}))
+
+	assert.True(t, txn(4, ports.Compare{Key: "a", Version: 3, NotEqual: true}).(service.ApplyResult).Txn.Results[0].Found)
+
+	// An op that is not allowed rejects the whole transaction.
+	err, _ := applyCommand(fsm, 5, time.Time{}, service.Command{Op: service.TxnOp, Txn: &service.TxnCommand{
+		Success: []service.Command{{Op: service.SetOp, Key: "a", Value: "3"}, {Op: service.AppendOp, Key: "a", Value: "x"}},
+	}}).(error)
+	assert.ErrorIs(t, err, coreerrors.ErrInvalidArgument)
+	assert.Equal(t, "2", storedValue(memStore, "a"))
+}
+
+func TestFSM_ExpireAndPersist(t *testing.T) {
+	memStore := store.New()
+	fsm := NewFSM(memStore)
+
+	err, _ := applyCommand(fsm, 1, time.Time{}, service.Command{Op: service.ExpireOp, Key: "k", TTL: time.Minute}).(error)
+	assert.ErrorIs(t, err, coreerrors.ErrNotFound)
+
+	applyCommand(fsm, 2, time.Time{}, service.Command{Op: service.SetOp, Key: "k", Value: "v"})
+	assert.Equal(t, service.ApplyResult{}, applyCommand(fsm, 3, time.Time{}, service.Command{Op: service.ExpireOp, Key: "k", TTL: time.Minute}))
+	ttl, _ := memStore.TTL("k")
+	assert.InDelta(t, time.Minute, ttl, float64(time.Second))
+
+	applyCommand(fsm, 4, time.Time{}, service.Command{Op: service.PersistOp, Key: "k"})
+	ttl, found := memStore.TTL("k")
+	assert.True(t, found)
+	assert.Zero(t, ttl)
+
+	// The value and its version are unchanged.
+	raw, _ := memStore.Get("k")
+	version, val := service.DecodeVersion(raw)
+	assert.Equal(t, uint64(2), version)
+	assert.Equal(t, "v", val)
+}
+
+func TestFSM_ExpiryFollowsEntryTime(t *testing.T) {
+	// Whether a key has expired is judged at each entry's timestamp, so a
+	// node whose clock is an hour ahead of the leader's, or an hour behind,
+	// applies the entries the same way.
+	for _, skew := range []time.Duration{-time.Hour, time.Hour} {
+		base := time.Now().Add(-skew)
+		fsm := NewFSM(store.New())
+		expiring := service.Command{Op: service.SetOp, Key: "k", Value: "v", ExpiresAt: base.Add(time.Second).UnixNano()}
+		applyCommand(fsm, 1, base, expiring)
+
+		assert.Equal(t, service.ApplyResult{},
+			applyCommand(fsm, 2, base.Add(500*time.Millisecond), service.Command{Op: service.ExpireOp, Key: "k", ExpiresAt: base.Add(2 * time.Second).UnixNano()}), skew)
+		err, _ := applyCommand(fsm, 3, base.Add(3*time.Second), service.Command{Op: service.PersistOp, Key: "k"}).(error)
+		assert.ErrorIs(t, err, coreerrors.ErrNotFound, skew)
+
+		// A sorted set may replace a string only once it has expired.
+		applyCommand(fsm, 4, base, expiring)
+		members := []ports.ScoredMember{{Member: "m", Score: 1}}
+		err, _ = applyCommand(fsm, 5, base.Add(500*time.Millisecond), service.Command{Op: service.ZAddOp, Key: "k", Members: members}).(error)
+		assert.ErrorIs(t, err, coreerrors.ErrWrongType, skew)
+		assert.Equal(t, service.ApplyResult{Count: 1},
+			applyCommand(fsm, 6, base.Add(2*time.Second), service.Command{Op: service.ZAddOp, Key: "k", Members: members}), skew)
+	}
+}
+
+func TestFSM_AbsoluteExpiry(t *testing.T) {
+	// Two replicas applying the same entry at different times agree on when
+	// the key expires.
+	expiresAt := time.Now().Add(time.Minute)
+	cmd := service.Command{Op: service.SetOp, Key: "k", Value: "v", TTL: time.Minute, ExpiresAt: expiresAt.UnixNano()}
+	a, b := store.New(), store.New()
+	applyCommand(NewFSM(a), 1, time.Time{}, cmd)
+	time.Sleep(20 * time.Millisecond)
+	applyCommand(NewFSM(b), 1, time.Time{}, cmd)
+	ttlA, _ := a.TTL("k")
+	ttlB, _ := b.TTL("k")
+	assert.InDelta(t, ttlA, ttlB, float64(15*time.Millisecond))
+
+	// Replaying an entry whose expiration has passed does not revive the key.
+	cmd.ExpiresAt = time.Now().Add(-time.Second).UnixNano()
+	replay := store.New()
+	applyCommand(NewFSM(replay), 1, time.Time{}, cmd)
+	_, found := replay.Get("k")
+	assert.False(t, found)
+
+	// Entries without ExpiresAt keep their relative TTL.
+	cmd.ExpiresAt = 0
+	applyCommand(NewFSM(replay), 2, time.Time{}, cmd)
+	ttl, _ := replay.TTL("k")
+	assert.InDelta(t, time.Minute, ttl, float64(time.Second))
+}
+
+// Legacy entries without ExpiresAt expire relative to when the leader
+// appended them, not to when each node applies them.
+func TestFSM_UpgradesLegacyTTL(t *testing.T) {
+	kv := store.New()
+	cmd := service.Command{Op: service.SetOp, Key: "k", Value: "v", TTL: time.Minute}
+	applyCommand(NewFSM(kv), 1, time.Now().Add(-30*time.Second), cmd)
+	ttl, _ := kv.TTL("k")
+	assert.InDelta(t, 30*time.Second, ttl, float64(time.Second))
+
+	txn := service.Command{Op: service.TxnOp, Txn: &service.TxnCommand{Success: []service.Command{cmd}}}
+	applyCommand(NewFSM(kv), 2, time.Now().Add(-2*time.Minute), txn)
+	_, found := kv.Get("k")
+	assert.False(t, found)
+}
+
+func TestFSM_ClusterVersion(t *testing.T) {
+	fsm := NewFSM(store.New())
+	assert.Equal(t, service.CommandVersionLegacy, fsm.ClusterVersion())
+
+	// A cluster at version 0 snapshots in a format older nodes read.
+	snapshotHeader := func(f *FSM) []byte {
+		snap, err := f.Snapshot()
+		assert.NoError(t, err)
+		sink := &memorySink{}
+		assert.NoError(t, snap.Persist(sink))
+		return sink.Bytes()
+	}
+	assert.Equal(t, byte(1), snapshotHeader(fsm)[len(fsmSnapshotMagic)])
+
+	raise := service.Command{Op: service.ClusterVersionOp, Version: service.CommandVersionBinary}
+	data, err := service.EncodeCommand(&raise)
+	assert.NoError(t, err)
+	assert.Equal(t, service.ApplyResult{}, fsm.Apply(&raft.Log{Index: 1, Data: data}))
+	assert.Equal(t, service.CommandVersionBinary, fsm.ClusterVersion())
+
+	// The version is never lowered.
+	applyCommand(fsm, 2, time.Time{}, service.Command{Op: service.ClusterVersionOp})
+	assert.Equal(t, service.CommandVersionBinary, fsm.ClusterVersion())
+
+	// It survives snapshots, but a backup of the store alone leaves it as is.
+	restored := NewFSM(store.New())
+	assert.NoError(t, restored.Restore(io.NopCloser(bytes.NewReader(snapshotHeader(fsm)))))
+	assert.Equal(t, service.CommandVersionBinary, restored.ClusterVersion())
+	var backup bytes.Buffer
+	assert.NoError(t, store.New().Snapshot(&backup))
+	assert.NoError(t, restored.Restore(io.NopCloser(&backup)))
+	assert.Equal(t, service.CommandVersionBinary, restored.ClusterVersion())
+
+	// Commands from a newer release are refused rather than misapplied.
+	future := service.Command{Op: service.SetOp, Key: "k", Value: "v", Version: service.MaxCommandVersion + 1}
+	data, err = service.EncodeCommand(&future)
+	assert.NoError(t, err)
+	resp := fsm.Apply(&raft.Log{Index: 3, Data: data})
+	assert.ErrorIs(t, resp.(error), coreerrors.ErrUnsupported)
+}
+
+func TestFSM_Purge(t *testing.T) {
+	memStore := store.New()
+	broker := events.NewBroker()
+	sub := broker.Subscribe("", 10)
+	fsm := NewFSM(memStore, WithEvents(broker))
+	now := time.Now()
+	memStore.SetExpiresAt("old", "v", now.Add(-time.Second))
+	memStore.SetExpiresAt("later", "v", now.Add(time.Second))
+	memStore.Set("forever", "v", 0)
+
+	// Only keys expired by the leader's time are removed, whatever this node's clock says.
+	result := applyCommand(fsm, 1, time.Time{}, service.Command{
+		Op:        service.PurgeOp,
+		Keys:      []string{"old", "later", "forever", "missing"},
+		ExpiresAt: now.UnixNano(),
+	})
+	assert.Equal(t, service.ApplyResult{Count: 1}, result)
+	assert.Equal(t, 2, memStore.Len())
+
+	assert.Equal(t, events.Event{Type: events.Delete, Key: "old", Index: 1}, <-sub.Events())
+
+	// A later purge, e.g. on replay, removes keys expired by its own time.
+	result = applyCommand(fsm, 2, time.Time{}, service.Command{
+		Op:        service.PurgeOp,
+		Keys:      []string{"later"},
+		ExpiresAt: now.Add(2 * time.Second).UnixNano(),
+	})
+	assert.Equal(t, service.ApplyResult{Count: 1}, result)
+	assert.Equal(t, 1, memStore.Len())
+}
+
+func TestFSM_Evict(t *testing.T) {
+	memStore := store.New(store.WithCapacity(1), store.WithDeferredEviction())
+	broker := events.NewBroker()
+	sub := broker.Subscribe("", 10)
+	fsm := NewFSM(memStore, WithEvents(broker))
+	applyCommand(fsm, 1, time.Time{}, service.Command{Op: service.SetOp, Key: "a", Value: "1"})
+	applyCommand(fsm, 2, time.Time{}, service.Command{Op: service.SetOp, Key: "b", Value: "2"})
+	assert.Equal(t, 2, memStore.Len())
+
+	// The FSM removes the keys the leader chose, and only those.
+	result := applyCommand(fsm, 3, time.Time{}, service.Command{Op: service.EvictOp, Keys: []string{"b", "missing"}})
+	assert.Equal(t, service.ApplyResult{Count: 1}, result)
+	assert.Equal(t, "1", storedValue(memStore, "a"))
+	_, found := memStore.Get("b")
+	assert.False(t, found)
+
+	<-sub.Events()
+	<-sub.Events()
+	assert.Equal(t, events.Event{Type: events.Delete, Key: "b", Index: 3}, <-sub.Events())
+}
+
+func TestFSM_Tombstones(t *testing.T) {
+	memStore := store.New()
+	fsm := NewFSM(memStore)
+	deleted := time.Unix(1700000000, 0)
+	before, after := deleted.Add(-time.Second).UnixNano(), deleted.Add(time.Second).UnixNano()
+
+	applyCommand(fsm, 1, time.Time{}, service.Command{Op: service.SetOp, Key: "k", Value: "v1"})
+	applyCommand(fsm, 2, time.Time{}, service.Command{Op: service.DeleteOp, Key: "k", OriginTime: deleted.UnixNano()})
+	// Deleting a key that does not exist still leaves a tombstone.
+	applyCommand(fsm, 3, time.Time{}, service.Command{Op: service.DeleteOp, Key: "absent", OriginTime: deleted.UnixNano()})
+
+	// A write made before the delete is refused, whatever its kind.
+	for i, op := range []service.CommandType{service.SetOp, service.GetSetOp, service.GetOrSetOp} {
+		resp := applyCommand(fsm, uint64(4+i), time.Time{}, service.Command{Op: op, Key: "k", Value: "old", OriginTime: before})
+		assert.ErrorIs(t, resp.(error), coreerrors.ErrVersionMismatch, op)
+	}
+	resp := applyCommand(fsm, 7, time.Time{}, service.Command{Op: service.SetOp, Key: "absent", Value: "old", OriginTime: before})
+	assert.ErrorIs(t, resp.(error), coreerrors.ErrVersionMismatch)
+	_, found := memStore.Get("k")
+	assert.False(t, found)
+
+	// Later writes, and writes made here, go through.
+	assert.Equal(t, service.ApplyResult{Version: 8},
+		applyCommand(fsm, 8, time.Time{}, service.Command{Op: service.SetOp, Key: "k", Value: "new", OriginTime: after}))
+	assert.Equal(t, service.ApplyResult{Version: 9},
+		applyCommand(fsm, 9, time.Time{}, service.Command{Op: service.SetOp, Key: "absent", Value: "local"}))
+
+	// Tombstones survive a snapshot, in a format older nodes know they cannot read.
+	snap, err := fsm.Snapshot()
+	assert.NoError(t, err)
+	sink := &memorySink{}
+	assert.NoError(t, snap.Persist(sink))
+	assert.Contains(t, sink.String(), fsmSnapshotMagic+"\x03")
+	follower := NewFSM(store.New())
+	assert.NoError(t, follower.Restore(io.NopCloser(&sink.Buffer)))
+	resp = applyCommand(follower, 10, time.Time{}, service.Command{Op: service.SetOp, Key: "absent", Value: "old", OriginTime: before})
+	assert.ErrorIs(t, resp.(error), coreerrors.ErrVersionMismatch)
+
+	// GC drops the tombstones of deletes made before its cutoff.
+	assert.Equal(t, service.ApplyResult{Count: 0},
+		applyCommand(fsm, 10, time.Time{}, service.Command{Op: service.TombstoneGCOp, OriginTime: deleted.UnixNano()}))
+	assert.Equal(t, service.ApplyResult{Count: 2},
+		applyCommand(fsm, 11, time.Time{}, service.Command{Op: service.TombstoneGCOp, OriginTime: after}))
+	assert.Equal(t, service.ApplyResult{Version: 12},
+		applyCommand(fsm, 12, time.Time{}, service.Command{Op: service.SetOp, Key: "k", Value: "old", OriginTime: before}))
+
+	// Without tombstones, snapshots keep the older format.
+	snap, err = fsm.Snapshot()
+	assert.NoError(t, err)
+	sink = &memorySink{}
+	assert.NoError(t, snap.Persist(sink))
+	assert.Contains(t, sink.String(), fsmSnapshotMagic+"\x01")
+}
+
+func TestFSM_Members(t *testing.T) {
+	fsm := NewFSM(store.New())
+	applyCommand(fsm, 1, time.Time{}, service.Command{Op: service.MemberOp, Key: "node1", Value: "10.0.0.1:50051"})
+	applyCommand(fsm, 2, time.Time{}, service.Command{Op: service.MemberOp, Key: "node2", Value: "10.0.0.2:50051"})
+	// A member that moves advertises its new address.
+	applyCommand(fsm, 3, time.Time{}, service.Command{Op: service.MemberOp, Key: "node1", Value: "10.0.0.9:6000"})
+	addr, ok := fsm.MemberAddr("node1")
+	assert.True(t, ok)
+	assert.Equal(t, "10.0.0.9:6000", addr)
+	_, ok = fsm.MemberAddr("node3")
+	assert.False(t, ok)
+
+	// Addresses survive a snapshot, in a format older nodes know they cannot read.
+	snap, err := fsm.Snapshot()
+	assert.NoError(t, err)
+	sink := &memorySink{}
+	assert.NoError(t, snap.Persist(sink))
+	assert.Contains(t, sink.String(), fsmSnapshotMagic+"\x04")
+	follower := NewFSM(store.New())
+	assert.NoError(t, follower.Restore(io.NopCloser(bytes.NewReader(sink.Bytes()))))
+	addr, _ = follower.MemberAddr("node2")
+	assert.Equal(t, "10.0.0.2:50051", addr)
+
+	// Snapshots taken before members advertised addresses keep the known ones.
+	older := NewFSM(store.New())
+	snap, err = older.Snapshot()
+	assert.NoError(t, err)
+	sink = &memorySink{}
+	assert.NoError(t, snap.Persist(sink))
+	assert.NoError(t, follower.Restore(io.NopCloser(&sink.Buffer)))
+	addr, _ = follower.MemberAddr("node1")
+	assert.Equal(t, "10.0.0.9:6000", addr)
+}
@@ -0,0 +1,64 @@
+package consensus
+
+import (
+	"maps"
+	"slices"
+	"strings"
+)
+
+// memberAddrs maps member IDs to the gRPC address each advertised through a
+// MEMBER command. Raft's configuration holds only Raft addresses, so the
+// others are kept here, as replicated state carried in FSM snapshots.
+//
+// Members are read by other goroutines than the FSM's, and rarely change:
+// the map is replaced, never modified.
+func (f *FSM) memberAddrs() map[string]string {
+	if m := f.members.Load(); m != nil {
+		return *m
+	}
+	return nil
+}
+
+// setMemberAddr records addr as the gRPC address of member id.
+func (f *FSM) setMemberAddr(id, addr string) {
+	m := maps.Clone(f.memberAddrs())
+	if m == nil {
+		m = make(map[string]string)
+	}
+	m[id] = addr
+	f.members.Store(&m)
+}
+
+// resetMembers replaces the recorded addresses with entries, from a snapshot.
+func (f *FSM) resetMembers(entries []memberAddr) {
+	m := make(map[string]string, len(entries))
+	for _, e := range entries {
+		m[e.id] = e.addr
+	}
+	f.members.Store(&m)
+}
+
+// MemberAddr returns the gRPC address member id advertised, if it has.
+func (f *FSM) MemberAddr(id string) (string, bool) {
+	addr, ok := f.memberAddrs()[id]
+	return addr, ok
+}
+
+func (f *FSM) cloneMembers() []memberAddr {
+	m := f.memberAddrs()
+	if len(m) == 0 {
+		return nil
+	}
+	entries := make([]memberAddr, 0, len(m))
+	for id, addr := range m {
+		entries = append(entries, memberAddr{id: id, addr: addr})
+	}
+	// Sorted, so that replicas write identical snapshots.
+	slices.SortFunc(entries, func(a, b memberAddr) int { return strings.Compare(a.id, b.id) })
+	return entries
+}
+
+type memberAddr struct {
+	id   string
+	addr string
+}
@@ -0,0 +1,69 @@
+package consensus
+
+import (
+	"sync"
+	"time"
+
+	"distributed-cache-service/internal/observability"
+)
+
+// Write phases after encoding, as labelled in cache_raft_apply_phase_seconds.
+const (
+	// phaseQueue is the wait from submitting an entry until the leader
+	// appends it to its log.
+	phaseQueue = "queue"
+	// phaseReplicate is the time from the append until the FSM starts applying
+	// the entry: the quorum's disk writes and round trip, then any backlog of
+	// committed entries ahead of it.
+	phaseReplicate = "replicate"
+	// phaseApply is the time the FSM takes to apply the entry.
+	phaseApply = "apply"
+)
+
+// applyTimingSlots bounds the entries whose timings are kept. The leader reads
+// an entry's timings as soon as it has been applied, so only a burst of this
+// many later entries, applied in between, would evict them first.
+const applyTimingSlots = 4096
+
+// applyTiming records when the FSM saw an entry.
+type applyTiming struct {
+	index    uint64
+	appended time.Time // by the leader, from raft.Log.AppendedAt
+	started  time.Time
+	finished time.Time
+}
+
+// applyTimings is a ring of the timings of recently applied entries, written
+// by the FSM and read by the node that submitted them.
+type applyTimings struct {
+	mu    sync.Mutex
+	slots [applyTimingSlots]applyTiming
+}
+
+func (a *applyTimings) record(t applyTiming) {
+	a.mu.Lock()
+	a.slots[t.index%applyTimingSlots] = t
+	a.mu.Unlock()
+}
+
+func (a *applyTimings) get(index uint64) (applyTiming, bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	t := a.slots[index%applyTimingSlots]
+	return t, t.index == index && !t.appended.IsZero()
+}
+
+// observePhases splits the latency of the entry at index, submitted at
+// submitted and since applied by fsm, into its phases.
+func observePhases(fsm *FSM, index uint64, submitted time.Time) {
+	if fsm == nil {
+		return
+	}
+	t, ok := fsm.timings.get(index)
+	if !ok {
+		return
+	}
+	observability.RaftApplyPhaseSeconds.WithLabelValues(phaseQueue).Observe(t.appended.Sub(submitted).Seconds())
+	observability.RaftApplyPhaseSeconds.WithLabelValues(phaseReplicate).Observe(t.started.Sub(t.appended).Seconds())
+	observability.RaftApplyPhaseSeconds.WithLabelValues(phaseApply).Observe(t.finished.Sub(t.started).Seconds())
+}
@@ -1,95 +1,54 @@
 package consensus
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"net"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
-	// Added for string containment check
-
-	// Added for string containment check
+	coreerrors "distributed-cache-service/internal/core/errors"
+	"distributed-cache-service/internal/core/ports"
+	"distributed-cache-service/internal/core/service"
+	"distributed-cache-service/internal/mux"
+	"distributed-cache-service/internal/observability"
 
 	"github.com/hashicorp/raft"
 	raftboltdb "github.com/hashicorp/raft-boltdb"
 )
 
-// BufferedConn wraps a net.Conn to replay a peeked byte
-// BufferedConn wraps a net.Conn to allow replaying a peeked byte.
-// This is necessary because RaftListener peaks at the first byte to detect HTTP traffic.
-// If the traffic is Raft, the byte is placed into this connection which then behaves normally,
-// yielding the peeked byte on the first Read call.
-type BufferedConn struct {
-	net.Conn
-	peeked []byte
-}
-
-func (c *BufferedConn) Read(p []byte) (n int, err error) {
-	if len(c.peeked) > 0 {
-		n = copy(p, c.peeked)
-		c.peeked = c.peeked[n:]
-		if len(c.peeked) == 0 {
-			c.peeked = nil
-		}
-		return n, nil
+// MatchRaft matches connections of Raft's network transport, which open with
+// a one-byte RPC type: AppendEntries, RequestVote, InstallSnapshot, TimeoutNow
+// or RequestPreVote, numbered 0 to 4. No text protocol starts with these bytes.
+func MatchRaft() mux.Matcher {
+	return func(r io.Reader) bool {
+		b := make([]byte, 1)
+		_, err := io.ReadFull(r, b)
+		return err == nil && b[0] <= 4
 	}
-	return c.Conn.Read(p)
 }
 
-// RaftListener is a custom net.Listener that intercepts incoming connections to peek at the first byte.
-// It is designed to filter out HTTP health checks (which often hit the same port in some deployment environments)
-// while allowing legitimate binary Raft RPC traffic to pass through.
+// RaftListener is Raft's stream layer: it accepts peer connections from a
+// listener, usually one returned by a mux.Mux, and dials peers over TCP.
 type RaftListener struct {
 	net.Listener
+	advertise net.Addr
 }
 
-// Accept accepts the next incoming connection. It reads the first byte to determine the protocol.
-// If the byte indicates an HTTP request ('G', 'H', 'P', etc.), it responds with 200 OK and closes the connection.
-// This prevents 'unknown rpc type' errors in the Raft logs.
-// If the byte indicates binary Raft traffic, it returns a BufferedConn that replays the peeked byte.
-func (l *RaftListener) Accept() (net.Conn, error) {
-	for {
-		conn, err := l.Listener.Accept()
-		if err != nil {
-			return nil, err
-		}
-
-		// Set a short read deadline to peek at the first byte
-		_ = conn.SetReadDeadline(time.Now().Add(500 * time.Millisecond))
-		peek := make([]byte, 1)
-		n, err := conn.Read(peek)
-		_ = conn.SetReadDeadline(time.Time{}) // Reset deadline
-
-		if err != nil {
-			conn.Close()
-			continue // Handshake failed, ignore
-		}
-
-		if n == 0 {
-			conn.Close()
-			continue
-		}
-
-		// Check if it looks like HTTP (G=GET, H=HEAD, P=POST, C=CONNECT, O=OPTIONS)
-		// Raft RPC types are binary, unlikely to match these ASCII upper-case letters first.
-		// Valid Raft RPC types (as of hashicorp/raft):
-		// 0=RPCInstallSnapshot, 1=RPCAppendEntries, 2=RPCRequestVote, 3=RPCTimeoutNow
-		// So checking for ASCII chars is safe.
-		b := peek[0]
-		if b == 'G' || b == 'H' || b == 'P' || b == 'C' || b == 'O' || b == 'D' {
-			// It is likely HTTP. Respond with 200 OK
-			if _, err := conn.Write([]byte("HTTP/1.1 200 OK\r\nConnection: close\r\nContent-Length: 2\r\n\r\nok")); err != nil {
-				log.Printf("Failed to write to connection: %v", err)
-			}
-			conn.Close()
-			continue // Drop this connection, don't return to Raft
-		}
-
-		// It is not HTTP, pass it to Raft with the peeked byte
-		return &BufferedConn{Conn: conn, peeked: peek}, nil
+// Addr returns the address peers reach this node at.
+func (l *RaftListener) Addr() net.Addr {
+	if l.advertise != nil {
+		return l.advertise
 	}
+	return l.Listener.Addr()
 }
 
 func (l *RaftListener) Dial(address raft.ServerAddress, timeout time.Duration) (net.Conn, error) {
@@ -98,30 +57,40 @@ func (l *RaftListener) Dial(address raft.ServerAddress, timeout time.Duration) (
 
 // SetupRaft initializes and starts a Raft node.
 // SetupRaft initializes and starts a Raft node with the given configuration.
-// It sets up the BoltDB store for logs and snapshots, configures the transport with the custom RaftListener,
+// It sets up the BoltDB store for logs and snapshots, configures the transport with a RaftListener,
 // and bootstraps the Raft instance.
 //
 // Parameters:
 //   - dir: Directory to store Raft data (logs and snapshots).
 //   - nodeId: Unique identifier for this node.
-//   - bindAddr: Address to bind the listener to (should be valid local IP).
+//   - ln: Listener for Raft connections from peers, e.g. matched with MatchRaft.
 //   - advertiseAddr: Address to advertise to other peers (reachable IP:Port).
 //   - fsm: The Finite State Machine that applies committed log entries.
-func SetupRaft(dir, nodeId, bindAddr, advertiseAddr string, fsm *FSM) (*raft.Raft, error) {
-	// Setup Raft configuration
-	config := raft.DefaultConfig()
-	config.LocalID = raft.ServerID(nodeId)
-	// config.Logger = hclog.New(&hclog.LoggerOptions{Output: os.Stderr, Level: hclog.Error, Name: "raft"})
-
-	// Create a custom listener that traps HTTP health checks
-	realListener, err := net.Listen("tcp", bindAddr)
+//   - tuning: Snapshot, timeout and log size settings; zero values keep Raft's defaults.
+func SetupRaft(dir, nodeId string, ln net.Listener, advertiseAddr string, fsm *FSM, tuning RaftConfig) (*RaftNode, error) {
+	advertise, err := net.ResolveTCPAddr("tcp", advertiseAddr)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("resolve advertise address: %w", err)
 	}
-	raftListener := &RaftListener{Listener: realListener}
+	raftListener := &RaftListener{Listener: ln, advertise: advertise}
 
 	transport := raft.NewNetworkTransport(raftListener, 3, 10*time.Second, os.Stderr)
 
+	// A corrupt raft.db would otherwise make NewRaft fail cryptically, and a
+	// gap after the newest valid snapshot would restore stale state.
+	if !tuning.SkipVerify {
+		report, err := VerifyDataDir(dir)
+		if err != nil {
+			return nil, fmt.Errorf("verify raft data: %w", err)
+		}
+		if report.Fatal {
+			return nil, fmt.Errorf("%w: %s. %s", ErrCorruptData, strings.Join(report.Problems, "; "), report.Guidance())
+		}
+		for _, p := range report.Problems {
+			log.Printf("Raft data: %s. %s", p, report.Guidance())
+		}
+	}
+
 	// Create the snapshot store. This allows the Raft to truncate the log.
 	snapshotStore, err := raft.NewFileSnapshotStore(dir, 2, os.Stderr)
 	if err != nil {
@@ -129,45 +98,540 @@ func SetupRaft(dir, nodeId, bindAddr, advertiseAddr string, fsm *FSM) (*raft.Raf
 	}
 
 	// Create the log store and stable store
-	var logStore raft.LogStore
-	var stableStore raft.StableStore
-
 	boltDir := filepath.Join(dir, "raft.db")
 	boltDB, err := raftboltdb.NewBoltStore(boltDir)
 	if err != nil {
 		return nil, fmt.Errorf("new bolt store: %w", err)
 	}
-	logStore = boltDB
-	stableStore = boltDB
 
-	// Instantiate the Raft systems
-	ra, err := raft.NewRaft(config, fsm, logStore, stableStore, snapshotStore, transport)
+	return NewRaftNode(nodeId, fsm, boltDB, boltDB, snapshotStore, transport, tuning)
+}
+
+// SetupRaftInMem starts a Raft node like SetupRaft, but on in-memory log,
+// stable and snapshot stores behind an in-memory transport, so that tests
+// need neither a data directory nor a listener. Nodes reach each other once
+// their transports are connected with ConnectInMem; the transport's
+// LocalAddr is the node's address in the cluster configuration.
+func SetupRaftInMem(nodeId string, fsm *FSM, tuning RaftConfig) (*RaftNode, *raft.InmemTransport, error) {
+	_, trans := raft.NewInmemTransport("")
+	logs := raft.NewInmemStore()
+	node, err := NewRaftNode(nodeId, fsm, logs, logs, raft.NewInmemSnapshotStore(), trans, tuning)
+	if err != nil {
+		return nil, nil, err
+	}
+	return node, trans, nil
+}
+
+// ConnectInMem connects every pair of the given in-memory transports.
+func ConnectInMem(transports ...*raft.InmemTransport) {
+	for _, a := range transports {
+		for _, b := range transports {
+			if a != b {
+				a.Connect(b.LocalAddr(), b)
+			}
+		}
+	}
+}
+
+// NewRaftNode starts a Raft node on the given stores and transport. SetupRaft
+// uses BoltDB and TCP, and SetupRaftInMem in-memory stores and transport.
+func NewRaftNode(nodeId string, fsm *FSM, logs raft.LogStore, stable raft.StableStore, snapshots raft.SnapshotStore, trans raft.Transport, tuning RaftConfig) (*RaftNode, error) {
+	config := raft.DefaultConfig()
+	config.LocalID = raft.ServerID(nodeId)
+	tuning.apply(config)
+
+	// Track the log's size so it can be compacted before it grows unbounded.
+	sized, err := newSizedLogStore(logs, tuning.LogMaxBytes)
+	if err != nil {
+		return nil, fmt.Errorf("measure raft log: %w", err)
+	}
+
+	ra, err := raft.NewRaft(config, fsm, sized, stable, snapshots, trans)
 	if err != nil {
 		return nil, fmt.Errorf("new raft: %w", err)
 	}
+	node := &RaftNode{Raft: ra, Snapshots: snapshots, localID: config.LocalID, logs: sized, fsm: fsm}
+	for _, c := range []interface{}{trans, logs, stable} {
+		if c, ok := c.(io.Closer); ok && !slices.Contains(node.closers, c) {
+			node.closers = append(node.closers, c)
+		}
+	}
+	full := node.autoCompact
+	sized.full.Store(&full)
+	node.watchLeadership()
+	if tuning.ReapAfter > 0 {
+		node.reaper = newReaper(node, tuning)
+	}
 
-	return ra, nil
+	return node, nil
 }
 
+// ensure implementation
+var (
+	_ ports.Consensus        = (*RaftNode)(nil)
+	_ ports.ClusterAdmin     = (*RaftNode)(nil)
+	_ ports.ClusterVersioner = (*RaftNode)(nil)
+	_ ports.PositionReporter = (*RaftNode)(nil)
+	_ ports.AsyncApplier     = (*RaftNode)(nil)
+	_ ports.QuorumChecker    = (*RaftNode)(nil)
+	_ ports.Reaper           = (*RaftNode)(nil)
+)
+
+// DefaultApplyTimeout bounds a write when the caller's context has no deadline.
+const DefaultApplyTimeout = 2 * time.Second
+
+// DefaultQuorumTimeout is how long a node may go without hearing from a leader
+// before CheckQuorum reports it has lost quorum. It leaves room for an
+// election with the default timeouts.
+const DefaultQuorumTimeout = 3 * time.Second
+
 // Wrapper to satisfy ports.Consensus interface
 type RaftNode struct {
-	Raft *raft.Raft
+	Raft      *raft.Raft
+	Snapshots raft.SnapshotStore
+	// ApplyTimeout is used for Apply calls whose context has no deadline.
+	ApplyTimeout time.Duration
+	// QuorumTimeout is how long CheckQuorum lets the node go without
+	// contact from a leader; zero uses DefaultQuorumTimeout.
+	QuorumTimeout time.Duration
+
+	localID raft.ServerID
+	fsm     *FSM
+	// tuneMu serializes configuration reloads, including Compact's.
+	tuneMu     sync.Mutex
+	logs       *sizedLogStore
+	compacting atomic.Bool
+	// barrierTerm is the last term in which this node, as leader, applied
+	// every entry committed before it.
+	barrierTerm atomic.Uint64
+	// closers are the transport and stores NewRaftNode was given that can be
+	// closed, e.g. the network transport and the BoltDB log store.
+	closers []io.Closer
+	// leadership receives the leader changes Raft observes, see watchLeadership.
+	leadership chan raft.Observation
+	observer   *raft.Observer
+	unwatch    sync.Once
+	// reaper removes dead members, if RaftConfig.ReapAfter is set.
+	reaper *reaper
 }
 
-func (n *RaftNode) Apply(cmd []byte) error {
-	f := n.Raft.Apply(cmd, 500*time.Millisecond) // Lower timeout
-	return f.Error()
+// Apply submits cmd and waits for it to be applied on this node.
+// The wait is bounded by ctx, or by ApplyTimeout if ctx has no deadline.
+// If the entry could not even be enqueued in time, ErrTimeout is returned and
+// the write did not happen. If it was enqueued but not confirmed before the
+// deadline, ErrApplyTimeout is returned: the write may still commit.
+func (n *RaftNode) Apply(ctx context.Context, cmd []byte) (interface{}, error) {
+	if _, ok := ctx.Deadline(); !ok {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, n.applyTimeout())
+		defer cancel()
+	}
+	deadline, _ := ctx.Deadline()
+	timeout := time.Until(deadline)
+	if timeout <= 0 || ctx.Err() != nil {
+		return nil, fmt.Errorf("%w: deadline passed before the write was submitted", coreerrors.ErrTimeout)
+	}
+
+	submitted := time.Now()
+	f := n.Raft.Apply(cmd, timeout)
+	done := make(chan error, 1)
+	go func() { done <- f.Error() }()
+
+	select {
+	case err := <-done:
+		if err != nil {
+			return nil, translateError(err)
+		}
+		observePhases(n.fsm, f.Index(), submitted)
+		// The FSM reports rejected commands (e.g. failed preconditions) as its response.
+		resp := f.Response()
+		if err, ok := resp.(error); ok {
+			return nil, err
+		}
+		return resp, nil
+	case <-ctx.Done():
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return nil, fmt.Errorf("%w: %w", coreerrors.ErrApplyTimeout, ctx.Err())
+		}
+		return nil, fmt.Errorf("write abandoned, outcome unknown: %w", ctx.Err())
+	}
+}
+
+// ApplyAsync submits cmd to the leader's log and returns once Raft has queued
+// it, without waiting for a quorum. done receives the outcome, as Apply would
+// return it, once the entry commits or fails, within the default apply timeout.
+func (n *RaftNode) ApplyAsync(cmd []byte, done func(interface{}, error)) error {
+	if n.Raft.State() != raft.Leader {
+		return fmt.Errorf("%w: %w", coreerrors.ErrNotLeader, raft.ErrNotLeader)
+	}
+	submitted := time.Now()
+	f := n.Raft.Apply(cmd, n.applyTimeout())
+	go func() {
+		if err := f.Error(); err != nil {
+			done(nil, translateError(err))
+			return
+		}
+		observePhases(n.fsm, f.Index(), submitted)
+		resp := f.Response()
+		if err, ok := resp.(error); ok {
+			done(nil, err)
+			return
+		}
+		done(resp, nil)
+	}()
+	return nil
+}
+
+func (n *RaftNode) applyTimeout() time.Duration {
+	if n.ApplyTimeout > 0 {
+		return n.ApplyTimeout
+	}
+	return DefaultApplyTimeout
 }
 
 func (n *RaftNode) AddVoter(id, addr string) error {
 	f := n.Raft.AddVoter(raft.ServerID(id), raft.ServerAddress(addr), 0, 0)
-	return f.Error()
+	return translateError(f.Error())
+}
+
+// LocalAddress returns the address the cluster configuration holds for this
+// node, or an empty string if the node is not a member of the cluster.
+func (n *RaftNode) LocalAddress() (string, error) {
+	f := n.Raft.GetConfiguration()
+	if err := f.Error(); err != nil {
+		return "", err
+	}
+	for _, srv := range f.Configuration().Servers {
+		if srv.ID == n.localID {
+			return string(srv.Address), nil
+		}
+	}
+	return "", nil
+}
+
+// Members returns the servers in the latest cluster configuration, with the
+// gRPC addresses they advertised.
+func (n *RaftNode) Members() ([]ports.Member, error) {
+	f := n.Raft.GetConfiguration()
+	if err := f.Error(); err != nil {
+		return nil, err
+	}
+	_, leader := n.Raft.LeaderWithID()
+	servers := f.Configuration().Servers
+	members := make([]ports.Member, 0, len(servers))
+	grpcAddrs := n.fsm.memberAddrs()
+	for _, srv := range servers {
+		members = append(members, ports.Member{
+			ID:       string(srv.ID),
+			Address:  string(srv.Address),
+			Voter:    srv.Suffrage == raft.Voter,
+			Leader:   srv.ID == leader,
+			GRPCAddr: grpcAddrs[string(srv.ID)],
+		})
+	}
+	return members, nil
 }
 
 func (n *RaftNode) IsLeader() bool {
 	return n.Raft.State() == raft.Leader
 }
 
+// watchLeadership keeps the cache_raft_leader gauge in step with this node's
+// state until Shutdown. A witness that wins an election hands leadership
+// straight to a node with the data: it cannot serve, and the followers it
+// replicates to must not be sent its empty snapshots.
+func (n *RaftNode) watchLeadership() {
+	n.leadership = make(chan raft.Observation, 1)
+	n.observer = raft.NewObserver(n.leadership, false, func(o *raft.Observation) bool {
+		_, ok := o.Data.(raft.LeaderObservation)
+		return ok
+	})
+	n.Raft.RegisterObserver(n.observer)
+	go func() {
+		for range n.leadership {
+			if n.IsLeader() {
+				observability.RaftLeader.Set(1)
+				if n.fsm.witness {
+					go n.handOff()
+				}
+			} else {
+				observability.RaftLeader.Set(0)
+			}
+		}
+		observability.RaftLeader.Set(0)
+	}()
+}
+
+// handOff transfers the leadership of a witness to the most up to date voter.
+func (n *RaftNode) handOff() {
+	log.Printf("Witness won an election, transferring leadership")
+	if err := n.Raft.LeadershipTransfer().Error(); err != nil {
+		log.Printf("Witness failed to transfer leadership: %v", err)
+	}
+}
+
+// VerifyLeader confirms with a quorum that this node still leads, and that
+// its state includes every write acknowledged before, so that it can serve a
+// linearizable read. A new leader may not yet have applied the entries its
+// predecessor committed, so the first check of each term waits for a barrier.
+// Raft may never answer a check pending when it shuts down, so the wait is
+// bounded by ApplyTimeout.
 func (n *RaftNode) VerifyLeader() error {
-	return n.Raft.VerifyLeader().Error()
+	term := n.Raft.CurrentTerm()
+	f := n.Raft.VerifyLeader()
+	done := make(chan error, 1)
+	go func() {
+		err := f.Error()
+		if err == nil && n.barrierTerm.Load() != term {
+			if err = n.Raft.Barrier(n.applyTimeout()).Error(); err == nil {
+				n.barrierTerm.Store(term)
+			}
+		}
+		done <- err
+	}()
+	timer := time.NewTimer(n.applyTimeout())
+	defer timer.Stop()
+	select {
+	case err := <-done:
+		return translateError(err)
+	case <-timer.C:
+		return fmt.Errorf("%w: leadership not confirmed within %s", coreerrors.ErrTimeout, n.applyTimeout())
+	}
+}
+
+// CheckQuorum reports ErrNoQuorum unless this node leads or has heard from a
+// leader within QuorumTimeout. A leader needs no check: Raft makes it step
+// down once it cannot reach a quorum for the leader lease. A minority cut off
+// from the rest of the cluster thus fails writes and strong reads at once,
+// rather than once each has waited out its timeout.
+func (n *RaftNode) CheckQuorum() error {
+	if n.Raft.State() == raft.Leader {
+		return nil
+	}
+	last := n.Raft.LastContact()
+	if last.IsZero() {
+		return fmt.Errorf("%w: no leader contacted yet", coreerrors.ErrNoQuorum)
+	}
+	timeout := n.QuorumTimeout
+	if timeout <= 0 {
+		timeout = DefaultQuorumTimeout
+	}
+	if since := time.Since(last); since > timeout {
+		return fmt.Errorf("%w: no leader contact for %s", coreerrors.ErrNoQuorum, since.Round(time.Millisecond))
+	}
+	return nil
+}
+
+// ReplicationLag returns how many committed entries this node has yet to
+// apply. A follower learns the commit index from the leader's heartbeats, so it
+// is only a bound while the follower has a leader; candidates and followers
+// without one report ErrStaleRead.
+func (n *RaftNode) ReplicationLag() (uint64, error) {
+	switch state := n.Raft.State(); {
+	case state == raft.Follower && n.Raft.Leader() == "":
+		return 0, fmt.Errorf("%w: no known leader", coreerrors.ErrStaleRead)
+	case state != raft.Leader && state != raft.Follower:
+		return 0, fmt.Errorf("%w: node is %s", coreerrors.ErrStaleRead, state)
+	}
+	commit, applied := n.Raft.CommitIndex(), n.Raft.AppliedIndex()
+	if applied >= commit {
+		return 0, nil
+	}
+	return commit - applied, nil
+}
+
+func (n *RaftNode) RemoveServer(id string) error {
+	f := n.Raft.RemoveServer(raft.ServerID(id), 0, 0)
+	return translateError(f.Error())
+}
+
+func (n *RaftNode) TransferLeadership(id, addr string) error {
+	var f raft.Future
+	if id == "" {
+		f = n.Raft.LeadershipTransfer()
+	} else {
+		f = n.Raft.LeadershipTransferToServer(raft.ServerID(id), raft.ServerAddress(addr))
+	}
+	return translateError(f.Error())
+}
+
+func (n *RaftNode) Snapshot() (ports.SnapshotInfo, error) {
+	f := n.Raft.Snapshot()
+	if err := f.Error(); err != nil {
+		return ports.SnapshotInfo{}, translateError(err)
+	}
+	meta, rc, err := f.Open()
+	if err != nil {
+		return ports.SnapshotInfo{}, err
+	}
+	rc.Close()
+	return ports.SnapshotInfo{ID: meta.ID, Index: meta.Index, Term: meta.Term, Size: meta.Size}, nil
+}
+
+// Compact takes a snapshot with TrailingLogs temporarily set to zero so that
+// every log entry covered by the snapshot is truncated.
+func (n *RaftNode) Compact() (uint64, error) {
+	n.tuneMu.Lock()
+	defer n.tuneMu.Unlock()
+	prev := n.Raft.ReloadableConfig()
+	next := prev
+	next.TrailingLogs = 0
+	if err := n.Raft.ReloadConfig(next); err != nil {
+		return 0, err
+	}
+	defer func() {
+		if err := n.Raft.ReloadConfig(prev); err != nil {
+			log.Printf("Failed to restore raft config after compaction: %v", err)
+		}
+	}()
+
+	info, err := n.Snapshot()
+	if err != nil {
+		return 0, err
+	}
+	return info.Index, nil
+}
+
+// ListSnapshots returns the snapshots held in the local snapshot store, newest first.
+func (n *RaftNode) ListSnapshots() ([]ports.SnapshotInfo, error) {
+	if n.Snapshots == nil {
+		return nil, fmt.Errorf("snapshot store not configured")
+	}
+	metas, err := n.Snapshots.List()
+	if err != nil {
+		return nil, err
+	}
+	infos := make([]ports.SnapshotInfo, 0, len(metas))
+	for _, m := range metas {
+		infos = append(infos, ports.SnapshotInfo{ID: m.ID, Index: m.Index, Term: m.Term, Size: m.Size})
+	}
+	return infos, nil
+}
+
+// RestoreFrom forces the cluster to adopt the snapshot read from r as its state.
+// It must be called on the leader; the restored state is replicated to followers
+// via InstallSnapshot.
+func (n *RaftNode) RestoreFrom(r io.Reader) error {
+	// Raft checks the snapshot against the size in its metadata, so the
+	// backup is spooled to a temporary file to measure it first.
+	f, err := os.CreateTemp("", "cache-restore-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+	size, err := io.Copy(f, r)
+	if err != nil {
+		return fmt.Errorf("read backup: %w", err)
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	meta := &raft.SnapshotMeta{Version: raft.SnapshotVersionMax, Size: size}
+	return translateError(n.Raft.Restore(meta, f, 0))
+}
+
+// Shutdown stops Raft and closes its transport and stores, releasing the
+// listener and the lock on raft.db so that the node can be started again in
+// the same process.
+func (n *RaftNode) Shutdown() error {
+	err := n.Raft.Shutdown().Error()
+	n.unwatch.Do(func() {
+		n.Raft.DeregisterObserver(n.observer)
+		close(n.leadership)
+		if n.reaper != nil {
+			n.reaper.close()
+		}
+	})
+	for _, c := range n.closers {
+		err = errors.Join(err, c.Close())
+	}
+	return err
+}
+
+// Bootstrap starts a new cluster with the servers of cfg, as
+// raft.BootstrapCluster does. A new cluster has no members running older
+// releases, so once elected, its first leader raises the cluster version to
+// service.MaxCommandVersion. Clusters upgraded from an older release stay at
+// their version until an operator raises it.
+func (n *RaftNode) Bootstrap(cfg raft.Configuration) error {
+	if err := n.Raft.BootstrapCluster(cfg).Error(); err != nil {
+		return err
+	}
+	go n.raiseNewClusterVersion()
+	return nil
+}
+
+// raiseNewClusterVersion waits for the first leader of a cluster this node
+// bootstrapped and, if it is this node, raises the cluster version. Other
+// nodes bootstrapping the same cluster, as with -bootstrap_expect, do so if
+// they win the election instead.
+func (n *RaftNode) raiseNewClusterVersion() {
+	for !n.IsLeader() {
+		if _, id := n.Raft.LeaderWithID(); id != "" || n.Raft.State() == raft.Shutdown {
+			return
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+	if n.fsm.ClusterVersion() >= service.MaxCommandVersion {
+		return
+	}
+	data, err := service.EncodeCommand(&service.Command{Op: service.ClusterVersionOp, Version: service.MaxCommandVersion})
+	if err == nil {
+		_, err = n.Apply(context.Background(), data)
+	}
+	if err != nil {
+		log.Printf("Failed to raise the version of the new cluster; raise it with /admin/cluster_version: %v", err)
+	}
+}
+
+// AppliedPosition returns the index of the last entry applied to the FSM and
+// the current term.
+func (n *RaftNode) AppliedPosition() (uint64, uint64) {
+	return n.Raft.AppliedIndex(), n.Raft.CurrentTerm()
+}
+
+// ClusterVersion returns the cluster's command version as applied on this node.
+func (n *RaftNode) ClusterVersion() uint32 {
+	return n.fsm.ClusterVersion()
+}
+
+// WaitForLeader blocks until this node becomes leader or the timeout elapses.
+func (n *RaftNode) WaitForLeader(timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if n.IsLeader() {
+			return nil
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	return fmt.Errorf("%w: timed out after %s waiting for leadership", coreerrors.ErrTimeout, timeout)
+}
+
+func (n *RaftNode) State() string {
+	return n.Raft.State().String()
+}
+
+func (n *RaftNode) Leader() string {
+	return string(n.Raft.Leader())
+}
+
+func (n *RaftNode) Stats() map[string]string {
+	return n.Raft.Stats()
+}
+
+// translateError maps Raft errors onto the core sentinel errors
+// so transports can react to them without depending on hashicorp/raft.
+// The Raft error stays wrapped: ErrNotLeader means the write was never
+// submitted, while ErrLeadershipLost leaves its outcome unknown.
+func translateError(err error) error {
+	switch {
+	case errors.Is(err, raft.ErrNotLeader):
+		return fmt.Errorf("%w: %w", coreerrors.ErrNotLeader, err)
+	case errors.Is(err, raft.ErrLeadershipLost):
+		return fmt.Errorf("%w: %w: %w", coreerrors.ErrNotLeader, coreerrors.ErrLeadershipLost, err)
+	case errors.Is(err, raft.ErrEnqueueTimeout):
+		return fmt.Errorf("%w: %w", coreerrors.ErrTimeout, err)
+	}
+	return err
 }
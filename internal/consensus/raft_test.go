@@ -0,0 +1,342 @@
+package consensus
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	coreerrors "distributed-cache-service/internal/core/errors"
+	"distributed-cache-service/internal/core/ports"
+	"distributed-cache-service/internal/core/service"
+	"distributed-cache-service/internal/observability"
+	"distributed-cache-service/internal/store"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/raft"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRaftNode_ListSnapshots(t *testing.T) {
+	snapshots := raft.NewInmemSnapshotStore()
+	sink, err := snapshots.Create(raft.SnapshotVersionMax, 42, 3, raft.Configuration{}, 0, nil)
+	require.NoError(t, err)
+	_, err = sink.Write([]byte("{}"))
+	require.NoError(t, err)
+	require.NoError(t, sink.Close())
+
+	node := &RaftNode{Snapshots: snapshots}
+	infos, err := node.ListSnapshots()
+	require.NoError(t, err)
+	require.Len(t, infos, 1)
+	assert.Equal(t, uint64(42), infos[0].Index)
+	assert.Equal(t, uint64(3), infos[0].Term)
+	assert.Equal(t, int64(2), infos[0].Size)
+}
+
+// blockingFSM holds every Apply until release is closed.
+type blockingFSM struct{ release chan struct{} }
+
+func (f *blockingFSM) Apply(*raft.Log) interface{}         { <-f.release; return nil }
+func (f *blockingFSM) Snapshot() (raft.FSMSnapshot, error) { return nil, errors.New("unsupported") }
+func (f *blockingFSM) Restore(io.ReadCloser) error         { return nil }
+
+func TestRaftNode_ApplyTimeout(t *testing.T) {
+	fsm := &blockingFSM{release: make(chan struct{})}
+	defer close(fsm.release)
+
+	conf := raft.DefaultConfig()
+	conf.LocalID = "node1"
+	conf.HeartbeatTimeout = 50 * time.Millisecond
+	conf.ElectionTimeout = 50 * time.Millisecond
+	conf.LeaderLeaseTimeout = 50 * time.Millisecond
+	conf.Logger = hclog.NewNullLogger()
+	_, trans := raft.NewInmemTransport("")
+	store := raft.NewInmemStore()
+	r, err := raft.NewRaft(conf, fsm, store, store, raft.NewInmemSnapshotStore(), trans)
+	require.NoError(t, err)
+	defer r.Shutdown()
+	require.NoError(t, r.BootstrapCluster(raft.Configuration{
+		Servers: []raft.Server{{ID: conf.LocalID, Address: trans.LocalAddr()}},
+	}).Error())
+	require.Eventually(t, func() bool { return r.State() == raft.Leader }, 2*time.Second, 10*time.Millisecond)
+
+	node := &RaftNode{Raft: r, ApplyTimeout: 50 * time.Millisecond}
+
+	// No caller deadline: the node's default applies.
+	_, err = node.Apply(context.Background(), []byte("{}"))
+	assert.ErrorIs(t, err, coreerrors.ErrApplyTimeout)
+
+	// A caller deadline overrides the default.
+	node.ApplyTimeout = time.Minute
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err = node.Apply(ctx, []byte("{}"))
+	assert.ErrorIs(t, err, coreerrors.ErrApplyTimeout)
+	assert.Less(t, time.Since(start), 5*time.Second)
+
+	// An already expired context never submits the write.
+	expired, cancel := context.WithDeadline(context.Background(), time.Now().Add(-time.Second))
+	defer cancel()
+	_, err = node.Apply(expired, []byte("{}"))
+	assert.ErrorIs(t, err, coreerrors.ErrTimeout)
+}
+
+func TestRaftConfig_Apply(t *testing.T) {
+	defaultConfig := func() *raft.Config {
+		conf := raft.DefaultConfig()
+		conf.LocalID = "node1"
+		return conf
+	}
+
+	conf := defaultConfig()
+	RaftConfig{HeartbeatTimeout: 200 * time.Millisecond}.apply(conf)
+	assert.Equal(t, 200*time.Millisecond, conf.LeaderLeaseTimeout, "the default lease shrinks to the heartbeat timeout")
+	assert.False(t, conf.PreVoteDisabled)
+	assert.Equal(t, raft.DefaultConfig().TrailingLogs, conf.TrailingLogs)
+
+	conf = defaultConfig()
+	RaftConfig{DisablePreVote: true, LeaderLeaseTimeout: 300 * time.Millisecond}.apply(conf)
+	assert.True(t, conf.PreVoteDisabled)
+	assert.Equal(t, 300*time.Millisecond, conf.LeaderLeaseTimeout)
+	require.NoError(t, raft.ValidateConfig(conf))
+
+	conf = defaultConfig()
+	RaftConfig{LeaderLeaseTimeout: 2 * time.Second}.apply(conf)
+	assert.Error(t, raft.ValidateConfig(conf), "the lease may not exceed the heartbeat timeout")
+}
+
+func TestSizedLogStore(t *testing.T) {
+	inner := raft.NewInmemStore()
+	require.NoError(t, inner.StoreLogs([]*raft.Log{{Index: 1, Data: make([]byte, 100)}}))
+
+	s, err := newSizedLogStore(inner, 400)
+	require.NoError(t, err)
+	assert.Equal(t, int64(100), s.size.Load(), "existing entries are measured")
+
+	var calls int
+	full := func() { calls++ }
+	s.full.Store(&full)
+
+	for i := uint64(2); i <= 4; i++ {
+		require.NoError(t, s.StoreLog(&raft.Log{Index: i, Data: make([]byte, 100)}))
+	}
+	assert.Equal(t, int64(400), s.size.Load())
+	assert.Equal(t, 1, calls, "reaching the limit calls full")
+
+	// Truncating half the entries subtracts half the size.
+	require.NoError(t, s.DeleteRange(1, 2))
+	assert.Equal(t, int64(200), s.size.Load())
+	require.NoError(t, s.DeleteRange(3, 4))
+	assert.Equal(t, int64(0), s.size.Load())
+}
+
+// startInMem starts an in-memory Raft node with short timeouts, shut down
+// when the test ends.
+func startInMem(t *testing.T, id string, kv *store.Store, tuning RaftConfig) (*RaftNode, *raft.InmemTransport) {
+	t.Helper()
+	if tuning.HeartbeatTimeout == 0 {
+		tuning.HeartbeatTimeout, tuning.ElectionTimeout = 50*time.Millisecond, 50*time.Millisecond
+	}
+	tuning.Logger = hclog.NewNullLogger()
+	node, trans, err := SetupRaftInMem(id, NewFSM(kv), tuning)
+	require.NoError(t, err)
+	t.Cleanup(func() { node.Shutdown() })
+	return node, trans
+}
+
+// bootstrapInMem makes node the leader of a cluster of the given voters.
+func bootstrapInMem(t *testing.T, node *RaftNode, voters ...*raft.InmemTransport) {
+	t.Helper()
+	var servers []raft.Server
+	for i, trans := range voters {
+		servers = append(servers, raft.Server{ID: raft.ServerID(fmt.Sprint("node", i+1)), Address: trans.LocalAddr()})
+	}
+	require.NoError(t, node.Bootstrap(raft.Configuration{Servers: servers}))
+	require.Eventually(t, node.IsLeader, 2*time.Second, 10*time.Millisecond)
+}
+
+func TestSetupRaftInMem_Cluster(t *testing.T) {
+	var (
+		nodes      []*RaftNode
+		stores     []*store.Store
+		transports []*raft.InmemTransport
+	)
+	for i := 1; i <= 3; i++ {
+		kv := store.New()
+		node, trans := startInMem(t, fmt.Sprint("node", i), kv, RaftConfig{})
+		nodes, stores, transports = append(nodes, node), append(stores, kv), append(transports, trans)
+	}
+	ConnectInMem(transports...)
+	bootstrapInMem(t, nodes[0], transports...)
+
+	data, err := json.Marshal(service.Command{Op: service.SetOp, Key: "key", Value: "value"})
+	require.NoError(t, err)
+	_, err = nodes[0].Apply(context.Background(), data)
+	require.NoError(t, err)
+	for _, kv := range stores {
+		require.Eventually(t, func() bool {
+			raw, ok := kv.Get("key")
+			_, val := service.DecodeVersion(raw)
+			return ok && val == "value"
+		}, 2*time.Second, 10*time.Millisecond)
+	}
+
+	members, err := nodes[1].Members()
+	require.NoError(t, err)
+	assert.Len(t, members, 3)
+	assert.NoError(t, nodes[0].VerifyLeader())
+
+	// A new cluster moves to the newest command version on every node.
+	for _, node := range nodes {
+		require.Eventually(t, func() bool {
+			return node.ClusterVersion() == service.MaxCommandVersion
+		}, 2*time.Second, 10*time.Millisecond)
+	}
+}
+
+// The FSM's response to a command reaches the service through Apply, so
+// conditional writes report their outcome.
+func TestRaftNode_ApplyReturnsResponse(t *testing.T) {
+	kv := store.New()
+	node, trans := startInMem(t, "node1", kv, RaftConfig{})
+	bootstrapInMem(t, node, trans)
+	svc := service.New(kv, node, service.ConsistencyStrong)
+	ctx := context.Background()
+
+	version, err := svc.SetIf(ctx, "key", "v1", 0, ports.Precondition{IfAbsent: true})
+	require.NoError(t, err)
+	assert.NotZero(t, version)
+	_, err = svc.SetIf(ctx, "key", "v2", 0, ports.Precondition{IfAbsent: true})
+	assert.ErrorIs(t, err, coreerrors.ErrVersionMismatch)
+	assert.ErrorIs(t, svc.DeleteIf(ctx, "key", ports.Precondition{IfVersion: version + 1}), coreerrors.ErrVersionMismatch)
+
+	old, found, err := svc.GetSet(ctx, "key", "v3", 0)
+	require.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, "v1", old)
+
+	n, err := svc.Append(ctx, "key", "!")
+	require.NoError(t, err)
+	assert.Equal(t, 3, n)
+}
+
+func TestRaftNode_ApplyAsync(t *testing.T) {
+	kv := store.New()
+	node, trans := startInMem(t, "node1", kv, RaftConfig{})
+	bootstrapInMem(t, node, trans)
+
+	data, err := service.EncodeCommand(&service.Command{Op: service.SetOp, Key: "key", Value: "value", Version: service.CommandVersionBinary})
+	require.NoError(t, err)
+	done := make(chan error, 1)
+	require.NoError(t, node.ApplyAsync(data, func(_ interface{}, err error) { done <- err }))
+	select {
+	case err := <-done:
+		require.NoError(t, err)
+	case <-time.After(2 * time.Second):
+		t.Fatal("the outcome was never reported")
+	}
+	assert.Equal(t, "value", storedValue(kv, "key"))
+
+	// Followers refuse at once.
+	follower, _ := startInMem(t, "node2", store.New(), RaftConfig{})
+	assert.ErrorIs(t, follower.ApplyAsync(data, func(interface{}, error) {}), coreerrors.ErrNotLeader)
+}
+
+// phaseCount returns how many writes have been observed in phase.
+func phaseCount(t *testing.T, phase string) uint64 {
+	t.Helper()
+	var m dto.Metric
+	require.NoError(t, observability.RaftApplyPhaseSeconds.WithLabelValues(phase).(prometheus.Histogram).Write(&m))
+	return m.GetHistogram().GetSampleCount()
+}
+
+func TestRaftNode_ApplyPhases(t *testing.T) {
+	kv := store.New()
+	node, trans := startInMem(t, "node1", kv, RaftConfig{})
+	bootstrapInMem(t, node, trans)
+	svc := service.New(kv, node, service.ConsistencyStrong)
+
+	phases := []string{"encode", phaseQueue, phaseReplicate, phaseApply}
+	before := make(map[string]uint64)
+	for _, phase := range phases {
+		before[phase] = phaseCount(t, phase)
+	}
+	require.NoError(t, svc.Set(context.Background(), "key", "value", 0))
+	// The new cluster may be raising its version concurrently.
+	for _, phase := range phases {
+		assert.Greater(t, phaseCount(t, phase), before[phase], "phase %s", phase)
+	}
+}
+
+func TestRaftNode_AutoCompact(t *testing.T) {
+	node, trans := startInMem(t, "node1", store.New(), RaftConfig{LogMaxBytes: 4096})
+	bootstrapInMem(t, node, trans)
+	r, logs := node.Raft, node.logs
+
+	for i := 0; i < 50; i++ {
+		data, err := json.Marshal(service.Command{Op: service.SetOp, Key: fmt.Sprint("key", i), Value: strings.Repeat("v", 200)})
+		require.NoError(t, err)
+		_, err = node.Apply(context.Background(), data)
+		require.NoError(t, err)
+	}
+
+	// The log outgrew 4096 bytes, so a snapshot truncated it.
+	require.Eventually(t, func() bool {
+		infos, err := node.ListSnapshots()
+		return err == nil && len(infos) > 0
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Eventually(t, func() bool { return !node.compacting.Load() }, 5*time.Second, 10*time.Millisecond)
+	assert.Less(t, logs.size.Load(), int64(4096))
+	assert.Equal(t, raft.DefaultConfig().TrailingLogs, r.ReloadableConfig().TrailingLogs, "trailing logs are restored")
+
+	// Tuning changes the running configuration and the size limit.
+	require.NoError(t, node.Tune(RaftConfig{TrailingLogs: 100, HeartbeatTimeout: 100 * time.Millisecond, ElectionTimeout: 100 * time.Millisecond}))
+	assert.Equal(t, uint64(100), r.ReloadableConfig().TrailingLogs)
+	assert.Equal(t, int64(0), logs.maxBytes.Load())
+	assert.Error(t, node.Tune(RaftConfig{HeartbeatTimeout: time.Millisecond}), "timeouts are validated by Raft")
+}
+
+func TestRaftNode_RestoreFrom(t *testing.T) {
+	src := store.New()
+	src.Set("restored", "yes", 0)
+	var backup bytes.Buffer
+	require.NoError(t, src.Snapshot(&backup))
+
+	dst := store.New()
+	node, trans := startInMem(t, "node1", dst, RaftConfig{})
+	bootstrapInMem(t, node, trans)
+
+	require.NoError(t, node.RestoreFrom(&backup))
+	val, ok := dst.Get("restored")
+	require.True(t, ok)
+	assert.Equal(t, "yes", val)
+
+	members, err := node.Members()
+	require.NoError(t, err)
+	assert.Equal(t, []ports.Member{{ID: "node1", Address: string(trans.LocalAddr()), Voter: true, Leader: true}}, members)
+}
+
+func TestRaftNode_LocalAddress(t *testing.T) {
+	node, _ := startInMem(t, "node1", store.New(), RaftConfig{})
+
+	addr, err := node.LocalAddress()
+	require.NoError(t, err)
+	assert.Empty(t, addr, "a node without state is not a member")
+
+	require.NoError(t, node.Raft.BootstrapCluster(raft.Configuration{
+		Servers: []raft.Server{{ID: "node1", Address: "10.0.0.1:11000"}},
+	}).Error())
+	addr, err = node.LocalAddress()
+	require.NoError(t, err)
+	assert.Equal(t, "10.0.0.1:11000", addr)
+}
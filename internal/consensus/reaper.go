@@ -0,0 +1,243 @@
+package consensus
+
+import (
+	"cmp"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	coreerrors "distributed-cache-service/internal/core/errors"
+	"distributed-cache-service/internal/core/ports"
+	"distributed-cache-service/internal/observability"
+
+	"github.com/hashicorp/raft"
+)
+
+// reaper tracks, on the leader, the members whose heartbeats fail, and
+// removes those it has not heard from for RaftConfig.ReapAfter. A permanently
+// lost voter still counts towards the quorum, so leaving it in the
+// configuration brings the cluster closer to losing quorum with every
+// further failure.
+type reaper struct {
+	node      *RaftNode
+	after     time.Duration
+	confirm   bool
+	minVoters int
+
+	mu      sync.Mutex
+	failing map[raft.ServerID]*failingPeer
+
+	observations chan raft.Observation
+	observer     *raft.Observer
+	stop         chan struct{}
+}
+
+// failingPeer is a member whose heartbeats have been failing since its last
+// contact, in the given term.
+type failingPeer struct {
+	lastContact time.Time
+	term        uint64
+	announced   bool
+}
+
+func newReaper(n *RaftNode, tuning RaftConfig) *reaper {
+	r := &reaper{
+		node:         n,
+		after:        tuning.ReapAfter,
+		confirm:      tuning.ReapConfirm,
+		minVoters:    cmp.Or(tuning.ReapMinVoters, 3),
+		failing:      make(map[raft.ServerID]*failingPeer),
+		observations: make(chan raft.Observation, 64),
+		stop:         make(chan struct{}),
+	}
+	// Failed heartbeats are observed again and again, so the odd one dropped
+	// when the channel is full is harmless.
+	r.observer = raft.NewObserver(r.observations, false, func(o *raft.Observation) bool {
+		switch o.Data.(type) {
+		case raft.FailedHeartbeatObservation, raft.ResumedHeartbeatObservation, raft.LeaderObservation:
+			return true
+		}
+		return false
+	})
+	n.Raft.RegisterObserver(r.observer)
+	go r.run()
+	return r
+}
+
+func (r *reaper) run() {
+	ticker := time.NewTicker(min(max(r.after/4, 50*time.Millisecond), 10*time.Second))
+	defer ticker.Stop()
+	for {
+		select {
+		case <-r.stop:
+			return
+		case o := <-r.observations:
+			r.observe(o)
+		case <-ticker.C:
+			r.reap()
+		}
+	}
+}
+
+func (r *reaper) close() {
+	r.node.Raft.DeregisterObserver(r.observer)
+	close(r.stop)
+}
+
+func (r *reaper) observe(o raft.Observation) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	switch o := o.Data.(type) {
+	case raft.FailedHeartbeatObservation:
+		if _, ok := r.failing[o.PeerID]; ok {
+			return
+		}
+		// A peer that has not answered since this node took over has no
+		// last contact: count from its first failure.
+		last := o.LastContact
+		if last.IsZero() {
+			last = time.Now()
+		}
+		r.failing[o.PeerID] = &failingPeer{lastContact: last, term: r.node.Raft.CurrentTerm()}
+	case raft.ResumedHeartbeatObservation:
+		delete(r.failing, o.PeerID)
+	case raft.LeaderObservation:
+		// What a previous leadership learnt about its followers is stale.
+		clear(r.failing)
+	}
+}
+
+// reap removes the first dead member, unless that would leave fewer voters
+// than the minimum, or, with ReapConfirm, logs the members that became due
+// for an operator to remove.
+func (r *reaper) reap() {
+	dead, err := r.dead()
+	if err != nil || len(dead) == 0 {
+		return
+	}
+	if r.confirm {
+		r.announce(dead, "remove it with POST /admin/cluster/reap?id=")
+		return
+	}
+	voters, err := r.voters()
+	if err != nil {
+		return
+	}
+	if voters[raft.ServerID(dead[0].ID)] && len(voters) <= r.minVoters {
+		r.announce(dead[:1], fmt.Sprintf("the cluster is down to %d voters, the fewest reaping leaves, so remove it with POST /admin/cluster/reap?id=", len(voters)))
+		return
+	}
+	if err := r.remove(dead[0].ID); err != nil {
+		log.Printf("Failed to remove dead member %s: %v", dead[0].ID, err)
+	}
+}
+
+// announce logs, once per outage, that the dead members have been
+// unreachable, with what to do about it, followed by the member's ID.
+func (r *reaper) announce(dead []ports.DeadMember, action string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, m := range dead {
+		if p := r.failing[raft.ServerID(m.ID)]; p != nil && !p.announced {
+			p.announced = true
+			log.Printf("Member %s (%s) has been unreachable since %s; %s%s",
+				m.ID, m.Address, m.LastContact.Format(time.RFC3339), action, m.ID)
+		}
+	}
+}
+
+// voters returns the voters of the configuration.
+func (r *reaper) voters() (map[raft.ServerID]bool, error) {
+	f := r.node.Raft.GetConfiguration()
+	if err := f.Error(); err != nil {
+		return nil, translateError(err)
+	}
+	voters := make(map[raft.ServerID]bool)
+	for _, srv := range f.Configuration().Servers {
+		if srv.Suffrage == raft.Voter {
+			voters[srv.ID] = true
+		}
+	}
+	return voters, nil
+}
+
+// dead returns the members of the configuration the leader has not heard
+// from for the reaper's duration, longest silent first.
+func (r *reaper) dead() ([]ports.DeadMember, error) {
+	n := r.node
+	if !n.IsLeader() {
+		r.mu.Lock()
+		clear(r.failing)
+		r.mu.Unlock()
+		return nil, fmt.Errorf("%w: only the leader tracks dead members", coreerrors.ErrNotLeader)
+	}
+	f := n.Raft.GetConfiguration()
+	if err := f.Error(); err != nil {
+		return nil, translateError(err)
+	}
+	term := n.Raft.CurrentTerm()
+	now := time.Now()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	members := make(map[raft.ServerID]bool)
+	var dead []ports.DeadMember
+	for _, srv := range f.Configuration().Servers {
+		members[srv.ID] = true
+		p := r.failing[srv.ID]
+		if srv.ID == n.localID || p == nil || p.term != term || now.Sub(p.lastContact) < r.after {
+			continue
+		}
+		dead = append(dead, ports.DeadMember{ID: string(srv.ID), Address: string(srv.Address), LastContact: p.lastContact})
+	}
+	for id := range r.failing {
+		if !members[id] {
+			delete(r.failing, id)
+		}
+	}
+	sort.Slice(dead, func(i, j int) bool { return dead[i].LastContact.Before(dead[j].LastContact) })
+	return dead, nil
+}
+
+func (r *reaper) remove(id string) error {
+	if err := r.node.RemoveServer(id); err != nil {
+		return err
+	}
+	r.mu.Lock()
+	delete(r.failing, raft.ServerID(id))
+	r.mu.Unlock()
+	observability.RaftReapedTotal.Inc()
+	log.Printf("Removed member %s from the cluster after losing contact with it for over %s", id, r.after)
+	return nil
+}
+
+// DeadMembers returns the members this node, as leader, has not heard from
+// for RaftConfig.ReapAfter. Unless ReapConfirm is set, they are removed
+// soon after they become due.
+func (n *RaftNode) DeadMembers() ([]ports.DeadMember, error) {
+	if n.reaper == nil {
+		return nil, fmt.Errorf("%w: reaping dead members is not enabled", coreerrors.ErrUnsupported)
+	}
+	return n.reaper.dead()
+}
+
+// Reap removes the dead member id from the cluster, as an operator's
+// confirmation under ReapConfirm. Members that are not dead are refused, so
+// a healthy node cannot be removed by mistake; use RemoveServer for those.
+func (n *RaftNode) Reap(id string) error {
+	if n.reaper == nil {
+		return fmt.Errorf("%w: reaping dead members is not enabled", coreerrors.ErrUnsupported)
+	}
+	dead, err := n.reaper.dead()
+	if err != nil {
+		return err
+	}
+	for _, m := range dead {
+		if m.ID == id {
+			return n.reaper.remove(id)
+		}
+	}
+	return fmt.Errorf("%w: %q is not a dead member", coreerrors.ErrInvalidArgument, id)
+}
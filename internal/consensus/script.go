@@ -0,0 +1,100 @@
+package consensus
+
+import (
+	"time"
+
+	"distributed-cache-service/internal/core/service"
+	"distributed-cache-service/internal/events"
+	"distributed-cache-service/internal/script"
+
+	"github.com/hashicorp/raft"
+)
+
+// eval runs an EVAL command. The script's writes are buffered and applied only
+// if it succeeds, so a script that fails, or runs out of steps, leaves the store
+// untouched. Every write gets the entry's index as its version, and TTLs set
+// by the script count from the entry's timestamp, like cache.time.
+func (f *FSM) eval(c *service.Command, log *raft.Log) (interface{}, error) {
+	s, err := script.Compile(c.Script)
+	if err != nil {
+		return nil, err
+	}
+	env := &scriptEnv{fsm: f, now: appliedAt(log), writes: make(map[string]*scriptWrite)}
+	reply, err := s.Run(env, c.Keys, c.Args)
+	if err != nil {
+		return nil, err
+	}
+	// Apply in the order keys were first written, which is the same on every node.
+	for _, key := range env.order {
+		w := env.writes[key]
+		if w.deleted {
+			f.store.Delete(key)
+			f.publish(events.Delete, key, log.Index)
+			f.enqueue(service.DeleteOp, key, "", 0, log)
+			continue
+		}
+		var expiresAt time.Time
+		if w.ttl > 0 {
+			expiresAt = env.now.Add(w.ttl)
+		}
+		stored := f.cipher.Escape(w.value)
+		f.store.SetExpiresAt(key, service.EncodeVersion(log.Index, stored), expiresAt)
+		f.publish(events.Set, key, log.Index)
+		f.enqueue(service.SetOp, key, stored, w.ttl, log)
+	}
+	return reply, nil
+}
+
+// scriptWrite is a pending write by a script. A delete of a key that did not
+// exist is not recorded.
+type scriptWrite struct {
+	value   string
+	ttl     time.Duration
+	deleted bool
+}
+
+// scriptEnv is the script.Env of an EVAL: reads see the script's own pending
+// writes, then the store.
+type scriptEnv struct {
+	fsm    *FSM
+	now    time.Time
+	writes map[string]*scriptWrite
+	order  []string
+}
+
+func (e *scriptEnv) Get(key string) (string, bool) {
+	if w, ok := e.writes[key]; ok {
+		return w.value, !w.deleted
+	}
+	stored, found := e.fsm.current(key, e.now)
+	if !found {
+		return "", false
+	}
+	value, err := e.fsm.cipher.Decode(stored)
+	if err != nil {
+		// A value that cannot be decoded cannot be read by GET either.
+		return "", false
+	}
+	return value, true
+}
+
+func (e *scriptEnv) Set(key, value string, ttl time.Duration) {
+	e.write(key, &scriptWrite{value: value, ttl: ttl})
+}
+
+func (e *scriptEnv) Delete(key string) bool {
+	_, found := e.Get(key)
+	if found {
+		e.write(key, &scriptWrite{deleted: true})
+	}
+	return found
+}
+
+func (e *scriptEnv) Now() time.Time { return e.now }
+
+func (e *scriptEnv) write(key string, w *scriptWrite) {
+	if _, ok := e.writes[key]; !ok {
+		e.order = append(e.order, key)
+	}
+	e.writes[key] = w
+}
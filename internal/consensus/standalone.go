@@ -0,0 +1,146 @@
+package consensus
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+
+	coreerrors "distributed-cache-service/internal/core/errors"
+	"distributed-cache-service/internal/core/ports"
+	"distributed-cache-service/internal/observability"
+
+	"github.com/hashicorp/raft"
+)
+
+// errStandalone is returned by cluster operations on a standalone node.
+var errStandalone = fmt.Errorf("%w: the node runs standalone, without Raft", coreerrors.ErrUnsupported)
+
+// ensure implementation
+var (
+	_ ports.Consensus        = (*Standalone)(nil)
+	_ ports.ClusterAdmin     = (*Standalone)(nil)
+	_ ports.LagReporter      = (*Standalone)(nil)
+	_ ports.PositionReporter = (*Standalone)(nil)
+)
+
+// Standalone applies commands straight to the FSM, with no Raft log, peers
+// or data directory. It is always the leader of a cluster of one, so the
+// service runs unchanged on top of it; cluster operations fail with
+// ErrUnsupported. It suits local development and tests.
+type Standalone struct {
+	id  string
+	fsm *FSM
+
+	// mu serializes applies, as Raft's FSM goroutine would.
+	mu    sync.Mutex
+	index uint64
+}
+
+// NewStandalone returns a standalone node applying to fsm.
+func NewStandalone(nodeID string, fsm *FSM) *Standalone {
+	observability.RaftLeader.Set(1)
+	// Indexes become key versions. Starting from the clock keeps them
+	// increasing across restarts when the store persists, e.g. with an AOF.
+	return &Standalone{id: nodeID, fsm: fsm, index: uint64(time.Now().UnixNano())}
+}
+
+// Apply applies cmd to the FSM and returns its response, or the error the
+// FSM responded with.
+func (s *Standalone) Apply(ctx context.Context, cmd []byte) (interface{}, error) {
+	if ctx.Err() != nil {
+		return nil, fmt.Errorf("%w: deadline passed before the write was submitted", coreerrors.ErrTimeout)
+	}
+	submitted := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.index++
+	resp := s.fsm.Apply(&raft.Log{Index: s.index, Type: raft.LogCommand, Data: cmd, AppendedAt: time.Now()})
+	observePhases(s.fsm, s.index, submitted)
+	if err, ok := resp.(error); ok {
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (s *Standalone) IsLeader() bool {
+	return true
+}
+
+func (s *Standalone) VerifyLeader() error {
+	return nil
+}
+
+// WaitForLeader returns at once: a standalone node always leads.
+func (s *Standalone) WaitForLeader(time.Duration) error {
+	return nil
+}
+
+// ReplicationLag is always zero.
+func (s *Standalone) ReplicationLag() (uint64, error) {
+	return 0, nil
+}
+
+// AppliedPosition returns the index of the last command applied. There are no
+// elections, so the term is always 0.
+func (s *Standalone) AppliedPosition() (uint64, uint64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.index, 0
+}
+
+func (s *Standalone) AddVoter(id, addr string) error {
+	return errStandalone
+}
+
+func (s *Standalone) RemoveServer(id string) error {
+	return errStandalone
+}
+
+func (s *Standalone) TransferLeadership(id, addr string) error {
+	return errStandalone
+}
+
+// Snapshot fails: there is no log to truncate. Backups still work, since
+// they are taken from the store.
+func (s *Standalone) Snapshot() (ports.SnapshotInfo, error) {
+	return ports.SnapshotInfo{}, errStandalone
+}
+
+func (s *Standalone) Compact() (uint64, error) {
+	return 0, errStandalone
+}
+
+// ListSnapshots returns no snapshots.
+func (s *Standalone) ListSnapshots() ([]ports.SnapshotInfo, error) {
+	return []ports.SnapshotInfo{}, nil
+}
+
+// RestoreFrom replaces the state with the snapshot read from r.
+func (s *Standalone) RestoreFrom(r io.Reader) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.fsm.Restore(io.NopCloser(r))
+}
+
+// Members returns the node itself.
+func (s *Standalone) Members() ([]ports.Member, error) {
+	return []ports.Member{{ID: s.id, Voter: true, Leader: true}}, nil
+}
+
+func (s *Standalone) State() string {
+	return "Standalone"
+}
+
+// Leader returns "": the node has no Raft address.
+func (s *Standalone) Leader() string {
+	return ""
+}
+
+func (s *Standalone) Stats() map[string]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return map[string]string{"state": s.State(), "applied_index": strconv.FormatUint(s.index, 10)}
+}
@@ -0,0 +1,81 @@
+package consensus
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	coreerrors "distributed-cache-service/internal/core/errors"
+	"distributed-cache-service/internal/core/ports"
+	"distributed-cache-service/internal/core/service"
+	"distributed-cache-service/internal/store"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStandalone_Service(t *testing.T) {
+	kv := store.New()
+	node := NewStandalone("node1", NewFSM(kv))
+	svc := service.New(kv, node, service.ConsistencyStrong)
+	ctx := context.Background()
+
+	require.NoError(t, svc.Set(ctx, "key", "value", 0))
+	val, version, err := svc.GetVersioned(ctx, "key")
+	require.NoError(t, err)
+	assert.Equal(t, "value", val)
+
+	_, err = svc.SetIf(ctx, "key", "stale", 0, ports.Precondition{IfVersion: version + 1})
+	assert.ErrorIs(t, err, coreerrors.ErrVersionMismatch)
+	next, err := svc.SetIf(ctx, "key", "changed", 0, ports.Precondition{IfVersion: version})
+	require.NoError(t, err)
+	assert.Greater(t, next, version)
+
+	// Versions keep increasing when a node restarts over a persistent store.
+	restarted := NewStandalone("node1", NewFSM(kv))
+	_, err = service.New(kv, restarted, service.ConsistencyStrong).SetIf(ctx, "key", "again", 0, ports.Precondition{IfVersion: next})
+	require.NoError(t, err)
+	_, latest, err := svc.GetVersioned(ctx, "key")
+	require.NoError(t, err)
+	assert.Greater(t, latest, next)
+
+	require.NoError(t, svc.Delete(ctx, "key"))
+	_, err = svc.Get(ctx, "key")
+	assert.ErrorIs(t, err, coreerrors.ErrNotFound)
+}
+
+func TestStandalone_ClusterOperations(t *testing.T) {
+	node := NewStandalone("node1", NewFSM(store.New()))
+
+	assert.True(t, node.IsLeader())
+	assert.NoError(t, node.VerifyLeader())
+	assert.ErrorIs(t, node.AddVoter("node2", "127.0.0.1:11001"), coreerrors.ErrUnsupported)
+	assert.ErrorIs(t, node.RemoveServer("node2"), coreerrors.ErrUnsupported)
+	_, err := node.Snapshot()
+	assert.ErrorIs(t, err, coreerrors.ErrUnsupported)
+	snapshots, err := node.ListSnapshots()
+	require.NoError(t, err)
+	assert.Empty(t, snapshots)
+
+	members, err := node.Members()
+	require.NoError(t, err)
+	assert.Equal(t, []ports.Member{{ID: "node1", Voter: true, Leader: true}}, members)
+}
+
+func TestStandalone_RestoreFrom(t *testing.T) {
+	src := store.New()
+	src.Set("restored", "yes", 0)
+	var backup bytes.Buffer
+	require.NoError(t, src.Snapshot(&backup))
+
+	dst := store.New()
+	dst.Set("dropped", "yes", 0)
+	node := NewStandalone("node1", NewFSM(dst))
+	require.NoError(t, node.RestoreFrom(&backup))
+
+	val, ok := dst.Get("restored")
+	require.True(t, ok)
+	assert.Equal(t, "yes", val)
+	_, ok = dst.Get("dropped")
+	assert.False(t, ok)
+}
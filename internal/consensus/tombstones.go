@@ -0,0 +1,91 @@
+package consensus
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	coreerrors "distributed-cache-service/internal/core/errors"
+	"distributed-cache-service/internal/core/service"
+	"distributed-cache-service/internal/observability"
+)
+
+// tombstones remember when keys were deleted, so that a write made before a
+// delete, but replicated after it from elsewhere, does not resurrect the key
+// (see service.WithTombstoneRetention).
+//
+// Like the dedup window, they are driven only by replicated commands: deletes
+// that carry an origin time add them and TOMBSTONEGC drops them, so every
+// replica holds the same set. They are carried in FSM snapshots.
+type tombstones map[string]int64 // key -> deleted at, in Unix nanoseconds
+
+// record remembers that key was deleted at at, keeping the latest delete.
+func (t tombstones) record(key string, at int64) {
+	if prev, ok := t[key]; !ok || at > prev {
+		t[key] = at
+		observability.TombstonesHeld.Set(float64(len(t)))
+	}
+}
+
+// check refuses a write first made at origin if key was deleted at or after it.
+func (t tombstones) check(key string, origin int64) error {
+	if at, ok := t[key]; ok && at >= origin {
+		observability.SupersededWritesTotal.Inc()
+		return fmt.Errorf("%w: key was deleted at %s, after the write was made", coreerrors.ErrVersionMismatch,
+			time.Unix(0, at).UTC().Format(time.RFC3339Nano))
+	}
+	return nil
+}
+
+// gc drops the tombstones of deletes made before cutoff and returns how many.
+func (t tombstones) gc(cutoff int64) int {
+	n := 0
+	for key, at := range t {
+		if at < cutoff {
+			delete(t, key)
+			n++
+		}
+	}
+	observability.TombstonesHeld.Set(float64(len(t)))
+	return n
+}
+
+func (t tombstones) clone() []tombstone {
+	if len(t) == 0 {
+		return nil
+	}
+	entries := make([]tombstone, 0, len(t))
+	for key, at := range t {
+		entries = append(entries, tombstone{key: key, at: at})
+	}
+	// Sorted, so that replicas write identical snapshots.
+	slices.SortFunc(entries, func(a, b tombstone) int { return strings.Compare(a.key, b.key) })
+	return entries
+}
+
+// reset replaces the tombstones with entries, from a snapshot.
+func (t tombstones) reset(entries []tombstone) {
+	clear(t)
+	for _, e := range entries {
+		t[e.key] = e.at
+	}
+	observability.TombstonesHeld.Set(float64(len(t)))
+}
+
+type tombstone struct {
+	key string
+	at  int64 // Unix nanoseconds
+}
+
+// checkTombstone refuses a set that carries an origin time if its key was
+// deleted since.
+func (f *FSM) checkTombstone(c *service.Command) error {
+	switch c.Op {
+	case service.SetOp, service.GetSetOp, service.GetOrSetOp:
+		if c.OriginTime != 0 {
+			return f.tombstones.check(c.Key, c.OriginTime)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,94 @@
+package consensus
+
+import (
+	"fmt"
+	"time"
+
+	coreerrors "distributed-cache-service/internal/core/errors"
+	"distributed-cache-service/internal/core/ports"
+	"distributed-cache-service/internal/core/service"
+	"distributed-cache-service/internal/events"
+
+	"github.com/hashicorp/raft"
+)
+
+// applyTxn evaluates a transaction's comparisons and applies the branch they
+// select, judging expiry at now. Every op is validated first, so a malformed
+// transaction is rejected as a unit; once validated, the ops cannot fail.
+func (f *FSM) applyTxn(t *service.TxnCommand, log *raft.Log, now time.Time) (ports.TxnResult, error) {
+	if t == nil {
+		return ports.TxnResult{}, fmt.Errorf("%w: transaction has no body", coreerrors.ErrInvalidArgument)
+	}
+	result := ports.TxnResult{Succeeded: true}
+	for _, cmp := range t.Compares {
+		ok, err := f.compare(cmp, now)
+		if err != nil {
+			return ports.TxnResult{}, err
+		}
+		if !ok {
+			result.Succeeded = false
+			break
+		}
+	}
+	ops := t.Success
+	if !result.Succeeded {
+		ops = t.Failure
+	}
+	for _, c := range ops {
+		switch c.Op {
+		case service.GetOp, service.SetOp, service.DeleteOp:
+		default:
+			return ports.TxnResult{}, fmt.Errorf("%w: %s is not allowed in a transaction", coreerrors.ErrInvalidArgument, c.Op)
+		}
+	}
+
+	result.Results = make([]ports.TxnOpResult, len(ops))
+	for i, c := range ops {
+		r := &result.Results[i]
+		switch c.Op {
+		case service.GetOp:
+			if raw, found := f.store.GetAt(c.Key, now); found {
+				r.Version, r.Value = service.DecodeVersion(raw)
+				r.Found = true
+			}
+		case service.SetOp:
+			stored := c.StoredValue()
+			f.store.SetExpiresAt(c.Key, service.EncodeVersion(log.Index, stored), c.Expiry())
+			f.publish(events.Set, c.Key, log.Index)
+			f.enqueue(service.SetOp, c.Key, stored, c.TTL, log)
+			r.Version = log.Index
+		case service.DeleteOp:
+			_, r.Found = f.store.GetAt(c.Key, now)
+			f.store.Delete(c.Key)
+			f.publish(events.Delete, c.Key, log.Index)
+			f.enqueue(service.DeleteOp, c.Key, "", 0, log)
+		}
+	}
+	return result, nil
+}
+
+// compare reports whether cmp holds for the state of its key at now.
+func (f *FSM) compare(cmp ports.Compare, now time.Time) (bool, error) {
+	raw, found := f.store.GetAt(cmp.Key, now)
+	var equal bool
+	switch cmp.Target {
+	case ports.CompareVersion:
+		var version uint64
+		if found {
+			version, _ = service.DecodeVersion(raw)
+		}
+		equal = version == cmp.Version
+	case ports.CompareValue:
+		if found {
+			_, stored := service.DecodeVersion(raw)
+			value, err := f.cipher.Decode(stored)
+			if err != nil {
+				return false, err
+			}
+			equal = value == cmp.Value
+		}
+	default:
+		return false, fmt.Errorf("%w: unknown compare target %d", coreerrors.ErrInvalidArgument, cmp.Target)
+	}
+	return equal != cmp.NotEqual, nil
+}
@@ -0,0 +1,423 @@
+package consensus
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/boltdb/bolt"
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/raft"
+	raftboltdb "github.com/hashicorp/raft-boltdb"
+)
+
+// ErrCorruptData is returned when a Raft data directory fails verification.
+var ErrCorruptData = errors.New("raft data directory is corrupt")
+
+const (
+	raftDBFile    = "raft.db"
+	snapshotsDir  = "snapshots"
+	quarantineDir = "quarantine"
+)
+
+// Stable store keys, as hashicorp/raft writes them.
+var (
+	keyCurrentTerm  = []byte("CurrentTerm")
+	keyLastVoteTerm = []byte("LastVoteTerm")
+	keyLastVoteCand = []byte("LastVoteCand")
+)
+
+// SnapshotCheck is the outcome of verifying one snapshot directory.
+type SnapshotCheck struct {
+	ID    string
+	Index uint64
+	Term  uint64
+	// Err is why the snapshot is unusable: a torn write, unreadable
+	// metadata or a CRC mismatch. Raft skips such snapshots on startup.
+	Err error
+}
+
+// VerifyReport describes the state of a Raft data directory.
+type VerifyReport struct {
+	Dir string
+	// DBErr is why raft.db could not be read, if it could not.
+	DBErr error
+	// LogFirst and LogLast bound the log; both are zero if it is empty.
+	LogFirst, LogLast uint64
+	// BadLogs lists log indexes that are missing or fail to decode.
+	BadLogs     []uint64
+	CurrentTerm uint64
+	// Snapshots lists every snapshot directory, newest first.
+	Snapshots []SnapshotCheck
+	// Problems says what is wrong, in words; empty if the directory is sound.
+	Problems []string
+	// Fatal reports whether Raft cannot start correctly from the directory.
+	Fatal bool
+}
+
+// OK reports whether no problem was found.
+func (r *VerifyReport) OK() bool {
+	return len(r.Problems) == 0
+}
+
+// NewestSnapshot returns the newest snapshot that passed verification.
+func (r *VerifyReport) NewestSnapshot() (SnapshotCheck, bool) {
+	for _, s := range r.Snapshots {
+		if s.Err == nil {
+			return s, true
+		}
+	}
+	return SnapshotCheck{}, false
+}
+
+// Guidance says what to do about the problems found.
+func (r *VerifyReport) Guidance() string {
+	switch {
+	case r.OK():
+		return "No action needed."
+	case !r.Fatal:
+		return fmt.Sprintf("Raft can start, skipping unusable snapshots. Run `cachectl raft recover -dir %s` to move them aside.", r.Dir)
+	case r.recoverable():
+		return fmt.Sprintf("Stop the node and run `cachectl raft recover -dir %s` to rebuild the log from the newest valid snapshot and the readable entries after it. "+
+			"Entries lost with the log are replicated again by the leader; a single-node cluster loses the writes after the snapshot.", r.Dir)
+	default:
+		return fmt.Sprintf("No valid snapshot covers the unreadable log entries, so this node cannot recover on its own. "+
+			"Remove the node from the cluster, empty %s and join it again, or restore the cluster from a backup with -restore_from.", r.Dir)
+	}
+}
+
+// recoverable reports whether RecoverDataDir can produce a usable directory:
+// some valid snapshot, or a log that is readable from its first entry.
+func (r *VerifyReport) recoverable() bool {
+	if _, ok := r.NewestSnapshot(); ok {
+		return true
+	}
+	return r.DBErr == nil && r.LogFirst <= 1 && (len(r.BadLogs) == 0 || r.BadLogs[0] > r.LogFirst)
+}
+
+// VerifyDataDir checks a Raft data directory without modifying it: the
+// consistency of the BoltDB file, that every log entry decodes, and that each
+// snapshot is complete and matches its CRC. It fails only if the directory
+// cannot be read at all, or raft.db is locked by a running node.
+func VerifyDataDir(dir string) (*VerifyReport, error) {
+	if _, err := os.Stat(dir); err != nil {
+		return nil, err
+	}
+	r := &VerifyReport{Dir: dir}
+
+	snaps, err := checkSnapshots(dir)
+	if err != nil {
+		return nil, err
+	}
+	r.Snapshots = snaps
+	for _, s := range snaps {
+		if s.Err != nil {
+			r.Problems = append(r.Problems, fmt.Sprintf("snapshot %s is unusable: %v", s.ID, s.Err))
+		}
+	}
+
+	dbPath := filepath.Join(dir, raftDBFile)
+	if _, err := os.Stat(dbPath); errors.Is(err, os.ErrNotExist) {
+		return r, nil
+	}
+	if err := checkBolt(dbPath); err != nil {
+		if errors.Is(err, bolt.ErrTimeout) {
+			return nil, fmt.Errorf("%s is locked: stop the node first", dbPath)
+		}
+		r.DBErr = err
+	} else {
+		r.DBErr = r.checkLog(dbPath)
+	}
+	if r.DBErr != nil {
+		r.Problems = append(r.Problems, fmt.Sprintf("%s is corrupt: %v", raftDBFile, r.DBErr))
+		r.Fatal = true
+	}
+	if len(r.BadLogs) > 0 {
+		r.Problems = append(r.Problems, fmt.Sprintf("%d log entries are unreadable, first at index %d", len(r.BadLogs), r.BadLogs[0]))
+		r.Fatal = true
+	}
+
+	// Raft restores the newest valid snapshot and replays the log after it,
+	// so the log must continue where the snapshot ends.
+	start := uint64(0)
+	if s, ok := r.NewestSnapshot(); ok {
+		start = s.Index
+	}
+	if r.DBErr == nil && r.LogFirst > start+1 {
+		r.Problems = append(r.Problems, fmt.Sprintf("log starts at index %d but the newest valid snapshot ends at %d: entries in between are lost", r.LogFirst, start))
+		r.Fatal = true
+	}
+	return r, nil
+}
+
+// checkBolt runs BoltDB's consistency check over path, opened read-only.
+// BoltDB panics on some kinds of corruption, which are reported as errors.
+func checkBolt(path string) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("bolt: %v", p)
+		}
+	}()
+	db, err := bolt.Open(path, 0600, &bolt.Options{ReadOnly: true, Timeout: time.Second})
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+	return db.View(func(tx *bolt.Tx) error {
+		var problems []string
+		for err := range tx.Check() {
+			problems = append(problems, err.Error())
+		}
+		if len(problems) > 0 {
+			return fmt.Errorf("consistency check: %s", strings.Join(problems, "; "))
+		}
+		return nil
+	})
+}
+
+// checkLog reads every log entry and the current term from path.
+func (r *VerifyReport) checkLog(path string) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("bolt: %v", p)
+		}
+	}()
+	store, err := raftboltdb.New(raftboltdb.Options{Path: path, BoltOptions: &bolt.Options{ReadOnly: true, Timeout: time.Second}})
+	if err != nil {
+		return err
+	}
+	defer store.Close()
+	if r.LogFirst, err = store.FirstIndex(); err != nil {
+		return err
+	}
+	if r.LogLast, err = store.LastIndex(); err != nil {
+		return err
+	}
+	var entry raft.Log
+	for i := r.LogFirst; r.LogFirst > 0 && i <= r.LogLast; i++ {
+		if err := store.GetLog(i, &entry); err != nil || entry.Index != i {
+			r.BadLogs = append(r.BadLogs, i)
+		}
+	}
+	r.CurrentTerm, err = store.GetUint64(keyCurrentTerm)
+	if err != nil && !errors.Is(err, raftboltdb.ErrKeyNotFound) {
+		return fmt.Errorf("read current term: %w", err)
+	}
+	return nil
+}
+
+// checkSnapshots verifies every directory under dir/snapshots, newest first.
+func checkSnapshots(dir string) ([]SnapshotCheck, error) {
+	path := filepath.Join(dir, snapshotsDir)
+	entries, err := os.ReadDir(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	store, err := raft.NewFileSnapshotStoreWithLogger(dir, len(entries)+1, hclog.NewNullLogger())
+	if err != nil {
+		return nil, err
+	}
+
+	var checks []SnapshotCheck
+	for _, e := range entries {
+		if !e.IsDir() {
+			continue
+		}
+		c := SnapshotCheck{ID: e.Name()}
+		if strings.HasSuffix(c.ID, ".tmp") {
+			c.Err = errors.New("torn: the node stopped while writing it")
+			checks = append(checks, c)
+			continue
+		}
+		// Open reads the whole state file to check its CRC.
+		meta, rc, err := store.Open(c.ID)
+		if err != nil {
+			c.Err = err
+		} else {
+			c.Index, c.Term = meta.Index, meta.Term
+			rc.Close()
+		}
+		checks = append(checks, c)
+	}
+	sort.Slice(checks, func(i, j int) bool { return parseSnapshotAge(checks[i].ID).newer(parseSnapshotAge(checks[j].ID)) })
+	return checks, nil
+}
+
+// snapshotAge orders snapshots by the term, index and creation time that
+// their IDs are made of.
+type snapshotAge [3]uint64
+
+func (a snapshotAge) newer(b snapshotAge) bool {
+	for i := range a {
+		if a[i] != b[i] {
+			return a[i] > b[i]
+		}
+	}
+	return false
+}
+
+func parseSnapshotAge(id string) snapshotAge {
+	var a snapshotAge
+	fmt.Sscanf(strings.TrimSuffix(id, ".tmp"), "%d-%d-%d", &a[0], &a[1], &a[2])
+	return a
+}
+
+// RecoveryResult says what RecoverDataDir changed.
+type RecoveryResult struct {
+	// Quarantine is where the replaced files were moved.
+	Quarantine string
+	// Moved lists the files and snapshots moved to Quarantine.
+	Moved []string
+	// Snapshot is the snapshot Raft will restore on startup, if any.
+	Snapshot *SnapshotCheck
+	// LogFirst and LogLast bound the rebuilt log; both are zero if it is empty.
+	LogFirst, LogLast uint64
+}
+
+// RecoverDataDir repairs a Raft data directory that failed verification so
+// that Raft starts from the newest valid snapshot. Unusable snapshots are
+// moved to a quarantine directory. If raft.db is corrupt, or discardLogs is
+// set, it is rebuilt: the stable store keys are carried over where readable,
+// followed by the contiguous run of readable log entries that continues the
+// snapshot. Nothing is deleted; the replaced files stay in the quarantine
+// directory. The node must be stopped.
+func RecoverDataDir(dir string, discardLogs bool) (*RecoveryResult, error) {
+	report, err := VerifyDataDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	if report.Fatal && !report.recoverable() {
+		return nil, fmt.Errorf("%w: %s", ErrCorruptData, report.Guidance())
+	}
+	if _, ok := report.NewestSnapshot(); discardLogs && !ok {
+		return nil, errors.New("discarding the log needs a valid snapshot to restore")
+	}
+	res := &RecoveryResult{Quarantine: filepath.Join(dir, quarantineDir, time.Now().UTC().Format("20060102T150405Z"))}
+	if s, ok := report.NewestSnapshot(); ok {
+		res.Snapshot = &s
+	}
+	moveAside := func(rel string) error {
+		dst := filepath.Join(res.Quarantine, rel)
+		if err := os.MkdirAll(filepath.Dir(dst), 0700); err != nil {
+			return err
+		}
+		if err := os.Rename(filepath.Join(dir, rel), dst); err != nil {
+			return err
+		}
+		res.Moved = append(res.Moved, rel)
+		return nil
+	}
+
+	for _, s := range report.Snapshots {
+		if s.Err != nil {
+			if err := moveAside(filepath.Join(snapshotsDir, s.ID)); err != nil {
+				return res, err
+			}
+		}
+	}
+
+	dbPath := filepath.Join(dir, raftDBFile)
+	if _, err := os.Stat(dbPath); err != nil || (!report.Fatal && !discardLogs) {
+		return res, nil
+	}
+	rebuilt := dbPath + ".rebuild"
+	if err := rebuildLog(dbPath, rebuilt, report, discardLogs, res); err != nil {
+		os.Remove(rebuilt)
+		return res, fmt.Errorf("rebuild %s: %w", raftDBFile, err)
+	}
+	if err := moveAside(raftDBFile); err != nil {
+		return res, err
+	}
+	return res, os.Rename(rebuilt, dbPath)
+}
+
+// rebuildLog writes a fresh log store to dst from what is readable in src.
+func rebuildLog(src, dst string, report *VerifyReport, discardLogs bool, res *RecoveryResult) error {
+	out, err := raftboltdb.NewBoltStore(dst)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	start := uint64(1)
+	term := uint64(0)
+	if res.Snapshot != nil {
+		start, term = res.Snapshot.Index+1, res.Snapshot.Term
+	}
+
+	// Whatever cannot be read from a corrupt src is left out.
+	if in, err := openReadOnly(src); err == nil {
+		defer in.Close()
+		copied, err := copyReadable(in, out, start, report.LogLast, discardLogs)
+		if err != nil {
+			return err
+		}
+		if copied.LogLast > 0 {
+			res.LogFirst, res.LogLast, term = copied.LogFirst, copied.LogLast, copied.term
+		}
+	}
+	// Raft refuses to start with a term older than its newest entry.
+	if cur, err := out.GetUint64(keyCurrentTerm); err != nil || cur < term {
+		return out.SetUint64(keyCurrentTerm, term)
+	}
+	return nil
+}
+
+type copiedLog struct {
+	LogFirst, LogLast, term uint64
+}
+
+// copyReadable copies the stable store keys and, unless discardLogs is set,
+// the log entries from start up to last or the first unreadable one.
+// BoltDB may panic on corrupt pages, which ends the copy.
+func copyReadable(in, out *raftboltdb.BoltStore, start, last uint64, discardLogs bool) (c copiedLog, err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = nil
+		}
+	}()
+	for _, key := range [][]byte{keyCurrentTerm, keyLastVoteTerm, keyLastVoteCand} {
+		if v, err := in.Get(key); err == nil {
+			if err := out.Set(key, v); err != nil {
+				return c, err
+			}
+		}
+	}
+	if discardLogs {
+		return c, nil
+	}
+	first, err := in.FirstIndex()
+	if err != nil {
+		return c, nil
+	}
+	var entry raft.Log
+	for i := max(start, first); first > 0 && i <= last; i++ {
+		if err := in.GetLog(i, &entry); err != nil || entry.Index != i {
+			break
+		}
+		if err := out.StoreLog(&entry); err != nil {
+			return c, err
+		}
+		if c.LogFirst == 0 {
+			c.LogFirst = i
+		}
+		c.LogLast, c.term = i, entry.Term
+	}
+	return c, nil
+}
+
+// openReadOnly opens a log store for reading, turning BoltDB panics into errors.
+func openReadOnly(path string) (store *raftboltdb.BoltStore, err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			store, err = nil, fmt.Errorf("bolt: %v", p)
+		}
+	}()
+	return raftboltdb.New(raftboltdb.Options{Path: path, BoltOptions: &bolt.Options{ReadOnly: true, Timeout: time.Second}})
+}
@@ -0,0 +1,142 @@
+package consensus
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hashicorp/raft"
+	raftboltdb "github.com/hashicorp/raft-boltdb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeDataDir lays out a Raft data directory holding entries 1-last at
+// term 2 and a snapshot at each of the given indexes.
+func writeDataDir(t *testing.T, last uint64, snapshotAt ...uint64) string {
+	t.Helper()
+	dir := t.TempDir()
+	db, err := raftboltdb.NewBoltStore(filepath.Join(dir, raftDBFile))
+	require.NoError(t, err)
+	for i := uint64(1); i <= last; i++ {
+		require.NoError(t, db.StoreLog(&raft.Log{Index: i, Term: 2, Type: raft.LogCommand, Data: []byte("entry")}))
+	}
+	require.NoError(t, db.SetUint64(keyCurrentTerm, 2))
+	require.NoError(t, db.Close())
+
+	snaps, err := raft.NewFileSnapshotStore(dir, len(snapshotAt)+1, os.Stderr)
+	require.NoError(t, err)
+	for _, index := range snapshotAt {
+		sink, err := snaps.Create(raft.SnapshotVersionMax, index, 2, raft.Configuration{}, 1, nil)
+		require.NoError(t, err)
+		_, err = sink.Write([]byte("state"))
+		require.NoError(t, err)
+		require.NoError(t, sink.Close())
+	}
+	return dir
+}
+
+func snapshotIDs(t *testing.T, dir string) []string {
+	t.Helper()
+	infos, err := raft.NewFileSnapshotStore(dir, 3, os.Stderr)
+	require.NoError(t, err)
+	metas, err := infos.List()
+	require.NoError(t, err)
+	var ids []string
+	for _, m := range metas {
+		ids = append(ids, m.ID)
+	}
+	return ids
+}
+
+func TestVerifyDataDir_Clean(t *testing.T) {
+	dir := writeDataDir(t, 10, 5)
+
+	report, err := VerifyDataDir(dir)
+	require.NoError(t, err)
+	assert.True(t, report.OK(), report.Problems)
+	assert.Equal(t, uint64(1), report.LogFirst)
+	assert.Equal(t, uint64(10), report.LogLast)
+	assert.Equal(t, uint64(2), report.CurrentTerm)
+	snap, ok := report.NewestSnapshot()
+	require.True(t, ok)
+	assert.Equal(t, uint64(5), snap.Index)
+
+	res, err := RecoverDataDir(dir, false)
+	require.NoError(t, err)
+	assert.Empty(t, res.Moved, "a sound directory is left alone")
+}
+
+func TestVerifyDataDir_BadSnapshots(t *testing.T) {
+	dir := writeDataDir(t, 10, 5, 8)
+	ids := snapshotIDs(t, dir)
+	require.Len(t, ids, 2)
+
+	// The newest snapshot fails its CRC, and a torn one was never finished.
+	require.NoError(t, os.WriteFile(filepath.Join(dir, snapshotsDir, ids[0], "state.bin"), []byte("STATE"), 0600))
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, snapshotsDir, "2-9-1.tmp"), 0700))
+
+	report, err := VerifyDataDir(dir)
+	require.NoError(t, err)
+	assert.False(t, report.OK())
+	assert.False(t, report.Fatal, "Raft skips unusable snapshots")
+	snap, ok := report.NewestSnapshot()
+	require.True(t, ok)
+	assert.Equal(t, ids[1], snap.ID)
+
+	res, err := RecoverDataDir(dir, false)
+	require.NoError(t, err)
+	assert.Len(t, res.Moved, 2)
+	assert.Equal(t, []string{ids[1]}, snapshotIDs(t, dir))
+	assert.DirExists(t, filepath.Join(res.Quarantine, snapshotsDir, ids[0]))
+
+	report, err = VerifyDataDir(dir)
+	require.NoError(t, err)
+	assert.True(t, report.OK(), report.Problems)
+}
+
+func TestRecoverDataDir_CorruptLog(t *testing.T) {
+	dir := writeDataDir(t, 200, 150)
+	path := filepath.Join(dir, raftDBFile)
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	// Overwrite every page after BoltDB's two meta pages.
+	for i := 2 * 4096; i < len(data); i++ {
+		data[i] = 0xff
+	}
+	require.NoError(t, os.WriteFile(path, data, 0600))
+
+	report, err := VerifyDataDir(dir)
+	require.NoError(t, err)
+	assert.True(t, report.Fatal, report.Problems)
+	assert.Contains(t, report.Guidance(), "cachectl raft recover")
+
+	res, err := RecoverDataDir(dir, false)
+	require.NoError(t, err)
+	require.NotNil(t, res.Snapshot)
+	assert.Equal(t, uint64(150), res.Snapshot.Index)
+	assert.Contains(t, res.Moved, raftDBFile)
+	assert.FileExists(t, filepath.Join(res.Quarantine, raftDBFile))
+
+	report, err = VerifyDataDir(dir)
+	require.NoError(t, err)
+	assert.True(t, report.OK(), report.Problems)
+	assert.GreaterOrEqual(t, report.CurrentTerm, uint64(2), "the term never goes back")
+}
+
+func TestRecoverDataDir_DiscardLogs(t *testing.T) {
+	dir := writeDataDir(t, 10, 5)
+
+	res, err := RecoverDataDir(dir, true)
+	require.NoError(t, err)
+	assert.Zero(t, res.LogLast)
+
+	report, err := VerifyDataDir(dir)
+	require.NoError(t, err)
+	assert.True(t, report.OK(), report.Problems)
+	assert.Zero(t, report.LogLast)
+	assert.Equal(t, uint64(2), report.CurrentTerm)
+
+	_, err = RecoverDataDir(writeDataDir(t, 10), true)
+	assert.Error(t, err, "discarding the log needs a snapshot")
+}
@@ -0,0 +1,113 @@
+// Package errors defines the sentinel errors shared by the service layer and
+// its transports. Callers should compare against these values with errors.Is
+// instead of matching on error strings.
+package errors
+
+import (
+	"context"
+	"errors"
+	"net/http"
+)
+
+var (
+	// ErrNotFound is returned when a key does not exist or has expired.
+	ErrNotFound = errors.New("key not found")
+	// ErrNotLeader is returned when an operation requires the cluster leader.
+	ErrNotLeader = errors.New("node is not the leader")
+	// ErrEmptyKey is returned when a request does not specify a key.
+	ErrEmptyKey = errors.New("key must not be empty")
+	// ErrKeyTooLarge is returned when a key exceeds the maximum allowed length.
+	ErrKeyTooLarge = errors.New("key too large")
+	// ErrTimeout is returned when an operation could not complete in time.
+	ErrTimeout = errors.New("operation timed out")
+	// ErrApplyTimeout is returned when a write was submitted to the cluster but not
+	// confirmed before the deadline. Unlike ErrTimeout, the write may still commit;
+	// retry it with the same request ID to get exactly-once semantics.
+	ErrApplyTimeout = errors.New("write not confirmed before deadline, outcome unknown")
+	// ErrLeadershipLost is returned, along with ErrNotLeader, when the leader
+	// lost its leadership after a write was submitted. Like ErrApplyTimeout,
+	// the write may still commit.
+	ErrLeadershipLost = errors.New("leadership lost, outcome unknown")
+	// ErrVersionMismatch is returned when a conditional write's precondition does
+	// not hold, e.g. the key was modified since the version the client read.
+	ErrVersionMismatch = errors.New("version mismatch")
+	// ErrInvalidArgument is returned when a request parameter is malformed or out of range.
+	ErrInvalidArgument = errors.New("invalid argument")
+	// ErrWrongType is returned when an operation is used on a key holding another kind of value.
+	ErrWrongType = errors.New("operation against a key holding the wrong kind of value")
+	// ErrUnsupported is returned when the configured storage backend, or a
+	// standalone node, does not support an operation.
+	ErrUnsupported = errors.New("operation not supported")
+	// ErrStaleRead is returned when an eventually consistent read is refused
+	// because the node is further behind the leader than the configured bound.
+	// Retry on another node, or on the leader.
+	ErrStaleRead = errors.New("replica too far behind the leader")
+	// ErrScript is returned when a script fails to compile or raises an error.
+	// Nothing the script wrote is applied.
+	ErrScript = errors.New("script error")
+	// ErrQuotaExceeded is returned when a write would take a namespace past its
+	// key, byte or write rate quota. Deleting keys or waiting frees room.
+	ErrQuotaExceeded = errors.New("quota exceeded")
+	// ErrNoQuorum is returned when writes and strong reads fail fast because
+	// the node has lost contact with a majority of the cluster, and so with
+	// any leader. Retry on another node.
+	ErrNoQuorum = errors.New("no quorum: node has lost contact with the cluster")
+	// ErrWitness is returned by a witness node, which votes in the cluster but
+	// holds no data, for every read and write. Retry on another node.
+	ErrWitness = errors.New("witness node: holds no data")
+	// ErrUnavailable is returned when writes fail fast because replication has
+	// been failing, e.g. after losing quorum or on a stalled disk. Writes are
+	// tried again after a cooldown; retry later, or on another cluster.
+	ErrUnavailable = errors.New("writes unavailable: replication is failing")
+)
+
+// StatusClientClosedRequest is the status of a request the client gave up on
+// (nginx's 499), the HTTP counterpart of gRPC's CANCELLED.
+const StatusClientClosedRequest = 499
+
+// HTTPStatus maps an error to the HTTP status code that should be returned to clients.
+func HTTPStatus(err error) int {
+	switch {
+	case err == nil:
+		return http.StatusOK
+	case errors.Is(err, ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, ErrEmptyKey), errors.Is(err, ErrKeyTooLarge), errors.Is(err, ErrInvalidArgument), errors.Is(err, ErrWrongType), errors.Is(err, ErrScript):
+		return http.StatusBadRequest
+	case errors.Is(err, ErrNotLeader), errors.Is(err, ErrStaleRead), errors.Is(err, ErrUnavailable), errors.Is(err, ErrNoQuorum), errors.Is(err, ErrWitness):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, ErrVersionMismatch):
+		return http.StatusPreconditionFailed
+	case errors.Is(err, ErrQuotaExceeded):
+		return http.StatusTooManyRequests
+	case errors.Is(err, ErrUnsupported):
+		return http.StatusNotImplemented
+	case errors.Is(err, ErrTimeout), errors.Is(err, ErrApplyTimeout), errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout
+	case errors.Is(err, context.Canceled):
+		return StatusClientClosedRequest
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// PublicMessage returns a client-safe description of err.
+// Known sentinel errors are reported verbatim; anything else is reduced to a
+// generic message so internal details are not leaked to callers. Script and
+// quota errors are reported in full, since they describe the caller's own
+// script or namespace. Errors that leave a write's outcome unknown are
+// checked first, since they come wrapped with ErrNotLeader or ErrTimeout.
+func PublicMessage(err error) string {
+	if errors.Is(err, ErrScript) || errors.Is(err, ErrQuotaExceeded) {
+		return err.Error()
+	}
+	for _, known := range []error{ErrLeadershipLost, ErrApplyTimeout, ErrNotFound, ErrNotLeader, ErrEmptyKey, ErrKeyTooLarge, ErrVersionMismatch, ErrInvalidArgument, ErrWrongType, ErrUnsupported, ErrStaleRead, ErrNoQuorum, ErrWitness, ErrUnavailable, ErrTimeout} {
+		if errors.Is(err, known) {
+			return known.Error()
+		}
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return ErrTimeout.Error()
+	}
+	return "internal error"
+}
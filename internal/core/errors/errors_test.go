@@ -0,0 +1,52 @@
+package errors
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHTTPStatus(t *testing.T) {
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{nil, http.StatusOK},
+		{ErrNotFound, http.StatusNotFound},
+		{fmt.Errorf("wrapped: %w", ErrNotLeader), http.StatusServiceUnavailable},
+		{fmt.Errorf("%w: 120 entries behind", ErrStaleRead), http.StatusServiceUnavailable},
+		{fmt.Errorf("%w: no leader contact for 5s", ErrNoQuorum), http.StatusServiceUnavailable},
+		{ErrWitness, http.StatusServiceUnavailable},
+		{ErrUnavailable, http.StatusServiceUnavailable},
+		{ErrEmptyKey, http.StatusBadRequest},
+		{ErrKeyTooLarge, http.StatusBadRequest},
+		{ErrTimeout, http.StatusGatewayTimeout},
+		{fmt.Errorf("%w: score is NaN", ErrInvalidArgument), http.StatusBadRequest},
+		{ErrWrongType, http.StatusBadRequest},
+		{ErrUnsupported, http.StatusNotImplemented},
+		{fmt.Errorf("line 2: %w", ErrScript), http.StatusBadRequest},
+		{fmt.Errorf("%w: key is at version 7", ErrVersionMismatch), http.StatusPreconditionFailed},
+		{fmt.Errorf("%w: namespace \"user:\" holds 100 keys", ErrQuotaExceeded), http.StatusTooManyRequests},
+		{fmt.Errorf("%w: %w", ErrApplyTimeout, context.DeadlineExceeded), http.StatusGatewayTimeout},
+		{fmt.Errorf("%w: %w", ErrNotLeader, ErrLeadershipLost), http.StatusServiceUnavailable},
+		{fmt.Errorf("request: %w", context.Canceled), StatusClientClosedRequest},
+		{errors.New("boom"), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, HTTPStatus(tt.err), "error: %v", tt.err)
+	}
+}
+
+func TestPublicMessage(t *testing.T) {
+	assert.Equal(t, "node is not the leader", PublicMessage(fmt.Errorf("raft: %w", ErrNotLeader)))
+	assert.Equal(t, "internal error", PublicMessage(errors.New("bolt: disk I/O error at 0xdeadbeef")))
+	assert.Equal(t, ErrApplyTimeout.Error(), PublicMessage(fmt.Errorf("%w: %w", ErrApplyTimeout, context.DeadlineExceeded)))
+	assert.Equal(t, ErrLeadershipLost.Error(), PublicMessage(fmt.Errorf("%w: %w: raft: leadership lost", ErrNotLeader, ErrLeadershipLost)))
+	assert.Equal(t, ErrApplyTimeout.Error(), PublicMessage(fmt.Errorf("%w: %w", ErrTimeout, ErrApplyTimeout)))
+	assert.Equal(t, "script error: line 2: rate limited", PublicMessage(fmt.Errorf("%w: line 2: rate limited", ErrScript)))
+	assert.Equal(t, "quota exceeded: 10 keys", PublicMessage(fmt.Errorf("%w: 10 keys", ErrQuotaExceeded)))
+}
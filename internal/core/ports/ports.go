@@ -2,6 +2,7 @@ package ports
 
 import (
 	"context"
+	"io"
 	"time"
 )
 
@@ -14,8 +15,158 @@ type CacheService interface {
 	Set(ctx context.Context, key, value string, ttl time.Duration) error
 	// Delete removes a key from the cache.
 	Delete(ctx context.Context, key string) error
-	// Join adds a new node to the distributed cluster.
-	Join(ctx context.Context, nodeID, addr string) error
+	// Join adds a new node to the distributed cluster at Raft address addr.
+	// grpcAddr, if given, is recorded as the address it serves gRPC at.
+	Join(ctx context.Context, nodeID, addr, grpcAddr string) error
+	// GetVersioned is like Get but also returns the key's version: the Raft log
+	// index of its last write. Versions only increase.
+	GetVersioned(ctx context.Context, key string) (string, uint64, error)
+	// SetIf is like Set but only applies if cond holds, failing with
+	// errors.ErrVersionMismatch otherwise. It returns the new version.
+	SetIf(ctx context.Context, key, value string, ttl time.Duration, cond Precondition) (uint64, error)
+	// DeleteIf is like Delete but only applies if cond holds.
+	DeleteIf(ctx context.Context, key string, cond Precondition) error
+	// GetSet atomically replaces the value of key and returns the previous one.
+	// found is false if the key did not exist.
+	GetSet(ctx context.Context, key, value string, ttl time.Duration) (old string, found bool, err error)
+	// GetDel atomically deletes key and returns its value, or errors.ErrNotFound.
+	GetDel(ctx context.Context, key string) (string, error)
+	// GetOrSet atomically returns the value of key, or stores and returns value
+	// if there is none. loaded is true if the value was already there.
+	GetOrSet(ctx context.Context, key, value string, ttl time.Duration) (actual string, loaded bool, err error)
+	// Append appends suffix to the value of key, creating it if needed, and
+	// returns the new length in bytes.
+	Append(ctx context.Context, key, suffix string) (int, error)
+	// StrLen returns the length in bytes of the value of key, or 0 if it does not exist.
+	StrLen(ctx context.Context, key string) (int, error)
+	// ZAdd adds members to the sorted set at key or updates their scores, and
+	// returns how many were new.
+	ZAdd(ctx context.Context, key string, members ...ScoredMember) (int, error)
+	// ZRemRangeByScore removes members with min <= score <= max and returns how many.
+	ZRemRangeByScore(ctx context.Context, key string, min, max float64) (int, error)
+	// ZScore returns the score of member; found is false if it is not in the set.
+	ZScore(ctx context.Context, key, member string) (score float64, found bool, err error)
+	// ZRange returns members by 0-based rank, lowest score first. Negative ranks
+	// count from the end.
+	ZRange(ctx context.Context, key string, start, stop int) ([]ScoredMember, error)
+	// ZRangeByScore returns members with min <= score <= max, lowest score first,
+	// at most limit of them if limit > 0.
+	ZRangeByScore(ctx context.Context, key string, min, max float64, limit int) ([]ScoredMember, error)
+	// Eval runs a script atomically against keys and returns its result.
+	// A script that fails applies none of its writes.
+	Eval(ctx context.Context, script string, keys, args []string) (interface{}, error)
+	// TTL returns the remaining lifetime of key, or 0 if it does not expire.
+	TTL(ctx context.Context, key string) (time.Duration, error)
+	// Expire makes an existing key expire after ttl, keeping its value.
+	Expire(ctx context.Context, key string, ttl time.Duration) error
+	// Persist removes an existing key's expiration, keeping its value.
+	Persist(ctx context.Context, key string) error
+	// Txn atomically evaluates txn's comparisons and applies its Success ops if
+	// all hold, or its Failure ops otherwise.
+	Txn(ctx context.Context, txn Txn) (TxnResult, error)
+	// BulkLoad writes the entries next returns until it returns io.EOF,
+	// batched into large replicated writes. Each batch is applied atomically;
+	// on failure the result counts the batches written before it.
+	BulkLoad(ctx context.Context, next func() (BulkEntry, error)) (BulkLoadResult, error)
+}
+
+// Precondition makes a write conditional on the key's current version.
+// The zero value is unconditional.
+type Precondition struct {
+	// IfVersion requires the key to exist at exactly this version.
+	IfVersion uint64
+	// IfAbsent requires the key to not exist.
+	IfAbsent bool
+}
+
+// Txn is a compare-then-ops transaction, as in etcd: if every comparison
+// holds, the Success ops are applied, otherwise the Failure ops. Comparisons
+// and ops are applied as one step, with no other write in between.
+type Txn struct {
+	Compares []Compare
+	Success  []TxnOp
+	Failure  []TxnOp
+}
+
+// CompareTarget is what a Compare looks at.
+type CompareTarget int
+
+const (
+	// CompareVersion compares the key's version; 0 is the version of a missing key.
+	CompareVersion CompareTarget = iota
+	// CompareValue compares the key's value. A missing key equals no value.
+	CompareValue
+)
+
+// Compare is a condition on one key of a transaction.
+type Compare struct {
+	Key     string        `json:"key"`
+	Target  CompareTarget `json:"target,omitempty"`
+	Version uint64        `json:"version,omitempty"`
+	Value   string        `json:"value,omitempty"`
+	// NotEqual makes the condition hold when the target differs.
+	NotEqual bool `json:"not_equal,omitempty"`
+}
+
+// TxnOpType is the kind of a TxnOp.
+type TxnOpType int
+
+const (
+	TxnGet TxnOpType = iota
+	TxnSet
+	TxnDelete
+)
+
+// TxnOp is one operation of a transaction. Value and TTL only apply to TxnSet.
+type TxnOp struct {
+	Type  TxnOpType
+	Key   string
+	Value string
+	TTL   time.Duration
+}
+
+// TxnResult reports which branch of a transaction ran, with one result per op.
+type TxnResult struct {
+	Succeeded bool
+	Results   []TxnOpResult
+}
+
+// TxnOpResult is the outcome of a TxnOp. For a get, Found, Value and Version
+// describe the key; for a set, Version is its new version; for a delete, Found
+// reports whether the key existed.
+type TxnOpResult struct {
+	Value   string
+	Found   bool
+	Version uint64
+}
+
+// BulkEntry is a key written by BulkLoad, as with Set.
+type BulkEntry struct {
+	Key   string
+	Value string
+	TTL   time.Duration
+}
+
+// BulkLoadResult reports the entries BulkLoad wrote and the replicated writes
+// they took.
+type BulkLoadResult struct {
+	Loaded  int
+	Batches int
+}
+
+// Loader fetches values from a system of record on cache misses (read-through).
+type Loader interface {
+	// Load returns the value for key and how long to cache it (0 = no expiration).
+	// It returns errors.ErrNotFound if the key does not exist upstream.
+	Load(ctx context.Context, key string) (value string, ttl time.Duration, err error)
+}
+
+// LoaderFunc adapts a function to the Loader interface.
+type LoaderFunc func(ctx context.Context, key string) (string, time.Duration, error)
+
+// Load calls f(ctx, key).
+func (f LoaderFunc) Load(ctx context.Context, key string) (string, time.Duration, error) {
+	return f(ctx, key)
 }
 
 // Storage defines the interface for underlying data persistence/storage.
@@ -27,12 +178,216 @@ type Storage interface {
 	Set(key, value string, ttl time.Duration)
 	// Delete removes the key from storage.
 	Delete(key string)
+	// Len returns the number of keys currently held.
+	Len() int
+}
+
+// SnapshotStorage is a Storage whose whole state can be captured and replaced,
+// as required by the Raft FSM. Both the in-memory and on-disk backends implement it.
+type SnapshotStorage interface {
+	Storage
+	// PointInTime captures a consistent view that can be serialized without blocking writers.
+	PointInTime() StateView
+	// Snapshot serializes the current state to w.
+	Snapshot(w io.Writer) error
+	// Restore replaces the entire state with a snapshot read from r.
+	Restore(r io.Reader) error
+	// Replace overwrites the value of a key existing and unexpired at now,
+	// keeping its expiration. It reports false, storing nothing, if there is no
+	// such key.
+	Replace(key, value string, now time.Time) bool
+	ExpiryStorage
+}
+
+// ExpiryStorage is a Storage that accepts absolute expiration times, and whose
+// keys' expiration can be read and changed without rewriting their values.
+type ExpiryStorage interface {
+	// SetExpiresAt is like Set but expires the key at expiresAt, or never if
+	// it is the zero time.
+	SetExpiresAt(key, value string, expiresAt time.Time)
+	// TTL returns the remaining lifetime of key, or 0 if it does not expire.
+	// found is false if there is no such unexpired key.
+	TTL(key string) (ttl time.Duration, found bool)
+	// GetStale is like Get but also returns keys that have expired and not
+	// yet been deleted, with their expiration (the zero time if none).
+	GetStale(key string) (value string, expiresAt time.Time, found bool)
+	// GetAt is like Get but judges expiry at now instead of by the local
+	// clock. Under Raft, now is the log entry's timestamp, so that every
+	// replica sees the same keys.
+	GetAt(key string, now time.Time) (string, bool)
+	// ExpireAt makes a key existing at now expire at expiresAt, or never if
+	// it is the zero time. It reports false if there is no such key.
+	ExpireAt(key string, expiresAt, now time.Time) bool
+	// ExpiredKeys returns up to limit keys that had expired by now.
+	ExpiredKeys(now time.Time, limit int) []string
+	// DeleteExpired removes key if it had expired by now, reporting whether
+	// it did.
+	DeleteExpired(key string, now time.Time) bool
+}
+
+// EvictionStorage is a Storage with a capacity that writes do not enforce:
+// its owner evicts the keys it proposes.
+type EvictionStorage interface {
+	// EvictionVictims returns up to limit keys to evict, in order, to bring the
+	// store back within its capacity, or nil if it fits.
+	EvictionVictims(limit int) []string
+	// Evict removes key, expired or not, reporting whether it existed.
+	Evict(key string) bool
+}
+
+// Usage is how much of the store the keys under a prefix take up.
+type Usage struct {
+	Keys  int64 `json:"keys"`
+	Bytes int64 `json:"bytes"`
+}
+
+// UsageStorage is a Storage that accounts for the keys and bytes stored under
+// key prefixes.
+type UsageStorage interface {
+	// Usage returns the usage of the keys under prefix, if it is counted.
+	Usage(prefix string) (Usage, bool)
+	// Size returns the bytes key takes up in its Usage.
+	Size(key string) (int64, bool)
+}
+
+// NodeStats are a node's role, key count, read counters and replication
+// progress.
+type NodeStats struct {
+	State string `json:"state"`
+	// LeaderAddr is the Raft address of the leader the node follows, empty
+	// if it knows of none.
+	LeaderAddr string `json:"leader_addr,omitempty"`
+	Keys       int64  `json:"keys"`
+	// Capacity is the most keys the store holds before evicting, 0 if
+	// unlimited.
+	Capacity int64 `json:"capacity,omitempty"`
+	// Hits and Misses count the reads the node served since it started.
+	Hits   uint64 `json:"hits"`
+	Misses uint64 `json:"misses"`
+	// AppliedIndex is the index of the last log entry applied to the store.
+	AppliedIndex uint64 `json:"applied_index"`
+	// ReplicationLag is how many committed entries the node has yet to
+	// apply. If it cannot tell, ReplicationLagError says why.
+	ReplicationLag      uint64 `json:"replication_lag"`
+	ReplicationLagError string `json:"replication_lag_error,omitempty"`
+}
+
+// NodeReporter reports a node's stats.
+type NodeReporter interface {
+	NodeStats() NodeStats
+}
+
+// NamespaceStats is what a namespace with a quota has used on one node.
+type NamespaceStats struct {
+	Namespace string `json:"namespace"`
+	// Hits and Misses count the reads of the namespace's keys the node
+	// served since it started.
+	Hits   uint64 `json:"hits"`
+	Misses uint64 `json:"misses"`
+	// Usage is what the namespace takes up in the node's store, nil if the
+	// storage backend does not count it.
+	Usage *Usage `json:"usage,omitempty"`
+	// The namespace's quotas, 0 if unlimited.
+	MaxKeys      int64   `json:"max_keys"`
+	MaxBytes     int64   `json:"max_bytes"`
+	MaxWriteRate float64 `json:"max_write_rate"`
+	// Throttled counts the writes the node refused since it started, by the
+	// quota they would have exceeded: keys, bytes or write_rate.
+	Throttled map[string]uint64 `json:"throttled,omitempty"`
+}
+
+// NamespaceReporter reports the stats of each namespace with a quota.
+type NamespaceReporter interface {
+	NamespaceStats() []NamespaceStats
+}
+
+// KeyMemory is a key with the bytes of memory it takes up, approximately.
+type KeyMemory struct {
+	Key   string `json:"key"`
+	Bytes int64  `json:"bytes"`
+}
+
+// MemoryStats is the memory a store's keys take up, approximately.
+type MemoryStats struct {
+	Keys  int64 `json:"keys"`
+	Bytes int64 `json:"bytes"`
+	// Largest lists the keys taking up the most memory, largest first.
+	Largest []KeyMemory `json:"largest"`
+}
+
+// MemoryStorage is a Storage that estimates the memory its keys take up: their
+// keys and values, plus the overhead of the structures holding them.
+type MemoryStorage interface {
+	// MemoryUsage returns the bytes key takes up, expired or not, without
+	// counting as an access.
+	MemoryUsage(key string) (int64, bool)
+	// MemoryStats returns the bytes every key takes up, with the n largest.
+	MemoryStats(n int) MemoryStats
+}
+
+// StoredEntry is a key with its value as stored and when it expires, the
+// zero time if it does not.
+type StoredEntry struct {
+	Key       string
+	Value     string
+	ExpiresAt time.Time
+}
+
+// ScanStorage is a Storage whose keys can be listed in order, for browsing.
+type ScanStorage interface {
+	// Scan returns up to limit unexpired keys starting with prefix and
+	// greater than after, in order, with their values. match, if not nil,
+	// further filters the keys. Scan does not count as an access.
+	Scan(prefix, after string, limit int, match func(key string) bool) []StoredEntry
+}
+
+// ScoredMember is a member of a sorted set with its score.
+type ScoredMember struct {
+	Member string  `json:"member"`
+	Score  float64 `json:"score"`
+}
+
+// KeyCount is a key with how often it was read.
+type KeyCount struct {
+	Key   string `json:"key"`
+	Count uint64 `json:"count"`
+}
+
+// SortedSetStorage is implemented by storage backends that support sorted sets.
+// A key holds either a string value or a sorted set; sorted-set operations on a
+// key holding a string fail with errors.ErrWrongType, and a string write to a
+// sorted-set key replaces the set.
+type SortedSetStorage interface {
+	// ZAdd adds members or updates their scores, returning how many were new.
+	// Whether a string at key has expired, and may be replaced, is judged at
+	// now.
+	ZAdd(key string, now time.Time, members ...ScoredMember) (int, error)
+	// ZRemRangeByScore removes members with min <= score <= max, returning how
+	// many. Like ZAdd it judges expiry at now.
+	ZRemRangeByScore(key string, min, max float64, now time.Time) (int, error)
+	// ZScore returns the score of member.
+	ZScore(key, member string) (float64, bool, error)
+	// ZRange returns members by rank, start to stop inclusive; negative ranks count from the end.
+	ZRange(key string, start, stop int) ([]ScoredMember, error)
+	// ZRangeByScore returns members with min <= score <= max in order, at most limit if limit > 0.
+	ZRangeByScore(key string, min, max float64, limit int) ([]ScoredMember, error)
+}
+
+// StateView is an immutable point-in-time view of a SnapshotStorage.
+type StateView interface {
+	// Snapshot serializes the view to w.
+	Snapshot(w io.Writer) error
+	// Release frees resources held by the view. It must be called once the view is no longer needed.
+	Release()
 }
 
 // Consensus defines the interface for distributed agreement/replication.
 type Consensus interface {
-	// Apply replicates a state-changing command to the cluster.
-	Apply(cmd []byte) error
+	// Apply replicates a state-changing command to the cluster and waits until it
+	// is applied, ctx is done, or the implementation's default timeout elapses.
+	// It returns the state machine's response; a response that is an error is
+	// returned as the error.
+	Apply(ctx context.Context, cmd []byte) (interface{}, error)
 	// AddVoter adds a new voting member to the cluster.
 	AddVoter(id, addr string) error
 	// IsLeader checks if the current node is the cluster leader.
@@ -40,3 +395,111 @@ type Consensus interface {
 	// VerifyLeader checks if the current node is the leader and can serve consistent reads.
 	VerifyLeader() error
 }
+
+// AsyncApplier is a Consensus that can acknowledge a command before it
+// commits.
+type AsyncApplier interface {
+	// ApplyAsync submits cmd and returns once the leader has queued it for
+	// replication. done is called from another goroutine with what Apply
+	// would have returned, once the outcome is known.
+	ApplyAsync(cmd []byte, done func(resp interface{}, err error)) error
+}
+
+// LagReporter is a Consensus that can tell how far this node's state is behind
+// the cluster's.
+type LagReporter interface {
+	// ReplicationLag returns how many committed log entries this node has yet
+	// to apply. It returns an error wrapping ErrStaleRead if the node cannot
+	// bound its lag, e.g. because it has lost contact with the leader.
+	ReplicationLag() (uint64, error)
+}
+
+// QuorumChecker is a Consensus that can tell whether this node is in touch
+// with a quorum of the cluster.
+type QuorumChecker interface {
+	// CheckQuorum returns an error wrapping ErrNoQuorum if the node has lost
+	// contact with the cluster's majority, so that writes and strong reads
+	// could only wait out their timeouts.
+	CheckQuorum() error
+}
+
+// PositionReporter is a Consensus that can tell how far this node has applied
+// the replicated log.
+type PositionReporter interface {
+	// AppliedPosition returns the index of the last log entry applied to this
+	// node's state, and the node's current term.
+	AppliedPosition() (index, term uint64)
+}
+
+// ClusterVersioner is a Consensus that replicates the cluster's command
+// version: the newest command schema every member can apply.
+type ClusterVersioner interface {
+	ClusterVersion() uint32
+}
+
+// SnapshotInfo describes a consensus snapshot.
+type SnapshotInfo struct {
+	ID    string `json:"id"`
+	Index uint64 `json:"index"`
+	Term  uint64 `json:"term"`
+	Size  int64  `json:"size"`
+}
+
+// Member is a server in the consensus configuration.
+type Member struct {
+	ID      string `json:"id"`
+	Address string `json:"address"`
+	Voter   bool   `json:"voter"`
+	Leader  bool   `json:"leader"`
+	// GRPCAddr is the address the member serves gRPC at, as it advertised
+	// it, or empty if it has not.
+	GRPCAddr string `json:"grpc_addr,omitempty"`
+}
+
+// DeadMember is a member the leader has lost contact with for longer than
+// the reaper allows.
+type DeadMember struct {
+	ID      string `json:"id"`
+	Address string `json:"address"`
+	// LastContact is when the leader last heard from the member, or when it
+	// first failed to, if the member has not answered since the leader took
+	// over.
+	LastContact time.Time `json:"last_contact"`
+}
+
+// Reaper is implemented by consensus modules that remove members the leader
+// has lost contact with from the cluster, automatically or once an operator
+// confirms it.
+type Reaper interface {
+	// DeadMembers returns the members due for removal. Only the leader
+	// tracks them.
+	DeadMembers() ([]DeadMember, error)
+	// Reap removes the member id, which must be one of DeadMembers.
+	Reap(id string) error
+}
+
+// ClusterAdmin defines operator-level cluster management operations.
+type ClusterAdmin interface {
+	// AddVoter adds a new voting member to the cluster.
+	AddVoter(id, addr string) error
+	// RemoveServer removes a member from the cluster.
+	RemoveServer(id string) error
+	// TransferLeadership hands leadership to the given node, or to any suitable follower if id is empty.
+	TransferLeadership(id, addr string) error
+	// Snapshot forces a snapshot of the state machine.
+	Snapshot() (SnapshotInfo, error)
+	// ListSnapshots returns the snapshots retained locally, newest first.
+	ListSnapshots() ([]SnapshotInfo, error)
+	// Compact takes a snapshot and truncates the log up to it, returning the compacted index.
+	Compact() (uint64, error)
+	// RestoreFrom makes the cluster adopt the snapshot read from r as its state.
+	RestoreFrom(r io.Reader) error
+	// Members returns the servers in the cluster configuration.
+	Members() ([]Member, error)
+	// State returns the node's current role (Leader, Follower, Candidate).
+	State() string
+	// Leader returns the address of the current leader, or an empty string if unknown.
+	Leader() string
+	// Stats returns diagnostic counters from the consensus layer.
+	Stats() map[string]string
+}
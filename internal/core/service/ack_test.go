@@ -0,0 +1,88 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	coreerrors "distributed-cache-service/internal/core/errors"
+	"distributed-cache-service/internal/core/ports"
+)
+
+// asyncConsensus holds back the outcome of commands submitted with
+// ApplyAsync until the test reports it.
+type asyncConsensus struct {
+	MockConsensus
+	applied   int
+	submitted []Command
+	done      []func(interface{}, error)
+}
+
+func (m *asyncConsensus) Apply(ctx context.Context, data []byte) (interface{}, error) {
+	m.applied++
+	return ApplyResult{Version: 1}, nil
+}
+
+func (m *asyncConsensus) ApplyAsync(data []byte, done func(interface{}, error)) error {
+	var cmd Command
+	if err := DecodeCommand(data, &cmd); err != nil {
+		return err
+	}
+	m.submitted = append(m.submitted, cmd)
+	m.done = append(m.done, done)
+	return nil
+}
+
+func TestService_AckLeader(t *testing.T) {
+	consensus := &asyncConsensus{}
+	svc := New(&MockStore{data: map[string]string{}}, consensus, ConsistencyEventual,
+		WithBreaker(Breaker{Failures: 2, Cooldown: time.Minute}))
+	ctx := ContextWithAck(context.Background(), AckLeader)
+
+	// Plain sets and deletes return once submitted.
+	if version, err := svc.SetIf(ctx, "k", "v", 0, ports.Precondition{}); err != nil || version != 0 {
+		t.Fatalf("expected version 0 and no error, got %d (%v)", version, err)
+	}
+	if err := svc.Delete(ctx, "k"); err != nil {
+		t.Fatal(err)
+	}
+	if len(consensus.submitted) != 2 || consensus.applied != 0 {
+		t.Fatalf("expected 2 writes submitted without waiting, got %d (%d applied)", len(consensus.submitted), consensus.applied)
+	}
+
+	// Writes that report a result still wait for a quorum.
+	if _, _, err := svc.GetSet(ctx, "k", "v", 0); err != nil || consensus.applied != 1 {
+		t.Errorf("expected GETSET to wait for a quorum, got %v (%d applied)", err, consensus.applied)
+	}
+	if _, err := svc.SetIf(ctx, "k", "v", 0, ports.Precondition{IfVersion: 3}); !errors.Is(err, coreerrors.ErrInvalidArgument) {
+		t.Errorf("expected ErrInvalidArgument for a precondition, got %v", err)
+	}
+
+	// Failures to commit still reach the breaker.
+	for _, done := range consensus.done {
+		done(nil, coreerrors.ErrApplyTimeout)
+	}
+	if err := svc.Set(ctx, "k", "v", 0); !errors.Is(err, coreerrors.ErrUnavailable) {
+		t.Errorf("expected the breaker to open, got %v", err)
+	}
+}
+
+func TestService_AckLeaderFallsBackToQuorum(t *testing.T) {
+	consensus := &failingConsensus{}
+	svc := New(&MockStore{data: map[string]string{}}, consensus, ConsistencyEventual)
+
+	// A consensus that cannot acknowledge early waits as usual.
+	if err := svc.Set(ContextWithAck(context.Background(), AckLeader), "k", "v", 0); err != nil || consensus.calls != 1 {
+		t.Errorf("expected the write to be applied, got %v (%d applies)", err, consensus.calls)
+	}
+}
+
+func TestParseAckLevel(t *testing.T) {
+	if ack, err := ParseAckLevel("Leader"); err != nil || ack != AckLeader {
+		t.Errorf("expected leader, got %q (%v)", ack, err)
+	}
+	if _, err := ParseAckLevel("all"); !errors.Is(err, coreerrors.ErrInvalidArgument) {
+		t.Errorf("expected ErrInvalidArgument, got %v", err)
+	}
+}
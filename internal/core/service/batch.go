@@ -0,0 +1,228 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	coreerrors "distributed-cache-service/internal/core/errors"
+	"distributed-cache-service/internal/observability"
+)
+
+// Batching configures group commit on the leader. Every Raft entry costs a
+// consensus round, so under concurrency writes queue behind each other's
+// rounds. With batching, plain SETs and DELETEs that arrive within Window of
+// the first are replicated together as one BATCH entry, of up to MaxCommands
+// commands and MaxBytes bytes of keys and stored values. Each write still
+// succeeds or fails on its own: its precondition, request ID and new version
+// are handled as for a write replicated alone.
+type Batching struct {
+	// Window is how long a batch stays open for more writes.
+	Window time.Duration
+	// MaxCommands and MaxBytes close a batch early once it reaches them.
+	MaxCommands int
+	MaxBytes    int
+}
+
+// WithBatching groups concurrent writes into shared Raft entries. A batch is
+// sent early rather than hold a write for more than half the time left before
+// its deadline, and writes cancelled while they wait are never sent. Two
+// writes to the same key never share a batch, so that every write gets a
+// version of its own. Batching needs cluster version CommandVersionBatch;
+// until then, and on followers, writes are replicated one by one.
+func WithBatching(b Batching) Option {
+	return func(s *ServiceImpl) {
+		if b.Window > 0 && b.MaxCommands > 1 && b.MaxBytes > 0 {
+			s.batcher = &batcher{Batching: b, s: s}
+		}
+	}
+}
+
+// batcher collects writes into the batch being filled.
+type batcher struct {
+	Batching
+	s *ServiceImpl
+
+	mu      sync.Mutex
+	pending *batch
+}
+
+// batch is a group of writes to be replicated as one entry.
+type batch struct {
+	writes  []*batchedWrite
+	keys    map[string]bool
+	size    int
+	closeAt time.Time
+	timer   *time.Timer
+	// sent is set once the batch's writes are submitted. Guarded by batcher.mu.
+	sent bool
+}
+
+// batchedWrite is one write waiting for its batch to commit.
+type batchedWrite struct {
+	ctx   context.Context
+	cmd   Command
+	batch *batch
+	// dropped marks a write whose caller gave up before it was submitted.
+	// Guarded by batcher.mu.
+	dropped bool
+
+	done chan struct{}
+	resp interface{}
+	err  error
+}
+
+// accepts reports whether cmd may be batched.
+func (b *batcher) accepts(cmd *Command) bool {
+	if cmd.Op != SetOp && cmd.Op != DeleteOp {
+		return false
+	}
+	return b.s.ClusterVersion() >= CommandVersionBatch && b.s.consensus.IsLeader()
+}
+
+// apply replicates cmd as part of a batch and returns its response, like
+// ports.Consensus.Apply.
+func (b *batcher) apply(ctx context.Context, cmd Command) (interface{}, error) {
+	w := &batchedWrite{ctx: ctx, cmd: cmd, done: make(chan struct{})}
+	b.add(w)
+	select {
+	case <-w.done:
+		return w.resp, w.err
+	case <-ctx.Done():
+	}
+
+	b.mu.Lock()
+	submitted := w.batch.sent && !w.dropped
+	w.dropped = !submitted
+	b.mu.Unlock()
+	if !submitted {
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			return nil, fmt.Errorf("%w: deadline passed before the write was submitted", coreerrors.ErrTimeout)
+		}
+		return nil, ctx.Err()
+	}
+	select {
+	case <-w.done:
+		return w.resp, w.err
+	default:
+	}
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return nil, fmt.Errorf("%w: %w", coreerrors.ErrApplyTimeout, ctx.Err())
+	}
+	return nil, fmt.Errorf("write abandoned, outcome unknown: %w", ctx.Err())
+}
+
+// add puts w in the pending batch, starting one if needed, and sends the
+// batch once it is full or its write with the nearest deadline should not
+// wait any longer.
+func (b *batcher) add(w *batchedWrite) {
+	n := len(w.cmd.Key) + len(w.cmd.Value) + len(w.cmd.Compressed)
+	now := time.Now()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if p := b.pending; p != nil && (p.keys[w.cmd.Key] || p.size+n > b.MaxBytes) {
+		b.flush()
+	}
+	if b.pending == nil {
+		p := &batch{keys: make(map[string]bool), closeAt: now.Add(b.Window)}
+		p.timer = time.AfterFunc(b.Window, func() {
+			b.mu.Lock()
+			defer b.mu.Unlock()
+			if b.pending == p {
+				b.flush()
+			}
+		})
+		b.pending = p
+	}
+
+	p := b.pending
+	w.batch = p
+	p.writes = append(p.writes, w)
+	p.keys[w.cmd.Key] = true
+	p.size += n
+	if deadline, ok := w.ctx.Deadline(); ok {
+		// Leave at least half the time the write has left for replication.
+		if closeAt := now.Add(deadline.Sub(now) / 2); closeAt.Before(p.closeAt) {
+			p.closeAt = closeAt
+			p.timer.Reset(closeAt.Sub(now))
+		}
+	}
+	if len(p.writes) >= b.MaxCommands || p.size >= b.MaxBytes || !p.closeAt.After(now) {
+		b.flush()
+	}
+}
+
+// flush sends the pending batch. The caller holds b.mu.
+func (b *batcher) flush() {
+	p := b.pending
+	b.pending = nil
+	p.timer.Stop()
+	go b.send(p)
+}
+
+// send replicates the writes of p whose callers are still waiting, as one
+// BATCH entry, or as a plain command if only one is left.
+func (b *batcher) send(p *batch) {
+	var live []*batchedWrite
+	var latest time.Time
+	bounded := true
+	b.mu.Lock()
+	for _, w := range p.writes {
+		if w.dropped || w.ctx.Err() != nil {
+			w.dropped = true
+			continue
+		}
+		live = append(live, w)
+		if deadline, ok := w.ctx.Deadline(); !ok {
+			bounded = false
+		} else if deadline.After(latest) {
+			latest = deadline
+		}
+	}
+	p.sent = true
+	b.mu.Unlock()
+	if len(live) == 0 {
+		return
+	}
+	observability.CacheWriteBatchSize.Observe(float64(len(live)))
+
+	cmd := live[0].cmd
+	if len(live) > 1 {
+		cmd = Command{Op: BatchOp, Version: b.s.ClusterVersion(), Batch: make([]Command, len(live))}
+		for i, w := range live {
+			cmd.Batch[i] = w.cmd
+		}
+	}
+	// The entry is awaited for as long as any of its writes is.
+	ctx := context.Background()
+	if bounded {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, latest)
+		defer cancel()
+	}
+	data, err := encodeTimed(&cmd)
+	var resp interface{}
+	if err == nil {
+		resp, err = b.s.consensus.Apply(ctx, data)
+	}
+
+	results, _ := resp.(BatchResult)
+	for i, w := range live {
+		switch {
+		case err != nil:
+			w.err = err
+		case len(live) == 1:
+			w.resp = resp
+		case i < len(results):
+			if rerr, ok := results[i].(error); ok {
+				w.err = rerr
+			} else {
+				w.resp = results[i]
+			}
+		}
+		close(w.done)
+	}
+}
@@ -0,0 +1,167 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	coreerrors "distributed-cache-service/internal/core/errors"
+)
+
+// groupConsensus records the commands it applies and answers each write with
+// its entry's index as version, failing writes to keys listed in fail.
+type groupConsensus struct {
+	MockConsensus
+	version uint32
+
+	mu      sync.Mutex
+	entries []Command
+	fail    map[string]error
+}
+
+func (m *groupConsensus) ClusterVersion() uint32 {
+	return m.version
+}
+
+func (m *groupConsensus) Apply(ctx context.Context, data []byte) (interface{}, error) {
+	var cmd Command
+	if err := DecodeCommand(data, &cmd); err != nil {
+		return nil, err
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.entries = append(m.entries, cmd)
+	index := uint64(len(m.entries))
+	answer := func(c Command) interface{} {
+		if err := m.fail[c.Key]; err != nil {
+			return err
+		}
+		return ApplyResult{Version: index}
+	}
+	if cmd.Op != BatchOp {
+		if resp, ok := answer(cmd).(error); ok {
+			return nil, resp
+		}
+		return answer(cmd), nil
+	}
+	results := make(BatchResult, len(cmd.Batch))
+	for i, c := range cmd.Batch {
+		results[i] = answer(c)
+	}
+	return results, nil
+}
+
+func (m *groupConsensus) applied() []Command {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Command(nil), m.entries...)
+}
+
+// setConcurrently sets each key from its own goroutine and returns the errors.
+func setConcurrently(svc *ServiceImpl, ctx context.Context, keys ...string) []error {
+	errs := make([]error, len(keys))
+	var wg sync.WaitGroup
+	for i, key := range keys {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = svc.Set(ctx, key, "v", 0)
+		}()
+	}
+	wg.Wait()
+	return errs
+}
+
+func TestService_Batching(t *testing.T) {
+	consensus := &groupConsensus{version: CommandVersionBatch, fail: map[string]error{
+		"bad": fmt.Errorf("%w: key is at version 7", coreerrors.ErrVersionMismatch),
+	}}
+	svc := New(&MockStore{data: map[string]string{}}, consensus, ConsistencyEventual,
+		WithBatching(Batching{Window: time.Hour, MaxCommands: 4, MaxBytes: 1 << 20}))
+
+	// Four concurrent writes fill one batch, and each gets its own answer.
+	errs := setConcurrently(svc, context.Background(), "a", "b", "c", "bad")
+	entries := consensus.applied()
+	if len(entries) != 1 || entries[0].Op != BatchOp || len(entries[0].Batch) != 4 {
+		t.Fatalf("expected one BATCH of 4 writes, got %+v", entries)
+	}
+	for i, key := range []string{"a", "b", "c", "bad"} {
+		if want := consensus.fail[key]; !errors.Is(errs[i], want) || (want == nil && errs[i] != nil) {
+			t.Errorf("write to %s: expected %v, got %v", key, want, errs[i])
+		}
+	}
+
+	// Writes of other types are not batched.
+	if _, err := svc.Append(context.Background(), "a", "x"); err != nil {
+		t.Fatal(err)
+	}
+	if entries := consensus.applied(); entries[len(entries)-1].Op != AppendOp {
+		t.Errorf("expected a plain APPEND, got %+v", entries[len(entries)-1])
+	}
+}
+
+func TestService_BatchingSplitsSameKey(t *testing.T) {
+	consensus := &groupConsensus{version: CommandVersionBatch}
+	svc := New(&MockStore{data: map[string]string{}}, consensus, ConsistencyEventual,
+		WithBatching(Batching{Window: 50 * time.Millisecond, MaxCommands: 100, MaxBytes: 1 << 20}))
+
+	for _, err := range setConcurrently(svc, context.Background(), "k", "k") {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	entries := consensus.applied()
+	if len(entries) != 2 || entries[0].Op != SetOp || entries[1].Op != SetOp {
+		t.Errorf("expected writes to one key in separate entries, got %+v", entries)
+	}
+}
+
+func TestService_BatchingHonoursDeadlines(t *testing.T) {
+	consensus := &groupConsensus{version: CommandVersionBatch}
+	svc := New(&MockStore{data: map[string]string{}}, consensus, ConsistencyEventual,
+		WithBatching(Batching{Window: time.Hour, MaxCommands: 100, MaxBytes: 1 << 20}))
+
+	// A write with a deadline is not held for the whole window.
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	if err := svc.Set(ctx, "k", "v", 0); err != nil {
+		t.Fatalf("expected the batch to be sent before the deadline, got %v", err)
+	}
+
+	// A write cancelled while it waits is never sent.
+	ctx, cancel = context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(20 * time.Millisecond)
+		cancel()
+	}()
+	if err := svc.Set(ctx, "gone", "v", 0); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	svc.batcher.mu.Lock()
+	svc.batcher.flush()
+	svc.batcher.mu.Unlock()
+	time.Sleep(20 * time.Millisecond)
+	for _, cmd := range consensus.applied() {
+		if cmd.Key == "gone" {
+			t.Errorf("expected the cancelled write not to be applied, got %+v", cmd)
+		}
+	}
+}
+
+func TestService_BatchingNeedsClusterVersion(t *testing.T) {
+	consensus := &groupConsensus{version: CommandVersionGetOrSet}
+	svc := New(&MockStore{data: map[string]string{}}, consensus, ConsistencyEventual,
+		WithBatching(Batching{Window: time.Hour, MaxCommands: 2, MaxBytes: 1 << 20}))
+
+	for _, err := range setConcurrently(svc, context.Background(), "a", "b") {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	if entries := consensus.applied(); len(entries) != 2 || entries[0].Op != SetOp || entries[1].Op != SetOp {
+		t.Errorf("expected writes replicated one by one, got %+v", entries)
+	}
+}
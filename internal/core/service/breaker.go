@@ -0,0 +1,131 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	coreerrors "distributed-cache-service/internal/core/errors"
+	"distributed-cache-service/internal/observability"
+)
+
+// Breaker configures the write circuit breaker. When replication keeps
+// failing, e.g. the leader has lost quorum or its disk has stalled, every
+// write would otherwise wait out its apply timeout. Instead, after Failures
+// writes in a row fail, the breaker opens and writes fail at once with
+// ErrUnavailable. After Cooldown it lets a single write through as a probe:
+// if it commits the breaker closes, otherwise it opens for another Cooldown.
+type Breaker struct {
+	// Failures is how many writes must fail in a row to open the breaker.
+	Failures int
+	// Cooldown is how long the breaker stays open before probing.
+	Cooldown time.Duration
+}
+
+// WithBreaker guards writes with a circuit breaker. Timeouts and errors from
+// the consensus layer count as failures. Writes refused on their merits, such
+// as failed preconditions, count as successes, since they were replicated,
+// and ErrNotLeader counts as neither. Reads are not affected.
+func WithBreaker(b Breaker) Option {
+	return func(s *ServiceImpl) {
+		if b.Failures > 0 {
+			s.breaker = &breaker{Breaker: b, now: time.Now}
+		}
+	}
+}
+
+// Breaker states, as exported by the cache_write_breaker_state metric.
+const (
+	breakerClosed = iota
+	breakerOpen
+	breakerHalfOpen
+)
+
+// breaker is the state of a write circuit breaker.
+type breaker struct {
+	Breaker
+	now func() time.Time
+
+	mu       sync.Mutex
+	state    int
+	failures int
+	openedAt time.Time
+}
+
+// allow reports whether a write may be submitted. Once Cooldown has passed,
+// an open breaker lets the next write through as its probe; it refuses the
+// others until the probe finishes.
+func (b *breaker) allow() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch {
+	case b.state == breakerClosed:
+		return nil
+	case b.state == breakerOpen && b.now().Sub(b.openedAt) >= b.Cooldown:
+		b.setState(breakerHalfOpen)
+		return nil
+	}
+	observability.CacheWriteBreakerRejectionsTotal.Inc()
+	return coreerrors.ErrUnavailable
+}
+
+// record updates the breaker with the outcome of a submitted write.
+func (b *breaker) record(err error) {
+	failed := err != nil && breakerFailure(err)
+	neutral := err != nil && !failed && (errors.Is(err, coreerrors.ErrNotLeader) || errors.Is(err, context.Canceled))
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.state {
+	case breakerClosed:
+		switch {
+		case failed:
+			if b.failures++; b.failures >= b.Failures {
+				b.open()
+			}
+		case !neutral:
+			b.failures = 0
+		}
+	case breakerHalfOpen:
+		// Whatever else finishes while probing is taken as the probe's result.
+		if failed || neutral {
+			b.open()
+		} else {
+			b.failures = 0
+			b.setState(breakerClosed)
+		}
+	}
+	// Writes submitted before the breaker opened change nothing once it has.
+}
+
+// open opens the breaker for Cooldown. The caller holds b.mu.
+func (b *breaker) open() {
+	b.openedAt = b.now()
+	b.setState(breakerOpen)
+	observability.CacheWriteBreakerOpensTotal.Inc()
+}
+
+// setState moves the breaker to state. The caller holds b.mu.
+func (b *breaker) setState(state int) {
+	b.state = state
+	observability.CacheWriteBreakerState.Set(float64(state))
+}
+
+// breakerFailure reports whether err from Apply means replication is failing,
+// rather than that the write was refused or given up by its caller.
+func breakerFailure(err error) bool {
+	if errors.Is(err, coreerrors.ErrApplyTimeout) || errors.Is(err, coreerrors.ErrTimeout) {
+		return true
+	}
+	for _, refused := range []error{
+		coreerrors.ErrNotLeader, coreerrors.ErrVersionMismatch, coreerrors.ErrWrongType,
+		coreerrors.ErrInvalidArgument, coreerrors.ErrScript, coreerrors.ErrUnsupported,
+		coreerrors.ErrQuotaExceeded, coreerrors.ErrNotFound, context.Canceled,
+	} {
+		if errors.Is(err, refused) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,99 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	coreerrors "distributed-cache-service/internal/core/errors"
+)
+
+// failingConsensus fails every Apply with err and counts the calls.
+type failingConsensus struct {
+	MockConsensus
+	err   error
+	calls int
+}
+
+func (m *failingConsensus) Apply(ctx context.Context, data []byte) (interface{}, error) {
+	m.calls++
+	if m.err != nil {
+		return nil, m.err
+	}
+	return ApplyResult{}, nil
+}
+
+func TestService_Breaker(t *testing.T) {
+	consensus := &failingConsensus{err: fmt.Errorf("%w: context deadline exceeded", coreerrors.ErrApplyTimeout)}
+	svc := New(&MockStore{data: map[string]string{}}, consensus, ConsistencyEventual,
+		WithBreaker(Breaker{Failures: 3, Cooldown: time.Second}))
+	now := time.Unix(1000, 0)
+	svc.breaker.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	// Three timeouts in a row open the breaker, which then fails writes at once.
+	for i := 0; i < 3; i++ {
+		if err := svc.Set(ctx, "k", "v", 0); !errors.Is(err, coreerrors.ErrApplyTimeout) {
+			t.Fatalf("write %d: expected ErrApplyTimeout, got %v", i, err)
+		}
+	}
+	if err := svc.Set(ctx, "k", "v", 0); !errors.Is(err, coreerrors.ErrUnavailable) || consensus.calls != 3 {
+		t.Fatalf("expected ErrUnavailable without applying, got %v after %d applies", err, consensus.calls)
+	}
+	// Invalid writes are still refused on their merits.
+	if err := svc.Set(ctx, "", "v", 0); !errors.Is(err, coreerrors.ErrEmptyKey) {
+		t.Errorf("expected ErrEmptyKey, got %v", err)
+	}
+
+	// After the cooldown a single probe goes through; a failed one reopens it.
+	now = now.Add(time.Second)
+	if err := svc.Set(ctx, "k", "v", 0); !errors.Is(err, coreerrors.ErrApplyTimeout) || consensus.calls != 4 {
+		t.Fatalf("expected the probe to be applied, got %v after %d applies", err, consensus.calls)
+	}
+	if err := svc.Set(ctx, "k", "v", 0); !errors.Is(err, coreerrors.ErrUnavailable) {
+		t.Fatalf("expected the breaker to reopen, got %v", err)
+	}
+
+	// A successful probe closes it.
+	now = now.Add(time.Second)
+	consensus.err = nil
+	for i := 0; i < 3; i++ {
+		if err := svc.Set(ctx, "k", "v", 0); err != nil {
+			t.Fatalf("write %d: %v", i, err)
+		}
+	}
+}
+
+func TestService_BreakerIgnoresRefusals(t *testing.T) {
+	consensus := &failingConsensus{}
+	svc := New(&MockStore{data: map[string]string{}}, consensus, ConsistencyEventual,
+		WithBreaker(Breaker{Failures: 2, Cooldown: time.Minute}))
+	ctx := context.Background()
+
+	// Refused writes, and those sent to a follower, never open the breaker.
+	for _, err := range []error{
+		coreerrors.ErrNotLeader,
+		fmt.Errorf("%w: key is at version 7", coreerrors.ErrVersionMismatch),
+		coreerrors.ErrWrongType,
+		context.Canceled,
+	} {
+		consensus.err = err
+		for i := 0; i < 3; i++ {
+			if got := svc.Set(ctx, "k", "v", 0); !errors.Is(got, err) {
+				t.Fatalf("expected %v, got %v", err, got)
+			}
+		}
+	}
+
+	// Failures must be consecutive: a committed write resets the count.
+	for _, err := range []error{errors.New("raft is already shutdown"), nil, errors.New("raft is already shutdown"), nil} {
+		consensus.err = err
+		svc.Set(ctx, "k", "v", 0)
+	}
+	consensus.err = nil
+	if err := svc.Set(ctx, "k", "v", 0); err != nil {
+		t.Errorf("expected the breaker to stay closed, got %v", err)
+	}
+}
@@ -0,0 +1,70 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	"distributed-cache-service/internal/core/ports"
+	"distributed-cache-service/internal/observability"
+)
+
+// BulkLoadBatchKeys and BulkLoadBatchBytes bound each replicated write of a
+// bulk load. Larger batches spread a Raft round trip over more keys, but hold
+// up every node's FSM for longer while they are applied.
+const (
+	BulkLoadBatchKeys  = 1000
+	BulkLoadBatchBytes = 1 << 20
+)
+
+// BulkLoad writes the entries next returns until it returns io.EOF. They are
+// replicated as SETs batched into transactions with no comparisons, one Raft
+// entry each, which needs no newer cluster version. A batch holds up to
+// BulkLoadBatchKeys keys and BulkLoadBatchBytes bytes of keys and stored
+// values; a larger entry is a batch of its own. TTL limits and quotas apply
+// to every entry, and a batch that breaks one fails as a whole. The load stops
+// at the first error, from next or from a batch, and the result counts the
+// batches committed before it.
+func (s *ServiceImpl) BulkLoad(ctx context.Context, next func() (ports.BulkEntry, error)) (ports.BulkLoadResult, error) {
+	var result ports.BulkLoadResult
+	var batch []Command
+	size := 0
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		if _, err := s.replicate(ctx, "bulk_load", Command{Op: TxnOp, Txn: &TxnCommand{Success: batch}}); err != nil {
+			return err
+		}
+		result.Loaded += len(batch)
+		result.Batches++
+		observability.CacheBulkLoadedKeysTotal.Add(float64(len(batch)))
+		batch, size = batch[:0], 0
+		return nil
+	}
+
+	for {
+		e, err := next()
+		if errors.Is(err, io.EOF) {
+			return result, flush()
+		}
+		if err != nil {
+			return result, err
+		}
+		cmd := Command{Op: SetOp, Key: e.Key, TTL: e.TTL}
+		s.encodeValue(&cmd, e.Value)
+		n := len(cmd.Key) + len(cmd.Value) + len(cmd.Compressed)
+		if size+n > BulkLoadBatchBytes {
+			if err := flush(); err != nil {
+				return result, err
+			}
+		}
+		batch = append(batch, cmd)
+		size += n
+		if len(batch) == BulkLoadBatchKeys {
+			if err := flush(); err != nil {
+				return result, err
+			}
+		}
+	}
+}
@@ -0,0 +1,121 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	coreerrors "distributed-cache-service/internal/core/errors"
+	"distributed-cache-service/internal/core/ports"
+)
+
+// batchConsensus records the commands it applies and fails from the failAt'th.
+type batchConsensus struct {
+	MockConsensus
+	applied []Command
+	failAt  int
+}
+
+func (m *batchConsensus) Apply(ctx context.Context, data []byte) (interface{}, error) {
+	var cmd Command
+	if err := DecodeCommand(data, &cmd); err != nil {
+		return nil, err
+	}
+	if m.failAt > 0 && len(m.applied)+1 >= m.failAt {
+		return nil, coreerrors.ErrNotLeader
+	}
+	m.applied = append(m.applied, cmd)
+	return ApplyResult{}, nil
+}
+
+// entries returns a next function yielding n entries, then io.EOF.
+func entries(n int, value string) func() (ports.BulkEntry, error) {
+	i := 0
+	return func() (ports.BulkEntry, error) {
+		if i == n {
+			return ports.BulkEntry{}, io.EOF
+		}
+		i++
+		return ports.BulkEntry{Key: fmt.Sprintf("k%d", i), Value: value, TTL: time.Minute}, nil
+	}
+}
+
+func TestService_BulkLoad(t *testing.T) {
+	consensus := &batchConsensus{}
+	svc := New(&MockStore{data: map[string]string{}}, consensus, ConsistencyEventual)
+	ctx := context.Background()
+
+	result, err := svc.BulkLoad(ctx, entries(2*BulkLoadBatchKeys+1, "v"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result != (ports.BulkLoadResult{Loaded: 2*BulkLoadBatchKeys + 1, Batches: 3}) {
+		t.Errorf("unexpected result %+v", result)
+	}
+	for i, n := range []int{BulkLoadBatchKeys, BulkLoadBatchKeys, 1} {
+		cmd := consensus.applied[i]
+		if cmd.Op != TxnOp || len(cmd.Txn.Compares) != 0 || len(cmd.Txn.Success) != n {
+			t.Fatalf("batch %d: expected a transaction of %d sets, got %+v", i, n, cmd)
+		}
+	}
+	if op := consensus.applied[0].Txn.Success[0]; op.Op != SetOp || op.Key != "k1" || op.Value != "v" || op.ExpiresAt == 0 {
+		t.Errorf("unexpected op %+v", op)
+	}
+
+	// Batches are also cut by size, and a large entry travels alone.
+	consensus.applied = nil
+	result, err = svc.BulkLoad(ctx, entries(5, strings.Repeat("x", BulkLoadBatchBytes/4-8)))
+	if err != nil || result.Batches != 2 {
+		t.Fatalf("expected 2 batches, got %+v (%v)", result, err)
+	}
+	consensus.applied = nil
+	if result, err = svc.BulkLoad(ctx, entries(1, strings.Repeat("x", 2*BulkLoadBatchBytes))); err != nil || result.Batches != 1 {
+		t.Fatalf("expected 1 batch, got %+v (%v)", result, err)
+	}
+
+	// An empty stream writes nothing.
+	consensus.applied = nil
+	if result, err = svc.BulkLoad(ctx, entries(0, "v")); err != nil || result != (ports.BulkLoadResult{}) || len(consensus.applied) != 0 {
+		t.Errorf("expected nothing written, got %+v (%v)", result, err)
+	}
+}
+
+func TestService_BulkLoadErrors(t *testing.T) {
+	consensus := &batchConsensus{failAt: 2}
+	svc := New(&MockStore{data: map[string]string{}}, consensus, ConsistencyEventual)
+	ctx := context.Background()
+
+	// A failed batch stops the load; the batches before it are counted.
+	result, err := svc.BulkLoad(ctx, entries(3*BulkLoadBatchKeys, "v"))
+	if !errors.Is(err, coreerrors.ErrNotLeader) || result != (ports.BulkLoadResult{Loaded: BulkLoadBatchKeys, Batches: 1}) {
+		t.Errorf("expected the first batch then ErrNotLeader, got %+v (%v)", result, err)
+	}
+
+	// So does an error from next, and an invalid key fails its batch.
+	consensus.failAt, consensus.applied = 0, nil
+	boom := errors.New("stream broken")
+	next := entries(5, "v")
+	calls := 0
+	if _, err := svc.BulkLoad(ctx, func() (ports.BulkEntry, error) {
+		if calls++; calls == 3 {
+			return ports.BulkEntry{}, boom
+		}
+		return next()
+	}); !errors.Is(err, boom) || len(consensus.applied) != 0 {
+		t.Errorf("expected the stream error and nothing written, got %v", err)
+	}
+	empty := false
+	if _, err := svc.BulkLoad(ctx, func() (ports.BulkEntry, error) {
+		if empty {
+			return ports.BulkEntry{}, io.EOF
+		}
+		empty = true
+		return ports.BulkEntry{Value: "v"}, nil
+	}); !errors.Is(err, coreerrors.ErrEmptyKey) {
+		t.Errorf("expected ErrEmptyKey, got %v", err)
+	}
+}
@@ -0,0 +1,284 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"distributed-cache-service/internal/core/ports"
+	"distributed-cache-service/internal/core/service/commandpb"
+
+	"google.golang.org/protobuf/proto"
+)
+
+// Command schema versions. Every node applies commands up to
+// MaxCommandVersion. The leader writes commands at the cluster version, which
+// the FSM replicates and which is only raised once every member runs a release
+// that supports it (see ServiceImpl.SetClusterVersion), so that a cluster in
+// the middle of a rolling upgrade never sees a command some member cannot apply.
+const (
+	// CommandVersionLegacy is the schema of releases before command versions:
+	// JSON-encoded, with no version field.
+	CommandVersionLegacy uint32 = 0
+	// CommandVersionBinary encodes commands as protobuf (see commandpb) and
+	// stamps them with their version.
+	CommandVersionBinary uint32 = 1
+	// CommandVersionGetOrSet adds GETORSET, encoded like CommandVersionBinary.
+	CommandVersionGetOrSet uint32 = 2
+	// CommandVersionBatch adds BATCH, which groups concurrent writes into one
+	// entry (see WithBatching).
+	CommandVersionBatch uint32 = 3
+	// CommandVersionTombstones adds TOMBSTONEGC and Command.OriginTime, with
+	// which deletes leave tombstones (see WithTombstoneRetention).
+	CommandVersionTombstones uint32 = 4
+	// CommandVersionMembers adds MEMBER, which records the gRPC address each
+	// member advertises.
+	CommandVersionMembers uint32 = 5
+
+	// MaxCommandVersion is the newest version this release applies.
+	MaxCommandVersion = CommandVersionMembers
+)
+
+// opMinVersion maps command types to the version that introduced them. The
+// leader refuses to write a command before the cluster reaches its version;
+// types not listed predate versioning. A new command type is added here with
+// a new MaxCommandVersion.
+var opMinVersion = map[CommandType]uint32{
+	GetOrSetOp:    CommandVersionGetOrSet,
+	BatchOp:       CommandVersionBatch,
+	TombstoneGCOp: CommandVersionTombstones,
+	MemberOp:      CommandVersionMembers,
+}
+
+// minVersion returns the cluster version that c, and the ops of a transaction
+// or the commands of a batch, require.
+func (c *Command) minVersion() uint32 {
+	v := opMinVersion[c.Op]
+	if c.OriginTime != 0 {
+		v = max(v, CommandVersionTombstones)
+	}
+	for i := range c.Batch {
+		v = max(v, c.Batch[i].minVersion())
+	}
+	if c.Txn != nil {
+		for _, ops := range [][]Command{c.Txn.Success, c.Txn.Failure} {
+			for i := range ops {
+				v = max(v, ops[i].minVersion())
+			}
+		}
+	}
+	return v
+}
+
+// commandFormatProto prefixes commands encoded as protobuf. JSON commands
+// start with '{', so DecodeCommand tells the formats apart by the first byte; a
+// later binary format takes another prefix.
+const commandFormatProto byte = 1
+
+// EncodeCommand encodes cmd for the Raft log: as JSON at CommandVersionLegacy,
+// which older releases can decode, and as protobuf from CommandVersionBinary.
+func EncodeCommand(cmd *Command) ([]byte, error) {
+	if cmd.Version == CommandVersionLegacy {
+		// Marshal a copy, so that cmd does not escape on the protobuf path.
+		legacy := *cmd
+		return json.Marshal(&legacy)
+	}
+	b := encodeBuffers.Get().(*encodeBuffer)
+	defer b.release()
+	msg := b.fill(cmd)
+	data := make([]byte, 1, 1+proto.Size(msg))
+	data[0] = commandFormatProto
+	return proto.MarshalOptions{}.MarshalAppend(data, msg)
+}
+
+// maxPooledEncodeBuffer is the largest scratch space an encodeBuffer keeps
+// when it goes back to the pool, so that one large value is not held onto.
+const maxPooledEncodeBuffer = 64 << 10
+
+// encodeBuffers holds the messages and scratch space EncodeCommand marshals
+// through, so that encoding a single-key command only allocates its output.
+var encodeBuffers = sync.Pool{New: func() any { return new(encodeBuffer) }}
+
+type encodeBuffer struct {
+	msg     commandpb.Command
+	scratch []byte
+}
+
+// fill sets b's message from cmd, copying its key, value and request ID into
+// b's scratch space instead of converting each to a new []byte. Nested
+// commands are converted by commandToProto.
+func (b *encodeBuffer) fill(cmd *Command) *commandpb.Command {
+	n := len(cmd.Key) + len(cmd.Value) + len(cmd.RequestID)
+	if cap(b.scratch) < n {
+		b.scratch = make([]byte, 0, n)
+	}
+	scratch := b.scratch[:0]
+	field := func(s string) []byte {
+		if s == "" {
+			return nil
+		}
+		start := len(scratch)
+		scratch = append(scratch, s...)
+		return scratch[start:len(scratch):len(scratch)]
+	}
+	commandIntoProto(&b.msg, cmd)
+	b.msg.Key = field(cmd.Key)
+	b.msg.Value = field(cmd.Value)
+	b.msg.RequestId = field(cmd.RequestID)
+	return &b.msg
+}
+
+// release resets b and returns it to encodeBuffers.
+func (b *encodeBuffer) release() {
+	b.msg.Reset()
+	if cap(b.scratch) > maxPooledEncodeBuffer {
+		b.scratch = nil
+	}
+	encodeBuffers.Put(b)
+}
+
+// DecodeCommand decodes a Raft log entry written by EncodeCommand, at any
+// version, into cmd.
+func DecodeCommand(data []byte, cmd *Command) error {
+	if len(data) == 0 {
+		return errors.New("empty command")
+	}
+	switch data[0] {
+	case '{':
+		return json.Unmarshal(data, cmd)
+	case commandFormatProto:
+		var msg commandpb.Command
+		if err := proto.Unmarshal(data[1:], &msg); err != nil {
+			return err
+		}
+		*cmd = commandFromProto(&msg)
+		return nil
+	}
+	return fmt.Errorf("unknown command format %d", data[0])
+}
+
+func commandToProto(c *Command) *commandpb.Command {
+	msg := &commandpb.Command{}
+	commandIntoProto(msg, c)
+	msg.Key = []byte(c.Key)
+	msg.Value = []byte(c.Value)
+	msg.RequestId = []byte(c.RequestID)
+	return msg
+}
+
+// commandIntoProto sets every field of msg from c but the key, value and
+// request ID, which callers convert as suits them (see encodeBuffer).
+func commandIntoProto(msg *commandpb.Command, c *Command) {
+	msg.Op = string(c.Op)
+	msg.Ttl = int64(c.TTL)
+	msg.ExpiresAt = c.ExpiresAt
+	msg.Compressed = c.Compressed
+	msg.IfVersion = c.IfVersion
+	msg.IfAbsent = c.IfAbsent
+	msg.Min = c.Min
+	msg.Max = c.Max
+	msg.Script = []byte(c.Script)
+	msg.Keys = stringsToBytes(c.Keys)
+	msg.Args = stringsToBytes(c.Args)
+	msg.Version = c.Version
+	msg.OriginTime = c.OriginTime
+	for _, m := range c.Members {
+		msg.Members = append(msg.Members, &commandpb.ScoredMember{Member: []byte(m.Member), Score: m.Score})
+	}
+	if c.Txn != nil {
+		txn := &commandpb.Txn{}
+		for _, cmp := range c.Txn.Compares {
+			txn.Compares = append(txn.Compares, &commandpb.Compare{
+				Key:      []byte(cmp.Key),
+				Target:   int32(cmp.Target),
+				Version:  cmp.Version,
+				Value:    []byte(cmp.Value),
+				NotEqual: cmp.NotEqual,
+			})
+		}
+		for i := range c.Txn.Success {
+			txn.Success = append(txn.Success, commandToProto(&c.Txn.Success[i]))
+		}
+		for i := range c.Txn.Failure {
+			txn.Failure = append(txn.Failure, commandToProto(&c.Txn.Failure[i]))
+		}
+		msg.Txn = txn
+	}
+	for i := range c.Batch {
+		msg.Batch = append(msg.Batch, commandToProto(&c.Batch[i]))
+	}
+}
+
+func commandFromProto(msg *commandpb.Command) Command {
+	c := Command{
+		Op:         CommandType(msg.Op),
+		Key:        string(msg.Key),
+		Value:      string(msg.Value),
+		TTL:        time.Duration(msg.Ttl),
+		ExpiresAt:  msg.ExpiresAt,
+		Compressed: msg.Compressed,
+		RequestID:  string(msg.RequestId),
+		IfVersion:  msg.IfVersion,
+		IfAbsent:   msg.IfAbsent,
+		Min:        msg.Min,
+		Max:        msg.Max,
+		Script:     string(msg.Script),
+		Keys:       bytesToStrings(msg.Keys),
+		Args:       bytesToStrings(msg.Args),
+		Version:    msg.Version,
+		OriginTime: msg.OriginTime,
+	}
+	if len(msg.Members) > 0 {
+		c.Members = make([]ports.ScoredMember, len(msg.Members))
+		for i, m := range msg.Members {
+			c.Members[i] = ports.ScoredMember{Member: string(m.Member), Score: m.Score}
+		}
+	}
+	if msg.Txn != nil {
+		txn := &TxnCommand{}
+		for _, cmp := range msg.Txn.Compares {
+			txn.Compares = append(txn.Compares, ports.Compare{
+				Key:      string(cmp.Key),
+				Target:   ports.CompareTarget(cmp.Target),
+				Version:  cmp.Version,
+				Value:    string(cmp.Value),
+				NotEqual: cmp.NotEqual,
+			})
+		}
+		for _, op := range msg.Txn.Success {
+			txn.Success = append(txn.Success, commandFromProto(op))
+		}
+		for _, op := range msg.Txn.Failure {
+			txn.Failure = append(txn.Failure, commandFromProto(op))
+		}
+		c.Txn = txn
+	}
+	for _, cmd := range msg.Batch {
+		c.Batch = append(c.Batch, commandFromProto(cmd))
+	}
+	return c
+}
+
+func stringsToBytes(ss []string) [][]byte {
+	if len(ss) == 0 {
+		return nil
+	}
+	bs := make([][]byte, len(ss))
+	for i, s := range ss {
+		bs[i] = []byte(s)
+	}
+	return bs
+}
+
+func bytesToStrings(bs [][]byte) []string {
+	if len(bs) == 0 {
+		return nil
+	}
+	ss := make([]string, len(bs))
+	for i, b := range bs {
+		ss[i] = string(b)
+	}
+	return ss
+}
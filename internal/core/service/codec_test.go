@@ -0,0 +1,200 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	coreerrors "distributed-cache-service/internal/core/errors"
+	"distributed-cache-service/internal/core/ports"
+)
+
+func codecCommands() []Command {
+	return []Command{
+		{Op: SetOp, Key: "key", Value: "value", TTL: time.Minute, ExpiresAt: 1700000000000000000, RequestID: "req-1", IfVersion: 7},
+		{Op: SetOp, Key: "bin\xff\x00", Compressed: []byte{0, 1, 0xff}, IfAbsent: true},
+		{Op: DeleteOp, Key: "key"},
+		{Op: ZAddOp, Key: "zset", Members: []ports.ScoredMember{{Member: "a", Score: 1.5}, {Member: "b", Score: -2}}},
+		{Op: ZRemRangeByScoreOp, Key: "zset", Min: -math.MaxFloat64, Max: math.MaxFloat64},
+		{Op: EvalOp, Script: "return ARGV[1]", Keys: []string{"k1", "k2"}, Args: []string{"x"}},
+		{Op: PurgeOp, Keys: []string{"a", "b"}, ExpiresAt: 42},
+		{Op: TxnOp, Txn: &TxnCommand{
+			Compares: []ports.Compare{{Key: "a", Target: ports.CompareValue, Value: "1", NotEqual: true}, {Key: "b", Version: 3}},
+			Success:  []Command{{Op: SetOp, Key: "a", Value: "2"}, {Op: GetOp, Key: "b"}},
+			Failure:  []Command{{Op: DeleteOp, Key: "a"}},
+		}},
+		{Op: BatchOp, Batch: []Command{{Op: SetOp, Key: "a", Value: "1", RequestID: "req-2"}, {Op: DeleteOp, Key: "b", IfVersion: 3}}},
+	}
+}
+
+func TestCommandCodec_RoundTrip(t *testing.T) {
+	for _, version := range []uint32{CommandVersionLegacy, CommandVersionBinary} {
+		for _, cmd := range codecCommands() {
+			cmd.Version = version
+			data, err := EncodeCommand(&cmd)
+			if err != nil {
+				t.Fatalf("v%d: encode %s: %v", version, cmd.Op, err)
+			}
+			var got Command
+			if err := DecodeCommand(data, &got); err != nil {
+				t.Fatalf("v%d: decode %s: %v", version, cmd.Op, err)
+			}
+			// JSON replaces invalid UTF-8; only the binary format keeps such
+			// keys intact.
+			if version == CommandVersionLegacy && !utf8.ValidString(cmd.Key) {
+				continue
+			}
+			if !reflect.DeepEqual(got, cmd) {
+				t.Errorf("v%d: round trip of %s: got %+v, want %+v", version, cmd.Op, got, cmd)
+			}
+		}
+	}
+}
+
+func TestCommandCodec_BinaryIsSmaller(t *testing.T) {
+	cmd := Command{Op: SetOp, Key: "user:1234", Value: "value", TTL: time.Minute, ExpiresAt: time.Now().UnixNano(), RequestID: "0f8fad5b-d9cb-469f-a165-70867728950e"}
+	legacy, err := EncodeCommand(&cmd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmd.Version = CommandVersionBinary
+	binary, err := EncodeCommand(&cmd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if legacy[0] != '{' || binary[0] != commandFormatProto {
+		t.Errorf("expected JSON at version 0 and protobuf at version 1, got %q and %q", legacy[0], binary[0])
+	}
+	if len(binary) >= len(legacy) {
+		t.Errorf("expected binary encoding to be smaller than JSON: %d >= %d bytes", len(binary), len(legacy))
+	}
+}
+
+// Entries written by releases that only knew JSON still decode.
+func TestDecodeCommand_LegacyJSON(t *testing.T) {
+	data := []byte(`{"op":"SET","key":"key","value":"value","ttl":60000000000,"compressed":"AAH/","request_id":"req-1"}`)
+	var got Command
+	if err := DecodeCommand(data, &got); err != nil {
+		t.Fatal(err)
+	}
+	want := Command{Op: SetOp, Key: "key", Value: "value", TTL: time.Minute, Compressed: []byte{0, 1, 0xff}, RequestID: "req-1"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+}
+
+func TestDecodeCommand_Invalid(t *testing.T) {
+	for _, data := range [][]byte{nil, {0x7f, 1, 2}, {commandFormatProto, 0xff}} {
+		var cmd Command
+		if err := DecodeCommand(data, &cmd); err == nil {
+			t.Errorf("expected an error decoding %q", data)
+		}
+	}
+}
+
+// versionedConsensus reports a fixed cluster version and records the last
+// command it was given, as encoded.
+type versionedConsensus struct {
+	MockConsensus
+	version uint32
+	data    []byte
+	last    Command
+}
+
+func (m *versionedConsensus) ClusterVersion() uint32 {
+	return m.version
+}
+
+func (m *versionedConsensus) Apply(ctx context.Context, data []byte) (interface{}, error) {
+	m.data = data
+	if err := DecodeCommand(data, &m.last); err != nil {
+		return nil, err
+	}
+	if m.last.Op == ClusterVersionOp {
+		m.version = m.last.Version
+	}
+	return nil, nil
+}
+
+func TestService_ClusterVersion(t *testing.T) {
+	cluster := &versionedConsensus{}
+	svc := New(&MockStore{data: map[string]string{}}, cluster, ConsistencyEventual)
+	ctx := context.Background()
+
+	// An upgraded cluster keeps writing what older members can decode.
+	if err := svc.Set(ctx, "key", "value", 0); err != nil {
+		t.Fatal(err)
+	}
+	if cluster.data[0] != '{' || cluster.last.Version != CommandVersionLegacy {
+		t.Errorf("expected a legacy JSON command, got version %d: %q", cluster.last.Version, cluster.data)
+	}
+
+	// Command types newer than the cluster version are held back.
+	opMinVersion[SetOp] = CommandVersionBinary
+	defer delete(opMinVersion, SetOp)
+	txn := ports.Txn{Success: []ports.TxnOp{{Type: ports.TxnSet, Key: "key", Value: "value"}}}
+	if _, err := svc.Txn(ctx, txn); !errors.Is(err, coreerrors.ErrUnsupported) {
+		t.Errorf("expected ErrUnsupported for a gated op, got %v", err)
+	}
+
+	if err := svc.SetClusterVersion(ctx, MaxCommandVersion+1); !errors.Is(err, coreerrors.ErrInvalidArgument) {
+		t.Errorf("expected ErrInvalidArgument for an unknown version, got %v", err)
+	}
+	if err := svc.SetClusterVersion(ctx, CommandVersionBinary); err != nil {
+		t.Fatal(err)
+	}
+	if svc.ClusterVersion() != CommandVersionBinary {
+		t.Fatalf("expected cluster version %d, got %d", CommandVersionBinary, svc.ClusterVersion())
+	}
+	if err := svc.Set(ctx, "key", "value", 0); err != nil {
+		t.Fatal(err)
+	}
+	if cluster.data[0] != commandFormatProto || cluster.last.Version != CommandVersionBinary {
+		t.Errorf("expected a binary command at version %d, got version %d", CommandVersionBinary, cluster.last.Version)
+	}
+
+	// GETORSET needs the version that introduced it.
+	if _, _, err := svc.GetOrSet(ctx, "key", "value", 0); !errors.Is(err, coreerrors.ErrUnsupported) {
+		t.Errorf("expected ErrUnsupported for GETORSET at version %d, got %v", CommandVersionBinary, err)
+	}
+	if err := svc.SetClusterVersion(ctx, CommandVersionGetOrSet); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := svc.GetOrSet(ctx, "key", "value", 0); err != nil || cluster.last.Op != GetOrSetOp {
+		t.Errorf("expected a GETORSET command, got %+v (%v)", cluster.last, err)
+	}
+
+	// Lowering the version is a no-op.
+	cluster.last = Command{}
+	if err := svc.SetClusterVersion(ctx, CommandVersionLegacy); err != nil || cluster.last.Op != "" {
+		t.Errorf("expected no command for a lower version, got %v, %+v", err, cluster.last)
+	}
+}
+
+func BenchmarkCommandCodec(b *testing.B) {
+	cmd := Command{Op: SetOp, Key: "user:1234", Value: strings.Repeat("v", 256), TTL: time.Minute, ExpiresAt: time.Now().UnixNano(), RequestID: "0f8fad5b-d9cb-469f-a165-70867728950e"}
+	for _, version := range []uint32{CommandVersionLegacy, CommandVersionBinary} {
+		cmd.Version = version
+		data, _ := EncodeCommand(&cmd)
+		name := map[uint32]string{CommandVersionLegacy: "json", CommandVersionBinary: "proto"}[version]
+		b.Run(name+"/encode", func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				EncodeCommand(&cmd)
+			}
+		})
+		b.Run(name+"/decode", func(b *testing.B) {
+			b.ReportAllocs()
+			b.ReportMetric(float64(len(data)), "bytes/cmd")
+			for i := 0; i < b.N; i++ {
+				var c Command
+				DecodeCommand(data, &c)
+			}
+		})
+	}
+}
@@ -0,0 +1,507 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.10
+// 	protoc        v6.33.2
+// source: internal/core/service/commandpb/command.proto
+
+package commandpb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// Command is the binary form of service.Command, the Raft log entry of a
+// write; see that type for the meaning of each field. It is internal to the
+// servers and not part of the client API.
+//
+// Keys, values and script arguments are bytes rather than strings: proto3
+// strings must be valid UTF-8, while the cache stores arbitrary bytes.
+type Command struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Op            string                 `protobuf:"bytes,1,opt,name=op,proto3" json:"op,omitempty"`
+	Key           []byte                 `protobuf:"bytes,2,opt,name=key,proto3" json:"key,omitempty"`
+	Value         []byte                 `protobuf:"bytes,3,opt,name=value,proto3" json:"value,omitempty"`
+	Ttl           int64                  `protobuf:"varint,4,opt,name=ttl,proto3" json:"ttl,omitempty"`                              // nanoseconds
+	ExpiresAt     int64                  `protobuf:"varint,5,opt,name=expires_at,json=expiresAt,proto3" json:"expires_at,omitempty"` // Unix nanoseconds
+	Compressed    []byte                 `protobuf:"bytes,6,opt,name=compressed,proto3" json:"compressed,omitempty"`
+	RequestId     []byte                 `protobuf:"bytes,7,opt,name=request_id,json=requestId,proto3" json:"request_id,omitempty"`
+	IfVersion     uint64                 `protobuf:"varint,8,opt,name=if_version,json=ifVersion,proto3" json:"if_version,omitempty"`
+	IfAbsent      bool                   `protobuf:"varint,9,opt,name=if_absent,json=ifAbsent,proto3" json:"if_absent,omitempty"`
+	Members       []*ScoredMember        `protobuf:"bytes,10,rep,name=members,proto3" json:"members,omitempty"`
+	Min           float64                `protobuf:"fixed64,11,opt,name=min,proto3" json:"min,omitempty"`
+	Max           float64                `protobuf:"fixed64,12,opt,name=max,proto3" json:"max,omitempty"`
+	Script        []byte                 `protobuf:"bytes,13,opt,name=script,proto3" json:"script,omitempty"`
+	Keys          [][]byte               `protobuf:"bytes,14,rep,name=keys,proto3" json:"keys,omitempty"`
+	Args          [][]byte               `protobuf:"bytes,15,rep,name=args,proto3" json:"args,omitempty"`
+	Txn           *Txn                   `protobuf:"bytes,16,opt,name=txn,proto3" json:"txn,omitempty"`
+	Version       uint32                 `protobuf:"varint,17,opt,name=version,proto3" json:"version,omitempty"`
+	Batch         []*Command             `protobuf:"bytes,18,rep,name=batch,proto3" json:"batch,omitempty"`
+	OriginTime    int64                  `protobuf:"varint,19,opt,name=origin_time,json=originTime,proto3" json:"origin_time,omitempty"` // Unix nanoseconds
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Command) Reset() {
+	*x = Command{}
+	mi := &file_internal_core_service_commandpb_command_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Command) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Command) ProtoMessage() {}
+
+func (x *Command) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_core_service_commandpb_command_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Command.ProtoReflect.Descriptor instead.
+func (*Command) Descriptor() ([]byte, []int) {
+	return file_internal_core_service_commandpb_command_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Command) GetOp() string {
+	if x != nil {
+		return x.Op
+	}
+	return ""
+}
+
+func (x *Command) GetKey() []byte {
+	if x != nil {
+		return x.Key
+	}
+	return nil
+}
+
+func (x *Command) GetValue() []byte {
+	if x != nil {
+		return x.Value
+	}
+	return nil
+}
+
+func (x *Command) GetTtl() int64 {
+	if x != nil {
+		return x.Ttl
+	}
+	return 0
+}
+
+func (x *Command) GetExpiresAt() int64 {
+	if x != nil {
+		return x.ExpiresAt
+	}
+	return 0
+}
+
+func (x *Command) GetCompressed() []byte {
+	if x != nil {
+		return x.Compressed
+	}
+	return nil
+}
+
+func (x *Command) GetRequestId() []byte {
+	if x != nil {
+		return x.RequestId
+	}
+	return nil
+}
+
+func (x *Command) GetIfVersion() uint64 {
+	if x != nil {
+		return x.IfVersion
+	}
+	return 0
+}
+
+func (x *Command) GetIfAbsent() bool {
+	if x != nil {
+		return x.IfAbsent
+	}
+	return false
+}
+
+func (x *Command) GetMembers() []*ScoredMember {
+	if x != nil {
+		return x.Members
+	}
+	return nil
+}
+
+func (x *Command) GetMin() float64 {
+	if x != nil {
+		return x.Min
+	}
+	return 0
+}
+
+func (x *Command) GetMax() float64 {
+	if x != nil {
+		return x.Max
+	}
+	return 0
+}
+
+func (x *Command) GetScript() []byte {
+	if x != nil {
+		return x.Script
+	}
+	return nil
+}
+
+func (x *Command) GetKeys() [][]byte {
+	if x != nil {
+		return x.Keys
+	}
+	return nil
+}
+
+func (x *Command) GetArgs() [][]byte {
+	if x != nil {
+		return x.Args
+	}
+	return nil
+}
+
+func (x *Command) GetTxn() *Txn {
+	if x != nil {
+		return x.Txn
+	}
+	return nil
+}
+
+func (x *Command) GetVersion() uint32 {
+	if x != nil {
+		return x.Version
+	}
+	return 0
+}
+
+func (x *Command) GetBatch() []*Command {
+	if x != nil {
+		return x.Batch
+	}
+	return nil
+}
+
+func (x *Command) GetOriginTime() int64 {
+	if x != nil {
+		return x.OriginTime
+	}
+	return 0
+}
+
+type ScoredMember struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Member        []byte                 `protobuf:"bytes,1,opt,name=member,proto3" json:"member,omitempty"`
+	Score         float64                `protobuf:"fixed64,2,opt,name=score,proto3" json:"score,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ScoredMember) Reset() {
+	*x = ScoredMember{}
+	mi := &file_internal_core_service_commandpb_command_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ScoredMember) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ScoredMember) ProtoMessage() {}
+
+func (x *ScoredMember) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_core_service_commandpb_command_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ScoredMember.ProtoReflect.Descriptor instead.
+func (*ScoredMember) Descriptor() ([]byte, []int) {
+	return file_internal_core_service_commandpb_command_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *ScoredMember) GetMember() []byte {
+	if x != nil {
+		return x.Member
+	}
+	return nil
+}
+
+func (x *ScoredMember) GetScore() float64 {
+	if x != nil {
+		return x.Score
+	}
+	return 0
+}
+
+type Txn struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Compares      []*Compare             `protobuf:"bytes,1,rep,name=compares,proto3" json:"compares,omitempty"`
+	Success       []*Command             `protobuf:"bytes,2,rep,name=success,proto3" json:"success,omitempty"`
+	Failure       []*Command             `protobuf:"bytes,3,rep,name=failure,proto3" json:"failure,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Txn) Reset() {
+	*x = Txn{}
+	mi := &file_internal_core_service_commandpb_command_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Txn) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Txn) ProtoMessage() {}
+
+func (x *Txn) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_core_service_commandpb_command_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Txn.ProtoReflect.Descriptor instead.
+func (*Txn) Descriptor() ([]byte, []int) {
+	return file_internal_core_service_commandpb_command_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *Txn) GetCompares() []*Compare {
+	if x != nil {
+		return x.Compares
+	}
+	return nil
+}
+
+func (x *Txn) GetSuccess() []*Command {
+	if x != nil {
+		return x.Success
+	}
+	return nil
+}
+
+func (x *Txn) GetFailure() []*Command {
+	if x != nil {
+		return x.Failure
+	}
+	return nil
+}
+
+type Compare struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Key           []byte                 `protobuf:"bytes,1,opt,name=key,proto3" json:"key,omitempty"`
+	Target        int32                  `protobuf:"varint,2,opt,name=target,proto3" json:"target,omitempty"` // ports.CompareTarget
+	Version       uint64                 `protobuf:"varint,3,opt,name=version,proto3" json:"version,omitempty"`
+	Value         []byte                 `protobuf:"bytes,4,opt,name=value,proto3" json:"value,omitempty"`
+	NotEqual      bool                   `protobuf:"varint,5,opt,name=not_equal,json=notEqual,proto3" json:"not_equal,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Compare) Reset() {
+	*x = Compare{}
+	mi := &file_internal_core_service_commandpb_command_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Compare) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Compare) ProtoMessage() {}
+
+func (x *Compare) ProtoReflect() protoreflect.Message {
+	mi := &file_internal_core_service_commandpb_command_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Compare.ProtoReflect.Descriptor instead.
+func (*Compare) Descriptor() ([]byte, []int) {
+	return file_internal_core_service_commandpb_command_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *Compare) GetKey() []byte {
+	if x != nil {
+		return x.Key
+	}
+	return nil
+}
+
+func (x *Compare) GetTarget() int32 {
+	if x != nil {
+		return x.Target
+	}
+	return 0
+}
+
+func (x *Compare) GetVersion() uint64 {
+	if x != nil {
+		return x.Version
+	}
+	return 0
+}
+
+func (x *Compare) GetValue() []byte {
+	if x != nil {
+		return x.Value
+	}
+	return nil
+}
+
+func (x *Compare) GetNotEqual() bool {
+	if x != nil {
+		return x.NotEqual
+	}
+	return false
+}
+
+var File_internal_core_service_commandpb_command_proto protoreflect.FileDescriptor
+
+const file_internal_core_service_commandpb_command_proto_rawDesc = "" +
+	"\n" +
+	"-internal/core/service/commandpb/command.proto\x12\rcache.command\"\x97\x04\n" +
+	"\aCommand\x12\x0e\n" +
+	"\x02op\x18\x01 \x01(\tR\x02op\x12\x10\n" +
+	"\x03key\x18\x02 \x01(\fR\x03key\x12\x14\n" +
+	"\x05value\x18\x03 \x01(\fR\x05value\x12\x10\n" +
+	"\x03ttl\x18\x04 \x01(\x03R\x03ttl\x12\x1d\n" +
+	"\n" +
+	"expires_at\x18\x05 \x01(\x03R\texpiresAt\x12\x1e\n" +
+	"\n" +
+	"compressed\x18\x06 \x01(\fR\n" +
+	"compressed\x12\x1d\n" +
+	"\n" +
+	"request_id\x18\a \x01(\fR\trequestId\x12\x1d\n" +
+	"\n" +
+	"if_version\x18\b \x01(\x04R\tifVersion\x12\x1b\n" +
+	"\tif_absent\x18\t \x01(\bR\bifAbsent\x125\n" +
+	"\amembers\x18\n" +
+	" \x03(\v2\x1b.cache.command.ScoredMemberR\amembers\x12\x10\n" +
+	"\x03min\x18\v \x01(\x01R\x03min\x12\x10\n" +
+	"\x03max\x18\f \x01(\x01R\x03max\x12\x16\n" +
+	"\x06script\x18\r \x01(\fR\x06script\x12\x12\n" +
+	"\x04keys\x18\x0e \x03(\fR\x04keys\x12\x12\n" +
+	"\x04args\x18\x0f \x03(\fR\x04args\x12$\n" +
+	"\x03txn\x18\x10 \x01(\v2\x12.cache.command.TxnR\x03txn\x12\x18\n" +
+	"\aversion\x18\x11 \x01(\rR\aversion\x12,\n" +
+	"\x05batch\x18\x12 \x03(\v2\x16.cache.command.CommandR\x05batch\x12\x1f\n" +
+	"\vorigin_time\x18\x13 \x01(\x03R\n" +
+	"originTime\"<\n" +
+	"\fScoredMember\x12\x16\n" +
+	"\x06member\x18\x01 \x01(\fR\x06member\x12\x14\n" +
+	"\x05score\x18\x02 \x01(\x01R\x05score\"\x9d\x01\n" +
+	"\x03Txn\x122\n" +
+	"\bcompares\x18\x01 \x03(\v2\x16.cache.command.CompareR\bcompares\x120\n" +
+	"\asuccess\x18\x02 \x03(\v2\x16.cache.command.CommandR\asuccess\x120\n" +
+	"\afailure\x18\x03 \x03(\v2\x16.cache.command.CommandR\afailure\"\x80\x01\n" +
+	"\aCompare\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\fR\x03key\x12\x16\n" +
+	"\x06target\x18\x02 \x01(\x05R\x06target\x12\x18\n" +
+	"\aversion\x18\x03 \x01(\x04R\aversion\x12\x14\n" +
+	"\x05value\x18\x04 \x01(\fR\x05value\x12\x1b\n" +
+	"\tnot_equal\x18\x05 \x01(\bR\bnotEqualB;Z9distributed-cache-service/internal/core/service/commandpbb\x06proto3"
+
+var (
+	file_internal_core_service_commandpb_command_proto_rawDescOnce sync.Once
+	file_internal_core_service_commandpb_command_proto_rawDescData []byte
+)
+
+func file_internal_core_service_commandpb_command_proto_rawDescGZIP() []byte {
+	file_internal_core_service_commandpb_command_proto_rawDescOnce.Do(func() {
+		file_internal_core_service_commandpb_command_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_internal_core_service_commandpb_command_proto_rawDesc), len(file_internal_core_service_commandpb_command_proto_rawDesc)))
+	})
+	return file_internal_core_service_commandpb_command_proto_rawDescData
+}
+
+var file_internal_core_service_commandpb_command_proto_msgTypes = make([]protoimpl.MessageInfo, 4)
+var file_internal_core_service_commandpb_command_proto_goTypes = []any{
+	(*Command)(nil),      // 0: cache.command.Command
+	(*ScoredMember)(nil), // 1: cache.command.ScoredMember
+	(*Txn)(nil),          // 2: cache.command.Txn
+	(*Compare)(nil),      // 3: cache.command.Compare
+}
+var file_internal_core_service_commandpb_command_proto_depIdxs = []int32{
+	1, // 0: cache.command.Command.members:type_name -> cache.command.ScoredMember
+	2, // 1: cache.command.Command.txn:type_name -> cache.command.Txn
+	0, // 2: cache.command.Command.batch:type_name -> cache.command.Command
+	3, // 3: cache.command.Txn.compares:type_name -> cache.command.Compare
+	0, // 4: cache.command.Txn.success:type_name -> cache.command.Command
+	0, // 5: cache.command.Txn.failure:type_name -> cache.command.Command
+	6, // [6:6] is the sub-list for method output_type
+	6, // [6:6] is the sub-list for method input_type
+	6, // [6:6] is the sub-list for extension type_name
+	6, // [6:6] is the sub-list for extension extendee
+	0, // [0:6] is the sub-list for field type_name
+}
+
+func init() { file_internal_core_service_commandpb_command_proto_init() }
+func file_internal_core_service_commandpb_command_proto_init() {
+	if File_internal_core_service_commandpb_command_proto != nil {
+		return
+	}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_internal_core_service_commandpb_command_proto_rawDesc), len(file_internal_core_service_commandpb_command_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   4,
+			NumExtensions: 0,
+			NumServices:   0,
+		},
+		GoTypes:           file_internal_core_service_commandpb_command_proto_goTypes,
+		DependencyIndexes: file_internal_core_service_commandpb_command_proto_depIdxs,
+		MessageInfos:      file_internal_core_service_commandpb_command_proto_msgTypes,
+	}.Build()
+	File_internal_core_service_commandpb_command_proto = out.File
+	file_internal_core_service_commandpb_command_proto_goTypes = nil
+	file_internal_core_service_commandpb_command_proto_depIdxs = nil
+}
@@ -0,0 +1,132 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"time"
+
+	coreerrors "distributed-cache-service/internal/core/errors"
+	"distributed-cache-service/internal/observability"
+)
+
+// Config holds the service's timeouts, and how it retries operations caught
+// in a leader change. Timeouts only apply to requests whose context has no
+// deadline of its own.
+type Config struct {
+	// ReadTimeout bounds a read: the leadership check of a strong read, and
+	// the wait for the store or the loader on a miss.
+	ReadTimeout time.Duration
+	// ApplyTimeout bounds a write, from its submission to Raft until it is
+	// applied on this node.
+	ApplyTimeout time.Duration
+	// BackgroundTimeout bounds the work the service starts on its own, which
+	// no request waits for: refreshing a stale or expiring key, and evicting
+	// keys over capacity.
+	BackgroundTimeout time.Duration
+	Retry             RetryPolicy
+}
+
+// RetryPolicy retries writes and strong reads that fail with ErrNotLeader on
+// a node that was the leader when they arrived: leadership is changing, and
+// the node may win the election. Requests that reach a follower are not
+// retried, since its clients should go to the leader. Writes are only retried
+// if they never reached the log; one that failed because leadership was lost
+// after it was submitted may still commit.
+type RetryPolicy struct {
+	// Attempts is the most times an operation is tried, the first included.
+	// 1 disables retries.
+	Attempts int
+	// Backoff is the wait before the first retry. It doubles for each of the
+	// next, up to MaxBackoff, and every wait is jittered by up to ±25%.
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+}
+
+// DefaultConfig is the configuration of a service given no WithConfig.
+var DefaultConfig = Config{
+	ReadTimeout:       5 * time.Second,
+	ApplyTimeout:      2 * time.Second,
+	BackgroundTimeout: 30 * time.Second,
+	Retry: RetryPolicy{
+		Attempts:   3,
+		Backoff:    50 * time.Millisecond,
+		MaxBackoff: time.Second,
+	},
+}
+
+// WithConfig sets the service's timeouts and retry policy. Fields left zero
+// keep their value in DefaultConfig.
+func WithConfig(c Config) Option {
+	return func(s *ServiceImpl) {
+		d := DefaultConfig
+		if c.ReadTimeout <= 0 {
+			c.ReadTimeout = d.ReadTimeout
+		}
+		if c.ApplyTimeout <= 0 {
+			c.ApplyTimeout = d.ApplyTimeout
+		}
+		if c.BackgroundTimeout <= 0 {
+			c.BackgroundTimeout = d.BackgroundTimeout
+		}
+		if c.Retry.Attempts <= 0 {
+			c.Retry.Attempts = d.Retry.Attempts
+		}
+		if c.Retry.Backoff <= 0 {
+			c.Retry.Backoff = d.Retry.Backoff
+		}
+		if c.Retry.MaxBackoff <= 0 {
+			c.Retry.MaxBackoff = max(d.Retry.MaxBackoff, c.Retry.Backoff)
+		}
+		s.config = c
+	}
+}
+
+// withTimeout bounds ctx by timeout, unless it has a deadline already.
+func withTimeout(ctx context.Context, timeout time.Duration) (context.Context, context.CancelFunc) {
+	if _, ok := ctx.Deadline(); ok {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, timeout)
+}
+
+// retry calls fn until it succeeds, fails with an error the retry policy does
+// not cover, or the policy's attempts or ctx run out. It returns fn's last
+// error. If write is set, fn is not retried after losing leadership, since
+// what it wrote may still commit.
+func (s *ServiceImpl) retry(ctx context.Context, op string, write bool, fn func() error) error {
+	led := s.consensus.IsLeader()
+	backoff := s.config.Retry.Backoff
+	for attempt := 1; ; attempt++ {
+		err := fn()
+		if err == nil || !led || attempt >= s.config.Retry.Attempts || !errors.Is(err, coreerrors.ErrNotLeader) ||
+			write && errors.Is(err, coreerrors.ErrLeadershipLost) {
+			return err
+		}
+		observability.CacheRetriesTotal.WithLabelValues(op).Inc()
+		wait := time.Duration(float64(backoff) * (0.75 + rand.Float64()/2))
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+		backoff = min(2*backoff, s.config.Retry.MaxBackoff)
+	}
+}
+
+// verifyLeader confirms this node can serve a strong read, within ctx.
+func (s *ServiceImpl) verifyLeader(ctx context.Context) error {
+	return s.retry(ctx, "verify_leader", false, func() error {
+		done := make(chan error, 1)
+		go func() { done <- s.consensus.VerifyLeader() }()
+		select {
+		case err := <-done:
+			return err
+		case <-ctx.Done():
+			return fmt.Errorf("%w: leadership not confirmed: %w", coreerrors.ErrTimeout, ctx.Err())
+		}
+	})
+}
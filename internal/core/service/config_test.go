@@ -0,0 +1,110 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	coreerrors "distributed-cache-service/internal/core/errors"
+	"distributed-cache-service/internal/store"
+)
+
+// electionConsensus is a leader whose first failures calls fail with err, as
+// while leadership changes.
+type electionConsensus struct {
+	MockConsensus
+	err      error
+	failures int
+	leader   bool
+	applies  int
+	verifies int
+	deadline bool
+}
+
+func (c *electionConsensus) Apply(ctx context.Context, cmd []byte) (interface{}, error) {
+	_, c.deadline = ctx.Deadline()
+	c.applies++
+	if c.applies <= c.failures {
+		return nil, c.err
+	}
+	return nil, nil
+}
+
+func (c *electionConsensus) IsLeader() bool { return c.leader }
+
+func (c *electionConsensus) VerifyLeader() error {
+	c.verifies++
+	if c.verifies <= c.failures {
+		return c.err
+	}
+	return nil
+}
+
+func TestService_RetryDuringElection(t *testing.T) {
+	notLeader := fmt.Errorf("%w: raft", coreerrors.ErrNotLeader)
+	lost := fmt.Errorf("%w: %w: raft", coreerrors.ErrNotLeader, coreerrors.ErrLeadershipLost)
+	cfg := Config{Retry: RetryPolicy{Attempts: 3, Backoff: time.Millisecond}}
+	ctx := context.Background()
+
+	tests := []struct {
+		name     string
+		err      error
+		failures int
+		leader   bool
+		wantErr  bool
+		applies  int
+	}{
+		{"leader recovers", notLeader, 2, true, false, 3},
+		{"attempts run out", notLeader, 3, true, true, 3},
+		{"follower", notLeader, 1, false, true, 1},
+		{"outcome unknown", lost, 1, true, true, 1},
+		{"other error", coreerrors.ErrTimeout, 1, true, true, 1},
+	}
+	for _, tt := range tests {
+		c := &electionConsensus{err: tt.err, failures: tt.failures, leader: tt.leader}
+		svc := New(store.New(), c, ConsistencyStrong, WithConfig(cfg))
+		err := svc.Set(ctx, "k", "v", 0)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: got error %v", tt.name, err)
+		}
+		if c.applies != tt.applies {
+			t.Errorf("%s: applied %d times, want %d", tt.name, c.applies, tt.applies)
+		}
+		if !c.deadline {
+			t.Errorf("%s: expected the apply to be bounded by ApplyTimeout", tt.name)
+		}
+	}
+
+	// Strong reads write nothing, so losing leadership is worth a retry too.
+	c := &electionConsensus{err: lost, failures: 1, leader: true}
+	svc := New(store.New(), c, ConsistencyStrong, WithConfig(cfg))
+	if _, err := svc.Get(ctx, "k"); !errors.Is(err, coreerrors.ErrNotFound) {
+		t.Errorf("expected the read to go through, got %v", err)
+	}
+	if c.verifies != 2 {
+		t.Errorf("verified leadership %d times, want 2", c.verifies)
+	}
+}
+
+// slowConsensus takes a second to confirm leadership.
+type slowConsensus struct {
+	MockConsensus
+}
+
+func (*slowConsensus) VerifyLeader() error {
+	time.Sleep(time.Second)
+	return nil
+}
+
+func TestService_ReadTimeout(t *testing.T) {
+	svc := New(store.New(), &slowConsensus{}, ConsistencyStrong, WithConfig(Config{ReadTimeout: 10 * time.Millisecond}))
+	start := time.Now()
+	if _, err := svc.Get(context.Background(), "k"); !errors.Is(err, coreerrors.ErrTimeout) {
+		t.Errorf("expected ErrTimeout, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+		t.Errorf("read took %v despite a 10ms timeout", elapsed)
+	}
+}
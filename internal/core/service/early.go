@@ -0,0 +1,91 @@
+package service
+
+import (
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"strconv"
+	"strings"
+	"time"
+
+	coreerrors "distributed-cache-service/internal/core/errors"
+	"distributed-cache-service/internal/core/ports"
+)
+
+// WithEarlyRefresh makes reads refresh keys from the loader shortly before
+// they expire, with the XFetch algorithm: a read of a key with ttl left
+// refreshes it in the background with probability that grows as ttl shrinks
+// towards the time the loader takes, scaled by beta. Refreshes thus spread
+// over the last moments of a key's life, on every node, instead of all
+// readers missing at once when it expires. Until a node has timed a load it
+// does not refresh early.
+//
+// betas maps key prefixes (namespaces) to beta; the longest matching prefix
+// applies, and "" matches every key. A beta of 1 is the usual choice, larger
+// values refresh earlier, and 0 turns early refresh off. It requires WithLoader
+// and a store that implements ports.ExpiryStorage.
+func WithEarlyRefresh(betas map[string]float64) Option {
+	return func(s *ServiceImpl) {
+		s.earlyBetas = betas
+	}
+}
+
+// ParseEarlyRefresh parses a comma-separated list of [prefix=]beta, such as
+// "1,session:=0,user:=2", for WithEarlyRefresh. A beta without a prefix
+// applies to every key.
+func ParseEarlyRefresh(spec string) (map[string]float64, error) {
+	betas := make(map[string]float64)
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		prefix, value := "", entry
+		if i := strings.LastIndexByte(entry, '='); i >= 0 {
+			prefix, value = entry[:i], entry[i+1:]
+		}
+		beta, err := strconv.ParseFloat(value, 64)
+		if err != nil || beta < 0 || math.IsInf(beta, 0) || math.IsNaN(beta) {
+			return nil, fmt.Errorf("%w: invalid early refresh beta %q", coreerrors.ErrInvalidArgument, entry)
+		}
+		betas[prefix] = beta
+	}
+	return betas, nil
+}
+
+// earlyBeta returns the beta of the longest prefix of key in s.earlyBetas.
+func (s *ServiceImpl) earlyBeta(key string) float64 {
+	return longestPrefix(s.earlyBetas, key)
+}
+
+// refreshEarly reports whether a read of key should refresh it now: whether
+// loadTime * beta * -ln(rand) reaches past its expiry.
+func (s *ServiceImpl) refreshEarly(key string) bool {
+	if s.loader == nil || len(s.earlyBetas) == 0 {
+		return false
+	}
+	beta := s.earlyBeta(key)
+	delta := time.Duration(s.loadTime.Load())
+	es, ok := s.store.(ports.ExpiryStorage)
+	if beta == 0 || delta == 0 || !ok {
+		return false
+	}
+	ttl, found := es.TTL(key)
+	if !found || ttl == 0 {
+		return false
+	}
+	// 1-Float64 is in (0, 1], so the logarithm is finite.
+	gap := float64(delta) * beta * -math.Log(1-rand.Float64())
+	return gap >= float64(ttl)
+}
+
+// observeLoadTime folds the duration of a successful load into the moving
+// average that refreshEarly uses as the cost of recomputing a value.
+func (s *ServiceImpl) observeLoadTime(d time.Duration) {
+	old := s.loadTime.Load()
+	if old == 0 {
+		s.loadTime.Store(int64(d))
+		return
+	}
+	s.loadTime.Store(old + (int64(d)-old)/8)
+}
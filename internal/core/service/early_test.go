@@ -0,0 +1,76 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	coreerrors "distributed-cache-service/internal/core/errors"
+	"distributed-cache-service/internal/store"
+)
+
+func TestParseEarlyRefresh(t *testing.T) {
+	betas, err := ParseEarlyRefresh("1, session:=0,user:=2.5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]float64{"": 1, "session:": 0, "user:": 2.5}
+	if !reflect.DeepEqual(betas, want) {
+		t.Errorf("got %v, want %v", betas, want)
+	}
+	for _, spec := range []string{"fast", "user:=-1", "a=NaN", "b=Inf"} {
+		if _, err := ParseEarlyRefresh(spec); !errors.Is(err, coreerrors.ErrInvalidArgument) {
+			t.Errorf("expected ErrInvalidArgument for %q, got %v", spec, err)
+		}
+	}
+}
+
+func TestService_EarlyRefresh(t *testing.T) {
+	st := store.New()
+	consensus := &expiringConsensus{store: st}
+	loader := newBlockingLoader()
+	close(loader.release)
+	// A beta this large refreshes on practically every read.
+	svc := New(st, consensus, ConsistencyEventual, WithLoader(loader),
+		WithEarlyRefresh(map[string]float64{"": 1e9, "cold:": 0}))
+	ctx := context.Background()
+
+	st.Set("cold:k", EncodeVersion(3, "v"), time.Minute)
+	st.Set("forever", EncodeVersion(3, "v"), 0)
+	st.Set("k", EncodeVersion(3, "v"), time.Minute)
+
+	// Nothing is refreshed before the node knows how long a load takes.
+	if v, err := svc.Get(ctx, "k"); err != nil || v != "v" {
+		t.Fatalf("expected the cached value, got %q (%v)", v, err)
+	}
+	svc.observeLoadTime(10 * time.Millisecond)
+
+	// Namespaces with beta 0 and keys without expiry are never refreshed early.
+	for _, key := range []string{"cold:k", "forever"} {
+		if v, err := svc.Get(ctx, key); err != nil || v != "v" {
+			t.Fatalf("expected the cached value of %s, got %q (%v)", key, v, err)
+		}
+	}
+	if len(loader.started) != 0 {
+		t.Fatalf("expected no refresh, got %d", len(loader.started))
+	}
+
+	// Otherwise the read returns the cached value and refreshes it behind.
+	if v, err := svc.Get(ctx, "k"); err != nil || v != "v" {
+		t.Fatalf("expected the cached value, got %q (%v)", v, err)
+	}
+	waitOn(t, loader.started, "early refresh to start")
+	deadline := time.Now().Add(2 * time.Second)
+	for len(consensus.applied()) == 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for the refreshed value")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	// The write-back only applies if nothing wrote the key meanwhile.
+	if cmd := consensus.applied()[0]; cmd.Key != "k" || cmd.Value != "loaded-k" || cmd.IfVersion != 3 {
+		t.Errorf("unexpected write-back %+v", cmd)
+	}
+}
@@ -0,0 +1,98 @@
+package service
+
+import (
+	"context"
+	"sync"
+)
+
+// flightGroup coalesces concurrent lookups of the same key, like
+// singleflight.Group, but lets each caller give up independently.
+//
+// The shared lookup runs with its own context, detached from any single caller,
+// so one caller cancelling does not fail the others. It is cancelled once every
+// waiting caller has gone, so abandoned lookups (e.g. a slow loader) stop too.
+//
+// Every flight runs lookup, which is set once, so that reads do not allocate a
+// closure per request; results are returned as versioned rather than boxed.
+type flightGroup struct {
+	lookup  func(ctx context.Context, key string) (versioned, error)
+	mu      sync.Mutex
+	flights map[flightID]*flight
+}
+
+// flightID identifies a lookup. Reads only share a lookup if they ask for the
+// same consistency (see ServiceImpl.flightKey).
+type flightID struct {
+	mode ConsistencyMode
+	key  string
+}
+
+type flight struct {
+	ctx     context.Context
+	cancel  context.CancelFunc
+	waiters int
+
+	// done is closed once val and err are set.
+	done chan struct{}
+	val  versioned
+	err  error
+}
+
+// Do looks id up, or joins a lookup already in flight, and waits for the
+// result or for ctx to be done.
+func (g *flightGroup) Do(ctx context.Context, id flightID) (versioned, error) {
+	if err := ctx.Err(); err != nil {
+		return versioned{}, err
+	}
+
+	g.mu.Lock()
+	if g.flights == nil {
+		g.flights = make(map[flightID]*flight)
+	}
+	f, ok := g.flights[id]
+	if !ok {
+		// Keep request-scoped values (e.g. request IDs) but not the caller's cancellation.
+		fctx, cancel := context.WithCancel(context.WithoutCancel(ctx))
+		f = &flight{ctx: fctx, cancel: cancel, done: make(chan struct{})}
+		g.flights[id] = f
+		// Started under mu so that a run always belongs to a flight with waiters.
+		go g.run(id, f)
+	}
+	f.waiters++
+	g.mu.Unlock()
+
+	defer g.leave(id, f)
+	select {
+	case <-f.done:
+		return f.val, f.err
+	case <-ctx.Done():
+		return versioned{}, ctx.Err()
+	}
+}
+
+// run looks up f and forgets it, so that callers arriving after it finishes
+// start a new lookup rather than reading a result that may be out of date.
+func (g *flightGroup) run(id flightID, f *flight) {
+	f.val, f.err = g.lookup(f.ctx, id.key)
+	g.mu.Lock()
+	if g.flights[id] == f {
+		delete(g.flights, id)
+	}
+	g.mu.Unlock()
+	close(f.done)
+}
+
+// leave drops a waiter; the last one out cancels the shared run and forgets it,
+// so later callers start afresh instead of joining a cancelled lookup.
+func (g *flightGroup) leave(id flightID, f *flight) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	f.waiters--
+	if f.waiters > 0 {
+		return
+	}
+	f.cancel()
+	if g.flights[id] == f {
+		delete(g.flights, id)
+	}
+}
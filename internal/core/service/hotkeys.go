@@ -0,0 +1,100 @@
+package service
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"distributed-cache-service/internal/core/ports"
+)
+
+// hotKeyHalfLife is how often read counts are halved, so that the hot keys
+// reflect recent traffic rather than all reads since the node started.
+const hotKeyHalfLife = time.Minute
+
+// WithHotKeys tracks the most read keys, approximately, in capacity slots;
+// see HotKeys. Tracking costs a lock per read, so it is off by default.
+func WithHotKeys(capacity int) Option {
+	return func(s *ServiceImpl) {
+		if capacity > 0 {
+			s.hotKeys = newHotKeys(capacity, time.Now)
+		}
+	}
+}
+
+// HotKeys returns the n most read keys on this node, most read first, or nil
+// if tracking is off. Counts are approximate and decay over time.
+func (s *ServiceImpl) HotKeys(n int) []ports.KeyCount {
+	if s.hotKeys == nil {
+		return nil
+	}
+	return s.hotKeys.top(n)
+}
+
+// hotKeys counts reads with the Space-Saving algorithm: a key not tracked
+// takes over the slot of the least read one, inheriting its count. Keys read
+// more often than the least tracked one are never missed, and a count
+// overestimates by at most what it inherited.
+type hotKeys struct {
+	mu       sync.Mutex
+	capacity int
+	counts   map[string]uint64
+	decayed  time.Time
+	now      func() time.Time
+}
+
+func newHotKeys(capacity int, now func() time.Time) *hotKeys {
+	return &hotKeys{capacity: capacity, counts: make(map[string]uint64, capacity), decayed: now(), now: now}
+}
+
+func (h *hotKeys) record(key string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.decay()
+	if _, ok := h.counts[key]; ok || len(h.counts) < h.capacity {
+		h.counts[key]++
+		return
+	}
+	minKey, minCount := "", uint64(0)
+	for k, c := range h.counts {
+		if minKey == "" || c < minCount {
+			minKey, minCount = k, c
+		}
+	}
+	delete(h.counts, minKey)
+	h.counts[key] = minCount + 1
+}
+
+// decay halves every count once per hotKeyHalfLife elapsed, dropping keys
+// that reach zero.
+func (h *hotKeys) decay() {
+	for now := h.now(); now.Sub(h.decayed) >= hotKeyHalfLife; h.decayed = h.decayed.Add(hotKeyHalfLife) {
+		for k, c := range h.counts {
+			if c /= 2; c == 0 {
+				delete(h.counts, k)
+			} else {
+				h.counts[k] = c
+			}
+		}
+	}
+}
+
+func (h *hotKeys) top(n int) []ports.KeyCount {
+	h.mu.Lock()
+	h.decay()
+	keys := make([]ports.KeyCount, 0, len(h.counts))
+	for k, c := range h.counts {
+		keys = append(keys, ports.KeyCount{Key: k, Count: c})
+	}
+	h.mu.Unlock()
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].Count != keys[j].Count {
+			return keys[i].Count > keys[j].Count
+		}
+		return keys[i].Key < keys[j].Key
+	})
+	if n >= 0 && n < len(keys) {
+		keys = keys[:n]
+	}
+	return keys
+}
@@ -0,0 +1,58 @@
+package service
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	"distributed-cache-service/internal/core/ports"
+	"distributed-cache-service/internal/store"
+)
+
+func TestHotKeys_SpaceSaving(t *testing.T) {
+	now := time.Unix(0, 0)
+	h := newHotKeys(2, func() time.Time { return now })
+	for i := 0; i < 5; i++ {
+		h.record("a")
+	}
+	h.record("b")
+	h.record("b")
+	// c takes over b's slot, inheriting its count.
+	h.record("c")
+	want := []ports.KeyCount{{Key: "a", Count: 5}, {Key: "c", Count: 3}}
+	if got := h.top(10); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+	if got := h.top(1); !reflect.DeepEqual(got, want[:1]) {
+		t.Errorf("got %v, want %v", got, want[:1])
+	}
+
+	// A hot key that cools down makes room within a few half-lives.
+	now = now.Add(2 * hotKeyHalfLife)
+	want = []ports.KeyCount{{Key: "a", Count: 1}}
+	if got := h.top(10); !reflect.DeepEqual(got, want) {
+		t.Errorf("after decay got %v, want %v", got, want)
+	}
+}
+
+func TestService_HotKeys(t *testing.T) {
+	st := store.New()
+	svc := New(st, &expiringConsensus{store: st}, ConsistencyEventual)
+	if got := svc.HotKeys(10); got != nil {
+		t.Errorf("expected no hot keys with tracking off, got %v", got)
+	}
+
+	svc = New(st, &expiringConsensus{store: st}, ConsistencyEventual, WithHotKeys(8))
+	ctx := context.Background()
+	st.Set("k", EncodeVersion(1, "v"), 0)
+	for i := 0; i < 3; i++ {
+		svc.Get(ctx, "k")
+	}
+	// Misses count too: a hot missing key is worth knowing about.
+	svc.Get(ctx, "missing")
+	want := []ports.KeyCount{{Key: "k", Count: 3}, {Key: "missing", Count: 1}}
+	if got := svc.HotKeys(10); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
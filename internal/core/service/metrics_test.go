@@ -93,3 +93,33 @@ func TestMetrics_Delete(t *testing.T) {
 	newDels := testutil.ToFloat64(ctr)
 	assert.Equal(t, initialDels+1, newDels, "CacheOperationsTotal(delete, success) should increment")
 }
+
+func TestMetrics_Prefixes(t *testing.T) {
+	mockStore := &MockStore{
+		data: map[string]string{"user:1": "value", "user:vip:1": "value", "other": "value"},
+	}
+	svc := New(mockStore, &MockConsensus{}, ConsistencyStrong, WithMetricPrefixes("user:", "user:vip:"))
+	ctx := context.Background()
+
+	hits := func(prefix string) float64 {
+		return testutil.ToFloat64(observability.CachePrefixHitsTotal.WithLabelValues(prefix))
+	}
+	userHits, vipHits := hits("user:"), hits("user:vip:")
+	userMisses := testutil.ToFloat64(observability.CachePrefixMissesTotal.WithLabelValues("user:"))
+
+	for _, key := range []string{"user:1", "user:vip:1", "other"} {
+		_, err := svc.Get(ctx, key)
+		assert.NoError(t, err)
+	}
+	_, err := svc.Get(ctx, "user:2")
+	assert.Error(t, err)
+	assert.NoError(t, svc.Set(ctx, "user:vip:2", "value", 0))
+
+	// Keys count under their longest prefix only.
+	assert.Equal(t, userHits+1, hits("user:"))
+	assert.Equal(t, vipHits+1, hits("user:vip:"))
+	assert.Equal(t, userMisses+1, testutil.ToFloat64(observability.CachePrefixMissesTotal.WithLabelValues("user:")))
+	// Keys without a prefix add no series: gets of both prefixes and a set.
+	assert.Equal(t, 2, testutil.CollectAndCount(observability.CachePrefixHitsTotal))
+	assert.Equal(t, 3, testutil.CollectAndCount(observability.CachePrefixDurationSeconds))
+}
@@ -0,0 +1,46 @@
+package service
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"distributed-cache-service/internal/observability"
+)
+
+// MaxMetricPrefixes bounds the prefixes given to WithMetricPrefixes, and so
+// the cardinality of the per-prefix metrics.
+const MaxMetricPrefixes = 32
+
+// WithMetricPrefixes gives keys starting with each of prefixes their own hit,
+// miss and latency metrics, labelled with the prefix, on top of the cluster-wide
+// ones. A key is counted under the longest prefix it matches; keys matching
+// none are only counted cluster-wide. At most MaxMetricPrefixes are kept.
+func WithMetricPrefixes(prefixes ...string) Option {
+	return func(s *ServiceImpl) {
+		s.metricPrefixes = append([]string(nil), prefixes[:min(len(prefixes), MaxMetricPrefixes)]...)
+		sort.SliceStable(s.metricPrefixes, func(i, j int) bool {
+			return len(s.metricPrefixes[i]) > len(s.metricPrefixes[j])
+		})
+	}
+}
+
+// metricPrefix returns the longest of the metric prefixes that key starts with.
+func (s *ServiceImpl) metricPrefix(key string) (string, bool) {
+	for _, prefix := range s.metricPrefixes {
+		if strings.HasPrefix(key, prefix) {
+			return prefix, true
+		}
+	}
+	return "", false
+}
+
+// observeDuration records the latency of an op on key since start, cluster-wide
+// and, if key has a metric prefix, for that prefix.
+func (s *ServiceImpl) observeDuration(op, key string, start time.Time) {
+	elapsed := time.Since(start).Seconds()
+	observability.CacheDurationSeconds.WithLabelValues(op).Observe(elapsed)
+	if prefix, ok := s.metricPrefix(key); ok {
+		observability.CachePrefixDurationSeconds.WithLabelValues(prefix, op).Observe(elapsed)
+	}
+}
@@ -0,0 +1,283 @@
+package service
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	coreerrors "distributed-cache-service/internal/core/errors"
+	"distributed-cache-service/internal/core/ports"
+	"distributed-cache-service/internal/observability"
+	"distributed-cache-service/internal/ratelimit"
+)
+
+// Quota limits what a namespace, the keys under a prefix, may take up of a
+// shared cluster. Zero fields are unlimited.
+type Quota struct {
+	// MaxKeys is the most keys the namespace may hold.
+	MaxKeys int64
+	// MaxBytes is the most bytes its keys and stored values may take up,
+	// after compression and encryption.
+	MaxBytes int64
+	// MaxWriteRate is the most writes per second the namespace accepts, in
+	// bursts of up to a second's worth.
+	MaxWriteRate float64
+}
+
+// WithQuotas enforces quotas by key prefix. A key belongs to the namespace of
+// the longest prefix it starts with; "" takes every key matching no other, and
+// keys in no namespace are unlimited. Writes that would take a namespace past
+// a quota fail with ErrQuotaExceeded, while writes that do not grow it, such
+// as deletes and same-size overwrites, are always allowed unless over the
+// write rate.
+//
+// The leader checks quotas before replicating a write, against its store's
+// usage, so concurrent writes may overshoot a quota by those in flight. Key
+// and byte quotas need a store that implements ports.UsageStorage and counts
+// the same prefixes. Sorted sets and the keys written by scripts count towards
+// the write rate only.
+func WithQuotas(quotas map[string]Quota) Option {
+	return func(s *ServiceImpl) {
+		s.quotas = quotas
+		s.quotaPrefixes = make([]string, 0, len(quotas))
+		s.quotaLimiters = make(map[string]*ratelimit.Limiter)
+		for prefix, q := range quotas {
+			s.quotaPrefixes = append(s.quotaPrefixes, prefix)
+			if q.MaxWriteRate > 0 {
+				s.quotaLimiters[prefix] = ratelimit.New(q.MaxWriteRate, 0)
+			}
+			observability.CacheQuotaLimit.WithLabelValues(prefix, "keys").Set(float64(q.MaxKeys))
+			observability.CacheQuotaLimit.WithLabelValues(prefix, "bytes").Set(float64(q.MaxBytes))
+			observability.CacheQuotaLimit.WithLabelValues(prefix, "write_rate").Set(q.MaxWriteRate)
+		}
+		sort.Slice(s.quotaPrefixes, func(i, j int) bool {
+			return len(s.quotaPrefixes[i]) > len(s.quotaPrefixes[j])
+		})
+	}
+}
+
+// ParseQuotas builds quotas from comma-separated lists of [prefix=]limit, one
+// for each kind of quota, such as "user:=100000,session:=5000" for maxKeys. A
+// limit without a prefix applies to the "" namespace. Any list may be empty.
+func ParseQuotas(maxKeys, maxBytes, maxWriteRate string) (map[string]Quota, error) {
+	quotas := make(map[string]Quota)
+	err := parsePrefixed(maxKeys, func(prefix, value string) error {
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || n < 0 {
+			return fmt.Errorf("%w: invalid key quota %q", coreerrors.ErrInvalidArgument, value)
+		}
+		q := quotas[prefix]
+		q.MaxKeys = n
+		quotas[prefix] = q
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = parsePrefixed(maxBytes, func(prefix, value string) error {
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || n < 0 {
+			return fmt.Errorf("%w: invalid byte quota %q", coreerrors.ErrInvalidArgument, value)
+		}
+		q := quotas[prefix]
+		q.MaxBytes = n
+		quotas[prefix] = q
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	err = parsePrefixed(maxWriteRate, func(prefix, value string) error {
+		rate, err := strconv.ParseFloat(value, 64)
+		if err != nil || !(rate >= 0) {
+			return fmt.Errorf("%w: invalid write rate quota %q", coreerrors.ErrInvalidArgument, value)
+		}
+		q := quotas[prefix]
+		q.MaxWriteRate = rate
+		quotas[prefix] = q
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return quotas, nil
+}
+
+// parsePrefixed calls fn with the prefix and value of each entry of a
+// comma-separated list of [prefix=]value.
+func parsePrefixed(spec string, fn func(prefix, value string) error) error {
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		prefix, value := "", entry
+		if i := strings.LastIndexByte(entry, '='); i >= 0 {
+			prefix, value = entry[:i], entry[i+1:]
+		}
+		if err := fn(prefix, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// namespace returns the quota namespace of key.
+func (s *ServiceImpl) namespace(key string) (string, bool) {
+	for _, prefix := range s.quotaPrefixes {
+		if strings.HasPrefix(key, prefix) {
+			return prefix, true
+		}
+	}
+	return "", false
+}
+
+// checkQuotas refuses cmd if it would take a namespace past its quotas, and
+// otherwise counts it towards their write rates.
+func (s *ServiceImpl) checkQuotas(cmd *Command) error {
+	if len(s.quotas) == 0 || cmd.Op == PurgeOp || cmd.Op == EvictOp || cmd.Op == ClusterVersionOp {
+		return nil
+	}
+	growth := make(map[string]ports.Usage)
+	switch cmd.Op {
+	case SetOp, GetSetOp, GetOrSetOp, AppendOp:
+		if err := s.addGrowth(growth, cmd); err != nil {
+			return err
+		}
+	case TxnOp:
+		if cmd.Txn == nil {
+			break
+		}
+		// Only one branch runs, so the one growing a namespace most counts.
+		for _, ops := range [][]Command{cmd.Txn.Success, cmd.Txn.Failure} {
+			branch := make(map[string]ports.Usage)
+			for i := range ops {
+				if err := s.addGrowth(branch, &ops[i]); err != nil {
+					return err
+				}
+			}
+			for ns, u := range branch {
+				g := growth[ns]
+				growth[ns] = ports.Usage{Keys: max(g.Keys, u.Keys), Bytes: max(g.Bytes, u.Bytes)}
+			}
+		}
+	}
+	for ns, g := range growth {
+		q := s.quotas[ns]
+		usage, _ := s.store.(ports.UsageStorage).Usage(ns)
+		if q.MaxKeys > 0 && g.Keys > 0 && usage.Keys+g.Keys > q.MaxKeys {
+			return quotaExceeded(ns, "keys", "namespace %q holds %d of its %d keys", ns, usage.Keys, q.MaxKeys)
+		}
+		if q.MaxBytes > 0 && g.Bytes > 0 && usage.Bytes+g.Bytes > q.MaxBytes {
+			return quotaExceeded(ns, "bytes", "namespace %q holds %d of its %d bytes, the write needs %d more", ns, usage.Bytes, q.MaxBytes, g.Bytes)
+		}
+	}
+
+	counted := make(map[string]bool)
+	for _, key := range cmd.touchedKeys(nil) {
+		ns, ok := s.namespace(key)
+		if !ok || counted[ns] {
+			continue
+		}
+		counted[ns] = true
+		if l := s.quotaLimiters[ns]; l != nil && !l.Allow() {
+			return quotaExceeded(ns, "write_rate", "namespace %q is limited to %v writes per second", ns, s.quotas[ns].MaxWriteRate)
+		}
+	}
+	return nil
+}
+
+// addGrowth adds to growth the keys and bytes a string write cmd adds to its
+// namespace, if that has a key or byte quota.
+func (s *ServiceImpl) addGrowth(growth map[string]ports.Usage, cmd *Command) error {
+	if cmd.Op != SetOp && cmd.Op != GetSetOp && cmd.Op != GetOrSetOp && cmd.Op != AppendOp {
+		return nil
+	}
+	ns, ok := s.namespace(cmd.Key)
+	if q := s.quotas[ns]; !ok || (q.MaxKeys == 0 && q.MaxBytes == 0) {
+		return nil
+	}
+	us, ok := s.store.(ports.UsageStorage)
+	if !ok {
+		return fmt.Errorf("key and byte quotas: %w by this storage backend", coreerrors.ErrUnsupported)
+	}
+	value := int64(len(cmd.Value) + len(cmd.Compressed))
+	g := growth[ns]
+	switch old, exists := us.Size(cmd.Key); {
+	case !exists:
+		g.Keys++
+		g.Bytes += int64(len(cmd.Key)+versionedLen) + value
+	case cmd.Op == GetOrSetOp:
+		// The key is kept as it is.
+	case cmd.Op == AppendOp:
+		g.Bytes += value
+	default:
+		g.Bytes += int64(len(cmd.Key)+versionedLen) + value - old
+	}
+	growth[ns] = g
+	return nil
+}
+
+func quotaExceeded(ns, resource, format string, args ...any) error {
+	observability.CacheQuotaRejectionsTotal.WithLabelValues(ns, resource).Inc()
+	return fmt.Errorf("%w: "+format, append([]any{coreerrors.ErrQuotaExceeded}, args...)...)
+}
+
+// quotaResources are what a write can be throttled for, as labelled in
+// cache_quota_rejections_total.
+var quotaResources = []string{"keys", "bytes", "write_rate"}
+
+// NamespaceStats returns the reads, usage and throttled writes of each
+// namespace with a quota on this node, sorted by namespace. Reads and
+// throttled writes count since the node started.
+func (s *ServiceImpl) NamespaceStats() []ports.NamespaceStats {
+	us, _ := s.store.(ports.UsageStorage)
+	stats := make([]ports.NamespaceStats, 0, len(s.quotas))
+	for ns, q := range s.quotas {
+		st := ports.NamespaceStats{
+			Namespace:    ns,
+			Hits:         uint64(observability.CounterValue(observability.CacheNamespaceHitsTotal.WithLabelValues(ns))),
+			Misses:       uint64(observability.CounterValue(observability.CacheNamespaceMissesTotal.WithLabelValues(ns))),
+			MaxKeys:      q.MaxKeys,
+			MaxBytes:     q.MaxBytes,
+			MaxWriteRate: q.MaxWriteRate,
+		}
+		if us != nil {
+			if usage, ok := us.Usage(ns); ok {
+				st.Usage = &usage
+			}
+		}
+		for _, resource := range quotaResources {
+			if n := uint64(observability.CounterValue(observability.CacheQuotaRejectionsTotal.WithLabelValues(ns, resource))); n > 0 {
+				if st.Throttled == nil {
+					st.Throttled = make(map[string]uint64)
+				}
+				st.Throttled[resource] = n
+			}
+		}
+		stats = append(stats, st)
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Namespace < stats[j].Namespace })
+	return stats
+}
+
+// observeQuotaUsage updates the usage metrics of the namespaces of keys.
+func (s *ServiceImpl) observeQuotaUsage(keys []string) {
+	us, ok := s.store.(ports.UsageStorage)
+	if len(s.quotas) == 0 || !ok {
+		return
+	}
+	seen := make(map[string]bool)
+	for _, key := range keys {
+		ns, ok := s.namespace(key)
+		if !ok || seen[ns] {
+			continue
+		}
+		seen[ns] = true
+		if usage, ok := us.Usage(ns); ok {
+			observability.CacheQuotaUsage.WithLabelValues(ns, "keys").Set(float64(usage.Keys))
+			observability.CacheQuotaUsage.WithLabelValues(ns, "bytes").Set(float64(usage.Bytes))
+		}
+	}
+}
@@ -0,0 +1,158 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	coreerrors "distributed-cache-service/internal/core/errors"
+	"distributed-cache-service/internal/core/ports"
+	"distributed-cache-service/internal/store"
+)
+
+func TestParseQuotas(t *testing.T) {
+	quotas, err := ParseQuotas("100, user:=10", "user:=2048", "user:=5.5,session:=100")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]Quota{
+		"":         {MaxKeys: 100},
+		"user:":    {MaxKeys: 10, MaxBytes: 2048, MaxWriteRate: 5.5},
+		"session:": {MaxWriteRate: 100},
+	}
+	if !reflect.DeepEqual(quotas, want) {
+		t.Errorf("got %v, want %v", quotas, want)
+	}
+	for _, spec := range [][3]string{{"many", "", ""}, {"", "user:=-1", ""}, {"", "1KiB", ""}, {"", "", "a=NaN"}} {
+		if _, err := ParseQuotas(spec[0], spec[1], spec[2]); !errors.Is(err, coreerrors.ErrInvalidArgument) {
+			t.Errorf("expected ErrInvalidArgument for %q, got %v", spec, err)
+		}
+	}
+}
+
+func TestService_Quotas(t *testing.T) {
+	st := store.New(store.WithUsagePrefixes("user:", "user:admin:"))
+	svc := New(st, &expiringConsensus{store: st}, ConsistencyEventual, WithQuotas(map[string]Quota{
+		"user:":       {MaxKeys: 2, MaxBytes: 100},
+		"user:admin:": {},
+	}))
+	ctx := context.Background()
+
+	for _, key := range []string{"user:1", "user:2"} {
+		if err := svc.Set(ctx, key, "v", 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	err := svc.Set(ctx, "user:3", "v", 0)
+	if !errors.Is(err, coreerrors.ErrQuotaExceeded) || !strings.Contains(err.Error(), "2 of its 2 keys") {
+		t.Fatalf("expected the key quota to be exceeded, got %v", err)
+	}
+	// Overwrites do not add keys, and other namespaces are not affected.
+	if err := svc.Set(ctx, "user:1", "w", 0); err != nil {
+		t.Errorf("expected an overwrite to be allowed, got %v", err)
+	}
+	if err := svc.Set(ctx, "user:admin:1", "v", 0); err != nil {
+		t.Errorf("expected a nested namespace without quota to be unlimited, got %v", err)
+	}
+	if err := svc.Set(ctx, "other", "v", 0); err != nil {
+		t.Errorf("expected keys outside namespaces to be unlimited, got %v", err)
+	}
+	// GetOrSet of an existing key writes nothing.
+	if _, _, err := svc.GetOrSet(ctx, "user:1", strings.Repeat("x", 200), 0); err != nil {
+		t.Errorf("expected GetOrSet of an existing key to be allowed, got %v", err)
+	}
+
+	// Deleting frees room.
+	st.Delete("user:2")
+	if err := svc.Set(ctx, "user:2", "v", 0); err != nil {
+		t.Errorf("expected room after a delete, got %v", err)
+	}
+
+	// Growing a value past the byte quota fails; shrinking it does not.
+	if err := svc.Set(ctx, "user:1", strings.Repeat("x", 100), 0); !errors.Is(err, coreerrors.ErrQuotaExceeded) {
+		t.Errorf("expected the byte quota to be exceeded, got %v", err)
+	}
+	if _, err := svc.Append(ctx, "user:1", strings.Repeat("x", 100)); !errors.Is(err, coreerrors.ErrQuotaExceeded) {
+		t.Errorf("expected appends to count towards the byte quota, got %v", err)
+	}
+	if err := svc.Set(ctx, "user:1", "", 0); err != nil {
+		t.Errorf("expected a shrinking write to be allowed, got %v", err)
+	}
+
+	// A transaction counts the branch that grows a namespace most.
+	txn := ports.Txn{
+		Success: []ports.TxnOp{{Type: ports.TxnSet, Key: "user:1", Value: "v"}},
+		Failure: []ports.TxnOp{{Type: ports.TxnSet, Key: "user:9", Value: "v"}},
+	}
+	if _, err := svc.Txn(ctx, txn); !errors.Is(err, coreerrors.ErrQuotaExceeded) {
+		t.Errorf("expected the transaction to exceed the key quota, got %v", err)
+	}
+}
+
+func TestService_QuotaWriteRate(t *testing.T) {
+	consensus := &resultConsensus{}
+	svc := New(&MockStore{data: map[string]string{}}, consensus, ConsistencyEventual, WithQuotas(map[string]Quota{
+		"user:": {MaxWriteRate: 0.001},
+		"bulk:": {MaxKeys: 10},
+	}))
+	ctx := context.Background()
+
+	// The bucket holds one write; deletes count too.
+	if err := svc.Set(ctx, "user:1", "v", 0); err != nil {
+		t.Fatal(err)
+	}
+	err := svc.Delete(ctx, "user:1")
+	if !errors.Is(err, coreerrors.ErrQuotaExceeded) || !strings.Contains(err.Error(), "0.001 writes per second") {
+		t.Errorf("expected the write rate to be exceeded, got %v", err)
+	}
+	if err := svc.Set(ctx, "k", "v", 0); err != nil {
+		t.Errorf("expected keys outside namespaces to be unlimited, got %v", err)
+	}
+
+	// Key and byte quotas need a store that counts usage.
+	if err := svc.Set(ctx, "bulk:1", "v", 0); !errors.Is(err, coreerrors.ErrUnsupported) {
+		t.Errorf("expected ErrUnsupported, got %v", err)
+	}
+}
+
+func TestService_NamespaceStats(t *testing.T) {
+	// The counters are process-wide, so these namespaces are used by no other test.
+	st := store.New(store.WithUsagePrefixes("tenant:", "tenant:slow:"))
+	svc := New(st, &expiringConsensus{store: st}, ConsistencyEventual, WithQuotas(map[string]Quota{
+		"tenant:":      {MaxKeys: 1, MaxBytes: 1000},
+		"tenant:slow:": {MaxWriteRate: 0.001},
+	}))
+	ctx := context.Background()
+
+	if err := svc.Set(ctx, "tenant:1", "v", 0); err != nil {
+		t.Fatal(err)
+	}
+	if err := svc.Set(ctx, "tenant:2", "v", 0); !errors.Is(err, coreerrors.ErrQuotaExceeded) {
+		t.Fatalf("expected the key quota to be exceeded, got %v", err)
+	}
+	svc.Set(ctx, "tenant:slow:1", "v", 0)
+	svc.Set(ctx, "tenant:slow:2", "v", 0)
+	for _, key := range []string{"tenant:1", "tenant:1", "tenant:missing", "tenant:slow:1"} {
+		svc.Get(ctx, key)
+	}
+
+	stats := svc.NamespaceStats()
+	if len(stats) != 2 || stats[0].Namespace != "tenant:" || stats[1].Namespace != "tenant:slow:" {
+		t.Fatalf("expected both namespaces in order, got %+v", stats)
+	}
+	tenant := stats[0]
+	if tenant.Hits != 2 || tenant.Misses != 1 {
+		t.Errorf("expected 2 hits and 1 miss, got %d and %d", tenant.Hits, tenant.Misses)
+	}
+	if tenant.Usage == nil || tenant.Usage.Keys != 1 || tenant.MaxKeys != 1 || tenant.MaxBytes != 1000 {
+		t.Errorf("unexpected usage and quotas %+v", tenant)
+	}
+	if tenant.Throttled["keys"] != 1 {
+		t.Errorf("expected one write throttled for keys, got %v", tenant.Throttled)
+	}
+	if slow := stats[1]; slow.Hits != 1 || slow.Throttled["write_rate"] != 1 || slow.MaxWriteRate != 0.001 {
+		t.Errorf("unexpected stats %+v", slow)
+	}
+}
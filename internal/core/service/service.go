@@ -2,15 +2,28 @@ package service
 
 import (
 	"context"
+	"distributed-cache-service/internal/compression"
+	coreerrors "distributed-cache-service/internal/core/errors"
 	"distributed-cache-service/internal/core/ports"
+	"distributed-cache-service/internal/encryption"
 	"distributed-cache-service/internal/observability"
-	"encoding/json"
+	"distributed-cache-service/internal/ratelimit"
+	"distributed-cache-service/internal/script"
+	"errors"
 	"fmt"
-	"time"
-
 	"golang.org/x/sync/singleflight"
+	"log"
+	"math"
+	"math/rand/v2"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
+// MaxKeyLength is the maximum allowed key length in bytes.
+const MaxKeyLength = 1024
+
 // ensure implementation
 var _ ports.CacheService = (*ServiceImpl)(nil)
 
@@ -18,19 +31,120 @@ var _ ports.CacheService = (*ServiceImpl)(nil)
 // It orchestrates interactions between the storage (Read) and consensus (Write) layers.
 // It manages data consistency and request concurrency.
 type ServiceImpl struct {
-	store        ports.Storage
-	consensus    ports.Consensus
-	requestGroup singleflight.Group
-	consistency  ConsistencyMode
+	store          ports.Storage
+	consensus      ports.Consensus
+	requestGroup   flightGroup
+	consistency    ConsistencyMode
+	config         Config
+	compressor     *compression.Compressor
+	cipher         *encryption.Cipher
+	loader         ports.Loader
+	staleGrace     time.Duration
+	earlyBetas     map[string]float64
+	ttlJitter      float64
+	ttlLimits      TTLLimits
+	quotas         map[string]Quota
+	quotaPrefixes  []string // longest first
+	quotaLimiters  map[string]*ratelimit.Limiter
+	metricPrefixes []string
+	hotKeys        *hotKeys
+	loadTime       atomic.Int64 // moving average of loader latency, in ns
+	refreshGroup   singleflight.Group
+	maxLag         uint64
+	witness        bool
+	breaker        *breaker
+	batcher        *batcher
+
+	tombstoneRetention time.Duration
+
+	purgeMu   sync.Mutex
+	stopPurge chan struct{}
+	evicting  atomic.Bool
+}
+
+// Option configures optional service behaviour.
+type Option func(*ServiceImpl)
+
+// WithCompression compresses large values before they are replicated and stored,
+// which shrinks both the Raft log and the store. Values are decompressed on read.
+func WithCompression(c *compression.Compressor) Option {
+	return func(s *ServiceImpl) {
+		s.compressor = c
+	}
+}
+
+// WithEncryption seals values with c before they are replicated, so that the
+// Raft log, snapshots and the store hold only ciphertext. Every node needs the
+// keys, since the FSM also reads and writes values.
+func WithEncryption(c *encryption.Cipher) Option {
+	return func(s *ServiceImpl) {
+		s.cipher = c
+	}
+}
+
+// WithLoader turns the service into a read-through cache: on a miss, l is
+// called (once per key, however many requests are waiting) and the result is
+// stored before being returned.
+func WithLoader(l ports.Loader) Option {
+	return func(s *ServiceImpl) {
+		s.loader = l
+	}
+}
+
+// WithStaleWhileRevalidate keeps serving a value for up to grace after it
+// expires, while the loader refreshes it in the background (once per key, however
+// many reads see it stale). The leader postpones purging expired keys by grace
+// to keep them available. It requires WithLoader and a store that implements
+// ports.ExpiryStorage.
+func WithStaleWhileRevalidate(grace time.Duration) Option {
+	return func(s *ServiceImpl) {
+		s.staleGrace = grace
+	}
+}
+
+// WithTTLJitter spreads expirations by moving each TTL written by up to
+// ±fraction of itself at random (0.1 for ±10%), so that keys written together
+// with the same TTL do not all expire together. The leader draws the jitter
+// when it stamps the expiration, so every replica agrees on it. fraction must
+// be in [0, 1).
+func WithTTLJitter(fraction float64) Option {
+	return func(s *ServiceImpl) {
+		s.ttlJitter = fraction
+	}
+}
+
+// WithMaxLag bounds the staleness of eventually consistent reads: a node more
+// than maxLag committed entries behind, or one that cannot tell because it has
+// no leader, refuses reads with ErrStaleRead. 0 means unbounded. It requires a
+// Consensus that implements ports.LagReporter and has no effect on strong reads,
+// which are always current.
+func WithMaxLag(maxLag uint64) Option {
+	return func(s *ServiceImpl) {
+		s.maxLag = maxLag
+	}
+}
+
+// WithWitness serves a witness node, whose FSM keeps no data: every read and
+// write fails with ErrWitness, so clients retry on a node that has the data.
+func WithWitness() Option {
+	return func(s *ServiceImpl) {
+		s.witness = true
+	}
 }
 
 // New creates a new instance of the cache service.
-func New(store ports.Storage, consensus ports.Consensus, consistency ConsistencyMode) *ServiceImpl {
-	return &ServiceImpl{
+func New(store ports.Storage, consensus ports.Consensus, consistency ConsistencyMode, opts ...Option) *ServiceImpl {
+	s := &ServiceImpl{
 		store:       store,
 		consensus:   consensus,
 		consistency: consistency,
+		config:      DefaultConfig,
 	}
+	s.requestGroup.lookup = s.lookup
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
 }
 
 // Command definitions shared with Raft FSM
@@ -39,8 +153,58 @@ type CommandType string
 const (
 	SetOp    CommandType = "SET"
 	DeleteOp CommandType = "DELETE"
+	// GetSetOp sets a key and returns its previous value in ApplyResult.
+	GetSetOp CommandType = "GETSET"
+	// GetDelOp deletes a key and returns its previous value in ApplyResult.
+	GetDelOp CommandType = "GETDEL"
+	// GetOrSetOp sets a key that does not exist. If it does, its value is
+	// returned in ApplyResult and nothing is written.
+	GetOrSetOp CommandType = "GETORSET"
+	// AppendOp appends Value to a key's value, creating the key if needed, and
+	// returns the new length in ApplyResult.
+	AppendOp CommandType = "APPEND"
+	// ZAddOp adds Members to the sorted set at Key and returns how many were new
+	// in ApplyResult.
+	ZAddOp CommandType = "ZADD"
+	// ZRemRangeByScoreOp removes members scored within [Min, Max] from the sorted
+	// set at Key and returns how many were removed in ApplyResult.
+	ZRemRangeByScoreOp CommandType = "ZREMRANGEBYSCORE"
+	// EvalOp runs Script against Keys with Args (see package script) and returns
+	// its result in ApplyResult. Its writes are applied only if it succeeds.
+	EvalOp CommandType = "EVAL"
+	// TxnOp applies a compare-then-ops transaction (see ports.Txn) and returns
+	// its outcome in ApplyResult.
+	TxnOp CommandType = "TXN"
+	// GetOp reads a key. It only appears as an op of a TXN.
+	GetOp CommandType = "GET"
+	// ExpireOp makes an existing key expire after TTL, keeping its value and version.
+	ExpireOp CommandType = "EXPIRE"
+	// PersistOp removes an existing key's expiration, keeping its value and version.
+	PersistOp CommandType = "PERSIST"
+	// PurgeOp deletes those of Keys that had expired by ExpiresAt and returns
+	// how many it removed in ApplyResult.
+	PurgeOp CommandType = "PURGE"
+	// EvictOp deletes Keys chosen by the leader's eviction policy and returns
+	// how many it removed in ApplyResult.
+	EvictOp CommandType = "EVICT"
+	// ClusterVersionOp raises the cluster version to Version. Key is unused.
+	ClusterVersionOp CommandType = "CLUSTERVERSION"
+	// BatchOp applies each of Batch as though it were an entry of its own and
+	// returns their responses in a BatchResult. Key is unused.
+	BatchOp CommandType = "BATCH"
+	// TombstoneGCOp drops the tombstones of deletes made before OriginTime and
+	// returns how many it dropped in ApplyResult. Key is unused.
+	TombstoneGCOp CommandType = "TOMBSTONEGC"
+	// MemberOp records Value as the gRPC address of the member whose ID is Key.
+	MemberOp CommandType = "MEMBER"
 )
 
+// MaxTxnOps bounds the comparisons and the ops of each branch of a transaction.
+const MaxTxnOps = 128
+
+// PurgeBatchSize bounds the keys of each PURGE and EVICT command.
+const PurgeBatchSize = 1000
+
 // ConsistencyMode defines the consistency level for read operations.
 type ConsistencyMode string
 
@@ -49,12 +213,238 @@ const (
 	ConsistencyEventual ConsistencyMode = "eventual"
 )
 
+// ParseConsistencyMode parses a consistency mode name, case-insensitively.
+func ParseConsistencyMode(name string) (ConsistencyMode, error) {
+	switch mode := ConsistencyMode(strings.ToLower(name)); mode {
+	case ConsistencyStrong, ConsistencyEventual:
+		return mode, nil
+	}
+	return "", fmt.Errorf("%w: unknown consistency mode %q", coreerrors.ErrInvalidArgument, name)
+}
+
 // Command represents a state machine command to be replicated via Raft.
 type Command struct {
 	Op    CommandType   `json:"op"`
 	Key   string        `json:"key"`
 	Value string        `json:"value,omitempty"`
 	TTL   time.Duration `json:"ttl,omitempty"`
+	// ExpiresAt is when a write with a TTL expires, in Unix nanoseconds. The
+	// leader stamps it when submitting the command so that every replica, and
+	// every replay of the log, expires the key at the same moment. TTL is kept
+	// for write-behind sinks and for entries written before ExpiresAt existed.
+	// For PURGE it is the leader's clock when it found Keys expired.
+	ExpiresAt int64 `json:"expires_at,omitempty"`
+	// Compressed carries a compressed or encrypted value in place of Value,
+	// since JSON strings cannot hold binary data losslessly. For APPEND it is
+	// the encrypted suffix.
+	Compressed []byte `json:"compressed,omitempty"`
+	// RequestID identifies a client write across retries; the FSM applies each
+	// ID at most once within its dedup window.
+	RequestID string `json:"request_id,omitempty"`
+	// IfVersion and IfAbsent are the write's precondition (see ports.Precondition),
+	// evaluated by the FSM against the key's version at apply time.
+	IfVersion uint64 `json:"if_version,omitempty"`
+	IfAbsent  bool   `json:"if_absent,omitempty"`
+	// Members, Min and Max are the arguments of sorted set commands. JSON has no
+	// infinities, so open-ended ranges are clamped to ±math.MaxFloat64.
+	Members []ports.ScoredMember `json:"members,omitempty"`
+	Min     float64              `json:"min,omitempty"`
+	Max     float64              `json:"max,omitempty"`
+	// Script, Keys and Args are the arguments of EVAL, and Keys those of PURGE
+	// and EVICT.
+	// Key is unused.
+	Script string   `json:"script,omitempty"`
+	Keys   []string `json:"keys,omitempty"`
+	Args   []string `json:"args,omitempty"`
+	// Txn holds the arguments of TXN.
+	Txn *TxnCommand `json:"txn,omitempty"`
+	// Batch holds the commands of BATCH.
+	Batch []Command `json:"batch,omitempty"`
+	// Version is the command's schema version (see MaxCommandVersion), stamped
+	// by the leader. For CLUSTERVERSION it is the version the cluster moves to.
+	Version uint32 `json:"version,omitempty"`
+	// OriginTime is when a write was first made, in Unix nanoseconds, for
+	// writes copied from another cluster or replayed from elsewhere (see
+	// ContextWithOriginTime). A DELETE or GETDEL with an OriginTime leaves a
+	// tombstone, and a SET, GETSET or GETORSET is refused if the key was
+	// deleted at or after its OriginTime. For TOMBSTONEGC it is the cutoff.
+	OriginTime int64 `json:"origin_time,omitempty"`
+}
+
+// TxnCommand is the replicated form of a ports.Txn. Its ops are GET, SET and
+// DELETE commands, with SET values encoded like those of a plain SET.
+type TxnCommand struct {
+	Compares []ports.Compare `json:"compares,omitempty"`
+	Success  []Command       `json:"success,omitempty"`
+	Failure  []Command       `json:"failure,omitempty"`
+}
+
+// ApplyResult is the FSM's response to a successfully applied command.
+type ApplyResult struct {
+	// Version is the key's new version (0 for deletes).
+	Version uint64
+	// Previous and Found report the key's stored value before a GETSET,
+	// GETDEL or GETORSET, as read by the FSM in the same step as the write.
+	Previous string
+	Found    bool
+	// Length is the value's length in bytes after an APPEND.
+	Length int
+	// Count is the number of members added by a ZADD or removed by a
+	// ZREMRANGEBYSCORE, of keys removed by a PURGE or EVICT, or of
+	// tombstones dropped by a TOMBSTONEGC.
+	Count int
+	// Reply is the value returned by an EVAL script, as converted by script.Run.
+	Reply interface{}
+	// Txn is the outcome of a TXN. Values read by its gets are as stored.
+	Txn ports.TxnResult
+}
+
+// BatchResult is the FSM's response to a BATCH: for each of its commands, in
+// order, an ApplyResult or the error it was rejected with.
+type BatchResult []interface{}
+
+type requestIDKey struct{}
+
+// ContextWithRequestID attaches a client-supplied request ID to ctx. Set and
+// Delete carry it in the replicated command so retries are applied once.
+func ContextWithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestIDFromContext returns the request ID attached to ctx, if any.
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// AckLevel is how far a write must get before it is acknowledged.
+type AckLevel string
+
+const (
+	// AckQuorum acknowledges a write once a quorum has committed it and this
+	// node has applied it.
+	AckQuorum AckLevel = "quorum"
+	// AckLeader acknowledges a plain set or delete as soon as the leader has
+	// queued it for replication. The write is lost if the leader fails before
+	// it commits, and reads, even strong ones, may not see it yet.
+	AckLeader AckLevel = "leader"
+)
+
+// ParseAckLevel parses an ack level name, case-insensitively.
+func ParseAckLevel(name string) (AckLevel, error) {
+	switch ack := AckLevel(strings.ToLower(name)); ack {
+	case AckQuorum, AckLeader:
+		return ack, nil
+	}
+	return "", fmt.Errorf("%w: unknown ack level %q", coreerrors.ErrInvalidArgument, name)
+}
+
+type ackKey struct{}
+
+// ContextWithAck sets the ack level of the writes made with ctx. Only Set and
+// Delete without a precondition can be acknowledged by the leader alone;
+// other writes return results known only once they commit, and always wait
+// for a quorum.
+func ContextWithAck(ctx context.Context, ack AckLevel) context.Context {
+	return context.WithValue(ctx, ackKey{}, ack)
+}
+
+// AckFromContext returns the ack level attached to ctx, if any.
+func AckFromContext(ctx context.Context) (AckLevel, bool) {
+	ack, ok := ctx.Value(ackKey{}).(AckLevel)
+	return ack, ok
+}
+
+// ackFor returns the ack level for cmd written with ctx (see ContextWithAck).
+func ackFor(ctx context.Context, cmd *Command) (AckLevel, error) {
+	if ack, _ := AckFromContext(ctx); ack != AckLeader || (cmd.Op != SetOp && cmd.Op != DeleteOp) {
+		return AckQuorum, nil
+	}
+	if cmd.IfVersion != 0 || cmd.IfAbsent {
+		return "", fmt.Errorf("%w: a write acknowledged by the leader alone cannot report whether its precondition held", coreerrors.ErrInvalidArgument)
+	}
+	return AckLeader, nil
+}
+
+type consistencyKey struct{}
+
+// ContextWithConsistency overrides the service's consistency mode for the
+// reads made with ctx.
+func ContextWithConsistency(ctx context.Context, mode ConsistencyMode) context.Context {
+	return context.WithValue(ctx, consistencyKey{}, mode)
+}
+
+// ConsistencyFromContext returns the consistency mode attached to ctx, if any.
+func ConsistencyFromContext(ctx context.Context) (ConsistencyMode, bool) {
+	mode, ok := ctx.Value(consistencyKey{}).(ConsistencyMode)
+	return mode, ok
+}
+
+// consistencyFor returns the consistency mode for a read made with ctx.
+func (s *ServiceImpl) consistencyFor(ctx context.Context) ConsistencyMode {
+	if mode, ok := ConsistencyFromContext(ctx); ok {
+		return mode
+	}
+	return s.consistency
+}
+
+// touchedKeys appends the keys cmd reads or writes to dst, so that callers
+// can pass a buffer on the stack for the common single-key command.
+func (c *Command) touchedKeys(dst []string) []string {
+	switch {
+	case c.Op == EvalOp || c.Op == PurgeOp || c.Op == EvictOp || c.Op == ClusterVersionOp || c.Op == TombstoneGCOp:
+		return append(dst, c.Keys...)
+	case c.Op == TxnOp && c.Txn != nil:
+		for _, cmp := range c.Txn.Compares {
+			dst = append(dst, cmp.Key)
+		}
+		for _, ops := range [][]Command{c.Txn.Success, c.Txn.Failure} {
+			for _, op := range ops {
+				dst = append(dst, op.Key)
+			}
+		}
+		return dst
+	}
+	return append(dst, c.Key)
+}
+
+// stampExpiry sets ExpiresAt from TTL, moved by up to ±jitter of itself (see
+// WithTTLJitter), for cmd and the ops of a transaction.
+func (c *Command) stampExpiry(now time.Time, jitter float64) {
+	if c.TTL > 0 {
+		ttl := c.TTL
+		if jitter > 0 {
+			ttl = max(time.Duration(float64(ttl)*(1+jitter*(2*rand.Float64()-1))), 1)
+		}
+		c.ExpiresAt = now.Add(ttl).UnixNano()
+	}
+	if c.Txn != nil {
+		for _, ops := range [][]Command{c.Txn.Success, c.Txn.Failure} {
+			for i := range ops {
+				ops[i].stampExpiry(now, jitter)
+			}
+		}
+	}
+}
+
+// Expiry returns when a key written by c expires, or the zero time if it does
+// not. Commands without ExpiresAt fall back to their TTL from now.
+func (c *Command) Expiry() time.Time {
+	switch {
+	case c.ExpiresAt != 0:
+		return time.Unix(0, c.ExpiresAt)
+	case c.TTL > 0:
+		return time.Now().Add(c.TTL)
+	}
+	return time.Time{}
+}
+
+// StoredValue returns the value to write to the store for a SET command.
+func (c *Command) StoredValue() string {
+	if c.Compressed != nil {
+		return string(c.Compressed)
+	}
+	return c.Value
 }
 
 // Get retrieves a value from the local store.
@@ -66,93 +456,867 @@ type Command struct {
 // Concurrency:
 // - Uses SingleFlight to prevent cache stampedes (Thundering Herd).
 // - Multiple concurrent requests for the same key are coalesced into a single lookup.
+// - A caller whose ctx is done returns ctx.Err() at once; the shared lookup carries on
+// for the other callers and is cancelled once none are left.
+//
+// If a Loader is configured, misses are loaded from it and written back (see WithLoader).
 func (s *ServiceImpl) Get(ctx context.Context, key string) (string, error) {
+	val, _, err := s.GetVersioned(ctx, key)
+	return val, err
+}
+
+// versioned is a value together with its version.
+type versioned struct {
+	value   string
+	version uint64
+}
+
+// GetVersioned is like Get but also returns the key's version.
+// A value loaded on a follower, which cannot write it back, has version 0.
+func (s *ServiceImpl) GetVersioned(ctx context.Context, key string) (string, uint64, error) {
 	start := time.Now()
 
-	// Ensure Strong Consistency: Check if we are still the leader
-	if s.consistency == ConsistencyStrong {
-		if err := s.consensus.VerifyLeader(); err != nil {
-			observability.CacheOperationsTotal.WithLabelValues("get", "error").Inc()
-			return "", fmt.Errorf("consistency check failed: %w", err)
-		}
+	if err := validateKey(key); err != nil {
+		observability.CacheOperationsTotal.WithLabelValues("get", "error").Inc()
+		return "", 0, err
+	}
+
+	ctx, cancel := withTimeout(ctx, s.config.ReadTimeout)
+	defer cancel()
+	if err := s.checkConsistency(ctx); err != nil {
+		observability.CacheOperationsTotal.WithLabelValues("get", "error").Inc()
+		return "", 0, err
+	}
+	if s.hotKeys != nil {
+		s.hotKeys.record(key)
+	}
+
+	// Coalesce concurrent requests for the same key (see flightGroup)
+	r, err := s.requestGroup.Do(ctx, s.flightKey(ctx, key))
+	s.observeDuration("get", key, start)
+
+	if err != nil {
+		return "", 0, err
 	}
+	return r.value, r.version, nil
+}
 
-	// Use SingleFlight to coalesce concurrent requests for the same key
-	v, err, _ := s.requestGroup.Do(key, func() (interface{}, error) {
-		val, found := s.store.Get(key)
-		if !found {
-			observability.CacheMissesTotal.Inc()
-			observability.CacheOperationsTotal.WithLabelValues("get", "miss").Inc()
-			return "", fmt.Errorf("key not found")
+// lookup reads key from the store for the reads sharing a flight (see
+// flightGroup), falling back to a stale value or the loader on a miss.
+func (s *ServiceImpl) lookup(ctx context.Context, key string) (versioned, error) {
+	raw, found := s.store.Get(key)
+	if !found {
+		if stale, ok := s.getStale(key); ok {
+			observability.CacheOperationsTotal.WithLabelValues("get", "stale").Inc()
+			s.refresh(ctx, key, ports.Precondition{IfAbsent: true})
+			// Version 0, like a missing key: the FSM no longer sees it.
+			_, stored := DecodeVersion(stale)
+			val, err := s.cipher.Decode(stored)
+			return versioned{value: val}, err
+		}
+		observability.CacheMissesTotal.Inc()
+		observability.CacheOperationsTotal.WithLabelValues("get", "miss").Inc()
+		if prefix, ok := s.metricPrefix(key); ok {
+			observability.CachePrefixMissesTotal.WithLabelValues(prefix).Inc()
 		}
-		observability.CacheHitsTotal.Inc()
-		observability.CacheOperationsTotal.WithLabelValues("get", "hit").Inc()
-		return val, nil
+		if ns, ok := s.namespace(key); ok {
+			observability.CacheNamespaceMissesTotal.WithLabelValues(ns).Inc()
+		}
+		if s.loader != nil {
+			return s.load(ctx, key, ports.Precondition{IfAbsent: true})
+		}
+		return versioned{}, coreerrors.ErrNotFound
+	}
+	observability.CacheHitsTotal.Inc()
+	observability.CacheOperationsTotal.WithLabelValues("get", "hit").Inc()
+	if prefix, ok := s.metricPrefix(key); ok {
+		observability.CachePrefixHitsTotal.WithLabelValues(prefix).Inc()
+	}
+	if ns, ok := s.namespace(key); ok {
+		observability.CacheNamespaceHitsTotal.WithLabelValues(ns).Inc()
+	}
+	version, stored := DecodeVersion(raw)
+	if version != 0 && s.refreshEarly(key) {
+		observability.CacheEarlyRefreshesTotal.Inc()
+		s.refresh(ctx, key, ports.Precondition{IfVersion: version})
+	}
+	val, err := s.cipher.Decode(stored)
+	return versioned{value: val, version: version}, err
+}
+
+// getStale returns the stored value of key if it expired within the stale grace
+// window.
+func (s *ServiceImpl) getStale(key string) (string, bool) {
+	es, ok := s.store.(ports.ExpiryStorage)
+	if !ok || s.staleGrace <= 0 || s.loader == nil {
+		return "", false
+	}
+	raw, expiresAt, found := es.GetStale(key)
+	if !found || expiresAt.IsZero() || time.Since(expiresAt) > s.staleGrace {
+		return "", false
+	}
+	return raw, true
+}
+
+// refresh reloads key in the background, writing it back if cond holds, unless
+// a refresh of it is already running. Like a load on a miss, only the leader can write the result back;
+// until it does, followers keep serving the stale value.
+func (s *ServiceImpl) refresh(ctx context.Context, key string, cond ports.Precondition) {
+	// Keep request-scoped values but outlive the read that noticed the expiry.
+	ctx = context.WithoutCancel(ctx)
+	s.refreshGroup.DoChan(key, func() (interface{}, error) {
+		ctx, cancel := context.WithTimeout(ctx, s.config.BackgroundTimeout)
+		defer cancel()
+		return s.load(ctx, key, cond)
 	})
-	observability.CacheDurationSeconds.WithLabelValues("get").Observe(time.Since(start).Seconds())
+}
+
+// load fetches key from the loader and writes it back through Raft.
+// Write-back is best effort: followers cannot apply, so they still return the
+// loaded value and leave caching it to the leader. It only applies if cond
+// holds (the key is still absent, or still at the version being refreshed), so
+// a write that raced the load is not overwritten.
+func (s *ServiceImpl) load(ctx context.Context, key string, cond ports.Precondition) (versioned, error) {
+	start := time.Now()
+	defer func() {
+		observability.CacheDurationSeconds.WithLabelValues("load").Observe(time.Since(start).Seconds())
+	}()
 
+	val, ttl, err := s.loader.Load(ctx, key)
 	if err != nil {
-		return "", err
+		if errors.Is(err, coreerrors.ErrNotFound) {
+			observability.CacheLoadsTotal.WithLabelValues("not_found").Inc()
+			return versioned{}, coreerrors.ErrNotFound
+		}
+		observability.CacheLoadsTotal.WithLabelValues("error").Inc()
+		return versioned{}, fmt.Errorf("load %q: %w", key, err)
 	}
+	s.observeLoadTime(time.Since(start))
 
-	return v.(string), nil
+	// The write-back is not the caller's write, so it must not consume their request ID.
+	version, err := s.SetIf(ContextWithRequestID(ctx, ""), key, val, ttl, cond)
+	if err != nil {
+		observability.CacheLoadsTotal.WithLabelValues("store_failed").Inc()
+		return versioned{value: val}, nil
+	}
+	observability.CacheLoadsTotal.WithLabelValues("loaded").Inc()
+	return versioned{value: val, version: version}, nil
 }
 
 // Set stores a value in the system (Strongly Consistent via Raft).
+// A ctx that is already done fails fast without submitting the write; otherwise
+// ctx bounds the wait for the write to be confirmed.
 func (s *ServiceImpl) Set(ctx context.Context, key, value string, ttl time.Duration) error {
+	_, err := s.SetIf(ctx, key, value, ttl, ports.Precondition{})
+	return err
+}
+
+// SetIf is like Set but only applies if cond holds when the FSM applies it.
+// It returns the key's new version, or 0 if the write was a retry of one that
+// had already been applied (see ContextWithRequestID).
+func (s *ServiceImpl) SetIf(ctx context.Context, key, value string, ttl time.Duration, cond ports.Precondition) (uint64, error) {
+	cmd := Command{
+		Op:        SetOp,
+		Key:       key,
+		TTL:       ttl,
+		IfVersion: cond.IfVersion,
+		IfAbsent:  cond.IfAbsent,
+	}
+	s.encodeValue(&cmd, value)
+	result, err := s.replicate(ctx, "set", cmd)
+	return result.Version, err
+}
+
+// Delete removes a value from the system (Strongly Consistent via Raft).
+// Cancellation behaves as for Set.
+func (s *ServiceImpl) Delete(ctx context.Context, key string) error {
+	return s.DeleteIf(ctx, key, ports.Precondition{})
+}
+
+// DeleteIf is like Delete but only applies if cond holds when the FSM applies it.
+func (s *ServiceImpl) DeleteIf(ctx context.Context, key string, cond ports.Precondition) error {
+	_, err := s.replicate(ctx, "delete", Command{
+		Op:        DeleteOp,
+		Key:       key,
+		IfVersion: cond.IfVersion,
+		IfAbsent:  cond.IfAbsent,
+	})
+	return err
+}
+
+// GetSet stores value under key and returns the value it replaced, atomically:
+// no other write can land between the read and the write. found is false if
+// the key did not exist, and also for a deduplicated retry, whose original
+// response is not kept.
+func (s *ServiceImpl) GetSet(ctx context.Context, key, value string, ttl time.Duration) (string, bool, error) {
+	cmd := Command{Op: GetSetOp, Key: key, TTL: ttl}
+	s.encodeValue(&cmd, value)
+	result, err := s.replicate(ctx, "getset", cmd)
+	if err != nil || !result.Found {
+		return "", false, err
+	}
+	old, err := s.cipher.Decode(result.Previous)
+	if err != nil {
+		return "", false, err
+	}
+	return old, true, nil
+}
+
+// GetOrSet returns the value of key if it exists, and otherwise stores value
+// with ttl and returns it, atomically: concurrent callers all get the value of
+// whichever write landed first. loaded reports whether the value was already
+// there. A deduplicated retry reports value as stored, since the original
+// response is not kept.
+func (s *ServiceImpl) GetOrSet(ctx context.Context, key, value string, ttl time.Duration) (string, bool, error) {
+	cmd := Command{Op: GetOrSetOp, Key: key, TTL: ttl}
+	s.encodeValue(&cmd, value)
+	result, err := s.replicate(ctx, "getorset", cmd)
+	if err != nil {
+		return "", false, err
+	}
+	if !result.Found {
+		return value, false, nil
+	}
+	actual, err := s.cipher.Decode(result.Previous)
+	if err != nil {
+		return "", false, err
+	}
+	return actual, true, nil
+}
+
+// GetDel deletes key and returns the value it held, atomically.
+// It returns ErrNotFound if the key did not exist.
+func (s *ServiceImpl) GetDel(ctx context.Context, key string) (string, error) {
+	result, err := s.replicate(ctx, "getdel", Command{Op: GetDelOp, Key: key})
+	if err != nil {
+		return "", err
+	}
+	if !result.Found {
+		return "", coreerrors.ErrNotFound
+	}
+	return s.cipher.Decode(result.Previous)
+}
+
+// Append appends suffix to the value of key, creating the key if it does not
+// exist, and returns the new length in bytes. The key's TTL is kept. Appends are
+// applied in log order, so concurrent appends never lose each other's data.
+// A deduplicated retry returns a length of 0.
+func (s *ServiceImpl) Append(ctx context.Context, key, suffix string) (int, error) {
+	cmd := Command{Op: AppendOp, Key: key, Value: suffix}
+	if s.cipher != nil {
+		cmd.Value, cmd.Compressed = "", []byte(s.cipher.Escape(suffix))
+	}
+	result, err := s.replicate(ctx, "append", cmd)
+	return result.Length, err
+}
+
+// StrLen returns the length in bytes of the value of key, or 0 if it does not exist.
+// It reads like Get.
+func (s *ServiceImpl) StrLen(ctx context.Context, key string) (int, error) {
+	val, err := s.Get(ctx, key)
+	if errors.Is(err, coreerrors.ErrNotFound) {
+		return 0, nil
+	}
+	return len(val), err
+}
+
+// ZAdd adds members to the sorted set at key, creating it if needed, and
+// updates the scores of members already present. It returns how many members
+// were new (0 for a deduplicated retry). Scores must be finite.
+func (s *ServiceImpl) ZAdd(ctx context.Context, key string, members ...ports.ScoredMember) (int, error) {
+	for _, m := range members {
+		if math.IsNaN(m.Score) || math.IsInf(m.Score, 0) {
+			observability.CacheOperationsTotal.WithLabelValues("zadd", "error").Inc()
+			return 0, fmt.Errorf("%w: score of %q must be finite", coreerrors.ErrInvalidArgument, m.Member)
+		}
+	}
+	result, err := s.replicate(ctx, "zadd", Command{Op: ZAddOp, Key: key, Members: members})
+	return result.Count, err
+}
+
+// ZRemRangeByScore removes members with min <= score <= max from the sorted set
+// at key and returns how many were removed. Infinite bounds are allowed.
+func (s *ServiceImpl) ZRemRangeByScore(ctx context.Context, key string, min, max float64) (int, error) {
+	if math.IsNaN(min) || math.IsNaN(max) {
+		observability.CacheOperationsTotal.WithLabelValues("zremrangebyscore", "error").Inc()
+		return 0, fmt.Errorf("%w: score bound is NaN", coreerrors.ErrInvalidArgument)
+	}
+	result, err := s.replicate(ctx, "zremrangebyscore", Command{
+		Op:  ZRemRangeByScoreOp,
+		Key: key,
+		Min: clampScore(min),
+		Max: clampScore(max),
+	})
+	return result.Count, err
+}
+
+// Eval runs a script atomically against keys: it is replicated through Raft and
+// executed by the FSM on every node, with no other write interleaved, and its
+// writes are applied only if it completes without error. Scripts may only
+// access the keys they declare. See package script for the language.
+// It returns the script's result; a deduplicated retry returns nil.
+func (s *ServiceImpl) Eval(ctx context.Context, src string, keys, args []string) (interface{}, error) {
+	// Compile once here so that a syntax error is reported without going through Raft.
+	if _, err := script.Compile(src); err != nil {
+		observability.CacheOperationsTotal.WithLabelValues("eval", "error").Inc()
+		return nil, err
+	}
+	result, err := s.replicate(ctx, "eval", Command{Op: EvalOp, Script: src, Keys: keys, Args: args})
+	return result.Reply, err
+}
+
+// Txn applies a compare-then-ops transaction: the FSM evaluates txn.Compares
+// and applies txn.Success if they all hold, or txn.Failure otherwise, in a
+// single Raft entry, so no other write can land in between. Ops see the writes
+// of earlier ops in the same branch, and all writes get the same version.
+// A deduplicated retry returns a zero TxnResult.
+func (s *ServiceImpl) Txn(ctx context.Context, txn ports.Txn) (ports.TxnResult, error) {
+	cmd, err := s.txnCommand(txn)
+	if err != nil {
+		observability.CacheOperationsTotal.WithLabelValues("txn", "error").Inc()
+		return ports.TxnResult{}, err
+	}
+	result, err := s.replicate(ctx, "txn", Command{Op: TxnOp, Txn: cmd})
+	if err != nil {
+		return ports.TxnResult{}, err
+	}
+	for i, r := range result.Txn.Results {
+		if r.Value == "" {
+			continue
+		}
+		if result.Txn.Results[i].Value, err = s.cipher.Decode(r.Value); err != nil {
+			return ports.TxnResult{}, err
+		}
+	}
+	return result.Txn, nil
+}
+
+// txnCommand validates txn and converts it to its replicated form.
+func (s *ServiceImpl) txnCommand(txn ports.Txn) (*TxnCommand, error) {
+	if len(txn.Compares) > MaxTxnOps || len(txn.Success) > MaxTxnOps || len(txn.Failure) > MaxTxnOps {
+		return nil, fmt.Errorf("%w: transactions are limited to %d comparisons and ops per branch", coreerrors.ErrInvalidArgument, MaxTxnOps)
+	}
+	for _, cmp := range txn.Compares {
+		if cmp.Target != ports.CompareVersion && cmp.Target != ports.CompareValue {
+			return nil, fmt.Errorf("%w: unknown compare target %d", coreerrors.ErrInvalidArgument, cmp.Target)
+		}
+	}
+	cmd := &TxnCommand{Compares: txn.Compares}
+	var err error
+	if cmd.Success, err = s.txnOps(txn.Success); err != nil {
+		return nil, err
+	}
+	if cmd.Failure, err = s.txnOps(txn.Failure); err != nil {
+		return nil, err
+	}
+	return cmd, nil
+}
+
+func (s *ServiceImpl) txnOps(ops []ports.TxnOp) ([]Command, error) {
+	cmds := make([]Command, len(ops))
+	for i, op := range ops {
+		cmds[i] = Command{Key: op.Key}
+		switch op.Type {
+		case ports.TxnGet:
+			cmds[i].Op = GetOp
+		case ports.TxnSet:
+			cmds[i].Op = SetOp
+			cmds[i].TTL = op.TTL
+			s.encodeValue(&cmds[i], op.Value)
+		case ports.TxnDelete:
+			cmds[i].Op = DeleteOp
+		default:
+			return nil, fmt.Errorf("%w: unknown transaction op %d", coreerrors.ErrInvalidArgument, op.Type)
+		}
+	}
+	return cmds, nil
+}
+
+// TTL returns the remaining lifetime of key, or 0 if it does not expire.
+// It returns ErrNotFound if the key does not exist. It reads like Get, but is
+// not coalesced.
+func (s *ServiceImpl) TTL(ctx context.Context, key string) (time.Duration, error) {
+	var ttl time.Duration
+	err := s.read(ctx, "ttl", key, func() error {
+		es, ok := s.store.(ports.ExpiryStorage)
+		if !ok {
+			return fmt.Errorf("ttl: %w by this storage backend", coreerrors.ErrUnsupported)
+		}
+		var found bool
+		if ttl, found = es.TTL(key); !found {
+			return coreerrors.ErrNotFound
+		}
+		return nil
+	})
+	return ttl, err
+}
+
+// Expire makes an existing key expire after ttl, which must be positive,
+// keeping its value and version. Like every write it is applied through Raft,
+// so all replicas expire the key together. It returns ErrNotFound if the key
+// does not exist.
+func (s *ServiceImpl) Expire(ctx context.Context, key string, ttl time.Duration) error {
+	if ttl <= 0 {
+		observability.CacheOperationsTotal.WithLabelValues("expire", "error").Inc()
+		return fmt.Errorf("%w: ttl must be positive", coreerrors.ErrInvalidArgument)
+	}
+	_, err := s.replicate(ctx, "expire", Command{Op: ExpireOp, Key: key, TTL: ttl})
+	return err
+}
+
+// Persist removes key's expiration, keeping its value and version.
+// It returns ErrNotFound if the key does not exist.
+func (s *ServiceImpl) Persist(ctx context.Context, key string) error {
+	_, err := s.replicate(ctx, "persist", Command{Op: PersistOp, Key: key})
+	return err
+}
+
+// PurgeExpired deletes expired keys through Raft, in batches of at most
+// PurgeBatchSize, and returns how many it removed. Reads already hide expired
+// keys; purging frees their memory identically on every replica, instead of each
+// node deleting them by its own clock. It only scans on the leader and does
+// nothing elsewhere. Keys still within the WithStaleWhileRevalidate grace
+// window are kept.
+func (s *ServiceImpl) PurgeExpired(ctx context.Context) (int, error) {
+	es, ok := s.store.(ports.ExpiryStorage)
+	if !ok || !s.consensus.IsLeader() {
+		return 0, nil
+	}
+	return s.replicateBatches(ctx, "purge", func() Command {
+		now := time.Now()
+		if s.staleGrace > 0 && s.loader != nil {
+			// Keys within the grace window may still be served stale.
+			now = now.Add(-s.staleGrace)
+		}
+		return Command{Op: PurgeOp, Keys: es.ExpiredKeys(now, PurgeBatchSize), ExpiresAt: now.UnixNano()}
+	})
+}
+
+// EvictOverflow deletes the keys chosen by the store's eviction policy until
+// it is back within its capacity, through Raft, and returns how many it
+// removed. Writes call it in the background whenever the store is full, so that
+// replicas evict the keys the leader chose instead of each evicting by its own
+// access history. It only does something on the leader, and only for a store
+// that defers eviction to its owner (see ports.EvictionStorage).
+func (s *ServiceImpl) EvictOverflow(ctx context.Context) (int, error) {
+	es, ok := s.store.(ports.EvictionStorage)
+	if !ok || !s.consensus.IsLeader() {
+		return 0, nil
+	}
+	return s.replicateBatches(ctx, "evict", func() Command {
+		return Command{Op: EvictOp, Keys: es.EvictionVictims(PurgeBatchSize)}
+	})
+}
+
+// EvictIfFull starts EvictOverflow in the background if the store is over its
+// capacity. Writes call it, as should anything that lowers the capacity. Only
+// one eviction runs at a time; keys written meanwhile are covered by its next
+// batch or by the next write.
+func (s *ServiceImpl) EvictIfFull() {
+	es, ok := s.store.(ports.EvictionStorage)
+	if !ok || len(es.EvictionVictims(1)) == 0 || !s.evicting.CompareAndSwap(false, true) {
+		return
+	}
+	go func() {
+		defer s.evicting.Store(false)
+		ctx, cancel := context.WithTimeout(context.Background(), s.config.BackgroundTimeout)
+		defer cancel()
+		if _, err := s.EvictOverflow(ctx); err != nil {
+			log.Printf("evict keys over capacity: %v", err)
+		}
+	}()
+}
+
+// replicateBatches replicates the commands returned by next, recording
+// metrics under op, until one has no keys, is not full, or removes nothing, so
+// keys the FSM declines to remove cannot keep the loop spinning. It returns the
+// total number of keys removed.
+func (s *ServiceImpl) replicateBatches(ctx context.Context, op string, next func() Command) (int, error) {
+	total := 0
+	for {
+		cmd := next()
+		if len(cmd.Keys) == 0 {
+			return total, nil
+		}
+		result, err := s.replicate(ctx, op, cmd)
+		if err != nil {
+			return total, err
+		}
+		total += result.Count
+		if len(cmd.Keys) < PurgeBatchSize || result.Count == 0 {
+			return total, nil
+		}
+	}
+}
+
+// StartPurge starts a background loop that calls PurgeExpired and
+// PurgeTombstones every interval.
+// Calling it again replaces the running loop, which allows the interval to be
+// changed at runtime; an interval <= 0 stops it.
+func (s *ServiceImpl) StartPurge(interval time.Duration) {
+	s.purgeMu.Lock()
+	defer s.purgeMu.Unlock()
+
+	if s.stopPurge != nil {
+		close(s.stopPurge)
+		s.stopPurge = nil
+	}
+	if interval <= 0 {
+		return
+	}
+
+	stop := make(chan struct{})
+	s.stopPurge = stop
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				ctx, cancel := context.WithTimeout(context.Background(), interval)
+				if _, err := s.PurgeExpired(ctx); err != nil {
+					log.Printf("purge expired keys: %v", err)
+				}
+				if _, err := s.PurgeTombstones(ctx); err != nil {
+					log.Printf("purge tombstones: %v", err)
+				}
+				cancel()
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
+
+// ZScore returns the score of member in the sorted set at key.
+// Sorted set reads honour the consistency mode like Get, but are not coalesced.
+func (s *ServiceImpl) ZScore(ctx context.Context, key, member string) (float64, bool, error) {
+	var (
+		score float64
+		found bool
+	)
+	err := s.readSortedSet(ctx, "zscore", key, func(zs ports.SortedSetStorage) (err error) {
+		score, found, err = zs.ZScore(key, member)
+		return err
+	})
+	return score, found, err
+}
+
+// ZRange returns the members of the sorted set at key ranked start to stop
+// inclusive, lowest score first. Ranks are 0-based; negative ranks count from
+// the end, so (0, -1) is the whole set.
+func (s *ServiceImpl) ZRange(ctx context.Context, key string, start, stop int) ([]ports.ScoredMember, error) {
+	var members []ports.ScoredMember
+	err := s.readSortedSet(ctx, "zrange", key, func(zs ports.SortedSetStorage) (err error) {
+		members, err = zs.ZRange(key, start, stop)
+		return err
+	})
+	return members, err
+}
+
+// ZRangeByScore returns the members of the sorted set at key with
+// min <= score <= max, lowest score first, at most limit of them if limit > 0.
+func (s *ServiceImpl) ZRangeByScore(ctx context.Context, key string, min, max float64, limit int) ([]ports.ScoredMember, error) {
+	var members []ports.ScoredMember
+	err := s.readSortedSet(ctx, "zrangebyscore", key, func(zs ports.SortedSetStorage) (err error) {
+		members, err = zs.ZRangeByScore(key, min, max, limit)
+		return err
+	})
+	return members, err
+}
+
+// readSortedSet runs a sorted set read against the store, recording metrics under op.
+func (s *ServiceImpl) readSortedSet(ctx context.Context, op, key string, read func(ports.SortedSetStorage) error) error {
+	return s.read(ctx, op, key, func() error {
+		zs, ok := s.store.(ports.SortedSetStorage)
+		if !ok {
+			return fmt.Errorf("sorted sets: %w by this storage backend", coreerrors.ErrUnsupported)
+		}
+		return read(zs)
+	})
+}
+
+// read runs a read of key against the local store that bypasses the read path
+// of Get, honouring the consistency mode and recording metrics under op.
+func (s *ServiceImpl) read(ctx context.Context, op, key string, read func() error) error {
 	start := time.Now()
 	defer func() {
-		observability.CacheDurationSeconds.WithLabelValues("set").Observe(time.Since(start).Seconds())
+		observability.CacheDurationSeconds.WithLabelValues(op).Observe(time.Since(start).Seconds())
 	}()
 
-	cmd := Command{
-		Op:    SetOp,
-		Key:   key,
-		Value: value,
-		TTL:   ttl,
+	err := func() error {
+		if err := validateKey(key); err != nil {
+			return err
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		ctx, cancel := withTimeout(ctx, s.config.ReadTimeout)
+		defer cancel()
+		if err := s.checkConsistency(ctx); err != nil {
+			return err
+		}
+		return read()
+	}()
+	if err != nil {
+		observability.CacheOperationsTotal.WithLabelValues(op, "error").Inc()
+		return err
 	}
+	observability.CacheOperationsTotal.WithLabelValues(op, "success").Inc()
+	return nil
+}
+
+// flightKey is the key under which concurrent reads of key made with ctx are
+// coalesced. Reads only share a lookup if they ask for the same consistency,
+// so a strong read never takes the result of a lookup started for an eventual
+// read, which did not verify leadership first.
+func (s *ServiceImpl) flightKey(ctx context.Context, key string) flightID {
+	return flightID{mode: s.consistencyFor(ctx), key: key}
+}
 
-	data, err := json.Marshal(cmd)
+// checkConsistency checks that a local read meets the consistency mode for
+// ctx: under strong consistency this node must still be the leader, and under
+// eventual consistency it must be within the WithMaxLag bound.
+func (s *ServiceImpl) checkConsistency(ctx context.Context) error {
+	if s.witness {
+		return coreerrors.ErrWitness
+	}
+	if s.consistencyFor(ctx) == ConsistencyStrong {
+		if err := s.CheckQuorum(); err != nil {
+			return err
+		}
+		if err := s.verifyLeader(ctx); err != nil {
+			return fmt.Errorf("consistency check failed: %w", err)
+		}
+		return nil
+	}
+	lr, ok := s.consensus.(ports.LagReporter)
+	if s.maxLag == 0 || !ok {
+		return nil
+	}
+	lag, err := lr.ReplicationLag()
 	if err != nil {
-		observability.CacheOperationsTotal.WithLabelValues("set", "error").Inc()
 		return err
 	}
+	if lag > s.maxLag {
+		return fmt.Errorf("%w: %d entries behind, limit is %d", coreerrors.ErrStaleRead, lag, s.maxLag)
+	}
+	return nil
+}
 
-	if err := s.consensus.Apply(data); err != nil {
-		observability.CacheOperationsTotal.WithLabelValues("set", "error").Inc()
-		return err
+// CheckQuorum returns an error wrapping ErrNoQuorum if this node has lost
+// contact with a quorum of the cluster, in which case writes and strong reads
+// fail with it at once. A consensus that cannot tell always has quorum.
+func (s *ServiceImpl) CheckQuorum() error {
+	if qc, ok := s.consensus.(ports.QuorumChecker); ok {
+		return qc.CheckQuorum()
 	}
-	observability.CacheOperationsTotal.WithLabelValues("set", "success").Inc()
 	return nil
 }
 
-// Delete removes a value from the system (Strongly Consistent via Raft).
-func (s *ServiceImpl) Delete(ctx context.Context, key string) error {
+// clampScore maps infinite bounds to the largest finite ones, which JSON can
+// encode and which select the same members, since stored scores are finite.
+func clampScore(f float64) float64 {
+	return max(-math.MaxFloat64, min(f, math.MaxFloat64))
+}
+
+// encodeValue sets cmd's value, compressing and encrypting it if configured.
+func (s *ServiceImpl) encodeValue(cmd *Command, value string) {
+	encoded, compressed := s.compressor.Encode(value)
+	if s.cipher != nil {
+		cmd.Compressed = []byte(s.cipher.Seal(encoded))
+	} else if compressed {
+		cmd.Compressed = []byte(encoded)
+	} else {
+		cmd.Value = encoded
+	}
+}
+
+// replicate validates cmd, tags it with the request ID from ctx and applies it
+// through Raft, recording metrics under op.
+func (s *ServiceImpl) replicate(ctx context.Context, op string, cmd Command) (ApplyResult, error) {
 	start := time.Now()
-	defer func() {
-		observability.CacheDurationSeconds.WithLabelValues("delete").Observe(time.Since(start).Seconds())
-	}()
+	defer s.observeDuration(op, cmd.Key, start)
 
-	cmd := Command{
-		Op:  DeleteOp,
-		Key: key,
+	var buf [1]string
+	keys := cmd.touchedKeys(buf[:0])
+	for _, key := range keys {
+		if err := validateKey(key); err != nil {
+			observability.CacheOperationsTotal.WithLabelValues(op, "error").Inc()
+			return ApplyResult{}, err
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		observability.CacheOperationsTotal.WithLabelValues(op, "error").Inc()
+		return ApplyResult{}, err
+	}
+	ack, err := ackFor(ctx, &cmd)
+	if err != nil {
+		observability.CacheOperationsTotal.WithLabelValues(op, "error").Inc()
+		return ApplyResult{}, err
+	}
+	active := s.ClusterVersion()
+	s.stampOrigin(ctx, &cmd, start, active)
+	if need := cmd.minVersion(); need > active {
+		observability.CacheOperationsTotal.WithLabelValues(op, "error").Inc()
+		return ApplyResult{}, fmt.Errorf("%w: %s needs cluster version %d, the cluster is at %d until every node is upgraded",
+			coreerrors.ErrUnsupported, cmd.Op, need, active)
 	}
+	if cmd.Op != ClusterVersionOp {
+		cmd.Version = active
+	}
+	if err := s.limitTTL(&cmd); err != nil {
+		observability.CacheOperationsTotal.WithLabelValues(op, "error").Inc()
+		return ApplyResult{}, err
+	}
+	if err := s.checkQuotas(&cmd); err != nil {
+		observability.CacheOperationsTotal.WithLabelValues(op, "error").Inc()
+		return ApplyResult{}, err
+	}
+	cmd.RequestID = RequestIDFromContext(ctx)
+	// Only the leader accepts commands, so this is the leader's clock.
+	cmd.stampExpiry(start, s.ttlJitter)
+
+	if s.witness {
+		observability.CacheOperationsTotal.WithLabelValues(op, "error").Inc()
+		return ApplyResult{}, coreerrors.ErrWitness
+	}
+	if err := s.CheckQuorum(); err != nil {
+		observability.CacheOperationsTotal.WithLabelValues(op, "error").Inc()
+		return ApplyResult{}, err
+	}
+	if s.breaker != nil {
+		if err := s.breaker.allow(); err != nil {
+			observability.CacheOperationsTotal.WithLabelValues(op, "error").Inc()
+			return ApplyResult{}, err
+		}
+	}
+	var resp interface{}
+	async, canAsync := s.consensus.(ports.AsyncApplier)
+	switch {
+	case ack == AckLeader && canAsync:
+		// The outcome is recorded by the breaker once known.
+		err = s.applyAsync(async, &cmd)
+	default:
+		ctx, cancel := withTimeout(ctx, s.config.ApplyTimeout)
+		defer cancel()
+		err = s.retry(ctx, op, true, func() (err error) {
+			if s.batcher != nil && s.batcher.accepts(&cmd) {
+				resp, err = s.batcher.apply(ctx, cmd)
+			} else {
+				resp, err = s.apply(ctx, &cmd)
+			}
+			return err
+		})
+	}
+	if s.breaker != nil && (ack != AckLeader || !canAsync || err != nil) {
+		s.breaker.record(err)
+	}
+	if err != nil {
+		observability.CacheOperationsTotal.WithLabelValues(op, "error").Inc()
+		return ApplyResult{}, err
+	}
+	observability.CacheOperationsTotal.WithLabelValues(op, "success").Inc()
+	s.observeQuotaUsage(keys)
+	if cmd.Op != PurgeOp && cmd.Op != EvictOp && cmd.Op != ClusterVersionOp {
+		s.EvictIfFull()
+	}
+	result, _ := resp.(ApplyResult)
+	return result, nil
+}
 
-	data, err := json.Marshal(cmd)
+// apply encodes cmd and applies it through the consensus layer on its own.
+func (s *ServiceImpl) apply(ctx context.Context, cmd *Command) (interface{}, error) {
+	data, err := encodeTimed(cmd)
+	if err != nil {
+		return nil, err
+	}
+	return s.consensus.Apply(ctx, data)
+}
+
+// encodeTimed encodes cmd for the log, observed as the encode phase of
+// cache_raft_apply_phase_seconds; the consensus layer observes the others.
+func encodeTimed(cmd *Command) ([]byte, error) {
+	start := time.Now()
+	data, err := EncodeCommand(cmd)
+	observability.RaftApplyPhaseSeconds.WithLabelValues("encode").Observe(time.Since(start).Seconds())
+	return data, err
+}
+
+// applyAsync submits cmd without waiting for it to commit (see AckLeader).
+// Failures to commit are logged and counted, since no caller is left to
+// report them to.
+func (s *ServiceImpl) applyAsync(a ports.AsyncApplier, cmd *Command) error {
+	data, err := encodeTimed(cmd)
 	if err != nil {
-		observability.CacheOperationsTotal.WithLabelValues("delete", "error").Inc()
 		return err
 	}
+	op := cmd.Op
+	return a.ApplyAsync(data, func(_ interface{}, err error) {
+		if s.breaker != nil {
+			s.breaker.record(err)
+		}
+		if err != nil {
+			observability.CacheLeaderAckedFailuresTotal.Inc()
+			log.Printf("%s acknowledged by the leader failed to commit: %v", op, err)
+		}
+	})
+}
+
+// ClusterVersion returns the command version the leader writes at (see
+// MaxCommandVersion). Consensus implementations that do not track one, such as
+// a single node, are taken to support MaxCommandVersion.
+func (s *ServiceImpl) ClusterVersion() uint32 {
+	if cv, ok := s.consensus.(ports.ClusterVersioner); ok {
+		return cv.ClusterVersion()
+	}
+	return MaxCommandVersion
+}
 
-	if err := s.consensus.Apply(data); err != nil {
-		observability.CacheOperationsTotal.WithLabelValues("delete", "error").Inc()
+// SetClusterVersion raises the cluster version to version, enabling the
+// command encodings and types it introduced. The caller asserts that every
+// member runs a release supporting it: members that do not will fail to apply
+// later commands. The version never decreases; a lower one is a no-op.
+func (s *ServiceImpl) SetClusterVersion(ctx context.Context, version uint32) error {
+	if version > MaxCommandVersion {
+		return fmt.Errorf("%w: cluster version %d is newer than this node's %d", coreerrors.ErrInvalidArgument, version, MaxCommandVersion)
+	}
+	if version <= s.ClusterVersion() {
+		return nil
+	}
+	_, err := s.replicate(ctx, "cluster_version", Command{Op: ClusterVersionOp, Version: version})
+	return err
+}
+
+// Join adds a new node to the cluster by invoking the consensus layer, then
+// records grpcAddr, if given, as the address it serves gRPC at. Clusters below
+// CommandVersionMembers cannot record it, and only add the node.
+func (s *ServiceImpl) Join(ctx context.Context, nodeID, addr, grpcAddr string) error {
+	if err := s.consensus.AddVoter(nodeID, addr); err != nil {
 		return err
 	}
-	observability.CacheOperationsTotal.WithLabelValues("delete", "success").Inc()
-	return nil
+	if grpcAddr == "" || s.ClusterVersion() < CommandVersionMembers {
+		return nil
+	}
+	return s.AdvertiseMember(ctx, nodeID, grpcAddr)
 }
 
-// Join adds a new node to the cluster by invoking the consensus layer.
-func (s *ServiceImpl) Join(ctx context.Context, nodeID, addr string) error {
-	return s.consensus.AddVoter(nodeID, addr)
+// AdvertiseMember records grpcAddr as the address the member nodeID serves
+// gRPC at, so that other members and clients reach it there rather than
+// guessing from its Raft address (see ports.Member). It must be called on the
+// leader.
+func (s *ServiceImpl) AdvertiseMember(ctx context.Context, nodeID, grpcAddr string) error {
+	_, err := s.replicate(ctx, "member", Command{Op: MemberOp, Key: nodeID, Value: grpcAddr})
+	return err
+}
+
+// validateKey rejects keys that are empty or exceed MaxKeyLength.
+func validateKey(key string) error {
+	if key == "" {
+		return coreerrors.ErrEmptyKey
+	}
+	if len(key) > MaxKeyLength {
+		return fmt.Errorf("%w: %d bytes exceeds limit of %d", coreerrors.ErrKeyTooLarge, len(key), MaxKeyLength)
+	}
+	return nil
 }
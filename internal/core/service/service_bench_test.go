@@ -0,0 +1,66 @@
+package service
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	"distributed-cache-service/internal/store"
+)
+
+// benchConsensus applies writes straight to the store, so that benchmarks
+// measure the service rather than Raft.
+type benchConsensus struct {
+	MockConsensus
+	store *store.Store
+}
+
+func (m *benchConsensus) Apply(ctx context.Context, data []byte) (interface{}, error) {
+	var cmd Command
+	if err := DecodeCommand(data, &cmd); err != nil {
+		return nil, err
+	}
+	m.store.Set(cmd.Key, cmd.StoredValue(), cmd.TTL)
+	return ApplyResult{Version: 1}, nil
+}
+
+// BenchmarkService_Get reads a key that exists, to track the allocations
+// of the read path.
+func BenchmarkService_Get(b *testing.B) {
+	for _, mode := range []ConsistencyMode{ConsistencyStrong, ConsistencyEventual} {
+		b.Run(string(mode), func(b *testing.B) {
+			st := store.New()
+			svc := New(st, &benchConsensus{store: st}, mode)
+			ctx := context.Background()
+			st.Set("user:1", EncodeVersion(1, "value"), 0)
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				if _, err := svc.Get(ctx, "user:1"); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkService_Set writes keys through a consensus that applies them at
+// once, to track the allocations of the write path up to Raft.
+func BenchmarkService_Set(b *testing.B) {
+	st := store.New()
+	svc := New(st, &benchConsensus{store: st}, ConsistencyStrong)
+	ctx := context.Background()
+	keys := make([]string, 1024)
+	for i := range keys {
+		keys[i] = "user:" + strconv.Itoa(i)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := svc.Set(ctx, keys[i%len(keys)], "value", 0); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -3,9 +3,21 @@ package service
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
+
+	"distributed-cache-service/internal/compression"
+	coreerrors "distributed-cache-service/internal/core/errors"
+	"distributed-cache-service/internal/core/ports"
+	"distributed-cache-service/internal/encryption"
+	"distributed-cache-service/internal/store"
 )
 
 // MockStore implements ports.Storage for testing.
@@ -34,14 +46,20 @@ func (m *MockStore) Set(key, value string, ttl time.Duration) {
 
 func (m *MockStore) Delete(key string) {}
 
+func (m *MockStore) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.data)
+}
+
 // MockConsensus implements ports.Consensus for testing.
 // It serves as a no-op stub for consensus operations unless extended.
 type MockConsensus struct{}
 
-func (m *MockConsensus) Apply(cmd []byte) error         { return nil }
-func (m *MockConsensus) AddVoter(id, addr string) error { return nil }
-func (m *MockConsensus) IsLeader() bool                 { return true }
-func (m *MockConsensus) VerifyLeader() error            { return nil }
+func (m *MockConsensus) Apply(ctx context.Context, cmd []byte) (interface{}, error) { return nil, nil }
+func (m *MockConsensus) AddVoter(id, addr string) error                             { return nil }
+func (m *MockConsensus) IsLeader() bool                                             { return true }
+func (m *MockConsensus) VerifyLeader() error                                        { return nil }
 
 func TestService_Get_Concurrency(t *testing.T) {
 	mockStore := &MockStore{
@@ -84,3 +102,905 @@ func TestService_Get_Concurrency(t *testing.T) {
 		t.Errorf("Significantly failed to coalesce requests. Calls: %d", calls)
 	}
 }
+
+func TestService_Get_CoalescesPerConsistency(t *testing.T) {
+	store := &MockStore{data: map[string]string{}}
+	loader := newBlockingLoader()
+	svc := New(store, &applyingConsensus{store: store}, ConsistencyStrong, WithLoader(loader))
+	strong := context.Background()
+	eventual := ContextWithConsistency(strong, ConsistencyEventual)
+
+	errc := make(chan error, 4)
+	get := func(ctx context.Context) {
+		v, err := svc.Get(ctx, "k")
+		if err == nil && v != "loaded-k" {
+			err = fmt.Errorf("unexpected value %q", v)
+		}
+		errc <- err
+	}
+	waiters := func(ctx context.Context) int {
+		svc.requestGroup.mu.Lock()
+		defer svc.requestGroup.mu.Unlock()
+		if f := svc.requestGroup.flights[svc.flightKey(ctx, "k")]; f != nil {
+			return f.waiters
+		}
+		return 0
+	}
+
+	// A strong and an eventual read of the same key each get their own lookup.
+	go get(strong)
+	waitOn(t, loader.started, "strong load to start")
+	go get(eventual)
+	waitOn(t, loader.started, "eventual load to start")
+
+	// Reads in the same mode still share one.
+	go get(ContextWithConsistency(strong, ConsistencyStrong))
+	go get(eventual)
+	deadline := time.Now().Add(2 * time.Second)
+	for waiters(strong) != 2 || waiters(eventual) != 2 {
+		if time.Now().After(deadline) {
+			t.Fatal("reads never joined the lookup for their mode")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	close(loader.release)
+	for i := 0; i < 4; i++ {
+		if err := <-errc; err != nil {
+			t.Fatal(err)
+		}
+	}
+	if len(loader.started) != 0 {
+		t.Error("expected one load per consistency mode")
+	}
+}
+
+func TestService_KeyValidation(t *testing.T) {
+	svc := New(&MockStore{data: map[string]string{}}, &MockConsensus{}, ConsistencyStrong)
+	ctx := context.Background()
+
+	if _, err := svc.Get(ctx, ""); !errors.Is(err, coreerrors.ErrEmptyKey) {
+		t.Errorf("expected ErrEmptyKey, got %v", err)
+	}
+
+	longKey := strings.Repeat("k", MaxKeyLength+1)
+	if err := svc.Set(ctx, longKey, "v", 0); !errors.Is(err, coreerrors.ErrKeyTooLarge) {
+		t.Errorf("expected ErrKeyTooLarge, got %v", err)
+	}
+
+	if _, err := svc.Get(ctx, "missing"); !errors.Is(err, coreerrors.ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
+
+// applyingConsensus applies commands straight to a store, like a single-node FSM.
+type applyingConsensus struct {
+	MockConsensus
+	store *MockStore
+}
+
+func (m *applyingConsensus) Apply(ctx context.Context, data []byte) (interface{}, error) {
+	var cmd Command
+	if err := DecodeCommand(data, &cmd); err != nil {
+		return nil, err
+	}
+	m.store.Set(cmd.Key, cmd.StoredValue(), cmd.TTL)
+	return nil, nil
+}
+
+func TestService_Compression(t *testing.T) {
+	store := &MockStore{data: map[string]string{}}
+	svc := New(store, &applyingConsensus{store: store}, ConsistencyEventual,
+		WithCompression(compression.New(compression.Deflate, 64)))
+	ctx := context.Background()
+
+	large := strings.Repeat("compressible ", 100)
+	if err := svc.Set(ctx, "large", large, 0); err != nil {
+		t.Fatalf("set: %v", err)
+	}
+	if stored := store.data["large"]; len(stored) >= len(large) {
+		t.Errorf("expected stored value to be compressed, got %d bytes", len(stored))
+	}
+	if got, err := svc.Get(ctx, "large"); err != nil || got != large {
+		t.Errorf("expected round trip, got %d bytes (err=%v)", len(got), err)
+	}
+
+	if err := svc.Set(ctx, "small", "tiny", 0); err != nil {
+		t.Fatalf("set: %v", err)
+	}
+	if store.data["small"] != "tiny" {
+		t.Errorf("expected small value to be stored raw, got %q", store.data["small"])
+	}
+}
+
+func TestService_Encryption(t *testing.T) {
+	cipher, err := encryption.New(context.Background(), encryption.StaticKeys("k1", map[string][]byte{"k1": make([]byte, 32)}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	store := &MockStore{data: map[string]string{}}
+	svc := New(store, &applyingConsensus{store: store}, ConsistencyEventual,
+		WithCompression(compression.New(compression.Deflate, 64)), WithEncryption(cipher))
+	ctx := context.Background()
+
+	large := strings.Repeat("compressible secret ", 100)
+	for key, value := range map[string]string{"small": "secret", "large": large} {
+		if err := svc.Set(ctx, key, value, 0); err != nil {
+			t.Fatalf("set: %v", err)
+		}
+		if strings.Contains(store.data[key], "secret") {
+			t.Errorf("expected %s to be stored encrypted, got %q", key, store.data[key])
+		}
+		if got, err := svc.Get(ctx, key); err != nil || got != value {
+			t.Errorf("expected round trip of %s, got %d bytes (err=%v)", key, len(got), err)
+		}
+	}
+
+	// Without the keys, encrypted values cannot be read.
+	plain := New(store, &applyingConsensus{store: store}, ConsistencyEventual)
+	if _, err := plain.Get(ctx, "small"); !errors.Is(err, compression.ErrEncrypted) {
+		t.Errorf("expected ErrEncrypted, got %v", err)
+	}
+
+	// Appended suffixes are encrypted in the log too.
+	consensus := &resultConsensus{result: ApplyResult{Version: 2, Length: 9}}
+	svc = New(store, consensus, ConsistencyEventual, WithEncryption(cipher))
+	if _, err := svc.Append(ctx, "small", "-secret"); err != nil {
+		t.Fatal(err)
+	}
+	if consensus.last.Value != "" {
+		t.Errorf("expected the suffix to be encrypted, got %q", consensus.last.Value)
+	}
+	if suffix, err := cipher.Decode(string(consensus.last.Compressed)); err != nil || suffix != "-secret" {
+		t.Errorf("expected the encrypted suffix, got %q (%v)", suffix, err)
+	}
+}
+
+func TestService_ReadThroughLoader(t *testing.T) {
+	store := &MockStore{data: map[string]string{}}
+	var loads atomic.Int32
+	loader := ports.LoaderFunc(func(ctx context.Context, key string) (string, time.Duration, error) {
+		loads.Add(1)
+		time.Sleep(20 * time.Millisecond)
+		if key == "absent" {
+			return "", 0, coreerrors.ErrNotFound
+		}
+		return "loaded-" + key, time.Minute, nil
+	})
+	svc := New(store, &applyingConsensus{store: store}, ConsistencyEventual, WithLoader(loader))
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if v, err := svc.Get(ctx, "k"); err != nil || v != "loaded-k" {
+				t.Errorf("expected loaded-k, got %q (%v)", v, err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if n := loads.Load(); n != 1 {
+		t.Errorf("expected 1 loader call, got %d", n)
+	}
+	if store.data["k"] != "loaded-k" {
+		t.Errorf("expected loaded value to be written back, got %q", store.data["k"])
+	}
+
+	if _, err := svc.Get(ctx, "absent"); !errors.Is(err, coreerrors.ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
+
+// expiringConsensus applies SETs to a real store, so that keys expire, and
+// records them and the keys of the last PURGE.
+type expiringConsensus struct {
+	MockConsensus
+	store  *store.Store
+	purged []string
+
+	mu   sync.Mutex
+	sets []Command
+}
+
+func (m *expiringConsensus) applied() []Command {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Command(nil), m.sets...)
+}
+
+func (m *expiringConsensus) Apply(ctx context.Context, data []byte) (interface{}, error) {
+	var cmd Command
+	if err := DecodeCommand(data, &cmd); err != nil {
+		return nil, err
+	}
+	switch cmd.Op {
+	case SetOp:
+		m.store.Set(cmd.Key, cmd.StoredValue(), cmd.TTL)
+		m.mu.Lock()
+		m.sets = append(m.sets, cmd)
+		m.mu.Unlock()
+	case PurgeOp:
+		m.purged = cmd.Keys
+	}
+	return nil, nil
+}
+
+func TestService_StaleWhileRevalidate(t *testing.T) {
+	st := store.New()
+	loader := newBlockingLoader()
+	consensus := &expiringConsensus{store: st}
+	svc := New(st, consensus, ConsistencyEventual, WithLoader(loader), WithStaleWhileRevalidate(time.Minute))
+	ctx := context.Background()
+
+	// Within the grace window the stale value is served at once, and a single
+	// refresh runs in the background however many reads see it.
+	st.SetExpiresAt("k", "old", time.Now().Add(-time.Second))
+	for i := 0; i < 3; i++ {
+		if v, version, err := svc.GetVersioned(ctx, "k"); err != nil || v != "old" || version != 0 {
+			t.Fatalf("expected the stale value at version 0, got %q, %d (%v)", v, version, err)
+		}
+	}
+	waitOn(t, loader.started, "refresh to start")
+	close(loader.release)
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		if v, _ := st.Get("k"); v == "loaded-k" {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for the refreshed value")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if len(loader.started) != 0 {
+		t.Errorf("expected one refresh, got %d more", len(loader.started))
+	}
+
+	// Past the grace window a read waits for the loader, as on a miss.
+	st.SetExpiresAt("gone", "old", time.Now().Add(-2*time.Minute))
+	if v, err := svc.Get(ctx, "gone"); err != nil || v != "loaded-gone" {
+		t.Errorf("expected a synchronous load, got %q (%v)", v, err)
+	}
+
+	// Purging keeps keys that may still be served stale.
+	st.SetExpiresAt("recent", "v", time.Now().Add(-time.Second))
+	st.SetExpiresAt("old", "v", time.Now().Add(-2*time.Minute))
+	if _, err := svc.PurgeExpired(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if len(consensus.purged) != 1 || consensus.purged[0] != "old" {
+		t.Errorf("expected only the key past the grace window to be purged, got %v", consensus.purged)
+	}
+}
+
+// blockingLoader blocks every load until release is closed or its ctx is done.
+type blockingLoader struct {
+	started  chan struct{}
+	release  chan struct{}
+	canceled chan struct{}
+}
+
+func newBlockingLoader() *blockingLoader {
+	return &blockingLoader{
+		started:  make(chan struct{}, 10),
+		release:  make(chan struct{}),
+		canceled: make(chan struct{}, 10),
+	}
+}
+
+func (l *blockingLoader) Load(ctx context.Context, key string) (string, time.Duration, error) {
+	l.started <- struct{}{}
+	select {
+	case <-l.release:
+		return "loaded-" + key, 0, nil
+	case <-ctx.Done():
+		l.canceled <- struct{}{}
+		return "", 0, ctx.Err()
+	}
+}
+
+func waitOn(t *testing.T, ch <-chan struct{}, what string) {
+	t.Helper()
+	select {
+	case <-ch:
+	case <-time.After(2 * time.Second):
+		t.Fatalf("timed out waiting for %s", what)
+	}
+}
+
+func TestService_Get_Cancellation(t *testing.T) {
+	store := &MockStore{data: map[string]string{}}
+	loader := newBlockingLoader()
+	svc := New(store, &applyingConsensus{store: store}, ConsistencyEventual, WithLoader(loader))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	errc := make(chan error, 1)
+	go func() {
+		_, err := svc.Get(ctx, "k")
+		errc <- err
+	}()
+	waitOn(t, loader.started, "load to start")
+
+	cancel()
+	select {
+	case err := <-errc:
+		if !errors.Is(err, context.Canceled) {
+			t.Fatalf("expected context.Canceled, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Get did not return after its context was cancelled")
+	}
+	// The only waiter left, so the shared load is cancelled too.
+	waitOn(t, loader.canceled, "load to be cancelled")
+
+	// A later caller starts a fresh load rather than joining the cancelled one.
+	errc2 := make(chan error, 1)
+	go func() {
+		_, err := svc.Get(context.Background(), "k")
+		errc2 <- err
+	}()
+	waitOn(t, loader.started, "second load to start")
+	close(loader.release)
+	if err := <-errc2; err != nil {
+		t.Fatalf("expected fresh load to succeed, got %v", err)
+	}
+}
+
+func TestService_Get_CancelledWaiterDoesNotFailOthers(t *testing.T) {
+	store := &MockStore{data: map[string]string{}}
+	loader := newBlockingLoader()
+	svc := New(store, &applyingConsensus{store: store}, ConsistencyEventual, WithLoader(loader))
+
+	leaderCtx, cancel := context.WithCancel(context.Background())
+	leaderErr := make(chan error, 1)
+	go func() {
+		_, err := svc.Get(leaderCtx, "k")
+		leaderErr <- err
+	}()
+	waitOn(t, loader.started, "load to start")
+
+	type result struct {
+		v   string
+		err error
+	}
+	follower := make(chan result, 1)
+	go func() {
+		v, err := svc.Get(context.Background(), "k")
+		follower <- result{v, err}
+	}()
+	// Wait until the second caller has joined the flight.
+	deadline := time.Now().Add(2 * time.Second)
+	for {
+		svc.requestGroup.mu.Lock()
+		n := svc.requestGroup.flights[svc.flightKey(context.Background(), "k")].waiters
+		svc.requestGroup.mu.Unlock()
+		if n == 2 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("second caller never joined the flight")
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	// The caller that started the load gives up; the other keeps waiting.
+	cancel()
+	if err := <-leaderErr; !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	select {
+	case <-loader.canceled:
+		t.Fatal("shared load was cancelled while a caller was still waiting")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	close(loader.release)
+	r := <-follower
+	if r.err != nil || r.v != "loaded-k" {
+		t.Fatalf("expected loaded-k, got %q (%v)", r.v, r.err)
+	}
+	if len(loader.started) != 0 {
+		t.Error("expected a single shared load")
+	}
+}
+
+// countingConsensus records how many commands were submitted.
+type countingConsensus struct {
+	MockConsensus
+	applies atomic.Int32
+}
+
+func (m *countingConsensus) Apply(ctx context.Context, cmd []byte) (interface{}, error) {
+	m.applies.Add(1)
+	return nil, nil
+}
+
+func TestService_Write_CancelledContext(t *testing.T) {
+	consensus := &countingConsensus{}
+	svc := New(&MockStore{data: map[string]string{}}, consensus, ConsistencyEventual)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := svc.Set(ctx, "k", "v", 0); !errors.Is(err, context.Canceled) {
+		t.Errorf("Set: expected context.Canceled, got %v", err)
+	}
+	if err := svc.Delete(ctx, "k"); !errors.Is(err, context.Canceled) {
+		t.Errorf("Delete: expected context.Canceled, got %v", err)
+	}
+	if _, err := svc.Get(ctx, "k"); !errors.Is(err, context.Canceled) {
+		t.Errorf("Get: expected context.Canceled, got %v", err)
+	}
+	if n := consensus.applies.Load(); n != 0 {
+		t.Errorf("expected no commands to be submitted, got %d", n)
+	}
+}
+
+// versioningConsensus applies commands like the FSM: values are stored with
+// their version and preconditions are recorded for inspection.
+type versioningConsensus struct {
+	MockConsensus
+	store *MockStore
+	index uint64
+	last  Command
+}
+
+func (m *versioningConsensus) Apply(ctx context.Context, data []byte) (interface{}, error) {
+	if err := DecodeCommand(data, &m.last); err != nil {
+		return nil, err
+	}
+	m.index++
+	m.store.Set(m.last.Key, EncodeVersion(m.index, m.last.StoredValue()), m.last.TTL)
+	return ApplyResult{Version: m.index}, nil
+}
+
+func TestService_Versions(t *testing.T) {
+	store := &MockStore{data: map[string]string{"legacy": "old"}}
+	consensus := &versioningConsensus{store: store}
+	svc := New(store, consensus, ConsistencyEventual)
+	ctx := context.Background()
+
+	version, err := svc.SetIf(ctx, "k", "v1", 0, ports.Precondition{IfVersion: 0, IfAbsent: true})
+	if err != nil || version != 1 {
+		t.Fatalf("expected version 1, got %d (%v)", version, err)
+	}
+	if !consensus.last.IfAbsent {
+		t.Error("expected IfAbsent to be replicated")
+	}
+	if _, err := svc.SetIf(ctx, "k", "v2", 0, ports.Precondition{IfVersion: 1}); err != nil {
+		t.Fatalf("set: %v", err)
+	}
+	if consensus.last.IfVersion != 1 {
+		t.Errorf("expected IfVersion 1 to be replicated, got %d", consensus.last.IfVersion)
+	}
+
+	val, version, err := svc.GetVersioned(ctx, "k")
+	if err != nil || val != "v2" || version != 2 {
+		t.Errorf("expected v2 at version 2, got %q at %d (%v)", val, version, err)
+	}
+	// Values written before versioning read back with version 0.
+	val, version, err = svc.GetVersioned(ctx, "legacy")
+	if err != nil || val != "old" || version != 0 {
+		t.Errorf("expected old at version 0, got %q at %d (%v)", val, version, err)
+	}
+}
+
+func TestVersionEncoding(t *testing.T) {
+	for _, v := range []string{"", "plain", "\x00dcz-compressed"} {
+		version, stored := DecodeVersion(EncodeVersion(42, v))
+		if version != 42 || stored != v {
+			t.Errorf("round trip of %q: got %q at %d", v, stored, version)
+		}
+	}
+	if version, stored := DecodeVersion("\x00dcv"); version != 0 || stored != "\x00dcv" {
+		t.Errorf("expected short value to be read as unversioned, got %q at %d", stored, version)
+	}
+}
+
+// resultConsensus answers every command with a fixed ApplyResult.
+type resultConsensus struct {
+	MockConsensus
+	result ApplyResult
+	last   Command
+}
+
+func (m *resultConsensus) Apply(ctx context.Context, data []byte) (interface{}, error) {
+	if err := DecodeCommand(data, &m.last); err != nil {
+		return nil, err
+	}
+	return m.result, nil
+}
+
+func TestService_GetSetAndGetDel(t *testing.T) {
+	comp := compression.New(compression.Deflate, 16)
+	big := strings.Repeat("previous value ", 10)
+	stored, _ := comp.Encode(big)
+	consensus := &resultConsensus{result: ApplyResult{Version: 3, Previous: stored, Found: true}}
+	svc := New(&MockStore{data: map[string]string{}}, consensus, ConsistencyEventual, WithCompression(comp))
+	ctx := ContextWithRequestID(context.Background(), "req-1")
+
+	// Previous values come back decompressed.
+	old, found, err := svc.GetSet(ctx, "k", "v", time.Minute)
+	if err != nil || !found || old != big {
+		t.Fatalf("expected previous value, got %q found=%v (%v)", old, found, err)
+	}
+	if consensus.last.Op != GetSetOp || consensus.last.TTL != time.Minute || consensus.last.RequestID != "req-1" {
+		t.Errorf("unexpected command %+v", consensus.last)
+	}
+	if v, err := svc.GetDel(ctx, "k"); err != nil || v != big {
+		t.Fatalf("expected previous value, got %q (%v)", v, err)
+	}
+	if consensus.last.Op != GetDelOp {
+		t.Errorf("expected GETDEL, got %s", consensus.last.Op)
+	}
+
+	consensus.result = ApplyResult{}
+	if _, found, err := svc.GetSet(ctx, "k", "v", 0); err != nil || found {
+		t.Errorf("expected found=false, got %v (%v)", found, err)
+	}
+	if _, err := svc.GetDel(ctx, "k"); !errors.Is(err, coreerrors.ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+}
+
+func TestService_GetOrSet(t *testing.T) {
+	comp := compression.New(compression.Deflate, 16)
+	big := strings.Repeat("existing value ", 10)
+	stored, _ := comp.Encode(big)
+	consensus := &resultConsensus{result: ApplyResult{Previous: stored, Found: true}}
+	svc := New(&MockStore{data: map[string]string{}}, consensus, ConsistencyEventual, WithCompression(comp))
+	ctx := context.Background()
+
+	// An existing value comes back decompressed.
+	v, loaded, err := svc.GetOrSet(ctx, "k", "v", time.Minute)
+	if err != nil || !loaded || v != big {
+		t.Fatalf("expected the existing value, got %q loaded=%v (%v)", v, loaded, err)
+	}
+	if consensus.last.Op != GetOrSetOp || consensus.last.TTL != time.Minute || consensus.last.Value != "v" {
+		t.Errorf("unexpected command %+v", consensus.last)
+	}
+
+	consensus.result = ApplyResult{Version: 4}
+	if v, loaded, err := svc.GetOrSet(ctx, "k", "v", 0); err != nil || loaded || v != "v" {
+		t.Errorf("expected v to be stored, got %q loaded=%v (%v)", v, loaded, err)
+	}
+}
+
+func TestService_AppendAndStrLen(t *testing.T) {
+	store := &MockStore{data: map[string]string{"k": "hello"}}
+	consensus := &resultConsensus{result: ApplyResult{Version: 2, Length: 8}}
+	svc := New(store, consensus, ConsistencyEventual, WithCompression(compression.New(compression.Deflate, 0)))
+	ctx := context.Background()
+
+	n, err := svc.Append(ctx, "k", "abc")
+	if err != nil || n != 8 {
+		t.Fatalf("expected length 8, got %d (%v)", n, err)
+	}
+	// Suffixes are replicated as-is; the FSM owns the combined value.
+	if consensus.last.Op != AppendOp || consensus.last.Value != "abc" || consensus.last.Compressed != nil {
+		t.Errorf("unexpected command %+v", consensus.last)
+	}
+
+	if n, err := svc.StrLen(ctx, "k"); err != nil || n != 5 {
+		t.Errorf("expected 5, got %d (%v)", n, err)
+	}
+	if n, err := svc.StrLen(ctx, "missing"); err != nil || n != 0 {
+		t.Errorf("expected 0 for a missing key, got %d (%v)", n, err)
+	}
+}
+
+func TestService_SortedSets(t *testing.T) {
+	consensus := &resultConsensus{result: ApplyResult{Count: 2}}
+	zs := store.New()
+	svc := New(zs, consensus, ConsistencyEventual)
+	ctx := context.Background()
+
+	n, err := svc.ZAdd(ctx, "board", ports.ScoredMember{Member: "a", Score: 1}, ports.ScoredMember{Member: "b", Score: 2})
+	if err != nil || n != 2 {
+		t.Fatalf("expected 2 added, got %d (%v)", n, err)
+	}
+	if consensus.last.Op != ZAddOp || len(consensus.last.Members) != 2 {
+		t.Errorf("unexpected command %+v", consensus.last)
+	}
+	if _, err := svc.ZAdd(ctx, "board", ports.ScoredMember{Member: "a", Score: math.Inf(1)}); !errors.Is(err, coreerrors.ErrInvalidArgument) {
+		t.Errorf("expected ErrInvalidArgument for an infinite score, got %v", err)
+	}
+
+	// Infinite bounds are clamped so the command can be encoded as JSON.
+	if _, err := svc.ZRemRangeByScore(ctx, "board", math.Inf(-1), 5); err != nil {
+		t.Fatalf("zremrangebyscore: %v", err)
+	}
+	if consensus.last.Op != ZRemRangeByScoreOp || consensus.last.Min != -math.MaxFloat64 || consensus.last.Max != 5 {
+		t.Errorf("unexpected command %+v", consensus.last)
+	}
+
+	// Reads go straight to the store.
+	if _, err := zs.ZAdd("board", time.Now(), ports.ScoredMember{Member: "a", Score: 1}, ports.ScoredMember{Member: "b", Score: 2}); err != nil {
+		t.Fatal(err)
+	}
+	if members, err := svc.ZRangeByScore(ctx, "board", 2, math.Inf(1), 0); err != nil || len(members) != 1 || members[0].Member != "b" {
+		t.Errorf("unexpected range %v (%v)", members, err)
+	}
+	if score, found, err := svc.ZScore(ctx, "board", "a"); err != nil || !found || score != 1 {
+		t.Errorf("expected a at 1, got %v %v (%v)", score, found, err)
+	}
+
+	// Backends without sorted sets report ErrUnsupported.
+	plain := New(&MockStore{data: map[string]string{}}, consensus, ConsistencyEventual)
+	if _, err := plain.ZRange(ctx, "board", 0, -1); !errors.Is(err, coreerrors.ErrUnsupported) {
+		t.Errorf("expected ErrUnsupported, got %v", err)
+	}
+}
+
+func TestService_Eval(t *testing.T) {
+	consensus := &resultConsensus{result: ApplyResult{Reply: "ok"}}
+	svc := New(&MockStore{data: map[string]string{}}, consensus, ConsistencyEventual)
+	ctx := context.Background()
+
+	reply, err := svc.Eval(ctx, "return 'ok'", []string{"a", "b"}, []string{"1"})
+	if err != nil || reply != "ok" {
+		t.Fatalf("expected ok, got %v (%v)", reply, err)
+	}
+	if c := consensus.last; c.Op != EvalOp || c.Script != "return 'ok'" || len(c.Keys) != 2 || c.Args[0] != "1" {
+		t.Errorf("unexpected command %+v", c)
+	}
+
+	// Syntax errors and invalid keys are rejected before replication.
+	consensus.last = Command{}
+	if _, err := svc.Eval(ctx, "return 1 +", nil, nil); !errors.Is(err, coreerrors.ErrScript) {
+		t.Errorf("expected ErrScript, got %v", err)
+	}
+	if _, err := svc.Eval(ctx, "return 1", []string{""}, nil); !errors.Is(err, coreerrors.ErrEmptyKey) {
+		t.Errorf("expected ErrEmptyKey, got %v", err)
+	}
+	if consensus.last.Op != "" {
+		t.Errorf("expected no command to be submitted, got %+v", consensus.last)
+	}
+}
+
+func TestService_Txn(t *testing.T) {
+	comp := compression.New(compression.Deflate, 16)
+	big := strings.Repeat("stored value ", 10)
+	stored, _ := comp.Encode(big)
+	consensus := &resultConsensus{result: ApplyResult{Txn: ports.TxnResult{
+		Succeeded: true,
+		Results:   []ports.TxnOpResult{{Version: 7}, {Value: stored, Found: true, Version: 7}},
+	}}}
+	svc := New(&MockStore{data: map[string]string{}}, consensus, ConsistencyEventual, WithCompression(comp))
+	ctx := context.Background()
+
+	result, err := svc.Txn(ctx, ports.Txn{
+		Compares: []ports.Compare{{Key: "a", Version: 3}},
+		Success:  []ports.TxnOp{{Type: ports.TxnSet, Key: "a", Value: big, TTL: time.Minute}, {Type: ports.TxnGet, Key: "a"}},
+		Failure:  []ports.TxnOp{{Type: ports.TxnDelete, Key: "b"}},
+	})
+	if err != nil || !result.Succeeded || result.Results[1].Value != big {
+		t.Fatalf("unexpected result %+v (%v)", result, err)
+	}
+	txn := consensus.last.Txn
+	if consensus.last.Op != TxnOp || txn.Success[0].Compressed == nil || txn.Success[0].ExpiresAt == 0 ||
+		txn.Success[1].Op != GetOp || txn.Failure[0].Op != DeleteOp {
+		t.Errorf("unexpected command %+v", txn)
+	}
+
+	// Invalid transactions are rejected before replication.
+	consensus.last = Command{}
+	invalid := []ports.Txn{
+		{Compares: []ports.Compare{{Key: ""}}},
+		{Success: []ports.TxnOp{{Type: ports.TxnDelete, Key: strings.Repeat("k", MaxKeyLength+1)}}},
+		{Failure: []ports.TxnOp{{Type: 9, Key: "a"}}},
+		{Compares: []ports.Compare{{Key: "a", Target: 9}}},
+		{Success: make([]ports.TxnOp, MaxTxnOps+1)},
+	}
+	for _, txn := range invalid {
+		if _, err := svc.Txn(ctx, txn); err == nil {
+			t.Errorf("expected %+v to be rejected", txn)
+		}
+	}
+	if consensus.last.Op != "" {
+		t.Errorf("expected no command to be submitted, got %+v", consensus.last)
+	}
+}
+
+func TestService_Expiration(t *testing.T) {
+	consensus := &resultConsensus{}
+	st := store.New()
+	svc := New(st, consensus, ConsistencyEventual)
+	ctx := context.Background()
+
+	if _, err := svc.TTL(ctx, "k"); !errors.Is(err, coreerrors.ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+	st.Set("k", "v", time.Minute)
+	if ttl, err := svc.TTL(ctx, "k"); err != nil || ttl <= 59*time.Second {
+		t.Errorf("expected about a minute, got %v (%v)", ttl, err)
+	}
+
+	// The absolute expiration is stamped when the command is submitted.
+	before := time.Now()
+	if err := svc.Expire(ctx, "k", 30*time.Second); err != nil {
+		t.Fatal(err)
+	}
+	expiry := consensus.last.Expiry()
+	if consensus.last.Op != ExpireOp || consensus.last.TTL != 30*time.Second ||
+		expiry.Before(before.Add(30*time.Second)) || expiry.After(time.Now().Add(30*time.Second)) {
+		t.Errorf("unexpected command %+v", consensus.last)
+	}
+	consensus.last = Command{}
+	if err := svc.Persist(ctx, "k"); err != nil || consensus.last.Op != PersistOp || !consensus.last.Expiry().IsZero() {
+		t.Errorf("unexpected command %+v (%v)", consensus.last, err)
+	}
+	if err := svc.Expire(ctx, "k", 0); !errors.Is(err, coreerrors.ErrInvalidArgument) {
+		t.Errorf("expected ErrInvalidArgument, got %v", err)
+	}
+
+	plain := New(&MockStore{data: map[string]string{}}, consensus, ConsistencyEventual)
+	if _, err := plain.TTL(ctx, "k"); !errors.Is(err, coreerrors.ErrUnsupported) {
+		t.Errorf("expected ErrUnsupported, got %v", err)
+	}
+}
+
+func TestService_TTLJitter(t *testing.T) {
+	consensus := &resultConsensus{}
+	svc := New(&MockStore{data: map[string]string{}}, consensus, ConsistencyEventual, WithTTLJitter(0.1))
+	ctx := context.Background()
+
+	const ttl = 100 * time.Second
+	expiries := make(map[time.Duration]bool)
+	for i := 0; i < 50; i++ {
+		before := time.Now()
+		if err := svc.Set(ctx, "k", "v", ttl); err != nil {
+			t.Fatal(err)
+		}
+		// The jittered expiration is stamped; the TTL is kept as requested.
+		got := consensus.last.Expiry().Sub(before)
+		if got < 90*time.Second || got > 110*time.Second+time.Since(before) || consensus.last.TTL != ttl {
+			t.Fatalf("expected an expiry within 10%% of %v, got %v (ttl %v)", ttl, got, consensus.last.TTL)
+		}
+		expiries[got.Round(time.Millisecond)] = true
+	}
+	if len(expiries) < 10 {
+		t.Errorf("expected spread expirations, got %d distinct values", len(expiries))
+	}
+
+	txn := ports.Txn{Success: []ports.TxnOp{{Type: ports.TxnSet, Key: "k", Value: "v", TTL: ttl}}}
+	before := time.Now()
+	if _, err := svc.Txn(ctx, txn); err != nil {
+		t.Fatal(err)
+	}
+	if got := consensus.last.Txn.Success[0].Expiry().Sub(before); got < 90*time.Second || got > 111*time.Second {
+		t.Errorf("expected transaction ops to be jittered too, got %v", got)
+	}
+}
+
+type followerConsensus struct {
+	resultConsensus
+}
+
+func (m *followerConsensus) IsLeader() bool { return false }
+
+func (m *followerConsensus) VerifyLeader() error { return coreerrors.ErrNotLeader }
+
+func TestService_PurgeExpired(t *testing.T) {
+	consensus := &resultConsensus{result: ApplyResult{Count: 2}}
+	st := store.New()
+	st.SetExpiresAt("a", "v", time.Now().Add(-time.Second))
+	st.SetExpiresAt("b", "v", time.Now().Add(-time.Second))
+	st.Set("live", "v", time.Minute)
+	st.Set("forever", "v", 0)
+	svc := New(st, consensus, ConsistencyEventual)
+	ctx := context.Background()
+
+	// The leader submits the expired keys with its clock; the FSM deletes them.
+	before := time.Now()
+	n, err := svc.PurgeExpired(ctx)
+	if err != nil || n != 2 {
+		t.Fatalf("expected 2 keys purged, got %d (%v)", n, err)
+	}
+	keys := append([]string(nil), consensus.last.Keys...)
+	sort.Strings(keys)
+	if consensus.last.Op != PurgeOp || strings.Join(keys, ",") != "a,b" ||
+		consensus.last.ExpiresAt < before.UnixNano() || consensus.last.ExpiresAt > time.Now().UnixNano() {
+		t.Errorf("unexpected command %+v", consensus.last)
+	}
+
+	// Followers leave purging to the leader.
+	follower := &followerConsensus{}
+	if n, err := New(st, follower, ConsistencyEventual).PurgeExpired(ctx); err != nil || n != 0 || follower.last.Op != "" {
+		t.Errorf("expected no purge on a follower, got %d %+v (%v)", n, follower.last, err)
+	}
+}
+
+func TestService_EvictOverflow(t *testing.T) {
+	consensus := &resultConsensus{result: ApplyResult{Count: 1}}
+	st := store.New(store.WithCapacity(2), store.WithDeferredEviction())
+	svc := New(st, consensus, ConsistencyEventual)
+	ctx := context.Background()
+
+	st.Set("a", "v", 0)
+	st.Set("b", "v", 0)
+	if n, err := svc.EvictOverflow(ctx); err != nil || n != 0 || consensus.last.Op != "" {
+		t.Fatalf("expected nothing to evict, got %d %+v (%v)", n, consensus.last, err)
+	}
+
+	// The leader replicates the victims its policy chose.
+	st.Set("c", "v", 0)
+	n, err := svc.EvictOverflow(ctx)
+	if err != nil || n != 1 {
+		t.Fatalf("expected 1 key evicted, got %d (%v)", n, err)
+	}
+	if consensus.last.Op != EvictOp || strings.Join(consensus.last.Keys, ",") != "a" {
+		t.Errorf("unexpected command %+v", consensus.last)
+	}
+
+	follower := &followerConsensus{}
+	if n, err := New(st, follower, ConsistencyEventual).EvictOverflow(ctx); err != nil || n != 0 || follower.last.Op != "" {
+		t.Errorf("expected no eviction on a follower, got %d %+v (%v)", n, follower.last, err)
+	}
+}
+
+type lagConsensus struct {
+	MockConsensus
+	lag uint64
+	err error
+}
+
+func (m *lagConsensus) ReplicationLag() (uint64, error) { return m.lag, m.err }
+
+func TestService_MaxLag(t *testing.T) {
+	st := &MockStore{data: map[string]string{"k": EncodeVersion(1, "v")}}
+	consensus := &lagConsensus{lag: 10}
+	ctx := context.Background()
+
+	// Without a bound, and under strong consistency, lag does not matter.
+	if _, err := New(st, consensus, ConsistencyEventual).Get(ctx, "k"); err != nil {
+		t.Errorf("expected unbounded read to succeed, got %v", err)
+	}
+	if _, err := New(st, consensus, ConsistencyStrong, WithMaxLag(5)).Get(ctx, "k"); err != nil {
+		t.Errorf("expected strong read to succeed, got %v", err)
+	}
+
+	svc := New(st, consensus, ConsistencyEventual, WithMaxLag(10))
+	if _, err := svc.Get(ctx, "k"); err != nil {
+		t.Errorf("expected read within the bound to succeed, got %v", err)
+	}
+	consensus.lag = 11
+	if _, err := svc.Get(ctx, "k"); !errors.Is(err, coreerrors.ErrStaleRead) {
+		t.Errorf("expected ErrStaleRead, got %v", err)
+	}
+	consensus.lag, consensus.err = 0, fmt.Errorf("%w: no known leader", coreerrors.ErrStaleRead)
+	if _, err := svc.TTL(ctx, "k"); !errors.Is(err, coreerrors.ErrStaleRead) {
+		t.Errorf("expected ErrStaleRead, got %v", err)
+	}
+}
+
+func TestService_ConsistencyOverride(t *testing.T) {
+	st := &MockStore{data: map[string]string{"k": EncodeVersion(1, "v")}}
+	ctx := context.Background()
+	strong := ContextWithConsistency(ctx, ConsistencyStrong)
+	eventual := ContextWithConsistency(ctx, ConsistencyEventual)
+
+	// On a follower, strong reads fail unless the request asks for eventual.
+	follower := New(st, &followerConsensus{}, ConsistencyStrong)
+	if _, err := follower.Get(ctx, "k"); !errors.Is(err, coreerrors.ErrNotLeader) {
+		t.Errorf("expected ErrNotLeader, got %v", err)
+	}
+	if v, err := follower.Get(eventual, "k"); err != nil || v != "v" {
+		t.Errorf("expected an eventual read to succeed, got %q (%v)", v, err)
+	}
+
+	follower = New(st, &followerConsensus{}, ConsistencyEventual)
+	if _, err := follower.Get(strong, "k"); !errors.Is(err, coreerrors.ErrNotLeader) {
+		t.Errorf("expected a strong read to fail, got %v", err)
+	}
+
+	if mode, err := ParseConsistencyMode("Eventual"); err != nil || mode != ConsistencyEventual {
+		t.Errorf("expected eventual, got %q (%v)", mode, err)
+	}
+	if _, err := ParseConsistencyMode("linearizable"); !errors.Is(err, coreerrors.ErrInvalidArgument) {
+		t.Errorf("expected ErrInvalidArgument, got %v", err)
+	}
+}
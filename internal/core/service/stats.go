@@ -0,0 +1,36 @@
+package service
+
+import (
+	"distributed-cache-service/internal/core/ports"
+	"distributed-cache-service/internal/observability"
+)
+
+// NodeStats returns this node's role, key count, read counters and
+// replication progress, as far as its store and consensus can tell them.
+func (s *ServiceImpl) NodeStats() ports.NodeStats {
+	st := ports.NodeStats{
+		Keys:   int64(s.store.Len()),
+		Hits:   uint64(observability.CounterValue(observability.CacheHitsTotal)),
+		Misses: uint64(observability.CounterValue(observability.CacheMissesTotal)),
+	}
+	if c, ok := s.store.(interface{ Capacity() int }); ok {
+		st.Capacity = int64(c.Capacity())
+	}
+	if c, ok := s.consensus.(interface {
+		State() string
+		Leader() string
+	}); ok {
+		st.State, st.LeaderAddr = c.State(), c.Leader()
+	}
+	if pr, ok := s.consensus.(ports.PositionReporter); ok {
+		st.AppliedIndex, _ = pr.AppliedPosition()
+	}
+	if lr, ok := s.consensus.(ports.LagReporter); ok {
+		lag, err := lr.ReplicationLag()
+		if err != nil {
+			st.ReplicationLagError = err.Error()
+		}
+		st.ReplicationLag = lag
+	}
+	return st
+}
@@ -0,0 +1,49 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	coreerrors "distributed-cache-service/internal/core/errors"
+	"distributed-cache-service/internal/store"
+)
+
+type statsConsensus struct {
+	lagConsensus
+	applied uint64
+}
+
+func (m *statsConsensus) State() string  { return "Follower" }
+func (m *statsConsensus) Leader() string { return "10.0.0.1:7000" }
+func (m *statsConsensus) AppliedPosition() (uint64, uint64) {
+	return m.applied, 2
+}
+
+func TestService_NodeStats(t *testing.T) {
+	st := store.New(store.WithCapacity(10))
+	st.Set("k", EncodeVersion(1, "v"), 0)
+	consensus := &statsConsensus{lagConsensus: lagConsensus{lag: 3}, applied: 42}
+	svc := New(st, consensus, ConsistencyEventual)
+	ctx := context.Background()
+
+	// The read counters are process-wide, so only their growth is checked.
+	before := svc.NodeStats()
+	svc.Get(ctx, "k")
+	svc.Get(ctx, "missing")
+	stats := svc.NodeStats()
+	if stats.Hits-before.Hits != 1 || stats.Misses-before.Misses != 1 {
+		t.Errorf("expected a hit and a miss, got %+v then %+v", before, stats)
+	}
+	if stats.Keys != 1 || stats.Capacity != 10 {
+		t.Errorf("expected 1 of 10 keys, got %d of %d", stats.Keys, stats.Capacity)
+	}
+	if stats.State != "Follower" || stats.LeaderAddr != "10.0.0.1:7000" || stats.AppliedIndex != 42 || stats.ReplicationLag != 3 {
+		t.Errorf("unexpected stats %+v", stats)
+	}
+
+	consensus.err = fmt.Errorf("%w: no known leader", coreerrors.ErrStaleRead)
+	if stats := svc.NodeStats(); stats.ReplicationLagError == "" {
+		t.Error("expected the lag error to be reported")
+	}
+}
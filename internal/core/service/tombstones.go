@@ -0,0 +1,71 @@
+package service
+
+import (
+	"context"
+	"time"
+)
+
+// WithTombstoneRetention makes deletes leave a tombstone, kept for retention,
+// that refuses writes made before the delete (see ContextWithOriginTime). It
+// keeps a write copied from another cluster, or replayed by a sink, from
+// resurrecting a key deleted after it was made, provided it arrives within
+// retention. The leader stamps each delete with its clock and drops expired
+// tombstones through Raft (see PurgeTombstones). 0 keeps no tombstones and
+// ignores origin times. Tombstones need cluster version
+// CommandVersionTombstones; deletes made before leave none.
+func WithTombstoneRetention(retention time.Duration) Option {
+	return func(s *ServiceImpl) {
+		s.tombstoneRetention = retention
+	}
+}
+
+type originTimeKey struct{}
+
+// ContextWithOriginTime marks the sets and deletes made with ctx as having
+// first been made at t, elsewhere: a set is refused with ErrVersionMismatch
+// if the key was deleted at or after t, and a delete's tombstone is dated t.
+// Writes without an origin time are made now, and are never refused.
+func ContextWithOriginTime(ctx context.Context, t time.Time) context.Context {
+	return context.WithValue(ctx, originTimeKey{}, t)
+}
+
+// OriginTimeFromContext returns the origin time attached to ctx, if any.
+func OriginTimeFromContext(ctx context.Context) (time.Time, bool) {
+	t, ok := ctx.Value(originTimeKey{}).(time.Time)
+	return t, ok
+}
+
+// stampOrigin sets the OriginTime of a set or delete made with ctx when
+// tombstones are kept. Deletes without one are dated now, on the leader's
+// clock, once the cluster can replicate them.
+func (s *ServiceImpl) stampOrigin(ctx context.Context, cmd *Command, now time.Time, active uint32) {
+	if s.tombstoneRetention <= 0 {
+		return
+	}
+	switch cmd.Op {
+	case SetOp, GetSetOp, GetOrSetOp, DeleteOp, GetDelOp:
+	default:
+		return
+	}
+	if t, ok := OriginTimeFromContext(ctx); ok {
+		cmd.OriginTime = t.UnixNano()
+	} else if (cmd.Op == DeleteOp || cmd.Op == GetDelOp) && active >= CommandVersionTombstones {
+		cmd.OriginTime = now.UnixNano()
+	}
+}
+
+// PurgeTombstones drops, through Raft, the tombstones of deletes made more
+// than the retention ago (see WithTombstoneRetention), and returns how many
+// it dropped. It only does something on the leader, and StartPurge calls it
+// along with PurgeExpired.
+func (s *ServiceImpl) PurgeTombstones(ctx context.Context) (int, error) {
+	if s.tombstoneRetention <= 0 || !s.consensus.IsLeader() || s.ClusterVersion() < CommandVersionTombstones {
+		return 0, nil
+	}
+	cutoff := time.Now().Add(-s.tombstoneRetention)
+	result, err := s.replicate(ctx, "tombstonegc", Command{Op: TombstoneGCOp, OriginTime: cutoff.UnixNano()})
+	if err != nil {
+		return 0, err
+	}
+	return result.Count, nil
+}
@@ -0,0 +1,62 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	coreerrors "distributed-cache-service/internal/core/errors"
+)
+
+func TestService_TombstoneOriginTimes(t *testing.T) {
+	cluster := &versionedConsensus{version: CommandVersionBatch}
+	svc := New(&MockStore{data: map[string]string{}}, cluster, ConsistencyEventual, WithTombstoneRetention(time.Hour))
+	ctx := context.Background()
+	origin := time.Unix(1700000000, 0)
+	copied := ContextWithOriginTime(ctx, origin)
+
+	// Before the cluster can replicate tombstones, deletes leave none, and
+	// writes that need them are refused.
+	if err := svc.Delete(ctx, "k"); err != nil || cluster.last.OriginTime != 0 {
+		t.Fatalf("expected a delete without origin time, got %+v (%v)", cluster.last, err)
+	}
+	if err := svc.Set(copied, "k", "v", 0); !errors.Is(err, coreerrors.ErrUnsupported) {
+		t.Errorf("expected ErrUnsupported before cluster version %d, got %v", CommandVersionTombstones, err)
+	}
+	if n, err := svc.PurgeTombstones(ctx); err != nil || n != 0 || cluster.last.Op != DeleteOp {
+		t.Errorf("expected no tombstone GC, got %d %+v (%v)", n, cluster.last, err)
+	}
+
+	cluster.version = CommandVersionTombstones
+	before := time.Now()
+	if err := svc.Delete(ctx, "k"); err != nil {
+		t.Fatal(err)
+	}
+	if cluster.last.OriginTime < before.UnixNano() || cluster.last.OriginTime > time.Now().UnixNano() {
+		t.Errorf("expected a delete dated by the leader's clock, got %+v", cluster.last)
+	}
+	if err := svc.Set(copied, "k", "v", 0); err != nil || cluster.last.OriginTime != origin.UnixNano() {
+		t.Errorf("expected a set with origin time %d, got %+v (%v)", origin.UnixNano(), cluster.last, err)
+	}
+	if err := svc.Set(ctx, "k", "v", 0); err != nil || cluster.last.OriginTime != 0 {
+		t.Errorf("expected a local set without origin time, got %+v (%v)", cluster.last, err)
+	}
+
+	// The leader drops tombstones older than the retention.
+	if _, err := svc.PurgeTombstones(ctx); err != nil {
+		t.Fatal(err)
+	}
+	if cutoff := time.Now().Add(-time.Hour).UnixNano(); cluster.last.Op != TombstoneGCOp || cluster.last.OriginTime > cutoff {
+		t.Errorf("expected a tombstone GC up to %d, got %+v", cutoff, cluster.last)
+	}
+
+	// Without a retention, origin times are ignored.
+	svc = New(&MockStore{data: map[string]string{}}, cluster, ConsistencyEventual)
+	if err := svc.Set(copied, "k", "v", 0); err != nil || cluster.last.OriginTime != 0 {
+		t.Errorf("expected origin time to be ignored, got %+v (%v)", cluster.last, err)
+	}
+	if err := svc.Delete(ctx, "k"); err != nil || cluster.last.OriginTime != 0 {
+		t.Errorf("expected a delete without tombstone, got %+v (%v)", cluster.last, err)
+	}
+}
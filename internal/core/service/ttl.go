@@ -0,0 +1,111 @@
+package service
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	coreerrors "distributed-cache-service/internal/core/errors"
+)
+
+// TTLLimits bounds how long keys live, whatever TTL clients ask for. Default
+// and Max map key prefixes (namespaces) to durations; the longest matching
+// prefix applies, and "" matches every key.
+type TTLLimits struct {
+	// Default is the TTL of writes that do not give one. 0 keeps them
+	// persistent, unless Max applies.
+	Default map[string]time.Duration
+	// Max is the longest TTL a write may set. 0 means unbounded. Writes without
+	// a TTL and no Default count as over it, and PERSIST is refused.
+	Max map[string]time.Duration
+	// Reject fails writes over Max with ErrInvalidArgument instead of lowering
+	// their TTL to Max.
+	Reject bool
+}
+
+// WithTTLLimits applies limits to the TTLs of SET, GETSET, GETORSET, EXPIRE
+// and PERSIST, including the sets of transactions. Keys created by APPEND,
+// ZADD and scripts are not limited. The leader applies the limits before
+// replicating, so changing them does not affect keys already written.
+func WithTTLLimits(limits TTLLimits) Option {
+	return func(s *ServiceImpl) {
+		s.ttlLimits = limits
+	}
+}
+
+// ParseTTLs parses a comma-separated list of [prefix=]duration, such as
+// "1h,session:=30m", for TTLLimits. A duration without a prefix applies to
+// every key.
+func ParseTTLs(spec string) (map[string]time.Duration, error) {
+	ttls := make(map[string]time.Duration)
+	err := parsePrefixed(spec, func(prefix, value string) error {
+		ttl, err := time.ParseDuration(value)
+		if err != nil || ttl < 0 {
+			return fmt.Errorf("%w: invalid TTL %q", coreerrors.ErrInvalidArgument, value)
+		}
+		ttls[prefix] = ttl
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return ttls, nil
+}
+
+// longestPrefix returns the value of the longest prefix of key in m, or the
+// zero value if none matches.
+func longestPrefix[V any](m map[string]V, key string) V {
+	var value V
+	longest := -1
+	for prefix, v := range m {
+		if len(prefix) > longest && strings.HasPrefix(key, prefix) {
+			value, longest = v, len(prefix)
+		}
+	}
+	return value
+}
+
+// limitTTL applies the TTL limits to cmd and the sets of a transaction.
+func (s *ServiceImpl) limitTTL(cmd *Command) error {
+	switch cmd.Op {
+	case SetOp, GetSetOp, GetOrSetOp:
+		if cmd.TTL == 0 {
+			cmd.TTL = longestPrefix(s.ttlLimits.Default, cmd.Key)
+		}
+		return s.capTTL(cmd)
+	case ExpireOp:
+		return s.capTTL(cmd)
+	case PersistOp:
+		if limit := longestPrefix(s.ttlLimits.Max, cmd.Key); limit > 0 {
+			return fmt.Errorf("%w: key %q may live at most %v and cannot be persisted", coreerrors.ErrInvalidArgument, cmd.Key, limit)
+		}
+	case TxnOp:
+		if cmd.Txn == nil {
+			return nil
+		}
+		for _, ops := range [][]Command{cmd.Txn.Success, cmd.Txn.Failure} {
+			for i := range ops {
+				if err := s.limitTTL(&ops[i]); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// capTTL lowers cmd's TTL to the maximum for its key, or rejects it.
+func (s *ServiceImpl) capTTL(cmd *Command) error {
+	limit := longestPrefix(s.ttlLimits.Max, cmd.Key)
+	if limit == 0 || (cmd.TTL > 0 && cmd.TTL <= limit) {
+		return nil
+	}
+	if s.ttlLimits.Reject {
+		if cmd.TTL == 0 {
+			return fmt.Errorf("%w: key %q needs a TTL of at most %v", coreerrors.ErrInvalidArgument, cmd.Key, limit)
+		}
+		return fmt.Errorf("%w: TTL %v of key %q exceeds the limit of %v", coreerrors.ErrInvalidArgument, cmd.TTL, cmd.Key, limit)
+	}
+	cmd.TTL = limit
+	return nil
+}
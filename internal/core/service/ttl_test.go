@@ -0,0 +1,105 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	coreerrors "distributed-cache-service/internal/core/errors"
+	"distributed-cache-service/internal/core/ports"
+)
+
+func TestParseTTLs(t *testing.T) {
+	ttls, err := ParseTTLs("1h, session:=30m,tmp:=0s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]time.Duration{"": time.Hour, "session:": 30 * time.Minute, "tmp:": 0}
+	if !reflect.DeepEqual(ttls, want) {
+		t.Errorf("got %v, want %v", ttls, want)
+	}
+	for _, spec := range []string{"forever", "user:=-1m", "a=1"} {
+		if _, err := ParseTTLs(spec); !errors.Is(err, coreerrors.ErrInvalidArgument) {
+			t.Errorf("expected ErrInvalidArgument for %q, got %v", spec, err)
+		}
+	}
+}
+
+func TestService_TTLLimits(t *testing.T) {
+	consensus := &resultConsensus{}
+	svc := New(&MockStore{data: map[string]string{}}, consensus, ConsistencyEventual, WithTTLLimits(TTLLimits{
+		Default: map[string]time.Duration{"": time.Hour, "session:": time.Minute},
+		Max:     map[string]time.Duration{"": 24 * time.Hour, "session:": 10 * time.Minute, "ref:": 0},
+	}))
+	ctx := context.Background()
+
+	tests := []struct {
+		key  string
+		ttl  time.Duration
+		want time.Duration
+	}{
+		{"k", 0, time.Hour},
+		{"k", time.Second, time.Second},
+		{"k", 48 * time.Hour, 24 * time.Hour},
+		{"session:1", 0, time.Minute},
+		{"session:1", time.Hour, 10 * time.Minute},
+		{"ref:1", 0, time.Hour},
+		{"ref:1", 48 * time.Hour, 48 * time.Hour},
+	}
+	for _, tt := range tests {
+		if err := svc.Set(ctx, tt.key, "v", tt.ttl); err != nil {
+			t.Fatal(err)
+		}
+		if consensus.last.TTL != tt.want {
+			t.Errorf("Set(%s, %v): expected TTL %v, got %v", tt.key, tt.ttl, tt.want, consensus.last.TTL)
+		}
+	}
+
+	if _, _, err := svc.GetOrSet(ctx, "session:2", "v", 0); err != nil || consensus.last.TTL != time.Minute {
+		t.Errorf("expected GetOrSet to get the default TTL, got %v (%v)", consensus.last.TTL, err)
+	}
+	if err := svc.Expire(ctx, "k", 48*time.Hour); err != nil || consensus.last.TTL != 24*time.Hour {
+		t.Errorf("expected Expire to be capped, got %v (%v)", consensus.last.TTL, err)
+	}
+	txn := ports.Txn{Success: []ports.TxnOp{{Type: ports.TxnSet, Key: "session:3", Value: "v"}}}
+	if _, err := svc.Txn(ctx, txn); err != nil || consensus.last.Txn.Success[0].TTL != time.Minute {
+		t.Errorf("expected transaction sets to get the default TTL, got %+v (%v)", consensus.last.Txn.Success[0], err)
+	}
+
+	// Persisting would outlive the maximum, except where there is none.
+	if err := svc.Persist(ctx, "k"); !errors.Is(err, coreerrors.ErrInvalidArgument) {
+		t.Errorf("expected ErrInvalidArgument, got %v", err)
+	}
+	if err := svc.Persist(ctx, "ref:1"); err != nil {
+		t.Errorf("expected persist without a maximum to succeed, got %v", err)
+	}
+}
+
+func TestService_TTLLimitsReject(t *testing.T) {
+	consensus := &resultConsensus{}
+	svc := New(&MockStore{data: map[string]string{}}, consensus, ConsistencyEventual, WithTTLLimits(TTLLimits{
+		Default: map[string]time.Duration{"session:": time.Minute},
+		Max:     map[string]time.Duration{"": time.Hour},
+		Reject:  true,
+	}))
+	ctx := context.Background()
+
+	for _, ttl := range []time.Duration{0, 2 * time.Hour} {
+		consensus.last = Command{}
+		if err := svc.Set(ctx, "k", "v", ttl); !errors.Is(err, coreerrors.ErrInvalidArgument) {
+			t.Errorf("Set with TTL %v: expected ErrInvalidArgument, got %v", ttl, err)
+		}
+		if consensus.last.Op != "" {
+			t.Errorf("expected nothing to be replicated, got %+v", consensus.last)
+		}
+	}
+	if err := svc.Set(ctx, "session:1", "v", 0); err != nil || consensus.last.TTL != time.Minute {
+		t.Errorf("expected the default TTL to be accepted, got %v (%v)", consensus.last.TTL, err)
+	}
+	txn := ports.Txn{Failure: []ports.TxnOp{{Type: ports.TxnSet, Key: "k", Value: "v", TTL: 2 * time.Hour}}}
+	if _, err := svc.Txn(ctx, txn); !errors.Is(err, coreerrors.ErrInvalidArgument) {
+		t.Errorf("expected the transaction to be rejected, got %v", err)
+	}
+}
@@ -0,0 +1,28 @@
+package service
+
+import "encoding/binary"
+
+// versionHeader prefixes every value written by the FSM, followed by the
+// key's version as 8 big-endian bytes and then the stored value itself.
+// Keeping the version inside the value means every storage backend, the AOF
+// and snapshots carry it without format changes.
+const versionHeader = "\x00dcv"
+
+const versionedLen = len(versionHeader) + 8
+
+// EncodeVersion wraps stored with its version for writing to the store.
+func EncodeVersion(version uint64, stored string) string {
+	buf := make([]byte, versionedLen, versionedLen+len(stored))
+	copy(buf, versionHeader)
+	binary.BigEndian.PutUint64(buf[len(versionHeader):], version)
+	return string(append(buf, stored...))
+}
+
+// DecodeVersion splits a value read from the store into its version and the
+// stored value. Values written before versioning existed have version 0.
+func DecodeVersion(raw string) (version uint64, stored string) {
+	if len(raw) < versionedLen || raw[:len(versionHeader)] != versionHeader {
+		return 0, raw
+	}
+	return binary.BigEndian.Uint64([]byte(raw[len(versionHeader):versionedLen])), raw[versionedLen:]
+}
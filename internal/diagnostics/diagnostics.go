@@ -0,0 +1,125 @@
+// Package diagnostics serves a node's runtime internals: pprof profiles,
+// expvar, goroutine dumps, GC statistics and store statistics. They reveal
+// keys, memory contents and load, so they belong on a listener of their own,
+// behind authentication, rather than on the public API.
+package diagnostics
+
+import (
+	"encoding/json"
+	"expvar"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/pprof"
+	"runtime"
+	rtdebug "runtime/debug"
+	rtpprof "runtime/pprof"
+	"time"
+)
+
+// Option configures the Handler.
+type Option func(*http.ServeMux)
+
+// WithStore serves the result of stats, encoded as JSON, at /debug/store.
+func WithStore(stats func() interface{}) Option {
+	return func(m *http.ServeMux) {
+		m.HandleFunc("/debug/store", func(w http.ResponseWriter, r *http.Request) {
+			writeJSON(w, stats())
+		})
+	}
+}
+
+// Handler returns the diagnostics endpoints:
+//
+//	/debug/pprof/      pprof profiles, as served by net/http/pprof
+//	/debug/vars        expvar variables
+//	/debug/goroutines  stack traces of all goroutines, as text
+//	/debug/gc          garbage collector and heap statistics
+//	/debug/heap        POST: collect garbage, then return a heap profile
+//	/debug/store       store statistics, see WithStore
+func Handler(opts ...Option) http.Handler {
+	m := http.NewServeMux()
+	m.HandleFunc("/debug/pprof/", pprof.Index)
+	m.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	m.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	m.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	m.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	m.Handle("/debug/vars", expvar.Handler())
+	m.HandleFunc("/debug/goroutines", goroutines)
+	m.HandleFunc("/debug/gc", gcStats)
+	m.HandleFunc("/debug/heap", heapProfile)
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
+
+func goroutines(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	if err := rtpprof.Lookup("goroutine").WriteTo(w, 2); err != nil {
+		log.Printf("Failed to write goroutine dump: %v", err)
+	}
+}
+
+// GCStats is the body of /debug/gc.
+type GCStats struct {
+	NumGC         int64           `json:"num_gc"`
+	LastGC        time.Time       `json:"last_gc"`
+	PauseTotal    time.Duration   `json:"pause_total_ns"`
+	RecentPauses  []time.Duration `json:"recent_pauses_ns"`
+	HeapAlloc     uint64          `json:"heap_alloc_bytes"`
+	HeapInuse     uint64          `json:"heap_inuse_bytes"`
+	HeapObjects   uint64          `json:"heap_objects"`
+	NextGC        uint64          `json:"next_gc_bytes"`
+	Sys           uint64          `json:"sys_bytes"`
+	GCCPUFraction float64         `json:"gc_cpu_fraction"`
+	Goroutines    int             `json:"goroutines"`
+}
+
+// recentPauses bounds the pauses /debug/gc reports, most recent first.
+const recentPauses = 16
+
+func gcStats(w http.ResponseWriter, r *http.Request) {
+	var gc rtdebug.GCStats
+	rtdebug.ReadGCStats(&gc)
+	var mem runtime.MemStats
+	runtime.ReadMemStats(&mem)
+	if len(gc.Pause) > recentPauses {
+		gc.Pause = gc.Pause[:recentPauses]
+	}
+	writeJSON(w, GCStats{
+		NumGC:         gc.NumGC,
+		LastGC:        gc.LastGC,
+		PauseTotal:    gc.PauseTotal,
+		RecentPauses:  gc.Pause,
+		HeapAlloc:     mem.HeapAlloc,
+		HeapInuse:     mem.HeapInuse,
+		HeapObjects:   mem.HeapObjects,
+		NextGC:        mem.NextGC,
+		Sys:           mem.Sys,
+		GCCPUFraction: mem.GCCPUFraction,
+		Goroutines:    runtime.NumGoroutine(),
+	})
+}
+
+// heapProfile collects garbage so the profile shows only live objects, then
+// returns it as a download for go tool pprof.
+func heapProfile(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	runtime.GC()
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="heap-%d.pb.gz"`, time.Now().Unix()))
+	if err := rtpprof.Lookup("heap").WriteTo(w, 0); err != nil {
+		log.Printf("Failed to write heap profile: %v", err)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("Failed to write response: %v", err)
+	}
+}
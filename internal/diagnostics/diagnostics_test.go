@@ -0,0 +1,56 @@
+package diagnostics
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandler(t *testing.T) {
+	h := Handler(WithStore(func() interface{} { return map[string]int{"items": 3} }))
+	get := func(method, path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(method, path, nil))
+		return rec
+	}
+
+	rec := get(http.MethodGet, "/debug/pprof/")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "goroutine")
+
+	rec = get(http.MethodGet, "/debug/vars")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "memstats")
+
+	rec = get(http.MethodGet, "/debug/goroutines")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.True(t, strings.HasPrefix(rec.Body.String(), "goroutine "), rec.Body.String())
+
+	rec = get(http.MethodGet, "/debug/gc")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var gc GCStats
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&gc))
+	assert.Positive(t, gc.Goroutines)
+	assert.Positive(t, gc.HeapAlloc)
+
+	assert.Equal(t, http.StatusMethodNotAllowed, get(http.MethodGet, "/debug/heap").Code)
+	rec = get(http.MethodPost, "/debug/heap")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Header().Get("Content-Disposition"), "heap-")
+	assert.NotZero(t, rec.Body.Len())
+
+	rec = get(http.MethodGet, "/debug/store")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"items":3}`, rec.Body.String())
+}
+
+func TestHandler_WithoutStore(t *testing.T) {
+	rec := httptest.NewRecorder()
+	Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/store", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
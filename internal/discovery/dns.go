@@ -0,0 +1,110 @@
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Resolver looks up DNS records. *net.Resolver implements it.
+type Resolver interface {
+	LookupHost(ctx context.Context, host string) ([]string, error)
+	LookupSRV(ctx context.Context, service, proto, name string) (string, []*net.SRV, error)
+}
+
+// DNS discovers peers from the records of a DNS name, such as those of a
+// Kubernetes headless service.
+type DNS struct {
+	name     string
+	srv      bool
+	port     string
+	resolver Resolver
+}
+
+// Option configures a DNS discoverer.
+type Option func(*DNS)
+
+// WithResolver replaces the system resolver.
+func WithResolver(r Resolver) Option {
+	return func(d *DNS) {
+		d.resolver = r
+	}
+}
+
+// Parse parses a discovery spec. "dns:<name>" resolves the A and AAAA records
+// of name and pairs each address with port, the HTTP port every node listens
+// on. "srv:<name>" resolves the SRV records of name, e.g.
+// _http._tcp.cache-headless.default.svc, which carry the port themselves.
+func Parse(spec, port string, opts ...Option) (*DNS, error) {
+	kind, name, ok := strings.Cut(spec, ":")
+	if !ok || name == "" {
+		return nil, fmt.Errorf("invalid discovery %q: want dns:<name> or srv:<name>", spec)
+	}
+	d := &DNS{name: name, resolver: net.DefaultResolver}
+	switch kind {
+	case "dns":
+		if port == "" {
+			return nil, fmt.Errorf("dns discovery needs the HTTP port")
+		}
+		d.port = port
+	case "srv":
+		d.srv = true
+	default:
+		return nil, fmt.Errorf("unknown discovery mode %q: want dns or srv", kind)
+	}
+	for _, opt := range opts {
+		opt(d)
+	}
+	return d, nil
+}
+
+func (d *DNS) String() string {
+	if d.srv {
+		return "srv:" + d.name
+	}
+	return "dns:" + d.name
+}
+
+// Peers returns the HTTP addresses, as ip:port, of the nodes the records name,
+// sorted so that every node sees them in the same order.
+func (d *DNS) Peers(ctx context.Context) ([]string, error) {
+	var peers []string
+	if d.srv {
+		_, records, err := d.resolver.LookupSRV(ctx, "", "", d.name)
+		if err != nil {
+			return nil, err
+		}
+		for _, rec := range records {
+			hosts, err := d.resolver.LookupHost(ctx, strings.TrimSuffix(rec.Target, "."))
+			if err != nil {
+				return nil, err
+			}
+			for _, h := range hosts {
+				peers = append(peers, net.JoinHostPort(h, strconv.Itoa(int(rec.Port))))
+			}
+		}
+	} else {
+		hosts, err := d.resolver.LookupHost(ctx, d.name)
+		if err != nil {
+			return nil, err
+		}
+		for _, h := range hosts {
+			peers = append(peers, net.JoinHostPort(h, d.port))
+		}
+	}
+	sort.Strings(peers)
+	return dedup(peers), nil
+}
+
+func dedup(sorted []string) []string {
+	out := sorted[:0]
+	for i, s := range sorted {
+		if i == 0 || s != sorted[i-1] {
+			out = append(out, s)
+		}
+	}
+	return out
+}
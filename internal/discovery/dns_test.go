@@ -0,0 +1,68 @@
+package discovery
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeResolver struct {
+	hosts map[string][]string
+	srv   map[string][]*net.SRV
+}
+
+func (f *fakeResolver) LookupHost(_ context.Context, host string) ([]string, error) {
+	if addrs, ok := f.hosts[host]; ok {
+		return addrs, nil
+	}
+	return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+}
+
+func (f *fakeResolver) LookupSRV(_ context.Context, _, _, name string) (string, []*net.SRV, error) {
+	if recs, ok := f.srv[name]; ok {
+		return name, recs, nil
+	}
+	return "", nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+}
+
+func TestParse(t *testing.T) {
+	_, err := Parse("dns:cache-headless", "8080")
+	assert.NoError(t, err)
+	_, err = Parse("srv:_http._tcp.cache-headless", "")
+	assert.NoError(t, err)
+
+	for _, spec := range []string{"cache-headless", "dns:", "consul:cache"} {
+		_, err := Parse(spec, "8080")
+		assert.Error(t, err, spec)
+	}
+	_, err = Parse("dns:cache-headless", "")
+	assert.Error(t, err, "dns discovery needs a port")
+}
+
+func TestDNS_Peers(t *testing.T) {
+	r := &fakeResolver{
+		hosts: map[string][]string{
+			"cache-headless":   {"10.0.0.9", "10.0.0.10", "10.0.0.9"},
+			"cache-0.headless": {"10.0.0.3"},
+			"cache-1.headless": {"10.0.0.2"},
+		},
+		srv: map[string][]*net.SRV{
+			"_http._tcp.headless": {{Target: "cache-0.headless.", Port: 8080}, {Target: "cache-1.headless.", Port: 8080}},
+		},
+	}
+
+	d, err := Parse("dns:cache-headless", "8080", WithResolver(r))
+	require.NoError(t, err)
+	peers, err := d.Peers(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"10.0.0.10:8080", "10.0.0.9:8080"}, peers, "sorted and deduplicated")
+
+	d, err = Parse("srv:_http._tcp.headless", "", WithResolver(r))
+	require.NoError(t, err)
+	peers, err = d.Peers(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, []string{"10.0.0.2:8080", "10.0.0.3:8080"}, peers)
+}
@@ -0,0 +1,124 @@
+// Package discovery forms a cluster from nodes that find each other, through
+// DNS or a static list, so that no node has to be started with -bootstrap and
+// the others pointed at it with -join.
+package discovery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sort"
+	"time"
+)
+
+// ErrNoCluster is returned by a Formation's Join when every peer answered
+// that it is not a member of any cluster yet.
+var ErrNoCluster = errors.New("no peer is a cluster member")
+
+// Source lists the HTTP addresses of a node's peers, the node itself included.
+type Source interface {
+	Peers(ctx context.Context) ([]string, error)
+}
+
+// Static is a fixed list of peers.
+type Static []string
+
+func (s Static) Peers(context.Context) ([]string, error) {
+	return s, nil
+}
+
+// Member identifies a node of the initial cluster.
+type Member struct {
+	ID       string `json:"id"`
+	RaftAddr string `json:"raft_addr"`
+}
+
+// Formation tells Form how a node without Raft state becomes a member.
+type Formation struct {
+	// Self is this node's ID.
+	Self string
+	// Expect is how many peers must be found before a cluster is bootstrapped.
+	Expect int
+	// Interval is how long to wait between attempts.
+	Interval time.Duration
+	// Join asks the given peers to add this node, succeeding once the leader
+	// does. It returns ErrNoCluster if none of them belongs to a cluster.
+	Join func(peers []string) error
+	// Identify asks a peer for its node ID and Raft address.
+	Identify func(peer string) (Member, error)
+	// Bootstrap starts a cluster whose initial voters are members.
+	Bootstrap func(members []Member) error
+}
+
+// Form makes this node a member of the cluster formed by the peers src finds.
+// It first tries to join an existing cluster through them. If every peer
+// answers that there is none and at least Expect peers are found, the peer
+// with the lowest node ID bootstraps a cluster with all of them as voters; the
+// others learn they are members from the leader that they elect. A peer that
+// is unreachable or a member without a leader holds bootstrapping back, so a
+// node that restarts with no state, even during an election, joins the
+// running cluster rather than starting a second one. Form retries until ctx
+// is done.
+func Form(ctx context.Context, src Source, f Formation) error {
+	interval := f.Interval
+	if interval <= 0 {
+		interval = 2 * time.Second
+	}
+	for {
+		done, err := formOnce(ctx, src, f)
+		if done {
+			return err
+		}
+		if err != nil {
+			log.Printf("Waiting for a cluster to join: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(interval):
+		}
+	}
+}
+
+// formOnce makes one attempt, reporting whether Form is done.
+func formOnce(ctx context.Context, src Source, f Formation) (bool, error) {
+	peers, err := src.Peers(ctx)
+	if err != nil {
+		return false, err
+	}
+	if err := f.Join(peers); err == nil {
+		return true, nil
+	} else if !errors.Is(err, ErrNoCluster) {
+		return false, err
+	}
+	if len(peers) < max(f.Expect, 1) {
+		return false, fmt.Errorf("found %d of %d expected peers", len(peers), f.Expect)
+	}
+
+	members := make([]Member, 0, len(peers))
+	seen := make(map[string]bool)
+	for _, p := range peers {
+		m, err := f.Identify(p)
+		if err != nil {
+			return false, err
+		}
+		if !seen[m.ID] {
+			seen[m.ID] = true
+			members = append(members, m)
+		}
+	}
+	sort.Slice(members, func(i, j int) bool { return members[i].ID < members[j].ID })
+	switch {
+	case len(members) < max(f.Expect, 1):
+		return false, fmt.Errorf("found %d of %d expected nodes", len(members), f.Expect)
+	case !seen[f.Self]:
+		return false, errors.New("this node is not among its peers")
+	case members[0].ID != f.Self:
+		// The lowest node bootstraps; this one becomes a member once it
+		// hears from the elected leader, and its next Join succeeds.
+		return false, fmt.Errorf("waiting for %s to bootstrap the cluster", members[0].ID)
+	}
+	log.Printf("Bootstrapping the cluster with %d voters", len(members))
+	return true, f.Bootstrap(members)
+}
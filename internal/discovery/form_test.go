@@ -0,0 +1,82 @@
+package discovery
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestForm(t *testing.T) {
+	peers := Static{"c:8080", "a:8080", "b:8080"}
+	ids := map[string]string{"a:8080": "node2", "b:8080": "node1", "c:8080": "node3"}
+	identify := func(peer string) (Member, error) {
+		return Member{ID: ids[peer], RaftAddr: peer[:1] + ":11000"}, nil
+	}
+	errNoLeader := errors.New("no leader")
+
+	t.Run("LowestIDBootstrapsAll", func(t *testing.T) {
+		var members []Member
+		err := Form(context.Background(), peers, Formation{
+			Self:      "node1",
+			Expect:    3,
+			Join:      func([]string) error { return ErrNoCluster },
+			Identify:  identify,
+			Bootstrap: func(m []Member) error { members = m; return nil },
+		})
+		require.NoError(t, err)
+		assert.Equal(t, []Member{{"node1", "b:11000"}, {"node2", "a:11000"}, {"node3", "c:11000"}}, members)
+	})
+
+	t.Run("OthersJoin", func(t *testing.T) {
+		attempts := 0
+		err := Form(context.Background(), peers, Formation{
+			Self:     "node2",
+			Expect:   3,
+			Interval: time.Millisecond,
+			Join: func([]string) error {
+				if attempts++; attempts < 3 {
+					return ErrNoCluster
+				}
+				return nil
+			},
+			Identify:  identify,
+			Bootstrap: func([]Member) error { t.Fatal("only the lowest node bootstraps"); return nil },
+		})
+		require.NoError(t, err)
+		assert.Equal(t, 3, attempts)
+	})
+
+	t.Run("ExistingClusterIsJoined", func(t *testing.T) {
+		err := Form(context.Background(), peers, Formation{
+			Self:      "node1",
+			Expect:    3,
+			Join:      func([]string) error { return nil },
+			Identify:  identify,
+			Bootstrap: func([]Member) error { t.Fatal("a running cluster is joined"); return nil },
+		})
+		require.NoError(t, err)
+	})
+
+	for name, f := range map[string]Formation{
+		"WaitsForExpectedPeers":              {Expect: 5, Join: func([]string) error { return ErrNoCluster }},
+		"MemberWithoutLeaderBlocksBootstrap": {Expect: 3, Join: func([]string) error { return errNoLeader }},
+		"UnidentifiedPeerBlocksBootstrap": {Expect: 3, Join: func([]string) error { return ErrNoCluster },
+			Identify: func(string) (Member, error) { return Member{}, errors.New("unreachable") }},
+	} {
+		t.Run(name, func(t *testing.T) {
+			ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+			defer cancel()
+			f.Self = "node1"
+			f.Interval = time.Millisecond
+			if f.Identify == nil {
+				f.Identify = identify
+			}
+			f.Bootstrap = func([]Member) error { t.Fatal("must not bootstrap"); return nil }
+			assert.ErrorIs(t, Form(ctx, peers, f), context.DeadlineExceeded)
+		})
+	}
+}
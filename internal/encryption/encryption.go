@@ -0,0 +1,189 @@
+// Package encryption implements encryption of cache values at rest.
+//
+// Values are sealed with AES-GCM by the leader before they are replicated, so
+// the Raft log, snapshots, backups and on-disk storage backends only ever hold
+// ciphertext. Sealed values carry the ID of their key, which lets keys be
+// rotated: new values use the current key while older ones stay readable for
+// as long as their key is still provided.
+//
+// Keys, sorted set members, scripts and their arguments, and the values of
+// transaction comparisons are not encrypted.
+package encryption
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"distributed-cache-service/internal/compression"
+)
+
+// header prefixes every sealed value. It is in the namespace of compression
+// headers, so raw values that happen to start with it are escaped like those.
+var header = compression.Header(compression.Encrypted)
+
+// KeyProvider supplies the data keys. It is the extension point for key
+// management services: an implementation can fetch keys from a KMS, or unwrap
+// them with a key held there. Keys are read once, when the Cipher is created.
+type KeyProvider interface {
+	// Keys returns AES keys (16, 24 or 32 bytes) by ID and the ID of the key
+	// new values are sealed with.
+	Keys(ctx context.Context) (current string, keys map[string][]byte, err error)
+}
+
+// StaticKeys returns a KeyProvider for keys already in memory.
+func StaticKeys(current string, keys map[string][]byte) KeyProvider {
+	return staticKeys{current, keys}
+}
+
+type staticKeys struct {
+	current string
+	keys    map[string][]byte
+}
+
+func (s staticKeys) Keys(context.Context) (string, map[string][]byte, error) {
+	return s.current, s.keys, nil
+}
+
+// EnvKeys returns a KeyProvider reading keys from the environment variable
+// name, as a comma-separated list of id:base64-key pairs. The first key is
+// the current one; the others are kept to read values sealed before a rotation.
+func EnvKeys(name string) KeyProvider {
+	return envKeys(name)
+}
+
+type envKeys string
+
+func (e envKeys) Keys(context.Context) (string, map[string][]byte, error) {
+	spec := os.Getenv(string(e))
+	if spec == "" {
+		return "", nil, fmt.Errorf("environment variable %s is not set", string(e))
+	}
+	return ParseKeys(spec)
+}
+
+// ParseKeys parses a comma-separated list of id:base64-key pairs, returning
+// the ID of the first key and all keys by ID.
+func ParseKeys(spec string) (current string, keys map[string][]byte, err error) {
+	keys = make(map[string][]byte)
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		id, encoded, ok := strings.Cut(entry, ":")
+		if !ok || id == "" {
+			return "", nil, fmt.Errorf("key %q: want id:base64-key", entry)
+		}
+		key, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return "", nil, fmt.Errorf("key %q: %w", id, err)
+		}
+		if _, dup := keys[id]; dup {
+			return "", nil, fmt.Errorf("key %q given twice", id)
+		}
+		keys[id] = key
+		if current == "" {
+			current = id
+		}
+	}
+	return current, keys, nil
+}
+
+// Cipher seals and opens stored values. A nil Cipher seals nothing, and opens
+// only values that are not sealed.
+type Cipher struct {
+	current string
+	aeads   map[string]cipher.AEAD
+}
+
+// New creates a Cipher with the keys from p.
+func New(ctx context.Context, p KeyProvider) (*Cipher, error) {
+	current, keys, err := p.Keys(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("load encryption keys: %w", err)
+	}
+	if _, ok := keys[current]; !ok {
+		return nil, fmt.Errorf("current encryption key %q is not provided", current)
+	}
+	c := &Cipher{current: current, aeads: make(map[string]cipher.AEAD, len(keys))}
+	for id, key := range keys {
+		if len(id) > 255 {
+			return nil, fmt.Errorf("encryption key ID %.16q... is longer than 255 bytes", id)
+		}
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, fmt.Errorf("encryption key %q: %w", id, err)
+		}
+		if c.aeads[id], err = cipher.NewGCM(block); err != nil {
+			return nil, fmt.Errorf("encryption key %q: %w", id, err)
+		}
+	}
+	return c, nil
+}
+
+// Seal encrypts a stored value (as produced by compression) with the current
+// key. Sealed values are the header, the key ID preceded by its length, the
+// nonce and the ciphertext.
+func (c *Cipher) Seal(stored string) string {
+	if c == nil {
+		return stored
+	}
+	aead := c.aeads[c.current]
+	out := make([]byte, 0, len(header)+1+len(c.current)+aead.NonceSize()+len(stored)+aead.Overhead())
+	out = append(out, header...)
+	out = append(out, byte(len(c.current)))
+	out = append(out, c.current...)
+	nonce := out[len(out) : len(out)+aead.NonceSize()]
+	rand.Read(nonce)
+	out = out[:len(out)+len(nonce)]
+	return string(aead.Seal(out, nonce, []byte(stored), nil))
+}
+
+// Open decrypts a value sealed by Seal. Values that are not sealed are
+// returned unchanged.
+func (c *Cipher) Open(stored string) (string, error) {
+	if !strings.HasPrefix(stored, header) {
+		return stored, nil
+	}
+	if c == nil {
+		return "", compression.ErrEncrypted
+	}
+	rest := stored[len(header):]
+	if len(rest) == 0 || len(rest) < 1+int(rest[0]) {
+		return "", errors.New("decrypt value: truncated")
+	}
+	id := rest[1 : 1+int(rest[0])]
+	rest = rest[1+len(id):]
+	aead, ok := c.aeads[id]
+	if !ok {
+		return "", fmt.Errorf("decrypt value: unknown key %q", id)
+	}
+	if len(rest) < aead.NonceSize() {
+		return "", errors.New("decrypt value: truncated")
+	}
+	plain, err := aead.Open(nil, []byte(rest[:aead.NonceSize()]), []byte(rest[aead.NonceSize():]), nil)
+	if err != nil {
+		return "", fmt.Errorf("decrypt value with key %q: %w", id, err)
+	}
+	return string(plain), nil
+}
+
+// Decode returns the original value of a stored value: opened, then
+// decompressed.
+func (c *Cipher) Decode(stored string) (string, error) {
+	plain, err := c.Open(stored)
+	if err != nil {
+		return "", err
+	}
+	return compression.Decode(plain)
+}
+
+// Escape returns value in stored form without compressing it, sealed if c is
+// not nil.
+func (c *Cipher) Escape(value string) string {
+	return c.Seal(compression.Escape(value))
+}
@@ -0,0 +1,120 @@
+package encryption
+
+import (
+	"context"
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"distributed-cache-service/internal/compression"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func testKey(b byte) string {
+	return base64.StdEncoding.EncodeToString([]byte(strings.Repeat(string(b), 32)))
+}
+
+func newCipher(t *testing.T, spec string) *Cipher {
+	t.Helper()
+	current, keys, err := ParseKeys(spec)
+	require.NoError(t, err)
+	c, err := New(context.Background(), StaticKeys(current, keys))
+	require.NoError(t, err)
+	return c
+}
+
+func TestSealOpen(t *testing.T) {
+	c := newCipher(t, "k1:"+testKey('a'))
+	comp := compression.New(compression.Deflate, 0)
+	tests := []struct {
+		name  string
+		value string
+	}{
+		{"empty", ""},
+		{"text", "hello"},
+		{"compressed", strings.Repeat("abc", 100)},
+		{"looks like header", header + "\x02k1 not sealed"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			encoded, _ := comp.Encode(tt.value)
+			sealed := c.Seal(encoded)
+			assert.True(t, strings.HasPrefix(sealed, header))
+			assert.NotContains(t, sealed, "hello")
+
+			got, err := c.Decode(sealed)
+			require.NoError(t, err)
+			assert.Equal(t, tt.value, got)
+		})
+	}
+
+	// Sealing twice gives different ciphertexts.
+	assert.NotEqual(t, c.Seal("v"), c.Seal("v"))
+	// Values written before encryption was enabled are still readable.
+	got, err := c.Decode("plain")
+	require.NoError(t, err)
+	assert.Equal(t, "plain", got)
+}
+
+func TestRotation(t *testing.T) {
+	old := newCipher(t, "k1:"+testKey('a'))
+	sealed := old.Escape("secret")
+
+	rotated := newCipher(t, "k2:"+testKey('b')+", k1:"+testKey('a'))
+	got, err := rotated.Decode(sealed)
+	require.NoError(t, err)
+	assert.Equal(t, "secret", got)
+	assert.Contains(t, rotated.Escape("secret"), "k2")
+
+	_, err = newCipher(t, "k2:"+testKey('b')).Decode(sealed)
+	assert.ErrorContains(t, err, `unknown key "k1"`)
+}
+
+func TestOpenErrors(t *testing.T) {
+	c := newCipher(t, "k1:"+testKey('a'))
+	sealed := c.Escape("secret")
+
+	tampered := []byte(sealed)
+	tampered[len(tampered)-1] ^= 1
+	_, err := c.Decode(string(tampered))
+	assert.ErrorContains(t, err, "decrypt value")
+
+	_, err = c.Decode(sealed[:len(header)+3])
+	assert.ErrorContains(t, err, "truncated")
+
+	// Without keys, a sealed value is an error rather than ciphertext.
+	_, err = (*Cipher)(nil).Decode(sealed)
+	assert.ErrorIs(t, err, compression.ErrEncrypted)
+	assert.Equal(t, "v", (*Cipher)(nil).Escape("v"))
+}
+
+func TestParseKeys(t *testing.T) {
+	current, keys, err := ParseKeys("a:" + testKey('a') + ",b:" + testKey('b'))
+	require.NoError(t, err)
+	assert.Equal(t, "a", current)
+	assert.Len(t, keys, 2)
+
+	for _, spec := range []string{"", testKey('a'), "a:not base64!", "a:" + testKey('a') + ",a:" + testKey('b')} {
+		_, _, err := ParseKeys(spec)
+		assert.Error(t, err, spec)
+	}
+
+	_, err = New(context.Background(), StaticKeys("a", map[string][]byte{"a": []byte("short")}))
+	assert.Error(t, err)
+	_, err = New(context.Background(), StaticKeys("b", map[string][]byte{"a": make([]byte, 32)}))
+	assert.Error(t, err)
+}
+
+func TestEnvKeys(t *testing.T) {
+	t.Setenv("TEST_CACHE_KEYS", "k1:"+testKey('a'))
+	c, err := New(context.Background(), EnvKeys("TEST_CACHE_KEYS"))
+	require.NoError(t, err)
+	got, err := c.Decode(c.Escape("v"))
+	require.NoError(t, err)
+	assert.Equal(t, "v", got)
+
+	_, err = New(context.Background(), EnvKeys("TEST_CACHE_KEYS_UNSET"))
+	assert.ErrorContains(t, err, "TEST_CACHE_KEYS_UNSET")
+}
@@ -0,0 +1,102 @@
+// Package events fans out committed keyspace changes to subscribers.
+//
+// Every node applies every Raft log entry, so subscribers on any node observe
+// all writes in commit order. Delivery is best effort: a subscriber that falls
+// behind is dropped (its channel is closed) rather than stalling the FSM, and
+// must resubscribe and treat anything it cached as stale.
+package events
+
+import (
+	"strings"
+	"sync"
+)
+
+// Type identifies the kind of keyspace change.
+type Type string
+
+const (
+	// Set means the key was written.
+	Set Type = "SET"
+	// Delete means the key was removed.
+	Delete Type = "DELETE"
+	// Flush means the whole keyspace may have changed, e.g. after a snapshot restore.
+	Flush Type = "FLUSH"
+)
+
+// Event describes a committed change to a key.
+type Event struct {
+	Type  Type
+	Key   string // empty for Flush
+	Index uint64 // Raft log index that produced the change
+}
+
+// Broker distributes events to subscribers. The zero value is not usable; use NewBroker.
+type Broker struct {
+	mu   sync.Mutex
+	subs map[*Subscription]struct{}
+}
+
+// NewBroker creates an empty Broker.
+func NewBroker() *Broker {
+	return &Broker{subs: make(map[*Subscription]struct{})}
+}
+
+// Subscription receives events for keys with a given prefix.
+type Subscription struct {
+	broker *Broker
+	prefix string
+	ch     chan Event
+}
+
+// Subscribe registers a subscriber for keys starting with prefix ("" for all keys).
+// Flush events are delivered to every subscriber. buffer bounds how far the
+// subscriber may lag before it is dropped.
+func (b *Broker) Subscribe(prefix string, buffer int) *Subscription {
+	s := &Subscription{broker: b, prefix: prefix, ch: make(chan Event, buffer)}
+	b.mu.Lock()
+	b.subs[s] = struct{}{}
+	b.mu.Unlock()
+	return s
+}
+
+// Publish delivers e to all matching subscribers without blocking.
+func (b *Broker) Publish(e Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for s := range b.subs {
+		if e.Type != Flush && !strings.HasPrefix(e.Key, s.prefix) {
+			continue
+		}
+		select {
+		case s.ch <- e:
+		default:
+			// Too slow; drop it so the FSM never waits on a client.
+			delete(b.subs, s)
+			close(s.ch)
+		}
+	}
+}
+
+// Len returns the number of active subscribers.
+func (b *Broker) Len() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.subs)
+}
+
+// Events returns the delivery channel. It is closed when the subscription is
+// closed or dropped for falling behind.
+func (s *Subscription) Events() <-chan Event {
+	return s.ch
+}
+
+// Close unsubscribes. It is safe to call more than once.
+func (s *Subscription) Close() {
+	b := s.broker
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.subs[s]; ok {
+		delete(b.subs, s)
+		close(s.ch)
+	}
+}
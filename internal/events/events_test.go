@@ -0,0 +1,44 @@
+package events
+
+import "testing"
+
+func TestBroker_PrefixAndFlush(t *testing.T) {
+	b := NewBroker()
+	sub := b.Subscribe("user:", 10)
+	defer sub.Close()
+
+	b.Publish(Event{Type: Set, Key: "user:1", Index: 1})
+	b.Publish(Event{Type: Set, Key: "order:1", Index: 2})
+	b.Publish(Event{Type: Delete, Key: "user:2", Index: 3})
+	b.Publish(Event{Type: Flush, Index: 4})
+
+	var got []uint64
+	for len(got) < 3 {
+		got = append(got, (<-sub.Events()).Index)
+	}
+	want := []uint64{1, 3, 4}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("expected indexes %v, got %v", want, got)
+		}
+	}
+}
+
+func TestBroker_DropsSlowSubscriber(t *testing.T) {
+	b := NewBroker()
+	slow := b.Subscribe("", 1)
+	fast := b.Subscribe("", 10)
+	defer fast.Close()
+
+	b.Publish(Event{Type: Set, Key: "a", Index: 1})
+	b.Publish(Event{Type: Set, Key: "b", Index: 2})
+
+	if b.Len() != 1 {
+		t.Fatalf("expected slow subscriber to be dropped, have %d", b.Len())
+	}
+	<-slow.Events()
+	if _, ok := <-slow.Events(); ok {
+		t.Error("expected dropped subscription channel to be closed")
+	}
+	slow.Close() // must not panic after being dropped
+}
@@ -0,0 +1,195 @@
+package grpc
+
+import (
+	"context"
+
+	"distributed-cache-service/internal/backup"
+	"distributed-cache-service/internal/core/ports"
+	pb "distributed-cache-service/proto"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// AdminAdapter implements the generated AdminServiceServer interface.
+type AdminAdapter struct {
+	pb.UnimplementedAdminServiceServer
+	cluster    ports.ClusterAdmin
+	storage    ports.SnapshotStorage
+	backupDest string
+	namespaces ports.NamespaceReporter
+	node       ports.NodeReporter
+}
+
+// AdminOption configures an AdminAdapter.
+type AdminOption func(*AdminAdapter)
+
+// WithBackupDest sets the location Backup writes to when the request names none.
+func WithBackupDest(dest string) AdminOption {
+	return func(s *AdminAdapter) {
+		s.backupDest = dest
+	}
+}
+
+// WithNamespaceStats enables NamespaceStats, which reports what r returns.
+func WithNamespaceStats(r ports.NamespaceReporter) AdminOption {
+	return func(s *AdminAdapter) {
+		s.namespaces = r
+	}
+}
+
+// WithNodeStats makes Stats report the read counters and replication
+// progress r returns.
+func WithNodeStats(r ports.NodeReporter) AdminOption {
+	return func(s *AdminAdapter) {
+		s.node = r
+	}
+}
+
+// NewAdmin creates a new gRPC admin adapter.
+func NewAdmin(cluster ports.ClusterAdmin, storage ports.SnapshotStorage, opts ...AdminOption) *AdminAdapter {
+	s := &AdminAdapter{cluster: cluster, storage: storage}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+// Join adds a voting member to the cluster.
+func (s *AdminAdapter) Join(ctx context.Context, req *pb.JoinRequest) (*pb.JoinResponse, error) {
+	if req.NodeId == "" || req.Addr == "" {
+		return nil, status.Error(codes.InvalidArgument, "node_id and addr are required")
+	}
+	if err := s.cluster.AddVoter(req.NodeId, req.Addr); err != nil {
+		return nil, toStatus(err)
+	}
+	return &pb.JoinResponse{}, nil
+}
+
+// Remove removes a member from the cluster.
+func (s *AdminAdapter) Remove(ctx context.Context, req *pb.RemoveRequest) (*pb.RemoveResponse, error) {
+	if req.NodeId == "" {
+		return nil, status.Error(codes.InvalidArgument, "node_id is required")
+	}
+	if err := s.cluster.RemoveServer(req.NodeId); err != nil {
+		return nil, toStatus(err)
+	}
+	return &pb.RemoveResponse{}, nil
+}
+
+// TransferLeadership hands leadership to another node.
+func (s *AdminAdapter) TransferLeadership(ctx context.Context, req *pb.TransferLeadershipRequest) (*pb.TransferLeadershipResponse, error) {
+	if (req.NodeId == "") != (req.Addr == "") {
+		return nil, status.Error(codes.InvalidArgument, "node_id and addr must be set together")
+	}
+	if err := s.cluster.TransferLeadership(req.NodeId, req.Addr); err != nil {
+		return nil, toStatus(err)
+	}
+	return &pb.TransferLeadershipResponse{}, nil
+}
+
+// Snapshot forces a snapshot of the state machine.
+func (s *AdminAdapter) Snapshot(ctx context.Context, req *pb.SnapshotRequest) (*pb.SnapshotResponse, error) {
+	info, err := s.cluster.Snapshot()
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &pb.SnapshotResponse{Id: info.ID, Index: info.Index, Term: info.Term, Size: info.Size}, nil
+}
+
+// Compact snapshots the state machine and truncates the log behind it.
+func (s *AdminAdapter) Compact(ctx context.Context, req *pb.CompactRequest) (*pb.CompactResponse, error) {
+	index, err := s.cluster.Compact()
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	return &pb.CompactResponse{Index: index}, nil
+}
+
+// Stats reports node role, leader and consensus counters.
+func (s *AdminAdapter) Stats(ctx context.Context, req *pb.StatsRequest) (*pb.StatsResponse, error) {
+	resp := &pb.StatsResponse{
+		State:    s.cluster.State(),
+		Leader:   s.cluster.Leader(),
+		KeyCount: int64(s.storage.Len()),
+		Raft:     s.cluster.Stats(),
+	}
+	if s.node != nil {
+		st := s.node.NodeStats()
+		resp.Hits, resp.Misses, resp.Capacity = st.Hits, st.Misses, st.Capacity
+		resp.AppliedIndex = st.AppliedIndex
+		resp.ReplicationLag, resp.ReplicationLagError = st.ReplicationLag, st.ReplicationLagError
+	}
+	return resp, nil
+}
+
+// Members lists the servers in the cluster configuration.
+func (s *AdminAdapter) Members(ctx context.Context, req *pb.MembersRequest) (*pb.MembersResponse, error) {
+	members, err := s.cluster.Members()
+	if err != nil {
+		return nil, toStatus(err)
+	}
+	resp := &pb.MembersResponse{Members: make([]*pb.ClusterMember, 0, len(members))}
+	for _, m := range members {
+		resp.Members = append(resp.Members, &pb.ClusterMember{Id: m.ID, Addr: m.Address, Voter: m.Voter, Leader: m.Leader, GrpcAddr: m.GRPCAddr})
+	}
+	return resp, nil
+}
+
+// Backup streams a consistent snapshot of the store to req.Dest.
+func (s *AdminAdapter) Backup(ctx context.Context, req *pb.BackupRequest) (*pb.BackupResponse, error) {
+	dest := req.Dest
+	if dest == "" {
+		dest = s.backupDest
+	}
+	loc, err := backup.ParseLocation(dest)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	if err := backup.Backup(ctx, s.storage, loc); err != nil {
+		return nil, toStatus(err)
+	}
+	return &pb.BackupResponse{Location: loc.String()}, nil
+}
+
+// Restore replaces the cluster's state with the backup at req.Source.
+func (s *AdminAdapter) Restore(ctx context.Context, req *pb.RestoreRequest) (*pb.RestoreResponse, error) {
+	loc, err := backup.ParseLocation(req.Source)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+	rc, err := loc.Open(ctx)
+	if err != nil {
+		return nil, status.Error(codes.NotFound, err.Error())
+	}
+	defer rc.Close()
+	if err := s.cluster.RestoreFrom(rc); err != nil {
+		return nil, toStatus(err)
+	}
+	return &pb.RestoreResponse{}, nil
+}
+
+// NamespaceStats reports this node's stats for each namespace with a quota.
+func (s *AdminAdapter) NamespaceStats(ctx context.Context, req *pb.NamespaceStatsRequest) (*pb.NamespaceStatsResponse, error) {
+	if s.namespaces == nil {
+		return nil, status.Error(codes.Unimplemented, "namespace stats are not enabled")
+	}
+	stats := s.namespaces.NamespaceStats()
+	resp := &pb.NamespaceStatsResponse{Namespaces: make([]*pb.NamespaceStats, 0, len(stats))}
+	for _, st := range stats {
+		ns := &pb.NamespaceStats{
+			Namespace:    st.Namespace,
+			Hits:         st.Hits,
+			Misses:       st.Misses,
+			MaxKeys:      st.MaxKeys,
+			MaxBytes:     st.MaxBytes,
+			MaxWriteRate: st.MaxWriteRate,
+			Throttled:    st.Throttled,
+		}
+		if st.Usage != nil {
+			ns.HasUsage, ns.Keys, ns.Bytes = true, st.Usage.Keys, st.Usage.Bytes
+		}
+		resp.Namespaces = append(resp.Namespaces, ns)
+	}
+	return resp, nil
+}
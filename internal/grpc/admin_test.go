@@ -0,0 +1,157 @@
+package grpc
+
+import (
+	"context"
+	"io"
+	"path/filepath"
+	"testing"
+
+	"distributed-cache-service/internal/core/ports"
+	pb "distributed-cache-service/proto"
+
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type mockCluster struct {
+	removed  string
+	restored string
+}
+
+func (m *mockCluster) AddVoter(id, addr string) error           { return nil }
+func (m *mockCluster) RemoveServer(id string) error             { m.removed = id; return nil }
+func (m *mockCluster) TransferLeadership(id, addr string) error { return nil }
+func (m *mockCluster) Snapshot() (ports.SnapshotInfo, error) {
+	return ports.SnapshotInfo{ID: "1-10-123", Index: 10, Term: 1, Size: 64}, nil
+}
+func (m *mockCluster) ListSnapshots() ([]ports.SnapshotInfo, error) { return nil, nil }
+func (m *mockCluster) Compact() (uint64, error)                     { return 10, nil }
+func (m *mockCluster) State() string                                { return "Leader" }
+func (m *mockCluster) Leader() string                               { return "127.0.0.1:11000" }
+func (m *mockCluster) Stats() map[string]string                     { return map[string]string{"term": "1"} }
+func (m *mockCluster) Members() ([]ports.Member, error) {
+	return []ports.Member{
+		{ID: "node1", Address: "127.0.0.1:11000", Voter: true, Leader: true},
+		{ID: "node2", Address: "127.0.0.1:11001", Voter: true},
+	}, nil
+}
+func (m *mockCluster) RestoreFrom(r io.Reader) error {
+	data, err := io.ReadAll(r)
+	m.restored = string(data)
+	return err
+}
+
+type mockStorage struct{ ports.SnapshotStorage }
+
+func (m *mockStorage) Len() int { return 3 }
+func (m *mockStorage) Snapshot(w io.Writer) error {
+	_, err := w.Write([]byte("state"))
+	return err
+}
+
+func TestAdminAdapter_Remove(t *testing.T) {
+	cluster := &mockCluster{}
+	adapter := NewAdmin(cluster, &mockStorage{})
+
+	_, err := adapter.Remove(context.Background(), &pb.RemoveRequest{})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	_, err = adapter.Remove(context.Background(), &pb.RemoveRequest{NodeId: "node2"})
+	assert.NoError(t, err)
+	assert.Equal(t, "node2", cluster.removed)
+}
+
+func TestAdminAdapter_Stats(t *testing.T) {
+	adapter := NewAdmin(&mockCluster{}, &mockStorage{})
+
+	resp, err := adapter.Stats(context.Background(), &pb.StatsRequest{})
+	assert.NoError(t, err)
+	assert.Equal(t, "Leader", resp.State)
+	assert.Equal(t, int64(3), resp.KeyCount)
+	assert.Equal(t, "1", resp.Raft["term"])
+}
+
+type nodeReporter ports.NodeStats
+
+func (r nodeReporter) NodeStats() ports.NodeStats { return ports.NodeStats(r) }
+
+func TestAdminAdapter_NodeStats(t *testing.T) {
+	adapter := NewAdmin(&mockCluster{}, &mockStorage{}, WithNodeStats(nodeReporter{
+		Hits: 5, Misses: 2, Capacity: 100, AppliedIndex: 42, ReplicationLagError: "no known leader",
+	}))
+
+	resp, err := adapter.Stats(context.Background(), &pb.StatsRequest{})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), resp.KeyCount)
+	assert.Equal(t, uint64(5), resp.Hits)
+	assert.Equal(t, uint64(2), resp.Misses)
+	assert.Equal(t, int64(100), resp.Capacity)
+	assert.Equal(t, uint64(42), resp.AppliedIndex)
+	assert.Equal(t, "no known leader", resp.ReplicationLagError)
+}
+
+func TestAdminAdapter_Members(t *testing.T) {
+	adapter := NewAdmin(&mockCluster{}, &mockStorage{})
+
+	resp, err := adapter.Members(context.Background(), &pb.MembersRequest{})
+	assert.NoError(t, err)
+	if assert.Len(t, resp.Members, 2) {
+		assert.Equal(t, "node1", resp.Members[0].Id)
+		assert.True(t, resp.Members[0].Leader)
+		assert.False(t, resp.Members[1].Leader)
+	}
+}
+
+func TestAdminAdapter_BackupRestore(t *testing.T) {
+	cluster := &mockCluster{}
+	dest := filepath.Join(t.TempDir(), "cache.snap")
+
+	_, err := NewAdmin(cluster, &mockStorage{}).Backup(context.Background(), &pb.BackupRequest{})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err), "no destination")
+
+	adapter := NewAdmin(cluster, &mockStorage{}, WithBackupDest(dest))
+	resp, err := adapter.Backup(context.Background(), &pb.BackupRequest{})
+	assert.NoError(t, err)
+	assert.Equal(t, "file://"+dest, resp.Location)
+
+	_, err = adapter.Restore(context.Background(), &pb.RestoreRequest{Source: dest + ".missing"})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	_, err = adapter.Restore(context.Background(), &pb.RestoreRequest{Source: dest})
+	assert.NoError(t, err)
+	assert.Equal(t, "state", cluster.restored)
+}
+
+func TestAdminAdapter_Snapshot(t *testing.T) {
+	adapter := NewAdmin(&mockCluster{}, &mockStorage{})
+
+	resp, err := adapter.Snapshot(context.Background(), &pb.SnapshotRequest{})
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(10), resp.Index)
+}
+
+type namespaceReporter []ports.NamespaceStats
+
+func (r namespaceReporter) NamespaceStats() []ports.NamespaceStats { return r }
+
+func TestAdminAdapter_NamespaceStats(t *testing.T) {
+	_, err := NewAdmin(&mockCluster{}, &mockStorage{}).NamespaceStats(context.Background(), &pb.NamespaceStatsRequest{})
+	assert.Equal(t, codes.Unimplemented, status.Code(err))
+
+	adapter := NewAdmin(&mockCluster{}, &mockStorage{}, WithNamespaceStats(namespaceReporter{
+		{Namespace: "user:", Hits: 3, Misses: 1, Usage: &ports.Usage{Keys: 2, Bytes: 20}, MaxKeys: 10, Throttled: map[string]uint64{"keys": 1}},
+		{Namespace: "session:", MaxWriteRate: 5},
+	}))
+	resp, err := adapter.NamespaceStats(context.Background(), &pb.NamespaceStatsRequest{})
+	assert.NoError(t, err)
+	if assert.Len(t, resp.Namespaces, 2) {
+		user := resp.Namespaces[0]
+		assert.Equal(t, "user:", user.Namespace)
+		assert.True(t, user.HasUsage)
+		assert.Equal(t, int64(2), user.Keys)
+		assert.Equal(t, uint64(1), user.Throttled["keys"])
+		assert.False(t, resp.Namespaces[1].HasUsage)
+		assert.Equal(t, 5.0, resp.Namespaces[1].MaxWriteRate)
+	}
+}
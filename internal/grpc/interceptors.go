@@ -0,0 +1,206 @@
+package grpc
+
+import (
+	"context"
+	"log"
+	"log/slog"
+	"runtime/debug"
+	"strings"
+	"time"
+
+	"distributed-cache-service/internal/observability"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	otelcodes "go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// tracerName names the tracer that spans RPCs.
+const tracerName = "distributed-cache-service/internal/grpc"
+
+// Interceptors is the server's interceptor chain. Every call, unary or
+// streaming, runs through tracing, metrics, logging and panic recovery, in
+// that order, and then through the interceptors given here, the first
+// outermost. Registering a new RPC needs nothing more for it to be traced,
+// measured, logged and recovered.
+type Interceptors struct {
+	Unary  []grpc.UnaryServerInterceptor
+	Stream []grpc.StreamServerInterceptor
+}
+
+// ServerOptions installs the chain on a server.
+func (i Interceptors) ServerOptions() []grpc.ServerOption {
+	unary := append([]grpc.UnaryServerInterceptor{
+		unaryTrace, unaryMetrics, unaryLog, unaryRecover,
+	}, i.Unary...)
+	stream := append([]grpc.StreamServerInterceptor{
+		streamTrace, streamMetrics, streamLog, streamRecover,
+	}, i.Stream...)
+	return []grpc.ServerOption{
+		grpc.ChainUnaryInterceptor(unary...),
+		grpc.ChainStreamInterceptor(stream...),
+	}
+}
+
+// unaryTrace spans a call, continuing the trace the client propagated in
+// W3C trace context metadata, if any. Spans are dropped unless a tracer
+// provider is installed, see observability.SetupTracing.
+func unaryTrace(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	ctx, span := startSpan(ctx, info.FullMethod)
+	resp, err := handler(ctx, req)
+	endSpan(span, err)
+	return resp, err
+}
+
+func streamTrace(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, span := startSpan(ss.Context(), info.FullMethod)
+	err := handler(srv, &contextStream{ServerStream: ss, ctx: ctx})
+	endSpan(span, err)
+	return err
+}
+
+func startSpan(ctx context.Context, method string) (context.Context, trace.Span) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	ctx = otel.GetTextMapPropagator().Extract(ctx, metadataCarrier(md))
+	service, name, _ := strings.Cut(strings.TrimPrefix(method, "/"), "/")
+	return otel.Tracer(tracerName).Start(ctx, strings.TrimPrefix(method, "/"),
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			attribute.String("rpc.system", "grpc"),
+			attribute.String("rpc.service", service),
+			attribute.String("rpc.method", name),
+		))
+}
+
+func endSpan(span trace.Span, err error) {
+	code := status.Code(err)
+	span.SetAttributes(attribute.Int("rpc.grpc.status_code", int(code)))
+	if serverError(code) {
+		span.SetStatus(otelcodes.Error, status.Convert(err).Message())
+	}
+	span.End()
+}
+
+// unaryMetrics counts calls by method and status code and measures their
+// latency. Method names come from the registered services, so the labels
+// stay bounded.
+func unaryMetrics(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	start := time.Now()
+	resp, err := handler(ctx, req)
+	observe(info.FullMethod, start, err)
+	return resp, err
+}
+
+func streamMetrics(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	start := time.Now()
+	err := handler(srv, ss)
+	observe(info.FullMethod, start, err)
+	return err
+}
+
+func observe(method string, start time.Time, err error) {
+	observability.GRPCRequestsTotal.WithLabelValues(method, status.Code(err).String()).Inc()
+	observability.GRPCRequestDurationSeconds.WithLabelValues(method).Observe(time.Since(start).Seconds())
+}
+
+// unaryLog logs each call at debug level, and calls that fail with a server
+// error at warn level.
+func unaryLog(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	start := time.Now()
+	resp, err := handler(ctx, req)
+	logCall(ctx, info.FullMethod, start, err)
+	return resp, err
+}
+
+func streamLog(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	start := time.Now()
+	err := handler(srv, ss)
+	logCall(ss.Context(), info.FullMethod, start, err)
+	return err
+}
+
+func logCall(ctx context.Context, method string, start time.Time, err error) {
+	code := status.Code(err)
+	level := slog.LevelDebug
+	if serverError(code) {
+		level = slog.LevelWarn
+	}
+	args := []any{"method", method, "code", code.String(), "duration", time.Since(start)}
+	if p, ok := peer.FromContext(ctx); ok {
+		args = append(args, "remote", p.Addr.String())
+	}
+	if err != nil {
+		args = append(args, "error", status.Convert(err).Message())
+	}
+	slog.Log(ctx, level, "gRPC call", args...)
+}
+
+// serverError reports whether code blames the server rather than the call.
+func serverError(code codes.Code) bool {
+	switch code {
+	case codes.Unknown, codes.DeadlineExceeded, codes.Unimplemented, codes.Internal,
+		codes.Unavailable, codes.DataLoss:
+		return true
+	}
+	return false
+}
+
+// unaryRecover turns a panicking handler into an Internal error, logging the
+// panic and its stack, so one bad call cannot take the node down.
+func unaryRecover(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (resp interface{}, err error) {
+	defer recoverCall(info.FullMethod, &err)
+	return handler(ctx, req)
+}
+
+func streamRecover(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+	defer recoverCall(info.FullMethod, &err)
+	return handler(srv, ss)
+}
+
+func recoverCall(method string, err *error) {
+	p := recover()
+	if p == nil {
+		return
+	}
+	observability.GRPCPanicsTotal.Inc()
+	log.Printf("Panic serving %s: %v\n%s", method, p, debug.Stack())
+	*err = status.Error(codes.Internal, "internal error")
+}
+
+// contextStream replaces the context of a ServerStream.
+type contextStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *contextStream) Context() context.Context {
+	return s.ctx
+}
+
+// metadataCarrier reads and writes propagated trace context in gRPC metadata.
+type metadataCarrier metadata.MD
+
+func (c metadataCarrier) Get(key string) string {
+	if v := metadata.MD(c).Get(key); len(v) > 0 {
+		return v[0]
+	}
+	return ""
+}
+
+func (c metadataCarrier) Set(key, value string) {
+	metadata.MD(c).Set(key, value)
+}
+
+func (c metadataCarrier) Keys() []string {
+	keys := make([]string, 0, len(c))
+	for k := range c {
+		keys = append(keys, k)
+	}
+	return keys
+}
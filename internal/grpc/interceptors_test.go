@@ -0,0 +1,134 @@
+package grpc
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"distributed-cache-service/internal/core/ports"
+	"distributed-cache-service/internal/observability"
+	pb "distributed-cache-service/proto"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// serveIntercepted serves mock behind the interceptor chain and returns a
+// client for it.
+func serveIntercepted(t *testing.T, mock *mockService, extra Interceptors) pb.CacheServiceClient {
+	t.Helper()
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer(extra.ServerOptions()...)
+	pb.RegisterCacheServiceServer(srv, New(mock))
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }))
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return pb.NewCacheServiceClient(conn)
+}
+
+func TestInterceptors_Recover(t *testing.T) {
+	client := serveIntercepted(t, &mockService{
+		setFunc: func(ctx context.Context, key, value string, ttl time.Duration) error { panic("boom") },
+		bulkLoadFunc: func(ctx context.Context, next func() (ports.BulkEntry, error)) (ports.BulkLoadResult, error) {
+			panic("boom")
+		},
+	}, Interceptors{})
+
+	panics := testutil.ToFloat64(observability.GRPCPanicsTotal)
+	internal := observability.GRPCRequestsTotal.WithLabelValues(pb.CacheService_Set_FullMethodName, "Internal")
+	before := testutil.ToFloat64(internal)
+	_, err := client.Set(context.Background(), &pb.SetRequest{Key: "k", Value: "v"})
+	if status.Code(err) != codes.Internal {
+		t.Fatalf("expected Internal from a panicking handler, got %v", err)
+	}
+	if got := testutil.ToFloat64(internal); got != before+1 {
+		t.Errorf("expected the failed call to be counted, got %v after %v", got, before)
+	}
+
+	stream, err := client.BulkLoad(context.Background())
+	if err != nil {
+		t.Fatalf("bulk load: %v", err)
+	}
+	if _, err := stream.CloseAndRecv(); status.Code(err) != codes.Internal {
+		t.Fatalf("expected Internal from a panicking stream handler, got %v", err)
+	}
+	if got := testutil.ToFloat64(observability.GRPCPanicsTotal); got != panics+2 {
+		t.Errorf("expected 2 more panics, got %v after %v", got, panics)
+	}
+}
+
+func TestInterceptors_Extra(t *testing.T) {
+	var calls []string
+	client := serveIntercepted(t, &mockService{
+		getFunc: func(ctx context.Context, key string) (string, error) { return "v", nil },
+	}, Interceptors{Unary: []grpc.UnaryServerInterceptor{
+		func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+			calls = append(calls, info.FullMethod)
+			return nil, status.Error(codes.PermissionDenied, "denied")
+		},
+	}})
+
+	ok := observability.GRPCRequestsTotal.WithLabelValues(pb.CacheService_Get_FullMethodName, "PermissionDenied")
+	before := testutil.ToFloat64(ok)
+	if _, err := client.Get(context.Background(), &pb.GetRequest{Key: "k"}); status.Code(err) != codes.PermissionDenied {
+		t.Fatalf("expected the extra interceptor's error, got %v", err)
+	}
+	if len(calls) != 1 || calls[0] != pb.CacheService_Get_FullMethodName {
+		t.Errorf("expected one call through the extra interceptor, got %v", calls)
+	}
+	if got := testutil.ToFloat64(ok); got != before+1 {
+		t.Errorf("expected calls rejected by extra interceptors to be counted, got %v after %v", got, before)
+	}
+}
+
+func TestInterceptors_Trace(t *testing.T) {
+	spans := tracetest.NewSpanRecorder()
+	prevProvider, prevPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(spans)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	defer func() {
+		otel.SetTracerProvider(prevProvider)
+		otel.SetTextMapPropagator(prevPropagator)
+	}()
+
+	client := serveIntercepted(t, &mockService{
+		getFunc: func(ctx context.Context, key string) (string, error) { return "v", nil },
+	}, Interceptors{})
+	const traceID = "4bf92f3577b34da6a3ce929d0e0e4736"
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+	if _, err := client.Get(ctx, &pb.GetRequest{Key: "k"}); err != nil {
+		t.Fatalf("get: %v", err)
+	}
+
+	ended := spans.Ended()
+	if len(ended) != 1 {
+		t.Fatalf("expected one span, got %d", len(ended))
+	}
+	span := ended[0]
+	if span.Name() != "cache.CacheService/Get" {
+		t.Errorf("unexpected span name %q", span.Name())
+	}
+	if got := span.SpanContext().TraceID().String(); got != traceID {
+		t.Errorf("expected the client's trace %s to be continued, got %s", traceID, got)
+	}
+	if !span.Parent().IsRemote() {
+		t.Errorf("expected the span's parent to be the client's")
+	}
+}
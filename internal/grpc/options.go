@@ -0,0 +1,84 @@
+package grpc
+
+import (
+	"fmt"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/keepalive"
+)
+
+// ServerConfig tunes the gRPC server's connections. Zero values keep gRPC's
+// defaults: no keepalive pings for two hours, no connection age limit,
+// unlimited streams, 4 MiB messages received and unlimited messages sent.
+type ServerConfig struct {
+	// KeepaliveTime is how long a connection may be idle before the server
+	// pings the client. Below a NAT or load balancer idle timeout, it keeps
+	// long-lived connections from being dropped silently.
+	KeepaliveTime time.Duration
+	// KeepaliveTimeout is how long the server waits for a ping ack before
+	// closing the connection.
+	KeepaliveTimeout time.Duration
+	// KeepaliveMinTime is the shortest interval at which clients may ping; a
+	// client pinging more often is disconnected.
+	KeepaliveMinTime time.Duration
+	// PermitWithoutStream allows clients to ping with no call in flight.
+	PermitWithoutStream bool
+	// MaxConnectionIdle closes connections with no call for this long.
+	MaxConnectionIdle time.Duration
+	// MaxConnectionAge closes connections after this long, so that clients
+	// rebalance across nodes; MaxConnectionAgeGrace lets calls finish first.
+	MaxConnectionAge      time.Duration
+	MaxConnectionAgeGrace time.Duration
+	// MaxConcurrentStreams bounds the calls in flight on one connection.
+	MaxConcurrentStreams uint32
+	// MaxRecvMsgSize and MaxSendMsgSize bound message sizes in bytes.
+	MaxRecvMsgSize int
+	MaxSendMsgSize int
+}
+
+// ServerOptions converts cfg into gRPC server options.
+func (cfg ServerConfig) ServerOptions() ([]grpc.ServerOption, error) {
+	for _, d := range []struct {
+		name  string
+		value time.Duration
+	}{
+		{"keepalive time", cfg.KeepaliveTime},
+		{"keepalive timeout", cfg.KeepaliveTimeout},
+		{"keepalive min time", cfg.KeepaliveMinTime},
+		{"max connection idle", cfg.MaxConnectionIdle},
+		{"max connection age", cfg.MaxConnectionAge},
+		{"max connection age grace", cfg.MaxConnectionAgeGrace},
+	} {
+		if d.value < 0 {
+			return nil, fmt.Errorf("%s must not be negative", d.name)
+		}
+	}
+	if cfg.MaxRecvMsgSize < 0 || cfg.MaxSendMsgSize < 0 {
+		return nil, fmt.Errorf("message sizes must not be negative")
+	}
+
+	opts := []grpc.ServerOption{
+		grpc.KeepaliveParams(keepalive.ServerParameters{
+			Time:                  cfg.KeepaliveTime,
+			Timeout:               cfg.KeepaliveTimeout,
+			MaxConnectionIdle:     cfg.MaxConnectionIdle,
+			MaxConnectionAge:      cfg.MaxConnectionAge,
+			MaxConnectionAgeGrace: cfg.MaxConnectionAgeGrace,
+		}),
+		grpc.KeepaliveEnforcementPolicy(keepalive.EnforcementPolicy{
+			MinTime:             cfg.KeepaliveMinTime,
+			PermitWithoutStream: cfg.PermitWithoutStream,
+		}),
+	}
+	if cfg.MaxConcurrentStreams > 0 {
+		opts = append(opts, grpc.MaxConcurrentStreams(cfg.MaxConcurrentStreams))
+	}
+	if cfg.MaxRecvMsgSize > 0 {
+		opts = append(opts, grpc.MaxRecvMsgSize(cfg.MaxRecvMsgSize))
+	}
+	if cfg.MaxSendMsgSize > 0 {
+		opts = append(opts, grpc.MaxSendMsgSize(cfg.MaxSendMsgSize))
+	}
+	return opts, nil
+}
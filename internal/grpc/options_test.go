@@ -0,0 +1,63 @@
+package grpc
+
+import (
+	"context"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	pb "distributed-cache-service/proto"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func TestServerConfig_Validation(t *testing.T) {
+	if _, err := (ServerConfig{}).ServerOptions(); err != nil {
+		t.Fatalf("expected the zero config to be valid, got %v", err)
+	}
+	for _, cfg := range []ServerConfig{
+		{KeepaliveTime: -time.Second},
+		{MaxConnectionAgeGrace: -time.Second},
+		{MaxRecvMsgSize: -1},
+	} {
+		if _, err := cfg.ServerOptions(); err == nil {
+			t.Errorf("expected %+v to be rejected", cfg)
+		}
+	}
+}
+
+func TestServerConfig_MaxRecvMsgSize(t *testing.T) {
+	opts, err := ServerConfig{MaxRecvMsgSize: 1024, KeepaliveTime: time.Minute}.ServerOptions()
+	if err != nil {
+		t.Fatalf("server options: %v", err)
+	}
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer(opts...)
+	mock := &mockService{setFunc: func(ctx context.Context, key, value string, ttl time.Duration) error { return nil }}
+	pb.RegisterCacheServiceServer(srv, New(mock))
+	go srv.Serve(lis)
+	defer srv.Stop()
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }))
+	if err != nil {
+		t.Fatalf("dial: %v", err)
+	}
+	defer conn.Close()
+	client := pb.NewCacheServiceClient(conn)
+
+	ctx := context.Background()
+	if _, err := client.Set(ctx, &pb.SetRequest{Key: "k", Value: "small"}); err != nil {
+		t.Fatalf("expected a small request to pass, got %v", err)
+	}
+	_, err = client.Set(ctx, &pb.SetRequest{Key: "k", Value: strings.Repeat("x", 2048)})
+	if status.Code(err) != codes.ResourceExhausted {
+		t.Errorf("expected ResourceExhausted for an oversized request, got %v", err)
+	}
+}
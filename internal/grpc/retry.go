@@ -0,0 +1,82 @@
+package grpc
+
+import (
+	"errors"
+	"time"
+
+	coreerrors "distributed-cache-service/internal/core/errors"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// Retry hints are attached to the status of a call refused because this node
+// is not the leader or has lost contact with it, as a google.rpc.ErrorInfo of
+// ErrorDomain with one of the reasons below, and a google.rpc.RetryInfo. The
+// call was not carried out, so it is safe to retry, writes included.
+const (
+	ErrorDomain = "distributed-cache-service"
+
+	// ReasonNotLeader means the node is a follower. The ErrorInfo's
+	// MetadataLeader entry holds the leader's gRPC address, if one is known;
+	// the call should be retried there at once. Without one, an election is
+	// under way.
+	ReasonNotLeader = "NOT_LEADER"
+	// ReasonNoQuorum means the node has lost contact with a majority of the
+	// cluster. It may be partitioned from the leader, or the leader may have
+	// failed.
+	ReasonNoQuorum = "NO_QUORUM"
+
+	// MetadataLeader is the ErrorInfo metadata key of the leader's address.
+	MetadataLeader = "leader"
+)
+
+// WithRetryHints attaches retry hints to calls refused during a leader
+// change. leader returns the current leader's gRPC address, or "" while there
+// is none. retryAfter is how long clients should wait before retrying when
+// no leader is known, which should be about an election's length.
+func WithRetryHints(leader func() string, retryAfter time.Duration) Option {
+	return func(a *Adapter) {
+		a.leader = leader
+		a.retryAfter = retryAfter
+	}
+}
+
+// statusOf converts a service error into a gRPC status error, with retry
+// hints if the error is election related and hints are enabled.
+func (s *Adapter) statusOf(err error) error {
+	reason := electionReason(err)
+	if reason == "" || s.leader == nil {
+		return toStatus(err)
+	}
+	info := &errdetails.ErrorInfo{Reason: reason, Domain: ErrorDomain}
+	wait := s.retryAfter
+	if leader := s.leader(); leader != "" {
+		info.Metadata = map[string]string{MetadataLeader: leader}
+		if reason == ReasonNotLeader {
+			wait = 0
+		}
+	}
+	st, derr := status.New(Code(err), coreerrors.PublicMessage(err)).WithDetails(info, &errdetails.RetryInfo{RetryDelay: durationpb.New(wait)})
+	if derr != nil {
+		return toStatus(err)
+	}
+	return st.Err()
+}
+
+// electionReason returns the ErrorInfo reason for err if it refuses a call
+// that never reached the log because of a leader change, or "" otherwise. A
+// write whose leader lost leadership after submitting it is not covered,
+// since it may still commit.
+func electionReason(err error) string {
+	switch {
+	case errors.Is(err, coreerrors.ErrLeadershipLost):
+		return ""
+	case errors.Is(err, coreerrors.ErrNotLeader):
+		return ReasonNotLeader
+	case errors.Is(err, coreerrors.ErrNoQuorum):
+		return ReasonNoQuorum
+	}
+	return ""
+}
@@ -0,0 +1,80 @@
+package grpc
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	coreerrors "distributed-cache-service/internal/core/errors"
+	pb "distributed-cache-service/proto"
+
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc/status"
+)
+
+func TestAdapter_RetryHints(t *testing.T) {
+	var failure error
+	mock := &mockService{getFunc: func(ctx context.Context, key string) (string, error) {
+		return "", failure
+	}}
+	leader := ""
+	adapter := New(mock, WithRetryHints(func() string { return leader }, time.Second))
+
+	hints := func(err error) (*errdetails.ErrorInfo, *errdetails.RetryInfo) {
+		var info *errdetails.ErrorInfo
+		var retry *errdetails.RetryInfo
+		for _, d := range status.Convert(err).Details() {
+			switch d := d.(type) {
+			case *errdetails.ErrorInfo:
+				info = d
+			case *errdetails.RetryInfo:
+				retry = d
+			}
+		}
+		return info, retry
+	}
+
+	tests := []struct {
+		name   string
+		err    error
+		leader string
+		reason string
+		wait   time.Duration
+	}{
+		{"election", coreerrors.ErrNotLeader, "", ReasonNotLeader, time.Second},
+		{"follower", coreerrors.ErrNotLeader, "node2:9090", ReasonNotLeader, 0},
+		{"no quorum", coreerrors.ErrNoQuorum, "node2:9090", ReasonNoQuorum, time.Second},
+		{"leadership lost", coreerrors.ErrLeadershipLost, "node2:9090", "", 0},
+		{"not found", coreerrors.ErrNotFound, "", "", 0},
+	}
+	for _, tt := range tests {
+		failure, leader = tt.err, tt.leader
+		_, err := adapter.Get(context.Background(), &pb.GetRequest{Key: "k"})
+		if status.Code(err) != Code(tt.err) {
+			t.Errorf("%s: expected code %v, got %v", tt.name, Code(tt.err), err)
+		}
+		info, retry := hints(err)
+		if tt.reason == "" {
+			if info != nil || retry != nil {
+				t.Errorf("%s: expected no hints, got %v and %v", tt.name, info, retry)
+			}
+			continue
+		}
+		if info == nil || retry == nil {
+			t.Fatalf("%s: expected hints, got %v and %v", tt.name, info, retry)
+		}
+		if info.Domain != ErrorDomain || info.Reason != tt.reason || info.Metadata[MetadataLeader] != tt.leader {
+			t.Errorf("%s: unexpected error info %v", tt.name, info)
+		}
+		if got := retry.RetryDelay.AsDuration(); got != tt.wait {
+			t.Errorf("%s: expected a wait of %v, got %v", tt.name, tt.wait, got)
+		}
+	}
+
+	// Without WithRetryHints, errors carry no details.
+	failure = coreerrors.ErrNotLeader
+	_, err := New(mock).Get(context.Background(), &pb.GetRequest{Key: "k"})
+	if info, retry := hints(err); info != nil || retry != nil {
+		t.Errorf("expected no hints, got %v and %v", info, retry)
+	}
+}
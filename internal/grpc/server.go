@@ -2,48 +2,402 @@ package grpc
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
 	"time"
 
+	coreerrors "distributed-cache-service/internal/core/errors"
 	"distributed-cache-service/internal/core/ports"
+	"distributed-cache-service/internal/core/service"
+	"distributed-cache-service/internal/events"
 	pb "distributed-cache-service/proto"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 )
 
+// watchBuffer is how many events a Watch stream may lag behind before it is dropped.
+const watchBuffer = 1024
+
+// TrailerBulkLoaded is the gRPC trailer in which BulkLoad reports how many
+// entries it wrote, also when it fails.
+const TrailerBulkLoaded = "x-bulk-loaded"
+
 // Adapter implements the generated CacheServiceServer interface.
 type Adapter struct {
 	pb.UnimplementedCacheServiceServer
 	service ports.CacheService
+	events  *events.Broker
+
+	// See WithRetryHints.
+	leader     func() string
+	retryAfter time.Duration
+
+	maxStreamValue int // see WithMaxStreamValueSize
+}
+
+// Option configures optional adapter behaviour.
+type Option func(*Adapter)
+
+// WithEvents enables the Watch RPC, streaming events from b.
+func WithEvents(b *events.Broker) Option {
+	return func(a *Adapter) {
+		a.events = b
+	}
 }
 
 // New creates a new gRPC adapter.
-func New(service ports.CacheService) *Adapter {
-	return &Adapter{service: service}
+func New(service ports.CacheService, opts ...Option) *Adapter {
+	a := &Adapter{service: service}
+	for _, opt := range opts {
+		opt(a)
+	}
+	return a
 }
 
 // Get retrieves a value from the cache.
+// A miss is reported as codes.NotFound rather than an empty response.
 func (s *Adapter) Get(ctx context.Context, req *pb.GetRequest) (*pb.GetResponse, error) {
-	val, err := s.service.Get(ctx, req.Key)
+	val, version, err := s.service.GetVersioned(withConsistency(ctx, req.Consistency), req.Key)
 	if err != nil {
-		// Verify if it's a not found error or other error
-		// For simplicity, we assume error means not found for now, or we can check string
-		return &pb.GetResponse{Value: "", Found: false}, nil
+		return nil, s.statusOf(err)
 	}
-	return &pb.GetResponse{Value: val, Found: true}, nil
+	return &pb.GetResponse{Value: val, Found: true, Version: version}, nil
 }
 
 // Set stores a value in the cache.
 func (s *Adapter) Set(ctx context.Context, req *pb.SetRequest) (*pb.SetResponse, error) {
-	err := s.service.Set(ctx, req.Key, req.Value, time.Duration(req.Ttl)*time.Second)
+	cond := ports.Precondition{IfVersion: req.IfVersion, IfAbsent: req.IfAbsent}
+	version, err := s.service.SetIf(withOriginTime(withAck(withRequestID(ctx, req.RequestId), req.Ack), req.OriginTime), req.Key, req.Value, time.Duration(req.Ttl)*time.Second, cond)
 	if err != nil {
-		return &pb.SetResponse{Success: false}, err
+		return nil, s.statusOf(err)
 	}
-	return &pb.SetResponse{Success: true}, nil
+	return &pb.SetResponse{Success: true, Version: version}, nil
 }
 
 // Delete removes a value from the cache.
 func (s *Adapter) Delete(ctx context.Context, req *pb.DeleteRequest) (*pb.DeleteResponse, error) {
-	err := s.service.Delete(ctx, req.Key)
+	err := s.service.DeleteIf(withOriginTime(withAck(withRequestID(ctx, req.RequestId), req.Ack), req.OriginTime), req.Key, ports.Precondition{IfVersion: req.IfVersion})
 	if err != nil {
-		return &pb.DeleteResponse{Success: false}, err
+		return nil, s.statusOf(err)
 	}
 	return &pb.DeleteResponse{Success: true}, nil
 }
+
+// GetSet replaces a value and returns the previous one.
+func (s *Adapter) GetSet(ctx context.Context, req *pb.GetSetRequest) (*pb.GetSetResponse, error) {
+	old, found, err := s.service.GetSet(withRequestID(ctx, req.RequestId), req.Key, req.Value, time.Duration(req.Ttl)*time.Second)
+	if err != nil {
+		return nil, s.statusOf(err)
+	}
+	return &pb.GetSetResponse{OldValue: old, Found: found}, nil
+}
+
+// GetDel deletes a key and returns its value.
+func (s *Adapter) GetDel(ctx context.Context, req *pb.GetDelRequest) (*pb.GetDelResponse, error) {
+	val, err := s.service.GetDel(withRequestID(ctx, req.RequestId), req.Key)
+	if err != nil {
+		return nil, s.statusOf(err)
+	}
+	return &pb.GetDelResponse{Value: val}, nil
+}
+
+// GetOrSet returns a value, storing the given one if the key does not exist.
+func (s *Adapter) GetOrSet(ctx context.Context, req *pb.GetOrSetRequest) (*pb.GetOrSetResponse, error) {
+	val, loaded, err := s.service.GetOrSet(withRequestID(ctx, req.RequestId), req.Key, req.Value, time.Duration(req.Ttl)*time.Second)
+	if err != nil {
+		return nil, s.statusOf(err)
+	}
+	return &pb.GetOrSetResponse{Value: val, Loaded: loaded}, nil
+}
+
+// Append appends to a value and returns its new length.
+func (s *Adapter) Append(ctx context.Context, req *pb.AppendRequest) (*pb.AppendResponse, error) {
+	n, err := s.service.Append(withRequestID(ctx, req.RequestId), req.Key, req.Suffix)
+	if err != nil {
+		return nil, s.statusOf(err)
+	}
+	return &pb.AppendResponse{Length: int64(n)}, nil
+}
+
+// StrLen returns the length of a value.
+func (s *Adapter) StrLen(ctx context.Context, req *pb.StrLenRequest) (*pb.StrLenResponse, error) {
+	n, err := s.service.StrLen(withConsistency(ctx, req.Consistency), req.Key)
+	if err != nil {
+		return nil, s.statusOf(err)
+	}
+	return &pb.StrLenResponse{Length: int64(n)}, nil
+}
+
+// TTL returns the remaining lifetime of a key.
+func (s *Adapter) TTL(ctx context.Context, req *pb.TTLRequest) (*pb.TTLResponse, error) {
+	ttl, err := s.service.TTL(withConsistency(ctx, req.Consistency), req.Key)
+	if err != nil {
+		return nil, s.statusOf(err)
+	}
+	return &pb.TTLResponse{TtlMs: ttl.Milliseconds()}, nil
+}
+
+// Expire sets a key's expiration.
+func (s *Adapter) Expire(ctx context.Context, req *pb.ExpireRequest) (*pb.ExpireResponse, error) {
+	if err := s.service.Expire(withRequestID(ctx, req.RequestId), req.Key, time.Duration(req.Ttl)*time.Second); err != nil {
+		return nil, s.statusOf(err)
+	}
+	return &pb.ExpireResponse{}, nil
+}
+
+// Persist removes a key's expiration.
+func (s *Adapter) Persist(ctx context.Context, req *pb.PersistRequest) (*pb.PersistResponse, error) {
+	if err := s.service.Persist(withRequestID(ctx, req.RequestId), req.Key); err != nil {
+		return nil, s.statusOf(err)
+	}
+	return &pb.PersistResponse{}, nil
+}
+
+// ZAdd adds members to a sorted set.
+func (s *Adapter) ZAdd(ctx context.Context, req *pb.ZAddRequest) (*pb.ZAddResponse, error) {
+	members := make([]ports.ScoredMember, len(req.Members))
+	for i, m := range req.Members {
+		members[i] = ports.ScoredMember{Member: m.Member, Score: m.Score}
+	}
+	n, err := s.service.ZAdd(withRequestID(ctx, req.RequestId), req.Key, members...)
+	if err != nil {
+		return nil, s.statusOf(err)
+	}
+	return &pb.ZAddResponse{Added: int64(n)}, nil
+}
+
+// ZRange returns sorted set members by rank or, if by_score is set, by score.
+func (s *Adapter) ZRange(ctx context.Context, req *pb.ZRangeRequest) (*pb.ZRangeResponse, error) {
+	var (
+		members []ports.ScoredMember
+		err     error
+	)
+	ctx = withConsistency(ctx, req.Consistency)
+	if req.ByScore {
+		members, err = s.service.ZRangeByScore(ctx, req.Key, req.Min, req.Max, int(req.Limit))
+	} else {
+		members, err = s.service.ZRange(ctx, req.Key, int(req.Start), int(req.Stop))
+	}
+	if err != nil {
+		return nil, s.statusOf(err)
+	}
+	resp := &pb.ZRangeResponse{Members: make([]*pb.ScoredMember, len(members))}
+	for i, m := range members {
+		resp.Members[i] = &pb.ScoredMember{Member: m.Member, Score: m.Score}
+	}
+	return resp, nil
+}
+
+// ZScore returns the score of a sorted set member.
+func (s *Adapter) ZScore(ctx context.Context, req *pb.ZScoreRequest) (*pb.ZScoreResponse, error) {
+	score, found, err := s.service.ZScore(withConsistency(ctx, req.Consistency), req.Key, req.Member)
+	if err != nil {
+		return nil, s.statusOf(err)
+	}
+	return &pb.ZScoreResponse{Score: score, Found: found}, nil
+}
+
+// ZRemRangeByScore removes sorted set members by score.
+func (s *Adapter) ZRemRangeByScore(ctx context.Context, req *pb.ZRemRangeByScoreRequest) (*pb.ZRemRangeByScoreResponse, error) {
+	n, err := s.service.ZRemRangeByScore(withRequestID(ctx, req.RequestId), req.Key, req.Min, req.Max)
+	if err != nil {
+		return nil, s.statusOf(err)
+	}
+	return &pb.ZRemRangeByScoreResponse{Removed: int64(n)}, nil
+}
+
+// Eval runs a script and returns its result as JSON.
+func (s *Adapter) Eval(ctx context.Context, req *pb.EvalRequest) (*pb.EvalResponse, error) {
+	reply, err := s.service.Eval(withRequestID(ctx, req.RequestId), req.Script, req.Keys, req.Args)
+	if err != nil {
+		return nil, s.statusOf(err)
+	}
+	data, err := json.Marshal(reply)
+	if err != nil {
+		return nil, s.statusOf(err)
+	}
+	return &pb.EvalResponse{Result: string(data)}, nil
+}
+
+// Txn applies a compare-then-ops transaction.
+func (s *Adapter) Txn(ctx context.Context, req *pb.TxnRequest) (*pb.TxnResponse, error) {
+	txn := ports.Txn{
+		Compares: make([]ports.Compare, len(req.Compare)),
+		Success:  txnOps(req.Success),
+		Failure:  txnOps(req.Failure),
+	}
+	for i, c := range req.Compare {
+		txn.Compares[i] = ports.Compare{
+			Key: c.Key,
+			// The proto enums use the same numbering as ports; unknown values are rejected by the service.
+			Target:   ports.CompareTarget(c.Target),
+			Version:  c.Version,
+			Value:    c.Value,
+			NotEqual: c.Result == pb.Compare_NOT_EQUAL,
+		}
+	}
+	result, err := s.service.Txn(withRequestID(ctx, req.RequestId), txn)
+	if err != nil {
+		return nil, s.statusOf(err)
+	}
+	resp := &pb.TxnResponse{Succeeded: result.Succeeded, Results: make([]*pb.TxnOpResult, len(result.Results))}
+	for i, r := range result.Results {
+		resp.Results[i] = &pb.TxnOpResult{Value: r.Value, Found: r.Found, Version: r.Version}
+	}
+	return resp, nil
+}
+
+func txnOps(ops []*pb.TxnOp) []ports.TxnOp {
+	out := make([]ports.TxnOp, len(ops))
+	for i, op := range ops {
+		out[i] = ports.TxnOp{
+			Type:  ports.TxnOpType(op.Type),
+			Key:   op.Key,
+			Value: op.Value,
+			TTL:   time.Duration(op.Ttl) * time.Second,
+		}
+	}
+	return out
+}
+
+// withConsistency applies a request's consistency override, if any.
+func withConsistency(ctx context.Context, c pb.Consistency) context.Context {
+	switch c {
+	case pb.Consistency_CONSISTENCY_STRONG:
+		return service.ContextWithConsistency(ctx, service.ConsistencyStrong)
+	case pb.Consistency_CONSISTENCY_EVENTUAL:
+		return service.ContextWithConsistency(ctx, service.ConsistencyEventual)
+	}
+	return ctx
+}
+
+// withAck applies a write's ack level, if it asks for less than a quorum.
+func withAck(ctx context.Context, ack pb.Ack) context.Context {
+	if ack == pb.Ack_ACK_LEADER {
+		return service.ContextWithAck(ctx, service.AckLeader)
+	}
+	return ctx
+}
+
+func withRequestID(ctx context.Context, id string) context.Context {
+	if id == "" {
+		return ctx
+	}
+	return service.ContextWithRequestID(ctx, id)
+}
+
+func withOriginTime(ctx context.Context, unixNano int64) context.Context {
+	if unixNano == 0 {
+		return ctx
+	}
+	return service.ContextWithOriginTime(ctx, time.Unix(0, unixNano))
+}
+
+// Watch streams keyspace events to the client until it disconnects.
+// A SUBSCRIBED marker is sent first so the client knows from when it will
+// observe changes. If the client falls behind, the stream ends with
+// ResourceExhausted and the client must assume it missed events.
+func (s *Adapter) Watch(req *pb.WatchRequest, stream pb.CacheService_WatchServer) error {
+	if s.events == nil {
+		return status.Error(codes.Unimplemented, "keyspace events are not enabled")
+	}
+	sub := s.events.Subscribe(req.Prefix, watchBuffer)
+	defer sub.Close()
+
+	if err := stream.Send(&pb.KeyEvent{Type: pb.KeyEvent_SUBSCRIBED}); err != nil {
+		return err
+	}
+	for {
+		select {
+		case <-stream.Context().Done():
+			return s.statusOf(stream.Context().Err())
+		case e, ok := <-sub.Events():
+			if !ok {
+				return status.Error(codes.ResourceExhausted, "watcher fell behind, events were dropped")
+			}
+			if err := stream.Send(&pb.KeyEvent{Type: eventType(e.Type), Key: e.Key, Index: e.Index}); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// BulkLoad writes the entries streamed by the client in large batches until it
+// closes the stream. How many were committed is sent in the TrailerBulkLoaded
+// trailer, so that a client whose load failed knows where to resume.
+func (s *Adapter) BulkLoad(stream pb.CacheService_BulkLoadServer) error {
+	var pending []*pb.BulkLoadEntry
+	next := func() (ports.BulkEntry, error) {
+		for len(pending) == 0 {
+			req, err := stream.Recv()
+			if err != nil {
+				// io.EOF once the client has sent everything.
+				return ports.BulkEntry{}, err
+			}
+			pending = req.Entries
+		}
+		e := pending[0]
+		pending = pending[1:]
+		return ports.BulkEntry{Key: e.Key, Value: e.Value, TTL: time.Duration(e.Ttl) * time.Second}, nil
+	}
+	result, err := s.service.BulkLoad(stream.Context(), next)
+	stream.SetTrailer(metadata.Pairs(TrailerBulkLoaded, strconv.Itoa(result.Loaded)))
+	if err != nil {
+		if _, ok := status.FromError(err); ok {
+			// Receiving failed, e.g. the client cancelled the call.
+			return err
+		}
+		return s.statusOf(err)
+	}
+	return stream.SendAndClose(&pb.BulkLoadResponse{Loaded: int64(result.Loaded), Batches: int64(result.Batches)})
+}
+
+func eventType(t events.Type) pb.KeyEvent_Type {
+	switch t {
+	case events.Set:
+		return pb.KeyEvent_SET
+	case events.Delete:
+		return pb.KeyEvent_DELETE
+	default:
+		return pb.KeyEvent_FLUSH
+	}
+}
+
+// toStatus converts a service error into a gRPC status error.
+// Leadership errors map to Unavailable so that clients with a retry policy
+// can transparently retry against another node.
+func toStatus(err error) error {
+	return status.Error(Code(err), coreerrors.PublicMessage(err))
+}
+
+// Code maps an error to its gRPC status code.
+func Code(err error) codes.Code {
+	switch {
+	case err == nil:
+		return codes.OK
+	case errors.Is(err, coreerrors.ErrNotFound):
+		return codes.NotFound
+	case errors.Is(err, coreerrors.ErrEmptyKey), errors.Is(err, coreerrors.ErrKeyTooLarge), errors.Is(err, coreerrors.ErrInvalidArgument), errors.Is(err, coreerrors.ErrScript):
+		return codes.InvalidArgument
+	case errors.Is(err, coreerrors.ErrWrongType):
+		return codes.FailedPrecondition
+	case errors.Is(err, coreerrors.ErrUnsupported):
+		return codes.Unimplemented
+	case errors.Is(err, coreerrors.ErrNotLeader), errors.Is(err, coreerrors.ErrStaleRead), errors.Is(err, coreerrors.ErrUnavailable),
+		errors.Is(err, coreerrors.ErrNoQuorum), errors.Is(err, coreerrors.ErrWitness):
+		return codes.Unavailable
+	case errors.Is(err, coreerrors.ErrVersionMismatch):
+		return codes.FailedPrecondition
+	case errors.Is(err, coreerrors.ErrQuotaExceeded):
+		return codes.ResourceExhausted
+	case errors.Is(err, coreerrors.ErrTimeout), errors.Is(err, coreerrors.ErrApplyTimeout), errors.Is(err, context.DeadlineExceeded):
+		return codes.DeadlineExceeded
+	case errors.Is(err, context.Canceled):
+		return codes.Canceled
+	default:
+		return codes.Internal
+	}
+}
@@ -2,18 +2,41 @@ package grpc
 
 import (
 	"context"
-	"errors"
+	"fmt"
+	"io"
+	"reflect"
 	"testing"
 	"time"
 
+	coreerrors "distributed-cache-service/internal/core/errors"
+	"distributed-cache-service/internal/core/ports"
+	"distributed-cache-service/internal/core/service"
+	"distributed-cache-service/internal/store"
 	pb "distributed-cache-service/proto"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
 )
 
 type mockService struct {
-	getFunc    func(ctx context.Context, key string) (string, error)
-	setFunc    func(ctx context.Context, key, value string, ttl time.Duration) error
-	deleteFunc func(ctx context.Context, key string) error
-	joinFunc   func(ctx context.Context, id, addr string) error
+	getFunc      func(ctx context.Context, key string) (string, error)
+	setFunc      func(ctx context.Context, key, value string, ttl time.Duration) error
+	deleteFunc   func(ctx context.Context, key string) error
+	joinFunc     func(ctx context.Context, id, addr string) error
+	getSetFunc   func(ctx context.Context, key, value string, ttl time.Duration) (string, bool, error)
+	getDelFunc   func(ctx context.Context, key string) (string, error)
+	getOrSetFunc func(ctx context.Context, key, value string, ttl time.Duration) (string, bool, error)
+	appendFunc   func(ctx context.Context, key, suffix string) (int, error)
+	evalFunc     func(ctx context.Context, script string, keys, args []string) (interface{}, error)
+	txnFunc      func(ctx context.Context, txn ports.Txn) (ports.TxnResult, error)
+	bulkLoadFunc func(ctx context.Context, next func() (ports.BulkEntry, error)) (ports.BulkLoadResult, error)
+	zsets        *store.Store // backs the sorted set methods
+
+	version uint64             // reported by GetVersioned and SetIf
+	cond    ports.Precondition // last precondition passed to SetIf or DeleteIf
+	ttl     time.Duration      // last TTL passed to Expire, 0 after Persist
 }
 
 func (m *mockService) Get(ctx context.Context, key string) (string, error) {
@@ -25,17 +48,86 @@ func (m *mockService) Set(ctx context.Context, key, value string, ttl time.Durat
 func (m *mockService) Delete(ctx context.Context, key string) error {
 	return m.deleteFunc(ctx, key)
 }
-func (m *mockService) Join(ctx context.Context, id, addr string) error {
+func (m *mockService) Join(ctx context.Context, id, addr, grpcAddr string) error {
 	return m.joinFunc(ctx, id, addr)
 }
+func (m *mockService) GetVersioned(ctx context.Context, key string) (string, uint64, error) {
+	v, err := m.getFunc(ctx, key)
+	return v, m.version, err
+}
+func (m *mockService) SetIf(ctx context.Context, key, value string, ttl time.Duration, cond ports.Precondition) (uint64, error) {
+	m.cond = cond
+	return m.version, m.setFunc(ctx, key, value, ttl)
+}
+func (m *mockService) DeleteIf(ctx context.Context, key string, cond ports.Precondition) error {
+	m.cond = cond
+	return m.deleteFunc(ctx, key)
+}
+func (m *mockService) GetSet(ctx context.Context, key, value string, ttl time.Duration) (string, bool, error) {
+	return m.getSetFunc(ctx, key, value, ttl)
+}
+func (m *mockService) GetDel(ctx context.Context, key string) (string, error) {
+	return m.getDelFunc(ctx, key)
+}
+func (m *mockService) GetOrSet(ctx context.Context, key, value string, ttl time.Duration) (string, bool, error) {
+	return m.getOrSetFunc(ctx, key, value, ttl)
+}
+func (m *mockService) Append(ctx context.Context, key, suffix string) (int, error) {
+	return m.appendFunc(ctx, key, suffix)
+}
+func (m *mockService) StrLen(ctx context.Context, key string) (int, error) {
+	v, err := m.getFunc(ctx, key)
+	return len(v), err
+}
+func (m *mockService) ZAdd(ctx context.Context, key string, members ...ports.ScoredMember) (int, error) {
+	return m.zsets.ZAdd(key, time.Now(), members...)
+}
+func (m *mockService) ZRemRangeByScore(ctx context.Context, key string, min, max float64) (int, error) {
+	return m.zsets.ZRemRangeByScore(key, min, max, time.Now())
+}
+func (m *mockService) ZScore(ctx context.Context, key, member string) (float64, bool, error) {
+	return m.zsets.ZScore(key, member)
+}
+func (m *mockService) ZRange(ctx context.Context, key string, start, stop int) ([]ports.ScoredMember, error) {
+	return m.zsets.ZRange(key, start, stop)
+}
+func (m *mockService) Eval(ctx context.Context, script string, keys, args []string) (interface{}, error) {
+	return m.evalFunc(ctx, script, keys, args)
+}
+func (m *mockService) TTL(ctx context.Context, key string) (time.Duration, error) {
+	if key == "missing" {
+		return 0, coreerrors.ErrNotFound
+	}
+	return 90 * time.Second, nil
+}
+func (m *mockService) Expire(ctx context.Context, key string, ttl time.Duration) error {
+	m.ttl = ttl
+	return nil
+}
+func (m *mockService) Persist(ctx context.Context, key string) error {
+	m.ttl = 0
+	return nil
+}
+func (m *mockService) Txn(ctx context.Context, txn ports.Txn) (ports.TxnResult, error) {
+	return m.txnFunc(ctx, txn)
+}
+func (m *mockService) BulkLoad(ctx context.Context, next func() (ports.BulkEntry, error)) (ports.BulkLoadResult, error) {
+	return m.bulkLoadFunc(ctx, next)
+}
+func (m *mockService) ZRangeByScore(ctx context.Context, key string, min, max float64, limit int) ([]ports.ScoredMember, error) {
+	return m.zsets.ZRangeByScore(key, min, max, limit)
+}
 
 func TestAdapter_Get(t *testing.T) {
 	mock := &mockService{
 		getFunc: func(ctx context.Context, key string) (string, error) {
+			if key == "" {
+				return "", coreerrors.ErrEmptyKey
+			}
 			if key == "found" {
 				return "value", nil
 			}
-			return "", errors.New("not found")
+			return "", coreerrors.ErrNotFound
 		},
 	}
 	adapter := New(mock)
@@ -50,11 +142,383 @@ func TestAdapter_Get(t *testing.T) {
 	}
 
 	// Test Not Found
-	resp, err = adapter.Get(context.Background(), &pb.GetRequest{Key: "missing"})
-	if err != nil {
+	_, err = adapter.Get(context.Background(), &pb.GetRequest{Key: "missing"})
+	if status.Code(err) != codes.NotFound {
+		t.Errorf("expected NotFound, got %v", err)
+	}
+
+	// Test Empty Key
+	_, err = adapter.Get(context.Background(), &pb.GetRequest{Key: ""})
+	if status.Code(err) != codes.InvalidArgument {
+		t.Errorf("expected InvalidArgument, got %v", err)
+	}
+}
+
+func TestAdapter_Set_NotLeader(t *testing.T) {
+	mock := &mockService{
+		setFunc: func(ctx context.Context, key, value string, ttl time.Duration) error {
+			return fmt.Errorf("%w: raft", coreerrors.ErrNotLeader)
+		},
+	}
+	adapter := New(mock)
+
+	_, err := adapter.Set(context.Background(), &pb.SetRequest{Key: "k", Value: "v"})
+	if status.Code(err) != codes.Unavailable {
+		t.Errorf("expected Unavailable, got %v", err)
+	}
+}
+
+func TestAdapter_Set_RequestID(t *testing.T) {
+	var got string
+	mock := &mockService{
+		setFunc: func(ctx context.Context, key, value string, ttl time.Duration) error {
+			got = service.RequestIDFromContext(ctx)
+			return nil
+		},
+	}
+	adapter := New(mock)
+
+	if _, err := adapter.Set(context.Background(), &pb.SetRequest{Key: "k", Value: "v", RequestId: "req-1"}); err != nil {
 		t.Fatalf("unexpected error: %v", err)
 	}
-	if resp.Found {
-		t.Errorf("expected found=false")
+	if got != "req-1" {
+		t.Errorf("expected request ID to reach the service, got %q", got)
+	}
+}
+
+func TestAdapter_Ack(t *testing.T) {
+	var got service.AckLevel
+	mock := &mockService{
+		setFunc: func(ctx context.Context, key, value string, ttl time.Duration) error {
+			got, _ = service.AckFromContext(ctx)
+			return nil
+		},
+		deleteFunc: func(ctx context.Context, key string) error {
+			got, _ = service.AckFromContext(ctx)
+			return nil
+		},
+	}
+	adapter := New(mock)
+
+	if _, err := adapter.Set(context.Background(), &pb.SetRequest{Key: "k", Value: "v", Ack: pb.Ack_ACK_LEADER}); err != nil || got != service.AckLeader {
+		t.Errorf("expected ack=leader to reach the service, got %q (%v)", got, err)
+	}
+	got = ""
+	if _, err := adapter.Set(context.Background(), &pb.SetRequest{Key: "k", Value: "v"}); err != nil || got != "" {
+		t.Errorf("expected no ack level by default, got %q (%v)", got, err)
+	}
+	if _, err := adapter.Delete(context.Background(), &pb.DeleteRequest{Key: "k", Ack: pb.Ack_ACK_LEADER}); err != nil || got != service.AckLeader {
+		t.Errorf("expected ack=leader to reach the service, got %q (%v)", got, err)
+	}
+}
+
+func TestAdapter_Versions(t *testing.T) {
+	mock := &mockService{
+		version: 7,
+		getFunc: func(ctx context.Context, key string) (string, error) { return "v", nil },
+		setFunc: func(ctx context.Context, key, value string, ttl time.Duration) error {
+			if value == "stale" {
+				return fmt.Errorf("%w: key is at version 8", coreerrors.ErrVersionMismatch)
+			}
+			return nil
+		},
+	}
+	adapter := New(mock)
+	ctx := context.Background()
+
+	get, err := adapter.Get(ctx, &pb.GetRequest{Key: "k"})
+	if err != nil || get.Version != 7 {
+		t.Fatalf("expected version 7, got %v (%v)", get, err)
+	}
+
+	set, err := adapter.Set(ctx, &pb.SetRequest{Key: "k", Value: "v", IfVersion: 7})
+	if err != nil || set.Version != 7 {
+		t.Fatalf("expected version 7, got %v (%v)", set, err)
+	}
+	if mock.cond != (ports.Precondition{IfVersion: 7}) {
+		t.Errorf("expected precondition to reach the service, got %+v", mock.cond)
+	}
+
+	_, err = adapter.Set(ctx, &pb.SetRequest{Key: "k", Value: "stale", IfAbsent: true})
+	if status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("expected FailedPrecondition, got %v", err)
+	}
+	if !mock.cond.IfAbsent {
+		t.Error("expected IfAbsent to reach the service")
+	}
+}
+
+func TestAdapter_GetSetAndGetDel(t *testing.T) {
+	data := map[string]string{"k": "old"}
+	mock := &mockService{
+		getSetFunc: func(ctx context.Context, key, value string, ttl time.Duration) (string, bool, error) {
+			old, ok := data[key]
+			data[key] = value
+			return old, ok, nil
+		},
+		getDelFunc: func(ctx context.Context, key string) (string, error) {
+			v, ok := data[key]
+			if !ok {
+				return "", coreerrors.ErrNotFound
+			}
+			delete(data, key)
+			return v, nil
+		},
+	}
+	adapter := New(mock)
+	ctx := context.Background()
+
+	gs, err := adapter.GetSet(ctx, &pb.GetSetRequest{Key: "k", Value: "new"})
+	if err != nil || !gs.Found || gs.OldValue != "old" {
+		t.Fatalf("expected old value, got %v (%v)", gs, err)
+	}
+	gs, err = adapter.GetSet(ctx, &pb.GetSetRequest{Key: "fresh", Value: "v"})
+	if err != nil || gs.Found {
+		t.Fatalf("expected found=false for a new key, got %v (%v)", gs, err)
+	}
+
+	gd, err := adapter.GetDel(ctx, &pb.GetDelRequest{Key: "k"})
+	if err != nil || gd.Value != "new" {
+		t.Fatalf("expected new, got %v (%v)", gd, err)
+	}
+	if _, err := adapter.GetDel(ctx, &pb.GetDelRequest{Key: "k"}); status.Code(err) != codes.NotFound {
+		t.Errorf("expected NotFound, got %v", err)
+	}
+}
+
+func TestAdapter_GetOrSet(t *testing.T) {
+	data := map[string]string{"k": "old"}
+	var gotTTL time.Duration
+	mock := &mockService{
+		getOrSetFunc: func(ctx context.Context, key, value string, ttl time.Duration) (string, bool, error) {
+			if v, ok := data[key]; ok {
+				return v, true, nil
+			}
+			data[key], gotTTL = value, ttl
+			return value, false, nil
+		},
+	}
+	adapter := New(mock)
+	ctx := context.Background()
+
+	resp, err := adapter.GetOrSet(ctx, &pb.GetOrSetRequest{Key: "k", Value: "new"})
+	if err != nil || !resp.Loaded || resp.Value != "old" {
+		t.Fatalf("expected the existing value, got %v (%v)", resp, err)
+	}
+	resp, err = adapter.GetOrSet(ctx, &pb.GetOrSetRequest{Key: "fresh", Value: "v", Ttl: 5})
+	if err != nil || resp.Loaded || resp.Value != "v" || gotTTL != 5*time.Second {
+		t.Fatalf("expected v to be stored with a 5s TTL, got %v, %v (%v)", resp, gotTTL, err)
+	}
+}
+
+func TestAdapter_AppendAndStrLen(t *testing.T) {
+	value := "log:"
+	mock := &mockService{
+		appendFunc: func(ctx context.Context, key, suffix string) (int, error) {
+			value += suffix
+			return len(value), nil
+		},
+		getFunc: func(ctx context.Context, key string) (string, error) { return value, nil },
+	}
+	adapter := New(mock)
+	ctx := context.Background()
+
+	resp, err := adapter.Append(ctx, &pb.AppendRequest{Key: "k", Suffix: "abc"})
+	if err != nil || resp.Length != 7 {
+		t.Fatalf("expected length 7, got %v (%v)", resp, err)
+	}
+	n, err := adapter.StrLen(ctx, &pb.StrLenRequest{Key: "k"})
+	if err != nil || n.Length != 7 {
+		t.Fatalf("expected length 7, got %v (%v)", n, err)
+	}
+}
+
+func TestAdapter_SortedSets(t *testing.T) {
+	mock := &mockService{zsets: store.New()}
+	mock.zsets.Set("str", "value", 0)
+	adapter := New(mock)
+	ctx := context.Background()
+
+	added, err := adapter.ZAdd(ctx, &pb.ZAddRequest{Key: "board", Members: []*pb.ScoredMember{
+		{Member: "a", Score: 3}, {Member: "b", Score: 1}, {Member: "c", Score: 2},
+	}})
+	if err != nil || added.Added != 3 {
+		t.Fatalf("expected 3 added, got %v (%v)", added, err)
+	}
+
+	byRank, err := adapter.ZRange(ctx, &pb.ZRangeRequest{Key: "board", Start: 0, Stop: -1})
+	if err != nil || len(byRank.Members) != 3 || byRank.Members[0].Member != "b" {
+		t.Fatalf("unexpected rank range %v (%v)", byRank, err)
+	}
+	byScore, err := adapter.ZRange(ctx, &pb.ZRangeRequest{Key: "board", ByScore: true, Min: 2, Max: 3, Limit: 1})
+	if err != nil || len(byScore.Members) != 1 || byScore.Members[0].Member != "c" {
+		t.Fatalf("unexpected score range %v (%v)", byScore, err)
+	}
+	score, err := adapter.ZScore(ctx, &pb.ZScoreRequest{Key: "board", Member: "a"})
+	if err != nil || !score.Found || score.Score != 3 {
+		t.Fatalf("unexpected score %v (%v)", score, err)
+	}
+	removed, err := adapter.ZRemRangeByScore(ctx, &pb.ZRemRangeByScoreRequest{Key: "board", Min: 0, Max: 2})
+	if err != nil || removed.Removed != 2 {
+		t.Fatalf("expected 2 removed, got %v (%v)", removed, err)
+	}
+
+	_, err = adapter.ZRange(ctx, &pb.ZRangeRequest{Key: "str", Stop: -1})
+	if status.Code(err) != codes.FailedPrecondition {
+		t.Errorf("expected FailedPrecondition for a string key, got %v", err)
+	}
+}
+
+func TestAdapter_Eval(t *testing.T) {
+	mock := &mockService{
+		evalFunc: func(ctx context.Context, script string, keys, args []string) (interface{}, error) {
+			if script == "error('boom')" {
+				return nil, fmt.Errorf("%w: line 1: boom", coreerrors.ErrScript)
+			}
+			return []interface{}{keys[0], args[0], float64(1)}, nil
+		},
+	}
+	adapter := New(mock)
+	ctx := context.Background()
+
+	resp, err := adapter.Eval(ctx, &pb.EvalRequest{Script: "return {KEYS[1], ARGV[1], 1}", Keys: []string{"k"}, Args: []string{"a"}})
+	if err != nil || resp.Result != `["k","a",1]` {
+		t.Fatalf("unexpected result %v (%v)", resp, err)
+	}
+
+	_, err = adapter.Eval(ctx, &pb.EvalRequest{Script: "error('boom')"})
+	if status.Code(err) != codes.InvalidArgument || status.Convert(err).Message() != "script error: line 1: boom" {
+		t.Errorf("expected InvalidArgument with the script message, got %v", err)
+	}
+}
+
+func TestAdapter_Txn(t *testing.T) {
+	var got ports.Txn
+	mock := &mockService{
+		txnFunc: func(ctx context.Context, txn ports.Txn) (ports.TxnResult, error) {
+			got = txn
+			return ports.TxnResult{Succeeded: true, Results: []ports.TxnOpResult{{Version: 9}, {Value: "v", Found: true, Version: 4}}}, nil
+		},
+	}
+	adapter := New(mock)
+
+	resp, err := adapter.Txn(context.Background(), &pb.TxnRequest{
+		Compare: []*pb.Compare{
+			{Key: "a", Version: 4},
+			{Key: "b", Target: pb.Compare_VALUE, Result: pb.Compare_NOT_EQUAL, Value: "x"},
+		},
+		Success: []*pb.TxnOp{{Type: pb.TxnOp_SET, Key: "a", Value: "new", Ttl: 10}, {Type: pb.TxnOp_GET, Key: "b"}},
+		Failure: []*pb.TxnOp{{Type: pb.TxnOp_DELETE, Key: "a"}},
+	})
+	if err != nil || !resp.Succeeded || len(resp.Results) != 2 || resp.Results[1].Value != "v" {
+		t.Fatalf("unexpected response %v (%v)", resp, err)
+	}
+	want := ports.Txn{
+		Compares: []ports.Compare{
+			{Key: "a", Version: 4},
+			{Key: "b", Target: ports.CompareValue, Value: "x", NotEqual: true},
+		},
+		Success: []ports.TxnOp{{Type: ports.TxnSet, Key: "a", Value: "new", TTL: 10 * time.Second}, {Type: ports.TxnGet, Key: "b"}},
+		Failure: []ports.TxnOp{{Type: ports.TxnDelete, Key: "a"}},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %+v, got %+v", want, got)
+	}
+}
+
+func TestAdapter_TTL(t *testing.T) {
+	mock := &mockService{}
+	adapter := New(mock)
+	ctx := context.Background()
+
+	resp, err := adapter.TTL(ctx, &pb.TTLRequest{Key: "k"})
+	if err != nil || resp.TtlMs != 90000 {
+		t.Fatalf("expected 90000ms, got %v (%v)", resp, err)
+	}
+	if _, err := adapter.TTL(ctx, &pb.TTLRequest{Key: "missing"}); status.Code(err) != codes.NotFound {
+		t.Errorf("expected NotFound, got %v", err)
+	}
+
+	if _, err := adapter.Expire(ctx, &pb.ExpireRequest{Key: "k", Ttl: 30}); err != nil || mock.ttl != 30*time.Second {
+		t.Errorf("expected a 30s expiration, got %v (%v)", mock.ttl, err)
+	}
+	if _, err := adapter.Persist(ctx, &pb.PersistRequest{Key: "k"}); err != nil || mock.ttl != 0 {
+		t.Errorf("expected expiration to be removed, got %v (%v)", mock.ttl, err)
+	}
+}
+
+// bulkStream is the server side of a BulkLoad call sending reqs.
+type bulkStream struct {
+	grpc.ServerStream
+	reqs    []*pb.BulkLoadRequest
+	resp    *pb.BulkLoadResponse
+	trailer metadata.MD
+}
+
+func (s *bulkStream) Context() context.Context  { return context.Background() }
+func (s *bulkStream) SetTrailer(md metadata.MD) { s.trailer = metadata.Join(s.trailer, md) }
+func (s *bulkStream) Recv() (*pb.BulkLoadRequest, error) {
+	if len(s.reqs) == 0 {
+		return nil, io.EOF
+	}
+	req := s.reqs[0]
+	s.reqs = s.reqs[1:]
+	return req, nil
+}
+func (s *bulkStream) SendAndClose(resp *pb.BulkLoadResponse) error {
+	s.resp = resp
+	return nil
+}
+
+func TestAdapter_BulkLoad(t *testing.T) {
+	var got []ports.BulkEntry
+	var fail error
+	mock := &mockService{
+		bulkLoadFunc: func(ctx context.Context, next func() (ports.BulkEntry, error)) (ports.BulkLoadResult, error) {
+			for {
+				e, err := next()
+				if err == io.EOF {
+					return ports.BulkLoadResult{Loaded: len(got), Batches: 1}, nil
+				}
+				if err != nil {
+					return ports.BulkLoadResult{}, err
+				}
+				if fail != nil && len(got) == 2 {
+					return ports.BulkLoadResult{Loaded: 2, Batches: 1}, fail
+				}
+				got = append(got, e)
+			}
+		},
+	}
+	adapter := New(mock)
+
+	// Entries are read across messages, including empty ones.
+	stream := &bulkStream{reqs: []*pb.BulkLoadRequest{
+		{Entries: []*pb.BulkLoadEntry{{Key: "a", Value: "1", Ttl: 60}, {Key: "b", Value: "2"}}},
+		{},
+		{Entries: []*pb.BulkLoadEntry{{Key: "c", Value: "3"}}},
+	}}
+	if err := adapter.BulkLoad(stream); err != nil {
+		t.Fatal(err)
+	}
+	want := []ports.BulkEntry{{Key: "a", Value: "1", TTL: time.Minute}, {Key: "b", Value: "2"}, {Key: "c", Value: "3"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %+v, got %+v", want, got)
+	}
+	if stream.resp.GetLoaded() != 3 || stream.resp.GetBatches() != 1 {
+		t.Errorf("unexpected response %v", stream.resp)
+	}
+	if v := stream.trailer.Get(TrailerBulkLoaded); len(v) != 1 || v[0] != "3" {
+		t.Errorf("expected a trailer of 3, got %v", v)
+	}
+
+	// A failure reports what was written before it in the trailer.
+	got, fail = nil, fmt.Errorf("%w: namespace full", coreerrors.ErrQuotaExceeded)
+	stream = &bulkStream{reqs: []*pb.BulkLoadRequest{{Entries: []*pb.BulkLoadEntry{{Key: "a"}, {Key: "b"}, {Key: "c"}}}}}
+	if err := adapter.BulkLoad(stream); status.Code(err) != codes.ResourceExhausted {
+		t.Errorf("expected ResourceExhausted, got %v", err)
+	}
+	if v := stream.trailer.Get(TrailerBulkLoaded); len(v) != 1 || v[0] != "2" || stream.resp != nil {
+		t.Errorf("expected a trailer of 2 and no response, got %v and %v", v, stream.resp)
 	}
 }
@@ -0,0 +1,133 @@
+package grpc
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"sync"
+	"time"
+
+	"distributed-cache-service/internal/core/ports"
+	pb "distributed-cache-service/proto"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Chunk sizes of GetStream. Chunks stay under gRPC's default 4 MiB message
+// limit, so that values of any size can be read without raising it.
+const (
+	DefaultChunkSize = 1 << 20
+	MaxChunkSize     = 3 << 20
+)
+
+// DefaultMaxStreamValueSize caps a value written with SetStream unless
+// WithMaxStreamValueSize sets another limit. It matches gRPC's default limit
+// on a received message, which a streamed value would otherwise get around:
+// the server holds the value in memory until the client closes the stream,
+// and replicates it as a single Raft entry.
+const DefaultMaxStreamValueSize = 4 << 20
+
+// maxPooledValueBuffer is the largest buffer kept for reuse by SetStream, so
+// that one very large value is not held onto after its write.
+const maxPooledValueBuffer = 64 << 20
+
+// valueBuffers holds the buffers SetStream assembles values in, so that
+// writing large values one after another does not allocate each from scratch.
+var valueBuffers = sync.Pool{New: func() any { return new(bytes.Buffer) }}
+
+// GetStream sends the value of key in chunks of req.ChunkSize bytes. The
+// first chunk carries the version and the size of the value; an empty value
+// is sent as a single empty chunk.
+func (s *Adapter) GetStream(req *pb.GetStreamRequest, stream pb.CacheService_GetStreamServer) error {
+	size := int(req.ChunkSize)
+	switch {
+	case size < 0:
+		return status.Errorf(codes.InvalidArgument, "chunk_size must not be negative")
+	case size == 0:
+		size = DefaultChunkSize
+	case size > MaxChunkSize:
+		size = MaxChunkSize
+	}
+	val, version, err := s.service.GetVersioned(withConsistency(stream.Context(), req.Consistency), req.Key)
+	if err != nil {
+		return s.statusOf(err)
+	}
+
+	chunk := &pb.ValueChunk{Version: version, Size: int64(len(val))}
+	for off := 0; off == 0 || off < len(val); off += size {
+		// A sent message may still be read after Send returns, so every
+		// chunk gets its own data rather than reusing one buffer.
+		chunk.Data = []byte(val[off:min(off+size, len(val))])
+		if err := stream.Send(chunk); err != nil {
+			return err
+		}
+		chunk = &pb.ValueChunk{}
+	}
+	return nil
+}
+
+// WithMaxStreamValueSize caps the values written with SetStream at n bytes.
+func WithMaxStreamValueSize(n int) Option {
+	return func(a *Adapter) {
+		a.maxStreamValue = n
+	}
+}
+
+// SetStream assembles the value sent in chunks by the client and writes it
+// once the client closes the stream, with the key and options of the first
+// message's header. A value over the limit is refused as soon as it is
+// declared or received, before anything is replicated.
+func (s *Adapter) SetStream(stream pb.CacheService_SetStreamServer) error {
+	limit := s.maxStreamValue
+	if limit <= 0 {
+		limit = DefaultMaxStreamValueSize
+	}
+	first, err := stream.Recv()
+	if errors.Is(err, io.EOF) {
+		return status.Error(codes.InvalidArgument, "no header was sent")
+	}
+	if err != nil {
+		return err
+	}
+	header := first.GetHeader()
+	if header == nil {
+		return status.Error(codes.InvalidArgument, "the first message must carry a header")
+	}
+	if first.Size < 0 || first.Size > int64(limit) {
+		return status.Errorf(codes.ResourceExhausted, "values are limited to %d bytes", limit)
+	}
+
+	buf := valueBuffers.Get().(*bytes.Buffer)
+	defer func() {
+		if buf.Cap() <= maxPooledValueBuffer {
+			buf.Reset()
+			valueBuffers.Put(buf)
+		}
+	}()
+	// The buffer grows with the data received, not with the declared size,
+	// so that a client cannot make the server allocate for data it never
+	// sends.
+	req := first
+	for {
+		if buf.Len()+len(req.Data) > limit {
+			return status.Errorf(codes.ResourceExhausted, "values are limited to %d bytes", limit)
+		}
+		buf.Write(req.Data)
+		req, err = stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	ctx := withAck(withRequestID(stream.Context(), header.RequestId), header.Ack)
+	cond := ports.Precondition{IfVersion: header.IfVersion, IfAbsent: header.IfAbsent}
+	version, err := s.service.SetIf(ctx, header.Key, buf.String(), time.Duration(header.Ttl)*time.Second, cond)
+	if err != nil {
+		return s.statusOf(err)
+	}
+	return stream.SendAndClose(&pb.SetResponse{Success: true, Version: version})
+}
@@ -0,0 +1,173 @@
+package grpc
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	coreerrors "distributed-cache-service/internal/core/errors"
+	pb "distributed-cache-service/proto"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type chunkStream struct {
+	grpc.ServerStream
+	chunks []*pb.ValueChunk
+}
+
+func (s *chunkStream) Context() context.Context { return context.Background() }
+func (s *chunkStream) Send(c *pb.ValueChunk) error {
+	s.chunks = append(s.chunks, c)
+	return nil
+}
+
+type setStream struct {
+	grpc.ServerStream
+	reqs []*pb.SetStreamRequest
+	resp *pb.SetResponse
+}
+
+func (s *setStream) Context() context.Context { return context.Background() }
+func (s *setStream) Recv() (*pb.SetStreamRequest, error) {
+	if len(s.reqs) == 0 {
+		return nil, io.EOF
+	}
+	req := s.reqs[0]
+	s.reqs = s.reqs[1:]
+	return req, nil
+}
+func (s *setStream) SendAndClose(resp *pb.SetResponse) error {
+	s.resp = resp
+	return nil
+}
+
+func TestAdapter_GetStream(t *testing.T) {
+	values := map[string]string{"big": strings.Repeat("x", 10), "empty": ""}
+	mock := &mockService{
+		getFunc: func(ctx context.Context, key string) (string, error) {
+			v, ok := values[key]
+			if !ok {
+				return "", coreerrors.ErrNotFound
+			}
+			return v, nil
+		},
+		version: 7,
+	}
+	adapter := New(mock)
+
+	stream := &chunkStream{}
+	if err := adapter.GetStream(&pb.GetStreamRequest{Key: "big", ChunkSize: 4}, stream); err != nil {
+		t.Fatal(err)
+	}
+	var sizes []int
+	var got strings.Builder
+	for _, c := range stream.chunks {
+		sizes = append(sizes, len(c.Data))
+		got.Write(c.Data)
+	}
+	if len(sizes) != 3 || sizes[0] != 4 || sizes[2] != 2 || got.String() != values["big"] {
+		t.Errorf("expected chunks of 4, 4 and 2 bytes, got %v", sizes)
+	}
+	if first := stream.chunks[0]; first.Version != 7 || first.Size != 10 {
+		t.Errorf("expected version 7 and size 10 on the first chunk, got %v", first)
+	}
+	if last := stream.chunks[2]; last.Version != 0 || last.Size != 0 {
+		t.Errorf("expected only data after the first chunk, got %v", last)
+	}
+
+	// An empty value is still sent, as one chunk.
+	stream = &chunkStream{}
+	if err := adapter.GetStream(&pb.GetStreamRequest{Key: "empty"}, stream); err != nil || len(stream.chunks) != 1 {
+		t.Errorf("expected one empty chunk, got %v (%v)", stream.chunks, err)
+	}
+
+	if err := adapter.GetStream(&pb.GetStreamRequest{Key: "missing"}, &chunkStream{}); status.Code(err) != codes.NotFound {
+		t.Errorf("expected NotFound, got %v", err)
+	}
+	if err := adapter.GetStream(&pb.GetStreamRequest{Key: "big", ChunkSize: -1}, &chunkStream{}); status.Code(err) != codes.InvalidArgument {
+		t.Errorf("expected InvalidArgument, got %v", err)
+	}
+}
+
+func TestAdapter_SetStream(t *testing.T) {
+	var key, value string
+	var ttl time.Duration
+	mock := &mockService{
+		setFunc: func(ctx context.Context, k, v string, d time.Duration) error {
+			key, value, ttl = k, v, d
+			return nil
+		},
+		version: 3,
+	}
+	adapter := New(mock)
+
+	stream := &setStream{reqs: []*pb.SetStreamRequest{
+		{Header: &pb.SetRequest{Key: "k", Ttl: 60, IfVersion: 2}, Data: []byte("ab"), Size: 5},
+		{},
+		{Data: []byte("cde")},
+	}}
+	if err := adapter.SetStream(stream); err != nil {
+		t.Fatal(err)
+	}
+	if key != "k" || value != "abcde" || ttl != time.Minute || mock.cond.IfVersion != 2 {
+		t.Errorf("unexpected write of %q=%q for %v (%+v)", key, value, ttl, mock.cond)
+	}
+	if !stream.resp.GetSuccess() || stream.resp.GetVersion() != 3 {
+		t.Errorf("unexpected response %v", stream.resp)
+	}
+
+	// The buffer is reused without keeping the last value.
+	stream = &setStream{reqs: []*pb.SetStreamRequest{{Header: &pb.SetRequest{Key: "k"}, Data: []byte("z")}}}
+	if err := adapter.SetStream(stream); err != nil || value != "z" {
+		t.Errorf("expected z, got %q (%v)", value, err)
+	}
+
+	for name, reqs := range map[string][]*pb.SetStreamRequest{
+		"no messages": nil,
+		"no header":   {{Data: []byte("a")}},
+	} {
+		if err := adapter.SetStream(&setStream{reqs: reqs}); status.Code(err) != codes.InvalidArgument {
+			t.Errorf("%s: expected InvalidArgument, got %v", name, err)
+		}
+	}
+	tooLarge := &setStream{reqs: []*pb.SetStreamRequest{{Header: &pb.SetRequest{Key: "k"}, Size: DefaultMaxStreamValueSize + 1}}}
+	if err := adapter.SetStream(tooLarge); status.Code(err) != codes.ResourceExhausted {
+		t.Errorf("expected ResourceExhausted, got %v", err)
+	}
+}
+
+func TestAdapter_SetStreamLimit(t *testing.T) {
+	writes := 0
+	mock := &mockService{setFunc: func(context.Context, string, string, time.Duration) error {
+		writes++
+		return nil
+	}}
+	adapter := New(mock, WithMaxStreamValueSize(4))
+
+	// A declared size over the limit is refused before any data is read.
+	declared := &setStream{reqs: []*pb.SetStreamRequest{{Header: &pb.SetRequest{Key: "k"}, Size: 5}}}
+	if err := adapter.SetStream(declared); status.Code(err) != codes.ResourceExhausted {
+		t.Errorf("expected ResourceExhausted for the declared size, got %v", err)
+	}
+	// So is a value that outgrows it, whatever size was declared.
+	sent := &setStream{reqs: []*pb.SetStreamRequest{
+		{Header: &pb.SetRequest{Key: "k"}, Data: []byte("abc"), Size: 1},
+		{Data: []byte("de")},
+	}}
+	if err := adapter.SetStream(sent); status.Code(err) != codes.ResourceExhausted {
+		t.Errorf("expected ResourceExhausted for the data sent, got %v", err)
+	}
+	if writes != 0 {
+		t.Errorf("expected nothing written, got %d writes", writes)
+	}
+
+	ok := &setStream{reqs: []*pb.SetStreamRequest{{Header: &pb.SetRequest{Key: "k"}, Data: []byte("abcd"), Size: 4}}}
+	if err := adapter.SetStream(ok); err != nil || writes != 1 {
+		t.Errorf("expected a value at the limit to be written, got %v", err)
+	}
+}
@@ -0,0 +1,1277 @@
+// Package http is the HTTP transport of the cache: it declares the routes of
+// the HTTP API on a router.Router and translates requests into calls on the
+// core service, like package grpc does for the gRPC API.
+//
+// API routes are served under Version, and at their original unversioned
+// paths as deprecated aliases so that existing clients and peers running
+// older releases keep working.
+package http
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"regexp"
+	"strconv"
+	"time"
+
+	"distributed-cache-service/internal/backup"
+	"distributed-cache-service/internal/config"
+	coreerrors "distributed-cache-service/internal/core/errors"
+	"distributed-cache-service/internal/core/ports"
+	"distributed-cache-service/internal/core/service"
+	"distributed-cache-service/internal/discovery"
+	"distributed-cache-service/internal/events"
+	"distributed-cache-service/internal/migrate"
+	"distributed-cache-service/internal/observability"
+	"distributed-cache-service/internal/peers"
+	"distributed-cache-service/internal/router"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Version prefixes the paths of the current API.
+const Version = "/v1"
+
+// ClusterVersioner shows and raises the command version the cluster writes
+// its log at.
+type ClusterVersioner interface {
+	ports.ClusterVersioner
+	SetClusterVersion(ctx context.Context, version uint32) error
+}
+
+// Adapter serves the HTTP API.
+type Adapter struct {
+	service ports.CacheService
+
+	self     *discovery.Member
+	isMember func() bool
+
+	config         http.Handler
+	cluster        ports.ClusterAdmin
+	storage        ports.SnapshotStorage
+	backupDest     string
+	decode         func(stored string) (string, error)
+	clusterVersion ClusterVersioner
+
+	events     *events.Broker
+	origins    []string
+	allowFrame func() bool
+
+	hotKeys    func(n int) []ports.KeyCount
+	ready      func() error
+	namespaces func(ctx context.Context) ([]peers.Reply[[]ports.NamespaceStats], error)
+	nodeStats  func(ctx context.Context) ([]peers.Reply[ports.NodeStats], error)
+}
+
+// Option configures optional adapter behaviour.
+type Option func(*Adapter)
+
+// WithNode enables /node, which identifies this node to peers forming a
+// cluster. isMember reports whether the node has joined a cluster yet; /join
+// answers 409 Conflict until it has. A nil isMember means it always has.
+func WithNode(self discovery.Member, isMember func() bool) Option {
+	return func(a *Adapter) {
+		a.self = &self
+		a.isMember = isMember
+	}
+}
+
+// WithConfig serves the runtime configuration from h at /admin/config.
+func WithConfig(h http.Handler) Option {
+	return func(a *Adapter) {
+		a.config = h
+	}
+}
+
+// WithAdmin enables the cluster, snapshot, backup, export and key listing
+// admin routes.
+func WithAdmin(cluster ports.ClusterAdmin, storage ports.SnapshotStorage) Option {
+	return func(a *Adapter) {
+		a.cluster = cluster
+		a.storage = storage
+	}
+}
+
+// WithBackupDest sets the location /admin/backup writes to when the request
+// names none.
+func WithBackupDest(dest string) Option {
+	return func(a *Adapter) {
+		a.backupDest = dest
+	}
+}
+
+// WithDecoder sets how /admin/export turns a value as kept in the store back
+// into the one written. Values are exported as stored by default.
+func WithDecoder(decode func(stored string) (string, error)) Option {
+	return func(a *Adapter) {
+		a.decode = decode
+	}
+}
+
+// WithClusterVersion enables /admin/cluster_version.
+func WithClusterVersion(v ClusterVersioner) Option {
+	return func(a *Adapter) {
+		a.clusterVersion = v
+	}
+}
+
+// WithHotKeys enables /admin/hotkeys, which lists the n most read keys as
+// top reports them.
+func WithHotKeys(top func(n int) []ports.KeyCount) Option {
+	return func(a *Adapter) {
+		a.hotKeys = top
+	}
+}
+
+// WithReadiness makes /readyz answer 503 Service Unavailable while ready
+// returns an error, e.g. because the node has lost quorum.
+func WithReadiness(ready func() error) Option {
+	return func(a *Adapter) {
+		a.ready = ready
+	}
+}
+
+// WithNamespaceStats enables /stats/namespaces, which merges the namespace
+// stats gather collects from every cluster member.
+func WithNamespaceStats(gather func(ctx context.Context) ([]peers.Reply[[]ports.NamespaceStats], error)) Option {
+	return func(a *Adapter) {
+		a.namespaces = gather
+	}
+}
+
+// WithClusterStats enables /cluster/stats, which reports the node stats
+// gather collects from every cluster member.
+func WithClusterStats(gather func(ctx context.Context) ([]peers.Reply[ports.NodeStats], error)) Option {
+	return func(a *Adapter) {
+		a.nodeStats = gather
+	}
+}
+
+// New creates a new HTTP adapter.
+func New(service ports.CacheService, opts ...Option) *Adapter {
+	a := &Adapter{
+		service: service,
+		decode:  func(stored string) (string, error) { return stored, nil },
+	}
+	for _, opt := range opts {
+		opt(a)
+	}
+	return a
+}
+
+// Register declares the adapter's routes on rt: the API routes and the
+// WebSocket API under Version and at their deprecated unversioned aliases, the
+// admin UI, and the operational /health, /readyz and /metrics.
+func (a *Adapter) Register(rt *router.Router) {
+	for _, route := range append(a.routes(), a.webSocketRoute()) {
+		alias := route
+		route.Path = Version + route.Path
+		rt.Handle(route)
+
+		alias.Deprecated = true
+		alias.Description = joinSentences(alias.Description, "Use "+route.Path+" instead.")
+		rt.Handle(alias)
+	}
+	rt.Handle(uiRoute())
+
+	rt.Handle(router.Route{
+		Path:      "/health",
+		Summary:   "Health check",
+		Tag:       "operations",
+		Responses: []router.Response{{Status: http.StatusOK, Description: "ok"}},
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			writeText(w, "ok")
+		}),
+	})
+	rt.Handle(router.Route{
+		Path:        "/readyz",
+		Summary:     "Readiness check",
+		Description: "Fails while the node cannot serve writes or strong reads, e.g. because it has lost quorum.",
+		Tag:         "operations",
+		Responses: []router.Response{
+			{Status: http.StatusOK, Description: "ok"},
+			{Status: http.StatusServiceUnavailable, Description: "Why the node is not ready"},
+		},
+		Handler: http.HandlerFunc(a.readyz),
+	})
+	rt.Handle(router.Route{
+		Path:      "/metrics",
+		Summary:   "Prometheus metrics",
+		Tag:       "operations",
+		Responses: []router.Response{{Status: http.StatusOK, Description: "Metrics in the Prometheus exposition format"}},
+		Handler:   promhttp.Handler(),
+	})
+}
+
+func (a *Adapter) readyz(w http.ResponseWriter, r *http.Request) {
+	if a.ready != nil {
+		if err := a.ready(); err != nil {
+			http.Error(w, coreerrors.PublicMessage(err), http.StatusServiceUnavailable)
+			return
+		}
+	}
+	writeText(w, "ok")
+}
+
+func joinSentences(a, b string) string {
+	if a == "" {
+		return b
+	}
+	return a + " " + b
+}
+
+var (
+	keyParam   = router.Param{Name: "key", Required: true}
+	valueParam = router.Param{Name: "value"}
+	ttlParam   = router.Param{Name: "ttl", Type: "integer", Description: "Lifetime in seconds; 0 or absent keeps the key until it is deleted"}
+	etagHeader = map[string]string{"ETag": "The key's version"}
+
+	// writeParams are read by writeContext.
+	writeParams = []router.Param{
+		{Name: "X-Request-ID", In: "header", Description: "Makes retries of the write idempotent"},
+		{Name: "timeout", Description: "Bounds the wait for the write to commit, as a Go duration such as 500ms"},
+		{Name: "ack", Enum: []string{string(service.AckQuorum), string(service.AckLeader)}, Description: "How far the write must get before it is acknowledged"},
+	}
+	// readParams are read by readContext.
+	readParams = []router.Param{
+		{Name: "consistency", Enum: []string{string(service.ConsistencyStrong), string(service.ConsistencyEventual)}, Description: "Overrides -consistency for this read"},
+	}
+)
+
+func params(groups ...[]router.Param) []router.Param {
+	var all []router.Param
+	for _, g := range groups {
+		all = append(all, g...)
+	}
+	return all
+}
+
+// routes declares the API routes, at their unversioned paths. Key and sorted
+// set routes answer any method, as they always have, and are documented as
+// GET.
+func (a *Adapter) routes() []router.Route {
+	routes := []router.Route{
+		{
+			Path:    "/set",
+			Summary: "Set a key",
+			Tag:     "keys",
+			Limited: true,
+			Params: params([]router.Param{keyParam, valueParam, ttlParam,
+				{Name: "version", Type: "integer", Description: "Only write if the key is at this version, like If-Match"},
+				{Name: "If-Match", In: "header", Description: "Only write if the key's ETag matches"},
+				{Name: "If-None-Match", In: "header", Enum: []string{"*"}, Description: "Only write if the key does not exist"},
+			}, writeParams),
+			Responses: []router.Response{
+				{Status: http.StatusOK, Description: "ok", Headers: etagHeader},
+				{Status: http.StatusPreconditionFailed, Description: "The precondition does not hold"},
+			},
+			Handler: http.HandlerFunc(a.set),
+		},
+		{
+			Path:    "/get",
+			Summary: "Get a key",
+			Tag:     "keys",
+			Limited: true,
+			Params: params([]router.Param{keyParam,
+				{Name: "If-None-Match", In: "header", Description: "An ETag from an earlier /get"},
+			}, readParams),
+			Responses: []router.Response{
+				{Status: http.StatusOK, Description: "The value", Headers: etagHeader},
+				{Status: http.StatusNotModified, Description: "The key is still at the version If-None-Match names"},
+				{Status: http.StatusNotFound, Description: "No such key"},
+			},
+			Handler: http.HandlerFunc(a.get),
+		},
+		{
+			Path:    "/getset",
+			Summary: "Set a key, returning its old value",
+			Tag:     "keys",
+			Limited: true,
+			Params:  params([]router.Param{keyParam, valueParam}, writeParams),
+			Responses: []router.Response{
+				{Status: http.StatusOK, Description: "The old value"},
+				{Status: http.StatusNoContent, Description: "The key did not exist"},
+			},
+			Handler: http.HandlerFunc(a.getSet),
+		},
+		{
+			Path:    "/getorset",
+			Summary: "Get a key, setting it first if it does not exist",
+			Tag:     "keys",
+			Limited: true,
+			Params:  params([]router.Param{keyParam, valueParam, ttlParam}, writeParams),
+			Responses: []router.Response{
+				{Status: http.StatusOK, Description: "The existing value"},
+				{Status: http.StatusCreated, Description: "The value, which was just set"},
+			},
+			Handler: http.HandlerFunc(a.getOrSet),
+		},
+		{
+			Path:    "/getdel",
+			Summary: "Delete a key, returning its value",
+			Tag:     "keys",
+			Limited: true,
+			Params:  params([]router.Param{keyParam}, writeParams),
+			Responses: []router.Response{
+				{Status: http.StatusOK, Description: "The deleted value"},
+				{Status: http.StatusNotFound, Description: "No such key"},
+			},
+			Handler: http.HandlerFunc(a.getDel),
+		},
+		{
+			Path:      "/append",
+			Summary:   "Append to a key's value",
+			Tag:       "keys",
+			Limited:   true,
+			Params:    params([]router.Param{keyParam, valueParam}, writeParams),
+			Responses: []router.Response{{Status: http.StatusOK, Description: "The value's new length"}},
+			Handler:   http.HandlerFunc(a.appendValue),
+		},
+		{
+			Path:      "/strlen",
+			Summary:   "Get the length of a key's value",
+			Tag:       "keys",
+			Limited:   true,
+			Params:    params([]router.Param{keyParam}, readParams),
+			Responses: []router.Response{{Status: http.StatusOK, Description: "The length, 0 if the key does not exist"}},
+			Handler:   http.HandlerFunc(a.strLen),
+		},
+		{
+			Path:    "/ttl",
+			Summary: "Get a key's remaining lifetime",
+			Tag:     "keys",
+			Limited: true,
+			Params:  params([]router.Param{keyParam}, readParams),
+			Responses: []router.Response{
+				{Status: http.StatusOK, Description: "Whole seconds, rounded up, or -1 if the key does not expire"},
+				{Status: http.StatusNotFound, Description: "No such key"},
+			},
+			Handler: http.HandlerFunc(a.ttl),
+		},
+		{
+			Path:    "/expire",
+			Summary: "Set a key's lifetime",
+			Tag:     "keys",
+			Limited: true,
+			Params: params([]router.Param{keyParam,
+				{Name: "ttl", Type: "integer", Required: true, Description: "Lifetime in seconds"},
+			}, writeParams),
+			Responses: []router.Response{
+				{Status: http.StatusOK, Description: "ok"},
+				{Status: http.StatusNotFound, Description: "No such key"},
+			},
+			Handler: http.HandlerFunc(a.expire),
+		},
+		{
+			Path:    "/persist",
+			Summary: "Remove a key's lifetime",
+			Tag:     "keys",
+			Limited: true,
+			Params:  params([]router.Param{keyParam}, writeParams),
+			Responses: []router.Response{
+				{Status: http.StatusOK, Description: "ok"},
+				{Status: http.StatusNotFound, Description: "No such key"},
+			},
+			Handler: http.HandlerFunc(a.persist),
+		},
+		{
+			Path:    "/zadd",
+			Summary: "Add members to a sorted set",
+			Tag:     "sorted sets",
+			Limited: true,
+			Params: params([]router.Param{keyParam,
+				{Name: "member", Required: true, Repeated: true, Description: "Paired with score by position"},
+				{Name: "score", Type: "number", Required: true, Repeated: true},
+			}, writeParams),
+			Responses: []router.Response{{Status: http.StatusOK, Description: "The number of members added"}},
+			Handler:   http.HandlerFunc(a.zAdd),
+		},
+		{
+			Path:        "/zrange",
+			Summary:     "List members of a sorted set",
+			Description: "Selects by score if min or max is given, and by rank otherwise.",
+			Tag:         "sorted sets",
+			Limited:     true,
+			Params: params([]router.Param{keyParam,
+				{Name: "start", Type: "integer", Description: "First rank, counting from the end if negative"},
+				{Name: "stop", Type: "integer", Description: "Last rank, -1 by default"},
+				{Name: "min", Description: "Lowest score, or -inf"},
+				{Name: "max", Description: "Highest score, or +inf"},
+				{Name: "limit", Type: "integer", Description: "Most members to return when selecting by score"},
+			}, readParams),
+			Responses: []router.Response{{Status: http.StatusOK, Description: "Members in score order", Schema: []ports.ScoredMember{}}},
+			Handler:   http.HandlerFunc(a.zRange),
+		},
+		{
+			Path:    "/zscore",
+			Summary: "Get a sorted set member's score",
+			Tag:     "sorted sets",
+			Limited: true,
+			Params:  params([]router.Param{keyParam, {Name: "member", Required: true}}, readParams),
+			Responses: []router.Response{
+				{Status: http.StatusOK, Description: "The score"},
+				{Status: http.StatusNotFound, Description: "No such member"},
+			},
+			Handler: http.HandlerFunc(a.zScore),
+		},
+		{
+			Path:    "/zremrangebyscore",
+			Summary: "Remove sorted set members by score",
+			Tag:     "sorted sets",
+			Limited: true,
+			Params: params([]router.Param{keyParam,
+				{Name: "min", Description: "Lowest score, or -inf"},
+				{Name: "max", Description: "Highest score, or +inf"},
+			}, writeParams),
+			Responses: []router.Response{{Status: http.StatusOK, Description: "The number of members removed"}},
+			Handler:   http.HandlerFunc(a.zRemRangeByScore),
+		},
+		{
+			Method:  http.MethodPost,
+			Path:    "/eval",
+			Summary: "Run a Lua script atomically",
+			Tag:     "scripting",
+			Limited: true,
+			Params: params([]router.Param{
+				{Name: "key", Repeated: true, Description: "The script's KEYS"},
+				{Name: "arg", Repeated: true, Description: "The script's ARGV"},
+			}, writeParams),
+			Body:      &router.Body{Description: "The script", Required: true},
+			Responses: []router.Response{{Status: http.StatusOK, Description: "The script's result", ContentType: "application/json"}},
+			Handler:   http.HandlerFunc(a.eval),
+		},
+		{
+			Path:    "/join",
+			Summary: "Add a node to the cluster",
+			Tag:     "cluster",
+			Audited: true,
+			Params: []router.Param{
+				{Name: "node_id", Required: true},
+				{Name: "addr", Required: true, Description: "The node's Raft address"},
+			},
+			Responses: []router.Response{
+				{Status: http.StatusOK, Description: "joined"},
+				{Status: http.StatusConflict, Description: "This node is not a cluster member yet"},
+			},
+			Handler: http.HandlerFunc(a.join),
+		},
+	}
+	if a.self != nil {
+		routes = append(routes, router.Route{
+			Path:        "/node",
+			Summary:     "Node identity",
+			Description: "Used by peers forming a cluster with -bootstrap_expect.",
+			Tag:         "cluster",
+			Responses:   []router.Response{{Status: http.StatusOK, Schema: discovery.Member{}}},
+			Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				writeJSON(w, a.self)
+			}),
+		})
+	}
+	return append(routes, a.adminRoutes()...)
+}
+
+// adminRoutes declares the token protected routes the adapter's options
+// enable.
+func (a *Adapter) adminRoutes() []router.Route {
+	var routes []router.Route
+	if a.config != nil {
+		configResponses := []router.Response{{Status: http.StatusOK, Description: "The runtime configuration", Schema: config.Runtime{}}}
+		routes = append(routes, router.Route{
+			Method:    http.MethodGet,
+			Path:      "/admin/config",
+			Summary:   "Show the runtime configuration",
+			Tag:       "admin",
+			Admin:     true,
+			Audited:   true,
+			Responses: configResponses,
+			Handler:   a.config,
+		})
+		for _, method := range []string{http.MethodPost, http.MethodPut} {
+			routes = append(routes, router.Route{
+				Method:    method,
+				Path:      "/admin/config",
+				Summary:   "Change the runtime configuration",
+				Tag:       "admin",
+				Admin:     true,
+				Audited:   true,
+				Body:      &router.Body{ContentType: "application/json", Description: "The settings to change", Required: true},
+				Responses: configResponses,
+				Handler:   a.config,
+			})
+		}
+	}
+	if a.cluster != nil {
+		routes = append(routes, router.Route{
+			Method:      http.MethodPost,
+			Path:        "/admin/snapshot",
+			Summary:     "Force a Raft snapshot",
+			Description: "For example before an upgrade.",
+			Tag:         "admin",
+			Admin:       true,
+			Audited:     true,
+			Responses:   []router.Response{{Status: http.StatusOK, Description: "The snapshot taken", Schema: ports.SnapshotInfo{}}},
+			Handler:     http.HandlerFunc(a.snapshot),
+		}, router.Route{
+			Method:    http.MethodGet,
+			Path:      "/admin/cluster",
+			Summary:   "Show the cluster's members and leader, as this node sees them",
+			Tag:       "admin",
+			Admin:     true,
+			Responses: []router.Response{{Status: http.StatusOK, Schema: ClusterStatus{}}},
+			Handler:   http.HandlerFunc(a.clusterStatus),
+		}, router.Route{
+			Path:      "/admin/snapshots",
+			Summary:   "List local snapshots with index and size metadata",
+			Tag:       "admin",
+			Admin:     true,
+			Responses: []router.Response{{Status: http.StatusOK, Schema: []ports.SnapshotInfo{}}},
+			Handler:   http.HandlerFunc(a.listSnapshots),
+		})
+		if _, ok := a.cluster.(ports.Reaper); ok {
+			routes = append(routes, router.Route{
+				Method:      http.MethodGet,
+				Path:        "/admin/cluster/dead",
+				Summary:     "List the members the leader has lost contact with for longer than -raft_reap_after",
+				Description: "Only the leader tracks them. Unless -raft_reap_confirm is set, they are removed soon after they appear.",
+				Tag:         "admin",
+				Admin:       true,
+				Responses:   []router.Response{{Status: http.StatusOK, Schema: []ports.DeadMember{}}},
+				Handler:     http.HandlerFunc(a.deadMembers),
+			}, router.Route{
+				Method:      http.MethodPost,
+				Path:        "/admin/cluster/reap",
+				Summary:     "Remove a dead member from the cluster",
+				Description: "Confirms the removal of a member listed by /admin/cluster/dead. Other members are refused.",
+				Tag:         "admin",
+				Admin:       true,
+				Audited:     true,
+				Params:      []router.Param{{Name: "id", Required: true, Description: "The member's node ID"}},
+				Responses:   []router.Response{{Status: http.StatusOK, Description: "removed"}},
+				Handler:     http.HandlerFunc(a.reap),
+			})
+		}
+	}
+	if a.storage != nil {
+		routes = append(routes, router.Route{
+			Method:  http.MethodPost,
+			Path:    "/admin/backup",
+			Summary: "Stream a consistent backup of the store to a file or S3-compatible store",
+			Tag:     "admin",
+			Admin:   true,
+			Audited: true,
+			Params: []router.Param{
+				{Name: "dest", Description: "A path or s3:// URL; -backup_dest by default"},
+			},
+			Responses: []router.Response{{Status: http.StatusOK, Description: "Where the backup was written", Schema: map[string]string{}}},
+			Handler:   http.HandlerFunc(a.backup),
+		}, router.Route{
+			Method:      http.MethodGet,
+			Path:        "/admin/export",
+			Summary:     "Stream the keyspace as Redis commands",
+			Description: "For redis-cli --pipe or cachectl import.",
+			Tag:         "admin",
+			Admin:       true,
+			Audited:     true,
+			Responses:   []router.Response{{Status: http.StatusOK, Description: "RESP-encoded commands", ContentType: "application/octet-stream"}},
+			Handler:     http.HandlerFunc(a.export),
+		}, router.Route{
+			Method:      http.MethodGet,
+			Path:        "/admin/stats",
+			Summary:     "Show the node's key count and read counters",
+			Description: "Hits and misses count since the node started; sample them to get a hit ratio.",
+			Tag:         "admin",
+			Admin:       true,
+			Responses:   []router.Response{{Status: http.StatusOK, Schema: Stats{}}},
+			Handler:     http.HandlerFunc(a.stats),
+		})
+		if _, ok := a.storage.(ports.MemoryStorage); ok {
+			routes = append(routes, router.Route{
+				Method:  http.MethodGet,
+				Path:    "/stats/memory",
+				Summary: "Show the memory the keys on this node take up, with the largest keys",
+				Description: "Sizes are estimates: each key's name and value plus the typical overhead of storing it. The total is kept as keys are written; " +
+					"listing the largest keys visits the whole in-memory keyspace, so pass n=0 to read the total alone.",
+				Tag:       "admin",
+				Admin:     true,
+				Params:    []router.Param{{Name: "n", Type: "integer", Description: "How many of the largest keys to list; 10 by default, at most 1000"}},
+				Responses: []router.Response{{Status: http.StatusOK, Schema: ports.MemoryStats{}}},
+				Handler:   http.HandlerFunc(a.memoryStats),
+			}, router.Route{
+				Method:      http.MethodGet,
+				Path:        "/memory/usage",
+				Summary:     "Show the memory a key takes up",
+				Description: "Like Redis's MEMORY USAGE: an estimate of the key's name and value plus the typical overhead of storing it.",
+				Tag:         "admin",
+				Admin:       true,
+				Params:      []router.Param{{Name: "key", Required: true}},
+				Responses: []router.Response{
+					{Status: http.StatusOK, Schema: ports.KeyMemory{}},
+					{Status: http.StatusNotFound, Description: "No such key"},
+				},
+				Handler: http.HandlerFunc(a.memoryUsage),
+			})
+		}
+		if _, ok := a.storage.(ports.ScanStorage); ok {
+			routes = append(routes, router.Route{
+				Method:  http.MethodGet,
+				Path:    "/admin/keys",
+				Summary: "List the keys on this node, in order, with their size, TTL and version",
+				Description: "Values are left out unless reveal is true. Pass the cursor of a page to get the next one; " +
+					"the last page has none. Every page visits the whole in-memory keyspace, so keep it to browsing.",
+				Tag:     "admin",
+				Admin:   true,
+				Audited: true,
+				Params: []router.Param{
+					{Name: "prefix", Description: "Only list keys starting with prefix"},
+					{Name: "match", Description: "Only list keys matching this regular expression (RE2 syntax)"},
+					{Name: "cursor", Description: "The cursor of the previous page"},
+					{Name: "limit", Type: "integer", Description: "Keys per page; 100 by default, at most 1000"},
+					{Name: "reveal", Type: "boolean", Description: "Include the values"},
+				},
+				Responses: []router.Response{{Status: http.StatusOK, Schema: KeyPage{}}},
+				Handler:   http.HandlerFunc(a.listKeys),
+			})
+		}
+	}
+	if a.hotKeys != nil {
+		routes = append(routes, router.Route{
+			Method:      http.MethodGet,
+			Path:        "/admin/hotkeys",
+			Summary:     "List the most read keys on this node",
+			Description: "Counts are approximate, and halve every minute so that they follow recent traffic.",
+			Tag:         "admin",
+			Admin:       true,
+			Params:      []router.Param{{Name: "n", Type: "integer", Description: "How many keys to list; 10 by default"}},
+			Responses:   []router.Response{{Status: http.StatusOK, Schema: []ports.KeyCount{}}},
+			Handler:     http.HandlerFunc(a.listHotKeys),
+		})
+	}
+	if a.namespaces != nil {
+		routes = append(routes, router.Route{
+			Method:  http.MethodGet,
+			Path:    "/stats/namespaces",
+			Summary: "Show each quota namespace's reads, usage and throttled writes across the cluster",
+			Description: "The node serving the request asks every member for its stats over gRPC. Reads and throttled writes are summed " +
+				"over the members, and count since each started; usage is the leader's. Members that do not answer are listed with the error.",
+			Tag:       "admin",
+			Admin:     true,
+			Responses: []router.Response{{Status: http.StatusOK, Schema: NamespacesReport{}}},
+			Handler:   http.HandlerFunc(a.namespaceStats),
+		})
+	}
+	if a.nodeStats != nil {
+		routes = append(routes, router.Route{
+			Method:  http.MethodGet,
+			Path:    "/cluster/stats",
+			Summary: "Show every member's reads, key count and replication lag",
+			Description: "The node serving the request asks every member for its stats over gRPC, so one request covers the cluster. " +
+				"Reads count since each member started. Members that do not answer are listed with the error.",
+			Tag:       "admin",
+			Admin:     true,
+			Responses: []router.Response{{Status: http.StatusOK, Schema: ClusterStats{}}},
+			Handler:   http.HandlerFunc(a.clusterStats),
+		})
+	}
+	if a.clusterVersion != nil {
+		clusterVersionResponses := []router.Response{{Status: http.StatusOK, Schema: map[string]uint32{}}}
+		routes = append(routes, router.Route{
+			Method:    http.MethodGet,
+			Path:      "/admin/cluster_version",
+			Summary:   "Show the cluster's command version",
+			Tag:       "admin",
+			Admin:     true,
+			Audited:   true,
+			Responses: clusterVersionResponses,
+			Handler:   http.HandlerFunc(a.showClusterVersion),
+		}, router.Route{
+			Method:    http.MethodPost,
+			Path:      "/admin/cluster_version",
+			Summary:   "Raise the cluster's command version",
+			Tag:       "admin",
+			Admin:     true,
+			Audited:   true,
+			Params:    []router.Param{{Name: "version", Type: "integer", Required: true}},
+			Responses: clusterVersionResponses,
+			Handler:   http.HandlerFunc(a.raiseClusterVersion),
+		})
+	}
+	return routes
+}
+
+func (a *Adapter) set(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	key := q.Get("key")
+	val := q.Get("value")
+
+	ctx, cancel, err := writeContext(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer cancel()
+	cond, err := writePrecondition(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	ttl, err := intParam(q, "ttl", 0)
+	if err != nil || ttl < 0 {
+		http.Error(w, "invalid ttl", http.StatusBadRequest)
+		return
+	}
+	version, err := a.service.SetIf(ctx, key, val, time.Duration(ttl)*time.Second, cond)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if version != 0 {
+		w.Header().Set("ETag", formatETag(version))
+	}
+
+	writeText(w, "ok")
+}
+
+func (a *Adapter) get(w http.ResponseWriter, r *http.Request) {
+	key := r.URL.Query().Get("key")
+	ctx, err := readContext(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	val, version, err := a.service.GetVersioned(ctx, key)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if version != 0 {
+		etag := formatETag(version)
+		w.Header().Set("ETag", etag)
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+	}
+	writeText(w, val)
+}
+
+func (a *Adapter) getSet(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel, err := writeContext(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer cancel()
+
+	old, found, err := a.service.GetSet(ctx, r.URL.Query().Get("key"), r.URL.Query().Get("value"), 0)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if !found {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	writeText(w, old)
+}
+
+func (a *Adapter) getOrSet(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel, err := writeContext(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer cancel()
+	ttl, err := intParam(r.URL.Query(), "ttl", 0)
+	if err != nil || ttl < 0 {
+		http.Error(w, "invalid ttl", http.StatusBadRequest)
+		return
+	}
+
+	val, loaded, err := a.service.GetOrSet(ctx, r.URL.Query().Get("key"), r.URL.Query().Get("value"), time.Duration(ttl)*time.Second)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if !loaded {
+		w.WriteHeader(http.StatusCreated)
+	}
+	writeText(w, val)
+}
+
+func (a *Adapter) getDel(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel, err := writeContext(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer cancel()
+
+	val, err := a.service.GetDel(ctx, r.URL.Query().Get("key"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeText(w, val)
+}
+
+func (a *Adapter) appendValue(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel, err := writeContext(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer cancel()
+
+	n, err := a.service.Append(ctx, r.URL.Query().Get("key"), r.URL.Query().Get("value"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeText(w, strconv.Itoa(n))
+}
+
+func (a *Adapter) strLen(w http.ResponseWriter, r *http.Request) {
+	ctx, err := readContext(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	n, err := a.service.StrLen(ctx, r.URL.Query().Get("key"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeText(w, strconv.Itoa(n))
+}
+
+// ttl writes the remaining lifetime in whole seconds (rounded up), or -1 if
+// the key does not expire.
+func (a *Adapter) ttl(w http.ResponseWriter, r *http.Request) {
+	ctx, err := readContext(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	ttl, err := a.service.TTL(ctx, r.URL.Query().Get("key"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	secs := int64(-1)
+	if ttl > 0 {
+		secs = int64(math.Ceil(ttl.Seconds()))
+	}
+	writeText(w, strconv.FormatInt(secs, 10))
+}
+
+func (a *Adapter) expire(w http.ResponseWriter, r *http.Request) {
+	ttl, err := intParam(r.URL.Query(), "ttl", 0)
+	if err != nil || ttl <= 0 {
+		http.Error(w, "ttl must be a positive number of seconds", http.StatusBadRequest)
+		return
+	}
+	ctx, cancel, err := writeContext(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer cancel()
+
+	if err := a.service.Expire(ctx, r.URL.Query().Get("key"), time.Duration(ttl)*time.Second); err != nil {
+		writeError(w, err)
+		return
+	}
+	writeText(w, "ok")
+}
+
+func (a *Adapter) persist(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel, err := writeContext(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer cancel()
+
+	if err := a.service.Persist(ctx, r.URL.Query().Get("key")); err != nil {
+		writeError(w, err)
+		return
+	}
+	writeText(w, "ok")
+}
+
+func (a *Adapter) zAdd(w http.ResponseWriter, r *http.Request) {
+	members, err := scoredMembers(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	ctx, cancel, err := writeContext(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer cancel()
+
+	n, err := a.service.ZAdd(ctx, r.URL.Query().Get("key"), members...)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeText(w, strconv.Itoa(n))
+}
+
+// zRange selects by score if min or max is given, and by rank otherwise.
+func (a *Adapter) zRange(w http.ResponseWriter, r *http.Request) {
+	ctx, err := readContext(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	p, err := parseZRange(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	key := r.URL.Query().Get("key")
+	var members []ports.ScoredMember
+	if p.byScore {
+		members, err = a.service.ZRangeByScore(ctx, key, p.min, p.max, p.limit)
+	} else {
+		members, err = a.service.ZRange(ctx, key, p.start, p.stop)
+	}
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if members == nil {
+		members = []ports.ScoredMember{}
+	}
+	writeJSON(w, members)
+}
+
+func (a *Adapter) zScore(w http.ResponseWriter, r *http.Request) {
+	ctx, err := readContext(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	score, found, err := a.service.ZScore(ctx, r.URL.Query().Get("key"), r.URL.Query().Get("member"))
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if !found {
+		http.Error(w, "member not found", http.StatusNotFound)
+		return
+	}
+	writeText(w, strconv.FormatFloat(score, 'g', -1, 64))
+}
+
+func (a *Adapter) zRemRangeByScore(w http.ResponseWriter, r *http.Request) {
+	min, max, err := scoreRange(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	ctx, cancel, err := writeContext(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer cancel()
+
+	n, err := a.service.ZRemRangeByScore(ctx, r.URL.Query().Get("key"), min, max)
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeText(w, strconv.Itoa(n))
+}
+
+// eval runs a Lua script atomically: the script is the request body, with
+// repeated key and arg parameters.
+func (a *Adapter) eval(w http.ResponseWriter, r *http.Request) {
+	src, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxScriptBytes))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+		return
+	}
+	ctx, cancel, err := writeContext(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer cancel()
+
+	query := r.URL.Query()
+	result, err := a.service.Eval(ctx, string(src), query["key"], query["arg"])
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, result)
+}
+
+func (a *Adapter) join(w http.ResponseWriter, r *http.Request) {
+	nodeID := r.URL.Query().Get("node_id")
+	remoteAddr := r.URL.Query().Get("addr")
+	grpcAddr := r.URL.Query().Get("grpc_addr")
+
+	if nodeID == "" || remoteAddr == "" {
+		http.Error(w, "missing node_id or addr", http.StatusBadRequest)
+		return
+	}
+	// Lets discovering nodes tell a node that has yet to join from a
+	// member that is not the leader.
+	if a.isMember != nil && !a.isMember() {
+		http.Error(w, "node is not a cluster member", http.StatusConflict)
+		return
+	}
+
+	if err := a.service.Join(r.Context(), nodeID, remoteAddr, grpcAddr); err != nil {
+		writeError(w, err)
+		return
+	}
+	writeText(w, "joined")
+}
+
+// snapshot forces a Raft snapshot (e.g. before an upgrade).
+func (a *Adapter) snapshot(w http.ResponseWriter, r *http.Request) {
+	info, err := a.cluster.Snapshot()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, info)
+}
+
+func (a *Adapter) listSnapshots(w http.ResponseWriter, r *http.Request) {
+	infos, err := a.cluster.ListSnapshots()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, infos)
+}
+
+func (a *Adapter) backup(w http.ResponseWriter, r *http.Request) {
+	dest := r.URL.Query().Get("dest")
+	if dest == "" {
+		dest = a.backupDest
+	}
+	loc, err := backup.ParseLocation(dest)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := backup.Backup(r.Context(), a.storage, loc); err != nil {
+		writeError(w, err)
+		return
+	}
+	log.Printf("Backup written to %s", loc)
+	writeJSON(w, map[string]string{"location": loc.String()})
+}
+
+// export streams the keyspace as Redis commands, for redis-cli --pipe or
+// cachectl import.
+func (a *Adapter) export(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", `attachment; filename="cache.resp"`)
+	n, err := migrate.Export(w, a.storage.Snapshot, a.decode)
+	if err != nil && n == 0 {
+		writeError(w, err)
+		return
+	}
+	if err != nil {
+		// The status line is gone; a truncated body is all the client sees.
+		log.Printf("Export failed after %d keys: %v", n, err)
+		return
+	}
+	log.Printf("Exported %d keys", n)
+}
+
+// ClusterStatus is the cluster as a node sees it.
+type ClusterStatus struct {
+	NodeID string `json:"node_id,omitempty"`
+	// State is the node's Raft role, e.g. Leader or Follower.
+	State string `json:"state"`
+	// Leader is the leader's Raft address, empty while there is none.
+	Leader  string         `json:"leader"`
+	Members []ports.Member `json:"members"`
+}
+
+func (a *Adapter) clusterStatus(w http.ResponseWriter, r *http.Request) {
+	members, err := a.cluster.Members()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	status := ClusterStatus{State: a.cluster.State(), Leader: a.cluster.Leader(), Members: members}
+	if a.self != nil {
+		status.NodeID = a.self.ID
+	}
+	writeJSON(w, status)
+}
+
+func (a *Adapter) deadMembers(w http.ResponseWriter, r *http.Request) {
+	dead, err := a.cluster.(ports.Reaper).DeadMembers()
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	if dead == nil {
+		dead = []ports.DeadMember{}
+	}
+	writeJSON(w, dead)
+}
+
+func (a *Adapter) reap(w http.ResponseWriter, r *http.Request) {
+	id := r.URL.Query().Get("id")
+	if id == "" {
+		http.Error(w, "id is required", http.StatusBadRequest)
+		return
+	}
+	if err := a.cluster.(ports.Reaper).Reap(id); err != nil {
+		writeError(w, err)
+		return
+	}
+	writeText(w, "removed")
+}
+
+// Stats are a node's key count and read counters.
+type Stats struct {
+	Keys   int    `json:"keys"`
+	Hits   uint64 `json:"hits"`
+	Misses uint64 `json:"misses"`
+}
+
+func (a *Adapter) stats(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, Stats{
+		Keys:   a.storage.Len(),
+		Hits:   uint64(observability.CounterValue(observability.CacheHitsTotal)),
+		Misses: uint64(observability.CounterValue(observability.CacheMissesTotal)),
+	})
+}
+
+// maxKeysPage bounds the keys listed per /admin/keys page.
+const maxKeysPage = 1000
+
+// KeyPage is a page of /admin/keys.
+type KeyPage struct {
+	Keys []KeyInfo `json:"keys"`
+	// Cursor gets the next page; it is empty on the last one.
+	Cursor string `json:"cursor,omitempty"`
+}
+
+// KeyInfo describes a key listed by /admin/keys.
+type KeyInfo struct {
+	Key string `json:"key"`
+	// Size is the bytes the value takes up in the store, after any
+	// compression or encryption.
+	Size int `json:"size"`
+	// TTL is the key's remaining lifetime in seconds, 0 if it does not expire.
+	TTL     int64  `json:"ttl"`
+	Version uint64 `json:"version"`
+	// Value is only set when reveal is true.
+	Value *string `json:"value,omitempty"`
+}
+
+func (a *Adapter) listKeys(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	limit, err := intParam(q, "limit", 100)
+	if err != nil || limit <= 0 || limit > maxKeysPage {
+		http.Error(w, fmt.Sprintf("limit must be between 1 and %d", maxKeysPage), http.StatusBadRequest)
+		return
+	}
+	var match func(string) bool
+	if expr := q.Get("match"); expr != "" {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			http.Error(w, "invalid match: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		match = re.MatchString
+	}
+	reveal := false
+	if s := q.Get("reveal"); s != "" {
+		if reveal, err = strconv.ParseBool(s); err != nil {
+			http.Error(w, "invalid reveal", http.StatusBadRequest)
+			return
+		}
+	}
+
+	entries := a.storage.(ports.ScanStorage).Scan(q.Get("prefix"), q.Get("cursor"), limit, match)
+	page := KeyPage{Keys: make([]KeyInfo, 0, len(entries))}
+	now := time.Now()
+	for _, e := range entries {
+		version, stored := service.DecodeVersion(e.Value)
+		info := KeyInfo{Key: e.Key, Size: len(stored), Version: version}
+		if !e.ExpiresAt.IsZero() {
+			info.TTL = int64(math.Ceil(e.ExpiresAt.Sub(now).Seconds()))
+		}
+		if reveal {
+			value, err := a.decode(e.Value)
+			if err != nil {
+				writeError(w, fmt.Errorf("decode %q: %w", e.Key, err))
+				return
+			}
+			info.Value = &value
+		}
+		page.Keys = append(page.Keys, info)
+	}
+	if len(entries) == limit {
+		page.Cursor = entries[len(entries)-1].Key
+	}
+	writeJSON(w, page)
+}
+
+func (a *Adapter) memoryStats(w http.ResponseWriter, r *http.Request) {
+	n, err := intParam(r.URL.Query(), "n", 10)
+	if err != nil || n < 0 || n > maxKeysPage {
+		http.Error(w, fmt.Sprintf("n must be between 0 and %d", maxKeysPage), http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, a.storage.(ports.MemoryStorage).MemoryStats(n))
+}
+
+func (a *Adapter) memoryUsage(w http.ResponseWriter, r *http.Request) {
+	key := r.URL.Query().Get("key")
+	bytes, ok := a.storage.(ports.MemoryStorage).MemoryUsage(key)
+	if !ok {
+		writeError(w, coreerrors.ErrNotFound)
+		return
+	}
+	writeJSON(w, ports.KeyMemory{Key: key, Bytes: bytes})
+}
+
+func (a *Adapter) listHotKeys(w http.ResponseWriter, r *http.Request) {
+	n, err := intParam(r.URL.Query(), "n", 10)
+	if err != nil || n < 0 {
+		http.Error(w, "invalid n", http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, a.hotKeys(n))
+}
+
+func (a *Adapter) showClusterVersion(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, map[string]uint32{"version": a.clusterVersion.ClusterVersion(), "max_version": service.MaxCommandVersion})
+}
+
+func (a *Adapter) raiseClusterVersion(w http.ResponseWriter, r *http.Request) {
+	version, err := strconv.ParseUint(r.URL.Query().Get("version"), 10, 32)
+	if err != nil {
+		http.Error(w, "version must be a non-negative integer", http.StatusBadRequest)
+		return
+	}
+	if err := a.clusterVersion.SetClusterVersion(r.Context(), uint32(version)); err != nil {
+		writeError(w, err)
+		return
+	}
+	log.Printf("Cluster version raised to %d", version)
+	a.showClusterVersion(w, r)
+}
@@ -0,0 +1,60 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"distributed-cache-service/internal/consensus"
+	"distributed-cache-service/internal/core/service"
+	"distributed-cache-service/internal/store"
+)
+
+// discardWriter is a ResponseWriter that keeps nothing, so that benchmarks
+// count the handler's allocations rather than a recorder's.
+type discardWriter struct{ header http.Header }
+
+func (w *discardWriter) Header() http.Header         { return w.header }
+func (w *discardWriter) Write(b []byte) (int, error) { return len(b), nil }
+func (w *discardWriter) WriteString(s string) (int, error) {
+	return len(s), nil
+}
+func (w *discardWriter) WriteHeader(int) {}
+
+// benchAdapter returns an adapter over a standalone node.
+func benchAdapter(b *testing.B) *Adapter {
+	b.Helper()
+	kv := store.New()
+	node := consensus.NewStandalone("node1", consensus.NewFSM(kv))
+	return New(service.New(kv, node, service.ConsistencyEventual))
+}
+
+// BenchmarkAdapter_Get serves /get for a key that exists, to track the
+// allocations of the read path from request to response.
+func BenchmarkAdapter_Get(b *testing.B) {
+	a := benchAdapter(b)
+	set := httptest.NewRequest(http.MethodPost, "/set?key=user:1&value=value", nil)
+	a.set(&discardWriter{header: http.Header{}}, set)
+	req := httptest.NewRequest(http.MethodGet, "/get?key=user:1", nil)
+	w := &discardWriter{header: http.Header{}}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		a.get(w, req)
+	}
+}
+
+// BenchmarkAdapter_Set serves /set, to track the allocations of the write
+// path from request to response.
+func BenchmarkAdapter_Set(b *testing.B) {
+	a := benchAdapter(b)
+	req := httptest.NewRequest(http.MethodPost, "/set?key=user:1&value=value&ttl=60", nil)
+	w := &discardWriter{header: http.Header{}}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		a.set(w, req)
+	}
+}
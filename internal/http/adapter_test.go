@@ -0,0 +1,424 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"distributed-cache-service/internal/consensus"
+	coreerrors "distributed-cache-service/internal/core/errors"
+	"distributed-cache-service/internal/core/ports"
+	"distributed-cache-service/internal/core/service"
+	"distributed-cache-service/internal/discovery"
+	"distributed-cache-service/internal/peers"
+	"distributed-cache-service/internal/router"
+	"distributed-cache-service/internal/store"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestAPI serves an adapter over a standalone node, with opts applied
+// after the admin options.
+func newTestAPI(t *testing.T, opts ...Option) (*router.Router, *service.ServiceImpl) {
+	t.Helper()
+	kv := store.New()
+	node := consensus.NewStandalone("node1", consensus.NewFSM(kv))
+	svc := service.New(kv, node, service.ConsistencyStrong)
+	rt := router.New("test", "1", router.WithMiddleware(Middleware()...))
+	New(svc, append([]Option{
+		WithAdmin(node, kv),
+		WithClusterVersion(svc),
+		WithNode(discovery.Member{ID: "node1", RaftAddr: "127.0.0.1:11000"}, nil),
+		WithDecoder(func(stored string) (string, error) {
+			_, value := service.DecodeVersion(stored)
+			return value, nil
+		}),
+	}, opts...)...).Register(rt)
+	rt.HandleDocs()
+	return rt, svc
+}
+
+func do(h http.Handler, method, target string, header ...string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, nil)
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestAdapter_Versions(t *testing.T) {
+	api, _ := newTestAPI(t)
+
+	rec := do(api, http.MethodPost, "/v1/set?key=k&value=v")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "ok", rec.Body.String())
+	// The unversioned alias serves the same data.
+	rec = do(api, http.MethodGet, "/get?key=k")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "v", rec.Body.String())
+
+	var doc struct {
+		Paths map[string]map[string]struct {
+			Deprecated bool
+		}
+	}
+	require.NoError(t, json.Unmarshal(do(api, http.MethodGet, "/openapi.json").Body.Bytes(), &doc))
+	assert.False(t, doc.Paths["/v1/get"]["get"].Deprecated)
+	assert.True(t, doc.Paths["/get"]["get"].Deprecated)
+	assert.Contains(t, doc.Paths, "/health")
+	assert.NotContains(t, doc.Paths, "/v1/health")
+}
+
+func TestAdapter_Keys(t *testing.T) {
+	api, _ := newTestAPI(t)
+
+	rec := do(api, http.MethodGet, "/v1/set?key=k&value=v")
+	require.Equal(t, http.StatusOK, rec.Code)
+	etag := rec.Header().Get("ETag")
+	require.NotEmpty(t, etag)
+
+	assert.Equal(t, http.StatusNotModified, do(api, http.MethodGet, "/v1/get?key=k", "If-None-Match", etag).Code)
+	assert.Equal(t, http.StatusPreconditionFailed, do(api, http.MethodGet, "/v1/set?key=k&value=w", "If-Match", `"1"`).Code)
+	assert.Equal(t, http.StatusPreconditionFailed, do(api, http.MethodGet, "/v1/set?key=k&value=w", "If-None-Match", "*").Code)
+	assert.Equal(t, http.StatusOK, do(api, http.MethodGet, "/v1/set?key=k&value=w", "If-Match", etag).Code)
+
+	rec = do(api, http.MethodGet, "/v1/getset?key=k&value=x")
+	assert.Equal(t, "w", rec.Body.String())
+	assert.Equal(t, http.StatusNoContent, do(api, http.MethodGet, "/v1/getset?key=new&value=x").Code)
+	assert.Equal(t, http.StatusCreated, do(api, http.MethodGet, "/v1/getorset?key=other&value=y").Code)
+	rec = do(api, http.MethodGet, "/v1/getorset?key=other&value=z")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "y", rec.Body.String())
+
+	assert.Equal(t, "2", do(api, http.MethodGet, "/v1/append?key=k&value=y").Body.String())
+	assert.Equal(t, "2", do(api, http.MethodGet, "/v1/strlen?key=k").Body.String())
+	assert.Equal(t, "-1", do(api, http.MethodGet, "/v1/ttl?key=k").Body.String())
+	assert.Equal(t, http.StatusOK, do(api, http.MethodGet, "/v1/expire?key=k&ttl=90").Code)
+	assert.Equal(t, "90", do(api, http.MethodGet, "/v1/ttl?key=k").Body.String())
+	assert.Equal(t, http.StatusOK, do(api, http.MethodGet, "/v1/persist?key=k").Code)
+	assert.Equal(t, "-1", do(api, http.MethodGet, "/v1/ttl?key=k").Body.String())
+
+	assert.Equal(t, "xy", do(api, http.MethodGet, "/v1/getdel?key=k").Body.String())
+	assert.Equal(t, http.StatusNotFound, do(api, http.MethodGet, "/v1/get?key=k").Code)
+
+	for _, target := range []string{
+		"/v1/set?key=k&value=v&ttl=-1",
+		"/v1/set?key=k&value=v&ack=all",
+		"/v1/set?key=k&value=v&timeout=soon",
+		"/v1/get?key=k&consistency=weak",
+		"/v1/expire?key=other&ttl=0",
+	} {
+		assert.Equal(t, http.StatusBadRequest, do(api, http.MethodGet, target).Code, target)
+	}
+}
+
+func TestAdapter_SortedSets(t *testing.T) {
+	api, _ := newTestAPI(t)
+
+	rec := do(api, http.MethodGet, "/v1/zadd?key=z&member=a&score=3&member=b&score=1&member=c&score=2")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, "3", rec.Body.String())
+
+	var members []ports.ScoredMember
+	require.NoError(t, json.Unmarshal(do(api, http.MethodGet, "/v1/zrange?key=z").Body.Bytes(), &members))
+	assert.Equal(t, []ports.ScoredMember{{Member: "b", Score: 1}, {Member: "c", Score: 2}, {Member: "a", Score: 3}}, members)
+	require.NoError(t, json.Unmarshal(do(api, http.MethodGet, "/v1/zrange?key=z&min=2&limit=1").Body.Bytes(), &members))
+	assert.Equal(t, []ports.ScoredMember{{Member: "c", Score: 2}}, members)
+	assert.Equal(t, "[]\n", do(api, http.MethodGet, "/v1/zrange?key=missing").Body.String())
+
+	assert.Equal(t, "3", do(api, http.MethodGet, "/v1/zscore?key=z&member=a").Body.String())
+	assert.Equal(t, http.StatusNotFound, do(api, http.MethodGet, "/v1/zscore?key=z&member=x").Code)
+	assert.Equal(t, "2", do(api, http.MethodGet, "/v1/zremrangebyscore?key=z&max=2").Body.String())
+	assert.Equal(t, http.StatusBadRequest, do(api, http.MethodGet, "/v1/zadd?key=z&member=a").Code)
+}
+
+func TestAdapter_Eval(t *testing.T) {
+	api, _ := newTestAPI(t)
+
+	rec := do(api, http.MethodGet, "/v1/eval?key=k")
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+	assert.Equal(t, "POST", rec.Header().Get("Allow"))
+
+	req := httptest.NewRequest(http.MethodPost, "/v1/eval?key=k&arg=v", strings.NewReader(`cache.set(KEYS[1], ARGV[1]); return cache.get(KEYS[1])`))
+	rec = httptest.NewRecorder()
+	api.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	assert.JSONEq(t, `"v"`, rec.Body.String())
+}
+
+func TestAdapter_Cluster(t *testing.T) {
+	api, _ := newTestAPI(t)
+
+	var self discovery.Member
+	require.NoError(t, json.Unmarshal(do(api, http.MethodGet, "/v1/node").Body.Bytes(), &self))
+	assert.Equal(t, discovery.Member{ID: "node1", RaftAddr: "127.0.0.1:11000"}, self)
+	assert.Equal(t, http.StatusBadRequest, do(api, http.MethodGet, "/v1/join?node_id=node2").Code)
+	// A standalone node has no cluster to join.
+	assert.Equal(t, http.StatusNotImplemented, do(api, http.MethodGet, "/v1/join?node_id=node2&addr=127.0.0.1:11001").Code)
+
+	api, _ = newTestAPI(t, WithNode(discovery.Member{ID: "node1"}, func() bool { return false }))
+	assert.Equal(t, http.StatusConflict, do(api, http.MethodGet, "/v1/join?node_id=node2&addr=127.0.0.1:11001").Code)
+}
+
+func TestAdapter_Admin(t *testing.T) {
+	api, svc := newTestAPI(t)
+
+	var versions map[string]uint32
+	require.NoError(t, json.Unmarshal(do(api, http.MethodGet, "/v1/admin/cluster_version").Body.Bytes(), &versions))
+	assert.Equal(t, map[string]uint32{"version": svc.ClusterVersion(), "max_version": service.MaxCommandVersion}, versions)
+	assert.Equal(t, http.StatusBadRequest, do(api, http.MethodPost, "/v1/admin/cluster_version?version=x").Code)
+
+	assert.Equal(t, http.StatusMethodNotAllowed, do(api, http.MethodGet, "/v1/admin/snapshot").Code)
+	assert.Equal(t, http.StatusNotImplemented, do(api, http.MethodPost, "/v1/admin/snapshot").Code)
+	assert.Equal(t, "[]\n", do(api, http.MethodGet, "/v1/admin/snapshots").Body.String())
+	assert.Equal(t, http.StatusBadRequest, do(api, http.MethodPost, "/v1/admin/backup").Code)
+
+	do(api, http.MethodGet, "/v1/set?key=k&value=v")
+	rec := do(api, http.MethodGet, "/v1/admin/export")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "$1\r\nk\r\n$1\r\nv\r\n")
+
+	// Routes whose options were not given are not served.
+	assert.Equal(t, http.StatusNotFound, do(api, http.MethodGet, "/v1/admin/config").Code)
+}
+
+func TestAdapter_AdminUI(t *testing.T) {
+	api, _ := newTestAPI(t, WithHotKeys(func(n int) []ports.KeyCount {
+		return []ports.KeyCount{{Key: "k", Count: 3}, {Key: "j", Count: 1}}[:n]
+	}))
+
+	assert.Equal(t, "/ui/", do(api, http.MethodGet, "/ui").Header().Get("Location"))
+	rec := do(api, http.MethodGet, "/ui/")
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "<title>Cache admin</title>")
+	assert.Contains(t, rec.Header().Get("Content-Security-Policy"), "default-src 'self'")
+	assert.Equal(t, http.StatusOK, do(api, http.MethodGet, "/ui/app.js").Code)
+
+	var status ClusterStatus
+	require.NoError(t, json.Unmarshal(do(api, http.MethodGet, "/v1/admin/cluster").Body.Bytes(), &status))
+	assert.Equal(t, "node1", status.NodeID)
+	assert.Equal(t, "Standalone", status.State)
+	require.Len(t, status.Members, 1)
+	assert.True(t, status.Members[0].Leader)
+
+	do(api, http.MethodGet, "/v1/set?key=k&value=v")
+	var stats Stats
+	require.NoError(t, json.Unmarshal(do(api, http.MethodGet, "/v1/admin/stats").Body.Bytes(), &stats))
+	assert.Equal(t, 1, stats.Keys)
+
+	var hot []ports.KeyCount
+	require.NoError(t, json.Unmarshal(do(api, http.MethodGet, "/v1/admin/hotkeys?n=1").Body.Bytes(), &hot))
+	assert.Equal(t, []ports.KeyCount{{Key: "k", Count: 3}}, hot)
+	assert.Equal(t, http.StatusBadRequest, do(api, http.MethodGet, "/v1/admin/hotkeys?n=-1").Code)
+
+	// Without WithHotKeys the route is not served, and the UI says so.
+	api, _ = newTestAPI(t)
+	assert.Equal(t, http.StatusNotFound, do(api, http.MethodGet, "/v1/admin/hotkeys").Code)
+}
+
+func TestAdapter_AdminKeys(t *testing.T) {
+	api, _ := newTestAPI(t)
+	for _, k := range []string{"user:2", "user:1", "user:10", "order:1"} {
+		do(api, http.MethodPost, "/v1/set?key="+k+"&value=secret")
+	}
+	do(api, http.MethodPost, "/v1/set?key=user:3&value=x&ttl=60")
+
+	list := func(query string) KeyPage {
+		t.Helper()
+		rec := do(api, http.MethodGet, "/v1/admin/keys?"+query)
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		var page KeyPage
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &page))
+		return page
+	}
+
+	page := list("prefix=user:&limit=2")
+	require.Len(t, page.Keys, 2)
+	assert.Equal(t, "user:1", page.Keys[0].Key)
+	assert.Equal(t, len("secret"), page.Keys[0].Size)
+	assert.NotZero(t, page.Keys[0].Version)
+	assert.Nil(t, page.Keys[0].Value, "values are hidden by default")
+	assert.Equal(t, "user:10", page.Cursor)
+
+	page = list("prefix=user:&limit=2&cursor=" + page.Cursor)
+	require.Len(t, page.Keys, 2)
+	assert.Equal(t, "user:3", page.Keys[1].Key)
+	assert.InDelta(t, 60, page.Keys[1].TTL, 1)
+	page = list("prefix=user:&limit=2&cursor=" + page.Cursor)
+	assert.Empty(t, page.Keys)
+	assert.Empty(t, page.Cursor)
+
+	page = list(`match=:1$&reveal=true`)
+	require.Len(t, page.Keys, 2)
+	assert.Equal(t, "order:1", page.Keys[0].Key)
+	require.NotNil(t, page.Keys[0].Value)
+	assert.Equal(t, "secret", *page.Keys[0].Value)
+
+	assert.Equal(t, http.StatusBadRequest, do(api, http.MethodGet, "/v1/admin/keys?match=(").Code)
+	assert.Equal(t, http.StatusBadRequest, do(api, http.MethodGet, "/v1/admin/keys?limit=0").Code)
+	assert.Equal(t, http.StatusBadRequest, do(api, http.MethodGet, "/v1/admin/keys?reveal=maybe").Code)
+}
+
+func TestAdapter_Memory(t *testing.T) {
+	api, _ := newTestAPI(t)
+	do(api, http.MethodPost, "/v1/set?key=small&value=x")
+	do(api, http.MethodPost, "/v1/set?key=big&value="+strings.Repeat("v", 1000))
+
+	rec := do(api, http.MethodGet, "/v1/stats/memory?n=1")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var stats ports.MemoryStats
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &stats))
+	assert.EqualValues(t, 2, stats.Keys)
+	require.Len(t, stats.Largest, 1)
+	assert.Equal(t, "big", stats.Largest[0].Key)
+	assert.Greater(t, stats.Largest[0].Bytes, int64(1000))
+	assert.Equal(t, http.StatusBadRequest, do(api, http.MethodGet, "/v1/stats/memory?n=-1").Code)
+
+	rec = do(api, http.MethodGet, "/v1/memory/usage?key=big")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var usage ports.KeyMemory
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &usage))
+	assert.Equal(t, stats.Largest[0], usage)
+	assert.Equal(t, http.StatusNotFound, do(api, http.MethodGet, "/v1/memory/usage?key=missing").Code)
+}
+
+func TestAdapter_Readyz(t *testing.T) {
+	api, _ := newTestAPI(t)
+	assert.Equal(t, "ok", do(api, http.MethodGet, "/readyz").Body.String())
+
+	api, _ = newTestAPI(t, WithReadiness(func() error {
+		return fmt.Errorf("%w: no leader contact for 5s", coreerrors.ErrNoQuorum)
+	}))
+	rec := do(api, http.MethodGet, "/readyz")
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Contains(t, rec.Body.String(), "no quorum")
+}
+
+// reapingCluster is a cluster admin that reports dead as its dead members.
+type reapingCluster struct {
+	ports.ClusterAdmin
+	dead   []ports.DeadMember
+	reaped []string
+}
+
+func (c *reapingCluster) DeadMembers() ([]ports.DeadMember, error) { return c.dead, nil }
+
+func (c *reapingCluster) Reap(id string) error {
+	if len(c.dead) == 0 || c.dead[0].ID != id {
+		return fmt.Errorf("%w: %q is not a dead member", coreerrors.ErrInvalidArgument, id)
+	}
+	c.reaped = append(c.reaped, id)
+	return nil
+}
+
+func TestAdapter_Reap(t *testing.T) {
+	// A standalone node has no members to reap.
+	api, _ := newTestAPI(t)
+	assert.Equal(t, http.StatusNotFound, do(api, http.MethodGet, "/v1/admin/cluster/dead").Code)
+
+	kv := store.New()
+	cluster := &reapingCluster{
+		ClusterAdmin: consensus.NewStandalone("node1", consensus.NewFSM(kv)),
+		dead:         []ports.DeadMember{{ID: "node3", Address: "node3:11000"}},
+	}
+	api, _ = newTestAPI(t, WithAdmin(cluster, kv))
+	var dead []ports.DeadMember
+	require.NoError(t, json.Unmarshal(do(api, http.MethodGet, "/v1/admin/cluster/dead").Body.Bytes(), &dead))
+	assert.Equal(t, "node3", dead[0].ID)
+
+	assert.Equal(t, http.StatusBadRequest, do(api, http.MethodPost, "/v1/admin/cluster/reap").Code)
+	assert.Equal(t, http.StatusBadRequest, do(api, http.MethodPost, "/v1/admin/cluster/reap?id=node2").Code)
+	assert.Equal(t, http.StatusOK, do(api, http.MethodPost, "/v1/admin/cluster/reap?id=node3").Code)
+	assert.Equal(t, []string{"node3"}, cluster.reaped)
+}
+
+func TestAdapter_NamespaceStats(t *testing.T) {
+	usage := func(keys, bytes int64) *ports.Usage { return &ports.Usage{Keys: keys, Bytes: bytes} }
+	replies := []peers.Reply[[]ports.NamespaceStats]{
+		{Member: peers.Member{ID: "node1", Self: true}, Value: []ports.NamespaceStats{
+			{Namespace: "user:", Hits: 3, Misses: 1, Usage: usage(4, 400), MaxKeys: 10, MaxBytes: 1000},
+			{Namespace: "session:", Hits: 1, MaxWriteRate: 5},
+		}},
+		{Member: peers.Member{ID: "node2", Addr: "10.0.0.2:9090", Leader: true}, Value: []ports.NamespaceStats{
+			{Namespace: "user:", Hits: 5, Misses: 3, Usage: usage(5, 500), MaxKeys: 10, MaxBytes: 1000, Throttled: map[string]uint64{"keys": 2}},
+		}},
+		{Member: peers.Member{ID: "node3", Addr: "10.0.0.3:9090"}, Err: errors.New("unavailable")},
+	}
+	api, _ := newTestAPI(t, WithNamespaceStats(func(ctx context.Context) ([]peers.Reply[[]ports.NamespaceStats], error) {
+		return replies, nil
+	}))
+
+	rec := do(api, http.MethodGet, "/v1/stats/namespaces")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var report NamespacesReport
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &report))
+	require.Len(t, report.Namespaces, 2)
+
+	session, user := report.Namespaces[0], report.Namespaces[1]
+	assert.Equal(t, "session:", session.Namespace)
+	assert.Equal(t, 1.0, session.HitRatio)
+	assert.Nil(t, session.Usage)
+	assert.Equal(t, 5.0, session.MaxWriteRate)
+
+	assert.Equal(t, "user:", user.Namespace)
+	assert.EqualValues(t, 8, user.Hits, "reads are summed")
+	assert.EqualValues(t, 4, user.Misses)
+	assert.Equal(t, 2.0/3, user.HitRatio)
+	assert.Equal(t, usage(5, 500), user.Usage, "usage is the leader's")
+	assert.Equal(t, 0.5, user.KeyQuotaUsed)
+	assert.Equal(t, 0.5, user.ByteQuotaUsed)
+	assert.Equal(t, map[string]uint64{"keys": 2}, user.Throttled)
+
+	require.Len(t, report.Nodes, 3)
+	assert.Empty(t, report.Nodes[0].Error)
+	assert.Equal(t, "unavailable", report.Nodes[2].Error)
+
+	// Without WithNamespaceStats the route is not served.
+	api, _ = newTestAPI(t)
+	assert.Equal(t, http.StatusNotFound, do(api, http.MethodGet, "/v1/stats/namespaces").Code)
+}
+
+func TestAdapter_ClusterStats(t *testing.T) {
+	replies := []peers.Reply[ports.NodeStats]{
+		{Member: peers.Member{ID: "node1", Self: true}, Value: ports.NodeStats{State: "Follower", Keys: 5, Capacity: 10, Hits: 3, Misses: 1, AppliedIndex: 95}},
+		{Member: peers.Member{ID: "node2", Addr: "10.0.0.2:9090", Leader: true}, Value: ports.NodeStats{State: "Leader", Keys: 5, Hits: 1, Misses: 3, AppliedIndex: 100}},
+		{Member: peers.Member{ID: "node3", Addr: "10.0.0.3:9090"}, Err: errors.New("unavailable")},
+	}
+	api, _ := newTestAPI(t, WithClusterStats(func(ctx context.Context) ([]peers.Reply[ports.NodeStats], error) {
+		return replies, nil
+	}))
+
+	rec := do(api, http.MethodGet, "/v1/cluster/stats")
+	require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+	var stats ClusterStats
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &stats))
+	assert.EqualValues(t, 4, stats.Hits)
+	assert.EqualValues(t, 4, stats.Misses)
+	assert.Equal(t, 0.5, stats.HitRatio)
+
+	require.Len(t, stats.Nodes, 3)
+	self, leader, down := stats.Nodes[0], stats.Nodes[1], stats.Nodes[2]
+	require.NotNil(t, self.NodeStats)
+	assert.Equal(t, 0.75, self.HitRatio)
+	assert.Equal(t, 0.5, self.Occupancy)
+	require.NotNil(t, self.Lag)
+	assert.EqualValues(t, 5, *self.Lag, "lag is behind the leader's applied index")
+	require.NotNil(t, leader.Lag)
+	assert.Zero(t, *leader.Lag)
+	assert.Zero(t, leader.Occupancy, "no capacity, no occupancy")
+	assert.Nil(t, down.NodeStats)
+	assert.Equal(t, "unavailable", down.Error)
+	assert.NotContains(t, rec.Body.String(), `"state":""`, "members that did not answer have no stats")
+
+	// Without WithClusterStats the route is not served.
+	api, _ = newTestAPI(t)
+	assert.Equal(t, http.StatusNotFound, do(api, http.MethodGet, "/v1/cluster/stats").Code)
+}
@@ -0,0 +1,87 @@
+package http
+
+import (
+	"net/http"
+
+	"distributed-cache-service/internal/core/ports"
+	"distributed-cache-service/internal/peers"
+)
+
+// ClusterStats is what /cluster/stats shows.
+type ClusterStats struct {
+	Nodes []MemberStats `json:"nodes"`
+	// Hits and Misses are summed over the members that answered.
+	Hits     uint64  `json:"hits"`
+	Misses   uint64  `json:"misses"`
+	HitRatio float64 `json:"hit_ratio"`
+}
+
+// MemberStats is a member's stats, or the error of a member that did not
+// answer.
+type MemberStats struct {
+	peers.Member
+	*ports.NodeStats
+	// HitRatio is the member's hits over all its reads, 0 before any.
+	HitRatio float64 `json:"hit_ratio"`
+	// Occupancy is the share of the store's capacity in use, from 0 to 1, if
+	// it has one.
+	Occupancy float64 `json:"occupancy,omitempty"`
+	// Lag is how many entries the member has applied fewer than the leader,
+	// if the leader answered. Members are asked at once, so it is approximate.
+	Lag   *uint64 `json:"lag,omitempty"`
+	Error string  `json:"error,omitempty"`
+}
+
+func (a *Adapter) clusterStats(w http.ResponseWriter, r *http.Request) {
+	replies, err := a.nodeStats(r.Context())
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, mergeNodeStats(replies))
+}
+
+// mergeNodeStats reports every member's stats, with their lag behind the
+// leader, and the cluster's read totals.
+func mergeNodeStats(replies []peers.Reply[ports.NodeStats]) ClusterStats {
+	var leader *ports.NodeStats
+	for i := range replies {
+		if replies[i].Member.Leader && replies[i].Err == nil {
+			leader = &replies[i].Value
+		}
+	}
+
+	stats := ClusterStats{Nodes: make([]MemberStats, 0, len(replies))}
+	for i := range replies {
+		reply := &replies[i]
+		node := MemberStats{Member: reply.Member}
+		if reply.Err != nil {
+			node.Error = reply.Err.Error()
+			stats.Nodes = append(stats.Nodes, node)
+			continue
+		}
+		st := &reply.Value
+		node.NodeStats = st
+		node.HitRatio = hitRatio(st.Hits, st.Misses)
+		if st.Capacity > 0 {
+			node.Occupancy = float64(st.Keys) / float64(st.Capacity)
+		}
+		if leader != nil {
+			lag := leader.AppliedIndex - min(st.AppliedIndex, leader.AppliedIndex)
+			node.Lag = &lag
+		}
+		stats.Hits += st.Hits
+		stats.Misses += st.Misses
+		stats.Nodes = append(stats.Nodes, node)
+	}
+	stats.HitRatio = hitRatio(stats.Hits, stats.Misses)
+	return stats
+}
+
+// hitRatio returns hits over all reads, or 0 before any.
+func hitRatio(hits, misses uint64) float64 {
+	if hits+misses == 0 {
+		return 0
+	}
+	return float64(hits) / float64(hits+misses)
+}
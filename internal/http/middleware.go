@@ -0,0 +1,114 @@
+package http
+
+import (
+	"errors"
+	"log"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+	"strconv"
+	"time"
+
+	"distributed-cache-service/internal/observability"
+	"distributed-cache-service/internal/router"
+)
+
+// Middleware returns the chain every route runs through, outermost first:
+// Metrics, then Log, then Recover. Pass it to router.WithMiddleware, which
+// adds each route's audit, authentication and rate limiting inside it.
+func Middleware() []router.Middleware {
+	return []router.Middleware{Metrics, Log, Recover}
+}
+
+// Metrics counts requests and measures their latency by the route that
+// matched, so that its labels stay bounded whatever paths clients ask for.
+func Metrics(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(sw, r)
+		route := r.Pattern
+		observability.HTTPRequestsTotal.WithLabelValues(route, methodLabel(r.Method), strconv.Itoa(sw.status)).Inc()
+		observability.HTTPRequestDurationSeconds.WithLabelValues(route).Observe(time.Since(start).Seconds())
+	})
+}
+
+// methodLabel folds methods outside the standard ones into "other", as routes
+// without a declared method answer whatever a client sends.
+func methodLabel(method string) string {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut,
+		http.MethodPatch, http.MethodDelete, http.MethodOptions:
+		return method
+	}
+	return "other"
+}
+
+// Log logs each request at debug level, and requests that fail with a server
+// error at warn level.
+func Log(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		sw := &statusWriter{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(sw, r)
+		level := slog.LevelDebug
+		if sw.status >= http.StatusInternalServerError {
+			level = slog.LevelWarn
+		}
+		slog.Log(r.Context(), level, "HTTP request",
+			"method", r.Method,
+			"path", r.URL.Path,
+			"status", sw.status,
+			"duration", time.Since(start),
+			"remote", r.RemoteAddr,
+		)
+	})
+}
+
+// Recover turns a panicking handler into a 500 response, logging the panic
+// and its stack, so one bad request cannot take the node down.
+// http.ErrAbortHandler is passed on: it is how handlers abort a response on
+// purpose.
+func Recover(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			p := recover()
+			if p == nil {
+				return
+			}
+			if err, ok := p.(error); ok && errors.Is(err, http.ErrAbortHandler) {
+				panic(p)
+			}
+			observability.HTTPPanicsTotal.Inc()
+			log.Printf("Panic serving %s %s: %v\n%s", r.Method, r.URL.Path, p, debug.Stack())
+			// If the handler wrote a response already this only adds to it;
+			// the client sees a truncated body.
+			http.Error(w, "internal error", http.StatusInternalServerError)
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// statusWriter remembers the status code of a response.
+type statusWriter struct {
+	http.ResponseWriter
+	status int
+	wrote  bool
+}
+
+func (w *statusWriter) WriteHeader(code int) {
+	if !w.wrote {
+		w.status, w.wrote = code, true
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *statusWriter) Write(p []byte) (int, error) {
+	w.wrote = true
+	return w.ResponseWriter.Write(p)
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,64 @@
+package http
+
+import (
+	"bytes"
+	"log/slog"
+	"net/http"
+	"testing"
+
+	"distributed-cache-service/internal/observability"
+	"distributed-cache-service/internal/router"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMiddleware_Recover(t *testing.T) {
+	rt := router.New("test", "1", router.WithMiddleware(Middleware()...))
+	rt.Handle(router.Route{Path: "/panic", Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	})})
+	rt.Handle(router.Route{Path: "/abort", Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic(http.ErrAbortHandler)
+	})})
+
+	panics := testutil.ToFloat64(observability.HTTPPanicsTotal)
+	errors := testutil.ToFloat64(observability.HTTPRequestsTotal.WithLabelValues("/panic", "GET", "500"))
+	rec := do(rt, http.MethodGet, "/panic")
+	assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	assert.Equal(t, "internal error\n", rec.Body.String())
+	assert.Equal(t, panics+1, testutil.ToFloat64(observability.HTTPPanicsTotal))
+	assert.Equal(t, errors+1, testutil.ToFloat64(observability.HTTPRequestsTotal.WithLabelValues("/panic", "GET", "500")))
+
+	assert.PanicsWithValue(t, http.ErrAbortHandler, func() { do(rt, http.MethodGet, "/abort") })
+}
+
+func TestMiddleware_Metrics(t *testing.T) {
+	api, _ := newTestAPI(t)
+
+	found := observability.HTTPRequestsTotal.WithLabelValues("/v1/get", "GET", "404")
+	odd := observability.HTTPRequestsTotal.WithLabelValues("/v1/get", "other", "404")
+	before, oddBefore := testutil.ToFloat64(found), testutil.ToFloat64(odd)
+	do(api, http.MethodGet, "/v1/get?key=missing")
+	do(api, "PURGE", "/v1/get?key=missing")
+	assert.Equal(t, before+1, testutil.ToFloat64(found))
+	assert.Equal(t, oddBefore+1, testutil.ToFloat64(odd))
+}
+
+func TestMiddleware_Log(t *testing.T) {
+	var buf bytes.Buffer
+	prev := slog.Default()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})))
+	defer slog.SetDefault(prev)
+
+	api, _ := newTestAPI(t)
+	do(api, http.MethodGet, "/v1/get?key=missing")
+	assert.Contains(t, buf.String(), "level=DEBUG")
+	assert.Contains(t, buf.String(), "path=/v1/get")
+	assert.Contains(t, buf.String(), "status=404")
+
+	buf.Reset()
+	slog.SetDefault(slog.New(slog.NewTextHandler(&buf, nil)))
+	do(api, http.MethodGet, "/v1/get?key=missing")
+	assert.Empty(t, buf.String())
+}
@@ -0,0 +1,116 @@
+package http
+
+import (
+	"maps"
+	"net/http"
+	"slices"
+
+	"distributed-cache-service/internal/core/ports"
+	"distributed-cache-service/internal/peers"
+)
+
+// NamespacesReport is what /stats/namespaces shows.
+type NamespacesReport struct {
+	Namespaces []NamespaceReport `json:"namespaces"`
+	// Nodes are the members asked, with the error of those that did not
+	// answer. Their reads and throttled writes are missing from the totals.
+	Nodes []NodeStatus `json:"nodes"`
+}
+
+// NodeStatus is a member asked for its stats.
+type NodeStatus struct {
+	peers.Member
+	Error string `json:"error,omitempty"`
+}
+
+// NamespaceReport is a quota namespace's stats across the cluster.
+type NamespaceReport struct {
+	Namespace string `json:"namespace"`
+	// Hits and Misses are summed over the members.
+	Hits   uint64 `json:"hits"`
+	Misses uint64 `json:"misses"`
+	// HitRatio is Hits over all reads, 0 before any.
+	HitRatio float64 `json:"hit_ratio"`
+	// Usage is what the namespace takes up in the leader's store, or, if the
+	// leader did not answer, the largest a member reported. Every member
+	// stores every key, so it is not summed.
+	Usage *ports.Usage `json:"usage,omitempty"`
+	// The namespace's quotas, 0 if unlimited.
+	MaxKeys      int64   `json:"max_keys"`
+	MaxBytes     int64   `json:"max_bytes"`
+	MaxWriteRate float64 `json:"max_write_rate"`
+	// KeyQuotaUsed and ByteQuotaUsed are the shares of the key and byte
+	// quotas in use, from 0 to 1, if the namespace has them.
+	KeyQuotaUsed  float64 `json:"key_quota_used,omitempty"`
+	ByteQuotaUsed float64 `json:"byte_quota_used,omitempty"`
+	// Throttled counts the writes refused, by the quota they would have
+	// exceeded, summed over the members.
+	Throttled map[string]uint64 `json:"throttled,omitempty"`
+}
+
+func (a *Adapter) namespaceStats(w http.ResponseWriter, r *http.Request) {
+	replies, err := a.namespaces(r.Context())
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	writeJSON(w, mergeNamespaceStats(replies))
+}
+
+// mergeNamespaceStats merges the namespace stats of every member that
+// answered into one report.
+func mergeNamespaceStats(replies []peers.Reply[[]ports.NamespaceStats]) NamespacesReport {
+	report := NamespacesReport{Namespaces: []NamespaceReport{}, Nodes: make([]NodeStatus, 0, len(replies))}
+	merged := make(map[string]*NamespaceReport)
+	// The leader's usage and quotas take precedence, as it enforces them.
+	fromLeader := make(map[string]bool)
+	for _, reply := range replies {
+		node := NodeStatus{Member: reply.Member}
+		if reply.Err != nil {
+			node.Error = reply.Err.Error()
+		}
+		report.Nodes = append(report.Nodes, node)
+		if reply.Err != nil {
+			continue
+		}
+		for _, st := range reply.Value {
+			ns, ok := merged[st.Namespace]
+			if !ok {
+				ns = &NamespaceReport{Namespace: st.Namespace}
+				merged[st.Namespace] = ns
+			}
+			ns.Hits += st.Hits
+			ns.Misses += st.Misses
+			for resource, n := range st.Throttled {
+				if ns.Throttled == nil {
+					ns.Throttled = make(map[string]uint64)
+				}
+				ns.Throttled[resource] += n
+			}
+			if fromLeader[st.Namespace] {
+				continue
+			}
+			if !ok || reply.Member.Leader {
+				ns.MaxKeys, ns.MaxBytes, ns.MaxWriteRate = st.MaxKeys, st.MaxBytes, st.MaxWriteRate
+			}
+			if st.Usage != nil && (reply.Member.Leader || ns.Usage == nil || st.Usage.Keys > ns.Usage.Keys) {
+				usage := *st.Usage
+				ns.Usage = &usage
+			}
+			fromLeader[st.Namespace] = reply.Member.Leader
+		}
+	}
+
+	for _, name := range slices.Sorted(maps.Keys(merged)) {
+		ns := merged[name]
+		ns.HitRatio = hitRatio(ns.Hits, ns.Misses)
+		if ns.Usage != nil && ns.MaxKeys > 0 {
+			ns.KeyQuotaUsed = float64(ns.Usage.Keys) / float64(ns.MaxKeys)
+		}
+		if ns.Usage != nil && ns.MaxBytes > 0 {
+			ns.ByteQuotaUsed = float64(ns.Usage.Bytes) / float64(ns.MaxBytes)
+		}
+		report.Namespaces = append(report.Namespaces, *ns)
+	}
+	return report
+}
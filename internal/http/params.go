@@ -0,0 +1,198 @@
+package http
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	coreerrors "distributed-cache-service/internal/core/errors"
+	"distributed-cache-service/internal/core/ports"
+	"distributed-cache-service/internal/core/service"
+)
+
+// maxScriptBytes bounds the body of an /eval request.
+const maxScriptBytes = 1 << 20
+
+// writeContext derives the context for a write from r: the X-Request-ID header
+// makes retries idempotent, the X-Origin-Time header (RFC 3339) dates a write
+// copied from elsewhere (see service.ContextWithOriginTime), the timeout query
+// parameter bounds the wait and the ack query parameter sets how far the write
+// must get (see service.AckLevel).
+func writeContext(r *http.Request) (context.Context, context.CancelFunc, error) {
+	ctx := r.Context()
+	if id := r.Header.Get("X-Request-ID"); id != "" {
+		ctx = service.ContextWithRequestID(ctx, id)
+	}
+	if v := r.Header.Get("X-Origin-Time"); v != "" {
+		at, err := time.Parse(time.RFC3339Nano, v)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid X-Origin-Time %q", v)
+		}
+		ctx = service.ContextWithOriginTime(ctx, at)
+	}
+	q := r.URL.Query()
+	if name := q.Get("ack"); name != "" {
+		ack, err := service.ParseAckLevel(name)
+		if err != nil {
+			return nil, nil, fmt.Errorf("invalid ack %q", name)
+		}
+		ctx = service.ContextWithAck(ctx, ack)
+	}
+	if t := q.Get("timeout"); t != "" {
+		d, err := time.ParseDuration(t)
+		if err != nil || d <= 0 {
+			return nil, nil, fmt.Errorf("invalid timeout %q", t)
+		}
+		ctx, cancel := context.WithTimeout(ctx, d)
+		return ctx, cancel, nil
+	}
+	return ctx, func() {}, nil
+}
+
+// readContext derives the context for a read from r: the consistency query
+// parameter overrides -consistency for this request.
+func readContext(r *http.Request) (context.Context, error) {
+	name := r.URL.Query().Get("consistency")
+	if name == "" {
+		return r.Context(), nil
+	}
+	mode, err := service.ParseConsistencyMode(name)
+	if err != nil {
+		return nil, fmt.Errorf("invalid consistency %q", name)
+	}
+	return service.ContextWithConsistency(r.Context(), mode), nil
+}
+
+// formatETag renders a key version as an HTTP entity tag.
+func formatETag(version uint64) string {
+	var b [22]byte
+	tag := append(b[:0], '"')
+	tag = strconv.AppendUint(tag, version, 10)
+	return string(append(tag, '"'))
+}
+
+// writePrecondition reads a conditional write from the request: If-Match with
+// an ETag from /get (or the version query parameter) requires the key to be at
+// that version, and If-None-Match: * requires the key to not exist.
+func writePrecondition(r *http.Request) (ports.Precondition, error) {
+	var cond ports.Precondition
+	tag := r.Header.Get("If-Match")
+	if tag == "" {
+		tag = r.URL.Query().Get("version")
+	}
+	if tag != "" {
+		v, err := strconv.ParseUint(strings.Trim(strings.TrimPrefix(tag, "W/"), `"`), 10, 64)
+		if err != nil || v == 0 {
+			return cond, fmt.Errorf("invalid version %q", tag)
+		}
+		cond.IfVersion = v
+	}
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		if inm != "*" {
+			return cond, fmt.Errorf("If-None-Match only supports *")
+		}
+		cond.IfAbsent = true
+	}
+	return cond, nil
+}
+
+// scoredMembers parses the repeated member and score query parameters of /zadd.
+func scoredMembers(q url.Values) ([]ports.ScoredMember, error) {
+	names, scores := q["member"], q["score"]
+	if len(names) == 0 || len(names) != len(scores) {
+		return nil, fmt.Errorf("expected matching member and score parameters")
+	}
+	members := make([]ports.ScoredMember, len(names))
+	for i, name := range names {
+		score, err := strconv.ParseFloat(scores[i], 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid score %q", scores[i])
+		}
+		members[i] = ports.ScoredMember{Member: name, Score: score}
+	}
+	return members, nil
+}
+
+// zrangeParams are the query parameters of /zrange.
+type zrangeParams struct {
+	byScore     bool
+	min, max    float64
+	start, stop int
+	limit       int
+}
+
+func parseZRange(q url.Values) (p zrangeParams, err error) {
+	p.byScore = q.Has("min") || q.Has("max")
+	if p.min, p.max, err = scoreRange(q); err != nil {
+		return p, err
+	}
+	if p.start, err = intParam(q, "start", 0); err != nil {
+		return p, err
+	}
+	if p.stop, err = intParam(q, "stop", -1); err != nil {
+		return p, err
+	}
+	p.limit, err = intParam(q, "limit", 0)
+	return p, err
+}
+
+// scoreRange parses the min and max query parameters, which may be -inf or
+// +inf and default to an unbounded range.
+func scoreRange(q url.Values) (min, max float64, err error) {
+	bounds := []*float64{&min, &max}
+	for i, name := range []string{"min", "max"} {
+		*bounds[i] = math.Inf(2*i - 1)
+		if s := q.Get(name); s != "" {
+			if *bounds[i], err = strconv.ParseFloat(s, 64); err != nil {
+				return 0, 0, fmt.Errorf("invalid %s %q", name, s)
+			}
+		}
+	}
+	return min, max, nil
+}
+
+// intParam parses an integer query parameter, returning def if it is absent.
+func intParam(q url.Values, name string, def int) (int, error) {
+	s := q.Get(name)
+	if s == "" {
+		return def, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s %q", name, s)
+	}
+	return n, nil
+}
+
+// writeError writes err to the response using the status code from the core error model.
+// Unexpected errors are logged and reported generically so internals are not leaked.
+func writeError(w http.ResponseWriter, err error) {
+	code := coreerrors.HTTPStatus(err)
+	if code == http.StatusInternalServerError {
+		log.Printf("Request failed: %v", err)
+	}
+	http.Error(w, coreerrors.PublicMessage(err), code)
+}
+
+// writeJSON encodes v as the JSON response body.
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("Failed to write response: %v", err)
+	}
+}
+
+// writeText writes s as the plain text response body.
+func writeText(w http.ResponseWriter, s string) {
+	if _, err := io.WriteString(w, s); err != nil {
+		log.Printf("Failed to write response: %v", err)
+	}
+}
@@ -0,0 +1,37 @@
+package http
+
+import (
+	"embed"
+	"io/fs"
+	"net/http"
+
+	"distributed-cache-service/internal/router"
+)
+
+//go:embed ui
+var uiFiles embed.FS
+
+// uiRoute declares /ui/, the admin UI: a page showing the cluster's members,
+// the hit ratio, the hot keys and a key browser. The page is static and
+// served to anyone; what it shows comes from the admin routes, with the
+// token the operator enters.
+func uiRoute() router.Route {
+	files, err := fs.Sub(uiFiles, "ui")
+	if err != nil {
+		panic(err)
+	}
+	static := http.StripPrefix("/ui/", http.FileServer(http.FS(files)))
+	return router.Route{
+		Method:    http.MethodGet,
+		Path:      "/ui/",
+		Summary:   "Admin UI",
+		Tag:       "operations",
+		Responses: []router.Response{{Status: http.StatusOK, Description: "The UI's page, scripts and styles", ContentType: "text/html"}},
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			// The page handles the admin token; keep other origins' scripts
+			// and frames away from it.
+			w.Header().Set("Content-Security-Policy", "default-src 'self'; frame-ancestors 'none'")
+			static.ServeHTTP(w, r)
+		}),
+	}
+}
@@ -0,0 +1,170 @@
+// The admin UI. The page itself holds no data: everything it shows comes from
+// the admin API, which requires the admin token. The token is kept in
+// sessionStorage, so it is forgotten when the tab is closed.
+"use strict";
+
+const api = "../v1";
+const refreshEvery = 5000;
+const graphPoints = 60; // five minutes at refreshEvery
+
+const $ = (id) => document.getElementById(id);
+let timer = null;
+let lastStats = null;
+const ratios = [];
+
+class Unauthorized extends Error {}
+
+async function call(path, options = {}) {
+  const headers = {};
+  const token = sessionStorage.getItem("token");
+  if (token) {
+    headers["Authorization"] = "Bearer " + token;
+  }
+  const resp = await fetch(api + path, { ...options, headers });
+  if (resp.status === 401) {
+    throw new Unauthorized();
+  }
+  return resp;
+}
+
+async function json(path) {
+  const resp = await call(path);
+  if (!resp.ok) {
+    throw new Error(path + ": " + resp.status + " " + (await resp.text()).trim());
+  }
+  return resp.json();
+}
+
+function cell(row, text) {
+  const td = document.createElement("td");
+  td.textContent = text;
+  row.appendChild(td);
+}
+
+function rows(tbody, items, columns) {
+  tbody.replaceChildren(...items.map((item) => {
+    const tr = document.createElement("tr");
+    columns.forEach((col) => cell(tr, col(item)));
+    return tr;
+  }));
+}
+
+async function refreshCluster() {
+  const status = await json("/admin/cluster");
+  $("node").textContent = (status.node_id || "") + " (" + status.state + ")";
+  const leader = status.members.find((m) => m.leader);
+  $("leader").textContent = leader ? leader.id + (leader.address ? " (" + leader.address + ")" : "") : "none";
+  rows($("members"), status.members, [
+    (m) => m.id,
+    (m) => m.address,
+    (m) => (m.voter ? "yes" : "no"),
+    (m) => (m.leader ? "yes" : ""),
+  ]);
+}
+
+async function refreshStats() {
+  const stats = await json("/admin/stats");
+  $("keys").textContent = stats.keys;
+  if (lastStats) {
+    const hits = stats.hits - lastStats.hits;
+    const reads = hits + stats.misses - lastStats.misses;
+    // Idle intervals have no ratio; they leave a gap in the history.
+    const ratio = reads > 0 ? hits / reads : null;
+    $("ratio").textContent = ratio === null ? "no reads" : (100 * ratio).toFixed(1) + "%";
+    ratios.push(ratio);
+    if (ratios.length > graphPoints) {
+      ratios.shift();
+    }
+    drawGraph();
+  }
+  lastStats = stats;
+}
+
+function drawGraph() {
+  const step = 600 / (graphPoints - 1);
+  const offset = graphPoints - ratios.length;
+  const points = [];
+  ratios.forEach((r, i) => {
+    if (r !== null) {
+      points.push(((offset + i) * step).toFixed(1) + "," + (150 - r * 150).toFixed(1));
+    }
+  });
+  $("line").setAttribute("points", points.join(" "));
+}
+
+async function refreshHotKeys() {
+  const resp = await call("/admin/hotkeys?n=10");
+  $("hotkeys-note").hidden = resp.status !== 404;
+  if (resp.ok) {
+    rows($("hotkeys"), await resp.json(), [(k) => k.key, (k) => k.count]);
+  }
+}
+
+async function refresh() {
+  try {
+    await Promise.all([refreshCluster(), refreshStats(), refreshHotKeys()]);
+  } catch (err) {
+    if (err instanceof Unauthorized) {
+      showLogin("Missing or invalid admin token.");
+      return;
+    }
+    console.error(err);
+  }
+}
+
+async function browse(op) {
+  const key = encodeURIComponent($("key").value);
+  let resp;
+  if (op === "get") {
+    resp = await call("/get?key=" + key);
+  } else if (op === "set") {
+    const value = encodeURIComponent($("value").value);
+    resp = await call("/set?key=" + key + "&value=" + value + "&ttl=" + Number($("ttl").value), { method: "POST" });
+  } else {
+    resp = await call("/getdel?key=" + key, { method: "POST" });
+  }
+  const body = await resp.text();
+  const version = resp.headers.get("ETag");
+  $("result").textContent = resp.status + " " + resp.statusText + (version ? " (version " + version + ")" : "") + "\n" + body;
+}
+
+function showLogin(message) {
+  clearInterval(timer);
+  timer = null;
+  $("dashboard").hidden = true;
+  $("logout").hidden = true;
+  $("login").hidden = false;
+  $("login-error").textContent = message || "";
+}
+
+function showDashboard() {
+  $("login").hidden = true;
+  $("dashboard").hidden = false;
+  $("logout").hidden = !sessionStorage.getItem("token");
+  lastStats = null;
+  ratios.length = 0;
+  refresh();
+  timer = setInterval(refresh, refreshEvery);
+}
+
+$("login").addEventListener("submit", (e) => {
+  e.preventDefault();
+  sessionStorage.setItem("token", $("token").value);
+  $("token").value = "";
+  showDashboard();
+});
+
+$("logout").addEventListener("click", () => {
+  sessionStorage.removeItem("token");
+  showLogin();
+});
+
+$("browser").addEventListener("submit", (e) => {
+  e.preventDefault();
+  browse(e.submitter.dataset.op).catch((err) => {
+    $("result").textContent = err instanceof Unauthorized ? "Missing or invalid admin token." : String(err);
+  });
+});
+
+// Try without a token first: a server without -admin_token needs none.
+showDashboard();
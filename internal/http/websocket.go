@@ -0,0 +1,307 @@
+package http
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	coreerrors "distributed-cache-service/internal/core/errors"
+	"distributed-cache-service/internal/core/ports"
+	"distributed-cache-service/internal/events"
+	"distributed-cache-service/internal/router"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// wsBuffer is how many events a /ws subscription may lag behind before it
+	// is dropped, as for gRPC Watch streams.
+	wsBuffer = 1024
+	// wsMaxFrame bounds the size of a frame sent by a client.
+	wsMaxFrame = 4 << 20
+	// wsPingInterval is how often idle connections are pinged; a connection
+	// that answers neither frames nor pings for wsPongWait is closed.
+	wsPingInterval = 30 * time.Second
+	wsPongWait     = 2 * wsPingInterval
+	wsWriteWait    = 10 * time.Second
+)
+
+// WSRequest is a frame sent by a /ws client. ID is echoed in the reply so the
+// client can match replies to requests sent without waiting.
+type WSRequest struct {
+	ID string `json:"id,omitempty"`
+	// Op is get, set, delete, subscribe or unsubscribe.
+	Op    string `json:"op"`
+	Key   string `json:"key,omitempty"`
+	Value string `json:"value,omitempty"`
+	// TTL is the lifetime of a set key, in seconds.
+	TTL int64 `json:"ttl,omitempty"`
+	// Prefix limits a subscription to the keys starting with it.
+	Prefix string `json:"prefix,omitempty"`
+}
+
+// WSMessage is a frame sent by the server: the reply to a request, or, with
+// Event set, a keyspace event of the connection's subscription.
+type WSMessage struct {
+	ID string `json:"id,omitempty"`
+	// Status is the HTTP status code the request would have had on the
+	// equivalent route, e.g. 404 for a missing key.
+	Status  int    `json:"status,omitempty"`
+	Value   string `json:"value,omitempty"`
+	Version uint64 `json:"version,omitempty"`
+	Error   string `json:"error,omitempty"`
+
+	// Event is SET, DELETE or FLUSH, as in package events, or DROPPED when
+	// the subscription fell behind and was closed: the client must
+	// resubscribe and assume it missed changes.
+	Event string `json:"event,omitempty"`
+	Key   string `json:"key,omitempty"`
+	Index uint64 `json:"index,omitempty"`
+}
+
+// WithEvents enables subscriptions on /ws, streaming events from b.
+func WithEvents(b *events.Broker) Option {
+	return func(a *Adapter) {
+		a.events = b
+	}
+}
+
+// WithOrigins lets browser pages served from origins, such as
+// https://dashboard.example.com, open /ws. Pages from other origins are
+// refused, except the server's own; clients that send no Origin, which are
+// not browsers, are always accepted.
+func WithOrigins(origins []string) Option {
+	return func(a *Adapter) {
+		a.origins = origins
+	}
+}
+
+// WithFrameLimit counts each /ws request frame as a request towards a rate
+// limit: allow reports whether one may proceed. Frames over the limit are
+// answered with 429 rather than served.
+func WithFrameLimit(allow func() bool) Option {
+	return func(a *Adapter) {
+		a.allowFrame = allow
+	}
+}
+
+// webSocketRoute declares /ws, at its unversioned path.
+func (a *Adapter) webSocketRoute() router.Route {
+	return router.Route{
+		Method:  http.MethodGet,
+		Path:    "/ws",
+		Summary: "WebSocket API",
+		Description: "Upgrades to a WebSocket carrying one JSON object per frame. Requests name an op: get, set, delete, " +
+			"subscribe or unsubscribe. Each is answered with its id and the status the equivalent route would have. " +
+			"After subscribe, the keyspace events of keys with the given prefix follow.",
+		Tag:     "keys",
+		Limited: true,
+		Responses: []router.Response{
+			{Status: http.StatusSwitchingProtocols, Description: "The connection is a WebSocket"},
+			{Status: http.StatusForbidden, Description: "The page's origin is not allowed"},
+		},
+		Handler: http.HandlerFunc(a.webSocket),
+	}
+}
+
+func (a *Adapter) webSocket(w http.ResponseWriter, r *http.Request) {
+	upgrader := websocket.Upgrader{CheckOrigin: a.checkOrigin}
+	conn, err := upgrader.Upgrade(hijackWriter{w}, r, nil)
+	if err != nil {
+		// Upgrade has answered the request already.
+		return
+	}
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+	s := &wsSession{adapter: a, conn: conn, ctx: ctx}
+	defer s.close()
+
+	conn.SetReadLimit(wsMaxFrame)
+	conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	})
+	go s.ping()
+
+	for {
+		_, frame, err := conn.ReadMessage()
+		if err != nil {
+			if !websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				log.Printf("WebSocket closed: %v", err)
+			}
+			return
+		}
+		var req WSRequest
+		if err := json.Unmarshal(frame, &req); err != nil {
+			s.send(WSMessage{Status: http.StatusBadRequest, Error: "invalid frame: " + err.Error()})
+			continue
+		}
+		conn.SetReadDeadline(time.Now().Add(wsPongWait))
+		reply, sub := s.serve(req)
+		reply.ID = req.ID
+		s.send(reply)
+		if sub != nil {
+			go s.forward(sub)
+		}
+	}
+}
+
+func (a *Adapter) checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" || slices.Contains(a.origins, origin) {
+		return true
+	}
+	u, err := url.Parse(origin)
+	return err == nil && strings.EqualFold(u.Host, r.Host)
+}
+
+// wsSession is one /ws connection. Requests are served in the order they
+// arrive. Replies, events and pings are written under mu, as a WebSocket
+// allows one writer at a time.
+type wsSession struct {
+	adapter *Adapter
+	conn    *websocket.Conn
+	ctx     context.Context
+
+	mu  sync.Mutex
+	sub *events.Subscription
+}
+
+// serve answers req. A subscribe request also returns the new subscription,
+// whose events are forwarded once the reply is sent.
+func (s *wsSession) serve(req WSRequest) (WSMessage, *events.Subscription) {
+	a := s.adapter
+	if a.allowFrame != nil && !a.allowFrame() {
+		return WSMessage{Status: http.StatusTooManyRequests, Error: "rate limit exceeded"}, nil
+	}
+	switch req.Op {
+	case "get":
+		val, version, err := a.service.GetVersioned(s.ctx, req.Key)
+		if err != nil {
+			return wsError(err), nil
+		}
+		return WSMessage{Status: http.StatusOK, Value: val, Version: version}, nil
+	case "set":
+		if req.TTL < 0 {
+			return WSMessage{Status: http.StatusBadRequest, Error: "invalid ttl"}, nil
+		}
+		version, err := a.service.SetIf(s.ctx, req.Key, req.Value, time.Duration(req.TTL)*time.Second, ports.Precondition{})
+		if err != nil {
+			return wsError(err), nil
+		}
+		return WSMessage{Status: http.StatusOK, Version: version}, nil
+	case "delete":
+		if err := a.service.Delete(s.ctx, req.Key); err != nil {
+			return wsError(err), nil
+		}
+		return WSMessage{Status: http.StatusOK}, nil
+	case "subscribe":
+		if a.events == nil {
+			return WSMessage{Status: http.StatusNotImplemented, Error: "keyspace events are not enabled"}, nil
+		}
+		s.unsubscribe()
+		sub := a.events.Subscribe(req.Prefix, wsBuffer)
+		s.mu.Lock()
+		s.sub = sub
+		s.mu.Unlock()
+		return WSMessage{Status: http.StatusOK}, sub
+	case "unsubscribe":
+		s.unsubscribe()
+		return WSMessage{Status: http.StatusOK}, nil
+	}
+	return WSMessage{Status: http.StatusBadRequest, Error: "unknown op " + strconv.Quote(req.Op)}, nil
+}
+
+func wsError(err error) WSMessage {
+	code := coreerrors.HTTPStatus(err)
+	if code == http.StatusInternalServerError {
+		log.Printf("WebSocket request failed: %v", err)
+	}
+	return WSMessage{Status: code, Error: coreerrors.PublicMessage(err)}
+}
+
+// forward sends the events of sub until it is closed, by unsubscribe or for
+// falling behind.
+func (s *wsSession) forward(sub *events.Subscription) {
+	for e := range sub.Events() {
+		s.send(WSMessage{Event: string(e.Type), Key: e.Key, Index: e.Index})
+	}
+	s.mu.Lock()
+	dropped := s.sub == sub
+	if dropped {
+		s.sub = nil
+	}
+	s.mu.Unlock()
+	if dropped {
+		s.send(WSMessage{Event: "DROPPED", Error: "watcher fell behind, events were dropped"})
+	}
+}
+
+// unsubscribe closes the connection's subscription, if any. Its forward
+// sends no DROPPED event, as the client asked for it.
+func (s *wsSession) unsubscribe() {
+	s.mu.Lock()
+	sub := s.sub
+	s.sub = nil
+	s.mu.Unlock()
+	if sub != nil {
+		sub.Close()
+	}
+}
+
+// ping keeps the connection alive through proxies and detects dead peers,
+// until the session ends.
+func (s *wsSession) ping() {
+	ticker := time.NewTicker(wsPingInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-s.ctx.Done():
+			return
+		case <-ticker.C:
+			s.mu.Lock()
+			err := s.conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(wsWriteWait))
+			s.mu.Unlock()
+			if err != nil {
+				return
+			}
+		}
+	}
+}
+
+// send writes m. Errors are left for the read loop to notice, as it does
+// when the connection fails.
+func (s *wsSession) send(m WSMessage) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+	if err := s.conn.WriteJSON(m); err != nil {
+		s.conn.Close()
+	}
+}
+
+func (s *wsSession) close() {
+	s.unsubscribe()
+	s.conn.Close()
+}
+
+// hijackWriter lets websocket.Upgrader take over the connection. It asserts
+// http.Hijacker, which the writers of the middleware do not implement; they
+// expose the underlying writer through Unwrap, as http.ResponseController
+// expects.
+type hijackWriter struct {
+	http.ResponseWriter
+}
+
+func (w hijackWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	return http.NewResponseController(w.ResponseWriter).Hijack()
+}
@@ -0,0 +1,114 @@
+package http
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"distributed-cache-service/internal/compression"
+	"distributed-cache-service/internal/consensus"
+	"distributed-cache-service/internal/core/service"
+	"distributed-cache-service/internal/events"
+	"distributed-cache-service/internal/router"
+	"distributed-cache-service/internal/store"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newWSServer serves the API of a standalone node publishing keyspace events,
+// behind gzip as in the server, and returns its /v1/ws URL.
+func newWSServer(t *testing.T, opts ...Option) string {
+	t.Helper()
+	kv := store.New()
+	broker := events.NewBroker()
+	node := consensus.NewStandalone("node1", consensus.NewFSM(kv, consensus.WithEvents(broker)))
+	rt := router.New("test", "1", router.WithMiddleware(Middleware()...))
+	New(service.New(kv, node, service.ConsistencyStrong), append([]Option{WithEvents(broker)}, opts...)...).Register(rt)
+	h, err := compression.GzipHandler(rt, 1, 1)
+	require.NoError(t, err)
+	srv := httptest.NewServer(h)
+	t.Cleanup(srv.Close)
+	return "ws" + strings.TrimPrefix(srv.URL, "http") + Version + "/ws"
+}
+
+func dialWS(t *testing.T, url string, header http.Header) *websocket.Conn {
+	t.Helper()
+	conn, _, err := websocket.DefaultDialer.Dial(url, header)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	return conn
+}
+
+func roundTrip(t *testing.T, conn *websocket.Conn, req WSRequest) WSMessage {
+	t.Helper()
+	require.NoError(t, conn.WriteJSON(req))
+	var msg WSMessage
+	require.NoError(t, conn.ReadJSON(&msg))
+	return msg
+}
+
+func TestWebSocket_Requests(t *testing.T) {
+	conn := dialWS(t, newWSServer(t), http.Header{"Accept-Encoding": {"gzip"}})
+
+	msg := roundTrip(t, conn, WSRequest{ID: "1", Op: "set", Key: "k", Value: "v"})
+	assert.Equal(t, "1", msg.ID)
+	assert.Equal(t, http.StatusOK, msg.Status)
+	assert.NotZero(t, msg.Version)
+
+	msg = roundTrip(t, conn, WSRequest{ID: "2", Op: "get", Key: "k"})
+	assert.Equal(t, WSMessage{ID: "2", Status: http.StatusOK, Value: "v", Version: msg.Version}, msg)
+
+	assert.Equal(t, http.StatusOK, roundTrip(t, conn, WSRequest{Op: "delete", Key: "k"}).Status)
+	msg = roundTrip(t, conn, WSRequest{ID: "3", Op: "get", Key: "k"})
+	assert.Equal(t, http.StatusNotFound, msg.Status)
+	assert.Equal(t, "key not found", msg.Error)
+
+	// The unversioned path still serves clients written against it.
+	legacy := dialWS(t, strings.TrimSuffix(newWSServer(t), Version+"/ws")+"/ws", nil)
+	assert.Equal(t, http.StatusNotFound, roundTrip(t, legacy, WSRequest{Op: "get", Key: "k"}).Status)
+
+	assert.Equal(t, http.StatusBadRequest, roundTrip(t, conn, WSRequest{Op: "set", Key: "k", TTL: -1}).Status)
+	assert.Equal(t, http.StatusBadRequest, roundTrip(t, conn, WSRequest{Op: "incr", Key: "k"}).Status)
+	require.NoError(t, conn.WriteMessage(websocket.TextMessage, []byte("{")))
+	var msg2 WSMessage
+	require.NoError(t, conn.ReadJSON(&msg2))
+	assert.Equal(t, http.StatusBadRequest, msg2.Status)
+}
+
+func TestWebSocket_Subscribe(t *testing.T) {
+	url := newWSServer(t)
+	watcher, writer := dialWS(t, url, nil), dialWS(t, url, nil)
+
+	assert.Equal(t, WSMessage{ID: "s", Status: http.StatusOK}, roundTrip(t, watcher, WSRequest{ID: "s", Op: "subscribe", Prefix: "user:"}))
+	roundTrip(t, writer, WSRequest{Op: "set", Key: "other", Value: "x"})
+	set := roundTrip(t, writer, WSRequest{Op: "set", Key: "user:1", Value: "x"})
+	roundTrip(t, writer, WSRequest{Op: "delete", Key: "user:1"})
+
+	var msg WSMessage
+	require.NoError(t, watcher.ReadJSON(&msg))
+	assert.Equal(t, WSMessage{Event: "SET", Key: "user:1", Index: set.Version}, msg)
+	require.NoError(t, watcher.ReadJSON(&msg))
+	assert.Equal(t, "DELETE", msg.Event)
+
+	// After unsubscribe, the next frame is the reply to the following request.
+	assert.Equal(t, http.StatusOK, roundTrip(t, watcher, WSRequest{Op: "unsubscribe"}).Status)
+	roundTrip(t, writer, WSRequest{Op: "set", Key: "user:2", Value: "x"})
+	assert.Equal(t, WSMessage{ID: "g", Status: http.StatusNotFound, Error: "key not found"}, roundTrip(t, watcher, WSRequest{ID: "g", Op: "get", Key: "missing"}))
+}
+
+func TestWebSocket_Limits(t *testing.T) {
+	url := newWSServer(t, WithOrigins([]string{"https://dashboard.example.com"}), WithFrameLimit(func() bool { return false }))
+
+	_, resp, err := websocket.DefaultDialer.Dial(url, http.Header{"Origin": {"https://evil.example.com"}})
+	require.Error(t, err)
+	assert.Equal(t, http.StatusForbidden, resp.StatusCode)
+
+	conn := dialWS(t, url, http.Header{"Origin": {"https://dashboard.example.com"}})
+	msg := roundTrip(t, conn, WSRequest{ID: "1", Op: "get", Key: "k"})
+	assert.Equal(t, WSMessage{ID: "1", Status: http.StatusTooManyRequests, Error: "rate limit exceeded"}, msg)
+}
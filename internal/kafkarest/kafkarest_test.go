@@ -0,0 +1,48 @@
+package kafkarest
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestProducer_Produce(t *testing.T) {
+	var got struct {
+		path, contentType string
+		body              struct {
+			Records []struct {
+				Key   string         `json:"key"`
+				Value map[string]int `json:"value"`
+			} `json:"records"`
+		}
+	}
+	fail := false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fail {
+			http.Error(w, "no such topic", http.StatusNotFound)
+			return
+		}
+		got.path = r.URL.Path
+		got.contentType = r.Header.Get("Content-Type")
+		json.NewDecoder(r.Body).Decode(&got.body)
+	}))
+	defer srv.Close()
+
+	p := New(srv.URL+"/", "cache changes")
+	if err := p.Produce(context.Background(), []Record{{Key: "a", Value: map[string]int{"n": 1}}, {Key: "b", Value: map[string]int{"n": 2}}}); err != nil {
+		t.Fatalf("produce: %v", err)
+	}
+	if got.path != "/topics/cache changes" || got.contentType != "application/vnd.kafka.json.v2+json" {
+		t.Errorf("unexpected request %s (%s)", got.path, got.contentType)
+	}
+	if recs := got.body.Records; len(recs) != 2 || recs[1].Key != "b" || recs[1].Value["n"] != 2 {
+		t.Errorf("unexpected records %+v", recs)
+	}
+
+	fail = true
+	if err := p.Produce(context.Background(), []Record{{Key: "a"}}); err == nil {
+		t.Error("expected an error for a non-2xx response")
+	}
+}
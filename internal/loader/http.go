@@ -0,0 +1,99 @@
+// Package loader provides read-through loaders that fetch cache misses from
+// an external system of record.
+package loader
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	coreerrors "distributed-cache-service/internal/core/errors"
+	"distributed-cache-service/internal/core/ports"
+)
+
+// maxValueSize caps how much of a loader response body is read.
+const maxValueSize = 64 << 20
+
+// ensure implementation
+var _ ports.Loader = (*HTTP)(nil)
+
+// HTTP loads values with GET requests to a remote endpoint.
+//
+// The key is substituted for "{key}" in the URL, or appended as a final path
+// segment if there is no placeholder. A 200 response body is the value and a
+// 404 is a miss. The TTL comes from a Cache-Control max-age directive, falling
+// back to the configured default.
+type HTTP struct {
+	url    string
+	ttl    time.Duration
+	client *http.Client
+}
+
+// NewHTTP creates an HTTP loader. timeout bounds each request.
+func NewHTTP(rawURL string, ttl, timeout time.Duration) (*HTTP, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid loader url: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("invalid loader url %q: scheme must be http or https", rawURL)
+	}
+	return &HTTP{url: rawURL, ttl: ttl, client: &http.Client{Timeout: timeout}}, nil
+}
+
+// Load implements ports.Loader.
+func (l *HTTP) Load(ctx context.Context, key string) (string, time.Duration, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, l.keyURL(key), nil)
+	if err != nil {
+		return "", 0, err
+	}
+	resp, err := l.client.Do(req)
+	if err != nil {
+		return "", 0, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return "", 0, coreerrors.ErrNotFound
+	default:
+		return "", 0, fmt.Errorf("loader returned %s", resp.Status)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxValueSize+1))
+	if err != nil {
+		return "", 0, err
+	}
+	if len(body) > maxValueSize {
+		return "", 0, fmt.Errorf("loader response exceeds %d bytes", maxValueSize)
+	}
+	return string(body), l.ttlFor(resp.Header.Get("Cache-Control")), nil
+}
+
+func (l *HTTP) keyURL(key string) string {
+	escaped := url.PathEscape(key)
+	if strings.Contains(l.url, "{key}") {
+		return strings.ReplaceAll(l.url, "{key}", escaped)
+	}
+	return strings.TrimSuffix(l.url, "/") + "/" + escaped
+}
+
+// ttlFor honours "max-age=N" from Cache-Control, else returns the default TTL.
+func (l *HTTP) ttlFor(cacheControl string) time.Duration {
+	for _, directive := range strings.Split(cacheControl, ",") {
+		name, value, ok := strings.Cut(strings.TrimSpace(directive), "=")
+		if !ok || !strings.EqualFold(name, "max-age") {
+			continue
+		}
+		if secs, err := strconv.Atoi(value); err == nil && secs > 0 {
+			return time.Duration(secs) * time.Second
+		}
+	}
+	return l.ttl
+}
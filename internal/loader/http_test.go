@@ -0,0 +1,65 @@
+package loader
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	coreerrors "distributed-cache-service/internal/core/errors"
+)
+
+func TestHTTP_Load(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/users/42":
+			w.Header().Set("Cache-Control", "public, max-age=30")
+			w.Write([]byte("alice"))
+		case "/users/a b":
+			w.Write([]byte("spaced"))
+		case "/users/broken":
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	l, err := NewHTTP(srv.URL+"/users/{key}", time.Minute, time.Second)
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	ctx := context.Background()
+
+	val, ttl, err := l.Load(ctx, "42")
+	if err != nil || val != "alice" || ttl != 30*time.Second {
+		t.Errorf("expected alice/30s, got %q/%v (%v)", val, ttl, err)
+	}
+
+	val, ttl, err = l.Load(ctx, "a b")
+	if err != nil || val != "spaced" || ttl != time.Minute {
+		t.Errorf("expected spaced/1m, got %q/%v (%v)", val, ttl, err)
+	}
+
+	if _, _, err := l.Load(ctx, "missing"); !errors.Is(err, coreerrors.ErrNotFound) {
+		t.Errorf("expected ErrNotFound, got %v", err)
+	}
+	if _, _, err := l.Load(ctx, "broken"); err == nil || errors.Is(err, coreerrors.ErrNotFound) {
+		t.Errorf("expected upstream error, got %v", err)
+	}
+}
+
+func TestHTTP_AppendsKeyWithoutPlaceholder(t *testing.T) {
+	l, err := NewHTTP("http://example.com/values/", 0, time.Second)
+	if err != nil {
+		t.Fatalf("new: %v", err)
+	}
+	if got := l.keyURL("k/1"); got != "http://example.com/values/k%2F1" {
+		t.Errorf("unexpected url %q", got)
+	}
+	if _, err := NewHTTP("ftp://example.com", 0, time.Second); err == nil {
+		t.Error("expected error for non-http scheme")
+	}
+}
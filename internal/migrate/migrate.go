@@ -0,0 +1,367 @@
+// Package migrate moves data between Redis and the cache. It exports the store
+// as a stream of Redis commands, which redis-cli --pipe loads into Redis, and
+// reads keys from such a stream, from a backup snapshot or from a live Redis
+// server to import them.
+//
+// Strings and sorted sets are migrated, with their expiration. Redis hashes,
+// lists, sets and streams have no counterpart in the cache and are skipped.
+package migrate
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"distributed-cache-service/internal/core/ports"
+	"distributed-cache-service/internal/resp"
+	"distributed-cache-service/internal/store"
+)
+
+// Record is a key to migrate: a string value, or a sorted set if Members is
+// not nil.
+type Record struct {
+	Key       string
+	Value     string
+	Members   []ports.ScoredMember
+	ExpiresAt time.Time // zero if the key does not expire
+}
+
+// Export writes the keys in the snapshot that snapshot writes to w as Redis
+// commands: SET, with PXAT for keys that expire, and ZADD. decode turns
+// stored values back into the values clients wrote. Keys that have expired are
+// left out. It returns how many keys it wrote.
+func Export(w io.Writer, snapshot func(io.Writer) error, decode func(stored string) (string, error)) (int, error) {
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(snapshot(pw))
+	}()
+	defer pr.Close()
+
+	now := time.Now().UnixNano()
+	rw := resp.NewWriter(w)
+	var n int
+	var werr error
+	err := store.ReadSnapshotWithSets(pr, func(key string, item *store.Item) {
+		if werr != nil || (item.Expiration > 0 && item.Expiration <= now) {
+			return
+		}
+		value, err := decode(item.Value)
+		if err != nil {
+			werr = fmt.Errorf("decode %q: %w", key, err)
+			return
+		}
+		args := []string{"SET", key, value}
+		if item.Expiration > 0 {
+			args = append(args, "PXAT", strconv.FormatInt(time.Unix(0, item.Expiration).UnixMilli(), 10))
+		}
+		werr = rw.Command(args...)
+		n++
+	}, func(key string, members []ports.ScoredMember) {
+		if werr != nil || len(members) == 0 {
+			return
+		}
+		args := make([]string, 0, 2+2*len(members))
+		args = append(args, "ZADD", key)
+		for _, m := range members {
+			args = append(args, strconv.FormatFloat(m.Score, 'g', -1, 64), m.Member)
+		}
+		werr = rw.Command(args...)
+		n++
+	})
+	if err == nil {
+		err = werr
+	}
+	if err != nil {
+		return n, err
+	}
+	return n, rw.Flush()
+}
+
+// ReadDump reads a stream of SET and ZADD commands, such as Export writes,
+// and calls fn with the key each of them writes. SET may carry EX, PX, EXAT or
+// PXAT.
+func ReadDump(r io.Reader, fn func(Record) error) error {
+	rr := resp.NewReader(r)
+	for {
+		v, err := rr.Read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		args, ok := stringArray(v)
+		if !ok || len(args) == 0 {
+			return fmt.Errorf("dump: expected a command, got %T", v)
+		}
+		rec, err := parseCommand(args, time.Now())
+		if err != nil {
+			return fmt.Errorf("dump: %w", err)
+		}
+		if err := fn(rec); err != nil {
+			return err
+		}
+	}
+}
+
+// ReadDataset reads the keys of a dataset exported from the cache, either a
+// backup snapshot or a stream of commands such as Export writes, and calls fn
+// with each of them. The two are told apart by their first byte. decode turns
+// the stored values of a snapshot back into the values clients wrote, and
+// keys that have expired are left out.
+func ReadDataset(r io.Reader, decode func(stored string) (string, error), fn func(Record) error) error {
+	br := bufio.NewReader(r)
+	if head, err := br.Peek(1); err == nil && head[0] == '*' {
+		return ReadDump(br, fn)
+	}
+
+	// Snapshot callbacks cannot fail, so the first error fails the reads instead.
+	var ferr error
+	now := time.Now().UnixNano()
+	err := store.ReadSnapshotWithSets(stopReader{br, &ferr}, func(key string, item *store.Item) {
+		if ferr != nil || (item.Expiration > 0 && item.Expiration <= now) {
+			return
+		}
+		value, err := decode(item.Value)
+		if err != nil {
+			ferr = fmt.Errorf("decode %q: %w", key, err)
+			return
+		}
+		rec := Record{Key: key, Value: value}
+		if item.Expiration > 0 {
+			rec.ExpiresAt = time.Unix(0, item.Expiration)
+		}
+		ferr = fn(rec)
+	}, func(key string, members []ports.ScoredMember) {
+		if ferr == nil && len(members) > 0 {
+			ferr = fn(Record{Key: key, Members: members})
+		}
+	})
+	if ferr != nil {
+		return ferr
+	}
+	return err
+}
+
+// stopReader fails every read once *err is set.
+type stopReader struct {
+	r   io.Reader
+	err *error
+}
+
+func (s stopReader) Read(p []byte) (int, error) {
+	if *s.err != nil {
+		return 0, *s.err
+	}
+	return s.r.Read(p)
+}
+
+func parseCommand(args []string, now time.Time) (Record, error) {
+	switch name := strings.ToUpper(args[0]); {
+	case name == "SET" && len(args) >= 3:
+		rec := Record{Key: args[1], Value: args[2]}
+		opts := args[3:]
+		for len(opts) > 0 {
+			if len(opts) < 2 {
+				return Record{}, fmt.Errorf("SET %q: option %s without a value", rec.Key, opts[0])
+			}
+			n, err := strconv.ParseInt(opts[1], 10, 64)
+			if err != nil || n <= 0 {
+				return Record{}, fmt.Errorf("SET %q: invalid %s %q", rec.Key, opts[0], opts[1])
+			}
+			switch strings.ToUpper(opts[0]) {
+			case "EX":
+				rec.ExpiresAt = now.Add(time.Duration(n) * time.Second)
+			case "PX":
+				rec.ExpiresAt = now.Add(time.Duration(n) * time.Millisecond)
+			case "EXAT":
+				rec.ExpiresAt = time.Unix(n, 0)
+			case "PXAT":
+				rec.ExpiresAt = time.UnixMilli(n)
+			default:
+				return Record{}, fmt.Errorf("SET %q: unsupported option %s", rec.Key, opts[0])
+			}
+			opts = opts[2:]
+		}
+		return rec, nil
+	case name == "ZADD" && len(args) >= 4 && len(args)%2 == 0:
+		rec := Record{Key: args[1], Members: make([]ports.ScoredMember, 0, (len(args)-2)/2)}
+		for i := 2; i < len(args); i += 2 {
+			score, err := strconv.ParseFloat(args[i], 64)
+			if err != nil {
+				return Record{}, fmt.Errorf("ZADD %q: invalid score %q", rec.Key, args[i])
+			}
+			rec.Members = append(rec.Members, ports.ScoredMember{Member: args[i+1], Score: score})
+		}
+		return rec, nil
+	default:
+		return Record{}, fmt.Errorf("unsupported command %s with %d arguments", name, len(args)-1)
+	}
+}
+
+// ScanOptions configures ScanRedis.
+type ScanOptions struct {
+	// Match is the glob-style pattern of the keys to read; empty means all.
+	Match string
+	// Count is the number of keys Redis examines per SCAN; 0 means 1000.
+	Count int
+	// Timeout bounds each round trip to Redis; 0 means none.
+	Timeout time.Duration
+}
+
+// ScanRedis reads the keys of the database conn is connected to with SCAN,
+// calling fn for each string and sorted set with its expiration. The values
+// of a batch of keys are read in one round trip, so the scan does not block
+// Redis for long. Keys changed during the scan may be seen in either state,
+// or twice. It returns how many keys of other types it skipped, by type.
+func ScanRedis(ctx context.Context, conn *resp.Conn, opts ScanOptions, fn func(Record) error) (map[string]int, error) {
+	count := opts.Count
+	if count <= 0 {
+		count = 1000
+	}
+	skipped := make(map[string]int)
+	cursor := "0"
+	for {
+		args := []string{"SCAN", cursor, "COUNT", strconv.Itoa(count)}
+		if opts.Match != "" {
+			args = append(args, "MATCH", opts.Match)
+		}
+		reply, err := roundTrip(ctx, conn, opts.Timeout, [][]string{args})
+		if err != nil {
+			return skipped, err
+		}
+		page, ok := reply[0].([]any)
+		if !ok || len(page) != 2 {
+			return skipped, fmt.Errorf("unexpected SCAN reply %v", reply[0])
+		}
+		cursor, _ = page[0].(string)
+		keys, ok := stringArray(page[1])
+		if !ok {
+			return skipped, fmt.Errorf("unexpected SCAN keys %v", page[1])
+		}
+		recs, err := readKeys(ctx, conn, opts.Timeout, keys, skipped)
+		if err != nil {
+			return skipped, err
+		}
+		for _, rec := range recs {
+			if err := fn(rec); err != nil {
+				return skipped, err
+			}
+		}
+		if cursor == "0" || cursor == "" {
+			return skipped, nil
+		}
+	}
+}
+
+// readKeys reads the strings and sorted sets among keys, counting the others
+// in skipped.
+func readKeys(ctx context.Context, conn *resp.Conn, timeout time.Duration, keys []string, skipped map[string]int) ([]Record, error) {
+	if len(keys) == 0 {
+		return nil, nil
+	}
+	cmds := make([][]string, len(keys))
+	for i, key := range keys {
+		cmds[i] = []string{"TYPE", key}
+	}
+	types, err := roundTrip(ctx, conn, timeout, cmds)
+	if err != nil {
+		return nil, err
+	}
+
+	var read []string
+	cmds = cmds[:0]
+	for i, key := range keys {
+		switch typ, _ := types[i].(string); typ {
+		case "string":
+			cmds = append(cmds, []string{"GET", key}, []string{"PTTL", key})
+		case "zset":
+			cmds = append(cmds, []string{"ZRANGE", key, "0", "-1", "WITHSCORES"}, []string{"PTTL", key})
+		case "none":
+			// Deleted since SCAN returned it.
+			continue
+		default:
+			skipped[typ]++
+			continue
+		}
+		read = append(read, key)
+	}
+	if len(read) == 0 {
+		return nil, nil
+	}
+	now := time.Now()
+	replies, err := roundTrip(ctx, conn, timeout, cmds)
+	if err != nil {
+		return nil, err
+	}
+
+	recs := make([]Record, 0, len(read))
+	for i, key := range read {
+		value, pttl := replies[2*i], replies[2*i+1]
+		ms, _ := pttl.(int64)
+		if value == nil || ms == -2 {
+			// Deleted or expired since its type was read.
+			continue
+		}
+		rec := Record{Key: key}
+		if ms > 0 {
+			rec.ExpiresAt = now.Add(time.Duration(ms) * time.Millisecond)
+		}
+		if cmds[2*i][0] == "GET" {
+			rec.Value, _ = value.(string)
+		} else {
+			flat, ok := stringArray(value)
+			if !ok || len(flat)%2 != 0 {
+				return nil, fmt.Errorf("unexpected ZRANGE reply for %q", key)
+			}
+			rec.Members = make([]ports.ScoredMember, 0, len(flat)/2)
+			for j := 0; j < len(flat); j += 2 {
+				score, err := strconv.ParseFloat(flat[j+1], 64)
+				if err != nil {
+					return nil, fmt.Errorf("sorted set %q: invalid score %q", key, flat[j+1])
+				}
+				rec.Members = append(rec.Members, ports.ScoredMember{Member: flat[j], Score: score})
+			}
+		}
+		recs = append(recs, rec)
+	}
+	return recs, nil
+}
+
+// roundTrip sends cmds, failing on the first error reply.
+func roundTrip(ctx context.Context, conn *resp.Conn, timeout time.Duration, cmds [][]string) ([]any, error) {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	replies, err := conn.Pipeline(ctx, cmds)
+	if err != nil {
+		return nil, err
+	}
+	for i, reply := range replies {
+		if e, ok := reply.(resp.Error); ok {
+			return nil, fmt.Errorf("%s %q: %w", cmds[i][0], cmds[i][1], e)
+		}
+	}
+	return replies, nil
+}
+
+func stringArray(v any) ([]string, bool) {
+	values, ok := v.([]any)
+	if !ok {
+		return nil, false
+	}
+	out := make([]string, len(values))
+	for i, v := range values {
+		if out[i], ok = v.(string); !ok {
+			return nil, false
+		}
+	}
+	return out, true
+}
@@ -0,0 +1,298 @@
+package migrate
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"distributed-cache-service/internal/core/ports"
+	"distributed-cache-service/internal/resp"
+	"distributed-cache-service/internal/store"
+)
+
+func TestExportReadDump(t *testing.T) {
+	s := store.New()
+	expiresAt := time.Now().Add(time.Hour).Truncate(time.Millisecond)
+	s.SetExpiresAt("session", "enc:token", expiresAt)
+	s.Set("plain", "enc:v\r\n", 0)
+	s.SetExpiresAt("gone", "enc:old", time.Now().Add(-time.Second))
+	if _, err := s.ZAdd("board", time.Now(), ports.ScoredMember{Member: "b", Score: 2}, ports.ScoredMember{Member: "a", Score: 1.5}); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	n, err := Export(&buf, s.Snapshot, func(stored string) (string, error) {
+		return strings.TrimPrefix(stored, "enc:"), nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 3 {
+		t.Errorf("expected 3 keys exported, got %d", n)
+	}
+	if !strings.Contains(buf.String(), "PXAT") {
+		t.Errorf("expected an absolute expiration in %q", buf.String())
+	}
+
+	got := make(map[string]Record)
+	if err := ReadDump(&buf, func(rec Record) error {
+		got[rec.Key] = rec
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]Record{
+		"session": {Key: "session", Value: "token", ExpiresAt: expiresAt},
+		"plain":   {Key: "plain", Value: "v\r\n"},
+		"board":   {Key: "board", Members: []ports.ScoredMember{{Member: "a", Score: 1.5}, {Member: "b", Score: 2}}},
+	}
+	for key, w := range want {
+		g := got[key]
+		if g.Key != w.Key || g.Value != w.Value || !g.ExpiresAt.Equal(w.ExpiresAt) || !reflect.DeepEqual(g.Members, w.Members) {
+			t.Errorf("%s: got %+v, want %+v", key, g, w)
+		}
+	}
+	if len(got) != len(want) {
+		t.Errorf("expected %d records, got %d", len(want), len(got))
+	}
+}
+
+func TestReadDataset(t *testing.T) {
+	s := store.New()
+	expiresAt := time.Now().Add(time.Hour).Truncate(time.Millisecond)
+	s.SetExpiresAt("session", "enc:token", expiresAt)
+	s.SetExpiresAt("gone", "enc:old", time.Now().Add(-time.Second))
+	if _, err := s.ZAdd("board", time.Now(), ports.ScoredMember{Member: "a", Score: 1}); err != nil {
+		t.Fatal(err)
+	}
+	decode := func(stored string) (string, error) {
+		return strings.TrimPrefix(stored, "enc:"), nil
+	}
+	var snap, dump bytes.Buffer
+	if err := s.Snapshot(&snap); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Export(&dump, s.Snapshot, decode); err != nil {
+		t.Fatal(err)
+	}
+
+	// Snapshots and dumps read back alike.
+	for name, data := range map[string][]byte{"snapshot": snap.Bytes(), "dump": dump.Bytes()} {
+		got := make(map[string]Record)
+		if err := ReadDataset(bytes.NewReader(data), decode, func(rec Record) error {
+			got[rec.Key] = rec
+			return nil
+		}); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		if len(got) != 2 || got["session"].Value != "token" || !got["session"].ExpiresAt.Equal(expiresAt) || len(got["board"].Members) != 1 {
+			t.Errorf("%s: unexpected records %+v", name, got)
+		}
+	}
+
+	// An error from fn stops the read.
+	boom := errors.New("boom")
+	calls := 0
+	err := ReadDataset(bytes.NewReader(snap.Bytes()), decode, func(Record) error {
+		calls++
+		return boom
+	})
+	if !errors.Is(err, boom) || calls != 1 {
+		t.Errorf("expected one call and its error, got %d calls (%v)", calls, err)
+	}
+}
+
+func TestReadDump(t *testing.T) {
+	var buf bytes.Buffer
+	w := resp.NewWriter(&buf)
+	w.Command("set", "a", "1", "EX", "60")
+	w.Command("SET", "b", "2", "px", "1500")
+	w.Command("SET", "c", "3", "EXAT", "2000000000")
+	w.Flush()
+
+	before := time.Now()
+	var recs []Record
+	if err := ReadDump(&buf, func(rec Record) error {
+		recs = append(recs, rec)
+		return nil
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if len(recs) != 3 {
+		t.Fatalf("expected 3 records, got %d", len(recs))
+	}
+	if ttl := recs[0].ExpiresAt.Sub(before); ttl < 59*time.Second || ttl > 61*time.Second {
+		t.Errorf("EX: expected 60s, got %v", ttl)
+	}
+	if ttl := recs[1].ExpiresAt.Sub(before); ttl < time.Second || ttl > 2*time.Second {
+		t.Errorf("PX: expected 1.5s, got %v", ttl)
+	}
+	if !recs[2].ExpiresAt.Equal(time.Unix(2000000000, 0)) {
+		t.Errorf("EXAT: got %v", recs[2].ExpiresAt)
+	}
+
+	for _, cmd := range [][]string{
+		{"HSET", "h", "f", "v"},
+		{"SET", "k", "v", "NX"},
+		{"SET", "k", "v", "EX", "-1"},
+		{"ZADD", "z", "NaN?", "m"},
+		{"ZADD", "z", "1"},
+	} {
+		buf.Reset()
+		w.Command(cmd...)
+		w.Flush()
+		if err := ReadDump(&buf, func(Record) error { return nil }); err == nil {
+			t.Errorf("%v: expected an error", cmd)
+		}
+	}
+}
+
+// fakeRedis serves SCAN, TYPE, GET, ZRANGE and PTTL from fixed data, two
+// keys per SCAN page.
+type fakeRedis struct {
+	strings map[string]string
+	zsets   map[string][]string // member, score, ...
+	others  map[string]string   // key -> type
+	pttl    map[string]int64
+}
+
+func (f *fakeRedis) serve(t *testing.T) string {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	var keys []string
+	for k := range f.strings {
+		keys = append(keys, k)
+	}
+	for k := range f.zsets {
+		keys = append(keys, k)
+	}
+	for k := range f.others {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := resp.NewReader(conn)
+		for {
+			v, err := r.Read()
+			if err != nil {
+				return
+			}
+			args, _ := stringArray(v)
+			var reply []byte
+			switch args[0] {
+			case "SCAN":
+				cursor, _ := strconv.Atoi(args[1])
+				end := min(cursor+2, len(keys))
+				next := strconv.Itoa(end)
+				if end == len(keys) {
+					next = "0"
+				}
+				reply = encode(t, []any{next, toAny(keys[cursor:end])})
+			case "TYPE":
+				typ := "none"
+				if _, ok := f.strings[args[1]]; ok {
+					typ = "string"
+				} else if _, ok := f.zsets[args[1]]; ok {
+					typ = "zset"
+				} else if o, ok := f.others[args[1]]; ok {
+					typ = o
+				}
+				reply = []byte("+" + typ + "\r\n")
+			case "GET":
+				reply = encode(t, f.strings[args[1]])
+			case "ZRANGE":
+				reply = encode(t, toAny(f.zsets[args[1]]))
+			case "PTTL":
+				ttl, ok := f.pttl[args[1]]
+				if !ok {
+					ttl = -1
+				}
+				reply = []byte(":" + strconv.FormatInt(ttl, 10) + "\r\n")
+			}
+			conn.Write(reply)
+		}
+	}()
+	return ln.Addr().String()
+}
+
+func toAny(ss []string) []any {
+	out := make([]any, len(ss))
+	for i, s := range ss {
+		out[i] = s
+	}
+	return out
+}
+
+// encode writes a bulk string or an array of bulk strings and arrays.
+func encode(t *testing.T, v any) []byte {
+	switch v := v.(type) {
+	case string:
+		return []byte("$" + strconv.Itoa(len(v)) + "\r\n" + v + "\r\n")
+	case []any:
+		out := []byte("*" + strconv.Itoa(len(v)) + "\r\n")
+		for _, e := range v {
+			out = append(out, encode(t, e)...)
+		}
+		return out
+	}
+	t.Fatalf("cannot encode %T", v)
+	return nil
+}
+
+func TestScanRedis(t *testing.T) {
+	f := &fakeRedis{
+		strings: map[string]string{"a": "1", "b": "2", "c": "3"},
+		zsets:   map[string][]string{"z": {"m1", "1", "m2", "2.5"}},
+		others:  map[string]string{"h": "hash", "l": "list", "l2": "list"},
+		pttl:    map[string]int64{"a": 60000, "c": -2},
+	}
+	ctx := context.Background()
+	conn, err := resp.Dial(ctx, f.serve(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	before := time.Now()
+	got := make(map[string]Record)
+	skipped, err := ScanRedis(ctx, conn, ScanOptions{Count: 2, Timeout: time.Second}, func(rec Record) error {
+		got[rec.Key] = rec
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(skipped, map[string]int{"hash": 1, "list": 2}) {
+		t.Errorf("unexpected skipped types %v", skipped)
+	}
+	if len(got) != 3 {
+		t.Fatalf("expected a, b and z (c expired), got %v", got)
+	}
+	if ttl := got["a"].ExpiresAt.Sub(before); got["a"].Value != "1" || ttl < 59*time.Second || ttl > 61*time.Second {
+		t.Errorf("unexpected record %+v", got["a"])
+	}
+	if !got["b"].ExpiresAt.IsZero() || got["b"].Value != "2" {
+		t.Errorf("unexpected record %+v", got["b"])
+	}
+	want := []ports.ScoredMember{{Member: "m1", Score: 1}, {Member: "m2", Score: 2.5}}
+	if !reflect.DeepEqual(got["z"].Members, want) {
+		t.Errorf("got members %v, want %v", got["z"].Members, want)
+	}
+}
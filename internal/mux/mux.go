@@ -0,0 +1,242 @@
+// Package mux serves several protocols on one listener, in the style of cmux.
+//
+// Each accepted connection is sniffed: its first bytes are offered to the
+// matchers of each registered listener in turn, and the connection is handed,
+// with those bytes replayed, to the first listener that claims it. Connections
+// that nobody claims are closed.
+package mux
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"sync"
+	"time"
+)
+
+// DefaultSniffTimeout bounds how long a new connection may take to send the
+// bytes its protocol is recognised by.
+const DefaultSniffTimeout = 5 * time.Second
+
+// ErrListenerClosed is returned by Accept once the Mux has stopped.
+var ErrListenerClosed = errors.New("mux: listener closed")
+
+// Matcher reports whether a connection belongs to a protocol, reading as few
+// of its first bytes as it needs from r.
+type Matcher func(r io.Reader) bool
+
+// Any matches every connection. Register it last, as a fallback.
+func Any() Matcher {
+	return func(io.Reader) bool { return true }
+}
+
+// http2Preface opens every HTTP/2 connection without TLS, gRPC included.
+const http2Preface = "PRI * HTTP/2.0\r\n\r\nSM\r\n\r\n"
+
+// HTTP2 matches cleartext HTTP/2 (h2c) connections, such as gRPC's.
+func HTTP2() Matcher {
+	return func(r io.Reader) bool {
+		return hasPrefix(r, http2Preface)
+	}
+}
+
+// hasPrefix reads r until it has seen prefix or a byte that differs from it,
+// so that short messages of other protocols are rejected without blocking.
+func hasPrefix(r io.Reader, prefix string) bool {
+	b := make([]byte, 1)
+	for i := 0; i < len(prefix); i++ {
+		if _, err := io.ReadFull(r, b); err != nil || b[0] != prefix[i] {
+			return false
+		}
+	}
+	return true
+}
+
+var httpMethods = []string{"GET", "HEAD", "POST", "PUT", "DELETE", "CONNECT", "OPTIONS", "TRACE", "PATCH"}
+
+// HTTP1 matches HTTP/1.x connections by the method their request line starts with.
+func HTTP1() Matcher {
+	return func(r io.Reader) bool {
+		buf := make([]byte, 0, 8)
+		b := make([]byte, 1)
+		for len(buf) < cap(buf) {
+			if _, err := io.ReadFull(r, b); err != nil {
+				return false
+			}
+			if b[0] == ' ' {
+				for _, m := range httpMethods {
+					if string(buf) == m {
+						return true
+					}
+				}
+				return false
+			}
+			buf = append(buf, b[0])
+		}
+		return false
+	}
+}
+
+// Mux dispatches the connections accepted by a listener to per-protocol listeners.
+type Mux struct {
+	root         net.Listener
+	sniffTimeout time.Duration
+	routes       []route
+	done         chan struct{}
+	closeOnce    sync.Once
+}
+
+type route struct {
+	matchers []Matcher
+	l        *listener
+}
+
+// New creates a Mux over root. Register listeners with Match, then call Serve.
+func New(root net.Listener) *Mux {
+	return &Mux{root: root, sniffTimeout: DefaultSniffTimeout, done: make(chan struct{})}
+}
+
+// SetSniffTimeout changes how long a connection may take to be recognised.
+func (m *Mux) SetSniffTimeout(d time.Duration) {
+	m.sniffTimeout = d
+}
+
+// Match returns a listener for the connections that any of matchers claims.
+// Listeners are tried in the order they were registered.
+func (m *Mux) Match(matchers ...Matcher) net.Listener {
+	l := &listener{addr: m.root.Addr(), conns: make(chan net.Conn), done: m.done, closed: make(chan struct{})}
+	m.routes = append(m.routes, route{matchers: matchers, l: l})
+	return l
+}
+
+// Serve accepts connections until the root listener fails or is closed, then
+// closes every registered listener.
+func (m *Mux) Serve() error {
+	defer m.Close()
+	for {
+		conn, err := m.root.Accept()
+		if err != nil {
+			select {
+			case <-m.done:
+				return nil
+			default:
+			}
+			var ne net.Error
+			if errors.As(err, &ne) && ne.Timeout() {
+				continue
+			}
+			return err
+		}
+		go m.dispatch(conn)
+	}
+}
+
+// Close stops the Mux and closes the root listener.
+func (m *Mux) Close() error {
+	var err error
+	m.closeOnce.Do(func() {
+		close(m.done)
+		err = m.root.Close()
+	})
+	return err
+}
+
+func (m *Mux) dispatch(conn net.Conn) {
+	s := &sniffer{src: conn}
+	if m.sniffTimeout > 0 {
+		_ = conn.SetReadDeadline(time.Now().Add(m.sniffTimeout))
+	}
+	for _, r := range m.routes {
+		for _, match := range r.matchers {
+			s.rewind()
+			if !match(s) {
+				continue
+			}
+			_ = conn.SetReadDeadline(time.Time{})
+			select {
+			case r.l.conns <- &sniffedConn{Conn: conn, head: bytes.NewReader(s.buf)}:
+			case <-r.l.closed:
+				conn.Close()
+			case <-m.done:
+				conn.Close()
+			}
+			return
+		}
+	}
+	conn.Close()
+}
+
+// sniffChunk is how much a sniffer reads ahead: matchers consume a byte at a
+// time, but one read usually fetches all they need.
+const sniffChunk = 512
+
+// sniffer records what matchers read from src so that the next matcher, and
+// finally the claiming listener, can read it again.
+type sniffer struct {
+	src io.Reader
+	buf []byte
+	pos int
+}
+
+func (s *sniffer) rewind() {
+	s.pos = 0
+}
+
+func (s *sniffer) Read(p []byte) (int, error) {
+	if s.pos == len(s.buf) {
+		chunk := make([]byte, sniffChunk)
+		n, err := s.src.Read(chunk)
+		if n == 0 {
+			return 0, err
+		}
+		s.buf = append(s.buf, chunk[:n]...)
+	}
+	n := copy(p, s.buf[s.pos:])
+	s.pos += n
+	return n, nil
+}
+
+// sniffedConn replays the sniffed bytes before reading from the connection.
+type sniffedConn struct {
+	net.Conn
+	head *bytes.Reader
+}
+
+func (c *sniffedConn) Read(p []byte) (int, error) {
+	if c.head.Len() > 0 {
+		return c.head.Read(p)
+	}
+	return c.Conn.Read(p)
+}
+
+// listener receives the connections claimed by its route.
+type listener struct {
+	addr      net.Addr
+	conns     chan net.Conn
+	done      chan struct{} // the Mux's
+	closed    chan struct{}
+	closeOnce sync.Once
+}
+
+func (l *listener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.conns:
+		return conn, nil
+	case <-l.closed:
+		return nil, ErrListenerClosed
+	case <-l.done:
+		return nil, ErrListenerClosed
+	}
+}
+
+// Close stops this listener; later connections it would claim are closed.
+// The Mux and its other listeners keep running.
+func (l *listener) Close() error {
+	l.closeOnce.Do(func() { close(l.closed) })
+	return nil
+}
+
+func (l *listener) Addr() net.Addr {
+	return l.addr
+}
@@ -0,0 +1,106 @@
+package mux
+
+import (
+	"io"
+	"net"
+	"testing"
+	"time"
+)
+
+func TestMux_RoutesByFirstBytes(t *testing.T) {
+	root, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	m := New(root)
+	m.SetSniffTimeout(time.Second)
+	binary := m.Match(func(r io.Reader) bool {
+		b := make([]byte, 1)
+		_, err := io.ReadFull(r, b)
+		return err == nil && b[0] < 8
+	})
+	h2 := m.Match(HTTP2())
+	h1 := m.Match(HTTP1())
+	go m.Serve()
+	defer m.Close()
+
+	tests := []struct {
+		name string
+		send string
+		l    net.Listener
+	}{
+		{"binary", "\x01raft rpc", binary},
+		{"http2", http2Preface + "\x00\x00\x00\x04\x00\x00\x00\x00\x00", h2},
+		{"http1", "GET /health HTTP/1.1\r\nHost: x\r\n\r\n", h1},
+		// Shorter than the HTTP/2 preface, so sniffing must not wait for more.
+		{"short http1", "GET / HTTP/1.0\r\n\r\n", h1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn, err := net.Dial("tcp", root.Addr().String())
+			if err != nil {
+				t.Fatalf("dial: %v", err)
+			}
+			defer conn.Close()
+			if _, err := io.WriteString(conn, tt.send); err != nil {
+				t.Fatalf("write: %v", err)
+			}
+
+			accepted := make(chan net.Conn, 1)
+			go func() {
+				c, err := tt.l.Accept()
+				if err == nil {
+					accepted <- c
+				}
+			}()
+			select {
+			case c := <-accepted:
+				defer c.Close()
+				got := make([]byte, len(tt.send))
+				if _, err := io.ReadFull(c, got); err != nil || string(got) != tt.send {
+					t.Errorf("expected the sniffed bytes to be replayed, got %q (%v)", got, err)
+				}
+			case <-time.After(500 * time.Millisecond):
+				t.Fatal("connection was not routed")
+			}
+		})
+	}
+
+	t.Run("unmatched", func(t *testing.T) {
+		conn, err := net.Dial("tcp", root.Addr().String())
+		if err != nil {
+			t.Fatalf("dial: %v", err)
+		}
+		defer conn.Close()
+		io.WriteString(conn, "\xffunknown protocol")
+		conn.SetReadDeadline(time.Now().Add(time.Second))
+		if _, err := conn.Read(make([]byte, 1)); err != io.EOF {
+			t.Errorf("expected the connection to be closed, got %v", err)
+		}
+	})
+}
+
+func TestMux_Close(t *testing.T) {
+	root, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen: %v", err)
+	}
+	m := New(root)
+	a, b := m.Match(Any()), m.Match(Any())
+	served := make(chan error, 1)
+	go func() { served <- m.Serve() }()
+
+	// Closing one listener leaves the others running.
+	a.Close()
+	if _, err := a.Accept(); err != ErrListenerClosed {
+		t.Errorf("expected ErrListenerClosed, got %v", err)
+	}
+
+	m.Close()
+	if err := <-served; err != nil {
+		t.Errorf("expected Serve to return nil after Close, got %v", err)
+	}
+	if _, err := b.Accept(); err != ErrListenerClosed {
+		t.Errorf("expected ErrListenerClosed, got %v", err)
+	}
+}
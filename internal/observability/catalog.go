@@ -0,0 +1,89 @@
+package observability
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	dto "github.com/prometheus/client_model/go"
+)
+
+// Metric types, as in Prometheus exposition.
+const (
+	TypeCounter   = "counter"
+	TypeGauge     = "gauge"
+	TypeHistogram = "histogram"
+)
+
+// Definition describes a metric defined by this package.
+type Definition struct {
+	Name   string
+	Help   string
+	Type   string
+	Labels []string
+}
+
+// definitions holds every metric registered through the constructors below,
+// so that dashboards and alerts can be generated from the code.
+var definitions = map[string]Definition{}
+
+// Definitions returns the metrics defined by this package, sorted by name.
+func Definitions() []Definition {
+	defs := make([]Definition, 0, len(definitions))
+	for _, d := range definitions {
+		defs = append(defs, d)
+	}
+	slices.SortFunc(defs, func(a, b Definition) int { return strings.Compare(a.Name, b.Name) })
+	return defs
+}
+
+// Lookup returns the definition of the metric called name.
+func Lookup(name string) (Definition, bool) {
+	d, ok := definitions[name]
+	return d, ok
+}
+
+func define(name, help, typ string, labels []string) {
+	definitions[name] = Definition{Name: name, Help: help, Type: typ, Labels: labels}
+}
+
+func newCounter(opts prometheus.CounterOpts) prometheus.Counter {
+	define(opts.Name, opts.Help, TypeCounter, nil)
+	return promauto.NewCounter(opts)
+}
+
+func newCounterVec(opts prometheus.CounterOpts, labels []string) *prometheus.CounterVec {
+	define(opts.Name, opts.Help, TypeCounter, labels)
+	return promauto.NewCounterVec(opts, labels)
+}
+
+func newGauge(opts prometheus.GaugeOpts) prometheus.Gauge {
+	define(opts.Name, opts.Help, TypeGauge, nil)
+	return promauto.NewGauge(opts)
+}
+
+func newGaugeVec(opts prometheus.GaugeOpts, labels []string) *prometheus.GaugeVec {
+	define(opts.Name, opts.Help, TypeGauge, labels)
+	return promauto.NewGaugeVec(opts, labels)
+}
+
+func newHistogram(opts prometheus.HistogramOpts) prometheus.Histogram {
+	define(opts.Name, opts.Help, TypeHistogram, nil)
+	return promauto.NewHistogram(opts)
+}
+
+func newHistogramVec(opts prometheus.HistogramOpts, labels []string) *prometheus.HistogramVec {
+	define(opts.Name, opts.Help, TypeHistogram, labels)
+	return promauto.NewHistogramVec(opts, labels)
+}
+
+// CounterValue returns the current value of c, for showing it outside
+// Prometheus.
+func CounterValue(c prometheus.Counter) float64 {
+	var m dto.Metric
+	if err := c.Write(&m); err != nil {
+		return 0
+	}
+	return m.GetCounter().GetValue()
+}
@@ -0,0 +1,266 @@
+package observability
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// DashboardUID identifies the generated Grafana dashboard, so that importing a
+// newer export replaces the older one.
+const DashboardUID = "distributed-cache"
+
+// Thresholds tunes the generated alerts.
+type Thresholds struct {
+	// MissRatio is the share of lookups that may miss.
+	MissRatio float64
+	// LagEntries is how many entries a node may have applied fewer of than
+	// the most up-to-date node.
+	LagEntries int
+	// EvictionsPerSecond is the eviction rate that counts as a storm.
+	EvictionsPerSecond float64
+}
+
+// DefaultThresholds returns the thresholds cachectl uses unless told otherwise.
+func DefaultThresholds() Thresholds {
+	return Thresholds{MissRatio: 0.5, LagEntries: 1000, EvictionsPerSecond: 100}
+}
+
+// query is a PromQL expression and the metrics it reads, which must be
+// defined by this package.
+type query struct {
+	expr    string
+	legend  string
+	metrics []string
+}
+
+// overview lists the panels shown above the per-metric ones.
+var overview = []struct {
+	title, unit string
+	query
+}{
+	{"Hit ratio", "percentunit", query{
+		expr:    "sum(rate(cache_hits_total[5m])) / (sum(rate(cache_hits_total[5m])) + sum(rate(cache_misses_total[5m])))",
+		legend:  "hit ratio",
+		metrics: []string{"cache_hits_total", "cache_misses_total"},
+	}},
+	{"Operation latency (p99)", "s", query{
+		expr:    "histogram_quantile(0.99, sum by (type, le) (rate(cache_duration_seconds_bucket[5m])))",
+		legend:  "{{type}}",
+		metrics: []string{"cache_duration_seconds"},
+	}},
+	{"Leader", "none", query{
+		expr:    "cache_raft_leader",
+		legend:  "{{instance}}",
+		metrics: []string{"cache_raft_leader"},
+	}},
+	{"Entries behind the most up-to-date node", "none", query{
+		expr:    "max(cache_raft_applied_index) - cache_raft_applied_index",
+		legend:  "{{instance}}",
+		metrics: []string{"cache_raft_applied_index"},
+	}},
+}
+
+// alert is a Prometheus alerting rule.
+type alert struct {
+	name     string
+	query    query
+	wait     string
+	severity string
+	summary  string
+}
+
+func alerts(t Thresholds) []alert {
+	return []alert{
+		{
+			name: "CacheNoLeader",
+			query: query{
+				expr:    "max by (job) (cache_raft_leader) < 1",
+				metrics: []string{"cache_raft_leader"},
+			},
+			wait:     "1m",
+			severity: "critical",
+			summary:  "No node of {{ $labels.job }} is the Raft leader: writes and linearizable reads fail.",
+		},
+		{
+			name: "CacheHighMissRatio",
+			query: query{
+				expr: fmt.Sprintf("sum by (job) (rate(cache_misses_total[5m])) / (sum by (job) (rate(cache_hits_total[5m])) + sum by (job) (rate(cache_misses_total[5m]))) > %s",
+					formatFloat(t.MissRatio)),
+				metrics: []string{"cache_hits_total", "cache_misses_total"},
+			},
+			wait:     "15m",
+			severity: "warning",
+			summary:  "{{ $value | humanizePercentage }} of lookups on {{ $labels.job }} miss.",
+		},
+		{
+			name: "CacheReplicationLag",
+			query: query{
+				expr:    fmt.Sprintf("max by (job) (cache_raft_applied_index) - on (job) group_right cache_raft_applied_index > %d", t.LagEntries),
+				metrics: []string{"cache_raft_applied_index"},
+			},
+			wait:     "5m",
+			severity: "warning",
+			summary:  "{{ $labels.instance }} has applied {{ $value }} fewer entries than the most up-to-date node.",
+		},
+		{
+			name: "CacheEvictionStorm",
+			query: query{
+				expr:    fmt.Sprintf("sum by (job) (rate(cache_evictions_total[5m])) > %s", formatFloat(t.EvictionsPerSecond)),
+				metrics: []string{"cache_evictions_total"},
+			},
+			wait:     "10m",
+			severity: "warning",
+			summary:  "{{ $labels.job }} evicts {{ $value | humanize }} keys/s to stay within max_items: the cache is too small for its working set.",
+		},
+		{
+			name: "CacheWriteBreakerOpen",
+			query: query{
+				expr:    "max by (job, instance) (cache_write_breaker_state) == 1",
+				metrics: []string{"cache_write_breaker_state"},
+			},
+			wait:     "1m",
+			severity: "critical",
+			summary:  "The write circuit breaker of {{ $labels.instance }} is open: writes fail fast.",
+		},
+	}
+}
+
+// check reports a query reading a metric that is not defined.
+func (q query) check() error {
+	for _, name := range q.metrics {
+		if _, ok := Lookup(name); !ok {
+			return fmt.Errorf("query %q reads undefined metric %s", q.expr, name)
+		}
+	}
+	return nil
+}
+
+// perMetric returns the query a metric is graphed with on the dashboard, and
+// the unit of its values.
+func perMetric(d Definition) (query, string) {
+	by := strings.Join(d.Labels, ", ")
+	q := query{metrics: []string{d.Name}, legend: "{{instance}}"}
+	if by != "" {
+		q.legend = "{{" + strings.Join(d.Labels, "}} {{") + "}}"
+	}
+	unit := "none"
+	switch {
+	case strings.HasSuffix(d.Name, "_seconds"):
+		unit = "s"
+	case strings.HasSuffix(d.Name, "_bytes") || strings.HasSuffix(d.Name, "_bytes_total"):
+		unit = "bytes"
+	}
+	switch d.Type {
+	case TypeCounter:
+		q.expr = fmt.Sprintf("sum by (%s) (rate(%s[5m]))", by, d.Name)
+		if by == "" {
+			q.expr = fmt.Sprintf("sum(rate(%s[5m]))", d.Name)
+			q.legend = "total"
+		}
+		if unit == "bytes" {
+			unit = "Bps"
+		} else {
+			unit = "ops"
+		}
+	case TypeHistogram:
+		q.expr = fmt.Sprintf("histogram_quantile(0.99, sum by (%s) (rate(%s_bucket[5m])))", strings.TrimPrefix(by+", le", ", "), d.Name)
+		if by == "" {
+			q.legend = "p99"
+		}
+	default:
+		q.expr = d.Name
+		if by != "" {
+			q.legend = "{{instance}} " + q.legend
+		}
+	}
+	return q, unit
+}
+
+// Dashboard returns a Grafana dashboard, as JSON, with overview panels
+// followed by a panel for every metric this package defines.
+func Dashboard() ([]byte, error) {
+	// Panels are laid out two to a line, each section under a row header.
+	var panels []map[string]interface{}
+	y, col := 0, 0
+	row := func(title string) {
+		if col == 1 {
+			y, col = y+8, 0
+		}
+		panels = append(panels, map[string]interface{}{
+			"id": len(panels) + 1, "type": "row", "title": title, "collapsed": false,
+			"gridPos": map[string]int{"h": 1, "w": 24, "x": 0, "y": y},
+		})
+		y++
+	}
+	graph := func(title, description, unit string, q query) error {
+		if err := q.check(); err != nil {
+			return err
+		}
+		panels = append(panels, map[string]interface{}{
+			"id": len(panels) + 1, "type": "timeseries", "title": title, "description": description,
+			"datasource":  map[string]string{"type": "prometheus", "uid": "${datasource}"},
+			"gridPos":     map[string]int{"h": 8, "w": 12, "x": col * 12, "y": y},
+			"fieldConfig": map[string]interface{}{"defaults": map[string]string{"unit": unit}, "overrides": []interface{}{}},
+			"targets":     []map[string]string{{"refId": "A", "expr": q.expr, "legendFormat": q.legend}},
+		})
+		if col == 1 {
+			y += 8
+		}
+		col = 1 - col
+		return nil
+	}
+
+	row("Overview")
+	for _, p := range overview {
+		if err := graph(p.title, "", p.unit, p.query); err != nil {
+			return nil, err
+		}
+	}
+	row("Metrics")
+	for _, d := range Definitions() {
+		q, unit := perMetric(d)
+		if err := graph(d.Name, d.Help, unit, q); err != nil {
+			return nil, err
+		}
+	}
+
+	return json.MarshalIndent(map[string]interface{}{
+		"uid":           DashboardUID,
+		"title":         "Distributed Cache",
+		"tags":          []string{"cache", "raft"},
+		"schemaVersion": 39,
+		"editable":      true,
+		"refresh":       "30s",
+		"time":          map[string]string{"from": "now-1h", "to": "now"},
+		"templating": map[string]interface{}{"list": []map[string]interface{}{{
+			"name": "datasource", "label": "Data source", "type": "datasource", "query": "prometheus",
+		}}},
+		"panels": panels,
+	}, "", "  ")
+}
+
+// AlertRules returns Prometheus alerting rules, as a YAML rule file: no
+// leader, a high miss ratio, replication lag, eviction storms and an open
+// write circuit breaker.
+func AlertRules(t Thresholds) ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteString("groups:\n  - name: distributed-cache\n    rules:\n")
+	for _, a := range alerts(t) {
+		if err := a.query.check(); err != nil {
+			return nil, fmt.Errorf("alert %s: %w", a.name, err)
+		}
+		fmt.Fprintf(&b, "      - alert: %s\n", a.name)
+		fmt.Fprintf(&b, "        expr: %s\n", strconv.Quote(a.query.expr))
+		fmt.Fprintf(&b, "        for: %s\n", a.wait)
+		fmt.Fprintf(&b, "        labels:\n          severity: %s\n", a.severity)
+		fmt.Fprintf(&b, "        annotations:\n          summary: %s\n", strconv.Quote(a.summary))
+	}
+	return b.Bytes(), nil
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
@@ -0,0 +1,85 @@
+package observability
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDefinitions(t *testing.T) {
+	d, ok := Lookup("cache_operations_total")
+	require.True(t, ok)
+	assert.Equal(t, Definition{
+		Name:   "cache_operations_total",
+		Help:   "The total number of cache operations",
+		Type:   TypeCounter,
+		Labels: []string{"type", "status"},
+	}, d)
+
+	defs := Definitions()
+	require.NotEmpty(t, defs)
+	for i := 1; i < len(defs); i++ {
+		assert.Less(t, defs[i-1].Name, defs[i].Name)
+	}
+}
+
+func TestDashboard(t *testing.T) {
+	data, err := Dashboard()
+	require.NoError(t, err)
+	var dashboard struct {
+		UID    string `json:"uid"`
+		Panels []struct {
+			Type    string         `json:"type"`
+			Title   string         `json:"title"`
+			GridPos map[string]int `json:"gridPos"`
+			Targets []struct {
+				Expr string `json:"expr"`
+			} `json:"targets"`
+		} `json:"panels"`
+	}
+	require.NoError(t, json.Unmarshal(data, &dashboard))
+	assert.Equal(t, DashboardUID, dashboard.UID)
+
+	// Every metric gets a panel of its own, and no two panels overlap.
+	titles := map[string]bool{}
+	cells := map[[2]int]bool{}
+	for _, p := range dashboard.Panels {
+		titles[p.Title] = true
+		cell := [2]int{p.GridPos["x"], p.GridPos["y"]}
+		assert.False(t, cells[cell], "panel %q overlaps another", p.Title)
+		cells[cell] = true
+	}
+	for _, d := range Definitions() {
+		assert.True(t, titles[d.Name], "no panel for %s", d.Name)
+	}
+
+	q, unit := perMetric(Definition{Name: "cache_duration_seconds", Type: TypeHistogram, Labels: []string{"type"}})
+	assert.Equal(t, "histogram_quantile(0.99, sum by (type, le) (rate(cache_duration_seconds_bucket[5m])))", q.expr)
+	assert.Equal(t, "s", unit)
+	q, unit = perMetric(Definition{Name: "cache_hits_total", Type: TypeCounter})
+	assert.Equal(t, "sum(rate(cache_hits_total[5m]))", q.expr)
+	assert.Equal(t, "ops", unit)
+}
+
+func TestAlertRules(t *testing.T) {
+	rules, err := AlertRules(Thresholds{MissRatio: 0.25, LagEntries: 500, EvictionsPerSecond: 10})
+	require.NoError(t, err)
+	for _, want := range []string{
+		"alert: CacheNoLeader",
+		"alert: CacheHighMissRatio",
+		"alert: CacheReplicationLag",
+		"alert: CacheEvictionStorm",
+		"rate(cache_misses_total[5m]))) > 0.25\"",
+		"cache_raft_applied_index > 500\"",
+		"rate(cache_evictions_total[5m])) > 10\"",
+	} {
+		assert.Contains(t, string(rules), want)
+	}
+	assert.True(t, strings.HasPrefix(string(rules), "groups:\n"))
+
+	err = query{expr: "nope", metrics: []string{"cache_nope"}}.check()
+	assert.ErrorContains(t, err, "undefined metric cache_nope")
+}
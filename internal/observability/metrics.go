@@ -2,32 +2,291 @@ package observability
 
 import (
 	"github.com/prometheus/client_golang/prometheus"
-	"github.com/prometheus/client_golang/prometheus/promauto"
 )
 
 var (
 	// CacheOperationsTotal counts get/set/delete operations
-	CacheOperationsTotal = promauto.NewCounterVec(prometheus.CounterOpts{
+	CacheOperationsTotal = newCounterVec(prometheus.CounterOpts{
 		Name: "cache_operations_total",
 		Help: "The total number of cache operations",
 	}, []string{"type", "status"})
 
 	// CacheHitsTotal counts cache hits
-	CacheHitsTotal = promauto.NewCounter(prometheus.CounterOpts{
+	CacheHitsTotal = newCounter(prometheus.CounterOpts{
 		Name: "cache_hits_total",
 		Help: "The total number of cache hits",
 	})
 
 	// CacheMissesTotal counts cache misses
-	CacheMissesTotal = promauto.NewCounter(prometheus.CounterOpts{
+	CacheMissesTotal = newCounter(prometheus.CounterOpts{
 		Name: "cache_misses_total",
 		Help: "The total number of cache misses",
 	})
 
+	// CachePrefixHitsTotal counts cache hits on keys with a configured metric prefix
+	CachePrefixHitsTotal = newCounterVec(prometheus.CounterOpts{
+		Name: "cache_prefix_hits_total",
+		Help: "The total number of cache hits, by configured key prefix",
+	}, []string{"prefix"})
+
+	// CachePrefixMissesTotal counts cache misses on keys with a configured metric prefix
+	CachePrefixMissesTotal = newCounterVec(prometheus.CounterOpts{
+		Name: "cache_prefix_misses_total",
+		Help: "The total number of cache misses, by configured key prefix",
+	}, []string{"prefix"})
+
+	// CacheNamespaceHitsTotal counts cache hits on keys in a namespace with a quota
+	CacheNamespaceHitsTotal = newCounterVec(prometheus.CounterOpts{
+		Name: "cache_namespace_hits_total",
+		Help: "The total number of cache hits, by quota namespace",
+	}, []string{"namespace"})
+
+	// CacheNamespaceMissesTotal counts cache misses on keys in a namespace with a quota
+	CacheNamespaceMissesTotal = newCounterVec(prometheus.CounterOpts{
+		Name: "cache_namespace_misses_total",
+		Help: "The total number of cache misses, by quota namespace",
+	}, []string{"namespace"})
+
+	// CacheQuotaUsage tracks the keys and bytes stored in each namespace with a quota
+	CacheQuotaUsage = newGaugeVec(prometheus.GaugeOpts{
+		Name: "cache_quota_usage",
+		Help: "The keys and bytes stored in each namespace with a quota, as last seen by the leader",
+	}, []string{"namespace", "resource"})
+
+	// CacheQuotaLimit exposes the configured quotas, for utilization alerts
+	CacheQuotaLimit = newGaugeVec(prometheus.GaugeOpts{
+		Name: "cache_quota_limit",
+		Help: "The configured key, byte and write rate quota of each namespace (0 = unlimited)",
+	}, []string{"namespace", "resource"})
+
+	// CacheQuotaRejectionsTotal counts writes refused for exceeding a quota
+	CacheQuotaRejectionsTotal = newCounterVec(prometheus.CounterOpts{
+		Name: "cache_quota_rejections_total",
+		Help: "The total number of writes refused because a namespace was over its quota",
+	}, []string{"namespace", "resource"})
+
+	// CacheBulkLoadedKeysTotal counts keys written by bulk loads, per batch
+	CacheBulkLoadedKeysTotal = newCounter(prometheus.CounterOpts{
+		Name: "cache_bulk_loaded_keys_total",
+		Help: "The total number of keys written by bulk loads, counted as each batch commits",
+	})
+
+	// CacheWriteBreakerState tracks the write circuit breaker
+	CacheWriteBreakerState = newGauge(prometheus.GaugeOpts{
+		Name: "cache_write_breaker_state",
+		Help: "The state of the write circuit breaker: 0 closed, 1 open, 2 half-open",
+	})
+
+	// CacheWriteBreakerOpensTotal counts how often the write circuit breaker opened
+	CacheWriteBreakerOpensTotal = newCounter(prometheus.CounterOpts{
+		Name: "cache_write_breaker_opens_total",
+		Help: "The total number of times sustained replication failures opened the write circuit breaker",
+	})
+
+	// CacheWriteBreakerRejectionsTotal counts writes failed fast by the open breaker
+	CacheWriteBreakerRejectionsTotal = newCounter(prometheus.CounterOpts{
+		Name: "cache_write_breaker_rejections_total",
+		Help: "The total number of writes failed fast while the write circuit breaker was open",
+	})
+
+	// CacheLeaderAckedFailuresTotal counts leader-acknowledged writes that failed to commit
+	CacheLeaderAckedFailuresTotal = newCounter(prometheus.CounterOpts{
+		Name: "cache_leader_acked_failures_total",
+		Help: "The total number of writes acknowledged with ack=leader that then failed to commit",
+	})
+
+	// CacheRetriesTotal counts operations retried during a leader change
+	CacheRetriesTotal = newCounterVec(prometheus.CounterOpts{
+		Name: "cache_retries_total",
+		Help: "The total number of writes and strong reads retried because leadership was changing, by operation",
+	}, []string{"op"})
+
+	// CacheWriteBatchSize tracks how many writes share each batched Raft entry
+	CacheWriteBatchSize = newHistogram(prometheus.HistogramOpts{
+		Name:    "cache_write_batch_size",
+		Help:    "The number of writes replicated together in each batched Raft entry",
+		Buckets: prometheus.ExponentialBuckets(1, 2, 11),
+	})
+
+	// CacheLoadsTotal counts read-through loader calls by result
+	CacheLoadsTotal = newCounterVec(prometheus.CounterOpts{
+		Name: "cache_loads_total",
+		Help: "The total number of read-through loader calls on cache misses",
+	}, []string{"result"})
+
+	// CacheEarlyRefreshesTotal counts reads that refreshed a key ahead of its expiry
+	CacheEarlyRefreshesTotal = newCounter(prometheus.CounterOpts{
+		Name: "cache_early_refreshes_total",
+		Help: "The total number of reads that started a probabilistic early refresh",
+	})
+
+	// EvictionsTotal counts keys evicted over capacity by replicated evictions
+	EvictionsTotal = newCounter(prometheus.CounterOpts{
+		Name: "cache_evictions_total",
+		Help: "The total number of keys evicted to keep the store within its capacity",
+	})
+
+	// StoreRemovalsTotal counts values that left this node's store, by why they left
+	StoreRemovalsTotal = newCounterVec(prometheus.CounterOpts{
+		Name: "cache_store_removals_total",
+		Help: "The total number of values that left the store, by reason: capacity, ttl, explicit or replaced",
+	}, []string{"reason"})
+
+	// ExpiredKeysTotal counts expired keys deleted by replicated purges
+	ExpiredKeysTotal = newCounter(prometheus.CounterOpts{
+		Name: "cache_expired_keys_total",
+		Help: "The total number of expired keys deleted by replicated purges",
+	})
+
+	// DuplicateCommandsTotal counts retried writes skipped by request ID deduplication
+	DuplicateCommandsTotal = newCounter(prometheus.CounterOpts{
+		Name: "cache_duplicate_commands_total",
+		Help: "The total number of write commands skipped because their request ID was already applied",
+	})
+
+	// TombstonesHeld tracks the tombstones kept for deleted keys
+	TombstonesHeld = newGauge(prometheus.GaugeOpts{
+		Name: "cache_tombstones",
+		Help: "The number of tombstones kept for deleted keys",
+	})
+
+	// SupersededWritesTotal counts writes refused because their key was deleted after they were made
+	SupersededWritesTotal = newCounter(prometheus.CounterOpts{
+		Name: "cache_superseded_writes_total",
+		Help: "The total number of writes with an origin time refused because their key was deleted after it",
+	})
+
+	// ClusterCommandVersion tracks the replicated command version of the cluster
+	ClusterCommandVersion = newGauge(prometheus.GaugeOpts{
+		Name: "cache_cluster_command_version",
+		Help: "The command schema version the cluster writes its Raft log at",
+	})
+
+	// RaftLeader tracks whether this node is the Raft leader
+	RaftLeader = newGauge(prometheus.GaugeOpts{
+		Name: "cache_raft_leader",
+		Help: "Whether this node is the Raft leader: 1 on the leader, 0 elsewhere",
+	})
+
+	// RaftAppliedIndex tracks the index of the last Raft entry applied to the store
+	RaftAppliedIndex = newGauge(prometheus.GaugeOpts{
+		Name: "cache_raft_applied_index",
+		Help: "The index of the last Raft log entry applied to this node's store",
+	})
+
+	// RaftLogBytes tracks the size of the entries held in the Raft log store
+	RaftLogBytes = newGauge(prometheus.GaugeOpts{
+		Name: "cache_raft_log_bytes",
+		Help: "The size in bytes of the entries held in the Raft log store",
+	})
+
+	// RaftCompactionsTotal counts compactions triggered by -raft_log_max_bytes
+	RaftCompactionsTotal = newCounter(prometheus.CounterOpts{
+		Name: "cache_raft_compactions_total",
+		Help: "The total number of Raft log compactions triggered by the log size limit",
+	})
+
+	// RaftReapedTotal counts members removed by -raft_reap_after
+	RaftReapedTotal = newCounter(prometheus.CounterOpts{
+		Name: "cache_raft_reaped_total",
+		Help: "The total number of members the leader removed from the cluster after losing contact with them",
+	})
+
+	// RaftApplyPhaseSeconds splits the latency of replicated writes into phases
+	RaftApplyPhaseSeconds = newHistogramVec(prometheus.HistogramOpts{
+		Name:    "cache_raft_apply_phase_seconds",
+		Help:    "The time replicated writes spend in each phase: encode, queue, replicate and apply",
+		Buckets: prometheus.ExponentialBuckets(0.0001, 2, 16),
+	}, []string{"phase"})
+
 	// CacheDurationSeconds measures latency
-	CacheDurationSeconds = promauto.NewHistogramVec(prometheus.HistogramOpts{
+	CacheDurationSeconds = newHistogramVec(prometheus.HistogramOpts{
 		Name:    "cache_duration_seconds",
 		Help:    "The latency of cache operations",
 		Buckets: prometheus.DefBuckets,
 	}, []string{"type"})
+
+	// CachePrefixDurationSeconds tracks operation latency for keys with a configured metric prefix
+	CachePrefixDurationSeconds = newHistogramVec(prometheus.HistogramOpts{
+		Name:    "cache_prefix_duration_seconds",
+		Help:    "The latency of cache operations, by configured key prefix",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"prefix", "type"})
+
+	// HTTPRequestsTotal counts HTTP API requests by the route that matched
+	HTTPRequestsTotal = newCounterVec(prometheus.CounterOpts{
+		Name: "cache_http_requests_total",
+		Help: "The total number of HTTP API requests, by route, method and status code",
+	}, []string{"route", "method", "code"})
+
+	// HTTPRequestDurationSeconds measures HTTP API latency by route
+	HTTPRequestDurationSeconds = newHistogramVec(prometheus.HistogramOpts{
+		Name:    "cache_http_request_duration_seconds",
+		Help:    "The latency of HTTP API requests, by route",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"route"})
+
+	// HTTPPanicsTotal counts HTTP API handlers that panicked
+	HTTPPanicsTotal = newCounter(prometheus.CounterOpts{
+		Name: "cache_http_panics_total",
+		Help: "The total number of HTTP API requests whose handler panicked",
+	})
+
+	// GRPCRequestsTotal counts gRPC calls by full method name and status code
+	GRPCRequestsTotal = newCounterVec(prometheus.CounterOpts{
+		Name: "cache_grpc_requests_total",
+		Help: "The total number of gRPC calls, by method and status code",
+	}, []string{"method", "code"})
+
+	// GRPCRequestDurationSeconds measures gRPC latency by method; for streams
+	// it is the lifetime of the stream
+	GRPCRequestDurationSeconds = newHistogramVec(prometheus.HistogramOpts{
+		Name:    "cache_grpc_request_duration_seconds",
+		Help:    "The latency of gRPC calls, by method",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method"})
+
+	// GRPCPanicsTotal counts gRPC handlers that panicked
+	GRPCPanicsTotal = newCounter(prometheus.CounterOpts{
+		Name: "cache_grpc_panics_total",
+		Help: "The total number of gRPC calls whose handler panicked",
+	})
+
+	// CompressionBytesTotal counts value bytes before ("raw") and after ("compressed") compression.
+	// The compression ratio is compressed / raw.
+	CompressionBytesTotal = newCounterVec(prometheus.CounterOpts{
+		Name: "cache_compression_bytes_total",
+		Help: "The total number of value bytes before and after compression",
+	}, []string{"stage"})
+
+	// CompressionRatio measures the compressed/raw size ratio per value
+	CompressionRatio = newHistogram(prometheus.HistogramOpts{
+		Name:    "cache_compression_ratio",
+		Help:    "The ratio of compressed to raw size for compressed values",
+		Buckets: prometheus.LinearBuckets(0.1, 0.1, 10),
+	})
+
+	// CompressionSkippedTotal counts values above the threshold that did not shrink
+	CompressionSkippedTotal = newCounter(prometheus.CounterOpts{
+		Name: "cache_compression_skipped_total",
+		Help: "The total number of values stored uncompressed because compression did not reduce their size",
+	})
+
+	// WriteBehindQueueDepth tracks mutations waiting for write-behind delivery
+	WriteBehindQueueDepth = newGauge(prometheus.GaugeOpts{
+		Name: "cache_writebehind_queue_depth",
+		Help: "The number of mutations waiting for write-behind delivery",
+	})
+
+	// WriteBehindDeliveredTotal counts mutations delivered to the write-behind sink
+	WriteBehindDeliveredTotal = newCounter(prometheus.CounterOpts{
+		Name: "cache_writebehind_delivered_total",
+		Help: "The total number of mutations delivered to the write-behind sink",
+	})
+
+	// WriteBehindDeadLettersTotal counts mutations that were never delivered, by reason
+	WriteBehindDeadLettersTotal = newCounterVec(prometheus.CounterOpts{
+		Name: "cache_writebehind_dead_letters_total",
+		Help: "The total number of mutations dropped by the write-behind queue",
+	}, []string{"reason"})
 )
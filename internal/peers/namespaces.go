@@ -0,0 +1,40 @@
+package peers
+
+import (
+	"context"
+
+	"distributed-cache-service/internal/core/ports"
+	pb "distributed-cache-service/proto"
+)
+
+// NamespaceStats asks every member for its namespace stats, answering for
+// this node with local.
+func (p *Peers) NamespaceStats(ctx context.Context, local ports.NamespaceReporter) ([]Reply[[]ports.NamespaceStats], error) {
+	return Query(ctx, p,
+		func(context.Context) ([]ports.NamespaceStats, error) {
+			return local.NamespaceStats(), nil
+		},
+		func(ctx context.Context, admin pb.AdminServiceClient) ([]ports.NamespaceStats, error) {
+			resp, err := admin.NamespaceStats(ctx, &pb.NamespaceStatsRequest{})
+			if err != nil {
+				return nil, err
+			}
+			stats := make([]ports.NamespaceStats, 0, len(resp.Namespaces))
+			for _, ns := range resp.Namespaces {
+				st := ports.NamespaceStats{
+					Namespace:    ns.Namespace,
+					Hits:         ns.Hits,
+					Misses:       ns.Misses,
+					MaxKeys:      ns.MaxKeys,
+					MaxBytes:     ns.MaxBytes,
+					MaxWriteRate: ns.MaxWriteRate,
+					Throttled:    ns.Throttled,
+				}
+				if ns.HasUsage {
+					st.Usage = &ports.Usage{Keys: ns.Keys, Bytes: ns.Bytes}
+				}
+				stats = append(stats, st)
+			}
+			return stats, nil
+		})
+}
@@ -0,0 +1,189 @@
+// Package peers queries every member of the cluster over gRPC, for the
+// endpoints that report on the whole cluster from whichever node serves them.
+package peers
+
+import (
+	"context"
+	"errors"
+	"net"
+	"sort"
+	"sync"
+	"time"
+
+	"distributed-cache-service/internal/core/ports"
+	pb "distributed-cache-service/proto"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+)
+
+// DefaultTimeout bounds how long a query waits for each member.
+const DefaultTimeout = 2 * time.Second
+
+// Member is a cluster member as a query reaches it.
+type Member struct {
+	ID string `json:"id"`
+	// Addr is the address of the member's gRPC API, empty for this node.
+	Addr   string `json:"addr,omitempty"`
+	Leader bool   `json:"leader"`
+	Self   bool   `json:"self"`
+}
+
+// Reply is one member's answer to a query, or why it has none.
+type Reply[T any] struct {
+	Member Member
+	Value  T
+	Err    error
+}
+
+// Peers reaches the members of the cluster this node belongs to, at the gRPC
+// address each advertised (see ports.Member). Members that have not, such as
+// those of a cluster below service.CommandVersionMembers, are reached at the
+// host of their Raft address and the port this node serves gRPC on.
+type Peers struct {
+	cluster  ports.ClusterAdmin
+	self     string
+	port     string
+	token    string
+	timeout  time.Duration
+	dialOpts []grpc.DialOption
+
+	mu    sync.Mutex
+	conns map[string]*grpc.ClientConn // by address, closed by Close
+}
+
+// Option configures optional Peers behaviour.
+type Option func(*Peers)
+
+// WithToken sends the admin token with every query, which members need if
+// they were started with one.
+func WithToken(token string) Option {
+	return func(p *Peers) {
+		p.token = token
+	}
+}
+
+// WithTimeout sets how long a query waits for each member; DefaultTimeout by
+// default. Members that have not answered by then are reported with an error.
+func WithTimeout(d time.Duration) Option {
+	return func(p *Peers) {
+		p.timeout = d
+	}
+}
+
+// WithDialOptions adds options used when connecting to members.
+func WithDialOptions(opts ...grpc.DialOption) Option {
+	return func(p *Peers) {
+		p.dialOpts = append(p.dialOpts, opts...)
+	}
+}
+
+// New returns the Peers of the node with ID self, a member of cluster that
+// serves gRPC at grpcAddr.
+func New(cluster ports.ClusterAdmin, self, grpcAddr string, opts ...Option) *Peers {
+	p := &Peers{
+		cluster:  cluster,
+		self:     self,
+		timeout:  DefaultTimeout,
+		dialOpts: []grpc.DialOption{grpc.WithTransportCredentials(insecure.NewCredentials())},
+		conns:    make(map[string]*grpc.ClientConn),
+	}
+	if _, port, err := net.SplitHostPort(grpcAddr); err == nil {
+		p.port = port
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
+// Close closes the connections to members.
+func (p *Peers) Close() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var err error
+	for addr, conn := range p.conns {
+		err = errors.Join(err, conn.Close())
+		delete(p.conns, addr)
+	}
+	return err
+}
+
+// Query asks every member at once and returns their replies, this node's
+// first and then by ID. This node answers through local, the others through
+// remote with a client of their admin service.
+func Query[T any](ctx context.Context, p *Peers, local func(context.Context) (T, error), remote func(context.Context, pb.AdminServiceClient) (T, error)) ([]Reply[T], error) {
+	members, err := p.cluster.Members()
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(ctx, p.timeout)
+	defer cancel()
+	if p.token != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+p.token)
+	}
+
+	replies := make([]Reply[T], len(members))
+	var wg sync.WaitGroup
+	for i, m := range members {
+		r := &replies[i]
+		r.Member = Member{ID: m.ID, Leader: m.Leader, Self: m.ID == p.self}
+		if r.Member.Self {
+			r.Value, r.Err = local(ctx)
+			continue
+		}
+		r.Member.Addr, r.Err = p.grpcAddr(m)
+		if r.Err != nil {
+			continue
+		}
+		conn, err := p.conn(r.Member.Addr)
+		if err != nil {
+			r.Err = err
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r.Value, r.Err = remote(ctx, pb.NewAdminServiceClient(conn))
+		}()
+	}
+	wg.Wait()
+	sort.SliceStable(replies, func(i, j int) bool {
+		if replies[i].Member.Self != replies[j].Member.Self {
+			return replies[i].Member.Self
+		}
+		return replies[i].Member.ID < replies[j].Member.ID
+	})
+	return replies, nil
+}
+
+// grpcAddr returns the gRPC address of m.
+func (p *Peers) grpcAddr(m ports.Member) (string, error) {
+	if m.GRPCAddr != "" {
+		return m.GRPCAddr, nil
+	}
+	host, _, err := net.SplitHostPort(m.Address)
+	if err != nil {
+		return "", err
+	}
+	if p.port == "" {
+		return "", errors.New("this node's gRPC port is unknown")
+	}
+	return net.JoinHostPort(host, p.port), nil
+}
+
+// conn returns the connection to addr, making it on first use.
+func (p *Peers) conn(addr string) (*grpc.ClientConn, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if conn, ok := p.conns[addr]; ok {
+		return conn, nil
+	}
+	conn, err := grpc.NewClient(addr, p.dialOpts...)
+	if err != nil {
+		return nil, err
+	}
+	p.conns[addr] = conn
+	return conn, nil
+}
@@ -0,0 +1,134 @@
+package peers
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"distributed-cache-service/internal/core/ports"
+	pb "distributed-cache-service/proto"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+type fakeCluster struct {
+	ports.ClusterAdmin
+	members []ports.Member
+}
+
+func (c *fakeCluster) Members() ([]ports.Member, error) { return c.members, nil }
+
+// fakeAdmin reports one namespace with hits hits, if called with the token.
+type fakeAdmin struct {
+	pb.UnimplementedAdminServiceServer
+	hits uint64
+}
+
+func (a *fakeAdmin) NamespaceStats(ctx context.Context, req *pb.NamespaceStatsRequest) (*pb.NamespaceStatsResponse, error) {
+	md, _ := metadata.FromIncomingContext(ctx)
+	if v := md.Get("authorization"); len(v) == 0 || v[0] != "Bearer secret" {
+		return nil, status.Error(codes.Unauthenticated, "no token")
+	}
+	return &pb.NamespaceStatsResponse{Namespaces: []*pb.NamespaceStats{
+		{Namespace: "user:", Hits: a.hits, HasUsage: true, Keys: 2, Throttled: map[string]uint64{"keys": 1}},
+	}}, nil
+}
+
+func (a *fakeAdmin) Stats(ctx context.Context, req *pb.StatsRequest) (*pb.StatsResponse, error) {
+	return &pb.StatsResponse{State: "Follower", KeyCount: 4, Hits: a.hits, AppliedIndex: 10 * a.hits, ReplicationLagError: "no known leader"}, nil
+}
+
+type localNode ports.NodeStats
+
+func (n localNode) NodeStats() ports.NodeStats { return ports.NodeStats(n) }
+
+type localStats []ports.NamespaceStats
+
+func (s localStats) NamespaceStats() []ports.NamespaceStats { return s }
+
+// startPeers serves a fakeAdmin for node2, on the gRPC port of node1, and
+// node3, at the gRPC address it advertised, and returns node1's Peers; node4
+// is down.
+func startPeers(t *testing.T) *Peers {
+	t.Helper()
+	listeners := map[string]*bufconn.Listener{}
+	for addr, hits := range map[string]uint64{"127.0.0.2:9090": 2, "10.0.0.3:6000": 3} {
+		lis := bufconn.Listen(1 << 20)
+		srv := grpc.NewServer()
+		pb.RegisterAdminServiceServer(srv, &fakeAdmin{hits: hits})
+		go srv.Serve(lis)
+		t.Cleanup(srv.Stop)
+		listeners[addr] = lis
+	}
+	dialer := grpc.WithContextDialer(func(ctx context.Context, addr string) (net.Conn, error) {
+		lis, ok := listeners[addr]
+		if !ok {
+			return nil, errors.New("connection refused")
+		}
+		return lis.DialContext(ctx)
+	})
+	cluster := &fakeCluster{members: []ports.Member{
+		{ID: "node3", Address: "127.0.0.3:7000", GRPCAddr: "10.0.0.3:6000"},
+		{ID: "node2", Address: "127.0.0.2:7000", Leader: true},
+		{ID: "node4", Address: "127.0.0.4:7000"},
+		{ID: "node1", Address: "127.0.0.1:7000"},
+	}}
+	p := New(cluster, "node1", "127.0.0.1:9090", WithToken("secret"), WithTimeout(time.Second), WithDialOptions(dialer))
+	t.Cleanup(func() { p.Close() })
+	return p
+}
+
+func TestPeers_NamespaceStats(t *testing.T) {
+	p := startPeers(t)
+	replies, err := p.NamespaceStats(context.Background(), localStats{{Namespace: "user:", Hits: 1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ids []string
+	for _, r := range replies {
+		ids = append(ids, r.Member.ID)
+	}
+	if len(ids) != 4 || ids[0] != "node1" || ids[1] != "node2" || ids[3] != "node4" {
+		t.Fatalf("expected this node first, then the others by ID, got %v", ids)
+	}
+	if r := replies[0]; !r.Member.Self || r.Member.Addr != "" || r.Err != nil || r.Value[0].Hits != 1 {
+		t.Errorf("expected this node to answer locally, got %+v", r)
+	}
+	node2 := replies[1]
+	if node2.Err != nil || !node2.Member.Leader || node2.Member.Addr != "127.0.0.2:9090" {
+		t.Fatalf("unexpected reply %+v", node2)
+	}
+	if st := node2.Value[0]; st.Hits != 2 || st.Usage == nil || st.Usage.Keys != 2 || st.Throttled["keys"] != 1 {
+		t.Errorf("unexpected stats %+v", st)
+	}
+	if replies[2].Err != nil || replies[2].Member.Addr != "10.0.0.3:6000" || replies[2].Value[0].Hits != 3 {
+		t.Errorf("unexpected reply %+v", replies[2])
+	}
+	if replies[3].Err == nil {
+		t.Error("expected an error for the member that is down")
+	}
+}
+
+func TestPeers_Stats(t *testing.T) {
+	p := startPeers(t)
+	replies, err := p.Stats(context.Background(), localNode{State: "Leader", Keys: 4, AppliedIndex: 30})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(replies) != 4 || replies[0].Value.State != "Leader" || replies[0].Value.AppliedIndex != 30 {
+		t.Fatalf("expected this node's stats first, got %+v", replies)
+	}
+	node2 := replies[1].Value
+	if replies[1].Err != nil || node2.State != "Follower" || node2.Keys != 4 || node2.Hits != 2 || node2.AppliedIndex != 20 || node2.ReplicationLagError == "" {
+		t.Errorf("unexpected reply %+v (%v)", node2, replies[1].Err)
+	}
+	if replies[3].Err == nil {
+		t.Error("expected an error for the member that is down")
+	}
+}
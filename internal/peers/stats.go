@@ -0,0 +1,34 @@
+package peers
+
+import (
+	"context"
+
+	"distributed-cache-service/internal/core/ports"
+	pb "distributed-cache-service/proto"
+)
+
+// Stats asks every member for its node stats, answering for this node with
+// local.
+func (p *Peers) Stats(ctx context.Context, local ports.NodeReporter) ([]Reply[ports.NodeStats], error) {
+	return Query(ctx, p,
+		func(context.Context) (ports.NodeStats, error) {
+			return local.NodeStats(), nil
+		},
+		func(ctx context.Context, admin pb.AdminServiceClient) (ports.NodeStats, error) {
+			resp, err := admin.Stats(ctx, &pb.StatsRequest{})
+			if err != nil {
+				return ports.NodeStats{}, err
+			}
+			return ports.NodeStats{
+				State:               resp.State,
+				LeaderAddr:          resp.Leader,
+				Keys:                resp.KeyCount,
+				Capacity:            resp.Capacity,
+				Hits:                resp.Hits,
+				Misses:              resp.Misses,
+				AppliedIndex:        resp.AppliedIndex,
+				ReplicationLag:      resp.ReplicationLag,
+				ReplicationLagError: resp.ReplicationLagError,
+			}, nil
+		})
+}
@@ -0,0 +1,108 @@
+// Package position stamps responses with the node's position in the
+// replicated log: the index of the last entry applied to its state and its
+// current term. Clients keep the highest index they have seen as a session
+// token to detect stale reads, or to wait for a node to catch up before
+// reading from it.
+package position
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"distributed-cache-service/internal/core/ports"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// HTTP headers and gRPC trailer keys carrying the position.
+const (
+	HeaderIndex = "X-Raft-Index"
+	HeaderTerm  = "X-Raft-Term"
+
+	TrailerIndex = "x-raft-index"
+	TrailerTerm  = "x-raft-term"
+)
+
+// Stamper adds the position of a node to its responses. It is read when the
+// response is written, after the request's read or write took effect, so a
+// write's index is at least that of its own log entry.
+type Stamper struct {
+	source ports.PositionReporter
+}
+
+// New returns a Stamper reporting the position of source.
+func New(source ports.PositionReporter) *Stamper {
+	return &Stamper{source: source}
+}
+
+// Middleware sets HeaderIndex and HeaderTerm on every response of next.
+func (s *Stamper) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(&stampingWriter{ResponseWriter: w, source: s.source}, r)
+	})
+}
+
+// UnaryServerInterceptor sets TrailerIndex and TrailerTerm on gRPC calls whose
+// full method name starts with one of the given prefixes (e.g.
+// "/cache.CacheService/"), or on every call if there are none.
+func (s *Stamper) UnaryServerInterceptor(prefixes ...string) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		resp, err := handler(ctx, req)
+		if matches(info.FullMethod, prefixes) {
+			index, term := s.source.AppliedPosition()
+			grpc.SetTrailer(ctx, metadata.Pairs(
+				TrailerIndex, strconv.FormatUint(index, 10),
+				TrailerTerm, strconv.FormatUint(term, 10),
+			))
+		}
+		return resp, err
+	}
+}
+
+func matches(method string, prefixes []string) bool {
+	if len(prefixes) == 0 {
+		return true
+	}
+	for _, p := range prefixes {
+		if strings.HasPrefix(method, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// stampingWriter sets the position headers just before the status line goes
+// out, when the handler has done its work.
+type stampingWriter struct {
+	http.ResponseWriter
+	source  ports.PositionReporter
+	stamped bool
+}
+
+func (w *stampingWriter) WriteHeader(code int) {
+	w.stamp()
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *stampingWriter) Write(b []byte) (int, error) {
+	w.stamp()
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *stampingWriter) stamp() {
+	if w.stamped {
+		return
+	}
+	w.stamped = true
+	index, term := w.source.AppliedPosition()
+	w.Header().Set(HeaderIndex, strconv.FormatUint(index, 10))
+	w.Header().Set(HeaderTerm, strconv.FormatUint(term, 10))
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (w *stampingWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,76 @@
+package position
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+// fakeLog advances its index on every write, as applying an entry would.
+type fakeLog struct {
+	index, term uint64
+}
+
+func (l *fakeLog) AppliedPosition() (uint64, uint64) {
+	return l.index, l.term
+}
+
+func TestStamper_Middleware(t *testing.T) {
+	log := &fakeLog{index: 41, term: 3}
+	handler := New(log).Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			http.Error(w, "key not found", http.StatusNotFound)
+			return
+		}
+		log.index++ // the write applied before the response is written
+		w.Write([]byte("ok"))
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/set", nil))
+	assert.Equal(t, "42", rec.Header().Get(HeaderIndex))
+	assert.Equal(t, "3", rec.Header().Get(HeaderTerm))
+
+	// Errors carry the position too.
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/missing", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+	assert.Equal(t, "42", rec.Header().Get(HeaderIndex))
+}
+
+// trailerStream records the trailers a handler sets.
+type trailerStream struct {
+	grpc.ServerTransportStream
+	trailer metadata.MD
+}
+
+func (s *trailerStream) SetTrailer(md metadata.MD) error {
+	s.trailer = metadata.Join(s.trailer, md)
+	return nil
+}
+
+func TestStamper_UnaryServerInterceptor(t *testing.T) {
+	interceptor := New(&fakeLog{index: 7, term: 2}).UnaryServerInterceptor("/cache.CacheService/")
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) { return "resp", nil }
+
+	stream := &trailerStream{}
+	ctx := grpc.NewContextWithServerTransportStream(context.Background(), stream)
+	resp, err := interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/cache.CacheService/Get"}, handler)
+	require.NoError(t, err)
+	assert.Equal(t, "resp", resp)
+	assert.Equal(t, []string{"7"}, stream.trailer.Get(TrailerIndex))
+	assert.Equal(t, []string{"2"}, stream.trailer.Get(TrailerTerm))
+
+	// Other services are left alone.
+	stream = &trailerStream{}
+	ctx = grpc.NewContextWithServerTransportStream(context.Background(), stream)
+	_, err = interceptor(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/cache.AdminService/Snapshot"}, handler)
+	require.NoError(t, err)
+	assert.Empty(t, stream.trailer)
+}
@@ -0,0 +1,109 @@
+// Package ratelimit provides a token bucket limiter whose rate can be changed at runtime.
+package ratelimit
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+// Limiter is a thread-safe token bucket.
+// A rate <= 0 disables limiting and every request is allowed.
+type Limiter struct {
+	mu     sync.Mutex
+	rate   float64 // tokens added per second
+	burst  int     // bucket size
+	tokens float64
+	last   time.Time
+	now    func() time.Time
+}
+
+// New creates a limiter allowing rate requests per second with the given burst size.
+func New(rate float64, burst int) *Limiter {
+	l := &Limiter{now: time.Now}
+	l.SetLimit(rate, burst)
+	return l
+}
+
+// SetLimit updates the rate and burst size. The bucket is refilled to the new burst.
+func (l *Limiter) SetLimit(rate float64, burst int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if burst <= 0 {
+		burst = int(rate)
+		if burst < 1 {
+			burst = 1
+		}
+	}
+	l.rate = rate
+	l.burst = burst
+	l.tokens = float64(burst)
+	l.last = l.now()
+}
+
+// Limit returns the current rate and burst size.
+func (l *Limiter) Limit() (float64, int) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.rate, l.burst
+}
+
+// Allow reports whether a request may proceed, consuming one token if so.
+func (l *Limiter) Allow() bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.rate <= 0 {
+		return true
+	}
+
+	now := l.now()
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > float64(l.burst) {
+		l.tokens = float64(l.burst)
+	}
+	l.last = now
+
+	if l.tokens < 1 {
+		return false
+	}
+	l.tokens--
+	return true
+}
+
+// Middleware rejects HTTP requests with 429 Too Many Requests when the limit is exceeded.
+func (l *Limiter) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !l.Allow() {
+			http.Error(w, "rate limit exceeded", http.StatusTooManyRequests)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// UnaryServerInterceptor rejects gRPC calls with codes.ResourceExhausted when the limit is exceeded.
+func (l *Limiter) UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if !l.Allow() {
+			return nil, status.Error(codes.ResourceExhausted, "rate limit exceeded")
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor rejects streaming gRPC calls with
+// codes.ResourceExhausted when the limit is exceeded. A stream counts as one
+// request, when it opens.
+func (l *Limiter) StreamServerInterceptor() grpc.StreamServerInterceptor {
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if !l.Allow() {
+			return status.Error(codes.ResourceExhausted, "rate limit exceeded")
+		}
+		return handler(srv, ss)
+	}
+}
@@ -0,0 +1,31 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLimiter_Allow(t *testing.T) {
+	now := time.Unix(0, 0)
+	l := New(0, 0)
+	l.now = func() time.Time { return now }
+	l.SetLimit(2, 2)
+
+	assert.True(t, l.Allow())
+	assert.True(t, l.Allow())
+	assert.False(t, l.Allow(), "bucket should be empty")
+
+	// Half a second refills one token at 2 req/s
+	now = now.Add(500 * time.Millisecond)
+	assert.True(t, l.Allow())
+	assert.False(t, l.Allow())
+}
+
+func TestLimiter_Unlimited(t *testing.T) {
+	l := New(0, 0)
+	for i := 0; i < 1000; i++ {
+		assert.True(t, l.Allow())
+	}
+}
@@ -0,0 +1,210 @@
+// Package resp speaks RESP2, the Redis serialization protocol: enough of it
+// to read from a Redis server and to write dumps Redis can load with
+// redis-cli --pipe.
+package resp
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+)
+
+// maxBulkLen is the largest bulk string accepted, Redis's own limit.
+const maxBulkLen = 512 << 20
+
+// Error is an error reply.
+type Error string
+
+func (e Error) Error() string {
+	return string(e)
+}
+
+// Writer writes commands as arrays of bulk strings.
+type Writer struct {
+	w *bufio.Writer
+}
+
+// NewWriter returns a Writer buffering its output to w.
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: bufio.NewWriter(w)}
+}
+
+// Command writes a command. It is buffered until Flush.
+func (w *Writer) Command(args ...string) error {
+	w.w.WriteByte('*')
+	w.w.WriteString(strconv.Itoa(len(args)))
+	w.w.WriteString("\r\n")
+	for _, arg := range args {
+		w.w.WriteByte('$')
+		w.w.WriteString(strconv.Itoa(len(arg)))
+		w.w.WriteString("\r\n")
+		w.w.WriteString(arg)
+		if _, err := w.w.WriteString("\r\n"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Flush writes any buffered commands.
+func (w *Writer) Flush() error {
+	return w.w.Flush()
+}
+
+// Reader reads values. Simple and bulk strings are read as string, integers
+// as int64, arrays as []any, null bulk strings and arrays as nil, and error
+// replies as Error.
+type Reader struct {
+	r *bufio.Reader
+}
+
+// NewReader returns a Reader reading from r.
+func NewReader(r io.Reader) *Reader {
+	return &Reader{r: bufio.NewReader(r)}
+}
+
+// Read reads the next value. It returns io.EOF only between values.
+func (r *Reader) Read() (any, error) {
+	line, err := r.line()
+	if err != nil {
+		return nil, err
+	}
+	if len(line) == 0 {
+		return nil, errors.New("resp: empty line")
+	}
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return Error(line[1:]), nil
+	case ':':
+		n, err := strconv.ParseInt(line[1:], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("resp: bad integer %q", line)
+		}
+		return n, nil
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n < -1 || n > maxBulkLen {
+			return nil, fmt.Errorf("resp: bad bulk length %q", line)
+		}
+		if n == -1 {
+			return nil, nil
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(r.r, buf); err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		if buf[n] != '\r' || buf[n+1] != '\n' {
+			return nil, errors.New("resp: bulk string not terminated by CRLF")
+		}
+		return string(buf[:n]), nil
+	case '*':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil || n < -1 {
+			return nil, fmt.Errorf("resp: bad array length %q", line)
+		}
+		if n == -1 {
+			return nil, nil
+		}
+		values := make([]any, 0, min(n, 1024))
+		for i := 0; i < n; i++ {
+			v, err := r.Read()
+			if err != nil {
+				return nil, unexpectedEOF(err)
+			}
+			values = append(values, v)
+		}
+		return values, nil
+	default:
+		return nil, fmt.Errorf("resp: unknown type %q", line[0])
+	}
+}
+
+// line reads a line without its CRLF.
+func (r *Reader) line() (string, error) {
+	line, err := r.r.ReadString('\n')
+	if err != nil {
+		if errors.Is(err, io.EOF) && line != "" {
+			return "", io.ErrUnexpectedEOF
+		}
+		return "", err
+	}
+	if len(line) < 2 || line[len(line)-2] != '\r' {
+		return "", errors.New("resp: line not terminated by CRLF")
+	}
+	return line[:len(line)-2], nil
+}
+
+func unexpectedEOF(err error) error {
+	if errors.Is(err, io.EOF) {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// Conn is a connection to a Redis server. It is not safe for concurrent use.
+type Conn struct {
+	conn net.Conn
+	r    *Reader
+	w    *Writer
+}
+
+// Dial connects to the Redis server at addr.
+func Dial(ctx context.Context, addr string) (*Conn, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	return &Conn{conn: conn, r: NewReader(conn), w: NewWriter(conn)}, nil
+}
+
+// Do sends a command and returns its reply. Error replies are returned as
+// an Error.
+func (c *Conn) Do(ctx context.Context, args ...string) (any, error) {
+	replies, err := c.Pipeline(ctx, [][]string{args})
+	if err != nil {
+		return nil, err
+	}
+	if e, ok := replies[0].(Error); ok {
+		return nil, e
+	}
+	return replies[0], nil
+}
+
+// Pipeline sends commands in one round trip and returns their replies, in
+// order. Error replies are returned as values, not as the error. ctx's
+// deadline bounds the round trip.
+func (c *Conn) Pipeline(ctx context.Context, cmds [][]string) ([]any, error) {
+	deadline, _ := ctx.Deadline()
+	if err := c.conn.SetDeadline(deadline); err != nil {
+		return nil, err
+	}
+	for _, args := range cmds {
+		if err := c.w.Command(args...); err != nil {
+			return nil, err
+		}
+	}
+	if err := c.w.Flush(); err != nil {
+		return nil, err
+	}
+	replies := make([]any, len(cmds))
+	for i := range replies {
+		v, err := c.r.Read()
+		if err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		replies[i] = v
+	}
+	return replies, nil
+}
+
+// Close closes the connection.
+func (c *Conn) Close() error {
+	return c.conn.Close()
+}
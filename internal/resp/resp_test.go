@@ -0,0 +1,115 @@
+package resp
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestWriterReader(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	if err := w.Command("SET", "k", "line\r\nbreak", ""); err != nil {
+		t.Fatal(err)
+	}
+	if err := w.Flush(); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := buf.String(), "*4\r\n$3\r\nSET\r\n$1\r\nk\r\n$11\r\nline\r\nbreak\r\n$0\r\n\r\n"; got != want {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+
+	buf.WriteString("+OK\r\n-ERR boom\r\n:42\r\n$-1\r\n*2\r\n*0\r\n$1\r\nx\r\n")
+	r := NewReader(&buf)
+	want := []any{
+		[]any{"SET", "k", "line\r\nbreak", ""},
+		"OK",
+		Error("ERR boom"),
+		int64(42),
+		nil,
+		[]any{[]any{}, "x"},
+	}
+	for i, w := range want {
+		got, err := r.Read()
+		if err != nil {
+			t.Fatalf("value %d: %v", i, err)
+		}
+		if !reflect.DeepEqual(got, w) {
+			t.Errorf("value %d: got %#v, want %#v", i, got, w)
+		}
+	}
+	if _, err := r.Read(); !errors.Is(err, io.EOF) {
+		t.Errorf("expected EOF, got %v", err)
+	}
+}
+
+func TestReaderErrors(t *testing.T) {
+	for _, input := range []string{
+		"$5\r\nab",       // truncated bulk string
+		"*2\r\n:1\r\n",   // truncated array
+		"$2\r\nabcd\r\n", // bulk string longer than declared
+		"+OK\n",          // missing CR
+		"?x\r\n",         // unknown type
+		":one\r\n",       // bad integer
+		"$-2\r\n",        // bad length
+	} {
+		if _, err := NewReader(strings.NewReader(input)).Read(); err == nil || errors.Is(err, io.EOF) {
+			t.Errorf("%q: expected an error, got %v", input, err)
+		}
+	}
+}
+
+func TestConn(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r, w := NewReader(conn), NewWriter(conn)
+		for {
+			v, err := r.Read()
+			if err != nil {
+				return
+			}
+			args := v.([]any)
+			if args[0] == "PING" {
+				w.w.WriteString("+PONG\r\n")
+			} else {
+				w.w.WriteString("-ERR unknown command\r\n")
+			}
+			w.Flush()
+		}
+	}()
+
+	ctx := context.Background()
+	c, err := Dial(ctx, ln.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	if v, err := c.Do(ctx, "PING"); err != nil || v != "PONG" {
+		t.Fatalf("expected PONG, got %v (%v)", v, err)
+	}
+	var e Error
+	if _, err := c.Do(ctx, "NOPE"); !errors.As(err, &e) || e != "ERR unknown command" {
+		t.Errorf("expected an error reply, got %v", err)
+	}
+	replies, err := c.Pipeline(ctx, [][]string{{"PING"}, {"NOPE"}, {"PING"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(replies, []any{"PONG", Error("ERR unknown command"), "PONG"}) {
+		t.Errorf("unexpected replies %#v", replies)
+	}
+}
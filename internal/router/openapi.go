@@ -0,0 +1,324 @@
+package router
+
+import (
+	"encoding"
+	"encoding/json"
+	"log"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// OpenAPI returns the OpenAPI 3 document describing the declared routes.
+func (rt *Router) OpenAPI() map[string]interface{} {
+	paths := map[string]map[string]interface{}{}
+	var tags []map[string]string
+	seenTags := map[string]bool{}
+	admin := false
+	for _, route := range rt.routes {
+		method := strings.ToLower(route.Method)
+		if method == "" {
+			method = "get"
+		}
+		if paths[route.Path] == nil {
+			paths[route.Path] = map[string]interface{}{}
+		}
+		paths[route.Path][method] = rt.operation(method, route)
+		if route.Tag != "" && !seenTags[route.Tag] {
+			seenTags[route.Tag] = true
+			tags = append(tags, map[string]string{"name": route.Tag})
+		}
+		admin = admin || route.Admin
+	}
+
+	doc := map[string]interface{}{
+		"openapi": "3.0.3",
+		"info":    map[string]string{"title": rt.title, "version": rt.version},
+		"paths":   paths,
+	}
+	if len(tags) > 0 {
+		doc["tags"] = tags
+	}
+	if admin {
+		doc["components"] = map[string]interface{}{
+			"securitySchemes": map[string]interface{}{
+				"adminToken": map[string]string{"type": "http", "scheme": "bearer", "description": "The server's -admin_token"},
+			},
+		}
+	}
+	return doc
+}
+
+// operation describes route, served with method, as an OpenAPI operation.
+func (rt *Router) operation(method string, route Route) map[string]interface{} {
+	op := map[string]interface{}{
+		"operationId": method + strings.ReplaceAll(strings.TrimRight(route.Path, "/"), "/", "_"),
+	}
+	if route.Summary != "" {
+		op["summary"] = route.Summary
+	}
+	if route.Description != "" {
+		op["description"] = route.Description
+	}
+	if route.Tag != "" {
+		op["tags"] = []string{route.Tag}
+	}
+	if route.Deprecated {
+		op["deprecated"] = true
+	}
+
+	var params []map[string]interface{}
+	for _, p := range route.Params {
+		in := p.In
+		if in == "" {
+			in = "query"
+		}
+		schema := map[string]interface{}{"type": typeOrString(p.Type)}
+		if len(p.Enum) > 0 {
+			schema["enum"] = p.Enum
+		}
+		if p.Repeated {
+			schema = map[string]interface{}{"type": "array", "items": schema}
+		}
+		param := map[string]interface{}{"name": p.Name, "in": in, "schema": schema}
+		if p.Description != "" {
+			param["description"] = p.Description
+		}
+		if p.Required {
+			param["required"] = true
+		}
+		params = append(params, param)
+	}
+	if len(params) > 0 {
+		op["parameters"] = params
+	}
+
+	if b := route.Body; b != nil {
+		body := map[string]interface{}{
+			"content": map[string]interface{}{
+				typeOrText(b.ContentType): map[string]interface{}{"schema": map[string]string{"type": "string"}},
+			},
+		}
+		if b.Description != "" {
+			body["description"] = b.Description
+		}
+		if b.Required {
+			body["required"] = true
+		}
+		op["requestBody"] = body
+	}
+
+	responses := map[string]interface{}{}
+	for _, r := range route.Responses {
+		responses[strconv.Itoa(r.Status)] = response(r)
+	}
+	if route.Limited && rt.limit != nil {
+		addResponse(responses, http.StatusTooManyRequests, "Request rate limit exceeded")
+	}
+	if route.Admin {
+		addResponse(responses, http.StatusUnauthorized, "Missing or invalid admin token")
+		op["security"] = []map[string][]string{{"adminToken": {}}}
+	}
+	responses["default"] = response(Response{Description: "Error, with its message as the body"})
+	op["responses"] = responses
+	return op
+}
+
+func addResponse(responses map[string]interface{}, status int, description string) {
+	if _, ok := responses[strconv.Itoa(status)]; !ok {
+		responses[strconv.Itoa(status)] = response(Response{Status: status, Description: description})
+	}
+}
+
+func response(r Response) map[string]interface{} {
+	desc := r.Description
+	if desc == "" {
+		desc = http.StatusText(r.Status)
+	}
+	resp := map[string]interface{}{"description": desc}
+	switch {
+	case r.Schema != nil:
+		resp["content"] = map[string]interface{}{
+			"application/json": map[string]interface{}{"schema": SchemaOf(r.Schema)},
+		}
+	case r.Status != http.StatusNoContent && r.Status != http.StatusNotModified:
+		resp["content"] = map[string]interface{}{
+			typeOrText(r.ContentType): map[string]interface{}{"schema": map[string]string{"type": "string"}},
+		}
+	}
+	if len(r.Headers) > 0 {
+		headers := map[string]interface{}{}
+		for name, desc := range r.Headers {
+			headers[name] = map[string]interface{}{"description": desc, "schema": map[string]string{"type": "string"}}
+		}
+		resp["headers"] = headers
+	}
+	return resp
+}
+
+func typeOrString(t string) string {
+	if t == "" {
+		return "string"
+	}
+	return t
+}
+
+func typeOrText(contentType string) string {
+	if contentType == "" {
+		return "text/plain"
+	}
+	return contentType
+}
+
+var (
+	timeType          = reflect.TypeOf(time.Time{})
+	durationType      = reflect.TypeOf(time.Duration(0))
+	jsonMarshalerType = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	textMarshalerType = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+// SchemaOf returns the JSON schema of v's type as encoding/json would encode
+// it: structs become objects whose properties are named by their json tags,
+// and fields without omitempty are required. Types that marshal themselves
+// are described as strings if they implement encoding.TextMarshaler, and
+// left unconstrained otherwise.
+func SchemaOf(v interface{}) map[string]interface{} {
+	return schemaOf(reflect.TypeOf(v))
+}
+
+func schemaOf(t reflect.Type) map[string]interface{} {
+	if t == nil {
+		return map[string]interface{}{}
+	}
+	switch t {
+	case timeType:
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	case durationType:
+		return map[string]interface{}{"type": "integer", "format": "int64", "description": "nanoseconds"}
+	}
+	if implements(t, textMarshalerType) {
+		return map[string]interface{}{"type": "string"}
+	}
+	if implements(t, jsonMarshalerType) {
+		return map[string]interface{}{}
+	}
+	switch t.Kind() {
+	case reflect.Pointer:
+		s := schemaOf(t.Elem())
+		s["nullable"] = true
+		return s
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]interface{}{"type": "string", "format": "byte"}
+		}
+		return map[string]interface{}{"type": "array", "items": schemaOf(t.Elem())}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": schemaOf(t.Elem())}
+	case reflect.Struct:
+		props := map[string]interface{}{}
+		var required []string
+		addFields(t, props, &required)
+		s := map[string]interface{}{"type": "object", "properties": props}
+		if len(required) > 0 {
+			s["required"] = required
+		}
+		return s
+	}
+	return map[string]interface{}{}
+}
+
+func implements(t, iface reflect.Type) bool {
+	return t.Implements(iface) || (t.Kind() != reflect.Pointer && reflect.PointerTo(t).Implements(iface))
+}
+
+// addFields adds the exported fields of struct type t, and those of its
+// embedded structs, as properties. The fields of structs embedded by pointer
+// are optional, since encoding/json leaves them out when it is nil.
+func addFields(t reflect.Type, props map[string]interface{}, required *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if !f.IsExported() || tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct {
+			addFields(f.Type, props, required)
+			continue
+		}
+		if f.Anonymous && name == "" && f.Type.Kind() == reflect.Pointer && f.Type.Elem().Kind() == reflect.Struct {
+			addFields(f.Type.Elem(), props, new([]string))
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		props[name] = schemaOf(f.Type)
+		if !strings.Contains(","+opts+",", ",omitempty,") {
+			*required = append(*required, name)
+		}
+	}
+}
+
+// HandleDocs serves the OpenAPI document at /openapi.json and a Swagger UI
+// for it at /docs. The UI's scripts are loaded from a CDN by the browser.
+func (rt *Router) HandleDocs() {
+	rt.Handle(Route{
+		Method:    http.MethodGet,
+		Path:      "/openapi.json",
+		Summary:   "This document",
+		Tag:       "docs",
+		Responses: []Response{{Status: http.StatusOK, Description: "OpenAPI 3 document", ContentType: "application/json"}},
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "application/json")
+			enc := json.NewEncoder(w)
+			enc.SetIndent("", "  ")
+			if err := enc.Encode(rt.OpenAPI()); err != nil {
+				log.Printf("Failed to write response: %v", err)
+			}
+		}),
+	})
+	rt.Handle(Route{
+		Method:    http.MethodGet,
+		Path:      "/docs",
+		Summary:   "Swagger UI for this document",
+		Tag:       "docs",
+		Responses: []Response{{Status: http.StatusOK, Description: "HTML page", ContentType: "text/html"}},
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			if _, err := w.Write([]byte(swaggerUI)); err != nil {
+				log.Printf("Failed to write response: %v", err)
+			}
+		}),
+	})
+}
+
+const swaggerUI = `<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>API documentation</title>
+  <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@5/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="https://unpkg.com/swagger-ui-dist@5/swagger-ui-bundle.js" crossorigin></script>
+  <script>
+    window.onload = () => {
+      window.ui = SwaggerUIBundle({ url: "openapi.json", dom_id: "#swagger-ui" });
+    };
+  </script>
+</body>
+</html>
+`
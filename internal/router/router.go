@@ -0,0 +1,207 @@
+// Package router serves the HTTP API from a declarative table of routes. Each
+// Route names its method, path, parameters and responses next to its handler,
+// so the OpenAPI document served at /openapi.json is generated from the same
+// declarations that dispatch requests and cannot drift from them.
+package router
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// Route declares an endpoint of the HTTP API.
+type Route struct {
+	// Method is the HTTP method the route answers. A route without one
+	// answers every method not declared by another route on its path, and is
+	// documented as GET.
+	Method string
+	Path   string
+
+	Summary     string
+	Description string
+	// Tag groups the route with related ones in the documentation.
+	Tag string
+
+	// Admin routes require the admin token, see WithAuth.
+	Admin bool
+	// Audited routes are recorded in the audit log, see WithAudit.
+	Audited bool
+	// Limited routes count towards the request rate limit, see WithRateLimit.
+	Limited bool
+	// Deprecated routes are documented as such; they still answer.
+	Deprecated bool
+
+	Params    []Param
+	Body      *Body
+	Responses []Response
+
+	Handler http.Handler
+}
+
+// Param declares a query or header parameter.
+type Param struct {
+	Name string
+	// In is "query", the default, or "header".
+	In string
+	// Type is "string", the default, "integer", "number" or "boolean".
+	Type        string
+	Description string
+	Required    bool
+	// Repeated parameters may be given more than once.
+	Repeated bool
+	// Enum lists the values a string parameter accepts.
+	Enum []string
+}
+
+// Body declares a request body.
+type Body struct {
+	// ContentType defaults to text/plain.
+	ContentType string
+	Description string
+	Required    bool
+}
+
+// Response declares a response status.
+type Response struct {
+	Status      int
+	Description string
+	// Schema is a value whose type describes a JSON body, see SchemaOf. A
+	// response without one has a text/plain body, unless ContentType says
+	// otherwise.
+	Schema      interface{}
+	ContentType string
+	// Headers maps the names of response headers to their descriptions.
+	Headers map[string]string
+}
+
+// Middleware wraps a handler.
+type Middleware func(http.Handler) http.Handler
+
+// Option configures a Router.
+type Option func(*Router)
+
+// WithAuth protects Admin routes with auth.
+func WithAuth(auth Middleware) Option {
+	return func(rt *Router) {
+		rt.auth = auth
+	}
+}
+
+// WithAudit records requests to Audited routes with audit, which runs before
+// authentication so that rejected attempts are recorded too.
+func WithAudit(audit Middleware) Option {
+	return func(rt *Router) {
+		rt.audit = audit
+	}
+}
+
+// WithRateLimit limits requests to Limited routes with limit.
+func WithRateLimit(limit Middleware) Option {
+	return func(rt *Router) {
+		rt.limit = limit
+	}
+}
+
+// WithMiddleware wraps every route in mw, the first outermost, around the
+// audit, authentication and rate limiting the route asks for. Within mw,
+// r.Pattern is the path of the route that matched.
+func WithMiddleware(mw ...Middleware) Option {
+	return func(rt *Router) {
+		rt.chain = append(rt.chain, mw...)
+	}
+}
+
+// Router dispatches requests to the routes declared with Handle.
+type Router struct {
+	title, version     string
+	auth, audit, limit Middleware
+	chain              []Middleware
+	mux                *http.ServeMux
+	routes             []Route
+	methods            map[string]map[string]http.Handler
+}
+
+// New returns a Router for the API called title, at version.
+func New(title, version string, opts ...Option) *Router {
+	rt := &Router{
+		title:   title,
+		version: version,
+		mux:     http.NewServeMux(),
+		methods: make(map[string]map[string]http.Handler),
+	}
+	for _, opt := range opts {
+		opt(rt)
+	}
+	return rt
+}
+
+// Handle adds route. It panics if its method and path are declared already,
+// like http.ServeMux.Handle.
+func (rt *Router) Handle(route Route) {
+	methods, ok := rt.methods[route.Path]
+	if !ok {
+		methods = make(map[string]http.Handler)
+		rt.methods[route.Path] = methods
+		rt.mux.Handle(route.Path, rt.dispatch(methods))
+	}
+	if _, dup := methods[route.Method]; dup {
+		panic(fmt.Sprintf("router: %s %s declared twice", route.Method, route.Path))
+	}
+	methods[route.Method] = rt.wrap(route)
+	rt.routes = append(rt.routes, route)
+}
+
+// wrap applies the middleware given to WithMiddleware, then the middleware
+// route asks for: audit, then authentication, then rate limiting.
+func (rt *Router) wrap(route Route) http.Handler {
+	h := route.Handler
+	if route.Limited && rt.limit != nil {
+		h = rt.limit(h)
+	}
+	if route.Admin && rt.auth != nil {
+		h = rt.auth(h)
+	}
+	if route.Audited && rt.audit != nil {
+		h = rt.audit(h)
+	}
+	for i := len(rt.chain) - 1; i >= 0; i-- {
+		h = rt.chain[i](h)
+	}
+	return h
+}
+
+// dispatch picks the handler for the request's method among those declared
+// on one path. HEAD requests are served by GET handlers.
+func (rt *Router) dispatch(methods map[string]http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h, ok := methods[r.Method]
+		if !ok && r.Method == http.MethodHead {
+			h, ok = methods[http.MethodGet]
+		}
+		if !ok {
+			h, ok = methods[""]
+		}
+		if !ok {
+			var allow []string
+			for m := range methods {
+				allow = append(allow, m)
+			}
+			slices.Sort(allow)
+			w.Header().Set("Allow", strings.Join(allow, ", "))
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+func (rt *Router) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rt.mux.ServeHTTP(w, r)
+}
+
+// Routes returns the routes declared so far, in the order they were.
+func (rt *Router) Routes() []Route {
+	return slices.Clone(rt.routes)
+}
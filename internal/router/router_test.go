@@ -0,0 +1,229 @@
+package router
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func text(body string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(body))
+	})
+}
+
+// tagged returns middleware that appends name to the X-Chain header.
+func tagged(name string) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Add("X-Chain", name)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func serve(h http.Handler, method, target string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(method, target, nil))
+	return rec
+}
+
+func TestRouter_Dispatch(t *testing.T) {
+	rt := New("test", "1")
+	rt.Handle(Route{Path: "/any", Handler: text("any")})
+	rt.Handle(Route{Method: http.MethodGet, Path: "/thing", Handler: text("get")})
+	rt.Handle(Route{Method: http.MethodPost, Path: "/thing", Handler: text("post")})
+
+	assert.Equal(t, "any", serve(rt, http.MethodDelete, "/any").Body.String())
+	assert.Equal(t, "get", serve(rt, http.MethodGet, "/thing").Body.String())
+	assert.Equal(t, "post", serve(rt, http.MethodPost, "/thing").Body.String())
+	assert.Equal(t, http.StatusOK, serve(rt, http.MethodHead, "/thing").Code)
+
+	rec := serve(rt, http.MethodPut, "/thing")
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+	assert.Equal(t, "GET, POST", rec.Header().Get("Allow"))
+	assert.Equal(t, http.StatusNotFound, serve(rt, http.MethodGet, "/missing").Code)
+
+	assert.Panics(t, func() { rt.Handle(Route{Method: http.MethodGet, Path: "/thing", Handler: text("again")}) })
+}
+
+func TestRouter_Middleware(t *testing.T) {
+	rt := New("test", "1", WithAudit(tagged("audit")), WithAuth(tagged("auth")), WithRateLimit(tagged("limit")))
+	rt.Handle(Route{Path: "/admin", Admin: true, Audited: true, Handler: text("ok")})
+	rt.Handle(Route{Path: "/data", Limited: true, Handler: text("ok")})
+	rt.Handle(Route{Path: "/plain", Handler: text("ok")})
+
+	assert.Equal(t, []string{"audit", "auth"}, serve(rt, http.MethodGet, "/admin").Header().Values("X-Chain"))
+	assert.Equal(t, []string{"limit"}, serve(rt, http.MethodGet, "/data").Header().Values("X-Chain"))
+	assert.Empty(t, serve(rt, http.MethodGet, "/plain").Header().Values("X-Chain"))
+
+	var pattern string
+	rt = New("test", "1", WithAuth(tagged("auth")), WithMiddleware(tagged("first"), tagged("second"), func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			pattern = r.Pattern
+			next.ServeHTTP(w, r)
+		})
+	}))
+	rt.Handle(Route{Path: "/admin/", Admin: true, Handler: text("ok")})
+	assert.Equal(t, []string{"first", "second", "auth"}, serve(rt, http.MethodGet, "/admin/x").Header().Values("X-Chain"))
+	assert.Equal(t, "/admin/", pattern)
+}
+
+type member struct {
+	Name    string         `json:"name"`
+	Score   float64        `json:"score,omitempty"`
+	Tags    []string       `json:"tags"`
+	Meta    map[string]int `json:"meta,omitempty"`
+	Seen    time.Time      `json:"seen"`
+	Skipped string         `json:"-"`
+	hidden  string
+	Next    *member           `json:"-"`
+	Extra   map[string]string `json:"extra,omitempty"`
+}
+
+func TestSchemaOf(t *testing.T) {
+	got, err := json.Marshal(SchemaOf([]member{}))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"type": "array",
+		"items": {
+			"type": "object",
+			"required": ["name", "tags", "seen"],
+			"properties": {
+				"name": {"type": "string"},
+				"score": {"type": "number"},
+				"tags": {"type": "array", "items": {"type": "string"}},
+				"meta": {"type": "object", "additionalProperties": {"type": "integer"}},
+				"seen": {"type": "string", "format": "date-time"},
+				"extra": {"type": "object", "additionalProperties": {"type": "string"}}
+			}
+		}
+	}`, string(got))
+}
+
+func TestSchemaOf_Embedded(t *testing.T) {
+	type Base struct {
+		ID string `json:"id"`
+	}
+	type Detail struct {
+		Size int `json:"size"`
+	}
+	type row struct {
+		Base
+		*Detail
+		Error string `json:"error,omitempty"`
+	}
+	got, err := json.Marshal(SchemaOf(row{}))
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"type": "object",
+		"required": ["id"],
+		"properties": {
+			"id": {"type": "string"},
+			"size": {"type": "integer"},
+			"error": {"type": "string"}
+		}
+	}`, string(got))
+}
+
+func TestRouter_OpenAPI(t *testing.T) {
+	rt := New("Cache", "1", WithAuth(tagged("auth")), WithRateLimit(tagged("limit")))
+	rt.Handle(Route{
+		Path:    "/get",
+		Summary: "Get a key",
+		Tag:     "keys",
+		Limited: true,
+		Params: []Param{
+			{Name: "key", Required: true},
+			{Name: "consistency", Enum: []string{"strong", "eventual"}},
+			{Name: "If-None-Match", In: "header"},
+		},
+		Responses: []Response{
+			{Status: http.StatusOK, Description: "The value", Headers: map[string]string{"ETag": "The key's version"}},
+			{Status: http.StatusNotModified},
+		},
+		Handler: text("v"),
+	})
+	rt.Handle(Route{
+		Method:     http.MethodPost,
+		Path:       "/admin/snapshot",
+		Admin:      true,
+		Deprecated: true,
+		Body:       &Body{ContentType: "application/json", Required: true},
+		Responses:  []Response{{Status: http.StatusOK, Schema: map[string]uint64{}}},
+		Handler:    text("{}"),
+	})
+	rt.HandleDocs()
+
+	rec := serve(rt, http.MethodGet, "/openapi.json")
+	require.Equal(t, http.StatusOK, rec.Code)
+	var doc struct {
+		OpenAPI string `json:"openapi"`
+		Paths   map[string]map[string]struct {
+			OperationID string `json:"operationId"`
+			Deprecated  bool
+			Tags        []string
+			Parameters  []struct {
+				Name     string
+				In       string
+				Required bool
+				Schema   struct {
+					Type string
+					Enum []string
+				}
+			}
+			RequestBody struct {
+				Required bool
+				Content  map[string]interface{}
+			} `json:"requestBody"`
+			Responses map[string]struct {
+				Description string
+				Headers     map[string]interface{}
+				Content     map[string]struct {
+					Schema map[string]interface{}
+				}
+			}
+			Security []map[string][]string
+		}
+		Components struct {
+			SecuritySchemes map[string]interface{} `json:"securitySchemes"`
+		}
+	}
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &doc))
+	assert.Equal(t, "3.0.3", doc.OpenAPI)
+
+	get := doc.Paths["/get"]["get"]
+	assert.Equal(t, "get_get", get.OperationID)
+	assert.Equal(t, []string{"keys"}, get.Tags)
+	require.Len(t, get.Parameters, 3)
+	assert.True(t, get.Parameters[0].Required)
+	assert.Equal(t, []string{"strong", "eventual"}, get.Parameters[1].Schema.Enum)
+	assert.Equal(t, "header", get.Parameters[2].In)
+	assert.Contains(t, get.Responses["200"].Headers, "ETag")
+	assert.Contains(t, get.Responses["200"].Content, "text/plain")
+	assert.Empty(t, get.Responses["304"].Content)
+	assert.Contains(t, get.Responses, "429")
+	assert.Contains(t, get.Responses, "default")
+	assert.Empty(t, get.Security)
+	assert.False(t, get.Deprecated)
+
+	snap := doc.Paths["/admin/snapshot"]["post"]
+	assert.Equal(t, "post_admin_snapshot", snap.OperationID)
+	assert.True(t, snap.Deprecated)
+	assert.True(t, snap.RequestBody.Required)
+	assert.Contains(t, snap.RequestBody.Content, "application/json")
+	assert.Equal(t, "object", snap.Responses["200"].Content["application/json"].Schema["type"])
+	assert.Contains(t, snap.Responses, "401")
+	assert.Equal(t, []map[string][]string{{"adminToken": {}}}, snap.Security)
+	assert.Contains(t, doc.Components.SecuritySchemes, "adminToken")
+
+	assert.Contains(t, doc.Paths, "/docs")
+	rec = serve(rt, http.MethodGet, "/docs")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), "openapi.json")
+}
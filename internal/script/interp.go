@@ -0,0 +1,576 @@
+package script
+
+import (
+	"math"
+	"strconv"
+	"strings"
+)
+
+// value is a script value: nil, bool, float64, string, *table or *builtin.
+type value interface{}
+
+type table struct {
+	m map[value]value
+}
+
+func newTable() *table {
+	return &table{m: make(map[value]value)}
+}
+
+func (t *table) get(k value) value {
+	return t.m[k]
+}
+
+// length returns the table's border: the largest n with t[1..n] all non-nil.
+func (t *table) length() int {
+	n := 0
+	for t.m[float64(n+1)] != nil {
+		n++
+	}
+	return n
+}
+
+type builtin struct {
+	name string
+	fn   func(in *interp, line int, args []value) (value, error)
+}
+
+type control int
+
+const (
+	ctrlNone control = iota
+	ctrlBreak
+	ctrlReturn
+)
+
+type interp struct {
+	globals map[string]value
+	scopes  []map[string]value // innermost last
+	steps   int
+	alloced int
+	env     Env
+	keys    map[string]bool
+}
+
+// step charges one unit of the budget.
+func (in *interp) step(line int) error {
+	in.steps++
+	if in.steps > MaxSteps {
+		return errorf(line, "exceeded %d steps", MaxSteps)
+	}
+	return nil
+}
+
+// alloc charges n bytes against the allocation budget.
+func (in *interp) alloc(line, n int) error {
+	in.alloced += n
+	if in.alloced > MaxAlloc {
+		return errorf(line, "exceeded %d bytes of allocation", MaxAlloc)
+	}
+	return nil
+}
+
+func (in *interp) lookup(name string) value {
+	for i := len(in.scopes) - 1; i >= 0; i-- {
+		if v, ok := in.scopes[i][name]; ok {
+			return v
+		}
+	}
+	return in.globals[name]
+}
+
+func (in *interp) assign(name string, v value) {
+	for i := len(in.scopes) - 1; i >= 0; i-- {
+		if _, ok := in.scopes[i][name]; ok {
+			in.scopes[i][name] = v
+			return
+		}
+	}
+	in.globals[name] = v
+}
+
+// block runs stmts in a new scope.
+func (in *interp) block(stmts []stmt) (control, value, error) {
+	in.scopes = append(in.scopes, map[string]value{})
+	defer func() { in.scopes = in.scopes[:len(in.scopes)-1] }()
+	return in.stmts(stmts)
+}
+
+func (in *interp) stmts(stmts []stmt) (control, value, error) {
+	for _, s := range stmts {
+		ctrl, v, err := in.exec(s)
+		if err != nil || ctrl != ctrlNone {
+			return ctrl, v, err
+		}
+	}
+	return ctrlNone, nil, nil
+}
+
+func (in *interp) exec(s stmt) (control, value, error) {
+	if err := in.step(0); err != nil {
+		return ctrlNone, nil, err
+	}
+	switch s := s.(type) {
+	case *localStmt:
+		vals, err := in.evalList(s.exprs, len(s.names))
+		if err != nil {
+			return ctrlNone, nil, err
+		}
+		scope := in.scopes[len(in.scopes)-1]
+		for i, n := range s.names {
+			scope[n] = vals[i]
+		}
+	case *assignStmt:
+		vals, err := in.evalList(s.exprs, len(s.targets))
+		if err != nil {
+			return ctrlNone, nil, err
+		}
+		for i, target := range s.targets {
+			if err := in.store(target, vals[i]); err != nil {
+				return ctrlNone, nil, err
+			}
+		}
+	case *callStmt:
+		if _, err := in.eval(s.call); err != nil {
+			return ctrlNone, nil, err
+		}
+	case *ifStmt:
+		for i, cond := range s.conds {
+			v, err := in.eval(cond)
+			if err != nil {
+				return ctrlNone, nil, err
+			}
+			if truthy(v) {
+				return in.block(s.blocks[i])
+			}
+		}
+		if s.orElse != nil {
+			return in.block(s.orElse)
+		}
+	case *whileStmt:
+		for {
+			if err := in.step(0); err != nil {
+				return ctrlNone, nil, err
+			}
+			v, err := in.eval(s.cond)
+			if err != nil {
+				return ctrlNone, nil, err
+			}
+			if !truthy(v) {
+				break
+			}
+			ctrl, v, err := in.block(s.body)
+			if err != nil || ctrl == ctrlReturn {
+				return ctrl, v, err
+			}
+			if ctrl == ctrlBreak {
+				break
+			}
+		}
+	case *repeatStmt:
+		for {
+			if err := in.step(0); err != nil {
+				return ctrlNone, nil, err
+			}
+			// The condition can see the body's locals.
+			in.scopes = append(in.scopes, map[string]value{})
+			ctrl, v, err := in.stmts(s.body)
+			done := true
+			if err == nil && ctrl == ctrlNone {
+				var c value
+				c, err = in.eval(s.cond)
+				done = truthy(c)
+			}
+			in.scopes = in.scopes[:len(in.scopes)-1]
+			if err != nil || ctrl == ctrlReturn {
+				return ctrl, v, err
+			}
+			if ctrl == ctrlBreak || done {
+				break
+			}
+		}
+	case *forStmt:
+		return in.forLoop(s)
+	case *doStmt:
+		return in.block(s.body)
+	case *breakStmt:
+		return ctrlBreak, nil, nil
+	case *returnStmt:
+		if s.x == nil {
+			return ctrlReturn, nil, nil
+		}
+		v, err := in.eval(s.x)
+		return ctrlReturn, v, err
+	}
+	return ctrlNone, nil, nil
+}
+
+func (in *interp) forLoop(s *forStmt) (control, value, error) {
+	bounds := [3]float64{0, 0, 1}
+	for i, x := range []expr{s.start, s.stop, s.step} {
+		if x == nil {
+			continue
+		}
+		v, err := in.eval(x)
+		if err != nil {
+			return ctrlNone, nil, err
+		}
+		n, ok := v.(float64)
+		if !ok {
+			return ctrlNone, nil, errorf(s.line, "'for' %s must be a number", [3]string{"initial value", "limit", "step"}[i])
+		}
+		bounds[i] = n
+	}
+	start, stop, step := bounds[0], bounds[1], bounds[2]
+	if step == 0 {
+		return ctrlNone, nil, errorf(s.line, "'for' step is zero")
+	}
+	for i := start; (step > 0 && i <= stop) || (step < 0 && i >= stop); i += step {
+		if err := in.step(s.line); err != nil {
+			return ctrlNone, nil, err
+		}
+		in.scopes = append(in.scopes, map[string]value{s.name: i})
+		ctrl, v, err := in.block(s.body)
+		in.scopes = in.scopes[:len(in.scopes)-1]
+		if err != nil || ctrl == ctrlReturn {
+			return ctrl, v, err
+		}
+		if ctrl == ctrlBreak {
+			break
+		}
+	}
+	return ctrlNone, nil, nil
+}
+
+// evalList evaluates exprs, padding or truncating the results to n values.
+func (in *interp) evalList(exprs []expr, n int) ([]value, error) {
+	vals := make([]value, n)
+	for i, x := range exprs {
+		v, err := in.eval(x)
+		if err != nil {
+			return nil, err
+		}
+		if i < n {
+			vals[i] = v
+		}
+	}
+	return vals, nil
+}
+
+func (in *interp) store(target expr, v value) error {
+	switch t := target.(type) {
+	case *nameExpr:
+		in.assign(t.name, v)
+		return nil
+	case *indexExpr:
+		obj, err := in.eval(t.obj)
+		if err != nil {
+			return err
+		}
+		tbl, ok := obj.(*table)
+		if !ok {
+			return errorf(t.line, "attempt to index a %s value", typeName(obj))
+		}
+		key, err := in.eval(t.key)
+		if err != nil {
+			return err
+		}
+		if err := checkKey(key, t.line); err != nil {
+			return err
+		}
+		if v == nil {
+			delete(tbl.m, key)
+			return nil
+		}
+		if _, ok := tbl.m[key]; !ok {
+			if err := in.alloc(t.line, tableEntrySize); err != nil {
+				return err
+			}
+		}
+		tbl.m[key] = v
+		return nil
+	}
+	return errorf(0, "cannot assign to this expression")
+}
+
+func checkKey(k value, line int) error {
+	if k == nil {
+		return errorf(line, "table index is nil")
+	}
+	if f, ok := k.(float64); ok && math.IsNaN(f) {
+		return errorf(line, "table index is NaN")
+	}
+	return nil
+}
+
+func (in *interp) eval(x expr) (value, error) {
+	switch x := x.(type) {
+	case *constExpr:
+		return x.v, nil
+	case *nameExpr:
+		return in.lookup(x.name), nil
+	case *indexExpr:
+		obj, err := in.eval(x.obj)
+		if err != nil {
+			return nil, err
+		}
+		tbl, ok := obj.(*table)
+		if !ok {
+			return nil, errorf(x.line, "attempt to index a %s value", typeName(obj))
+		}
+		key, err := in.eval(x.key)
+		if err != nil {
+			return nil, err
+		}
+		return tbl.get(key), nil
+	case *callExpr:
+		fn, err := in.eval(x.fn)
+		if err != nil {
+			return nil, err
+		}
+		b, ok := fn.(*builtin)
+		if !ok {
+			return nil, errorf(x.line, "attempt to call a %s value", typeName(fn))
+		}
+		args := make([]value, len(x.args))
+		for i, a := range x.args {
+			if args[i], err = in.eval(a); err != nil {
+				return nil, err
+			}
+		}
+		if err := in.step(x.line); err != nil {
+			return nil, err
+		}
+		return b.fn(in, x.line, args)
+	case *unaryExpr:
+		v, err := in.eval(x.x)
+		if err != nil {
+			return nil, err
+		}
+		switch x.op {
+		case "not":
+			return !truthy(v), nil
+		case "-":
+			n, ok := toNumber(v)
+			if !ok {
+				return nil, errorf(x.line, "attempt to perform arithmetic on a %s value", typeName(v))
+			}
+			return -n, nil
+		default: // "#"
+			switch v := v.(type) {
+			case string:
+				return float64(len(v)), nil
+			case *table:
+				return float64(v.length()), nil
+			}
+			return nil, errorf(x.line, "attempt to get length of a %s value", typeName(v))
+		}
+	case *binaryExpr:
+		return in.binary(x)
+	case *tableExpr:
+		tbl := newTable()
+		n := 0
+		for _, f := range x.fields {
+			v, err := in.eval(f.val)
+			if err != nil {
+				return nil, err
+			}
+			var key value
+			if f.key == nil {
+				n++
+				key = float64(n)
+			} else if key, err = in.eval(f.key); err != nil {
+				return nil, err
+			} else if err := checkKey(key, 0); err != nil {
+				return nil, err
+			}
+			if v != nil {
+				if err := in.alloc(0, tableEntrySize); err != nil {
+					return nil, err
+				}
+				tbl.m[key] = v
+			}
+		}
+		return tbl, nil
+	}
+	return nil, errorf(0, "unknown expression")
+}
+
+func (in *interp) binary(x *binaryExpr) (value, error) {
+	l, err := in.eval(x.l)
+	if err != nil {
+		return nil, err
+	}
+	switch x.op {
+	case "and":
+		if !truthy(l) {
+			return l, nil
+		}
+		return in.eval(x.r)
+	case "or":
+		if truthy(l) {
+			return l, nil
+		}
+		return in.eval(x.r)
+	}
+	r, err := in.eval(x.r)
+	if err != nil {
+		return nil, err
+	}
+
+	switch x.op {
+	case "==":
+		return l == r, nil
+	case "~=":
+		return l != r, nil
+	case "<", "<=", ">", ">=":
+		return compare(x.op, l, r, x.line)
+	case "..":
+		ls, lok := concatOperand(l)
+		rs, rok := concatOperand(r)
+		if !lok || !rok {
+			bad := l
+			if lok {
+				bad = r
+			}
+			return nil, errorf(x.line, "attempt to concatenate a %s value", typeName(bad))
+		}
+		if len(ls)+len(rs) > maxStringLength {
+			return nil, errorf(x.line, "string longer than %d bytes", maxStringLength)
+		}
+		if err := in.alloc(x.line, len(ls)+len(rs)); err != nil {
+			return nil, err
+		}
+		return ls + rs, nil
+	}
+
+	a, aok := toNumber(l)
+	b, bok := toNumber(r)
+	if !aok || !bok {
+		bad := l
+		if aok {
+			bad = r
+		}
+		return nil, errorf(x.line, "attempt to perform arithmetic on a %s value", typeName(bad))
+	}
+	switch x.op {
+	case "+":
+		return a + b, nil
+	case "-":
+		return a - b, nil
+	case "*":
+		return a * b, nil
+	case "/":
+		return a / b, nil
+	case "%":
+		return a - math.Floor(a/b)*b, nil
+	default: // "^"
+		return math.Pow(a, b), nil
+	}
+}
+
+func compare(op string, l, r value, line int) (value, error) {
+	var c int
+	switch a := l.(type) {
+	case float64:
+		b, ok := r.(float64)
+		if !ok {
+			return nil, errorf(line, "attempt to compare %s with %s", typeName(l), typeName(r))
+		}
+		// Any comparison involving NaN is false.
+		if math.IsNaN(a) || math.IsNaN(b) {
+			return false, nil
+		}
+		switch {
+		case a < b:
+			c = -1
+		case a > b:
+			c = 1
+		}
+	case string:
+		b, ok := r.(string)
+		if !ok {
+			return nil, errorf(line, "attempt to compare %s with %s", typeName(l), typeName(r))
+		}
+		c = strings.Compare(a, b)
+	default:
+		return nil, errorf(line, "attempt to compare two %s values", typeName(l))
+	}
+	switch op {
+	case "<":
+		return c < 0, nil
+	case "<=":
+		return c <= 0, nil
+	case ">":
+		return c > 0, nil
+	default:
+		return c >= 0, nil
+	}
+}
+
+func truthy(v value) bool {
+	return v != nil && v != false
+}
+
+// toNumber converts numbers and numeric strings, as Lua does for arithmetic.
+func toNumber(v value) (float64, bool) {
+	switch v := v.(type) {
+	case float64:
+		return v, true
+	case string:
+		return parseNumber(v)
+	}
+	return 0, false
+}
+
+func concatOperand(v value) (string, bool) {
+	switch v := v.(type) {
+	case string:
+		return v, true
+	case float64:
+		return formatNumber(v), true
+	}
+	return "", false
+}
+
+// formatNumber renders integral values without a fraction, like Lua's %.14g.
+func formatNumber(f float64) string {
+	if f == math.Trunc(f) && math.Abs(f) < 1e15 {
+		return strconv.FormatInt(int64(f), 10)
+	}
+	return strconv.FormatFloat(f, 'g', 14, 64)
+}
+
+func toString(v value) string {
+	switch v := v.(type) {
+	case nil:
+		return "nil"
+	case bool:
+		return strconv.FormatBool(v)
+	case float64:
+		return formatNumber(v)
+	case string:
+		return v
+	case *builtin:
+		return "function: " + v.name
+	default:
+		return "table"
+	}
+}
+
+func typeName(v value) string {
+	switch v.(type) {
+	case nil:
+		return "nil"
+	case bool:
+		return "boolean"
+	case float64:
+		return "number"
+	case string:
+		return "string"
+	case *table:
+		return "table"
+	default:
+		return "function"
+	}
+}
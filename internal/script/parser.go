@@ -0,0 +1,534 @@
+package script
+
+// Abstract syntax tree
+
+type expr interface{}
+
+type (
+	constExpr struct{ v value }
+	nameExpr  struct {
+		name string
+		line int
+	}
+	indexExpr struct {
+		obj, key expr
+		line     int
+	}
+	callExpr struct {
+		fn   expr
+		args []expr
+		line int
+	}
+	binaryExpr struct {
+		op   string
+		l, r expr
+		line int
+	}
+	unaryExpr struct {
+		op   string
+		x    expr
+		line int
+	}
+	// tableExpr is a constructor: positional items and keyed fields, in source order.
+	tableExpr struct {
+		fields []tableField
+	}
+)
+
+type tableField struct {
+	key expr // nil for a positional item
+	val expr
+}
+
+type stmt interface{}
+
+type (
+	localStmt struct {
+		names []string
+		exprs []expr
+	}
+	assignStmt struct {
+		targets []expr // nameExpr or indexExpr
+		exprs   []expr
+	}
+	callStmt struct{ call *callExpr }
+	ifStmt   struct {
+		conds  []expr
+		blocks [][]stmt
+		orElse []stmt
+	}
+	whileStmt struct {
+		cond expr
+		body []stmt
+	}
+	repeatStmt struct {
+		body []stmt
+		cond expr
+	}
+	forStmt struct {
+		name              string
+		start, stop, step expr // step may be nil
+		body              []stmt
+		line              int
+	}
+	doStmt     struct{ body []stmt }
+	breakStmt  struct{ line int }
+	returnStmt struct{ x expr } // x may be nil
+)
+
+// Operator priorities as in Lua: {left, right}. Right-associative operators
+// have a lower right priority.
+var binaryPriority = map[string][2]int{
+	"or": {1, 1}, "and": {2, 2},
+	"<": {3, 3}, ">": {3, 3}, "<=": {3, 3}, ">=": {3, 3}, "~=": {3, 3}, "==": {3, 3},
+	"..": {5, 4}, "+": {6, 6}, "-": {6, 6},
+	"*": {7, 7}, "/": {7, 7}, "%": {7, 7}, "^": {10, 9},
+}
+
+const unaryPriority = 8
+
+type parser struct {
+	toks []token
+	pos  int
+}
+
+func parse(src string) ([]stmt, error) {
+	toks, err := lex(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &parser{toks: toks}
+	block, err := p.block()
+	if err != nil {
+		return nil, err
+	}
+	if t := p.peek(); t.kind != tokEOF {
+		return nil, errorf(t.line, "unexpected %s", describe(t))
+	}
+	return block, nil
+}
+
+func (p *parser) peek() token {
+	return p.toks[p.pos]
+}
+
+func (p *parser) next() token {
+	t := p.toks[p.pos]
+	if t.kind != tokEOF {
+		p.pos++
+	}
+	return t
+}
+
+// is reports whether the next token is the keyword or operator s.
+func (p *parser) is(s string) bool {
+	t := p.peek()
+	return (t.kind == tokKeyword || t.kind == tokOp) && t.text == s
+}
+
+func (p *parser) accept(s string) bool {
+	if p.is(s) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *parser) expect(s string) error {
+	if !p.accept(s) {
+		t := p.peek()
+		return errorf(t.line, "expected %q near %s", s, describe(t))
+	}
+	return nil
+}
+
+func (p *parser) name() (string, error) {
+	t := p.peek()
+	if t.kind != tokName {
+		return "", errorf(t.line, "expected name near %s", describe(t))
+	}
+	p.pos++
+	return t.text, nil
+}
+
+func describe(t token) string {
+	switch t.kind {
+	case tokEOF:
+		return "end of script"
+	case tokNumber:
+		return "number"
+	case tokString:
+		return "string"
+	default:
+		return "'" + t.text + "'"
+	}
+}
+
+// blockEnd reports whether the next token closes a block.
+func (p *parser) blockEnd() bool {
+	return p.peek().kind == tokEOF || p.is("end") || p.is("else") || p.is("elseif") || p.is("until")
+}
+
+func (p *parser) block() ([]stmt, error) {
+	var stmts []stmt
+	for !p.blockEnd() {
+		if p.accept(";") {
+			continue
+		}
+		if p.accept("return") {
+			r := &returnStmt{}
+			if !p.blockEnd() && !p.is(";") {
+				x, err := p.expr(0)
+				if err != nil {
+					return nil, err
+				}
+				r.x = x
+				if p.is(",") {
+					return nil, errorf(p.peek().line, "multiple return values are not supported; return a table")
+				}
+			}
+			p.accept(";")
+			if !p.blockEnd() {
+				t := p.peek()
+				return nil, errorf(t.line, "'return' must be the last statement in a block")
+			}
+			return append(stmts, r), nil
+		}
+		s, err := p.statement()
+		if err != nil {
+			return nil, err
+		}
+		stmts = append(stmts, s)
+	}
+	return stmts, nil
+}
+
+func (p *parser) statement() (stmt, error) {
+	t := p.peek()
+	switch {
+	case p.accept("local"):
+		if p.is("function") {
+			return nil, errorf(t.line, "function definitions are not supported")
+		}
+		s := &localStmt{}
+		for {
+			n, err := p.name()
+			if err != nil {
+				return nil, err
+			}
+			s.names = append(s.names, n)
+			if !p.accept(",") {
+				break
+			}
+		}
+		if p.accept("=") {
+			exprs, err := p.exprList()
+			if err != nil {
+				return nil, err
+			}
+			s.exprs = exprs
+		}
+		return s, nil
+	case p.accept("if"):
+		s := &ifStmt{}
+		for {
+			cond, err := p.expr(0)
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect("then"); err != nil {
+				return nil, err
+			}
+			body, err := p.block()
+			if err != nil {
+				return nil, err
+			}
+			s.conds = append(s.conds, cond)
+			s.blocks = append(s.blocks, body)
+			if !p.accept("elseif") {
+				break
+			}
+		}
+		if p.accept("else") {
+			body, err := p.block()
+			if err != nil {
+				return nil, err
+			}
+			s.orElse = body
+		}
+		return s, p.expect("end")
+	case p.accept("while"):
+		cond, err := p.expr(0)
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect("do"); err != nil {
+			return nil, err
+		}
+		body, err := p.block()
+		if err != nil {
+			return nil, err
+		}
+		return &whileStmt{cond: cond, body: body}, p.expect("end")
+	case p.accept("repeat"):
+		body, err := p.block()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect("until"); err != nil {
+			return nil, err
+		}
+		cond, err := p.expr(0)
+		if err != nil {
+			return nil, err
+		}
+		return &repeatStmt{body: body, cond: cond}, nil
+	case p.accept("for"):
+		return p.forStatement(t.line)
+	case p.accept("do"):
+		body, err := p.block()
+		if err != nil {
+			return nil, err
+		}
+		return &doStmt{body: body}, p.expect("end")
+	case p.accept("break"):
+		return &breakStmt{line: t.line}, nil
+	case p.is("function"):
+		return nil, errorf(t.line, "function definitions are not supported")
+	}
+
+	// An assignment or a call.
+	target, err := p.suffixedExpr()
+	if err != nil {
+		return nil, err
+	}
+	if call, ok := target.(*callExpr); ok && !p.is("=") && !p.is(",") {
+		return &callStmt{call: call}, nil
+	}
+	targets := []expr{target}
+	for p.accept(",") {
+		x, err := p.suffixedExpr()
+		if err != nil {
+			return nil, err
+		}
+		targets = append(targets, x)
+	}
+	for _, x := range targets {
+		switch x.(type) {
+		case *nameExpr, *indexExpr:
+		default:
+			return nil, errorf(t.line, "cannot assign to this expression")
+		}
+	}
+	if err := p.expect("="); err != nil {
+		return nil, err
+	}
+	exprs, err := p.exprList()
+	if err != nil {
+		return nil, err
+	}
+	return &assignStmt{targets: targets, exprs: exprs}, nil
+}
+
+func (p *parser) forStatement(line int) (stmt, error) {
+	name, err := p.name()
+	if err != nil {
+		return nil, err
+	}
+	if !p.is("=") {
+		return nil, errorf(line, "only numeric for loops are supported")
+	}
+	p.next()
+	s := &forStmt{name: name, line: line}
+	if s.start, err = p.expr(0); err != nil {
+		return nil, err
+	}
+	if err := p.expect(","); err != nil {
+		return nil, err
+	}
+	if s.stop, err = p.expr(0); err != nil {
+		return nil, err
+	}
+	if p.accept(",") {
+		if s.step, err = p.expr(0); err != nil {
+			return nil, err
+		}
+	}
+	if err := p.expect("do"); err != nil {
+		return nil, err
+	}
+	if s.body, err = p.block(); err != nil {
+		return nil, err
+	}
+	return s, p.expect("end")
+}
+
+func (p *parser) exprList() ([]expr, error) {
+	var exprs []expr
+	for {
+		x, err := p.expr(0)
+		if err != nil {
+			return nil, err
+		}
+		exprs = append(exprs, x)
+		if !p.accept(",") {
+			return exprs, nil
+		}
+	}
+}
+
+// expr parses a binary expression whose operators bind tighter than limit.
+func (p *parser) expr(limit int) (expr, error) {
+	var left expr
+	t := p.peek()
+	if p.is("not") || p.is("-") || p.is("#") {
+		p.next()
+		x, err := p.expr(unaryPriority)
+		if err != nil {
+			return nil, err
+		}
+		left = &unaryExpr{op: t.text, x: x, line: t.line}
+	} else {
+		x, err := p.simpleExpr()
+		if err != nil {
+			return nil, err
+		}
+		left = x
+	}
+	for {
+		t := p.peek()
+		prio, ok := binaryPriority[t.text]
+		if !ok || (t.kind != tokOp && t.kind != tokKeyword) || prio[0] <= limit {
+			return left, nil
+		}
+		p.next()
+		right, err := p.expr(prio[1])
+		if err != nil {
+			return nil, err
+		}
+		left = &binaryExpr{op: t.text, l: left, r: right, line: t.line}
+	}
+}
+
+func (p *parser) simpleExpr() (expr, error) {
+	t := p.peek()
+	switch {
+	case t.kind == tokNumber:
+		p.next()
+		return &constExpr{v: t.num}, nil
+	case t.kind == tokString:
+		p.next()
+		return &constExpr{v: t.text}, nil
+	case p.accept("nil"):
+		return &constExpr{v: nil}, nil
+	case p.accept("true"):
+		return &constExpr{v: true}, nil
+	case p.accept("false"):
+		return &constExpr{v: false}, nil
+	case p.is("{"):
+		return p.tableConstructor()
+	case p.is("function"):
+		return nil, errorf(t.line, "function definitions are not supported")
+	}
+	return p.suffixedExpr()
+}
+
+// suffixedExpr parses a name or parenthesized expression followed by any
+// number of field accesses, indexes and calls.
+func (p *parser) suffixedExpr() (expr, error) {
+	t := p.peek()
+	var x expr
+	switch {
+	case t.kind == tokName:
+		p.next()
+		x = &nameExpr{name: t.text, line: t.line}
+	case p.accept("("):
+		inner, err := p.expr(0)
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		x = inner
+	default:
+		return nil, errorf(t.line, "unexpected %s", describe(t))
+	}
+	for {
+		t := p.peek()
+		switch {
+		case p.accept("."):
+			n, err := p.name()
+			if err != nil {
+				return nil, err
+			}
+			x = &indexExpr{obj: x, key: &constExpr{v: n}, line: t.line}
+		case p.accept("["):
+			key, err := p.expr(0)
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect("]"); err != nil {
+				return nil, err
+			}
+			x = &indexExpr{obj: x, key: key, line: t.line}
+		case p.accept("("):
+			call := &callExpr{fn: x, line: t.line}
+			if !p.accept(")") {
+				args, err := p.exprList()
+				if err != nil {
+					return nil, err
+				}
+				if err := p.expect(")"); err != nil {
+					return nil, err
+				}
+				call.args = args
+			}
+			x = call
+		case p.is(":"):
+			return nil, errorf(t.line, "method calls are not supported")
+		default:
+			return x, nil
+		}
+	}
+}
+
+func (p *parser) tableConstructor() (expr, error) {
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+	tbl := &tableExpr{}
+	for !p.accept("}") {
+		var f tableField
+		switch {
+		case p.peek().kind == tokName && p.toks[p.pos+1].kind == tokOp && p.toks[p.pos+1].text == "=":
+			f.key = &constExpr{v: p.next().text}
+			p.next()
+		case p.accept("["):
+			key, err := p.expr(0)
+			if err != nil {
+				return nil, err
+			}
+			if err := p.expect("]"); err != nil {
+				return nil, err
+			}
+			if err := p.expect("="); err != nil {
+				return nil, err
+			}
+			f.key = key
+		}
+		val, err := p.expr(0)
+		if err != nil {
+			return nil, err
+		}
+		f.val = val
+		tbl.fields = append(tbl.fields, f)
+		if !p.accept(",") && !p.accept(";") {
+			if err := p.expect("}"); err != nil {
+				return nil, err
+			}
+			break
+		}
+	}
+	return tbl, nil
+}
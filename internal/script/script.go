@@ -0,0 +1,443 @@
+// Package script runs small Lua scripts against the cache, for atomic
+// multi-step operations such as rate limiters and conditional multi-key updates.
+//
+// Scripts are replicated as Raft commands and run by the FSM on every node, so
+// they must give the same result everywhere. The language is therefore a
+// deterministic subset of Lua 5.1: there is no I/O, no randomness, no clock
+// other than the log entry's timestamp (cache.time), no unordered iteration
+// (pairs), and every script runs under the same step and allocation budgets,
+// so a runaway script is stopped at the same point on every node.
+//
+// Supported: local variables and assignment, if/elseif/else, while, repeat,
+// numeric for, break, return; nil, booleans, numbers, strings and tables; the
+// Lua arithmetic, comparison, logical, length and concatenation operators.
+// Not supported: defining functions, generic for, metatables, varargs.
+//
+// Scripts see:
+//
+//	KEYS, ARGV                  the keys and arguments passed with the script (1-based)
+//	cache.get(key)              the value, or nil
+//	cache.set(key, value [, ttl_seconds])
+//	cache.del(key)              true if the key existed
+//	cache.exists(key)           true if the key exists
+//	cache.time()                the command's timestamp in Unix seconds
+//	tonumber, tostring, type, error
+//	math.floor, math.ceil, math.abs, math.min, math.max, math.huge
+//	string.len, string.sub, string.upper, string.lower, string.rep
+//
+// cache functions only accept keys declared in KEYS.
+package script
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"time"
+
+	coreerrors "distributed-cache-service/internal/core/errors"
+)
+
+const (
+	// MaxSteps bounds the statements, loop iterations and calls a script may
+	// execute. It must be the same on every node.
+	MaxSteps = 100000
+	// MaxAlloc bounds the bytes of strings and table entries a script may
+	// create over its whole run. Like MaxSteps it must be the same on every
+	// node.
+	MaxAlloc = 256 << 20
+
+	maxStringLength = 64 << 20
+	maxResultDepth  = 32
+	tableEntrySize  = 32 // charged against MaxAlloc per table entry
+)
+
+// Env is the keyspace a script runs against.
+type Env interface {
+	Get(key string) (string, bool)
+	Set(key, value string, ttl time.Duration)
+	// Delete removes key, reporting whether it existed.
+	Delete(key string) bool
+	// Now is the deterministic time of the command, the same on every node.
+	Now() time.Time
+}
+
+// Error is a compile or runtime error in a script. It wraps
+// coreerrors.ErrScript.
+type Error struct {
+	Line int // 0 if unknown
+	Msg  string
+}
+
+func (e *Error) Error() string {
+	if e.Line > 0 {
+		return fmt.Sprintf("script line %d: %s", e.Line, e.Msg)
+	}
+	return "script: " + e.Msg
+}
+
+func (e *Error) Unwrap() error {
+	return coreerrors.ErrScript
+}
+
+func errorf(line int, format string, args ...interface{}) *Error {
+	return &Error{Line: line, Msg: fmt.Sprintf(format, args...)}
+}
+
+// Script is a compiled script. It is immutable and safe for concurrent use.
+type Script struct {
+	body []stmt
+}
+
+// Compile parses src.
+func Compile(src string) (*Script, error) {
+	body, err := parse(src)
+	if err != nil {
+		return nil, err
+	}
+	return &Script{body: body}, nil
+}
+
+// Run executes the script against env and returns its result converted to Go:
+// nil, bool, float64, string, []interface{} for a table with only array items,
+// or map[string]interface{} for any other table.
+//
+// Scripts run inside the FSM, where a panic would take down every replica, so
+// Run reports one as an *Error instead.
+func (s *Script) Run(env Env, keys, args []string) (result interface{}, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			result, err = nil, errorf(0, "internal error: %v", r)
+		}
+	}()
+	in := &interp{
+		env:     env,
+		keys:    make(map[string]bool, len(keys)),
+		globals: builtins(),
+	}
+	keyTable, argTable := newTable(), newTable()
+	for i, k := range keys {
+		in.keys[k] = true
+		keyTable.m[float64(i+1)] = k
+	}
+	for i, a := range args {
+		argTable.m[float64(i+1)] = a
+	}
+	in.globals["KEYS"] = keyTable
+	in.globals["ARGV"] = argTable
+
+	_, v, err := in.block(s.body)
+	if err != nil {
+		return nil, err
+	}
+	return export(v, 0)
+}
+
+// export converts a script value to a Go value.
+func export(v value, depth int) (interface{}, error) {
+	switch v := v.(type) {
+	case *builtin:
+		return nil, errorf(0, "cannot return function %s", v.name)
+	case float64:
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return nil, errorf(0, "cannot return non-finite number %s", formatNumber(v))
+		}
+		return v, nil
+	}
+	t, ok := v.(*table)
+	if !ok {
+		return v, nil
+	}
+	if depth >= maxResultDepth {
+		return nil, errorf(0, "result nested deeper than %d tables", maxResultDepth)
+	}
+	if n := t.length(); n == len(t.m) {
+		out := make([]interface{}, n)
+		for i := range out {
+			var err error
+			if out[i], err = export(t.m[float64(i+1)], depth+1); err != nil {
+				return nil, err
+			}
+		}
+		return out, nil
+	}
+	out := make(map[string]interface{}, len(t.m))
+	for k, e := range t.m {
+		x, err := export(e, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		out[toString(k)] = x
+	}
+	return out, nil
+}
+
+func fn(name string, f func(in *interp, line int, args []value) (value, error)) *builtin {
+	return &builtin{name: name, fn: f}
+}
+
+// builtins returns a fresh global environment, so scripts cannot leak state
+// into each other by modifying library tables.
+func builtins() map[string]value {
+	lib := func(fns ...*builtin) *table {
+		t := newTable()
+		for _, f := range fns {
+			t.m[f.name[strings.IndexByte(f.name, '.')+1:]] = f
+		}
+		return t
+	}
+	mathLib := lib(
+		fn("math.floor", numberFunc(math.Floor)),
+		fn("math.ceil", numberFunc(math.Ceil)),
+		fn("math.abs", numberFunc(math.Abs)),
+		fn("math.min", minMax(math.Min)),
+		fn("math.max", minMax(math.Max)),
+	)
+	mathLib.m["huge"] = math.Inf(1)
+
+	return map[string]value{
+		"cache": lib(
+			fn("cache.get", cacheGet),
+			fn("cache.set", cacheSet),
+			fn("cache.del", cacheDel),
+			fn("cache.exists", cacheExists),
+			fn("cache.time", cacheTime),
+		),
+		"math": mathLib,
+		"string": lib(
+			fn("string.len", stringLen),
+			fn("string.sub", stringSub),
+			fn("string.upper", stringFunc(strings.ToUpper)),
+			fn("string.lower", stringFunc(strings.ToLower)),
+			fn("string.rep", stringRep),
+		),
+		"tonumber": fn("tonumber", func(in *interp, line int, args []value) (value, error) {
+			if n, ok := toNumber(arg(args, 0)); ok {
+				return n, nil
+			}
+			return nil, nil
+		}),
+		"tostring": fn("tostring", func(in *interp, line int, args []value) (value, error) {
+			str := toString(arg(args, 0))
+			if err := in.alloc(line, len(str)); err != nil {
+				return nil, err
+			}
+			return str, nil
+		}),
+		"type": fn("type", func(in *interp, line int, args []value) (value, error) {
+			return typeName(arg(args, 0)), nil
+		}),
+		"error": fn("error", func(in *interp, line int, args []value) (value, error) {
+			return nil, &Error{Line: line, Msg: toString(arg(args, 0))}
+		}),
+	}
+}
+
+func arg(args []value, i int) value {
+	if i < len(args) {
+		return args[i]
+	}
+	return nil
+}
+
+func numberArg(name string, args []value, i, line int) (float64, error) {
+	n, ok := toNumber(arg(args, i))
+	if !ok {
+		return 0, errorf(line, "bad argument #%d to '%s' (number expected, got %s)", i+1, name, typeName(arg(args, i)))
+	}
+	return n, nil
+}
+
+// intArg returns argument i as an integer, rejecting NaN, infinities and
+// fractions rather than letting them reach an index or a count.
+func intArg(name string, args []value, i, line int) (float64, error) {
+	n, err := numberArg(name, args, i, line)
+	if err != nil {
+		return 0, err
+	}
+	if math.IsNaN(n) || math.IsInf(n, 0) || n != math.Trunc(n) {
+		return 0, errorf(line, "bad argument #%d to '%s' (number has no integer representation)", i+1, name)
+	}
+	return n, nil
+}
+
+func stringArg(name string, args []value, i, line int) (string, error) {
+	s, ok := concatOperand(arg(args, i))
+	if !ok {
+		return "", errorf(line, "bad argument #%d to '%s' (string expected, got %s)", i+1, name, typeName(arg(args, i)))
+	}
+	return s, nil
+}
+
+// keyArg returns argument i as a key declared in KEYS.
+func (in *interp) keyArg(name string, args []value, line int) (string, error) {
+	key, err := stringArg(name, args, 0, line)
+	if err != nil {
+		return "", err
+	}
+	if !in.keys[key] {
+		return "", errorf(line, "'%s' on key %q, which is not declared in KEYS", name, key)
+	}
+	return key, nil
+}
+
+func cacheGet(in *interp, line int, args []value) (value, error) {
+	key, err := in.keyArg("cache.get", args, line)
+	if err != nil {
+		return nil, err
+	}
+	if v, ok := in.env.Get(key); ok {
+		return v, nil
+	}
+	return nil, nil
+}
+
+func cacheSet(in *interp, line int, args []value) (value, error) {
+	key, err := in.keyArg("cache.set", args, line)
+	if err != nil {
+		return nil, err
+	}
+	val, err := stringArg("cache.set", args, 1, line)
+	if err != nil {
+		return nil, err
+	}
+	var ttl time.Duration
+	if arg(args, 2) != nil {
+		secs, err := numberArg("cache.set", args, 2, line)
+		if err != nil {
+			return nil, err
+		}
+		if !(secs > 0) || secs > math.MaxInt64/float64(time.Second) {
+			return nil, errorf(line, "bad argument #3 to 'cache.set' (ttl must be positive)")
+		}
+		ttl = time.Duration(secs * float64(time.Second))
+	}
+	in.env.Set(key, val, ttl)
+	return true, nil
+}
+
+func cacheDel(in *interp, line int, args []value) (value, error) {
+	key, err := in.keyArg("cache.del", args, line)
+	if err != nil {
+		return nil, err
+	}
+	return in.env.Delete(key), nil
+}
+
+func cacheExists(in *interp, line int, args []value) (value, error) {
+	key, err := in.keyArg("cache.exists", args, line)
+	if err != nil {
+		return nil, err
+	}
+	_, ok := in.env.Get(key)
+	return ok, nil
+}
+
+func cacheTime(in *interp, line int, args []value) (value, error) {
+	return float64(in.env.Now().UnixMilli()) / 1000, nil
+}
+
+func numberFunc(f func(float64) float64) func(*interp, int, []value) (value, error) {
+	return func(in *interp, line int, args []value) (value, error) {
+		n, err := numberArg("math function", args, 0, line)
+		if err != nil {
+			return nil, err
+		}
+		return f(n), nil
+	}
+}
+
+func minMax(f func(a, b float64) float64) func(*interp, int, []value) (value, error) {
+	return func(in *interp, line int, args []value) (value, error) {
+		acc, err := numberArg("math function", args, 0, line)
+		if err != nil {
+			return nil, err
+		}
+		for i := 1; i < len(args); i++ {
+			n, err := numberArg("math function", args, i, line)
+			if err != nil {
+				return nil, err
+			}
+			acc = f(acc, n)
+		}
+		return acc, nil
+	}
+}
+
+func stringFunc(f func(string) string) func(*interp, int, []value) (value, error) {
+	return func(in *interp, line int, args []value) (value, error) {
+		s, err := stringArg("string function", args, 0, line)
+		if err != nil {
+			return nil, err
+		}
+		if err := in.alloc(line, len(s)); err != nil {
+			return nil, err
+		}
+		return f(s), nil
+	}
+}
+
+func stringLen(in *interp, line int, args []value) (value, error) {
+	s, err := stringArg("string.len", args, 0, line)
+	if err != nil {
+		return nil, err
+	}
+	return float64(len(s)), nil
+}
+
+// stringSub implements string.sub(s, i [, j]) with Lua's 1-based, inclusive,
+// negative-from-the-end indices.
+func stringSub(in *interp, line int, args []value) (value, error) {
+	s, err := stringArg("string.sub", args, 0, line)
+	if err != nil {
+		return nil, err
+	}
+	i, err := intArg("string.sub", args, 1, line)
+	if err != nil {
+		return nil, err
+	}
+	j := float64(-1)
+	if arg(args, 2) != nil {
+		if j, err = intArg("string.sub", args, 2, line); err != nil {
+			return nil, err
+		}
+	}
+	// Clamping to ±(n+1) keeps the conversions below in range without
+	// changing the result.
+	n := float64(len(s))
+	i, j = math.Max(math.Min(i, n+1), -n-1), math.Max(math.Min(j, n+1), -n-1)
+	if i < 0 {
+		i = math.Max(n+i+1, 1)
+	} else if i == 0 {
+		i = 1
+	}
+	if j < 0 {
+		j = n + j + 1
+	} else if j > n {
+		j = n
+	}
+	if i > j {
+		return "", nil
+	}
+	return s[int(i)-1 : int(j)], nil
+}
+
+func stringRep(in *interp, line int, args []value) (value, error) {
+	s, err := stringArg("string.rep", args, 0, line)
+	if err != nil {
+		return nil, err
+	}
+	n, err := intArg("string.rep", args, 1, line)
+	if err != nil {
+		return nil, err
+	}
+	if n <= 0 {
+		return "", nil
+	}
+	if float64(len(s))*n > maxStringLength {
+		return nil, errorf(line, "string longer than %d bytes", maxStringLength)
+	}
+	if err := in.alloc(line, len(s)*int(n)); err != nil {
+		return nil, err
+	}
+	return strings.Repeat(s, int(n)), nil
+}
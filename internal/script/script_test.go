@@ -0,0 +1,232 @@
+package script
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	coreerrors "distributed-cache-service/internal/core/errors"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type mapEnv struct {
+	data map[string]string
+	ttls map[string]time.Duration
+	now  time.Time
+}
+
+func newMapEnv() *mapEnv {
+	return &mapEnv{data: map[string]string{}, ttls: map[string]time.Duration{}, now: time.Unix(1700000000, 500e6)}
+}
+
+func (e *mapEnv) Get(key string) (string, bool) {
+	v, ok := e.data[key]
+	return v, ok
+}
+
+func (e *mapEnv) Set(key, value string, ttl time.Duration) {
+	e.data[key] = value
+	e.ttls[key] = ttl
+}
+
+func (e *mapEnv) Delete(key string) bool {
+	_, ok := e.data[key]
+	delete(e.data, key)
+	return ok
+}
+
+func (e *mapEnv) Now() time.Time { return e.now }
+
+func run(t *testing.T, env Env, src string, keys, args []string) (interface{}, error) {
+	t.Helper()
+	s, err := Compile(src)
+	require.NoError(t, err)
+	return s.Run(env, keys, args)
+}
+
+func TestScript_Expressions(t *testing.T) {
+	tests := []struct {
+		src  string
+		want interface{}
+	}{
+		{"return 1 + 2 * 3", float64(7)},
+		{"return (1 + 2) * 3", float64(9)},
+		{"return 2 ^ 3 ^ 2", float64(512)},
+		{"return -2 ^ 2", float64(-4)},
+		{"return 7 % 3", float64(1)},
+		{"return -7 % 3", float64(2)},
+		{"return 'a' .. 1 .. 'b'", "a1b"},
+		{"return '10' + 5", float64(15)},
+		{"return 1 < 2 and 'yes' or 'no'", "yes"},
+		{"return nil or false", false},
+		{"return not nil", true},
+		{"return #'hello'", float64(5)},
+		{"return #{1, 2, 3}", float64(3)},
+		{"return 1 == '1'", false},
+		{"return 'a' < 'b'", true},
+		{"return tostring(1.5) .. tostring(2)", "1.52"},
+		{"return tonumber('0x10')", float64(16)},
+		{"return tonumber('abc')", nil},
+		{"return type({})", "table"},
+		{"return math.max(3, 9, 4) + math.floor(2.7)", float64(11)},
+		{"return string.sub('hello', 2, -2)", "ell"},
+		{"return string.upper('abc') .. string.rep('x', 3)", "ABCxxx"},
+		{"return [[long\nstring]]", "long\nstring"},
+		{"return {1, 'two', {3}}", []interface{}{float64(1), "two", []interface{}{float64(3)}}},
+		{"return {a = 1, [2] = true}", map[string]interface{}{"a": float64(1), "2": true}},
+		{"-- comment\n--[[ block\ncomment ]] return 'ok'", "ok"},
+	}
+	for _, tt := range tests {
+		got, err := run(t, newMapEnv(), tt.src, nil, nil)
+		if assert.NoError(t, err, tt.src) {
+			assert.Equal(t, tt.want, got, tt.src)
+		}
+	}
+}
+
+func TestScript_Statements(t *testing.T) {
+	src := `
+local sum = 0
+for i = 1, 10 do
+  if i % 2 == 0 then
+    sum = sum + i
+  elseif i == 9 then
+    break
+  end
+end
+local n, t = 0, {}
+while n < 3 do
+  n = n + 1
+  t[n] = n * n
+end
+repeat
+  local done = true
+until done
+for i = 10, 1, -3 do
+  t[#t + 1] = i
+end
+return {sum, t}
+`
+	got, err := run(t, newMapEnv(), src, nil, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{float64(20), []interface{}{float64(1), float64(4), float64(9), float64(10), float64(7), float64(4), float64(1)}}, got)
+}
+
+func TestScript_Cache(t *testing.T) {
+	env := newMapEnv()
+	env.data["a"] = "1"
+
+	src := `
+local v = tonumber(cache.get(KEYS[1])) + tonumber(ARGV[1])
+cache.set(KEYS[1], v, 10)
+cache.set(KEYS[2], 'x')
+return {v, cache.exists(KEYS[2]), cache.del(KEYS[3]), cache.time()}
+`
+	got, err := run(t, env, src, []string{"a", "b", "c"}, []string{"41"})
+	require.NoError(t, err)
+	assert.Equal(t, []interface{}{float64(42), true, false, 1700000000.5}, got)
+	assert.Equal(t, "42", env.data["a"])
+	assert.Equal(t, 10*time.Second, env.ttls["a"])
+	assert.Equal(t, time.Duration(0), env.ttls["b"])
+}
+
+func TestScript_UndeclaredKey(t *testing.T) {
+	_, err := run(t, newMapEnv(), "return cache.get('other')", []string{"k"}, nil)
+	assert.ErrorIs(t, err, coreerrors.ErrScript)
+	assert.Contains(t, err.Error(), "not declared in KEYS")
+}
+
+func TestScript_Errors(t *testing.T) {
+	compileErrors := []string{
+		"return 1 +",
+		"if true then",
+		"local function f() end",
+		"for k, v in pairs(t) do end",
+		"x = 'unfinished",
+		"return 1 return 2",
+		"return 1, 2",
+		"1 = 2",
+	}
+	for _, src := range compileErrors {
+		_, err := Compile(src)
+		assert.ErrorIs(t, err, coreerrors.ErrScript, src)
+	}
+
+	runtimeErrors := map[string]string{
+		"error('rate limited')":            "script line 1: rate limited",
+		"return nil + 1":                   "arithmetic on a nil value",
+		"return {} < {}":                   "compare two table values",
+		"local t = nil\nreturn t.x":        "script line 2: attempt to index a nil value",
+		"return undefined()":               "attempt to call a nil value",
+		"while true do end":                "exceeded",
+		"for i = 1, 10, 0 do end":          "step is zero",
+		"local t = {}\nt[nil] = 1":         "index is nil",
+		"return string.rep('x', 1e12)":     "string longer",
+		"return {1 / 0}":                   "non-finite",
+		"return string.sub('abc', 0.5)":    "no integer representation",
+		"return string.sub('abc', 1, 0/0)": "no integer representation",
+		"return string.sub('abc', 1 / 0)":  "no integer representation",
+		"return string.rep('x', 0/0)":      "no integer representation",
+		"return string.rep('x', -1 / 0)":   "no integer representation",
+		"local s = string.rep('x', 1048576)\nfor i = 1, 1000 do local t = s .. i end": "bytes of allocation",
+	}
+	for src, want := range runtimeErrors {
+		_, err := run(t, newMapEnv(), src, nil, nil)
+		var se *Error
+		if assert.True(t, errors.As(err, &se), src) {
+			assert.Contains(t, err.Error(), want, src)
+		}
+	}
+}
+
+func TestScript_StringSubBounds(t *testing.T) {
+	tests := map[string]string{
+		"return string.sub('abc', -1e300)":    "abc",
+		"return string.sub('abc', 1e300)":     "",
+		"return string.sub('abc', 2, 1e300)":  "bc",
+		"return string.sub('abc', 1, -1e300)": "",
+		"return string.sub('abc', -2)":        "bc",
+		"return string.sub('abc', 0, 2)":      "ab",
+		"return string.sub('abc', 3, 2)":      "",
+		"return string.sub('', 1, 1)":         "",
+		"return string.rep('ab', 3)":          "ababab",
+		"return string.rep('ab', -3)":         "",
+	}
+	for src, want := range tests {
+		got, err := run(t, newMapEnv(), src, nil, nil)
+		require.NoError(t, err, src)
+		assert.Equal(t, want, got, src)
+	}
+}
+
+type panicEnv struct{ *mapEnv }
+
+func (panicEnv) Get(string) (string, bool) { panic("boom") }
+
+func TestScript_PanicIsError(t *testing.T) {
+	// A panic in the interpreter must not escape into the FSM.
+	_, err := run(t, panicEnv{newMapEnv()}, "return cache.get(KEYS[1])", []string{"k"}, nil)
+	assert.ErrorIs(t, err, coreerrors.ErrScript)
+	assert.Contains(t, err.Error(), "boom")
+}
+
+func TestScript_Deterministic(t *testing.T) {
+	// The same script over the same state gives the same result and effects,
+	// including when it runs out of steps.
+	src := `
+local n = tonumber(cache.get(KEYS[1]) or '0')
+while true do
+  n = n + 1
+  cache.set(KEYS[1], n)
+end
+`
+	s, err := Compile(src)
+	require.NoError(t, err)
+	a, b := newMapEnv(), newMapEnv()
+	_, errA := s.Run(a, []string{"k"}, nil)
+	_, errB := s.Run(b, []string{"k"}, nil)
+	assert.Equal(t, errA, errB)
+	assert.Equal(t, a.data, b.data)
+}
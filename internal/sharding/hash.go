@@ -0,0 +1,80 @@
+package sharding
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"math/bits"
+	"strings"
+
+	"github.com/cespare/xxhash/v2"
+)
+
+// HashNames lists the hash functions ParseHash accepts.
+var HashNames = []string{"crc32", "xxhash", "murmur3"}
+
+// ParseHash returns the named hash function: "crc32" (the default),
+// "xxhash" or "murmur3". CRC32 spreads short, similar keys poorly; xxhash and
+// murmur3 place them far more evenly. Every user of a ring must agree on its
+// hash, since changing it moves almost every key.
+func ParseHash(name string) (Hash, error) {
+	switch strings.ToLower(name) {
+	case "", "crc32":
+		return crc32.ChecksumIEEE, nil
+	case "xxhash":
+		return XXHash, nil
+	case "murmur3":
+		return Murmur3, nil
+	default:
+		return nil, fmt.Errorf("unknown ring hash '%s' (want one of %s)", name, strings.Join(HashNames, ", "))
+	}
+}
+
+// XXHash is 64-bit xxHash folded to 32 bits.
+func XXHash(data []byte) uint32 {
+	h := xxhash.Sum64(data)
+	return uint32(h) ^ uint32(h>>32)
+}
+
+// Murmur3 is the 32-bit MurmurHash3 (x86_32) with seed 0.
+func Murmur3(data []byte) uint32 {
+	const (
+		c1 = 0xcc9e2d51
+		c2 = 0x1b873593
+	)
+	var h uint32
+	n := len(data)
+	for ; len(data) >= 4; data = data[4:] {
+		k := binary.LittleEndian.Uint32(data)
+		k *= c1
+		k = bits.RotateLeft32(k, 15)
+		k *= c2
+		h ^= k
+		h = bits.RotateLeft32(h, 13)
+		h = h*5 + 0xe6546b64
+	}
+
+	var k uint32
+	switch len(data) {
+	case 3:
+		k ^= uint32(data[2]) << 16
+		fallthrough
+	case 2:
+		k ^= uint32(data[1]) << 8
+		fallthrough
+	case 1:
+		k ^= uint32(data[0])
+		k *= c1
+		k = bits.RotateLeft32(k, 15)
+		k *= c2
+		h ^= k
+	}
+
+	h ^= uint32(n)
+	h ^= h >> 16
+	h *= 0x85ebca6b
+	h ^= h >> 13
+	h *= 0xc2b2ae35
+	h ^= h >> 16
+	return h
+}
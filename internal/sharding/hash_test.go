@@ -0,0 +1,47 @@
+package sharding
+
+import (
+	"hash/crc32"
+	"testing"
+)
+
+func TestMurmur3(t *testing.T) {
+	// Reference values of MurmurHash3_x86_32 with seed 0.
+	tests := []struct {
+		in   string
+		want uint32
+	}{
+		{"", 0},
+		{"a", 0x3c2569b2},
+		{"ab", 0x9bbfd75f},
+		{"abc", 0xb3dd93fa},
+		{"abcd", 0x43ed676a},
+		{"hello", 0x248bfa47},
+		{"The quick brown fox jumps over the lazy dog", 0x2e4ff723},
+	}
+	for _, tt := range tests {
+		if got := Murmur3([]byte(tt.in)); got != tt.want {
+			t.Errorf("Murmur3(%q) = %#x, want %#x", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestParseHash(t *testing.T) {
+	for _, name := range append(HashNames, "", "XXHash") {
+		if fn, err := ParseHash(name); err != nil || fn == nil {
+			t.Errorf("ParseHash(%q): %v", name, err)
+		}
+	}
+	if fn, _ := ParseHash(""); fn([]byte("key")) != crc32.ChecksumIEEE([]byte("key")) {
+		t.Error("expected CRC32 by default")
+	}
+	if _, err := ParseHash("md5"); err == nil {
+		t.Error("expected an error for an unknown hash")
+	}
+
+	m := New(100, XXHash)
+	m.Add("node1", "node2")
+	if node := m.Get("my_key"); node == "" {
+		t.Fatal("expected to get a node")
+	}
+}
@@ -2,6 +2,7 @@ package sharding
 
 import (
 	"hash/crc32"
+	"slices"
 	"sort"
 	"strconv"
 	"sync"
@@ -16,7 +17,20 @@ type Map struct {
 	virtualNodes int
 	keys         []int // Sorted
 	hashMap      map[int]string
+	nodeHashes   map[string][]int // points owned by each node
 	mu           sync.RWMutex
+
+	subMu       sync.Mutex
+	subscribers map[int]func(Change)
+	nextSub     int
+}
+
+// Change describes a change to the ring: the nodes added and removed, and the
+// nodes on the ring afterwards.
+type Change struct {
+	Added   []string
+	Removed []string
+	Nodes   []string
 }
 
 // New creates a new Map object
@@ -25,6 +39,8 @@ func New(virtualNodes int, fn Hash) *Map {
 		virtualNodes: virtualNodes,
 		hash:         fn,
 		hashMap:      make(map[int]string),
+		nodeHashes:   make(map[string][]int),
+		subscribers:  make(map[int]func(Change)),
 	}
 	if m.hash == nil {
 		m.hash = crc32.ChecksumIEEE
@@ -32,18 +48,37 @@ func New(virtualNodes int, fn Hash) *Map {
 	return m
 }
 
-// Add adds some keys to the hash.
+// Add adds some keys to the hash. Keys already on the ring are ignored. A
+// virtual node whose hash collides with an existing point is dropped, so the
+// point keeps its owner.
 func (m *Map) Add(keys ...string) {
 	m.mu.Lock()
-	defer m.mu.Unlock()
+	var added []string
 	for _, key := range keys {
+		if _, ok := m.nodeHashes[key]; ok {
+			continue
+		}
+		hashes := make([]int, 0, m.virtualNodes)
 		for i := 0; i < m.virtualNodes; i++ {
 			hash := int(m.hash([]byte(strconv.Itoa(i) + key)))
+			if _, taken := m.hashMap[hash]; taken {
+				continue
+			}
 			m.keys = append(m.keys, hash)
 			m.hashMap[hash] = key
+			hashes = append(hashes, hash)
 		}
+		sort.Ints(hashes)
+		m.nodeHashes[key] = hashes
+		added = append(added, key)
 	}
 	sort.Ints(m.keys)
+	nodes := m.nodesLocked()
+	m.mu.Unlock()
+
+	if len(added) > 0 {
+		m.notify(Change{Added: added, Nodes: nodes})
+	}
 }
 
 // Get gets the closest item in the hash to the provided key.
@@ -69,23 +104,111 @@ func (m *Map) Get(key string) string {
 	return m.hashMap[m.keys[idx]]
 }
 
-// Remove removes a key from the hash.
+// GetN returns up to n distinct nodes for key: its owner, as returned by Get,
+// followed by the owners of the next points clockwise, skipping virtual nodes
+// of nodes already chosen. It is the placement of a partition replicated n
+// times. Fewer than n nodes are returned if the ring has fewer.
+func (m *Map) GetN(key string, n int) []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	n = min(n, len(m.nodeHashes))
+	if n <= 0 || len(m.keys) == 0 {
+		return nil
+	}
+
+	hash := int(m.hash([]byte(key)))
+	start := sort.SearchInts(m.keys, hash)
+	nodes := make([]string, 0, n)
+	for i := 0; i < len(m.keys) && len(nodes) < n; i++ {
+		node := m.hashMap[m.keys[(start+i)%len(m.keys)]]
+		if !slices.Contains(nodes, node) {
+			nodes = append(nodes, node)
+		}
+	}
+	return nodes
+}
+
+// Remove removes a key from the hash. The first of its virtual nodes is found
+// by binary search and the rest of the ring is compacted in one pass, instead
+// of being rehashed and sorted again: O(N·V) for N nodes of V virtual nodes,
+// like a single copy of the ring, rather than the O(N·V log(N·V)) of a rebuild.
+// The ring stays a sorted slice so that Get, which runs on every request, is
+// a binary search over contiguous memory; membership changes are rare.
 func (m *Map) Remove(key string) {
 	m.mu.Lock()
-	defer m.mu.Unlock()
+	hashes, ok := m.nodeHashes[key]
+	if !ok {
+		m.mu.Unlock()
+		return
+	}
+	// hashes is sorted, like keys, so both can be walked together.
+	var w int
+	if len(hashes) > 0 {
+		w, _ = slices.BinarySearch(m.keys, hashes[0])
+	} else {
+		w = len(m.keys)
+	}
+	j := 0
+	for _, hash := range m.keys[w:] {
+		if j < len(hashes) && hash == hashes[j] {
+			j++
+			continue
+		}
+		m.keys[w] = hash
+		w++
+	}
+	m.keys = m.keys[:w]
+	for _, hash := range hashes {
+		delete(m.hashMap, hash)
+	}
+	delete(m.nodeHashes, key)
+	nodes := m.nodesLocked()
+	m.mu.Unlock()
 
-	// Create a new hashMap without the removed key
-	newHashMap := make(map[int]string)
-	var newKeys []int
+	m.notify(Change{Removed: []string{key}, Nodes: nodes})
+}
 
-	for k, v := range m.hashMap {
-		if v != key {
-			newHashMap[k] = v
-			newKeys = append(newKeys, k)
-		}
+// Nodes returns the keys on the ring, sorted.
+func (m *Map) Nodes() []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	return m.nodesLocked()
+}
+
+func (m *Map) nodesLocked() []string {
+	nodes := make([]string, 0, len(m.nodeHashes))
+	for node := range m.nodeHashes {
+		nodes = append(nodes, node)
 	}
+	sort.Strings(nodes)
+	return nodes
+}
 
-	m.hashMap = newHashMap
-	m.keys = newKeys
-	sort.Ints(m.keys)
+// Subscribe calls fn after every change to the ring, so that routers and
+// clients can react to topology updates. Calls are made synchronously by Add
+// and Remove, outside the ring's lock, so fn may read the ring. The returned
+// function unsubscribes.
+func (m *Map) Subscribe(fn func(Change)) (unsubscribe func()) {
+	m.subMu.Lock()
+	defer m.subMu.Unlock()
+	id := m.nextSub
+	m.nextSub++
+	m.subscribers[id] = fn
+	return func() {
+		m.subMu.Lock()
+		defer m.subMu.Unlock()
+		delete(m.subscribers, id)
+	}
+}
+
+func (m *Map) notify(c Change) {
+	m.subMu.Lock()
+	subs := make([]func(Change), 0, len(m.subscribers))
+	for _, fn := range m.subscribers {
+		subs = append(subs, fn)
+	}
+	m.subMu.Unlock()
+	for _, fn := range subs {
+		fn(c)
+	}
 }
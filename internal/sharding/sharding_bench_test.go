@@ -0,0 +1,57 @@
+package sharding
+
+import (
+	"fmt"
+	"testing"
+)
+
+// BenchmarkMap_Remove measures removing one node from a ring of 100.
+func BenchmarkMap_Remove(b *testing.B) {
+	nodes := make([]string, 100)
+	for i := range nodes {
+		nodes[i] = fmt.Sprintf("node%d", i)
+	}
+	m := New(100, XXHash)
+	m.Add(nodes...)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		node := nodes[i%len(nodes)]
+		m.Remove(node)
+		b.StopTimer()
+		m.Add(node)
+		b.StartTimer()
+	}
+}
+
+// BenchmarkMap_Get measures lookups with each hash function and reports the
+// skew of 100k short keys over 5 nodes: the busiest node's share relative to
+// an even split (1.0 is perfect).
+func BenchmarkMap_Get(b *testing.B) {
+	const keys = 100000
+	for _, name := range HashNames {
+		b.Run(name, func(b *testing.B) {
+			fn, err := ParseHash(name)
+			if err != nil {
+				b.Fatal(err)
+			}
+			m := New(100, fn)
+			m.Add("node1", "node2", "node3", "node4", "node5")
+
+			counts := make(map[string]int)
+			for i := 0; i < keys; i++ {
+				counts[m.Get(fmt.Sprintf("k%d", i))]++
+			}
+			var busiest int
+			for _, n := range counts {
+				busiest = max(busiest, n)
+			}
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				m.Get(fmt.Sprintf("k%d", i%keys))
+			}
+			b.ReportMetric(float64(busiest)/(keys/5), "skew")
+		})
+	}
+}
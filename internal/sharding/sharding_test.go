@@ -1,6 +1,7 @@
 package sharding
 
 import (
+	"reflect"
 	"strconv"
 	"testing"
 )
@@ -88,3 +89,109 @@ func calculateStdDev(counts map[string]int, total, n int) float64 {
 	}
 	return (sumSquares / float64(n)) // Simplified variance (not sqrt for comparison but named stddev for clarity)
 }
+
+func TestMap_Remove(t *testing.T) {
+	m := New(50, nil)
+	m.Add("node1", "node2", "node3")
+
+	before := make(map[string]string)
+	for i := 0; i < 1000; i++ {
+		key := "key_" + strconv.Itoa(i)
+		before[key] = m.Get(key)
+	}
+
+	m.Remove("node2")
+	if len(m.keys) != len(m.hashMap) || len(m.keys) != len(m.nodeHashes["node1"])+len(m.nodeHashes["node3"]) {
+		t.Fatalf("ring has %d points and %d owners after removal", len(m.keys), len(m.hashMap))
+	}
+	for key, node := range before {
+		got := m.Get(key)
+		if got == "node2" {
+			t.Fatalf("%s still maps to the removed node", key)
+		}
+		// Only keys of the removed node move.
+		if node != "node2" && got != node {
+			t.Errorf("%s moved from %s to %s", key, node, got)
+		}
+	}
+
+	m.Remove("node2") // not on the ring: no-op
+	m.Remove("node1")
+	m.Remove("node3")
+	if got := m.Get("key_1"); got != "" {
+		t.Errorf("expected an empty ring, got %s", got)
+	}
+}
+
+func TestMap_Subscribe(t *testing.T) {
+	m := New(3, nil)
+	var changes []Change
+	unsubscribe := m.Subscribe(func(c Change) {
+		// The ring is already updated and may be read.
+		if len(m.Nodes()) != len(c.Nodes) {
+			t.Errorf("callback saw %v, change has %v", m.Nodes(), c.Nodes)
+		}
+		changes = append(changes, c)
+	})
+
+	m.Add("node1", "node2")
+	m.Add("node1") // already present: no change
+	m.Remove("node1")
+	m.Remove("missing")
+	unsubscribe()
+	m.Add("node3")
+
+	want := []Change{
+		{Added: []string{"node1", "node2"}, Nodes: []string{"node1", "node2"}},
+		{Removed: []string{"node1"}, Nodes: []string{"node2"}},
+	}
+	if !reflect.DeepEqual(changes, want) {
+		t.Errorf("expected %+v, got %+v", want, changes)
+	}
+}
+
+func TestMap_RemoveWithoutVirtualNodes(t *testing.T) {
+	m := New(0, nil)
+	m.Add("node1")
+	m.Remove("node1")
+	if nodes := m.Nodes(); len(nodes) != 0 {
+		t.Errorf("expected no nodes, got %v", nodes)
+	}
+}
+
+func TestMap_GetN(t *testing.T) {
+	m := New(50, nil)
+	if got := m.GetN("key", 2); got != nil {
+		t.Errorf("expected no nodes on an empty ring, got %v", got)
+	}
+	m.Add("node1", "node2", "node3", "node4")
+
+	for i := 0; i < 200; i++ {
+		key := "key_" + strconv.Itoa(i)
+		nodes := m.GetN(key, 3)
+		if len(nodes) != 3 {
+			t.Fatalf("expected 3 nodes for %s, got %v", key, nodes)
+		}
+		if nodes[0] != m.Get(key) {
+			t.Errorf("expected %s's owner %s first, got %v", key, m.Get(key), nodes)
+		}
+		seen := make(map[string]bool)
+		for _, node := range nodes {
+			if seen[node] {
+				t.Fatalf("duplicate node in %v", nodes)
+			}
+			seen[node] = true
+		}
+		// Placement is a prefix: a smaller replica group is contained in a larger one.
+		if !reflect.DeepEqual(m.GetN(key, 2), nodes[:2]) {
+			t.Errorf("expected %v to start with GetN(2)", nodes)
+		}
+	}
+
+	if got := m.GetN("key", 10); len(got) != 4 {
+		t.Errorf("expected all 4 nodes, got %v", got)
+	}
+	if got := m.GetN("key", 0); got != nil {
+		t.Errorf("expected no nodes for n=0, got %v", got)
+	}
+}
@@ -0,0 +1,461 @@
+package store
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"distributed-cache-service/internal/core/ports"
+)
+
+// Append-only file (AOF)
+//
+// The AOF gives a store durability independent of Raft, so a single node can
+// recover its data after a restart. Every mutation is appended as a record:
+//
+//	record: op (1 byte) | key length (uvarint) | key | [value length (uvarint) | value | expiration (varint)]
+//
+// Sorted set records carry a member and its score (Z) or a score range (R),
+// with scores as big-endian IEEE 754 bits:
+//
+//	Z: op | key | member length (uvarint) | member | score (8 bytes)
+//	R: op | key | min (8 bytes) | max (8 bytes)
+//
+// Set records carry the absolute expiration, so replay restores the original
+// deadline rather than restarting the TTL. A record torn by a crash is
+// detected on replay and truncated away.
+
+// FsyncPolicy controls how often the AOF is flushed to stable storage.
+type FsyncPolicy int
+
+const (
+	// FsyncAlways fsyncs after every write. Safest and slowest.
+	FsyncAlways FsyncPolicy = iota
+	// FsyncEverySec fsyncs once per second; at most one second of writes can be lost.
+	FsyncEverySec
+	// FsyncNo leaves flushing to the operating system.
+	FsyncNo
+)
+
+// ParseFsyncPolicy converts a policy name (always, everysec, no) into an FsyncPolicy.
+func ParseFsyncPolicy(name string) (FsyncPolicy, error) {
+	switch strings.ToLower(name) {
+	case "always":
+		return FsyncAlways, nil
+	case "everysec", "":
+		return FsyncEverySec, nil
+	case "no":
+		return FsyncNo, nil
+	default:
+		return 0, fmt.Errorf("unknown fsync policy %q", name)
+	}
+}
+
+const (
+	aofOpSet       byte = 'S'
+	aofOpDelete    byte = 'D'
+	aofOpZAdd      byte = 'Z'
+	aofOpZRemRange byte = 'R'
+
+	// aofMinRewriteSize is the file size below which automatic rewrites are skipped.
+	aofMinRewriteSize = 64 << 20
+)
+
+// aof is an append-only log of store mutations.
+type aof struct {
+	mu     sync.Mutex
+	path   string
+	f      *os.File
+	w      *bufio.Writer
+	policy FsyncPolicy
+	size   int64
+	buf    []byte // scratch buffer for encoding records
+
+	// rewriting is set while a background rewrite runs; appends are mirrored
+	// into rewriteBuf so they can be replayed onto the new file.
+	rewriteMu  sync.Mutex // serializes rewrites
+	rewriting  bool
+	rewriteBuf []byte
+	baseSize   int64 // size after the last rewrite, used for auto-rewrite
+
+	stop chan struct{}
+	done chan struct{}
+}
+
+func openAOF(path string, policy FsyncPolicy) (*aof, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR|os.O_APPEND, 0600)
+	if err != nil {
+		return nil, err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return &aof{
+		path:     path,
+		f:        f,
+		w:        bufio.NewWriter(f),
+		policy:   policy,
+		size:     info.Size(),
+		baseSize: info.Size(),
+	}, nil
+}
+
+// replay reads every record from the start of the file and passes it to fn.
+// A truncated trailing record (e.g. from a crash mid-write) is cut off so
+// subsequent appends start on a record boundary.
+func (a *aof) replay(fn func(rec *aofRecord)) error {
+	r, err := os.Open(a.path)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	cr := &countingReader{r: bufio.NewReader(r)}
+	var good int64
+	for {
+		rec, err := readAOFRecord(cr)
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			log.Printf("AOF %s: truncating torn record at offset %d", a.path, good)
+			if err := a.f.Truncate(good); err != nil {
+				return err
+			}
+			a.size = good
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("aof %s at offset %d: %w", a.path, good, err)
+		}
+		fn(rec)
+		good = cr.n
+	}
+}
+
+// aofRecord is a decoded AOF record. Which fields are set depends on op.
+type aofRecord struct {
+	op       byte
+	key      string
+	item     *Item              // aofOpSet
+	member   ports.ScoredMember // aofOpZAdd
+	min, max float64            // aofOpZRemRange
+}
+
+func readAOFRecord(r *countingReader) (*aofRecord, error) {
+	op, err := r.ReadByte()
+	if err != nil {
+		return nil, err
+	}
+	key, err := readString(r)
+	if err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	rec := &aofRecord{op: op, key: key}
+	switch op {
+	case aofOpDelete:
+	case aofOpSet:
+		value, err := readString(r)
+		if err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		exp, err := binary.ReadVarint(r)
+		if err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		rec.item = &Item{Value: value, Expiration: exp}
+	case aofOpZAdd:
+		if rec.member.Member, err = readString(r); err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		if rec.member.Score, err = readFloat(r); err != nil {
+			return nil, err
+		}
+	case aofOpZRemRange:
+		if rec.min, err = readFloat(r); err != nil {
+			return nil, err
+		}
+		if rec.max, err = readFloat(r); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unknown aof op %q", op)
+	}
+	return rec, nil
+}
+
+func readFloat(r io.Reader) (float64, error) {
+	var b [8]byte
+	if _, err := io.ReadFull(r, b[:]); err != nil {
+		return 0, unexpectedEOF(err)
+	}
+	return math.Float64frombits(binary.BigEndian.Uint64(b[:])), nil
+}
+
+func appendFloat(b []byte, f float64) []byte {
+	return binary.BigEndian.AppendUint64(b, math.Float64bits(f))
+}
+
+// appendSet logs a set of key to item.
+func (a *aof) appendSet(key string, item *Item) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.buf = appendAOFRecord(a.buf[:0], aofOpSet, key, item)
+	a.write(a.buf)
+}
+
+// appendDelete logs a removal of key.
+func (a *aof) appendDelete(key string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.buf = appendAOFRecord(a.buf[:0], aofOpDelete, key, nil)
+	a.write(a.buf)
+}
+
+// appendZAdd logs a sorted set member being added or rescored.
+func (a *aof) appendZAdd(key string, m ports.ScoredMember) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.buf = appendZAddRecord(a.buf[:0], key, m)
+	a.write(a.buf)
+}
+
+// appendZRemRange logs a removal of sorted set members by score.
+func (a *aof) appendZRemRange(key string, min, max float64) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.buf = appendAOFRecord(a.buf[:0], aofOpZRemRange, key, nil)
+	a.buf = appendFloat(appendFloat(a.buf, min), max)
+	a.write(a.buf)
+}
+
+func appendZAddRecord(b []byte, key string, m ports.ScoredMember) []byte {
+	b = appendAOFRecord(b, aofOpZAdd, key, nil)
+	b = binary.AppendUvarint(b, uint64(len(m.Member)))
+	b = append(b, m.Member...)
+	return appendFloat(b, m.Score)
+}
+
+func appendAOFRecord(b []byte, op byte, key string, item *Item) []byte {
+	b = append(b, op)
+	b = binary.AppendUvarint(b, uint64(len(key)))
+	b = append(b, key...)
+	if item != nil {
+		b = binary.AppendUvarint(b, uint64(len(item.Value)))
+		b = append(b, item.Value...)
+		b = binary.AppendVarint(b, item.Expiration)
+	}
+	return b
+}
+
+// write appends an encoded record. Caller must hold a.mu.
+func (a *aof) write(b []byte) {
+	if a.rewriting {
+		a.rewriteBuf = append(a.rewriteBuf, b...)
+	}
+	if _, err := a.w.Write(b); err != nil {
+		log.Printf("AOF write failed: %v", err)
+		return
+	}
+	a.size += int64(len(b))
+
+	if err := a.w.Flush(); err != nil {
+		log.Printf("AOF flush failed: %v", err)
+		return
+	}
+	if a.policy == FsyncAlways {
+		if err := a.f.Sync(); err != nil {
+			log.Printf("AOF fsync failed: %v", err)
+		}
+	}
+}
+
+// start runs the background loop that fsyncs (for FsyncEverySec) and triggers
+// automatic rewrites once the file has doubled since the last rewrite.
+func (a *aof) start(freeze func() *Frozen) {
+	a.stop = make(chan struct{})
+	a.done = make(chan struct{})
+	go func() {
+		defer close(a.done)
+		ticker := time.NewTicker(time.Second)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if a.policy == FsyncEverySec {
+					a.sync()
+				}
+				if a.needsRewrite() {
+					if err := a.rewrite(freeze); err != nil {
+						log.Printf("AOF rewrite failed: %v", err)
+					}
+				}
+			case <-a.stop:
+				return
+			}
+		}
+	}()
+}
+
+func (a *aof) sync() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if err := a.f.Sync(); err != nil {
+		log.Printf("AOF fsync failed: %v", err)
+	}
+}
+
+func (a *aof) needsRewrite() bool {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.size > aofMinRewriteSize && a.size > 2*a.baseSize
+}
+
+// beginRewrite starts mirroring appends into the rewrite buffer.
+// It must be called while the store lock is held, atomically with capturing the
+// view that will be written to the new file.
+func (a *aof) beginRewrite() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.rewriting = true
+	a.rewriteBuf = nil
+}
+
+// rewrite compacts the log by writing the current state as set records to a new
+// file. Appends that arrive while the new file is being written are buffered
+// and appended to it before it atomically replaces the old file.
+// freeze must capture the view and call beginRewrite under the store lock.
+func (a *aof) rewrite(freeze func() *Frozen) error {
+	a.rewriteMu.Lock()
+	defer a.rewriteMu.Unlock()
+
+	view := freeze()
+
+	tmpPath := a.path + ".rewrite"
+	tmp, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		a.abortRewrite()
+		return err
+	}
+	w := bufio.NewWriter(tmp)
+	var buf []byte
+	err = view.items.forEach(func(k string, v *Item) error {
+		buf = appendAOFRecord(buf[:0], aofOpSet, k, v)
+		_, err := w.Write(buf)
+		return err
+	})
+	for k, z := range view.zsets {
+		if err != nil {
+			break
+		}
+		err = z.forEach(func(member string, score float64) error {
+			buf = appendZAddRecord(buf[:0], k, ports.ScoredMember{Member: member, Score: score})
+			_, err := w.Write(buf)
+			return err
+		})
+	}
+	if err != nil {
+		tmp.Close()
+		a.abortRewrite()
+		return err
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.rewriting = false
+	pending := a.rewriteBuf
+	a.rewriteBuf = nil
+
+	if _, err := w.Write(pending); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	info, err := tmp.Stat()
+	if err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	if err := a.w.Flush(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, a.path); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(a.path, os.O_RDWR|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	a.f.Close()
+	a.f = f
+	a.w = bufio.NewWriter(f)
+	a.size = info.Size()
+	a.baseSize = info.Size()
+	return nil
+}
+
+func (a *aof) abortRewrite() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.rewriting = false
+	a.rewriteBuf = nil
+}
+
+// close stops the background loop and flushes and fsyncs the file.
+func (a *aof) close() error {
+	if a.stop != nil {
+		close(a.stop)
+		<-a.done
+	}
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if err := a.w.Flush(); err != nil {
+		return err
+	}
+	if err := a.f.Sync(); err != nil {
+		return err
+	}
+	return a.f.Close()
+}
+
+// countingReader tracks the number of bytes consumed so replay knows where the
+// last complete record ended.
+type countingReader struct {
+	r *bufio.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
+
+func (c *countingReader) ReadByte() (byte, error) {
+	b, err := c.r.ReadByte()
+	if err == nil {
+		c.n++
+	}
+	return b, err
+}
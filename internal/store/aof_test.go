@@ -0,0 +1,92 @@
+package store
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore_AOFRecovery(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.aof")
+
+	s := New()
+	require.NoError(t, s.OpenAOF(path, FsyncAlways))
+	s.Set("k1", "v1", 0)
+	s.Set("k2", "v2", time.Hour)
+	s.Set("k1", "v1-updated", 0)
+	s.Delete("k2")
+	s.Set("k3", "v3", 0)
+	require.NoError(t, s.CloseAOF())
+
+	recovered := New()
+	require.NoError(t, recovered.OpenAOF(path, FsyncAlways))
+	defer recovered.CloseAOF()
+
+	assert.Equal(t, 2, recovered.Len())
+	val, found := recovered.Get("k1")
+	assert.True(t, found)
+	assert.Equal(t, "v1-updated", val)
+	_, found = recovered.Get("k2")
+	assert.False(t, found)
+}
+
+func TestStore_AOFTruncatesTornRecord(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.aof")
+
+	s := New()
+	require.NoError(t, s.OpenAOF(path, FsyncAlways))
+	s.Set("k1", "v1", 0)
+	require.NoError(t, s.CloseAOF())
+
+	// Simulate a crash in the middle of appending a record
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0600)
+	require.NoError(t, err)
+	_, err = f.Write([]byte{aofOpSet, 10, 'p', 'a'})
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	recovered := New()
+	require.NoError(t, recovered.OpenAOF(path, FsyncAlways))
+	recovered.Set("k2", "v2", 0)
+	require.NoError(t, recovered.CloseAOF())
+
+	again := New()
+	require.NoError(t, again.OpenAOF(path, FsyncNo))
+	defer again.CloseAOF()
+	assert.Equal(t, 2, again.Len())
+}
+
+func TestStore_AOFRewrite(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.aof")
+
+	s := New()
+	require.NoError(t, s.OpenAOF(path, FsyncEverySec))
+	for i := 0; i < 100; i++ {
+		s.Set("key", "value", 0)
+	}
+	before, _ := os.Stat(path)
+	require.NoError(t, s.RewriteAOF())
+	after, _ := os.Stat(path)
+	assert.Less(t, after.Size(), before.Size())
+
+	s.Set("other", "value", 0)
+	require.NoError(t, s.CloseAOF())
+
+	recovered := New()
+	require.NoError(t, recovered.OpenAOF(path, FsyncNo))
+	defer recovered.CloseAOF()
+	assert.Equal(t, 2, recovered.Len())
+}
+
+func TestParseFsyncPolicy(t *testing.T) {
+	p, err := ParseFsyncPolicy("always")
+	assert.NoError(t, err)
+	assert.Equal(t, FsyncAlways, p)
+
+	_, err = ParseFsyncPolicy("sometimes")
+	assert.Error(t, err)
+}
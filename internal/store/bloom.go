@@ -0,0 +1,131 @@
+package store
+
+import (
+	"math"
+	"sync/atomic"
+
+	"github.com/cespare/xxhash/v2"
+)
+
+// bloomFilter is a counting Bloom filter over the keys of a store. Slots are
+// 8-bit counters, four to a word, so that keys can be removed as well as
+// added; a counter that saturates is never decremented again, which can only
+// cause false positives. Lookups are lock-free. Updates must be serialized by
+// the caller, which the store does with its lock.
+type bloomFilter struct {
+	words    []atomic.Uint32
+	slots    uint64
+	hashes   int
+	capacity int // keys the filter was sized for
+}
+
+// newBloomFilter sizes a filter for capacity keys at a false positive rate of fp.
+func newBloomFilter(capacity int, fp float64) *bloomFilter {
+	capacity = max(capacity, 1)
+	slots := uint64(math.Ceil(-float64(capacity) * math.Log(fp) / (math.Ln2 * math.Ln2)))
+	slots = max(slots, 64)
+	hashes := int(math.Round(float64(slots) / float64(capacity) * math.Ln2))
+	return &bloomFilter{
+		words:    make([]atomic.Uint32, (slots+3)/4),
+		slots:    slots,
+		hashes:   max(hashes, 1),
+		capacity: capacity,
+	}
+}
+
+// slot returns the i-th slot of key, by double hashing.
+func (b *bloomFilter) slot(h uint64, i int) uint64 {
+	h1, h2 := h&math.MaxUint32, h>>32|1
+	return (h1 + uint64(i)*h2) % b.slots
+}
+
+// mayContain reports false only if key was never added, or has been removed.
+func (b *bloomFilter) mayContain(key string) bool {
+	h := xxhash.Sum64String(key)
+	for i := 0; i < b.hashes; i++ {
+		s := b.slot(h, i)
+		if b.words[s/4].Load()>>(8*(s%4))&0xff == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+func (b *bloomFilter) add(key string) {
+	b.update(key, 1)
+}
+
+func (b *bloomFilter) remove(key string) {
+	b.update(key, -1)
+}
+
+func (b *bloomFilter) update(key string, delta int) {
+	h := xxhash.Sum64String(key)
+	for i := 0; i < b.hashes; i++ {
+		s := b.slot(h, i)
+		w, shift := &b.words[s/4], 8*(s%4)
+		word := w.Load()
+		switch counter := word >> shift & 0xff; {
+		case counter == 0xff:
+			// Saturated: the true count is unknown.
+		case delta > 0:
+			w.Store(word + 1<<shift)
+		case counter > 0:
+			w.Store(word - 1<<shift)
+		}
+	}
+}
+
+// DefaultBloomFalsePositiveRate is the false positive rate the -bloom_keys
+// flag sizes the filter for.
+const DefaultBloomFalsePositiveRate = 0.01
+
+// WithBloomFilter keeps a counting Bloom filter of the store's keys, sized for
+// expectedKeys at the given false positive rate, and doubled whenever the
+// store outgrows it. Get, GetStale and TTL consult it before taking the lock,
+// so lookups of keys that do not exist return at once, without contending
+// with writers or touching the eviction policy. Sorted sets are not tracked.
+func WithBloomFilter(expectedKeys int, falsePositiveRate float64) Option {
+	return func(s *Store) {
+		s.bloomFP = falsePositiveRate
+		s.bloom.Store(newBloomFilter(expectedKeys, falsePositiveRate))
+	}
+}
+
+// absent reports whether the Bloom filter, if any, rules key out.
+func (s *Store) absent(key string) bool {
+	b := s.bloom.Load()
+	return b != nil && !b.mayContain(key)
+}
+
+// bloomAdd records a new key, growing the filter if the store has outgrown
+// it. It is called before the key is inserted, so that a concurrent lookup
+// never finds the key in the table but not in the filter. Caller must hold s.mu.
+func (s *Store) bloomAdd(key string) {
+	b := s.bloom.Load()
+	if b == nil {
+		return
+	}
+	if s.items.len() >= b.capacity {
+		b = s.newBloom(s.items, 2*b.capacity)
+		s.bloom.Store(b)
+	}
+	b.add(key)
+}
+
+// bloomRemove forgets a key that has been deleted. Caller must hold s.mu.
+func (s *Store) bloomRemove(key string) {
+	if b := s.bloom.Load(); b != nil {
+		b.remove(key)
+	}
+}
+
+// newBloom returns a filter of the keys in items with room for at least
+// capacity keys.
+func (s *Store) newBloom(items table, capacity int) *bloomFilter {
+	b := newBloomFilter(max(capacity, items.len()), s.bloomFP)
+	items.keys(func(k string, _ int64) {
+		b.add(k)
+	})
+	return b
+}
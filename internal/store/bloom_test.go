@@ -0,0 +1,137 @@
+package store
+
+import (
+	"bytes"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestBloomFilter(t *testing.T) {
+	b := newBloomFilter(1000, 0.01)
+	for i := 0; i < 1000; i++ {
+		b.add(fmt.Sprintf("key-%d", i))
+	}
+	for i := 0; i < 1000; i++ {
+		if !b.mayContain(fmt.Sprintf("key-%d", i)) {
+			t.Fatalf("false negative for key-%d", i)
+		}
+	}
+
+	var positives int
+	for i := 0; i < 10000; i++ {
+		if b.mayContain(fmt.Sprintf("other-%d", i)) {
+			positives++
+		}
+	}
+	if rate := float64(positives) / 10000; rate > 0.03 {
+		t.Errorf("false positive rate %.3f, sized for 0.01", rate)
+	}
+
+	// Removed keys are ruled out again; the others are unaffected.
+	for i := 0; i < 500; i++ {
+		b.remove(fmt.Sprintf("key-%d", i))
+	}
+	var remaining int
+	for i := 0; i < 500; i++ {
+		if b.mayContain(fmt.Sprintf("key-%d", i)) {
+			remaining++
+		}
+	}
+	if remaining > 25 {
+		t.Errorf("%d of 500 removed keys still reported", remaining)
+	}
+	for i := 500; i < 1000; i++ {
+		if !b.mayContain(fmt.Sprintf("key-%d", i)) {
+			t.Fatalf("false negative for key-%d after removals", i)
+		}
+	}
+}
+
+func TestBloomFilterSaturation(t *testing.T) {
+	b := newBloomFilter(1, 0.5)
+	for i := 0; i < 300; i++ {
+		b.add("k")
+	}
+	for i := 0; i < 299; i++ {
+		b.remove("k")
+	}
+	// The counters saturated, so the key can no longer be ruled out.
+	if !b.mayContain("k") {
+		t.Error("false negative after saturation")
+	}
+}
+
+func TestStore_BloomFilter(t *testing.T) {
+	s := New(WithBloomFilter(8, 0.01))
+	for i := 0; i < 1000; i++ {
+		s.Set(fmt.Sprintf("key-%d", i), "v", 0)
+	}
+	if got := s.bloom.Load().capacity; got < 1000 {
+		t.Errorf("expected the filter to grow past 1000 keys, has room for %d", got)
+	}
+	for i := 0; i < 1000; i++ {
+		if _, ok := s.Get(fmt.Sprintf("key-%d", i)); !ok {
+			t.Fatalf("key-%d not found", i)
+		}
+	}
+
+	s.Delete("key-1")
+	if _, ok := s.Get("key-1"); ok {
+		t.Error("expected key-1 to be deleted")
+	}
+	s.Set("key-1", "again", time.Minute)
+	if v, ok := s.Get("key-1"); !ok || v != "again" {
+		t.Errorf("expected key-1 to be set again, got %q %v", v, ok)
+	}
+	if _, ok := s.TTL("key-1"); !ok {
+		t.Error("expected a TTL for key-1")
+	}
+	if _, _, ok := s.GetStale("missing"); ok {
+		t.Error("expected no stale value for a missing key")
+	}
+
+	// Restore rebuilds the filter from the snapshot.
+	src := New()
+	src.Set("restored", "v", 0)
+	var buf bytes.Buffer
+	if err := src.Snapshot(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.Restore(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := s.Get("restored"); !ok {
+		t.Error("expected the restored key")
+	}
+	if _, ok := s.Get("key-2"); ok {
+		t.Error("expected keys from before the restore to be gone")
+	}
+	if b := s.bloom.Load(); b.mayContain("restored") == false {
+		t.Error("expected the restored key in the filter")
+	}
+}
+
+func TestStore_BloomFilterConcurrent(t *testing.T) {
+	s := New(WithBloomFilter(16, 0.01), WithCapacity(500), WithPolicy(nil))
+	var wg sync.WaitGroup
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < 1000; i++ {
+				key := fmt.Sprintf("w%d-%d", w, i)
+				s.Set(key, "v", 0)
+				if _, ok := s.Get(key); !ok {
+					t.Errorf("%s not found after Set", key)
+					return
+				}
+				if i%3 == 0 {
+					s.Delete(key)
+				}
+			}
+		}(w)
+	}
+	wg.Wait()
+}
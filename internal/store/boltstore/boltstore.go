@@ -0,0 +1,592 @@
+// Package boltstore implements ports.SnapshotStorage on top of BoltDB, an
+// embedded on-disk B+tree. It trades the speed of the in-memory store for the
+// ability to hold datasets larger than RAM and to survive restarts on its own.
+//
+// Capacity-based eviction is not supported; the dataset is bounded by disk space.
+// Expired keys are filtered on read and purged by DeleteExpired, or by
+// StartCleanup when the store is used without Raft.
+package boltstore
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"distributed-cache-service/internal/core/ports"
+	"distributed-cache-service/internal/store"
+
+	"github.com/boltdb/bolt"
+)
+
+// ensure implementation
+var (
+	_ ports.SnapshotStorage = (*Store)(nil)
+	_ ports.ScanStorage     = (*Store)(nil)
+)
+
+// The items are kept in one of two buckets, itemsBucket or restoreBucket, and
+// metaBucket's liveKey names the one in use. Restore fills the other and
+// switches to it in a single transaction, so a failed restore leaves the store
+// as it was. Databases without a meta bucket keep their items in itemsBucket.
+var (
+	itemsBucket   = []byte("items")
+	restoreBucket = []byte("items.restore")
+	metaBucket    = []byte("meta")
+	liveKey       = []byte("live")
+)
+
+// initialMmapSize reserves address space up front. Bolt must take an exclusive
+// lock to grow its mmap, which waits for open read transactions; a large initial
+// mapping keeps snapshot views from stalling writers while the file grows.
+const initialMmapSize = 1 << 30
+
+// restoreBatchSize bounds the number of keys written per transaction during Restore.
+const restoreBatchSize = 10000
+
+// Store is a BoltDB-backed key-value store.
+// All public methods are safe for concurrent use.
+type Store struct {
+	db *bolt.DB
+
+	cleanupMu   sync.Mutex
+	stopCleanup chan struct{}
+}
+
+// Open opens (or creates) a BoltDB file at path.
+func Open(path string) (*Store, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second, InitialMmapSize: initialMmapSize})
+	if err != nil {
+		return nil, fmt.Errorf("open bolt store: %w", err)
+	}
+	if err := db.Update(func(tx *bolt.Tx) error {
+		live := liveBucket(tx)
+		if _, err := tx.CreateBucketIfNotExists(live); err != nil {
+			return err
+		}
+		// A restore interrupted by a crash leaves its bucket behind.
+		if staging := otherBucket(live); tx.Bucket(staging) != nil {
+			return tx.DeleteBucket(staging)
+		}
+		return nil
+	}); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return &Store{db: db}, nil
+}
+
+// Close stops background cleanup and closes the database.
+func (s *Store) Close() error {
+	s.StartCleanup(0)
+	return s.db.Close()
+}
+
+// Get retrieves the value for key if it exists and has not expired.
+func (s *Store) Get(key string) (string, bool) {
+	return s.GetAt(key, time.Now())
+}
+
+// GetAt is like Get but judges expiry at now rather than by the local clock.
+func (s *Store) GetAt(key string, now time.Time) (string, bool) {
+	var (
+		value string
+		found bool
+	)
+	err := s.db.View(func(tx *bolt.Tx) error {
+		raw := items(tx).Get([]byte(key))
+		if raw == nil {
+			return nil
+		}
+		item, err := decodeItem(raw)
+		if err != nil {
+			return err
+		}
+		if expired(item, now.UnixNano()) {
+			return nil
+		}
+		value, found = item.Value, true
+		return nil
+	})
+	if err != nil {
+		log.Printf("bolt store get %q: %v", key, err)
+		return "", false
+	}
+	return value, found
+}
+
+// Set stores value under key. A ttl of 0 means the item never expires.
+func (s *Store) Set(key, value string, ttl time.Duration) {
+	var expiresAt time.Time
+	if ttl > 0 {
+		expiresAt = time.Now().Add(ttl)
+	}
+	s.SetExpiresAt(key, value, expiresAt)
+}
+
+// SetExpiresAt is like Set but with an absolute expiration time; the zero time
+// means the item never expires.
+func (s *Store) SetExpiresAt(key, value string, expiresAt time.Time) {
+	item := &store.Item{Value: value, Expiration: unixNano(expiresAt)}
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		return items(tx).Put([]byte(key), encodeItem(item))
+	})
+	if err != nil {
+		log.Printf("bolt store set %q: %v", key, err)
+	}
+}
+
+// Replace overwrites the value of a key existing and unexpired at now, keeping
+// its expiration. It reports false, storing nothing, if there is no such key.
+func (s *Store) Replace(key, value string, now time.Time) bool {
+	replaced := false
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		b := items(tx)
+		raw := b.Get([]byte(key))
+		if raw == nil {
+			return nil
+		}
+		item, err := decodeItem(raw)
+		if err != nil {
+			return err
+		}
+		if expired(item, now.UnixNano()) {
+			return nil
+		}
+		replaced = true
+		return b.Put([]byte(key), encodeItem(&store.Item{Value: value, Expiration: item.Expiration}))
+	})
+	if err != nil {
+		log.Printf("bolt store replace %q: %v", key, err)
+		return false
+	}
+	return replaced
+}
+
+// GetStale returns the value of key and when it expires, even if it already
+// has.
+func (s *Store) GetStale(key string) (string, time.Time, bool) {
+	var (
+		value     string
+		expiresAt time.Time
+		found     bool
+	)
+	err := s.db.View(func(tx *bolt.Tx) error {
+		raw := items(tx).Get([]byte(key))
+		if raw == nil {
+			return nil
+		}
+		item, err := decodeItem(raw)
+		if err != nil {
+			return err
+		}
+		if item.Expiration > 0 {
+			expiresAt = time.Unix(0, item.Expiration)
+		}
+		value, found = item.Value, true
+		return nil
+	})
+	if err != nil {
+		log.Printf("bolt store get %q: %v", key, err)
+		return "", time.Time{}, false
+	}
+	return value, expiresAt, found
+}
+
+// TTL returns the remaining lifetime of key, or 0 if it does not expire.
+// found is false if there is no such unexpired key.
+func (s *Store) TTL(key string) (time.Duration, bool) {
+	var (
+		ttl   time.Duration
+		found bool
+	)
+	err := s.db.View(func(tx *bolt.Tx) error {
+		raw := items(tx).Get([]byte(key))
+		if raw == nil {
+			return nil
+		}
+		item, err := decodeItem(raw)
+		if err != nil {
+			return err
+		}
+		now := time.Now().UnixNano()
+		if expired(item, now) {
+			return nil
+		}
+		if item.Expiration > 0 {
+			ttl = time.Duration(item.Expiration - now)
+		}
+		found = true
+		return nil
+	})
+	if err != nil {
+		log.Printf("bolt store ttl %q: %v", key, err)
+		return 0, false
+	}
+	return ttl, found
+}
+
+// ExpireAt sets a key existing and unexpired at now to expire at expiresAt, or
+// never if it is the zero time, keeping its value. It reports false if there is
+// no such key.
+func (s *Store) ExpireAt(key string, expiresAt, now time.Time) bool {
+	updated := false
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		b := items(tx)
+		raw := b.Get([]byte(key))
+		if raw == nil {
+			return nil
+		}
+		item, err := decodeItem(raw)
+		if err != nil {
+			return err
+		}
+		if expired(item, now.UnixNano()) {
+			return nil
+		}
+		item.Expiration = unixNano(expiresAt)
+		updated = true
+		return b.Put([]byte(key), encodeItem(item))
+	})
+	if err != nil {
+		log.Printf("bolt store expire %q: %v", key, err)
+		return false
+	}
+	return updated
+}
+
+// Delete removes key. Deleting a missing key is a no-op.
+func (s *Store) Delete(key string) {
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		return items(tx).Delete([]byte(key))
+	})
+	if err != nil {
+		log.Printf("bolt store delete %q: %v", key, err)
+	}
+}
+
+// Len returns the number of keys stored, including expired keys not yet purged.
+func (s *Store) Len() int {
+	var n int
+	_ = s.db.View(func(tx *bolt.Tx) error {
+		n = items(tx).Stats().KeyN
+		return nil
+	})
+	return n
+}
+
+// StartCleanup periodically deletes expired keys. Calling it again replaces the
+// running loop; an interval <= 0 stops it.
+func (s *Store) StartCleanup(interval time.Duration) {
+	s.cleanupMu.Lock()
+	defer s.cleanupMu.Unlock()
+
+	if s.stopCleanup != nil {
+		close(s.stopCleanup)
+		s.stopCleanup = nil
+	}
+	if interval <= 0 {
+		return
+	}
+
+	stop := make(chan struct{})
+	s.stopCleanup = stop
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				if err := s.deleteExpired(); err != nil {
+					log.Printf("bolt store cleanup: %v", err)
+				}
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
+
+// ExpiredKeys returns up to limit keys that had expired by now.
+func (s *Store) ExpiredKeys(now time.Time, limit int) []string {
+	var keys []string
+	at := now.UnixNano()
+	err := s.db.View(func(tx *bolt.Tx) error {
+		c := items(tx).Cursor()
+		for k, v := c.First(); k != nil && len(keys) < limit; k, v = c.Next() {
+			item, err := decodeItem(v)
+			if err != nil {
+				return err
+			}
+			if expired(item, at) {
+				keys = append(keys, string(k))
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("bolt store scan expired: %v", err)
+	}
+	return keys
+}
+
+// Scan returns up to limit unexpired keys starting with prefix and greater
+// than after, in order, with their values. It seeks to the first candidate,
+// so a page costs about as much as the keys it skips.
+func (s *Store) Scan(prefix, after string, limit int, match func(key string) bool) []ports.StoredEntry {
+	var entries []ports.StoredEntry
+	at := time.Now().UnixNano()
+	err := s.db.View(func(tx *bolt.Tx) error {
+		c := items(tx).Cursor()
+		start := prefix
+		if after > start {
+			start = after
+		}
+		for k, v := c.Seek([]byte(start)); k != nil && len(entries) < limit; k, v = c.Next() {
+			key := string(k)
+			if !strings.HasPrefix(key, prefix) {
+				break
+			}
+			if after != "" && key <= after || match != nil && !match(key) {
+				continue
+			}
+			item, err := decodeItem(v)
+			if err != nil {
+				return err
+			}
+			if expired(item, at) {
+				continue
+			}
+			entry := ports.StoredEntry{Key: key, Value: item.Value}
+			if item.Expiration > 0 {
+				entry.ExpiresAt = time.Unix(0, item.Expiration)
+			}
+			entries = append(entries, entry)
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("bolt store scan: %v", err)
+		return nil
+	}
+	return entries
+}
+
+// DeleteExpired removes key if it had expired by now, reporting whether it did.
+func (s *Store) DeleteExpired(key string, now time.Time) bool {
+	deleted := false
+	err := s.db.Update(func(tx *bolt.Tx) error {
+		b := items(tx)
+		raw := b.Get([]byte(key))
+		if raw == nil {
+			return nil
+		}
+		item, err := decodeItem(raw)
+		if err != nil {
+			return err
+		}
+		if !expired(item, now.UnixNano()) {
+			return nil
+		}
+		deleted = true
+		return b.Delete([]byte(key))
+	})
+	if err != nil {
+		log.Printf("bolt store delete expired %q: %v", key, err)
+		return false
+	}
+	return deleted
+}
+
+func (s *Store) deleteExpired() error {
+	now := time.Now().UnixNano()
+	return s.db.Update(func(tx *bolt.Tx) error {
+		c := items(tx).Cursor()
+		for k, v := c.First(); k != nil; k, v = c.Next() {
+			item, err := decodeItem(v)
+			if err != nil {
+				return err
+			}
+			if expired(item, now) {
+				if err := c.Delete(); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+}
+
+// PointInTime opens a read-only transaction. BoltDB's MVCC keeps the view
+// consistent while writers continue; the transaction is closed by Release.
+func (s *Store) PointInTime() ports.StateView {
+	tx, err := s.db.Begin(false)
+	return &view{tx: tx, err: err}
+}
+
+// Snapshot serializes the current state to w in the store snapshot format.
+func (s *Store) Snapshot(w io.Writer) error {
+	v := s.PointInTime()
+	defer v.Release()
+	return v.Snapshot(w)
+}
+
+// Restore replaces the entire state with the snapshot read from r.
+// Keys are written in batches, to bound transaction size, into a bucket of
+// their own, which replaces the live one only once the whole snapshot has been
+// read. A truncated or corrupt snapshot leaves the store unchanged.
+func (s *Store) Restore(r io.Reader) error {
+	var staging []byte
+	if err := s.db.Update(func(tx *bolt.Tx) error {
+		staging = otherBucket(liveBucket(tx))
+		if tx.Bucket(staging) != nil {
+			if err := tx.DeleteBucket(staging); err != nil {
+				return err
+			}
+		}
+		_, err := tx.CreateBucket(staging)
+		return err
+	}); err != nil {
+		return err
+	}
+
+	batch := make(map[string][]byte, restoreBatchSize)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		err := s.db.Update(func(tx *bolt.Tx) error {
+			b := tx.Bucket(staging)
+			for k, v := range batch {
+				if err := b.Put([]byte(k), v); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		clear(batch)
+		return err
+	}
+
+	var flushErr error
+	err := store.ReadSnapshot(r, func(key string, item *store.Item) {
+		if flushErr != nil {
+			return
+		}
+		batch[key] = encodeItem(item)
+		if len(batch) >= restoreBatchSize {
+			flushErr = flush()
+		}
+	})
+	if err == nil {
+		err = flushErr
+	}
+	if err == nil {
+		err = flush()
+	}
+	if err != nil {
+		if dropErr := s.db.Update(func(tx *bolt.Tx) error { return tx.DeleteBucket(staging) }); dropErr != nil {
+			log.Printf("bolt store restore: dropping partial restore: %v", dropErr)
+		}
+		return err
+	}
+
+	return s.db.Update(func(tx *bolt.Tx) error {
+		meta, err := tx.CreateBucketIfNotExists(metaBucket)
+		if err != nil {
+			return err
+		}
+		old := liveBucket(tx)
+		if err := meta.Put(liveKey, staging); err != nil {
+			return err
+		}
+		return tx.DeleteBucket(old)
+	})
+}
+
+// liveBucket returns the name of the bucket holding the items in tx. Like any
+// bolt value, it is only valid for the life of tx.
+func liveBucket(tx *bolt.Tx) []byte {
+	if meta := tx.Bucket(metaBucket); meta != nil {
+		if name := meta.Get(liveKey); name != nil {
+			return name
+		}
+	}
+	return itemsBucket
+}
+
+// otherBucket returns the bucket Restore fills while live is in use. Unlike
+// live, the name it returns stays valid after the transaction.
+func otherBucket(live []byte) []byte {
+	if string(live) == string(restoreBucket) {
+		return itemsBucket
+	}
+	return restoreBucket
+}
+
+// items returns the bucket holding the items in tx.
+func items(tx *bolt.Tx) *bolt.Bucket {
+	return tx.Bucket(liveBucket(tx))
+}
+
+// view is a point-in-time view backed by a read-only transaction.
+type view struct {
+	tx  *bolt.Tx
+	err error
+}
+
+func (v *view) Snapshot(w io.Writer) error {
+	if v.err != nil {
+		return v.err
+	}
+	sw, err := store.NewSnapshotWriter(w)
+	if err != nil {
+		return err
+	}
+	c := items(v.tx).Cursor()
+	for k, raw := c.First(); k != nil; k, raw = c.Next() {
+		item, err := decodeItem(raw)
+		if err != nil {
+			return err
+		}
+		if err := sw.WriteItem(string(k), item); err != nil {
+			return err
+		}
+	}
+	return sw.Flush()
+}
+
+func (v *view) Release() {
+	if v.tx != nil {
+		_ = v.tx.Rollback()
+		v.tx = nil
+	}
+}
+
+// encodeItem stores an item as expiration (varint) followed by the raw value.
+func encodeItem(item *store.Item) []byte {
+	b := binary.AppendVarint(make([]byte, 0, binary.MaxVarintLen64+len(item.Value)), item.Expiration)
+	return append(b, item.Value...)
+}
+
+func decodeItem(raw []byte) (*store.Item, error) {
+	exp, n := binary.Varint(raw)
+	if n <= 0 {
+		return nil, fmt.Errorf("corrupt item encoding")
+	}
+	return &store.Item{Value: string(raw[n:]), Expiration: exp}, nil
+}
+
+func unixNano(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixNano()
+}
+
+func expired(item *store.Item, now int64) bool {
+	return item.Expiration > 0 && now > item.Expiration
+}
@@ -0,0 +1,243 @@
+package boltstore
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"distributed-cache-service/internal/store"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func openTemp(t *testing.T) *Store {
+	t.Helper()
+	s, err := Open(filepath.Join(t.TempDir(), "cache.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func TestStore_SetGetDelete(t *testing.T) {
+	s := openTemp(t)
+
+	s.Set("k1", "v1", 0)
+	val, found := s.Get("k1")
+	assert.True(t, found)
+	assert.Equal(t, "v1", val)
+
+	s.Delete("k1")
+	_, found = s.Get("k1")
+	assert.False(t, found)
+}
+
+func TestStore_Replace(t *testing.T) {
+	s := openTemp(t)
+
+	assert.False(t, s.Replace("k", "v", time.Now()))
+	_, found := s.Get("k")
+	assert.False(t, found)
+
+	s.Set("k", "v1", 50*time.Millisecond)
+	assert.True(t, s.Replace("k", "v2", time.Now()))
+	val, _ := s.Get("k")
+	assert.Equal(t, "v2", val)
+
+	// The original expiration still applies.
+	time.Sleep(100 * time.Millisecond)
+	_, found = s.Get("k")
+	assert.False(t, found)
+	assert.False(t, s.Replace("k", "v3", time.Now()))
+}
+
+func TestStore_TTL(t *testing.T) {
+	s := openTemp(t)
+	s.Set("k", "v", 50*time.Millisecond)
+	time.Sleep(100 * time.Millisecond)
+
+	_, found := s.Get("k")
+	assert.False(t, found)
+
+	require.NoError(t, s.deleteExpired())
+	assert.Equal(t, 0, s.Len())
+}
+
+func TestStore_Expire(t *testing.T) {
+	s := openTemp(t)
+	assert.False(t, s.ExpireAt("k", time.Now().Add(time.Minute), time.Now()))
+
+	s.Set("k", "v", 0)
+	ttl, found := s.TTL("k")
+	assert.True(t, found)
+	assert.Zero(t, ttl)
+
+	assert.True(t, s.ExpireAt("k", time.Now().Add(time.Minute), time.Now()))
+	ttl, _ = s.TTL("k")
+	assert.InDelta(t, time.Minute, ttl, float64(time.Second))
+
+	assert.True(t, s.ExpireAt("k", time.Now().Add(50*time.Millisecond), time.Now()))
+	time.Sleep(100 * time.Millisecond)
+	_, found = s.TTL("k")
+	assert.False(t, found)
+	assert.False(t, s.ExpireAt("k", time.Time{}, time.Now()))
+
+	s.SetExpiresAt("past", "v", time.Now().Add(-time.Second))
+	_, found = s.Get("past")
+	assert.False(t, found)
+}
+
+func TestStore_PersistsAcrossReopen(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.db")
+	s, err := Open(path)
+	require.NoError(t, err)
+	s.Set("k", "v", 0)
+	require.NoError(t, s.Close())
+
+	s, err = Open(path)
+	require.NoError(t, err)
+	defer s.Close()
+	val, found := s.Get("k")
+	assert.True(t, found)
+	assert.Equal(t, "v", val)
+}
+
+func TestStore_SnapshotCompatibleWithMemoryStore(t *testing.T) {
+	s := openTemp(t)
+	s.Set("k1", "v1", 0)
+	s.Set("k2", "v2", 0)
+
+	view := s.PointInTime()
+	s.Set("k3", "after-view", 0)
+
+	var buf bytes.Buffer
+	require.NoError(t, view.Snapshot(&buf))
+	view.Release()
+
+	// A bolt snapshot restores into the memory store...
+	mem := store.New()
+	require.NoError(t, mem.Restore(bytes.NewReader(buf.Bytes())))
+	assert.Equal(t, 2, mem.Len())
+
+	// ...and back into a fresh bolt store
+	other := openTemp(t)
+	other.Set("stale", "x", 0)
+	require.NoError(t, other.Restore(&buf))
+	assert.Equal(t, 2, other.Len())
+	_, found := other.Get("stale")
+	assert.False(t, found)
+}
+
+func TestStore_FailedRestoreKeepsState(t *testing.T) {
+	src := store.New()
+	for _, k := range []string{"a", "b", "c"} {
+		src.Set(k, "new-"+k, 0)
+	}
+	var buf bytes.Buffer
+	require.NoError(t, src.Snapshot(&buf))
+	snapshot := buf.Bytes()
+
+	path := filepath.Join(t.TempDir(), "cache.db")
+	s, err := Open(path)
+	require.NoError(t, err)
+	s.Set("old", "v", 0)
+
+	// A truncated snapshot is rejected without touching the live items.
+	assert.Error(t, s.Restore(bytes.NewReader(snapshot[:len(snapshot)-3])))
+	assert.Equal(t, 1, s.Len())
+	val, found := s.Get("old")
+	assert.True(t, found)
+	assert.Equal(t, "v", val)
+
+	// Restores alternate between two buckets, and the one in use survives a
+	// reopen.
+	for i := 0; i < 3; i++ {
+		require.NoError(t, s.Restore(bytes.NewReader(snapshot)))
+		assert.Equal(t, 3, s.Len())
+	}
+	require.NoError(t, s.Close())
+	s, err = Open(path)
+	require.NoError(t, err)
+	defer s.Close()
+	assert.Equal(t, 3, s.Len())
+	val, _ = s.Get("b")
+	assert.Equal(t, "new-b", val)
+	_, found = s.Get("old")
+	assert.False(t, found)
+}
+
+// Snapshots of string items written by older binaries restore into bolt too.
+func TestStore_RestoreOlderFormats(t *testing.T) {
+	for _, name := range []string{"snapshot-json.json", "snapshot-v1.bin"} {
+		f, err := os.Open(filepath.Join("..", "testdata", name))
+		require.NoError(t, err)
+		s := openTemp(t)
+		require.NoError(t, s.Restore(f), name)
+		f.Close()
+
+		val, found := s.Get("plain")
+		assert.True(t, found, name)
+		assert.Equal(t, "value", val, name)
+		val, _ = s.Get("expiring")
+		assert.Equal(t, "later", val, name)
+	}
+}
+
+func TestStore_GetStale(t *testing.T) {
+	s := openTemp(t)
+	expiresAt := time.Unix(0, time.Now().Add(-time.Second).UnixNano())
+	s.SetExpiresAt("old", "v", expiresAt)
+	s.Set("forever", "w", 0)
+
+	_, found := s.Get("old")
+	assert.False(t, found)
+	v, at, found := s.GetStale("old")
+	assert.True(t, found)
+	assert.Equal(t, "v", v)
+	assert.True(t, at.Equal(expiresAt))
+	v, at, found = s.GetStale("forever")
+	assert.True(t, found)
+	assert.Equal(t, "w", v)
+	assert.True(t, at.IsZero())
+	_, _, found = s.GetStale("missing")
+	assert.False(t, found)
+}
+
+func TestStore_DeleteExpired(t *testing.T) {
+	s := openTemp(t)
+	now := time.Now()
+	s.SetExpiresAt("old", "v", now.Add(-time.Second))
+	s.SetExpiresAt("later", "v", now.Add(time.Second))
+	s.Set("forever", "v", 0)
+
+	assert.Equal(t, []string{"old"}, s.ExpiredKeys(now, 10))
+	assert.Len(t, s.ExpiredKeys(now.Add(2*time.Second), 1), 1)
+
+	assert.False(t, s.DeleteExpired("later", now))
+	assert.False(t, s.DeleteExpired("forever", now.Add(time.Hour)))
+	assert.False(t, s.DeleteExpired("missing", now))
+	assert.True(t, s.DeleteExpired("old", now))
+	assert.True(t, s.DeleteExpired("later", now.Add(2*time.Second)))
+	assert.Equal(t, 1, s.Len())
+}
+
+func TestStore_Scan(t *testing.T) {
+	s := openTemp(t)
+	for _, k := range []string{"user:3", "user:1", "other", "user:2", "zzz"} {
+		s.Set(k, "v", 0)
+	}
+	s.SetExpiresAt("user:0", "v", time.Now().Add(-time.Second))
+
+	keys := func(prefix, after string, limit int, match func(string) bool) []string {
+		var keys []string
+		for _, e := range s.Scan(prefix, after, limit, match) {
+			keys = append(keys, e.Key)
+		}
+		return keys
+	}
+	assert.Equal(t, []string{"user:1", "user:2"}, keys("user:", "", 2, nil))
+	assert.Equal(t, []string{"user:3"}, keys("user:", "user:2", 2, nil))
+	assert.Equal(t, []string{"other", "zzz"}, keys("", "", 10, func(k string) bool { return len(k) != 6 }))
+}
@@ -0,0 +1,48 @@
+package store
+
+// EvictReason says why a value left the store, for OnEvict.
+type EvictReason string
+
+const (
+	// EvictCapacity is a key evicted to keep the store within its capacity.
+	EvictCapacity EvictReason = "capacity"
+	// EvictTTL is a key purged after it expired.
+	EvictTTL EvictReason = "ttl"
+	// EvictExplicit is a key deleted by a client.
+	EvictExplicit EvictReason = "explicit"
+	// EvictReplaced is a value overwritten by a write to its key.
+	EvictReplaced EvictReason = "replaced"
+)
+
+// EvictReasons lists every EvictReason, e.g. to initialize metrics.
+var EvictReasons = []EvictReason{EvictCapacity, EvictTTL, EvictExplicit, EvictReplaced}
+
+// EvictFunc is called with a value that left the store, and why.
+type EvictFunc func(key, value string, reason EvictReason)
+
+// OnEvict registers fn to be called whenever a string value leaves the store.
+// A write reports the value it overwrites as replaced, unless it writes the
+// same value, as changing only the expiration does. Callbacks run in the order
+// registered, synchronously with the store locked, so they must be quick and
+// must not use the store. Sorted sets, values dropped by Restore and the
+// records replayed by OpenAOF are not reported.
+func (s *Store) OnEvict(fn EvictFunc) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.onEvict = append(s.onEvict, fn)
+}
+
+// evicted reports the value of key as leaving the store for reason, unless
+// next is the same value. Caller must hold s.mu.
+func (s *Store) evicted(key string, next *Item, reason EvictReason) {
+	if len(s.onEvict) == 0 {
+		return
+	}
+	item, ok := s.items.get(key)
+	if !ok || next != nil && next.Value == item.Value {
+		return
+	}
+	for _, fn := range s.onEvict {
+		fn(key, item.Value, reason)
+	}
+}
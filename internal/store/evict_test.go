@@ -0,0 +1,92 @@
+package store
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"distributed-cache-service/internal/core/ports"
+	"distributed-cache-service/internal/store/policy"
+)
+
+type eviction struct {
+	key, value string
+	reason     EvictReason
+}
+
+func recordEvictions(s *Store) *[]eviction {
+	var got []eviction
+	s.OnEvict(func(key, value string, reason EvictReason) {
+		got = append(got, eviction{key, value, reason})
+	})
+	return &got
+}
+
+func TestStore_OnEvict(t *testing.T) {
+	for _, offHeap := range []bool{false, true} {
+		opts := []Option{WithCapacity(2), WithPolicy(policy.NewFIFO())}
+		if offHeap {
+			opts = append(opts, WithOffHeap())
+		}
+		s := New(opts...)
+		got := recordEvictions(s)
+
+		s.Set("a", "1", 0)
+		s.Set("a", "2", 0)
+		// Only the expiration changes, so no value leaves.
+		s.ExpireAt("a", time.Now().Add(time.Hour), time.Now())
+		s.Set("b", "1", 0)
+		s.Set("c", "1", 0) // over capacity: a goes
+		s.Delete("b")
+		s.Delete("missing")
+		s.SetExpiresAt("d", "1", time.Unix(1, 0))
+		s.DeleteExpired("d", time.Now())
+		s.Evict("c")
+		// An expired string gives way to a sorted set.
+		s.SetExpiresAt("z", "1", time.Unix(1, 0))
+		if _, err := s.ZAdd("z", time.Now(), ports.ScoredMember{Member: "m", Score: 1}); err != nil {
+			t.Fatal(err)
+		}
+		s.Delete("z") // a sorted set now, so not reported
+
+		want := []eviction{
+			{"a", "1", EvictReplaced},
+			{"a", "2", EvictCapacity},
+			{"b", "1", EvictExplicit},
+			{"d", "1", EvictTTL},
+			{"c", "1", EvictCapacity},
+			{"z", "1", EvictReplaced},
+		}
+		if !reflect.DeepEqual(*got, want) {
+			t.Errorf("offHeap=%v: got %v, want %v", offHeap, *got, want)
+		}
+	}
+}
+
+func TestStore_OnEvictSkipsAOFReplay(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.aof")
+	s := New()
+	if err := s.OpenAOF(path, FsyncAlways); err != nil {
+		t.Fatal(err)
+	}
+	s.Set("k", "1", 0)
+	s.Set("k", "2", 0)
+	s.Delete("k")
+	if err := s.CloseAOF(); err != nil {
+		t.Fatal(err)
+	}
+
+	recovered := New()
+	got := recordEvictions(recovered)
+	if err := recovered.OpenAOF(path, FsyncAlways); err != nil {
+		t.Fatal(err)
+	}
+	defer recovered.CloseAOF()
+	recovered.Set("k", "3", 0)
+	recovered.Delete("k")
+	want := []eviction{{"k", "3", EvictExplicit}}
+	if !reflect.DeepEqual(*got, want) {
+		t.Errorf("got %v, want %v", *got, want)
+	}
+}
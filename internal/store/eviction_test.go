@@ -59,3 +59,69 @@ func TestStore_FIFOEviction(t *testing.T) {
 	_, found = s.Get("key3")
 	assert.True(t, found)
 }
+
+func TestStore_SetCapacityShrinks(t *testing.T) {
+	s := New(WithCapacity(3), WithPolicy(policy.NewFIFO()))
+	s.Set("key1", "val1", 0)
+	s.Set("key2", "val2", 0)
+	s.Set("key3", "val3", 0)
+
+	s.SetCapacity(1)
+
+	assert.Equal(t, 1, s.Len())
+	_, found := s.Get("key3")
+	assert.True(t, found, "newest key should survive FIFO shrink")
+}
+
+// singleVictim hides the SelectVictims of the policy it wraps.
+type singleVictim struct {
+	policy.SingleVictimPolicy
+}
+
+func TestStore_SetCapacityShrinksOneVictimAtATime(t *testing.T) {
+	s := New(WithCapacity(3), WithPolicy(policy.Batch(singleVictim{policy.NewFIFO()})))
+	s.Set("key1", "val1", 0)
+	s.Set("key2", "val2", 0)
+	s.Set("key3", "val3", 0)
+
+	s.SetCapacity(1)
+
+	assert.Equal(t, 1, s.Len())
+	_, found := s.Get("key3")
+	assert.True(t, found, "newest key should survive FIFO shrink")
+}
+
+func TestStore_SetPolicy(t *testing.T) {
+	s := New(WithCapacity(2), WithPolicy(policy.NewLRU()))
+	s.Set("key1", "val1", 0)
+	s.Set("key2", "val2", 0)
+
+	s.SetPolicy(policy.NewFIFO())
+	s.Get("key1")
+	s.Set("key3", "val3", 0)
+
+	assert.Equal(t, 2, s.Len())
+	_, found := s.Get("key3")
+	assert.True(t, found)
+}
+
+func TestStore_DeferredEviction(t *testing.T) {
+	s := New(WithCapacity(2), WithPolicy(policy.NewLRU()), WithDeferredEviction())
+	assert.Nil(t, s.EvictionVictims(10))
+
+	// Writes and shrinking never evict; the store reports the overflow instead.
+	s.Set("key1", "val1", 0)
+	s.Set("key2", "val2", 0)
+	s.Set("key3", "val3", 0)
+	s.Set("key4", "val4", 0)
+	s.Get("key1")
+	assert.Equal(t, 4, s.Len())
+	assert.Equal(t, []string{"key2", "key3"}, s.EvictionVictims(10))
+	assert.Equal(t, []string{"key2"}, s.EvictionVictims(1))
+	s.SetCapacity(1)
+	assert.Equal(t, 4, s.Len())
+
+	assert.True(t, s.Evict("key2"))
+	assert.False(t, s.Evict("key2"))
+	assert.Equal(t, []string{"key3", "key4"}, s.EvictionVictims(10))
+}
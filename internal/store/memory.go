@@ -0,0 +1,127 @@
+package store
+
+import (
+	"cmp"
+	"slices"
+
+	"distributed-cache-service/internal/core/ports"
+)
+
+// ensure implementation
+var _ ports.MemoryStorage = (*Store)(nil)
+
+// Memory estimates, in bytes, of what a key costs beyond its name and value.
+// They are rough averages for 64-bit platforms, meant to rank keys and size
+// the store, not to match the heap to the byte.
+const (
+	// itemOverhead covers a string's table entry, its Item and their string
+	// headers.
+	itemOverhead = 64
+	// zsetOverhead covers a sorted set's table entry, map and skip list head.
+	zsetOverhead = 600
+	// memberOverhead covers a sorted set member's map entry, score and skip
+	// list node with its levels.
+	memberOverhead = 96
+)
+
+func itemMemory(key string, item *Item) int64 {
+	return int64(len(key)+len(item.Value)) + itemOverhead
+}
+
+func zsetMemory(key string, z *zset) int64 {
+	return int64(len(key)) + zsetOverhead + int64(z.len())*memberOverhead + z.memberBytes
+}
+
+// keyMemory returns the bytes key takes up, or 0 if there is no such key.
+// Caller must hold s.mu.
+func (s *Store) keyMemory(key string) int64 {
+	if item, ok := s.items.get(key); ok {
+		return itemMemory(key, item)
+	}
+	if z, ok := s.zsets[key]; ok {
+		return zsetMemory(key, z)
+	}
+	return 0
+}
+
+// tableMemory returns the bytes all of items and zsets take up.
+func tableMemory(items table, zsets map[string]*zset) int64 {
+	var n int64
+	items.forEach(func(key string, item *Item) error {
+		n += itemMemory(key, item)
+		return nil
+	})
+	for key, z := range zsets {
+		n += zsetMemory(key, z)
+	}
+	return n
+}
+
+// MemoryUsage returns approximately how many bytes key takes up, expired or
+// not, like Redis's MEMORY USAGE. It does not count as an access.
+func (s *Store) MemoryUsage(key string) (int64, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if bytes := s.keyMemory(key); bytes > 0 {
+		return bytes, true
+	}
+	return 0, false
+}
+
+// MemoryStats returns approximately how many bytes all keys take up, expired
+// or not, with the n largest. The total is kept up to date as keys are
+// written, so it costs nothing to read. Finding the largest keys visits every
+// key under the read lock, like Scan, so a positive n is meant for occasional
+// inspection, not for the request path.
+func (s *Store) MemoryStats(n int) ports.MemoryStats {
+	if n <= 0 {
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+		return ports.MemoryStats{
+			Keys:    int64(s.items.len() + len(s.zsets)),
+			Bytes:   s.memory.Load(),
+			Largest: []ports.KeyMemory{},
+		}
+	}
+
+	var stats ports.MemoryStats
+	largest := []ports.KeyMemory{}
+	add := func(key string, bytes int64) {
+		stats.Keys++
+		largest = append(largest, ports.KeyMemory{Key: key, Bytes: bytes})
+		// Keep only the n largest seen so far, in batches, so memory stays
+		// proportional to n rather than to the table.
+		if len(largest) == 2*n {
+			sortBySize(largest)
+			largest = largest[:n]
+		}
+	}
+
+	s.mu.RLock()
+	s.items.forEach(func(key string, item *Item) error {
+		add(key, itemMemory(key, item))
+		return nil
+	})
+	for key, z := range s.zsets {
+		add(key, zsetMemory(key, z))
+	}
+	stats.Bytes = s.memory.Load()
+	s.mu.RUnlock()
+
+	sortBySize(largest)
+	if len(largest) > n {
+		largest = largest[:n]
+	}
+	stats.Largest = largest
+	return stats
+}
+
+// sortBySize sorts keys largest first, then by name.
+func sortBySize(keys []ports.KeyMemory) {
+	slices.SortFunc(keys, func(a, b ports.KeyMemory) int {
+		if c := cmp.Compare(b.Bytes, a.Bytes); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.Key, b.Key)
+	})
+}
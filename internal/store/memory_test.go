@@ -0,0 +1,87 @@
+package store
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+
+	"distributed-cache-service/internal/core/ports"
+)
+
+func TestStore_Memory(t *testing.T) {
+	for _, offHeap := range []bool{false, true} {
+		var opts []Option
+		if offHeap {
+			opts = append(opts, WithOffHeap())
+		}
+		s := New(opts...)
+		s.Set("small", "x", 0)
+		s.Set("big", string(make([]byte, 1000)), 0)
+		s.SetExpiresAt("expired", "abc", time.Unix(1, 0))
+		if _, err := s.ZAdd("z", time.Now(), ports.ScoredMember{Member: "m1", Score: 1}, ports.ScoredMember{Member: "m2", Score: 2}); err != nil {
+			t.Fatal(err)
+		}
+
+		small := int64(len("small")+len("x")) + itemOverhead
+		big := int64(len("big")+1000) + itemOverhead
+		expired := int64(len("expired")+len("abc")) + itemOverhead
+		z := int64(len("z")+len("m1")+len("m2")) + zsetOverhead + 2*memberOverhead
+		for key, want := range map[string]int64{"small": small, "big": big, "expired": expired, "z": z} {
+			if got, ok := s.MemoryUsage(key); !ok || got != want {
+				t.Errorf("offHeap=%v: MemoryUsage(%q) = %d, %v; want %d", offHeap, key, got, ok, want)
+			}
+		}
+		if _, ok := s.MemoryUsage("missing"); ok {
+			t.Errorf("offHeap=%v: expected no usage for a missing key", offHeap)
+		}
+
+		want := ports.MemoryStats{
+			Keys:    4,
+			Bytes:   small + big + expired + z,
+			Largest: []ports.KeyMemory{{Key: "big", Bytes: big}, {Key: "z", Bytes: z}},
+		}
+		if got := s.MemoryStats(2); !reflect.DeepEqual(got, want) {
+			t.Errorf("offHeap=%v: got %+v, want %+v", offHeap, got, want)
+		}
+		if got := s.MemoryStats(1).Largest; !reflect.DeepEqual(got, want.Largest[:1]) {
+			t.Errorf("offHeap=%v: MemoryStats(1) got %+v", offHeap, got)
+		}
+		if got := s.MemoryStats(0); got.Bytes != want.Bytes || len(got.Largest) != 0 {
+			t.Errorf("offHeap=%v: MemoryStats(0) = %+v", offHeap, got)
+		}
+
+		// The running total follows overwrites, deletes and restores.
+		s.Set("small", "xyz", 0)
+		s.Delete("big")
+		s.Set("z", "now a string", 0)
+		if _, err := s.ZAdd("z2", time.Now(), ports.ScoredMember{Member: "a", Score: 1}, ports.ScoredMember{Member: "bb", Score: 2}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := s.ZRemRangeByScore("z2", 1, 1, time.Now()); err != nil {
+			t.Fatal(err)
+		}
+		total := func(s *Store) int64 {
+			var n int64
+			for _, e := range s.MemoryStats(10).Largest {
+				n += e.Bytes
+			}
+			return n
+		}
+		if got, want := s.MemoryStats(0).Bytes, total(s); got != want {
+			t.Errorf("offHeap=%v: running total %d, want %d", offHeap, got, want)
+		}
+		var buf bytes.Buffer
+		if err := s.Snapshot(&buf); err != nil {
+			t.Fatal(err)
+		}
+		restored := New(opts...)
+		restored.Set("stale", "v", 0)
+		if err := restored.Restore(&buf); err != nil {
+			t.Fatal(err)
+		}
+		if got, want := restored.MemoryStats(0).Bytes, s.MemoryStats(0).Bytes; got != want {
+			t.Errorf("offHeap=%v: restored total %d, want %d", offHeap, got, want)
+		}
+	}
+}
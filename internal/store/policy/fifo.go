@@ -48,6 +48,17 @@ func (p *FIFOPolicy) OnRemove(key string) {
 	}
 }
 
+// SelectVictims returns up to n keys, oldest first.
+func (p *FIFOPolicy) SelectVictims(n int) []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var keys []string
+	for elem := p.order.Front(); elem != nil && len(keys) < n; elem = elem.Next() {
+		keys = append(keys, elem.Value.(string))
+	}
+	return keys
+}
+
 func (p *FIFOPolicy) SelectVictim() string {
 	p.mu.Lock()
 	defer p.mu.Unlock()
@@ -3,6 +3,7 @@ package policy
 import (
 	"container/heap"
 	"sync"
+	"time"
 )
 
 // lfuItem represents an item in the priority queue.
@@ -48,17 +49,65 @@ func (pq *PriorityQueue) Pop() interface{} {
 // LFUPolicy implements the Least Frequently Used (LFU) eviction strategy.
 // It uses a Min-Heap (PriorityQueue) to efficiently track and evict the item with the lowest access frequency.
 // operations are generally O(log N).
+//
+// Counts are halved once per half-life (DefaultHalfLife unless set with
+// WithHalfLife), and with WithDecayWindow once per window of accesses.
+// Without aging, a key that was hot once would outrank every key of the
+// current hot set and never be evicted. Halving keeps the order of keys
+// read at steady rates, such as a Zipfian hot set, while the counts of
+// keys no longer read fall away. Halving never breaks the heap, since it
+// keeps every count at or below those of the keys it was below.
 type LFUPolicy struct {
 	mu    sync.Mutex
 	pq    PriorityQueue
 	items map[string]*lfuItem
+
+	halfLife time.Duration
+	decayed  time.Time
+	window   int
+	accesses int
+	now      func() time.Time
 }
 
-// NewLFU creates a new LFU policy instance.
-func NewLFU() *LFUPolicy {
-	return &LFUPolicy{
-		pq:    make(PriorityQueue, 0),
-		items: make(map[string]*lfuItem),
+// NewLFU creates a new LFU policy instance, tuned by opts.
+func NewLFU(opts ...Option) *LFUPolicy {
+	o := options{halfLife: DefaultHalfLife}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	p := &LFUPolicy{
+		pq:       make(PriorityQueue, 0),
+		items:    make(map[string]*lfuItem),
+		halfLife: o.halfLife,
+		window:   o.window,
+		now:      time.Now,
+	}
+	p.decayed = p.now()
+	return p
+}
+
+// age halves every count once per half-life elapsed and, when a decay
+// window is set, once per window accesses counted by access.
+func (p *LFUPolicy) age(access bool) {
+	halvings := 0
+	if p.halfLife > 0 {
+		if elapsed := p.now().Sub(p.decayed); elapsed >= p.halfLife {
+			n := elapsed / p.halfLife
+			p.decayed = p.decayed.Add(n * p.halfLife)
+			halvings += int(min(n, 63))
+		}
+	}
+	if access && p.window > 0 {
+		if p.accesses++; p.accesses >= p.window {
+			p.accesses = 0
+			halvings++
+		}
+	}
+	if halvings == 0 {
+		return
+	}
+	for _, item := range p.pq {
+		item.frequency >>= min(halvings, 63)
 	}
 }
 
@@ -67,6 +116,7 @@ func NewLFU() *LFUPolicy {
 func (p *LFUPolicy) OnAccess(key string) {
 	p.mu.Lock()
 	defer p.mu.Unlock()
+	p.age(true)
 	if item, ok := p.items[key]; ok {
 		item.frequency++
 		heap.Fix(&p.pq, item.index)
@@ -78,6 +128,7 @@ func (p *LFUPolicy) OnAccess(key string) {
 func (p *LFUPolicy) OnAdd(key string) {
 	p.mu.Lock()
 	defer p.mu.Unlock()
+	p.age(true)
 	if item, ok := p.items[key]; ok {
 		item.frequency++
 		heap.Fix(&p.pq, item.index)
@@ -100,11 +151,29 @@ func (p *LFUPolicy) OnRemove(key string) {
 	}
 }
 
+// SelectVictims returns up to n keys, least frequently used first.
+// It pops them from a copy of the heap, so it costs O(N + n log N).
+func (p *LFUPolicy) SelectVictims(n int) []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.age(false)
+	pq := make(PriorityQueue, len(p.pq))
+	for i, item := range p.pq {
+		pq[i] = &lfuItem{key: item.key, frequency: item.frequency, index: i}
+	}
+	var keys []string
+	for len(pq) > 0 && len(keys) < n {
+		keys = append(keys, heap.Pop(&pq).(*lfuItem).key)
+	}
+	return keys
+}
+
 // SelectVictim returns the key with the lowest frequency.
 // It peeks at the top of the Min-Heap.
 func (p *LFUPolicy) SelectVictim() string {
 	p.mu.Lock()
 	defer p.mu.Unlock()
+	p.age(false)
 	if len(p.pq) == 0 {
 		return ""
 	}
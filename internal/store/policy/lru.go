@@ -49,6 +49,17 @@ func (p *LRUPolicy) OnRemove(key string) {
 	}
 }
 
+// SelectVictims returns up to n keys, least recently used first.
+func (p *LRUPolicy) SelectVictims(n int) []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	var keys []string
+	for elem := p.order.Back(); elem != nil && len(keys) < n; elem = elem.Prev() {
+		keys = append(keys, elem.Value.(string))
+	}
+	return keys
+}
+
 func (p *LRUPolicy) SelectVictim() string {
 	p.mu.Lock()
 	defer p.mu.Unlock()
@@ -1,5 +1,11 @@
 package policy
 
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
 // EvictionPolicy defines the interface for eviction algorithms.
 // Implementations allow the store to decouple capacity management from storage logic.
 type EvictionPolicy interface {
@@ -16,4 +22,90 @@ type EvictionPolicy interface {
 	// SelectVictim returns the key that should be evicted according to the policy.
 	// Returns an empty string if no victim is available (e.g., empty store).
 	SelectVictim() string
+
+	// SelectVictims returns up to n distinct keys in the order they should be
+	// evicted, without removing them. The first is the key SelectVictim returns.
+	SelectVictims(n int) []string
+}
+
+// SingleVictimPolicy is an eviction policy that names one victim at a time,
+// as EvictionPolicy did before SelectVictims.
+type SingleVictimPolicy interface {
+	OnAccess(key string)
+	OnAdd(key string)
+	OnRemove(key string)
+	SelectVictim() string
+}
+
+// Batch adapts p to EvictionPolicy, returning it as is if it implements
+// SelectVictims already. Otherwise SelectVictims returns at most the key
+// SelectVictim names: callers evicting several keys ask again once they
+// have removed it, as they must with any policy that returns fewer than n.
+func Batch(p SingleVictimPolicy) EvictionPolicy {
+	if ep, ok := p.(EvictionPolicy); ok {
+		return ep
+	}
+	return batched{p}
+}
+
+type batched struct {
+	SingleVictimPolicy
+}
+
+func (b batched) SelectVictims(n int) []string {
+	if n <= 0 {
+		return nil
+	}
+	if victim := b.SelectVictim(); victim != "" {
+		return []string{victim}
+	}
+	return nil
+}
+
+// Option tunes the policies that support it; the others ignore it.
+type Option func(*options)
+
+type options struct {
+	halfLife time.Duration
+	window   int
+}
+
+// DefaultHalfLife is how often LFU halves its counts unless told otherwise.
+const DefaultHalfLife = time.Minute
+
+// WithHalfLife makes LFU halve every count once per halfLife, so that keys
+// that were hot once but are no longer read become evictable again. Zero
+// or less never halves counts on a timer.
+func WithHalfLife(halfLife time.Duration) Option {
+	return func(o *options) {
+		o.halfLife = halfLife
+	}
+}
+
+// WithDecayWindow makes LFU halve every count once per window accesses, as
+// TinyLFU does, so that counts reflect the last few windows of traffic
+// however fast it comes. Zero or less disables it.
+func WithDecayWindow(window int) Option {
+	return func(o *options) {
+		o.window = window
+	}
+}
+
+// New returns the eviction policy registered under name (lru, fifo, lfu, random),
+// tuned by opts. The name "none" yields a nil policy, which disables eviction.
+func New(name string, opts ...Option) (EvictionPolicy, error) {
+	switch strings.ToLower(name) {
+	case "lru":
+		return NewLRU(), nil
+	case "fifo":
+		return NewFIFO(), nil
+	case "lfu":
+		return NewLFU(opts...), nil
+	case "random":
+		return NewRandom(), nil
+	case "none":
+		return nil, nil
+	default:
+		return nil, fmt.Errorf("unknown eviction policy %q", name)
+	}
 }
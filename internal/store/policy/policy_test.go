@@ -3,6 +3,7 @@ package policy
 import (
 	"math/rand"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -70,6 +71,55 @@ func TestLFUPolicy(t *testing.T) {
 	assert.Equal(t, "B", lfu.SelectVictim())
 }
 
+func TestLFUPolicy_Aging(t *testing.T) {
+	// A was the hot key, then traffic moved on to B and C.
+	shift := func(lfu *LFUPolicy, between func()) {
+		lfu.OnAdd("A")
+		for i := 0; i < 100; i++ {
+			lfu.OnAccess("A")
+		}
+		between()
+		lfu.OnAdd("B")
+		lfu.OnAdd("C")
+		for i := 0; i < 40; i++ {
+			lfu.OnAccess("B")
+			lfu.OnAccess("C")
+		}
+	}
+
+	t.Run("NoDecay", func(t *testing.T) {
+		lfu := NewLFU(WithHalfLife(0))
+		shift(lfu, func() {})
+		// A stays ahead of the new hot set forever.
+		assert.NotEqual(t, "A", lfu.SelectVictim())
+	})
+
+	t.Run("HalfLife", func(t *testing.T) {
+		now := time.Unix(0, 0)
+		lfu := NewLFU(WithHalfLife(time.Minute))
+		lfu.now = func() time.Time { return now }
+		lfu.decayed = now
+		// A's 101 falls to 6 over four half-lives.
+		shift(lfu, func() { now = now.Add(4 * time.Minute) })
+		assert.Equal(t, "A", lfu.SelectVictim())
+		assert.Equal(t, "A", lfu.SelectVictims(3)[0])
+
+		// Halving keeps the order of keys read at steady rates.
+		lfu.OnRemove("A")
+		lfu.OnAccess("C")
+		now = now.Add(time.Minute)
+		assert.Equal(t, "B", lfu.SelectVictim())
+	})
+
+	t.Run("Window", func(t *testing.T) {
+		lfu := NewLFU(WithHalfLife(0), WithDecayWindow(20))
+		// Every 20 reads halve all counts, so A's lead fades once it is no
+		// longer read.
+		shift(lfu, func() {})
+		assert.Equal(t, "A", lfu.SelectVictim())
+	})
+}
+
 func TestRandomPolicy(t *testing.T) {
 	// Use a local, deterministic rand source for reproducible tests
 	src := rand.NewSource(42) // Fixed seed for reproducibility
@@ -95,3 +145,61 @@ func TestRandomPolicy(t *testing.T) {
 		assert.Contains(t, []string{"A", "B", "C"}, newVictim) // Still one of the original set
 	})
 }
+
+func TestSelectVictims(t *testing.T) {
+	lru, fifo, lfu := NewLRU(), NewFIFO(), NewLFU()
+	for _, p := range []EvictionPolicy{lru, fifo, lfu} {
+		p.OnAdd("A")
+		p.OnAdd("B")
+		p.OnAdd("C")
+		p.OnAccess("A")
+		p.OnAccess("A")
+		p.OnAccess("C")
+	}
+	assert.Equal(t, []string{"B", "A", "C"}, lru.SelectVictims(5))
+	assert.Equal(t, []string{"A", "B"}, fifo.SelectVictims(2))
+	assert.Equal(t, []string{"B", "C", "A"}, lfu.SelectVictims(3))
+	// Selecting does not remove.
+	assert.Equal(t, "B", lfu.SelectVictim())
+
+	random := newRandomWithRand(rand.New(rand.NewSource(42)))
+	random.OnAdd("A")
+	random.OnAdd("B")
+	random.OnAdd("C")
+	assert.ElementsMatch(t, []string{"A", "B", "C"}, random.SelectVictims(5))
+	assert.Len(t, random.SelectVictims(2), 2)
+}
+
+// oneAtATime hides the SelectVictims of the policy it wraps.
+type oneAtATime struct {
+	SingleVictimPolicy
+}
+
+func TestBatch(t *testing.T) {
+	lru := NewLRU()
+	assert.Same(t, lru, Batch(lru))
+
+	p := Batch(oneAtATime{NewFIFO()})
+	assert.Empty(t, p.SelectVictims(3))
+	p.OnAdd("A")
+	p.OnAdd("B")
+	assert.Equal(t, []string{"A"}, p.SelectVictims(2))
+	assert.Empty(t, p.SelectVictims(0))
+	p.OnRemove("A")
+	assert.Equal(t, []string{"B"}, p.SelectVictims(2))
+}
+
+func TestNew(t *testing.T) {
+	for _, name := range []string{"lru", "FIFO", "lfu", "random"} {
+		p, err := New(name)
+		assert.NoError(t, err)
+		assert.NotNil(t, p, name)
+	}
+
+	p, err := New("none")
+	assert.NoError(t, err)
+	assert.Nil(t, p)
+
+	_, err = New("bogus")
+	assert.Error(t, err)
+}
@@ -69,6 +69,20 @@ func (p *RandomPolicy) Evict() string {
 	return p.SelectVictim()
 }
 
+// SelectVictims chooses up to n distinct random keys.
+func (p *RandomPolicy) SelectVictims(n int) []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if n > len(p.items) {
+		n = len(p.items)
+	}
+	keys := make([]string, 0, n)
+	for _, idx := range p.rnd.Perm(len(p.items))[:n] {
+		keys = append(keys, p.items[idx])
+	}
+	return keys
+}
+
 // SelectVictim chooses a random key from the current items.
 // It uses a uniform random distribution provided by the local source.
 // Returns an empty string if the policy has no items.
@@ -0,0 +1,114 @@
+package store
+
+import (
+	"maps"
+	"strings"
+)
+
+// prefixSep ends the prefix a prefixTable shares between keys.
+const prefixSep = ':'
+
+// WithInternedPrefixes stores each key's prefix, up to and including its last
+// ':', once for all the keys that share it, and only the rest of the key per
+// item. Keys shaped like service:tenant:object:id repeat long prefixes across
+// millions of entries, so this saves most of a key's bytes for a lookup in a
+// second map on every access. Keys without a ':' share the empty prefix. It
+// has no effect with WithOffHeap.
+func WithInternedPrefixes() Option {
+	return func(s *Store) {
+		s.internPrefixes = true
+	}
+}
+
+// prefixTable is a table indexed by key prefix, then by the rest of the key.
+// Items are immutable, as in mapTable.
+type prefixTable struct {
+	groups map[string]map[string]*Item
+	n      int
+}
+
+func newPrefixTable() *prefixTable {
+	return &prefixTable{groups: make(map[string]map[string]*Item)}
+}
+
+// splitKey splits key after its last prefixSep.
+func splitKey(key string) (prefix, rest string) {
+	i := strings.LastIndexByte(key, prefixSep) + 1
+	return key[:i], key[i:]
+}
+
+func (t *prefixTable) get(key string) (*Item, bool) {
+	prefix, rest := splitKey(key)
+	item, ok := t.groups[prefix][rest]
+	return item, ok
+}
+
+func (t *prefixTable) has(key string) bool {
+	_, ok := t.get(key)
+	return ok
+}
+
+func (t *prefixTable) set(key string, item *Item) {
+	prefix, rest := splitKey(key)
+	group, ok := t.groups[prefix]
+	if !ok {
+		// Cloned so that the table keeps neither the caller's key alive nor
+		// more of it than it needs.
+		group = make(map[string]*Item)
+		t.groups[strings.Clone(prefix)] = group
+	}
+	if _, ok := group[rest]; ok {
+		group[rest] = item
+		return
+	}
+	group[strings.Clone(rest)] = item
+	t.n++
+}
+
+func (t *prefixTable) delete(key string) bool {
+	prefix, rest := splitKey(key)
+	group, ok := t.groups[prefix]
+	if !ok {
+		return false
+	}
+	if _, ok := group[rest]; !ok {
+		return false
+	}
+	delete(group, rest)
+	if len(group) == 0 {
+		delete(t.groups, prefix)
+	}
+	t.n--
+	return true
+}
+
+func (t *prefixTable) len() int {
+	return t.n
+}
+
+func (t *prefixTable) keys(fn func(key string, expiration int64)) {
+	for prefix, group := range t.groups {
+		for rest, item := range group {
+			fn(prefix+rest, item.Expiration)
+		}
+	}
+}
+
+func (t *prefixTable) forEach(fn func(key string, item *Item) error) error {
+	for prefix, group := range t.groups {
+		for rest, item := range group {
+			if err := fn(prefix+rest, item); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (t *prefixTable) clone() table {
+	c := &prefixTable{groups: make(map[string]map[string]*Item, len(t.groups)), n: t.n}
+	for prefix, group := range t.groups {
+		c.groups[prefix] = maps.Clone(group)
+	}
+	return c
+}
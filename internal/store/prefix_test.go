@@ -0,0 +1,81 @@
+package store
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"testing"
+)
+
+func TestPrefixTable(t *testing.T) {
+	tbl := newPrefixTable()
+	tbl.set("svc:tenant:1", &Item{Value: "a"})
+	tbl.set("svc:tenant:2", &Item{Value: "b"})
+	tbl.set("plain", &Item{Value: "c"})
+	tbl.set("svc:tenant:1", &Item{Value: "a2"})
+	tbl.set("svc:", &Item{Value: "d"})
+
+	if tbl.len() != 4 {
+		t.Errorf("expected 4 items, got %d", tbl.len())
+	}
+	if len(tbl.groups) != 3 {
+		t.Errorf("expected 3 prefixes, got %d", len(tbl.groups))
+	}
+	for key, want := range map[string]string{"svc:tenant:1": "a2", "svc:tenant:2": "b", "plain": "c", "svc:": "d"} {
+		if item, ok := tbl.get(key); !ok || item.Value != want {
+			t.Errorf("get(%q) = %v, %v; want %q", key, item, ok, want)
+		}
+	}
+	if tbl.has("svc:tenant:3") || tbl.has("svc:tenant") || tbl.has("other:1") {
+		t.Error("expected missing keys to be absent")
+	}
+
+	view := tbl.clone()
+	if !tbl.delete("svc:tenant:2") || tbl.delete("svc:tenant:2") {
+		t.Error("expected delete to report whether the key existed")
+	}
+	// Deleting while visiting, as deleteExpired does.
+	tbl.keys(func(key string, _ int64) {
+		if key == "plain" {
+			tbl.delete(key)
+		}
+	})
+	if tbl.len() != 2 || len(tbl.groups) != 2 {
+		t.Errorf("expected 2 items under 2 prefixes, got %d under %d", tbl.len(), len(tbl.groups))
+	}
+
+	var keys []string
+	view.keys(func(key string, _ int64) { keys = append(keys, key) })
+	sort.Strings(keys)
+	if want := []string{"plain", "svc:", "svc:tenant:1", "svc:tenant:2"}; fmt.Sprint(keys) != fmt.Sprint(want) {
+		t.Errorf("clone changed with the table: got %v, want %v", keys, want)
+	}
+}
+
+func TestStore_InternedPrefixes(t *testing.T) {
+	s := New(WithInternedPrefixes())
+	for i := 0; i < 100; i++ {
+		s.Set(fmt.Sprintf("orders:tenant-%d:invoice:%d", i%4, i), fmt.Sprint(i), 0)
+	}
+	if got, ok := s.Get("orders:tenant-1:invoice:41"); !ok || got != "41" {
+		t.Errorf("got %q, %v", got, ok)
+	}
+	if st := s.Stats(); st.Items != 100 || st.Prefixes != 4 {
+		t.Errorf("expected 100 items under 4 prefixes, got %+v", st)
+	}
+
+	var buf bytes.Buffer
+	if err := s.Snapshot(&buf); err != nil {
+		t.Fatal(err)
+	}
+	restored := New(WithInternedPrefixes())
+	if err := restored.Restore(&buf); err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := restored.Get("orders:tenant-3:invoice:99"); !ok || got != "99" {
+		t.Errorf("after restore got %q, %v", got, ok)
+	}
+	if st := restored.Stats(); st.Items != 100 || st.Prefixes != 4 {
+		t.Errorf("after restore expected 100 items under 4 prefixes, got %+v", st)
+	}
+}
@@ -0,0 +1,55 @@
+package store
+
+import (
+	"slices"
+	"strings"
+	"time"
+
+	"distributed-cache-service/internal/core/ports"
+)
+
+// ensure implementation
+var _ ports.ScanStorage = (*Store)(nil)
+
+// Scan returns up to limit unexpired keys starting with prefix and greater
+// than after, in order, with their values. The table is unordered, so every
+// call visits all of its keys under the read lock: it is meant for browsing,
+// not for the request path. Sorted sets are not listed.
+func (s *Store) Scan(prefix, after string, limit int, match func(key string) bool) []ports.StoredEntry {
+	if limit <= 0 {
+		return nil
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	now := time.Now().UnixNano()
+	var keys []string
+	s.items.keys(func(k string, expiration int64) {
+		if after != "" && k <= after || !strings.HasPrefix(k, prefix) ||
+			expiration > 0 && now > expiration || match != nil && !match(k) {
+			return
+		}
+		keys = append(keys, k)
+		// Keep only the first limit keys seen so far, in batches, so memory
+		// stays proportional to limit rather than to the table.
+		if len(keys) == 2*limit {
+			slices.Sort(keys)
+			keys = keys[:limit]
+		}
+	})
+	slices.Sort(keys)
+	if len(keys) > limit {
+		keys = keys[:limit]
+	}
+
+	entries := make([]ports.StoredEntry, 0, len(keys))
+	for _, k := range keys {
+		item, _ := s.items.get(k)
+		entry := ports.StoredEntry{Key: k, Value: item.Value}
+		if item.Expiration > 0 {
+			entry.ExpiresAt = time.Unix(0, item.Expiration)
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
@@ -0,0 +1,47 @@
+package store
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestStore_Scan(t *testing.T) {
+	for _, offHeap := range []bool{false, true} {
+		var opts []Option
+		if offHeap {
+			opts = append(opts, WithOffHeap())
+		}
+		s := New(opts...)
+		for _, k := range []string{"user:3", "user:1", "other", "user:2", "user:10"} {
+			s.Set(k, "v"+k, 0)
+		}
+		s.SetExpiresAt("user:0", "gone", time.Now().Add(-time.Second))
+		expiresAt := time.Unix(0, time.Now().Add(time.Hour).UnixNano())
+		s.SetExpiresAt("user:4", "later", expiresAt)
+
+		keys := func(prefix, after string, limit int, match func(string) bool) []string {
+			var keys []string
+			for _, e := range s.Scan(prefix, after, limit, match) {
+				keys = append(keys, e.Key)
+			}
+			return keys
+		}
+		if got, want := keys("user:", "", 3, nil), []string{"user:1", "user:10", "user:2"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("offHeap=%v: first page %v, want %v", offHeap, got, want)
+		}
+		if got, want := keys("user:", "user:2", 3, nil), []string{"user:3", "user:4"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("offHeap=%v: second page %v, want %v", offHeap, got, want)
+		}
+		odd := func(k string) bool { return strings.HasSuffix(k, "1") || strings.HasSuffix(k, "3") }
+		if got, want := keys("", "", 10, odd), []string{"user:1", "user:3"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("offHeap=%v: matched %v, want %v", offHeap, got, want)
+		}
+
+		entries := s.Scan("user:4", "", 1, nil)
+		if len(entries) != 1 || entries[0].Value != "later" || !entries[0].ExpiresAt.Equal(expiresAt) {
+			t.Errorf("offHeap=%v: got %+v", offHeap, entries)
+		}
+	}
+}
@@ -0,0 +1,288 @@
+package store
+
+import (
+	"encoding/binary"
+	"hash/maphash"
+	"maps"
+	"slices"
+)
+
+// Slab table layout.
+//
+// Entries are packed into fixed-size chunks carved out of 1 MiB pages, with one
+// pool of pages per power-of-two chunk size (64 B .. 1 MiB), similar to
+// memcached's slab allocator. Each chunk holds:
+//
+//	expiration int64 | key length uint32 | value length uint32 | key | value
+//
+// The index maps a key hash to a chunk reference. Neither the index nor the
+// pages contain pointers, so the garbage collector does not have to scan them
+// no matter how many items are stored. Entries too large for the biggest chunk
+// get a dedicated buffer.
+const (
+	slabPageSize   = 1 << 20
+	slabMinChunk   = 64
+	slabClasses    = 15 // 64 B << 14 == 1 MiB
+	slabHeaderSize = 16
+	slabHugeClass  = slabClasses
+)
+
+// slabRef identifies an entry: the size class in the high 32 bits and the
+// chunk number within that class in the low 32 bits.
+type slabRef uint64
+
+func newSlabRef(class int, chunk uint32) slabRef {
+	return slabRef(uint64(class)<<32 | uint64(chunk))
+}
+
+func (r slabRef) class() int    { return int(r >> 32) }
+func (r slabRef) chunk() uint32 { return uint32(r) }
+
+type slabClass struct {
+	chunkSize int
+	perPage   uint32
+	pages     [][]byte
+	free      []uint32
+	next      uint32 // chunks handed out from pages so far
+}
+
+func (c *slabClass) alloc() uint32 {
+	if n := len(c.free); n > 0 {
+		chunk := c.free[n-1]
+		c.free = c.free[:n-1]
+		return chunk
+	}
+	if c.next == uint32(len(c.pages))*c.perPage {
+		c.pages = append(c.pages, make([]byte, slabPageSize))
+	}
+	chunk := c.next
+	c.next++
+	return chunk
+}
+
+func (c *slabClass) bytes(chunk uint32) []byte {
+	page := c.pages[chunk/c.perPage]
+	off := int(chunk%c.perPage) * c.chunkSize
+	return page[off : off+c.chunkSize]
+}
+
+// slabTable stores items in pointer-free slab pages. It trades an allocation
+// per read (values are copied out) for much shorter GC pauses on large caches.
+type slabTable struct {
+	seed     maphash.Seed
+	index    map[uint64]slabRef
+	overlap  map[string]slabRef // keys whose hash collided with another key
+	classes  [slabClasses]slabClass
+	huge     [][]byte
+	hugeFree []uint32
+}
+
+func newSlabTable() *slabTable {
+	t := &slabTable{
+		seed:    maphash.MakeSeed(),
+		index:   make(map[uint64]slabRef),
+		overlap: make(map[string]slabRef),
+	}
+	for i := range t.classes {
+		size := slabMinChunk << i
+		t.classes[i] = slabClass{chunkSize: size, perPage: uint32(slabPageSize / size)}
+	}
+	return t
+}
+
+func (t *slabTable) entry(ref slabRef) []byte {
+	if ref.class() == slabHugeClass {
+		return t.huge[ref.chunk()]
+	}
+	return t.classes[ref.class()].bytes(ref.chunk())
+}
+
+func (t *slabTable) entryKey(ref slabRef) string {
+	e := t.entry(ref)
+	keyLen := binary.LittleEndian.Uint32(e[8:])
+	return string(e[slabHeaderSize : slabHeaderSize+keyLen])
+}
+
+func (t *slabTable) entryKeyEquals(ref slabRef, key string) bool {
+	e := t.entry(ref)
+	keyLen := binary.LittleEndian.Uint32(e[8:])
+	return string(e[slabHeaderSize:slabHeaderSize+keyLen]) == key
+}
+
+func (t *slabTable) decode(ref slabRef) *Item {
+	e := t.entry(ref)
+	keyLen := binary.LittleEndian.Uint32(e[8:])
+	valLen := binary.LittleEndian.Uint32(e[12:])
+	start := slabHeaderSize + keyLen
+	return &Item{
+		Value:      string(e[start : start+valLen]),
+		Expiration: int64(binary.LittleEndian.Uint64(e)),
+	}
+}
+
+// lookup finds the reference for key and reports whether it lives in the
+// overlap map rather than the hash index.
+func (t *slabTable) lookup(key string) (ref slabRef, inOverlap bool, ok bool) {
+	if ref, ok := t.overlap[key]; ok {
+		return ref, true, true
+	}
+	ref, ok = t.index[maphash.String(t.seed, key)]
+	if !ok || !t.entryKeyEquals(ref, key) {
+		return 0, false, false
+	}
+	return ref, false, true
+}
+
+func (t *slabTable) get(key string) (*Item, bool) {
+	ref, _, ok := t.lookup(key)
+	if !ok {
+		return nil, false
+	}
+	return t.decode(ref), true
+}
+
+func (t *slabTable) has(key string) bool {
+	_, _, ok := t.lookup(key)
+	return ok
+}
+
+func (t *slabTable) set(key string, item *Item) {
+	ref := t.write(key, item)
+	if old, ok := t.overlap[key]; ok {
+		t.overlap[key] = ref
+		t.release(old)
+		return
+	}
+	h := maphash.String(t.seed, key)
+	old, ok := t.index[h]
+	switch {
+	case !ok:
+		t.index[h] = ref
+	case t.entryKeyEquals(old, key):
+		t.index[h] = ref
+		t.release(old)
+	default:
+		t.overlap[key] = ref
+	}
+}
+
+func (t *slabTable) delete(key string) bool {
+	ref, inOverlap, ok := t.lookup(key)
+	if !ok {
+		return false
+	}
+	if inOverlap {
+		delete(t.overlap, key)
+	} else {
+		delete(t.index, maphash.String(t.seed, key))
+	}
+	t.release(ref)
+	return true
+}
+
+// write copies the entry into a free chunk of the smallest fitting class.
+func (t *slabTable) write(key string, item *Item) slabRef {
+	size := slabHeaderSize + len(key) + len(item.Value)
+
+	var ref slabRef
+	var e []byte
+	if class := slabClassFor(size); class < slabClasses {
+		c := &t.classes[class]
+		chunk := c.alloc()
+		ref, e = newSlabRef(class, chunk), c.bytes(chunk)
+	} else {
+		buf := make([]byte, size)
+		if n := len(t.hugeFree); n > 0 {
+			chunk := t.hugeFree[n-1]
+			t.hugeFree = t.hugeFree[:n-1]
+			t.huge[chunk] = buf
+			ref = newSlabRef(slabHugeClass, chunk)
+		} else {
+			t.huge = append(t.huge, buf)
+			ref = newSlabRef(slabHugeClass, uint32(len(t.huge)-1))
+		}
+		e = buf
+	}
+
+	binary.LittleEndian.PutUint64(e, uint64(item.Expiration))
+	binary.LittleEndian.PutUint32(e[8:], uint32(len(key)))
+	binary.LittleEndian.PutUint32(e[12:], uint32(len(item.Value)))
+	copy(e[slabHeaderSize:], key)
+	copy(e[slabHeaderSize+len(key):], item.Value)
+	return ref
+}
+
+// release returns the entry's chunk to its free list. Pages are kept for reuse
+// rather than returned to the runtime.
+func (t *slabTable) release(ref slabRef) {
+	if ref.class() == slabHugeClass {
+		t.huge[ref.chunk()] = nil
+		t.hugeFree = append(t.hugeFree, ref.chunk())
+		return
+	}
+	c := &t.classes[ref.class()]
+	c.free = append(c.free, ref.chunk())
+}
+
+func slabClassFor(size int) int {
+	class, chunk := 0, slabMinChunk
+	for chunk < size && class < slabClasses {
+		class++
+		chunk <<= 1
+	}
+	return class
+}
+
+func (t *slabTable) len() int {
+	return len(t.index) + len(t.overlap)
+}
+
+func (t *slabTable) refs(fn func(ref slabRef)) {
+	for _, ref := range t.index {
+		fn(ref)
+	}
+	for _, ref := range t.overlap {
+		fn(ref)
+	}
+}
+
+func (t *slabTable) keys(fn func(key string, expiration int64)) {
+	t.refs(func(ref slabRef) {
+		fn(t.entryKey(ref), int64(binary.LittleEndian.Uint64(t.entry(ref))))
+	})
+}
+
+func (t *slabTable) forEach(fn func(key string, item *Item) error) error {
+	var err error
+	t.refs(func(ref slabRef) {
+		if err == nil {
+			err = fn(t.entryKey(ref), t.decode(ref))
+		}
+	})
+	return err
+}
+
+// clone copies the pages wholesale. This is a memcpy of pointer-free memory,
+// so it stays cheap for the garbage collector even for very large tables.
+func (t *slabTable) clone() table {
+	c := &slabTable{
+		seed:     t.seed,
+		index:    maps.Clone(t.index),
+		overlap:  maps.Clone(t.overlap),
+		huge:     make([][]byte, len(t.huge)),
+		hugeFree: slices.Clone(t.hugeFree),
+	}
+	for i, src := range t.classes {
+		dst := src
+		dst.free = slices.Clone(src.free)
+		dst.pages = make([][]byte, len(src.pages))
+		for p, page := range src.pages {
+			dst.pages[p] = slices.Clone(page)
+		}
+		c.classes[i] = dst
+	}
+	for i, buf := range t.huge {
+		c.huge[i] = slices.Clone(buf)
+	}
+	return c
+}
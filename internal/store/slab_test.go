@@ -0,0 +1,136 @@
+package store
+
+import (
+	"bytes"
+	"fmt"
+	"hash/maphash"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOffHeap_SetGetDelete(t *testing.T) {
+	s := New(WithOffHeap())
+
+	s.Set("key", "value", 0)
+	if got, ok := s.Get("key"); !ok || got != "value" {
+		t.Fatalf("expected value, got %q (found=%v)", got, ok)
+	}
+
+	// Overwrite with a value from a different size class
+	long := strings.Repeat("x", 500)
+	s.Set("key", long, 0)
+	if got, _ := s.Get("key"); got != long {
+		t.Fatalf("expected overwritten value, got %d bytes", len(got))
+	}
+	if s.Len() != 1 {
+		t.Fatalf("expected 1 item, got %d", s.Len())
+	}
+
+	s.Delete("key")
+	if _, ok := s.Get("key"); ok {
+		t.Fatal("key should have been deleted")
+	}
+	if s.Len() != 0 {
+		t.Fatalf("expected 0 items, got %d", s.Len())
+	}
+}
+
+func TestOffHeap_HugeValue(t *testing.T) {
+	s := New(WithOffHeap())
+	huge := strings.Repeat("h", 2*slabPageSize)
+
+	s.Set("huge", huge, 0)
+	if got, _ := s.Get("huge"); got != huge {
+		t.Fatalf("huge value mismatch: got %d bytes", len(got))
+	}
+	s.Delete("huge")
+	s.Set("huge2", huge, 0)
+
+	tbl := s.items.(*slabTable)
+	if len(tbl.huge) != 1 {
+		t.Errorf("expected huge slot to be reused, have %d slots", len(tbl.huge))
+	}
+}
+
+func TestOffHeap_ChunksAreReused(t *testing.T) {
+	s := New(WithOffHeap())
+	for round := 0; round < 3; round++ {
+		for i := 0; i < 1000; i++ {
+			s.Set(fmt.Sprintf("key-%d", i), fmt.Sprintf("value-%d-%d", round, i), 0)
+		}
+	}
+	for i := 0; i < 1000; i++ {
+		want := fmt.Sprintf("value-2-%d", i)
+		if got, _ := s.Get(fmt.Sprintf("key-%d", i)); got != want {
+			t.Fatalf("key-%d: expected %q, got %q", i, want, got)
+		}
+	}
+
+	tbl := s.items.(*slabTable)
+	c := tbl.classes[slabClassFor(slabHeaderSize+len("key-999")+len("value-2-999"))]
+	if c.next > 1001 {
+		t.Errorf("expected overwritten chunks to be reused, %d handed out", c.next)
+	}
+}
+
+func TestOffHeap_Expiration(t *testing.T) {
+	s := New(WithOffHeap())
+	s.Set("short", "v", 10*time.Millisecond)
+	s.Set("long", "v", 0)
+	time.Sleep(20 * time.Millisecond)
+
+	if _, ok := s.Get("short"); ok {
+		t.Fatal("key should have expired")
+	}
+	s.deleteExpired()
+	if s.Len() != 1 {
+		t.Fatalf("expected expired key to be removed, have %d items", s.Len())
+	}
+}
+
+func TestOffHeap_SnapshotRestore(t *testing.T) {
+	s := New(WithOffHeap())
+	for i := 0; i < 100; i++ {
+		s.Set(fmt.Sprintf("key-%d", i), fmt.Sprintf("value-%d", i), 0)
+	}
+
+	view := s.Freeze()
+	s.Set("key-0", "changed", 0)
+
+	var buf bytes.Buffer
+	if err := view.Snapshot(&buf); err != nil {
+		t.Fatalf("snapshot: %v", err)
+	}
+
+	restored := New(WithOffHeap())
+	if err := restored.Restore(&buf); err != nil {
+		t.Fatalf("restore: %v", err)
+	}
+	if restored.Len() != 100 {
+		t.Fatalf("expected 100 items, got %d", restored.Len())
+	}
+	if got, _ := restored.Get("key-0"); got != "value-0" {
+		t.Errorf("expected point-in-time value, got %q", got)
+	}
+}
+
+func TestSlabTable_HashCollision(t *testing.T) {
+	tbl := newSlabTable()
+	tbl.set("a", &Item{Value: "1"})
+
+	// Force "b" to collide with "a" by planting a's entry under b's hash.
+	aRef, _, _ := tbl.lookup("a")
+	tbl.index = map[uint64]slabRef{maphash.String(tbl.seed, "b"): aRef}
+
+	tbl.set("b", &Item{Value: "2"})
+	if _, inOverlap, ok := tbl.lookup("b"); !ok || !inOverlap {
+		t.Fatal("expected colliding key to be stored in the overlap map")
+	}
+	if item, _ := tbl.get("b"); item.Value != "2" {
+		t.Errorf("expected 2, got %q", item.Value)
+	}
+	if !tbl.delete("b") || tbl.has("b") {
+		t.Error("expected colliding key to be deleted")
+	}
+}
@@ -0,0 +1,295 @@
+package store
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	coreerrors "distributed-cache-service/internal/core/errors"
+	"distributed-cache-service/internal/core/ports"
+)
+
+// Snapshot stream format
+//
+// A snapshot is a header followed by a sequence of length-prefixed records:
+//
+//	header: magic "DCSNAP" | version (uvarint)
+//	record: key length (uvarint) | key | value length (uvarint) | value | expiration (varint)
+//
+// Version 2 prefixes every record with a kind byte so sorted sets can be stored
+// alongside string items:
+//
+//	record: 'i' | key | value | expiration                   (string item, as in version 1)
+//	record: 'z' | key | count (uvarint) | {member | score (8 bytes)} * count
+//
+// Writers emit version 1 unless the keyspace holds sorted sets, so snapshots
+// stay readable by older nodes until the feature is used.
+//
+// Every version ever written stays readable: snapshotFormats describes each, and
+// records of older versions are migrated to the current Item as they are read.
+// A change to the record layout, e.g. a new Item field, takes a new version
+// with its own entry there; its writer only emits it when the snapshot needs
+// it, and older versions decode with the new field at its default.
+// testdata holds a snapshot of each version, as older binaries wrote them.
+//
+// Records are written one at a time from an iterator, so encoding never builds a
+// second copy of the keyspace in memory. The stream ends at EOF.
+// Snapshots written before this format existed are a single JSON object; Restore
+// still accepts them so nodes can be upgraded in place.
+const (
+	snapshotMagic   = "DCSNAP"
+	snapshotVersion = 2
+
+	snapshotKindItem = 'i'
+	snapshotKindZSet = 'z'
+
+	// maxSnapshotField bounds a single key or value length to protect against corrupt input.
+	maxSnapshotField = 512 << 20
+)
+
+// snapshotFormat describes the record layout of a snapshot version.
+type snapshotFormat struct {
+	// kinds reports whether each record starts with its kind byte; without
+	// one, every record is a string item.
+	kinds bool
+}
+
+// snapshotFormats lists every snapshot version ever written.
+var snapshotFormats = map[uint64]snapshotFormat{
+	1: {},
+	2: {kinds: true},
+}
+
+// ErrUnsupportedSnapshotVersion is returned when a snapshot was written by a newer, incompatible version.
+var ErrUnsupportedSnapshotVersion = errors.New("unsupported snapshot version")
+
+// SnapshotWriter encodes records in the streaming snapshot format.
+// It is exported so alternative storage backends produce compatible snapshots.
+type SnapshotWriter struct {
+	w       *bufio.Writer
+	version int
+	buf     [binary.MaxVarintLen64]byte
+}
+
+// NewSnapshotWriter writes the snapshot header to w and returns a writer for records.
+// It writes version 1, which holds string items only.
+func NewSnapshotWriter(w io.Writer) (*SnapshotWriter, error) {
+	return newSnapshotWriter(w, 1)
+}
+
+func newSnapshotWriter(w io.Writer, version int) (*SnapshotWriter, error) {
+	sw := &SnapshotWriter{w: bufio.NewWriter(w), version: version}
+	if _, err := sw.w.WriteString(snapshotMagic); err != nil {
+		return nil, err
+	}
+	if err := sw.writeUvarint(uint64(version)); err != nil {
+		return nil, err
+	}
+	return sw, nil
+}
+
+func (sw *SnapshotWriter) writeUvarint(v uint64) error {
+	n := binary.PutUvarint(sw.buf[:], v)
+	_, err := sw.w.Write(sw.buf[:n])
+	return err
+}
+
+func (sw *SnapshotWriter) writeString(s string) error {
+	if err := sw.writeUvarint(uint64(len(s))); err != nil {
+		return err
+	}
+	_, err := sw.w.WriteString(s)
+	return err
+}
+
+// WriteItem appends a single key/item record.
+func (sw *SnapshotWriter) WriteItem(key string, item *Item) error {
+	if sw.version > 1 {
+		if err := sw.w.WriteByte(snapshotKindItem); err != nil {
+			return err
+		}
+	}
+	if err := sw.writeString(key); err != nil {
+		return err
+	}
+	if err := sw.writeString(item.Value); err != nil {
+		return err
+	}
+	n := binary.PutVarint(sw.buf[:], item.Expiration)
+	_, err := sw.w.Write(sw.buf[:n])
+	return err
+}
+
+// writeSortedSet appends a sorted set record. It requires version 2.
+func (sw *SnapshotWriter) writeSortedSet(key string, z *zset) error {
+	if err := sw.w.WriteByte(snapshotKindZSet); err != nil {
+		return err
+	}
+	if err := sw.writeString(key); err != nil {
+		return err
+	}
+	if err := sw.writeUvarint(uint64(z.len())); err != nil {
+		return err
+	}
+	var score [8]byte
+	return z.forEach(func(member string, s float64) error {
+		if err := sw.writeString(member); err != nil {
+			return err
+		}
+		_, err := sw.w.Write(appendFloat(score[:0], s))
+		return err
+	})
+}
+
+// Flush writes any buffered data to the underlying writer.
+func (sw *SnapshotWriter) Flush() error {
+	return sw.w.Flush()
+}
+
+// ReadSnapshot decodes a snapshot from r, invoking fn for every record.
+// Legacy JSON snapshots are detected by their leading '{' and decoded in full.
+// Snapshots holding sorted sets fail with coreerrors.ErrUnsupported, since
+// callers of ReadSnapshot can only store string items.
+func ReadSnapshot(r io.Reader, fn func(key string, item *Item)) error {
+	return readSnapshot(r, fn, nil)
+}
+
+// ReadSnapshotWithSets is ReadSnapshot for callers that also take sorted
+// sets, which are passed to zfn with their members in order.
+func ReadSnapshotWithSets(r io.Reader, fn func(key string, item *Item), zfn func(key string, members []ports.ScoredMember)) error {
+	return readSnapshot(r, fn, func(key string, z *zset) {
+		zfn(key, z.rangeByRank(0, -1))
+	})
+}
+
+// readSnapshot is ReadSnapshot with a callback for sorted sets; zfn may be nil.
+func readSnapshot(r io.Reader, fn func(key string, item *Item), zfn func(key string, z *zset)) error {
+	br := bufio.NewReader(r)
+	head, err := br.Peek(len(snapshotMagic))
+	if err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+
+	if !bytes.Equal(head, []byte(snapshotMagic)) {
+		return readLegacySnapshot(br, fn)
+	}
+	if _, err := br.Discard(len(snapshotMagic)); err != nil {
+		return err
+	}
+	version, err := binary.ReadUvarint(br)
+	if err != nil {
+		return fmt.Errorf("read snapshot version: %w", err)
+	}
+	format, ok := snapshotFormats[version]
+	if !ok {
+		return fmt.Errorf("%w: %d (max %d)", ErrUnsupportedSnapshotVersion, version, snapshotVersion)
+	}
+
+	for {
+		kind := byte(snapshotKindItem)
+		if format.kinds {
+			kind, err = br.ReadByte()
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("read snapshot record kind: %w", err)
+			}
+		}
+		key, err := readString(br)
+		if errors.Is(err, io.EOF) && !format.kinds {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("read snapshot key: %w", unexpectedEOF(err))
+		}
+		switch kind {
+		case snapshotKindItem:
+		case snapshotKindZSet:
+			if zfn == nil {
+				return fmt.Errorf("snapshot holds sorted set %q: %w", key, coreerrors.ErrUnsupported)
+			}
+			z, err := readSortedSet(br)
+			if err != nil {
+				return fmt.Errorf("read snapshot sorted set %q: %w", key, err)
+			}
+			zfn(key, z)
+			continue
+		default:
+			return fmt.Errorf("unknown snapshot record kind %q", kind)
+		}
+		value, err := readString(br)
+		if err != nil {
+			return fmt.Errorf("read snapshot value for %q: %w", key, unexpectedEOF(err))
+		}
+		exp, err := binary.ReadVarint(br)
+		if err != nil {
+			return fmt.Errorf("read snapshot expiration for %q: %w", key, unexpectedEOF(err))
+		}
+		fn(key, &Item{Value: value, Expiration: exp})
+	}
+}
+
+func readSortedSet(br *bufio.Reader) (*zset, error) {
+	n, err := binary.ReadUvarint(br)
+	if err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	z := newZSet()
+	for i := uint64(0); i < n; i++ {
+		member, err := readString(br)
+		if err != nil {
+			return nil, unexpectedEOF(err)
+		}
+		score, err := readFloat(br)
+		if err != nil {
+			return nil, err
+		}
+		z.add(member, score)
+	}
+	return z, nil
+}
+
+// byteReader is satisfied by *bufio.Reader and the AOF's counting reader.
+type byteReader interface {
+	io.Reader
+	io.ByteReader
+}
+
+func readString(br byteReader) (string, error) {
+	n, err := binary.ReadUvarint(br)
+	if err != nil {
+		return "", err
+	}
+	if n > maxSnapshotField {
+		return "", fmt.Errorf("field length %d exceeds limit", n)
+	}
+	b := make([]byte, n)
+	if _, err := io.ReadFull(br, b); err != nil {
+		return "", unexpectedEOF(err)
+	}
+	return string(b), nil
+}
+
+func unexpectedEOF(err error) error {
+	if errors.Is(err, io.EOF) {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+// readLegacySnapshot decodes the original single-document JSON format.
+func readLegacySnapshot(r io.Reader, fn func(key string, item *Item)) error {
+	items := make(map[string]*Item)
+	if err := json.NewDecoder(r).Decode(&items); err != nil {
+		return err
+	}
+	for k, v := range items {
+		fn(k, v)
+	}
+	return nil
+}
@@ -0,0 +1,153 @@
+package store
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"distributed-cache-service/internal/core/ports"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStore_SnapshotRestore(t *testing.T) {
+	src := New()
+	src.Set("k1", "v1", 0)
+	src.Set("k2", strings.Repeat("x", 1000), 0)
+	src.Set("", "empty-key", 0)
+
+	var buf bytes.Buffer
+	require.NoError(t, src.Snapshot(&buf))
+	assert.True(t, bytes.HasPrefix(buf.Bytes(), []byte(snapshotMagic)))
+
+	dst := New()
+	dst.Set("stale", "gone", 0)
+	require.NoError(t, dst.Restore(&buf))
+
+	assert.Equal(t, 3, dst.Len())
+	val, found := dst.Get("k2")
+	assert.True(t, found)
+	assert.Len(t, val, 1000)
+	_, found = dst.Get("stale")
+	assert.False(t, found, "restore must replace existing state")
+}
+
+func TestStore_RestoreLegacyJSON(t *testing.T) {
+	s := New()
+	require.NoError(t, s.Restore(strings.NewReader(`{"k1":{"value":"v1","expiration":0}}`)))
+
+	val, found := s.Get("k1")
+	assert.True(t, found)
+	assert.Equal(t, "v1", val)
+}
+
+// Snapshots written by older binaries, in every format, still restore.
+func TestStore_RestoreOlderFormats(t *testing.T) {
+	for _, name := range []string{"snapshot-json.json", "snapshot-v1.bin", "snapshot-v2.bin"} {
+		t.Run(name, func(t *testing.T) {
+			f, err := os.Open(filepath.Join("testdata", name))
+			require.NoError(t, err)
+			defer f.Close()
+			s := New()
+			require.NoError(t, s.Restore(f))
+
+			val, found := s.Get("plain")
+			assert.True(t, found)
+			assert.Equal(t, "value", val)
+			ttl, _ := s.TTL("plain")
+			assert.Zero(t, ttl)
+			val, _ = s.Get("expiring")
+			assert.Equal(t, "later", val)
+			ttl, _ = s.TTL("expiring")
+			assert.Equal(t, time.Unix(4102444800, 0).Round(time.Second), time.Now().Add(ttl).Round(time.Second))
+
+			if name == "snapshot-v2.bin" {
+				members, err := s.ZRange("scores", 0, -1)
+				require.NoError(t, err)
+				assert.Equal(t, []ports.ScoredMember{{Member: "a", Score: 1}, {Member: "b", Score: 2.5}}, members)
+			}
+		})
+	}
+}
+
+func TestStore_RestoreRejectsNewerVersion(t *testing.T) {
+	var buf bytes.Buffer
+	buf.WriteString(snapshotMagic)
+	buf.Write(binary.AppendUvarint(nil, snapshotVersion+1))
+
+	err := New().Restore(&buf)
+	assert.True(t, errors.Is(err, ErrUnsupportedSnapshotVersion))
+}
+
+func TestStore_RestoreTruncated(t *testing.T) {
+	src := New()
+	src.Set("key", "value", 0)
+	var buf bytes.Buffer
+	require.NoError(t, src.Snapshot(&buf))
+
+	dst := New()
+	dst.Set("keep", "me", 0)
+	err := dst.Restore(bytes.NewReader(buf.Bytes()[:buf.Len()-3]))
+	assert.Error(t, err)
+
+	_, found := dst.Get("keep")
+	assert.True(t, found, "failed restore must leave state untouched")
+}
+
+func TestStore_FreezeIsPointInTime(t *testing.T) {
+	s := New()
+	s.Set("k1", "before", 0)
+
+	view := s.Freeze()
+	s.Set("k1", "after", 0)
+	s.Set("k2", "new", 0)
+
+	var buf bytes.Buffer
+	require.NoError(t, view.Snapshot(&buf))
+
+	dst := New()
+	require.NoError(t, dst.Restore(&buf))
+	assert.Equal(t, 1, dst.Len())
+	val, _ := dst.Get("k1")
+	assert.Equal(t, "before", val)
+}
+
+func TestStore_Range(t *testing.T) {
+	for _, offHeap := range []bool{false, true} {
+		var opts []Option
+		if offHeap {
+			opts = append(opts, WithOffHeap())
+		}
+		s := New(opts...)
+		s.Set("k1", "v1", 0)
+		s.SetExpiresAt("k2", "v2", time.Unix(1, 0))
+		_, err := s.ZAdd("z", time.Now(), ports.ScoredMember{Member: "m", Score: 1})
+		require.NoError(t, err)
+
+		got := make(map[string]Item)
+		s.Range(func(key string, item Item) bool {
+			// Writing from fn neither deadlocks nor shows up in the view.
+			s.Set("k1", "changed", 0)
+			s.Set("k3", "new", 0)
+			got[key] = item
+			return true
+		})
+		assert.Equal(t, map[string]Item{
+			"k1": {Value: "v1"},
+			"k2": {Value: "v2", Expiration: time.Unix(1, 0).UnixNano()},
+		}, got, "offHeap=%v", offHeap)
+
+		visited := 0
+		s.Range(func(string, Item) bool {
+			visited++
+			return false
+		})
+		assert.Equal(t, 1, visited, "offHeap=%v", offHeap)
+	}
+}
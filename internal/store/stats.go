@@ -0,0 +1,118 @@
+package store
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Stats describes the store's internals, for debugging.
+type Stats struct {
+	Items      int       `json:"items"`
+	SortedSets int       `json:"sorted_sets"`
+	Capacity   int       `json:"capacity"`
+	OffHeap    bool      `json:"off_heap"`
+	Lock       LockStats `json:"lock"`
+	// Slabs lists the slab classes holding entries, when OffHeap is set.
+	Slabs []SlabStats `json:"slabs,omitempty"`
+	// Prefixes counts the distinct key prefixes held, when interned (see
+	// WithInternedPrefixes).
+	Prefixes int `json:"prefixes,omitempty"`
+}
+
+// LockStats counts acquisitions of the lock guarding the store. Every
+// operation takes it, so a high share of contended acquisitions, or a long
+// total wait, means callers are queuing behind each other.
+type LockStats struct {
+	Acquired    uint64  `json:"acquired"`
+	Contended   uint64  `json:"contended"`
+	WaitSeconds float64 `json:"wait_seconds"`
+}
+
+// SlabStats describes one slab class of an off-heap store. The huge class,
+// for entries too large for any chunk, has a ChunkSize of 0.
+type SlabStats struct {
+	ChunkSize  int    `json:"chunk_size"`
+	Pages      int    `json:"pages"`
+	UsedChunks uint32 `json:"used_chunks"`
+	FreeChunks int    `json:"free_chunks"`
+}
+
+// Stats reports the store's size, lock contention and memory layout.
+func (s *Store) Stats() Stats {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	st := Stats{
+		Items:      s.items.len(),
+		SortedSets: len(s.zsets),
+		Capacity:   s.capacity,
+		OffHeap:    s.offHeap,
+		Lock:       s.mu.stats(),
+	}
+	if t, ok := s.items.(*prefixTable); ok {
+		st.Prefixes = len(t.groups)
+	}
+	if t, ok := s.items.(*slabTable); ok {
+		for i := range t.classes {
+			c := &t.classes[i]
+			if c.next > 0 {
+				st.Slabs = append(st.Slabs, SlabStats{
+					ChunkSize:  c.chunkSize,
+					Pages:      len(c.pages),
+					UsedChunks: c.next - uint32(len(c.free)),
+					FreeChunks: len(c.free),
+				})
+			}
+		}
+		if len(t.huge) > 0 {
+			st.Slabs = append(st.Slabs, SlabStats{
+				UsedChunks: uint32(len(t.huge) - len(t.hugeFree)),
+				FreeChunks: len(t.hugeFree),
+			})
+		}
+	}
+	return st
+}
+
+// countingRWMutex is a sync.RWMutex that counts its acquisitions and the time
+// callers spend waiting for it. An uncontended acquisition costs one atomic
+// add more than a plain RWMutex.
+type countingRWMutex struct {
+	sync.RWMutex
+	acquired  atomic.Uint64
+	contended atomic.Uint64
+	waited    atomic.Int64 // nanoseconds
+}
+
+func (m *countingRWMutex) Lock() {
+	m.acquired.Add(1)
+	if m.RWMutex.TryLock() {
+		return
+	}
+	start := time.Now()
+	m.RWMutex.Lock()
+	m.contend(start)
+}
+
+func (m *countingRWMutex) RLock() {
+	m.acquired.Add(1)
+	if m.RWMutex.TryRLock() {
+		return
+	}
+	start := time.Now()
+	m.RWMutex.RLock()
+	m.contend(start)
+}
+
+func (m *countingRWMutex) contend(start time.Time) {
+	m.contended.Add(1)
+	m.waited.Add(int64(time.Since(start)))
+}
+
+func (m *countingRWMutex) stats() LockStats {
+	return LockStats{
+		Acquired:    m.acquired.Load(),
+		Contended:   m.contended.Load(),
+		WaitSeconds: time.Duration(m.waited.Load()).Seconds(),
+	}
+}
@@ -0,0 +1,57 @@
+package store
+
+import (
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"distributed-cache-service/internal/core/ports"
+)
+
+func TestStore_Stats(t *testing.T) {
+	s := New(WithCapacity(10), WithOffHeap())
+	s.Set("a", "1", 0)
+	s.Set("b", strings.Repeat("x", 100), 0)
+	s.Delete("b")
+	if _, err := s.ZAdd("z", time.Now(), ports.ScoredMember{Member: "m", Score: 1}); err != nil {
+		t.Fatal(err)
+	}
+
+	st := s.Stats()
+	if st.Items != 1 || st.SortedSets != 1 || st.Capacity != 10 || !st.OffHeap {
+		t.Errorf("unexpected stats %+v", st)
+	}
+	if st.Lock.Acquired == 0 {
+		t.Errorf("expected lock acquisitions to be counted, got %+v", st.Lock)
+	}
+	if len(st.Slabs) != 2 {
+		t.Fatalf("expected 2 slab classes in use, got %+v", st.Slabs)
+	}
+	if c := st.Slabs[0]; c.ChunkSize != 64 || c.Pages != 1 || c.UsedChunks != 1 || c.FreeChunks != 0 {
+		t.Errorf("unexpected 64 B class %+v", c)
+	}
+	if c := st.Slabs[1]; c.ChunkSize != 128 || c.UsedChunks != 0 || c.FreeChunks != 1 {
+		t.Errorf("unexpected 128 B class %+v", c)
+	}
+}
+
+func TestStore_StatsCountsContention(t *testing.T) {
+	s := New()
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				s.Set("k", "v", 0)
+			}
+		}()
+	}
+	wg.Wait()
+
+	lock := s.Stats().Lock
+	if lock.Acquired < 8000 || lock.Contended > lock.Acquired {
+		t.Errorf("unexpected lock stats %+v", lock)
+	}
+}
@@ -1,15 +1,23 @@
 package store
 
 import (
-	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"sync"
+	"sync/atomic"
 	"time"
 
+	"distributed-cache-service/internal/core/ports"
 	"distributed-cache-service/internal/store/policy"
 )
 
+// ensure implementation
+var _ ports.SnapshotStorage = (*Store)(nil)
+
 // Item represents a single cached value with its metadata.
+// Items are never modified after being stored; updates replace the pointer.
+// This lets Freeze share them with point-in-time views without copying.
 type Item struct {
 	Value      string `json:"value"`
 	Expiration int64  `json:"expiration"` // Unix timestamp in nanoseconds when this item expires. 0 means no expiration.
@@ -19,10 +27,30 @@ type Item struct {
 // It supports TTL-based expiration and basic CRUD operations.
 // All public methods are safe for concurrent use.
 type Store struct {
-	mu       sync.RWMutex
-	items    map[string]*Item
+	mu       countingRWMutex // see Stats
+	items    table
+	zsets    map[string]*zset // sorted sets, kept apart from items (see zset.go)
+	offHeap  bool
 	capacity int
 	policy   policy.EvictionPolicy
+	deferred bool // see WithDeferredEviction
+
+	internPrefixes bool // see WithInternedPrefixes
+
+	cleanupMu   sync.Mutex
+	stopCleanup chan struct{}
+
+	aof *aof // optional append-only file, see OpenAOF
+
+	bloom   atomic.Pointer[bloomFilter] // optional, see WithBloomFilter
+	bloomFP float64
+
+	usagePrefixes []string // longest first, see WithUsagePrefixes
+	usage         map[string]*ports.Usage
+
+	memory atomic.Int64 // bytes all keys take up, see MemoryStats
+
+	onEvict []EvictFunc // see OnEvict
 }
 
 // Option defines a functional option for configuring the store.
@@ -42,25 +70,66 @@ func WithPolicy(p policy.EvictionPolicy) Option {
 	}
 }
 
+// WithDeferredEviction stops writes from evicting when the store is full, so
+// it may exceed its capacity until the owner deletes the keys returned by
+// EvictionVictims. Under Raft this keeps every replica's state identical: the
+// leader chooses the victims and replicates their deletion, rather than each
+// node evicting by its own access history.
+func WithDeferredEviction() Option {
+	return func(s *Store) {
+		s.deferred = true
+	}
+}
+
+// WithOffHeap stores items in pointer-free slab pages instead of a map of
+// *Item. This keeps GC pauses short for caches with millions of keys, at the
+// cost of copying values out on every read. Freed chunks are reused but pages
+// are never returned to the runtime.
+func WithOffHeap() Option {
+	return func(s *Store) {
+		s.offHeap = true
+	}
+}
+
 // New creates a new, empty Store instance with optional configuration.
 // Default capacity is 0 (unlimited) and policy is nil (no eviction).
 func New(opts ...Option) *Store {
 	s := &Store{
-		items:    make(map[string]*Item),
 		capacity: 0,               // Default unlimited
 		policy:   policy.NewLRU(), // Default LRU if capacity set? Or just nil.
 	}
 	for _, opt := range opts {
 		opt(s)
 	}
+	s.items = s.newTable()
+	s.zsets = make(map[string]*zset)
 	return s
 }
 
+func (s *Store) newTable() table {
+	if s.offHeap {
+		return newSlabTable()
+	}
+	if s.internPrefixes {
+		return newPrefixTable()
+	}
+	return newMapTable()
+}
+
 // Get retrieves the value associated with the given key.
 // It returns the value and true if the key exists and has not expired.
 // If the key is not found or has expired, it returns an empty string and false.
 // It updates the eviction policy (if any) to mark the key as accessed.
 func (s *Store) Get(key string) (string, bool) {
+	return s.GetAt(key, time.Now())
+}
+
+// GetAt is like Get but judges expiry at now rather than by the local clock,
+// so that replicas applying the same log entry agree on whether key exists.
+func (s *Store) GetAt(key string, now time.Time) (string, bool) {
+	if s.absent(key) {
+		return "", false
+	}
 	s.mu.Lock() // Lock for policy update
 	defer s.mu.Unlock()
 
@@ -68,12 +137,12 @@ func (s *Store) Get(key string) (string, bool) {
 	// Optimally, we could use RLock first, check existence, RUnlock, then Lock for policy update if needed,
 	// but that introduces race conditions or complexity. For this implementation, simple Lock is safer.
 
-	item, found := s.items[key]
+	item, found := s.items.get(key)
 	if !found {
 		return "", false
 	}
 
-	if item.Expiration > 0 && time.Now().UnixNano() > item.Expiration {
+	if item.Expiration > 0 && now.UnixNano() > item.Expiration {
 		// Lazy deletion? Or just return not found.
 		// If we return not found, we should probably delete it or let cleanup handle it.
 		// Policy OnAccess should probably NOT be called if expired.
@@ -91,36 +160,134 @@ func (s *Store) Get(key string) (string, bool) {
 // If ttl is 0, the item will never expire.
 // If the store is full, it triggers eviction based on the configured policy.
 func (s *Store) Set(key, value string, ttl time.Duration) {
+	var expiresAt time.Time
+	if ttl > 0 {
+		expiresAt = time.Now().Add(ttl)
+	}
+	s.SetExpiresAt(key, value, expiresAt)
+}
+
+// SetExpiresAt is like Set but with an absolute expiration time; the zero time
+// means the item never expires. An expiration in the past stores an already
+// expired item.
+func (s *Store) SetExpiresAt(key, value string, expiresAt time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.setItem(key, &Item{
+		Value:      value,
+		Expiration: unixNano(expiresAt),
+	})
+}
+
+// unixNano converts an expiration time to Item.Expiration.
+func unixNano(t time.Time) int64 {
+	if t.IsZero() {
+		return 0
+	}
+	return t.UnixNano()
+}
+
+// Replace overwrites the value of a key existing and unexpired at now, keeping
+// its expiration. It reports false, storing nothing, if there is no such key.
+func (s *Store) Replace(key, value string, now time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	item, found := s.items.get(key)
+	if !found || (item.Expiration > 0 && now.UnixNano() > item.Expiration) {
+		return false
+	}
+	s.setItem(key, &Item{Value: value, Expiration: item.Expiration})
+	return true
+}
+
+// TTL returns the remaining lifetime of key, or 0 if it does not expire.
+// found is false if there is no such unexpired key.
+func (s *Store) TTL(key string) (time.Duration, bool) {
+	if s.absent(key) {
+		return 0, false
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	item, found := s.items.get(key)
+	now := time.Now().UnixNano()
+	if !found || (item.Expiration > 0 && now > item.Expiration) {
+		return 0, false
+	}
+	if item.Expiration == 0 {
+		return 0, true
+	}
+	return time.Duration(item.Expiration - now), true
+}
+
+// GetStale returns the value of key and when it expires, even if it already
+// has. Unlike Get, it does not count as an access for the eviction policy.
+func (s *Store) GetStale(key string) (string, time.Time, bool) {
+	if s.absent(key) {
+		return "", time.Time{}, false
+	}
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	item, found := s.items.get(key)
+	if !found {
+		return "", time.Time{}, false
+	}
+	var expiresAt time.Time
+	if item.Expiration > 0 {
+		expiresAt = time.Unix(0, item.Expiration)
+	}
+	return item.Value, expiresAt, true
+}
+
+// ExpireAt sets a key existing and unexpired at now to expire at expiresAt, or
+// never if it is the zero time, keeping its value. It reports false if there is
+// no such key.
+func (s *Store) ExpireAt(key string, expiresAt, now time.Time) bool {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
+	item, found := s.items.get(key)
+	if !found || (item.Expiration > 0 && now.UnixNano() > item.Expiration) {
+		return false
+	}
+	s.setItem(key, &Item{Value: item.Value, Expiration: unixNano(expiresAt)})
+	return true
+}
+
+// setItem stores item under key, updating the eviction policy and evicting if full.
+// Caller must hold s.mu.
+func (s *Store) setItem(key string, item *Item) {
 	// Check if update
-	if _, exists := s.items[key]; exists {
+	if s.items.has(key) {
 		if s.policy != nil {
 			s.policy.OnAccess(key)
 		}
+		s.evicted(key, item, EvictReplaced)
 	} else {
 		// New item
 		// Evict if full
-		if s.capacity > 0 && len(s.items) >= s.capacity && s.policy != nil {
+		if !s.deferred && s.capacity > 0 && s.items.len() >= s.capacity && s.policy != nil {
 			victim := s.policy.SelectVictim()
 			if victim != "" {
-				s.deleteInternal(victim)
+				s.deleteInternal(victim, EvictCapacity)
 			}
 		}
 		if s.policy != nil {
 			s.policy.OnAdd(key)
 		}
+		s.bloomAdd(key)
 	}
 
-	expiration := int64(0)
-	if ttl > 0 {
-		expiration = time.Now().Add(ttl).UnixNano()
-	}
-
-	s.items[key] = &Item{
-		Value:      value,
-		Expiration: expiration,
+	// A string write replaces a sorted set at the same key.
+	s.memory.Add(itemMemory(key, item) - s.keyMemory(key))
+	delete(s.zsets, key)
+	s.account(key, item)
+	s.items.set(key, item)
+	if s.aof != nil {
+		s.aof.appendSet(key, item)
 	}
 }
 
@@ -129,56 +296,401 @@ func (s *Store) Set(key, value string, ttl time.Duration) {
 func (s *Store) Delete(key string) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	s.deleteInternal(key)
+	s.deleteInternal(key, EvictExplicit)
 }
 
-func (s *Store) deleteInternal(key string) {
-	if _, exists := s.items[key]; exists {
-		delete(s.items, key)
+// deleteInternal removes key, reporting its value as leaving for reason.
+// Caller must hold s.mu.
+func (s *Store) deleteInternal(key string, reason EvictReason) {
+	s.evicted(key, nil, reason)
+	s.account(key, nil)
+	s.memory.Add(-s.keyMemory(key))
+	if s.items.delete(key) {
 		if s.policy != nil {
 			s.policy.OnRemove(key)
 		}
+		s.bloomRemove(key)
+	} else if _, ok := s.zsets[key]; ok {
+		delete(s.zsets, key)
+	} else {
+		return
+	}
+	if s.aof != nil {
+		s.aof.appendDelete(key)
 	}
 }
 
 // StartCleanup starts a background goroutine that periodically removes expired items.
 // The cleanup runs at the specified interval.
-// Note: This function spawns a goroutine and does not provide a way to stop it in this simple implementation.
-// It is intended to be called once at application startup.
+// Calling StartCleanup again replaces the running cleanup loop, which allows the
+// interval to be changed at runtime. An interval <= 0 stops the cleanup loop.
+// Replicas must not clean up by their own clocks; under Raft, expired keys are
+// removed with DeleteExpired instead.
 func (s *Store) StartCleanup(interval time.Duration) {
+	s.cleanupMu.Lock()
+	defer s.cleanupMu.Unlock()
+
+	if s.stopCleanup != nil {
+		close(s.stopCleanup)
+		s.stopCleanup = nil
+	}
+	if interval <= 0 {
+		return
+	}
+
+	stop := make(chan struct{})
+	s.stopCleanup = stop
 	go func() {
 		ticker := time.NewTicker(interval)
 		defer ticker.Stop()
-		for range ticker.C {
-			s.deleteExpired()
+		for {
+			select {
+			case <-ticker.C:
+				s.deleteExpired()
+			case <-stop:
+				return
+			}
 		}
 	}()
 }
 
+// SetCapacity changes the maximum number of items at runtime.
+// If the store currently holds more items than the new capacity, victims are
+// evicted according to the configured policy until it fits, unless eviction is
+// deferred (see WithDeferredEviction).
+func (s *Store) SetCapacity(capacity int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.capacity = capacity
+	s.evictToCapacity()
+}
+
+// SetPolicy replaces the eviction policy at runtime.
+// Existing keys are registered with the new policy; their previous access
+// history is not carried over.
+func (s *Store) SetPolicy(p policy.EvictionPolicy) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.policy = p
+	if p != nil {
+		s.items.keys(func(k string, _ int64) {
+			p.OnAdd(k)
+		})
+	}
+	s.evictToCapacity()
+}
+
+// Capacity returns the configured maximum number of items (0 = unlimited).
+func (s *Store) Capacity() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.capacity
+}
+
+// Len returns the number of items currently held, including expired items not yet cleaned up.
+func (s *Store) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.items.len() + len(s.zsets)
+}
+
+// evictToCapacity evicts items until the store fits its capacity, taking
+// the victims from the policy in batches. Caller must hold s.mu.
+func (s *Store) evictToCapacity() {
+	if s.deferred || s.capacity <= 0 || s.policy == nil {
+		return
+	}
+	for over := s.items.len() - s.capacity; over > 0; {
+		victims := s.policy.SelectVictims(over)
+		for _, victim := range victims {
+			s.deleteInternal(victim, EvictCapacity)
+		}
+		// A policy naming no key the store holds would never let it shrink.
+		next := s.items.len() - s.capacity
+		if len(victims) == 0 || next == over {
+			return
+		}
+		over = next
+	}
+}
+
+// EvictionVictims returns up to limit keys that the eviction policy would
+// evict to bring the store back within its capacity, or nil if it fits.
+func (s *Store) EvictionVictims(limit int) []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	over := s.items.len() - s.capacity
+	if s.capacity <= 0 || s.policy == nil || over <= 0 {
+		return nil
+	}
+	return s.policy.SelectVictims(min(over, limit))
+}
+
+// Evict removes key, expired or not, reporting whether it existed.
+// Unlike Get it does not count as an access.
+func (s *Store) Evict(key string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.items.has(key) {
+		return false
+	}
+	s.deleteInternal(key, EvictCapacity)
+	return true
+}
+
+// ExpiredKeys returns up to limit keys that had expired by now.
+func (s *Store) ExpiredKeys(now time.Time, limit int) []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var keys []string
+	at := now.UnixNano()
+	s.items.keys(func(k string, expiration int64) {
+		if len(keys) < limit && expiration > 0 && at > expiration {
+			keys = append(keys, k)
+		}
+	})
+	return keys
+}
+
+// DeleteExpired removes key if it had expired by now, reporting whether it did.
+// Because expirations are absolute, replicas given the same now agree on the
+// outcome regardless of their own clocks.
+func (s *Store) DeleteExpired(key string, now time.Time) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	item, found := s.items.get(key)
+	if !found || item.Expiration == 0 || now.UnixNano() <= item.Expiration {
+		return false
+	}
+	s.deleteInternal(key, EvictTTL)
+	return true
+}
+
 func (s *Store) deleteExpired() {
 	now := time.Now().UnixNano()
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	for k, v := range s.items {
-		if v.Expiration > 0 && now > v.Expiration {
-			delete(s.items, k)
+	s.items.keys(func(k string, expiration int64) {
+		if expiration > 0 && now > expiration {
+			s.deleteInternal(k, EvictTTL)
 		}
+	})
+}
+
+// OpenAOF enables append-only persistence at path.
+// Any existing log is replayed into the store first, so a node restarted
+// without Raft recovers its data. Subsequent mutations are appended to the log
+// and flushed according to fsync. The log is compacted automatically in the
+// background once it has doubled in size since the last rewrite.
+func (s *Store) OpenAOF(path string, fsync FsyncPolicy) error {
+	a, err := openAOF(path, fsync)
+	if err != nil {
+		return err
 	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.aof != nil {
+		a.close()
+		return fmt.Errorf("aof already open")
+	}
+	// Replaying recovers the store rather than changing it.
+	onEvict := s.onEvict
+	s.onEvict = nil
+	err = a.replay(func(rec *aofRecord) {
+		switch rec.op {
+		case aofOpSet:
+			s.setItem(rec.key, rec.item)
+		case aofOpDelete:
+			s.deleteInternal(rec.key, EvictExplicit)
+		case aofOpZAdd:
+			s.zadd(rec.key, rec.member)
+		case aofOpZRemRange:
+			s.zremRangeByScore(rec.key, rec.min, rec.max)
+		}
+	})
+	s.onEvict = onEvict
+	if err != nil {
+		a.close()
+		return err
+	}
+	s.aof = a
+	a.start(func() *Frozen { return s.freezeForRewrite(a) })
+	return nil
+}
+
+// RewriteAOF compacts the append-only file down to the current state.
+// Writes are not blocked while the new file is produced.
+func (s *Store) RewriteAOF() error {
+	s.mu.RLock()
+	a := s.aof
+	s.mu.RUnlock()
+	if a == nil {
+		return fmt.Errorf("aof not enabled")
+	}
+	return a.rewrite(func() *Frozen { return s.freezeForRewrite(a) })
+}
+
+// CloseAOF flushes and closes the append-only file, if enabled.
+func (s *Store) CloseAOF() error {
+	s.mu.Lock()
+	a := s.aof
+	s.aof = nil
+	s.mu.Unlock()
+	if a == nil {
+		return nil
+	}
+	return a.close()
+}
+
+// freezeForRewrite captures a view and starts buffering AOF appends atomically,
+// so no mutation falls between the view and the rewrite buffer.
+func (s *Store) freezeForRewrite(a *aof) *Frozen {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	a.beginRewrite()
+	return &Frozen{items: s.items.clone(), zsets: s.cloneZSets()}
 }
 
 // Snapshot serializes the current state of the store to the provided writer (IO sink).
 // This is used by Raft to take snapshots of the state machine.
+// The store is only locked while a point-in-time view is captured (see Freeze);
+// serialization runs without blocking writers.
 func (s *Store) Snapshot(w io.Writer) error {
+	return s.Freeze().Snapshot(w)
+}
+
+// Freeze captures an immutable point-in-time view of the store.
+// It holds the read lock while it shallow-copies the item map, or memcpys
+// every slab page in off-heap mode: O(N) with writers blocked, though much
+// cheaper than serializing every value while they are. Sorted sets are
+// mutable, so they are copied member by member.
+func (s *Store) Freeze() *Frozen {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
-	return json.NewEncoder(w).Encode(s.items)
+	return &Frozen{items: s.items.clone(), zsets: s.cloneZSets()}
+}
+
+// Frozen is an immutable point-in-time view of the store's items.
+// It is safe for concurrent use and unaffected by later writes to the store.
+type Frozen struct {
+	items table
+	zsets map[string]*zset
+}
+
+// PointInTime implements ports.SnapshotStorage by returning Freeze().
+func (s *Store) PointInTime() ports.StateView {
+	return s.Freeze()
+}
+
+// Release drops the view's references so the copied map can be garbage collected.
+func (f *Frozen) Release() {
+	f.items = nil
+	f.zsets = nil
+}
+
+// Len returns the number of keys in the view.
+func (f *Frozen) Len() int {
+	return f.items.len() + len(f.zsets)
+}
+
+// Range calls fn for every item in a point-in-time view of the store (see
+// Freeze), in no particular order, until fn returns false. The view is a copy
+// of the whole table made under the read lock: capturing it blocks writers
+// for O(N), off-heap values included, and keeps a second copy of them until
+// Range returns. fn runs without the lock, so it may take its time, and may
+// even write to the store, without blocking or affecting the iteration. Items
+// that have expired but not yet been purged are included, as in snapshots; fn
+// can tell them by their Expiration. Sorted sets are not visited.
+func (s *Store) Range(fn func(key string, item Item) bool) {
+	f := s.Freeze()
+	defer f.Release()
+	f.Range(fn)
+}
+
+// errStopRange ends a Range early.
+var errStopRange = errors.New("stop range")
+
+// Range calls fn for every item in the view, in no particular order, until
+// fn returns false. Sorted sets are not visited.
+func (f *Frozen) Range(fn func(key string, item Item) bool) {
+	f.items.forEach(func(key string, item *Item) error {
+		if !fn(key, *item) {
+			return errStopRange
+		}
+		return nil
+	})
+}
+
+// Snapshot streams the view to w in the snapshot format (see snapshot.go).
+// Views without sorted sets are written in version 1, which older nodes can read.
+func (f *Frozen) Snapshot(w io.Writer) error {
+	version := 1
+	if len(f.zsets) > 0 {
+		version = snapshotVersion
+	}
+	sw, err := newSnapshotWriter(w, version)
+	if err != nil {
+		return err
+	}
+	if err := f.items.forEach(sw.WriteItem); err != nil {
+		return err
+	}
+	for key, z := range f.zsets {
+		if err := sw.writeSortedSet(key, z); err != nil {
+			return err
+		}
+	}
+	return sw.Flush()
 }
 
 // Restore replaces the current state of the store with the data read from the provided reader.
 // This is used by Raft to restore the state machine from a snapshot.
+// The snapshot is decoded into a fresh map before the store is locked, so a
+// corrupt snapshot leaves the existing state untouched.
 func (s *Store) Restore(r io.Reader) error {
+	items := s.newTable()
+	zsets := make(map[string]*zset)
+	if err := readSnapshot(r, items.set, func(key string, z *zset) { zsets[key] = z }); err != nil {
+		return err
+	}
+	var bloom *bloomFilter
+	if b := s.bloom.Load(); b != nil {
+		bloom = s.newBloom(items, b.capacity)
+	}
+	usage := s.newUsage(items)
+	memory := tableMemory(items, zsets)
+
 	s.mu.Lock()
-	defer s.mu.Unlock()
-	return json.NewDecoder(r).Decode(&s.items)
+	if bloom != nil {
+		// Before the table, so that no restored key is ever ruled out.
+		s.bloom.Store(bloom)
+	}
+	if s.policy != nil {
+		s.items.keys(func(k string, _ int64) {
+			s.policy.OnRemove(k)
+		})
+		items.keys(func(k string, _ int64) {
+			s.policy.OnAdd(k)
+		})
+	}
+	s.items = items
+	s.zsets = zsets
+	s.memory.Store(memory)
+	if usage != nil {
+		s.usage = usage
+	}
+	a := s.aof
+	s.mu.Unlock()
+
+	// The restored state bypassed the AOF, so rewrite it to match.
+	if a != nil {
+		return a.rewrite(func() *Frozen { return s.freezeForRewrite(a) })
+	}
+	return nil
 }
@@ -2,6 +2,8 @@ package store
 
 import (
 	"fmt"
+	"io"
+	"runtime"
 	"testing"
 )
 
@@ -32,3 +34,95 @@ func BenchmarkStore_Get(b *testing.B) {
 		}
 	})
 }
+
+func BenchmarkStore_Snapshot(b *testing.B) {
+	s := New()
+	for i := 0; i < 100000; i++ {
+		s.Set(fmt.Sprintf("key-%d", i), "value", 0)
+	}
+
+	b.ResetTimer()
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if err := s.Snapshot(io.Discard); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkStore_SetOffHeap(b *testing.B) {
+	s := New(WithOffHeap())
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		key := fmt.Sprintf("key-%d", i)
+		s.Set(key, "value", 0)
+	}
+}
+
+// BenchmarkStore_GetMiss looks up keys that do not exist, with and without a
+// Bloom filter in front of the store.
+func BenchmarkStore_GetMiss(b *testing.B) {
+	for _, bench := range []struct {
+		name string
+		opts []Option
+	}{
+		{"plain", nil},
+		{"bloom", []Option{WithBloomFilter(1000, DefaultBloomFalsePositiveRate)}},
+	} {
+		b.Run(bench.name, func(b *testing.B) {
+			s := New(bench.opts...)
+			missing := make([]string, 1000)
+			for i := range missing {
+				s.Set(fmt.Sprintf("key-%d", i), "value", 0)
+				missing[i] = fmt.Sprintf("missing-%d", i)
+			}
+
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				i := 0
+				for pb.Next() {
+					s.Get(missing[i%len(missing)])
+					i++
+				}
+			})
+		})
+	}
+}
+
+// BenchmarkStore_KeyPrefixes reports the heap each key takes up, and the cost
+// of a read, for keys shaped like service:tenant:object:id, with and without
+// interned prefixes.
+func BenchmarkStore_KeyPrefixes(b *testing.B) {
+	const n = 100000
+	keys := make([]string, n)
+	for i := range keys {
+		keys[i] = fmt.Sprintf("billing-service:tenant-%03d:invoice:%08d", i%50, i)
+	}
+	for _, bench := range []struct {
+		name string
+		opts []Option
+	}{
+		{"map", []Option{WithPolicy(nil)}},
+		{"interned", []Option{WithPolicy(nil), WithInternedPrefixes()}},
+		{"offheap", []Option{WithPolicy(nil), WithOffHeap()}},
+	} {
+		b.Run(bench.name, func(b *testing.B) {
+			var before, after runtime.MemStats
+			runtime.GC()
+			runtime.ReadMemStats(&before)
+			s := New(bench.opts...)
+			for _, key := range keys {
+				// Copied, so that the store owns the only reference.
+				s.Set(string([]byte(key)), "v", 0)
+			}
+			runtime.GC()
+			runtime.ReadMemStats(&after)
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				s.Get(keys[i%n])
+			}
+			b.ReportMetric(float64(after.HeapAlloc-before.HeapAlloc)/n, "heap-B/key")
+		})
+	}
+}
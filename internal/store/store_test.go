@@ -45,6 +45,50 @@ func TestStore_TTL(t *testing.T) {
 	}
 }
 
+func TestStore_Expire(t *testing.T) {
+	s := New()
+	if _, found := s.TTL("key"); found {
+		t.Fatal("missing key should have no TTL")
+	}
+	if s.ExpireAt("key", time.Now().Add(time.Minute), time.Now()) {
+		t.Fatal("expire should fail for a missing key")
+	}
+
+	s.Set("key", "val", 0)
+	if ttl, found := s.TTL("key"); !found || ttl != 0 {
+		t.Fatalf("expected no expiration, got %v %v", ttl, found)
+	}
+	if !s.ExpireAt("key", time.Now().Add(time.Minute), time.Now()) {
+		t.Fatal("expire should succeed")
+	}
+	if ttl, _ := s.TTL("key"); ttl <= 59*time.Second || ttl > time.Minute {
+		t.Errorf("expected about a minute, got %v", ttl)
+	}
+
+	// Persisting keeps the value; a short expiration then applies.
+	s.ExpireAt("key", time.Time{}, time.Now())
+	if ttl, _ := s.TTL("key"); ttl != 0 {
+		t.Errorf("expected no expiration, got %v", ttl)
+	}
+	s.ExpireAt("key", time.Now().Add(50*time.Millisecond), time.Now())
+	if v, _ := s.Get("key"); v != "val" {
+		t.Errorf("expected value to be kept, got %q", v)
+	}
+	time.Sleep(100 * time.Millisecond)
+	if _, found := s.TTL("key"); found {
+		t.Error("key should have expired")
+	}
+	if s.ExpireAt("key", time.Time{}, time.Now()) {
+		t.Error("an expired key cannot be persisted")
+	}
+
+	// An expiration in the past stores an item that is already expired.
+	s.SetExpiresAt("past", "val", time.Now().Add(-time.Second))
+	if _, found := s.Get("past"); found {
+		t.Error("item with a past expiration should not be found")
+	}
+}
+
 func TestStore_Delete(t *testing.T) {
 	s := New()
 	s.Set("key", "val", 0)
@@ -54,3 +98,73 @@ func TestStore_Delete(t *testing.T) {
 		t.Fatal("key should have been deleted")
 	}
 }
+
+func TestStore_Replace(t *testing.T) {
+	s := New()
+	if s.Replace("key", "val", time.Now()) {
+		t.Fatal("expected Replace of a missing key to fail")
+	}
+	if _, found := s.Get("key"); found {
+		t.Fatal("Replace must not create keys")
+	}
+
+	s.Set("key", "v1", 100*time.Millisecond)
+	if !s.Replace("key", "v2", time.Now()) {
+		t.Fatal("expected Replace of an existing key to succeed")
+	}
+	if got, _ := s.Get("key"); got != "v2" {
+		t.Errorf("expected v2, got %s", got)
+	}
+
+	// The original expiration still applies.
+	time.Sleep(200 * time.Millisecond)
+	if _, found := s.Get("key"); found {
+		t.Fatal("key should have expired")
+	}
+}
+
+func TestStore_GetStale(t *testing.T) {
+	s := New()
+	expiresAt := time.Unix(0, time.Now().Add(-time.Second).UnixNano())
+	s.SetExpiresAt("old", "v", expiresAt)
+	s.Set("forever", "w", 0)
+
+	if _, found := s.Get("old"); found {
+		t.Fatal("Get should hide the expired key")
+	}
+	if v, at, found := s.GetStale("old"); !found || v != "v" || !at.Equal(expiresAt) {
+		t.Errorf("expected the expired value and its expiry, got %q, %v, %v", v, at, found)
+	}
+	if v, at, found := s.GetStale("forever"); !found || v != "w" || !at.IsZero() {
+		t.Errorf("expected a value without expiry, got %q, %v, %v", v, at, found)
+	}
+	if _, _, found := s.GetStale("missing"); found {
+		t.Error("expected a missing key not to be found")
+	}
+}
+
+func TestStore_DeleteExpired(t *testing.T) {
+	s := New()
+	now := time.Now()
+	s.SetExpiresAt("old", "v", now.Add(-time.Second))
+	s.SetExpiresAt("later", "v", now.Add(time.Second))
+	s.Set("forever", "v", 0)
+
+	if keys := s.ExpiredKeys(now, 10); len(keys) != 1 || keys[0] != "old" {
+		t.Errorf("expected [old], got %v", keys)
+	}
+	if keys := s.ExpiredKeys(now.Add(2*time.Second), 1); len(keys) != 1 {
+		t.Errorf("expected the limit to apply, got %v", keys)
+	}
+
+	// Whether a key is deleted depends on the given time, not the clock.
+	if s.DeleteExpired("later", now) || s.DeleteExpired("forever", now.Add(time.Hour)) || s.DeleteExpired("missing", now) {
+		t.Error("only expired keys should be deleted")
+	}
+	if !s.DeleteExpired("old", now) || !s.DeleteExpired("later", now.Add(2*time.Second)) {
+		t.Error("expired keys should be deleted")
+	}
+	if s.Len() != 1 {
+		t.Errorf("expected 1 key left, got %d", s.Len())
+	}
+}
@@ -0,0 +1,73 @@
+package store
+
+import "maps"
+
+// table holds the store's items.
+// Implementations are not safe for concurrent use; Store guards them with s.mu.
+type table interface {
+	get(key string) (*Item, bool)
+	has(key string) bool
+	set(key string, item *Item)
+	delete(key string) bool
+	len() int
+	// keys visits every key with its expiration without materializing values.
+	// fn may delete the visited key.
+	keys(fn func(key string, expiration int64))
+	// forEach visits every item, stopping at the first error fn returns.
+	forEach(fn func(key string, item *Item) error) error
+	// clone returns an independent copy for point-in-time views.
+	clone() table
+}
+
+// mapTable is the default table: a plain map of immutable items.
+// Cloning is a shallow map copy because items are never modified in place.
+type mapTable map[string]*Item
+
+func newMapTable() mapTable {
+	return make(mapTable)
+}
+
+func (t mapTable) get(key string) (*Item, bool) {
+	item, ok := t[key]
+	return item, ok
+}
+
+func (t mapTable) has(key string) bool {
+	_, ok := t[key]
+	return ok
+}
+
+func (t mapTable) set(key string, item *Item) {
+	t[key] = item
+}
+
+func (t mapTable) delete(key string) bool {
+	if _, ok := t[key]; !ok {
+		return false
+	}
+	delete(t, key)
+	return true
+}
+
+func (t mapTable) len() int {
+	return len(t)
+}
+
+func (t mapTable) keys(fn func(key string, expiration int64)) {
+	for k, v := range t {
+		fn(k, v.Expiration)
+	}
+}
+
+func (t mapTable) forEach(fn func(key string, item *Item) error) error {
+	for k, v := range t {
+		if err := fn(k, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t mapTable) clone() table {
+	return maps.Clone(t)
+}
@@ -0,0 +1,106 @@
+package store
+
+import (
+	"sort"
+	"strings"
+
+	"distributed-cache-service/internal/core/ports"
+)
+
+// ensure implementation
+var _ ports.UsageStorage = (*Store)(nil)
+
+// WithUsagePrefixes counts the keys and bytes stored under each of prefixes,
+// for Usage. A key counts under the longest prefix it starts with, and "" takes
+// every key matching no other. Only string values are counted; sorted sets are
+// not. Usage includes keys that have expired but not yet been purged, since
+// they still take up memory.
+func WithUsagePrefixes(prefixes ...string) Option {
+	return func(s *Store) {
+		s.usagePrefixes = append([]string(nil), prefixes...)
+		sort.SliceStable(s.usagePrefixes, func(i, j int) bool {
+			return len(s.usagePrefixes[i]) > len(s.usagePrefixes[j])
+		})
+		s.usage = make(map[string]*ports.Usage, len(prefixes))
+		for _, prefix := range prefixes {
+			s.usage[prefix] = &ports.Usage{}
+		}
+	}
+}
+
+// Usage returns the keys and bytes stored under prefix, which must be one of
+// those given to WithUsagePrefixes.
+func (s *Store) Usage(prefix string) (ports.Usage, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	u, ok := s.usage[prefix]
+	if !ok {
+		return ports.Usage{}, false
+	}
+	return *u, true
+}
+
+// Size returns the bytes key takes up in Usage, expired or not, without
+// counting as an access.
+func (s *Store) Size(key string) (int64, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	item, ok := s.items.get(key)
+	if !ok {
+		return 0, false
+	}
+	return itemSize(key, item), true
+}
+
+func itemSize(key string, item *Item) int64 {
+	return int64(len(key) + len(item.Value))
+}
+
+// usageOf returns the usage key counts under, or nil if it is not counted.
+func (s *Store) usageOf(key string) *ports.Usage {
+	for _, prefix := range s.usagePrefixes {
+		if strings.HasPrefix(key, prefix) {
+			return s.usage[prefix]
+		}
+	}
+	return nil
+}
+
+// account updates usage for key going from its current item to item, or to
+// nothing if item is nil. Caller must hold s.mu.
+func (s *Store) account(key string, item *Item) {
+	u := s.usageOf(key)
+	if u == nil {
+		return
+	}
+	if old, ok := s.items.get(key); ok {
+		u.Keys--
+		u.Bytes -= itemSize(key, old)
+	}
+	if item != nil {
+		u.Keys++
+		u.Bytes += itemSize(key, item)
+	}
+}
+
+// newUsage returns the usage of the keys in items, by prefix.
+func (s *Store) newUsage(items table) map[string]*ports.Usage {
+	if s.usage == nil {
+		return nil
+	}
+	usage := make(map[string]*ports.Usage, len(s.usagePrefixes))
+	for _, prefix := range s.usagePrefixes {
+		usage[prefix] = &ports.Usage{}
+	}
+	items.forEach(func(key string, item *Item) error {
+		for _, prefix := range s.usagePrefixes {
+			if strings.HasPrefix(key, prefix) {
+				usage[prefix].Keys++
+				usage[prefix].Bytes += itemSize(key, item)
+				break
+			}
+		}
+		return nil
+	})
+	return usage
+}
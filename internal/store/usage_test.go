@@ -0,0 +1,91 @@
+package store
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"distributed-cache-service/internal/core/ports"
+)
+
+func TestStore_Usage(t *testing.T) {
+	for _, offHeap := range []bool{false, true} {
+		opts := []Option{WithUsagePrefixes("", "user:", "user:admin:")}
+		if offHeap {
+			opts = append(opts, WithOffHeap())
+		}
+		s := New(opts...)
+		usage := func(prefix string) ports.Usage {
+			t.Helper()
+			u, ok := s.Usage(prefix)
+			if !ok {
+				t.Fatalf("prefix %q is not counted", prefix)
+			}
+			return u
+		}
+
+		s.Set("user:1", "abc", 0)
+		s.Set("user:admin:1", "x", 0)
+		s.Set("other", "vvvv", 0)
+		if got, want := usage("user:"), (ports.Usage{Keys: 1, Bytes: 9}); got != want {
+			t.Errorf("user: got %+v, want %+v", got, want)
+		}
+		if got, want := usage("user:admin:"), (ports.Usage{Keys: 1, Bytes: 13}); got != want {
+			t.Errorf("user:admin: got %+v, want %+v", got, want)
+		}
+		if got, want := usage(""), (ports.Usage{Keys: 1, Bytes: 9}); got != want {
+			t.Errorf("\"\" got %+v, want %+v", got, want)
+		}
+		if _, ok := s.Usage("missing:"); ok {
+			t.Error("expected an untracked prefix to report false")
+		}
+
+		// Overwrites count the difference; expirations do not change usage
+		// until the key is removed.
+		s.Set("user:1", "abcdef", 0)
+		s.ExpireAt("user:1", time.Now().Add(-time.Second), time.Now())
+		if got, want := usage("user:"), (ports.Usage{Keys: 1, Bytes: 12}); got != want {
+			t.Errorf("after overwrite got %+v, want %+v", got, want)
+		}
+		if size, ok := s.Size("user:1"); !ok || size != 12 {
+			t.Errorf("expected size 12, got %d (%v)", size, ok)
+		}
+		s.DeleteExpired("user:1", time.Now())
+		s.Delete("other")
+		s.Delete("other")
+		if got := usage("user:"); got != (ports.Usage{}) {
+			t.Errorf("after delete got %+v", got)
+		}
+		if got := usage(""); got != (ports.Usage{}) {
+			t.Errorf("after delete got %+v", got)
+		}
+
+		// A sorted set replacing an expired string value is not counted.
+		s.SetExpiresAt("user:2", "v", time.Now().Add(-time.Second))
+		if _, err := s.ZAdd("user:2", time.Now(), ports.ScoredMember{Member: "m", Score: 1}); err != nil {
+			t.Fatal(err)
+		}
+		if got := usage("user:"); got != (ports.Usage{}) {
+			t.Errorf("after zadd got %+v", got)
+		}
+
+		// Usage is recomputed from restored snapshots.
+		var buf bytes.Buffer
+		s.Set("user:3", "v", 0)
+		if err := s.Snapshot(&buf); err != nil {
+			t.Fatal(err)
+		}
+		restored := New(opts...)
+		restored.Set("user:4", "stale", 0)
+		if err := restored.Restore(&buf); err != nil {
+			t.Fatal(err)
+		}
+		s = restored
+		if got, want := usage("user:"), (ports.Usage{Keys: 1, Bytes: 7}); got != want {
+			t.Errorf("after restore got %+v, want %+v", got, want)
+		}
+		if got, want := usage("user:admin:"), (ports.Usage{Keys: 1, Bytes: 13}); got != want {
+			t.Errorf("after restore got %+v, want %+v", got, want)
+		}
+	}
+}
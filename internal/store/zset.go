@@ -0,0 +1,436 @@
+package store
+
+import (
+	"math/rand/v2"
+	"time"
+
+	coreerrors "distributed-cache-service/internal/core/errors"
+	"distributed-cache-service/internal/core/ports"
+)
+
+// Sorted sets
+//
+// A sorted set maps members to scores and keeps them ordered by (score, member).
+// Like Redis, it pairs a hash map (member lookups) with a skip list whose
+// forward links record how many nodes they span, so both score ranges and rank
+// ranges are found in O(log n).
+
+const (
+	skiplistMaxLevel = 32
+	skiplistP        = 0.25
+)
+
+type skipNode struct {
+	member   string
+	score    float64
+	backward *skipNode
+	level    []skipLevel
+}
+
+type skipLevel struct {
+	forward *skipNode
+	span    int // number of level-0 nodes this link skips over
+}
+
+// before reports whether n sorts before (score, member).
+func (n *skipNode) before(score float64, member string) bool {
+	return n.score < score || (n.score == score && n.member < member)
+}
+
+type skiplist struct {
+	head   *skipNode
+	tail   *skipNode
+	length int
+	level  int
+}
+
+func newSkiplist() *skiplist {
+	return &skiplist{head: &skipNode{level: make([]skipLevel, skiplistMaxLevel)}, level: 1}
+}
+
+// randomLevel picks a node height. The shape of the list does not affect
+// results, so nodes replaying the same commands may build different lists.
+func randomLevel() int {
+	level := 1
+	for level < skiplistMaxLevel && rand.Float64() < skiplistP {
+		level++
+	}
+	return level
+}
+
+// insert adds a node; the caller guarantees (score, member) is not present.
+func (sl *skiplist) insert(score float64, member string) {
+	var update [skiplistMaxLevel]*skipNode
+	var rank [skiplistMaxLevel]int
+	x := sl.head
+	for i := sl.level - 1; i >= 0; i-- {
+		if i < sl.level-1 {
+			rank[i] = rank[i+1]
+		}
+		for x.level[i].forward != nil && x.level[i].forward.before(score, member) {
+			rank[i] += x.level[i].span
+			x = x.level[i].forward
+		}
+		update[i] = x
+	}
+
+	level := randomLevel()
+	if level > sl.level {
+		for i := sl.level; i < level; i++ {
+			update[i] = sl.head
+			update[i].level[i].span = sl.length
+		}
+		sl.level = level
+	}
+
+	x = &skipNode{member: member, score: score, level: make([]skipLevel, level)}
+	for i := 0; i < level; i++ {
+		x.level[i].forward = update[i].level[i].forward
+		update[i].level[i].forward = x
+		x.level[i].span = update[i].level[i].span - (rank[0] - rank[i])
+		update[i].level[i].span = rank[0] - rank[i] + 1
+	}
+	for i := level; i < sl.level; i++ {
+		update[i].level[i].span++
+	}
+
+	if update[0] != sl.head {
+		x.backward = update[0]
+	}
+	if x.level[0].forward != nil {
+		x.level[0].forward.backward = x
+	} else {
+		sl.tail = x
+	}
+	sl.length++
+}
+
+// delete removes (score, member), reporting whether it was present.
+func (sl *skiplist) delete(score float64, member string) bool {
+	var update [skiplistMaxLevel]*skipNode
+	x := sl.head
+	for i := sl.level - 1; i >= 0; i-- {
+		for x.level[i].forward != nil && x.level[i].forward.before(score, member) {
+			x = x.level[i].forward
+		}
+		update[i] = x
+	}
+	x = x.level[0].forward
+	if x == nil || x.score != score || x.member != member {
+		return false
+	}
+	sl.deleteNode(x, &update)
+	return true
+}
+
+// deleteNode unlinks x given its predecessor at every level.
+func (sl *skiplist) deleteNode(x *skipNode, update *[skiplistMaxLevel]*skipNode) {
+	for i := 0; i < sl.level; i++ {
+		if update[i].level[i].forward == x {
+			update[i].level[i].span += x.level[i].span - 1
+			update[i].level[i].forward = x.level[i].forward
+		} else {
+			update[i].level[i].span--
+		}
+	}
+	if x.level[0].forward != nil {
+		x.level[0].forward.backward = x.backward
+	} else {
+		sl.tail = x.backward
+	}
+	for sl.level > 1 && sl.head.level[sl.level-1].forward == nil {
+		sl.level--
+	}
+	sl.length--
+}
+
+// byRank returns the node at 1-based rank, or nil.
+func (sl *skiplist) byRank(rank int) *skipNode {
+	traversed := 0
+	x := sl.head
+	for i := sl.level - 1; i >= 0; i-- {
+		for x.level[i].forward != nil && traversed+x.level[i].span <= rank {
+			traversed += x.level[i].span
+			x = x.level[i].forward
+		}
+		if traversed == rank {
+			return x
+		}
+	}
+	return nil
+}
+
+// firstFrom returns the first node with a score >= min, or nil.
+func (sl *skiplist) firstFrom(min float64) *skipNode {
+	x := sl.head
+	for i := sl.level - 1; i >= 0; i-- {
+		for x.level[i].forward != nil && x.level[i].forward.score < min {
+			x = x.level[i].forward
+		}
+	}
+	return x.level[0].forward
+}
+
+// zset is a sorted set. It is not safe for concurrent use; Store guards it with s.mu.
+type zset struct {
+	dict map[string]float64
+	sl   *skiplist
+	// memberBytes is the length of all member names, for zsetMemory.
+	memberBytes int64
+}
+
+func newZSet() *zset {
+	return &zset{dict: make(map[string]float64), sl: newSkiplist()}
+}
+
+func (z *zset) len() int {
+	return z.sl.length
+}
+
+// add sets member's score, reporting whether member is new.
+func (z *zset) add(member string, score float64) bool {
+	if old, ok := z.dict[member]; ok {
+		if old != score {
+			z.sl.delete(old, member)
+			z.sl.insert(score, member)
+			z.dict[member] = score
+		}
+		return false
+	}
+	z.sl.insert(score, member)
+	z.dict[member] = score
+	z.memberBytes += int64(len(member))
+	return true
+}
+
+func (z *zset) score(member string) (float64, bool) {
+	s, ok := z.dict[member]
+	return s, ok
+}
+
+// rangeByRank returns members from rank start to stop inclusive, 0-based.
+// Negative ranks count from the end, so (0, -1) is the whole set.
+func (z *zset) rangeByRank(start, stop int) []ports.ScoredMember {
+	n := z.sl.length
+	if start < 0 {
+		start += n
+	}
+	if stop < 0 {
+		stop += n
+	}
+	start = max(start, 0)
+	stop = min(stop, n-1)
+	if start > stop {
+		return nil
+	}
+	out := make([]ports.ScoredMember, 0, stop-start+1)
+	for x := z.sl.byRank(start + 1); x != nil && len(out) <= stop-start; x = x.level[0].forward {
+		out = append(out, ports.ScoredMember{Member: x.member, Score: x.score})
+	}
+	return out
+}
+
+// rangeByScore returns members with min <= score <= max in order, at most
+// limit of them if limit > 0.
+func (z *zset) rangeByScore(min, max float64, limit int) []ports.ScoredMember {
+	var out []ports.ScoredMember
+	for x := z.sl.firstFrom(min); x != nil && x.score <= max; x = x.level[0].forward {
+		if limit > 0 && len(out) == limit {
+			break
+		}
+		out = append(out, ports.ScoredMember{Member: x.member, Score: x.score})
+	}
+	return out
+}
+
+// removeRangeByScore deletes members with min <= score <= max and returns how many.
+func (z *zset) removeRangeByScore(min, max float64) int {
+	var update [skiplistMaxLevel]*skipNode
+	x := z.sl.head
+	for i := z.sl.level - 1; i >= 0; i-- {
+		for x.level[i].forward != nil && x.level[i].forward.score < min {
+			x = x.level[i].forward
+		}
+		update[i] = x
+	}
+	removed := 0
+	for x = x.level[0].forward; x != nil && x.score <= max; {
+		next := x.level[0].forward
+		z.sl.deleteNode(x, &update)
+		delete(z.dict, x.member)
+		z.memberBytes -= int64(len(x.member))
+		removed++
+		x = next
+	}
+	return removed
+}
+
+// forEach visits members in order, stopping at the first error fn returns.
+func (z *zset) forEach(fn func(member string, score float64) error) error {
+	for x := z.sl.head.level[0].forward; x != nil; x = x.level[0].forward {
+		if err := fn(x.member, x.score); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (z *zset) clone() *zset {
+	c := newZSet()
+	for x := z.sl.head.level[0].forward; x != nil; x = x.level[0].forward {
+		c.add(x.member, x.score)
+	}
+	return c
+}
+
+// Store methods for sorted sets. A key holds either a string item or a sorted
+// set; sorted set operations on a string key fail with ErrWrongType, while a
+// string write silently replaces a sorted set. Sorted sets never expire and
+// are not subject to capacity eviction.
+
+// ZAdd adds members to the sorted set at key, creating it if needed, and
+// updates the scores of members already present. It returns the number of
+// members that were new. A string item at key counts as expired, and is
+// replaced, if it had expired by now.
+func (s *Store) ZAdd(key string, now time.Time, members ...ports.ScoredMember) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.checkZSetKey(key, now); err != nil {
+		return 0, err
+	}
+	added := 0
+	for _, m := range members {
+		if s.zadd(key, m) {
+			added++
+		}
+		if s.aof != nil {
+			s.aof.appendZAdd(key, m)
+		}
+	}
+	return added, nil
+}
+
+// ZRemRangeByScore removes members with min <= score <= max from the sorted
+// set at key and returns how many were removed. An emptied set is deleted.
+// Like ZAdd it judges whether a string item at key has expired at now.
+func (s *Store) ZRemRangeByScore(key string, min, max float64, now time.Time) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err := s.checkZSetKey(key, now); err != nil {
+		return 0, err
+	}
+	if _, ok := s.zsets[key]; !ok {
+		return 0, nil
+	}
+	removed := s.zremRangeByScore(key, min, max)
+	if removed > 0 && s.aof != nil {
+		s.aof.appendZRemRange(key, min, max)
+	}
+	return removed, nil
+}
+
+// ZScore returns the score of member in the sorted set at key.
+func (s *Store) ZScore(key, member string) (float64, bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if err := s.checkZSetKey(key, time.Now()); err != nil {
+		return 0, false, err
+	}
+	z, ok := s.zsets[key]
+	if !ok {
+		return 0, false, nil
+	}
+	score, ok := z.score(member)
+	return score, ok, nil
+}
+
+// ZRange returns the members ranked start to stop inclusive, lowest score
+// first. Ranks are 0-based and negative ranks count from the end.
+func (s *Store) ZRange(key string, start, stop int) ([]ports.ScoredMember, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if err := s.checkZSetKey(key, time.Now()); err != nil {
+		return nil, err
+	}
+	z, ok := s.zsets[key]
+	if !ok {
+		return nil, nil
+	}
+	return z.rangeByRank(start, stop), nil
+}
+
+// ZRangeByScore returns the members with min <= score <= max, lowest score
+// first, at most limit of them if limit > 0.
+func (s *Store) ZRangeByScore(key string, min, max float64, limit int) ([]ports.ScoredMember, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if err := s.checkZSetKey(key, time.Now()); err != nil {
+		return nil, err
+	}
+	z, ok := s.zsets[key]
+	if !ok {
+		return nil, nil
+	}
+	return z.rangeByScore(min, max, limit), nil
+}
+
+// checkZSetKey fails if key holds a string item live at now. Caller must hold
+// s.mu.
+func (s *Store) checkZSetKey(key string, now time.Time) error {
+	item, found := s.items.get(key)
+	if found && (item.Expiration == 0 || now.UnixNano() <= item.Expiration) {
+		return coreerrors.ErrWrongType
+	}
+	return nil
+}
+
+// zadd adds a member without logging, replacing any (expired) string item at
+// key. Caller must hold s.mu.
+func (s *Store) zadd(key string, m ports.ScoredMember) bool {
+	z, ok := s.zsets[key]
+	if !ok {
+		s.evicted(key, nil, EvictReplaced)
+		s.account(key, nil)
+		s.memory.Add(-s.keyMemory(key))
+		if s.items.delete(key) && s.policy != nil {
+			s.policy.OnRemove(key)
+		}
+		z = newZSet()
+		s.zsets[key] = z
+		s.memory.Add(zsetMemory(key, z))
+	}
+	if !z.add(m.Member, m.Score) {
+		return false
+	}
+	s.memory.Add(memberOverhead + int64(len(m.Member)))
+	return true
+}
+
+// zremRangeByScore removes members by score without logging. Caller must hold s.mu.
+func (s *Store) zremRangeByScore(key string, min, max float64) int {
+	z, ok := s.zsets[key]
+	if !ok {
+		return 0
+	}
+	before := zsetMemory(key, z)
+	removed := z.removeRangeByScore(min, max)
+	if z.len() == 0 {
+		delete(s.zsets, key)
+		s.memory.Add(-before)
+	} else {
+		s.memory.Add(zsetMemory(key, z) - before)
+	}
+	return removed
+}
+
+// cloneZSets deep-copies every sorted set. Caller must hold s.mu.
+func (s *Store) cloneZSets() map[string]*zset {
+	if len(s.zsets) == 0 {
+		return nil
+	}
+	out := make(map[string]*zset, len(s.zsets))
+	for k, z := range s.zsets {
+		out[k] = z.clone()
+	}
+	return out
+}
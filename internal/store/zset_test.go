@@ -0,0 +1,189 @@
+package store
+
+import (
+	"bytes"
+	"cmp"
+	"fmt"
+	"math/rand/v2"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	coreerrors "distributed-cache-service/internal/core/errors"
+	"distributed-cache-service/internal/core/ports"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func sortedMembers(ref map[string]float64) []ports.ScoredMember {
+	out := make([]ports.ScoredMember, 0, len(ref))
+	for m, s := range ref {
+		out = append(out, ports.ScoredMember{Member: m, Score: s})
+	}
+	slices.SortFunc(out, func(a, b ports.ScoredMember) int {
+		if c := cmp.Compare(a.Score, b.Score); c != 0 {
+			return c
+		}
+		return cmp.Compare(a.Member, b.Member)
+	})
+	return out
+}
+
+func TestZSet_MatchesReference(t *testing.T) {
+	rng := rand.New(rand.NewPCG(1, 2))
+	z := newZSet()
+	ref := make(map[string]float64)
+
+	for i := 0; i < 2000; i++ {
+		member := fmt.Sprintf("m%d", rng.IntN(300))
+		score := float64(rng.IntN(50))
+		if rng.IntN(10) == 0 {
+			lo := float64(rng.IntN(50))
+			hi := lo + float64(rng.IntN(5))
+			want := 0
+			for m, s := range ref {
+				if s >= lo && s <= hi {
+					delete(ref, m)
+					want++
+				}
+			}
+			require.Equal(t, want, z.removeRangeByScore(lo, hi))
+			continue
+		}
+		_, existed := ref[member]
+		ref[member] = score
+		require.Equal(t, !existed, z.add(member, score))
+	}
+
+	want := sortedMembers(ref)
+	require.Equal(t, len(want), z.len())
+	if len(want) == 0 {
+		assert.Empty(t, z.rangeByRank(0, -1))
+	} else {
+		assert.Equal(t, want, z.rangeByRank(0, -1))
+	}
+	for rank := range want {
+		got := z.rangeByRank(rank, rank)
+		require.Len(t, got, 1)
+		assert.Equal(t, want[rank], got[0], "rank %d", rank)
+	}
+
+	var inRange []ports.ScoredMember
+	for _, m := range want {
+		if m.Score >= 10 && m.Score <= 20 {
+			inRange = append(inRange, m)
+		}
+	}
+	assert.Equal(t, inRange, z.rangeByScore(10, 20, 0))
+	if len(inRange) > 3 {
+		assert.Equal(t, inRange[:3], z.rangeByScore(10, 20, 3))
+	}
+}
+
+func TestZSet_RangeByRankBounds(t *testing.T) {
+	z := newZSet()
+	for i, m := range []string{"a", "b", "c", "d"} {
+		z.add(m, float64(i))
+	}
+
+	members := func(ms []ports.ScoredMember) []string {
+		var out []string
+		for _, m := range ms {
+			out = append(out, m.Member)
+		}
+		return out
+	}
+	assert.Equal(t, []string{"b", "c"}, members(z.rangeByRank(1, 2)))
+	assert.Equal(t, []string{"c", "d"}, members(z.rangeByRank(-2, -1)))
+	assert.Equal(t, []string{"a", "b", "c", "d"}, members(z.rangeByRank(-100, 100)))
+	assert.Empty(t, z.rangeByRank(3, 1))
+	assert.Empty(t, z.rangeByRank(10, 20))
+}
+
+func TestStore_ZSetOperations(t *testing.T) {
+	s := New()
+	added, err := s.ZAdd("board", time.Now(), ports.ScoredMember{Member: "alice", Score: 3}, ports.ScoredMember{Member: "bob", Score: 1})
+	require.NoError(t, err)
+	assert.Equal(t, 2, added)
+
+	added, err = s.ZAdd("board", time.Now(), ports.ScoredMember{Member: "alice", Score: 0.5})
+	require.NoError(t, err)
+	assert.Equal(t, 0, added, "rescoring is not an addition")
+
+	score, found, err := s.ZScore("board", "alice")
+	require.NoError(t, err)
+	assert.True(t, found)
+	assert.Equal(t, 0.5, score)
+
+	got, err := s.ZRange("board", 0, -1)
+	require.NoError(t, err)
+	assert.Equal(t, []ports.ScoredMember{{Member: "alice", Score: 0.5}, {Member: "bob", Score: 1}}, got)
+
+	removed, err := s.ZRemRangeByScore("board", 0, 10, time.Now())
+	require.NoError(t, err)
+	assert.Equal(t, 2, removed)
+	assert.Equal(t, 0, s.Len(), "an emptied set is deleted")
+}
+
+func TestStore_ZSetWrongType(t *testing.T) {
+	s := New()
+	s.Set("str", "value", 0)
+
+	_, err := s.ZAdd("str", time.Now(), ports.ScoredMember{Member: "m", Score: 1})
+	assert.ErrorIs(t, err, coreerrors.ErrWrongType)
+	_, err = s.ZRange("str", 0, -1)
+	assert.ErrorIs(t, err, coreerrors.ErrWrongType)
+
+	// A string write replaces a sorted set.
+	_, err = s.ZAdd("set", time.Now(), ports.ScoredMember{Member: "m", Score: 1})
+	require.NoError(t, err)
+	s.Set("set", "value", 0)
+	got, err := s.ZRange("set", 0, -1)
+	assert.ErrorIs(t, err, coreerrors.ErrWrongType)
+	assert.Empty(t, got)
+}
+
+func TestStore_ZSetSnapshotRestore(t *testing.T) {
+	src := New()
+	src.Set("str", "value", 0)
+	_, err := src.ZAdd("board", time.Now(), ports.ScoredMember{Member: "a", Score: 2}, ports.ScoredMember{Member: "b", Score: -1.5})
+	require.NoError(t, err)
+
+	var buf bytes.Buffer
+	require.NoError(t, src.Snapshot(&buf))
+
+	dst := New()
+	require.NoError(t, dst.Restore(bytes.NewReader(buf.Bytes())))
+	assert.Equal(t, 2, dst.Len())
+	got, err := dst.ZRange("board", 0, -1)
+	require.NoError(t, err)
+	assert.Equal(t, []ports.ScoredMember{{Member: "b", Score: -1.5}, {Member: "a", Score: 2}}, got)
+
+	// Backends without sorted set support refuse the snapshot rather than drop data.
+	err = ReadSnapshot(bytes.NewReader(buf.Bytes()), func(string, *Item) {})
+	assert.ErrorIs(t, err, coreerrors.ErrUnsupported)
+}
+
+func TestStore_ZSetAOFRecovery(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "cache.aof")
+
+	s := New()
+	require.NoError(t, s.OpenAOF(path, FsyncAlways))
+	_, err := s.ZAdd("board", time.Now(), ports.ScoredMember{Member: "a", Score: 1}, ports.ScoredMember{Member: "b", Score: 2}, ports.ScoredMember{Member: "c", Score: 3})
+	require.NoError(t, err)
+	_, err = s.ZRemRangeByScore("board", 2, 2, time.Now())
+	require.NoError(t, err)
+	require.NoError(t, s.RewriteAOF())
+	_, err = s.ZAdd("board", time.Now(), ports.ScoredMember{Member: "d", Score: 4})
+	require.NoError(t, err)
+	require.NoError(t, s.CloseAOF())
+
+	recovered := New()
+	require.NoError(t, recovered.OpenAOF(path, FsyncNo))
+	defer recovered.CloseAOF()
+	got, err := recovered.ZRange("board", 0, -1)
+	require.NoError(t, err)
+	assert.Equal(t, []ports.ScoredMember{{Member: "a", Score: 1}, {Member: "c", Score: 3}, {Member: "d", Score: 4}}, got)
+}
@@ -0,0 +1,435 @@
+// Package testcluster runs a cluster of cache nodes in one process for
+// integration tests, and injects failures into it: nodes can be killed and
+// restarted with their Raft state intact, and the network between them can
+// be partitioned. Nodes talk over Raft's in-memory transport, which fails
+// RPCs to peers it is disconnected from as a dropped connection would.
+package testcluster
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"distributed-cache-service/internal/consensus"
+	coreerrors "distributed-cache-service/internal/core/errors"
+	"distributed-cache-service/internal/core/service"
+	"distributed-cache-service/internal/store"
+
+	"github.com/hashicorp/go-hclog"
+	"github.com/hashicorp/raft"
+)
+
+// Node is one member of a Cluster. Its Raft log and snapshots survive
+// restarts; its state machine is rebuilt from them.
+type Node struct {
+	ID   string
+	Addr raft.ServerAddress
+	// Witness is set on nodes that vote but hold no data; see WithWitnesses.
+	Witness bool
+
+	raft    *consensus.RaftNode
+	service *service.ServiceImpl
+	trans   *raft.InmemTransport
+	logs    *raft.InmemStore
+	snaps   *raft.InmemSnapshotStore
+	// applyDelay holds the time each write takes to apply; see SlowApplies.
+	applyDelay atomic.Int64
+}
+
+// Cluster is a set of nodes in one process.
+type Cluster struct {
+	t         testing.TB
+	tuning    consensus.RaftConfig
+	witnesses int
+
+	mu    sync.Mutex
+	nodes []*Node
+	// cut holds the pairs of nodes the network is partitioned between.
+	cut map[[2]raft.ServerAddress]bool
+}
+
+// Option configures a Cluster.
+type Option func(*Cluster)
+
+// WithRaftConfig replaces the default Raft settings, which use short
+// timeouts so that elections take tens of milliseconds, and snapshot often
+// so that lagging nodes are caught up by InstallSnapshot as well as by log.
+func WithRaftConfig(cfg consensus.RaftConfig) Option {
+	return func(c *Cluster) {
+		c.tuning = cfg
+	}
+}
+
+// WithWitnesses makes the last n nodes witnesses, which vote in elections
+// but hold no data and serve no requests.
+func WithWitnesses(n int) Option {
+	return func(c *Cluster) {
+		c.witnesses = n
+	}
+}
+
+// WithReaper has the leader reap members it loses contact with for after,
+// or, if confirm is set, list them for Reap.
+func WithReaper(after time.Duration, confirm bool) Option {
+	return func(c *Cluster) {
+		c.tuning.ReapAfter = after
+		c.tuning.ReapConfirm = confirm
+	}
+}
+
+// WithReapMinVoters sets the fewest voters the reaper leaves.
+func WithReapMinVoters(n int) Option {
+	return func(c *Cluster) {
+		c.tuning.ReapMinVoters = n
+	}
+}
+
+// New starts a cluster of n nodes, bootstrapped with all of them as voters,
+// and waits for it to elect a leader. The cluster is shut down when the test
+// ends.
+func New(t testing.TB, n int, opts ...Option) *Cluster {
+	t.Helper()
+	c := &Cluster{
+		t: t,
+		tuning: consensus.RaftConfig{
+			HeartbeatTimeout:  50 * time.Millisecond,
+			ElectionTimeout:   50 * time.Millisecond,
+			SnapshotThreshold: 64,
+			SnapshotInterval:  100 * time.Millisecond,
+			TrailingLogs:      16,
+			SkipVerify:        true,
+		},
+		cut: make(map[[2]raft.ServerAddress]bool),
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+	if c.tuning.Logger == nil {
+		c.tuning.Logger = hclog.NewNullLogger()
+	}
+	t.Cleanup(c.Shutdown)
+
+	var servers []raft.Server
+	for i := 0; i < n; i++ {
+		node := &Node{
+			ID:    fmt.Sprintf("node%d", i+1),
+			Addr:  raft.ServerAddress(fmt.Sprintf("node%d:11000", i+1)),
+			logs:  raft.NewInmemStore(),
+			snaps: raft.NewInmemSnapshotStore(),
+		}
+		node.Witness = i >= n-c.witnesses
+		c.nodes = append(c.nodes, node)
+		servers = append(servers, raft.Server{ID: raft.ServerID(node.ID), Address: node.Addr})
+	}
+	for _, node := range c.nodes {
+		if err := c.start(node); err != nil {
+			t.Fatalf("start %s: %v", node.ID, err)
+		}
+	}
+	if err := c.nodes[0].raft.Bootstrap(raft.Configuration{Servers: servers}); err != nil {
+		t.Fatalf("bootstrap: %v", err)
+	}
+	c.Leader()
+	return c
+}
+
+// start runs Raft on node's stores behind a fresh transport, connected to
+// every running node the network is not partitioned from. c.mu must be
+// held or the cluster not yet shared.
+func (c *Cluster) start(node *Node) error {
+	kv := &laggingStore{Store: store.New(), delay: &node.applyDelay}
+	var fsmOpts []consensus.FSMOption
+	var svcOpts []service.Option
+	if node.Witness {
+		fsmOpts = append(fsmOpts, consensus.WithWitness())
+		svcOpts = append(svcOpts, service.WithWitness())
+	}
+	_, node.trans = raft.NewInmemTransport(node.Addr)
+	rn, err := consensus.NewRaftNode(node.ID, consensus.NewFSM(kv, fsmOpts...), node.logs, node.logs, node.snaps, node.trans, c.tuning)
+	if err != nil {
+		return err
+	}
+	node.raft = rn
+	node.service = service.New(kv, rn, service.ConsistencyStrong, svcOpts...)
+	for _, peer := range c.nodes {
+		if peer != node && peer.raft != nil && !c.cut[pair(node, peer)] {
+			node.trans.Connect(peer.Addr, peer.trans)
+			peer.trans.Connect(node.Addr, node.trans)
+		}
+	}
+	return nil
+}
+
+func pair(a, b *Node) [2]raft.ServerAddress {
+	if a.Addr > b.Addr {
+		a, b = b, a
+	}
+	return [2]raft.ServerAddress{a.Addr, b.Addr}
+}
+
+// Nodes returns every node, running or not.
+func (c *Cluster) Nodes() []*Node {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]*Node(nil), c.nodes...)
+}
+
+// Service returns the node's cache service, or nil if the node is down.
+func (c *Cluster) Service(node *Node) *service.ServiceImpl {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return node.service
+}
+
+// Raft returns the node's Raft node, or nil if the node is down.
+func (c *Cluster) Raft(node *Node) *consensus.RaftNode {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return node.raft
+}
+
+// Leader waits for one of nodes, or of all nodes if none are given, to lead
+// and returns it. It fails the test if none does within ten seconds.
+func (c *Cluster) Leader(nodes ...*Node) *Node {
+	c.t.Helper()
+	deadline := time.Now().Add(10 * time.Second)
+	for time.Now().Before(deadline) {
+		if leader := c.leaderNow(nodes...); leader != nil {
+			return leader
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	c.t.Fatalf("no leader elected within 10s")
+	return nil
+}
+
+// leaderNow returns a running node among nodes that believes it leads, or
+// nil. A leader cut off from the majority believes so until its lease
+// expires, so there may be several; one is picked at random.
+func (c *Cluster) leaderNow(nodes ...*Node) *Node {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(nodes) == 0 {
+		nodes = c.nodes
+	}
+	var leaders []*Node
+	for _, node := range nodes {
+		if node.raft != nil && node.raft.IsLeader() {
+			leaders = append(leaders, node)
+		}
+	}
+	if len(leaders) == 0 {
+		return nil
+	}
+	return leaders[rand.IntN(len(leaders))]
+}
+
+// Kill stops node abruptly, as a crash would. Its Raft state is kept.
+func (c *Cluster) Kill(node *Node) {
+	c.t.Helper()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if node.raft == nil {
+		return
+	}
+	for _, peer := range c.nodes {
+		if peer.raft != nil && peer != node {
+			peer.trans.Disconnect(node.Addr)
+		}
+	}
+	node.trans.DisconnectAll()
+	if err := node.raft.Shutdown(); err != nil {
+		c.t.Errorf("shut down %s: %v", node.ID, err)
+	}
+	node.raft, node.service = nil, nil
+}
+
+// Restart starts a killed node again from its Raft state.
+func (c *Cluster) Restart(node *Node) {
+	c.t.Helper()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if node.raft != nil {
+		return
+	}
+	if err := c.start(node); err != nil {
+		c.t.Fatalf("restart %s: %v", node.ID, err)
+	}
+}
+
+// SlowApplies makes each write take d to apply to node's state machine, as
+// on an overloaded node, which falls behind its log while still accepting
+// entries. 0 restores full speed.
+func (c *Cluster) SlowApplies(node *Node, d time.Duration) {
+	node.applyDelay.Store(int64(d))
+}
+
+// laggingStore delays the writes the FSM applies; see SlowApplies.
+type laggingStore struct {
+	*store.Store
+	delay *atomic.Int64
+}
+
+func (s *laggingStore) SetExpiresAt(key, value string, expiresAt time.Time) {
+	time.Sleep(time.Duration(s.delay.Load()))
+	s.Store.SetExpiresAt(key, value, expiresAt)
+}
+
+// Partition cuts the network between the given groups of nodes. Nodes in
+// no group form one more group. Partitions add up until Heal.
+func (c *Cluster) Partition(groups ...[]*Node) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	group := make(map[*Node]int)
+	for i, g := range groups {
+		for _, node := range g {
+			group[node] = i + 1
+		}
+	}
+	for i, a := range c.nodes {
+		for _, b := range c.nodes[i+1:] {
+			if group[a] == group[b] {
+				continue
+			}
+			c.cut[pair(a, b)] = true
+			if a.raft != nil && b.raft != nil {
+				a.trans.Disconnect(b.Addr)
+				b.trans.Disconnect(a.Addr)
+			}
+		}
+	}
+}
+
+// Isolate cuts node off from every other node.
+func (c *Cluster) Isolate(node *Node) {
+	c.Partition([]*Node{node})
+}
+
+// Heal restores the network between all running nodes.
+func (c *Cluster) Heal() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.cut = make(map[[2]raft.ServerAddress]bool)
+	for i, a := range c.nodes {
+		for _, b := range c.nodes[i+1:] {
+			if a.raft != nil && b.raft != nil {
+				a.trans.Connect(b.Addr, b.trans)
+				b.trans.Connect(a.Addr, a.trans)
+			}
+		}
+	}
+}
+
+// Shutdown stops every node.
+func (c *Cluster) Shutdown() {
+	for _, node := range c.Nodes() {
+		c.Kill(node)
+	}
+}
+
+// Workload describes the clients Run starts. Each writes unique values to
+// and reads keys at random, sending every call to the node it last saw lead.
+type Workload struct {
+	Clients int
+	Keys    int
+	// Eventual sends reads to any running node under eventual consistency,
+	// which is not linearizable; it checks that stale reads are caught.
+	Eventual bool
+}
+
+// Run runs w until ctx is done and returns what its clients observed.
+func (c *Cluster) Run(ctx context.Context, w Workload) *History {
+	h := &History{}
+	var wg sync.WaitGroup
+	for i := 0; i < w.Clients; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.runClient(ctx, i, w, h)
+		}()
+	}
+	wg.Wait()
+	return h
+}
+
+// anyNow returns a running node picked at random, or nil.
+func (c *Cluster) anyNow() *Node {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	var running []*Node
+	for _, node := range c.nodes {
+		if node.raft != nil {
+			running = append(running, node)
+		}
+	}
+	if len(running) == 0 {
+		return nil
+	}
+	return running[rand.IntN(len(running))]
+}
+
+func (c *Cluster) runClient(ctx context.Context, id int, w Workload, h *History) {
+	var target *Node
+	for seq := 0; ctx.Err() == nil; seq++ {
+		if target == nil {
+			if target = c.leaderNow(); target == nil {
+				time.Sleep(10 * time.Millisecond)
+				continue
+			}
+		}
+		write := rand.IntN(2) == 0
+		node := target
+		if w.Eventual && !write {
+			if node = c.anyNow(); node == nil {
+				continue
+			}
+		}
+		svc := c.Service(node)
+		if svc == nil {
+			target = nil
+			continue
+		}
+
+		op := Op{Client: id, Key: fmt.Sprintf("key%d", rand.IntN(w.Keys))}
+		callCtx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+		if w.Eventual {
+			callCtx = service.ContextWithConsistency(callCtx, service.ConsistencyEventual)
+		}
+		op.Call = time.Now()
+		var err error
+		if write {
+			op.Write, op.Value = true, fmt.Sprintf("c%d-%d", id, seq)
+			err = svc.Set(callCtx, op.Key, op.Value, 0)
+		} else {
+			op.Value, err = svc.Get(callCtx, op.Key)
+			op.Found = err == nil
+			if errors.Is(err, coreerrors.ErrNotFound) {
+				err = nil
+			}
+		}
+		op.Return = time.Now()
+		cancel()
+
+		switch {
+		case err == nil:
+			h.Add(op)
+		case !op.Write:
+			// A failed read observed nothing.
+		case errors.Is(err, raft.ErrNotLeader), errors.Is(err, coreerrors.ErrTimeout), errors.Is(err, coreerrors.ErrNoQuorum),
+			errors.Is(err, coreerrors.ErrWitness):
+			// The write was never submitted to Raft.
+		default:
+			// The write may have committed, or may yet.
+			op.Pending = true
+			h.Add(op)
+		}
+		if err != nil && node == target {
+			target = nil
+		}
+	}
+}
@@ -0,0 +1,290 @@
+package testcluster
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	coreerrors "distributed-cache-service/internal/core/errors"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// runFaults runs a workload for d while inject injects a fault every
+// interval, then heals the cluster and checks the history.
+func runFaults(t *testing.T, c *Cluster, d, interval time.Duration, inject func(round int)) {
+	h := runWorkload(c, Workload{Clients: 4, Keys: 3}, d, interval, inject)
+	ops := h.Ops()
+	require.NotEmpty(t, ops)
+	t.Logf("%d operations recorded", len(ops))
+	require.NoError(t, CheckLinearizable(ops))
+}
+
+func runWorkload(c *Cluster, w Workload, d, interval time.Duration, inject func(round int)) *History {
+	ctx, cancel := context.WithTimeout(context.Background(), d)
+	defer cancel()
+	done := make(chan *History)
+	go func() { done <- c.Run(ctx, w) }()
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for round := 0; ; round++ {
+		select {
+		case <-ticker.C:
+			inject(round)
+			continue
+		case h := <-done:
+			return h
+		}
+	}
+}
+
+func TestCluster_LeaderCrashes(t *testing.T) {
+	if testing.Short() {
+		t.Skip("fault injection runs for seconds")
+	}
+	c := New(t, 3)
+	var killed *Node
+	runFaults(t, c, 3*time.Second, 300*time.Millisecond, func(round int) {
+		if killed != nil {
+			c.Restart(killed)
+			killed = nil
+			return
+		}
+		killed = c.Leader()
+		c.Kill(killed)
+	})
+}
+
+func TestCluster_LeaderPartitioned(t *testing.T) {
+	if testing.Short() {
+		t.Skip("fault injection runs for seconds")
+	}
+	c := New(t, 5)
+	runFaults(t, c, 3*time.Second, 300*time.Millisecond, func(round int) {
+		if round%2 == 1 {
+			c.Heal()
+			return
+		}
+		// Cut the leader and one follower off from the other three, so that
+		// the old leader keeps believing it leads until its lease expires.
+		leader := c.Leader()
+		minority := []*Node{leader}
+		for _, n := range c.Nodes() {
+			if n != leader {
+				minority = append(minority, n)
+				break
+			}
+		}
+		c.Partition(minority)
+	})
+}
+
+// Reads from followers are stale while they are cut off from the leader, so
+// the checker must reject the history.
+func TestCluster_DetectsStaleReads(t *testing.T) {
+	if testing.Short() {
+		t.Skip("fault injection runs for seconds")
+	}
+	c := New(t, 3)
+	h := runWorkload(c, Workload{Clients: 4, Keys: 2, Eventual: true}, time.Second, 200*time.Millisecond, func(round int) {
+		leader := c.Leader()
+		c.Heal()
+		c.Isolate(c.otherThan(leader))
+	})
+	assert.Error(t, CheckLinearizable(h.Ops()))
+}
+
+func TestCluster_RestartedNodeCatchesUp(t *testing.T) {
+	c := New(t, 3)
+	leader := c.Leader()
+	var follower *Node
+	for _, n := range c.Nodes() {
+		if n != leader {
+			follower = n
+			break
+		}
+	}
+	c.Kill(follower)
+
+	// Enough writes for snapshots to truncate the log the follower needs.
+	svc := c.Service(leader)
+	for i := 0; i < 200; i++ {
+		require.NoError(t, svc.Set(context.Background(), fmt.Sprint("key", i), fmt.Sprint("value", i), 0))
+	}
+	c.Restart(follower)
+
+	require.Eventually(t, func() bool {
+		r := c.Raft(follower)
+		return r != nil && r.Raft.AppliedIndex() >= c.Raft(leader).Raft.AppliedIndex()
+	}, 5*time.Second, 10*time.Millisecond)
+	c.Isolate(leader)
+	newLeader := c.Leader(follower, c.otherThan(leader, follower))
+	val, err := c.Service(newLeader).Get(context.Background(), "key199")
+	require.NoError(t, err)
+	assert.Equal(t, "value199", val)
+}
+
+// A leader cut off from the cluster steps down, and once it has gone
+// QuorumTimeout without hearing from a new leader, fails writes and strong
+// reads at once instead of letting them time out.
+// A new leader may not yet have applied the writes its predecessor
+// committed: strong reads have to wait until it has.
+func TestCluster_NewLeaderReadsCommittedWrites(t *testing.T) {
+	c := New(t, 3)
+	leader := c.Leader()
+	for _, n := range c.Nodes() {
+		if n != leader {
+			c.SlowApplies(n, 500*time.Millisecond)
+		}
+	}
+	ctx := context.Background()
+	require.NoError(t, c.Service(leader).Set(ctx, "k", "v", 0))
+	c.Kill(leader)
+
+	val, err := c.Service(c.Leader()).Get(ctx, "k")
+	require.NoError(t, err)
+	assert.Equal(t, "v", val)
+}
+
+func TestCluster_MinorityFailsFast(t *testing.T) {
+	c := New(t, 3)
+	leader := c.Leader()
+	c.Raft(leader).QuorumTimeout = 200 * time.Millisecond
+	svc := c.Service(leader)
+	require.NoError(t, svc.CheckQuorum())
+
+	c.Isolate(leader)
+	require.Eventually(t, func() bool {
+		return errors.Is(svc.CheckQuorum(), coreerrors.ErrNoQuorum)
+	}, 5*time.Second, 10*time.Millisecond)
+	start := time.Now()
+	assert.ErrorIs(t, svc.Set(context.Background(), "k", "v", 0), coreerrors.ErrNoQuorum)
+	_, err := svc.Get(context.Background(), "k")
+	assert.ErrorIs(t, err, coreerrors.ErrNoQuorum)
+	assert.Less(t, time.Since(start), 100*time.Millisecond)
+	// The majority elected a new leader and goes on.
+	var majority []*Node
+	for _, n := range c.Nodes() {
+		if n != leader {
+			majority = append(majority, n)
+		}
+	}
+	assert.NoError(t, c.Service(c.Leader(majority...)).CheckQuorum())
+
+	c.Heal()
+	require.Eventually(t, func() bool { return svc.CheckQuorum() == nil }, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestCluster_Witness(t *testing.T) {
+	c := New(t, 3, WithWitnesses(1))
+	nodes := c.Nodes()
+	a, b, witness := nodes[0], nodes[1], nodes[2]
+	ctx := context.Background()
+	require.NoError(t, c.Service(c.Leader(a, b)).Set(ctx, "k", "v", 0))
+	_, err := c.Service(witness).Get(ctx, "k")
+	assert.ErrorIs(t, err, coreerrors.ErrWitness)
+	assert.ErrorIs(t, c.Service(witness).Set(ctx, "k", "w", 0), coreerrors.ErrWitness)
+
+	// A witness handed leadership gives it straight back to a data node.
+	require.NoError(t, c.Raft(c.Leader(a, b)).TransferLeadership(witness.ID, string(witness.Addr)))
+	require.Eventually(t, func() bool {
+		leader := c.leaderNow()
+		return leader != nil && leader != witness
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// With one data node down, the witness's vote keeps the other writing.
+	leader := c.Leader(a, b)
+	c.Kill(leader)
+	survivor := c.Leader(c.otherThan(leader, witness))
+	require.NoError(t, c.Service(survivor).Set(ctx, "k2", "v2", 0))
+	val, err := c.Service(survivor).Get(ctx, "k")
+	require.NoError(t, err)
+	assert.Equal(t, "v", val)
+}
+
+// members returns how many members node's configuration holds.
+func (c *Cluster) members(node *Node) int {
+	members, err := c.Raft(node).Members()
+	if err != nil {
+		return -1
+	}
+	return len(members)
+}
+
+func TestCluster_ReapsDeadMembers(t *testing.T) {
+	c := New(t, 5, WithReaper(300*time.Millisecond, false))
+	leader := c.Leader()
+	dead1 := c.otherThan(leader)
+	dead2 := c.otherThan(leader, dead1)
+	c.Kill(dead1)
+	c.Kill(dead2)
+	require.Eventually(t, func() bool { return c.members(c.Leader()) == 3 }, 10*time.Second, 50*time.Millisecond)
+
+	// Three members are left, so one more failure still leaves a quorum,
+	// where five, two of them lost for good, would not have one.
+	c.Kill(c.otherThan(c.Leader(), dead1, dead2))
+	assert.NoError(t, c.Service(c.Leader()).Set(context.Background(), "k", "v", 0))
+}
+
+func TestCluster_ReapStopsAtMinVoters(t *testing.T) {
+	c := New(t, 5, WithReaper(200*time.Millisecond, false), WithReapMinVoters(4))
+	leader := c.Leader()
+	dead1 := c.otherThan(leader)
+	dead2 := c.otherThan(leader, dead1)
+	c.Kill(dead1)
+	c.Kill(dead2)
+	require.Eventually(t, func() bool { return c.members(c.Leader()) == 4 }, 10*time.Second, 50*time.Millisecond)
+
+	// The second dead member stays, however long it is gone: four voters
+	// is the floor.
+	time.Sleep(time.Second)
+	assert.Equal(t, 4, c.members(c.Leader()))
+	dead, err := c.Raft(c.Leader()).DeadMembers()
+	require.NoError(t, err)
+	require.Len(t, dead, 1)
+	assert.Contains(t, []string{dead1.ID, dead2.ID}, dead[0].ID)
+
+	// The default floor keeps three voters.
+	c = New(t, 3, WithReaper(200*time.Millisecond, false))
+	c.Kill(c.otherThan(c.Leader()))
+	time.Sleep(time.Second)
+	assert.Equal(t, 3, c.members(c.Leader()))
+}
+
+func TestCluster_ReapConfirm(t *testing.T) {
+	c := New(t, 3, WithReaper(200*time.Millisecond, true))
+	leader := c.Leader()
+	dead := c.otherThan(leader)
+	c.Kill(dead)
+	require.Eventually(t, func() bool {
+		members, err := c.Raft(leader).DeadMembers()
+		return err == nil && len(members) == 1 && members[0].ID == dead.ID
+	}, 5*time.Second, 20*time.Millisecond)
+	time.Sleep(500 * time.Millisecond)
+	assert.Equal(t, 3, c.members(leader), "dead member removed without confirmation")
+
+	live := c.otherThan(leader, dead)
+	assert.ErrorIs(t, c.Raft(leader).Reap(live.ID), coreerrors.ErrInvalidArgument)
+	require.NoError(t, c.Raft(leader).Reap(dead.ID))
+	assert.Equal(t, 2, c.members(leader))
+	_, err := c.Raft(live).DeadMembers()
+	assert.ErrorIs(t, err, coreerrors.ErrNotLeader)
+}
+
+// otherThan returns a node that is none of nodes.
+func (c *Cluster) otherThan(nodes ...*Node) *Node {
+	for _, n := range c.Nodes() {
+		found := false
+		for _, m := range nodes {
+			found = found || n == m
+		}
+		if !found {
+			return n
+		}
+	}
+	return nil
+}
@@ -0,0 +1,218 @@
+package testcluster
+
+import (
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Op is one client operation on a key, treated as a register: a write of
+// Value, or a read that returned Value, or nothing if Found is false.
+type Op struct {
+	Client int
+	Key    string
+	Write  bool
+	Value  string
+	Found  bool
+	// Call and Return bound when the operation took effect. A write whose
+	// outcome is unknown, e.g. one that timed out, is Pending: it may take
+	// effect at any point after Call, or never.
+	Call, Return time.Time
+	Pending      bool
+}
+
+func (op Op) String() string {
+	var s string
+	switch {
+	case op.Write:
+		s = fmt.Sprintf("write(%s)", op.Value)
+	case op.Found:
+		s = fmt.Sprintf("read()=%s", op.Value)
+	default:
+		s = "read()=<none>"
+	}
+	if op.Pending {
+		return fmt.Sprintf("client %d %s %s [%s, ?)", op.Client, op.Key, s, op.Call.Format("15:04:05.000000"))
+	}
+	return fmt.Sprintf("client %d %s %s [%s, %s]", op.Client, op.Key, s, op.Call.Format("15:04:05.000000"), op.Return.Format("15:04:05.000000"))
+}
+
+// History records the operations of concurrent clients.
+type History struct {
+	mu  sync.Mutex
+	ops []Op
+}
+
+// Add records op.
+func (h *History) Add(op Op) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.ops = append(h.ops, op)
+}
+
+// Ops returns the operations recorded so far.
+func (h *History) Ops() []Op {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return append([]Op(nil), h.ops...)
+}
+
+// CheckLinearizable reports whether ops could have happened one at a time, in
+// an order that respects real time and in which every read returns the last
+// value written, key by key. Keys start out absent. It returns an error
+// naming the first key for which no such order exists.
+//
+// It is the search of Wing and Gong as improved by Lowe, which is exponential
+// in the worst case, so histories should keep to a few hundred operations per
+// key. Writes should write unique values.
+func CheckLinearizable(ops []Op) error {
+	byKey := make(map[string][]Op)
+	for _, op := range ops {
+		byKey[op.Key] = append(byKey[op.Key], op)
+	}
+	keys := make([]string, 0, len(byKey))
+	for k := range byKey {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		if !linearizable(byKey[k]) {
+			return fmt.Errorf("history of key %q is not linearizable:\n%s", k, describeHistory(byKey[k]))
+		}
+	}
+	return nil
+}
+
+func describeHistory(ops []Op) string {
+	sorted := append([]Op(nil), ops...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Call.Before(sorted[j].Call) })
+	lines := make([]string, len(sorted))
+	for i, op := range sorted {
+		lines[i] = "  " + op.String()
+	}
+	return strings.Join(lines, "\n")
+}
+
+// register is the state of a key.
+type register struct {
+	value string
+	found bool
+}
+
+// step applies op to r, reporting whether op is consistent with r.
+func step(r register, op Op) (register, bool) {
+	if op.Write {
+		return register{value: op.Value, found: true}, true
+	}
+	return r, op.Found == r.found && (!op.Found || op.Value == r.value)
+}
+
+// event is a call or return in the doubly linked list the search walks.
+type event struct {
+	op         int
+	call       bool
+	match      *event // the return of a call, nil for pending ops
+	prev, next *event
+}
+
+// lift unlinks a call and its return; unlift puts them back.
+func (e *event) lift() {
+	e.prev.next, e.next.prev = e.next, e.prev
+	if r := e.match; r != nil {
+		r.prev.next, r.next.prev = r.next, r.prev
+	}
+}
+
+func (e *event) unlift() {
+	if r := e.match; r != nil {
+		r.prev.next, r.next.prev = r, r
+	}
+	e.prev.next, e.next.prev = e, e
+}
+
+func linearizable(ops []Op) bool {
+	type timed struct {
+		at time.Time
+		e  *event
+	}
+	var events []timed
+	for i, op := range ops {
+		call := &event{op: i, call: true}
+		events = append(events, timed{op.Call, call})
+		if !op.Pending {
+			call.match = &event{op: i}
+			events = append(events, timed{op.Return, call.match})
+		}
+	}
+	// Calls sort before returns at the same instant, so that operations
+	// that touch are treated as concurrent.
+	sort.SliceStable(events, func(i, j int) bool {
+		if !events[i].at.Equal(events[j].at) {
+			return events[i].at.Before(events[j].at)
+		}
+		return events[i].e.call && !events[j].e.call
+	})
+	head := &event{}
+	prev := head
+	for _, t := range events {
+		prev.next, t.e.prev = t.e, prev
+		prev = t.e
+	}
+	// Sentinels at both ends keep lift and unlift free of nil checks.
+	tail := &event{op: -1, call: true}
+	prev.next, tail.prev = tail, prev
+
+	type frame struct {
+		e     *event
+		state register
+	}
+	type seen struct {
+		linearized string
+		state      register
+	}
+	var (
+		stack      []frame
+		state      register
+		linearized big.Int
+		cache      = make(map[seen]bool)
+	)
+	e := head.next
+	for {
+		switch {
+		case e == tail:
+			// Every completed operation is linearized; pending ones may
+			// never have taken effect.
+			return true
+		case e.call:
+			next, ok := step(state, ops[e.op])
+			if ok {
+				linearized.SetBit(&linearized, e.op, 1)
+				key := seen{string(linearized.Bytes()), next}
+				if !cache[key] {
+					cache[key] = true
+					stack = append(stack, frame{e, state})
+					state = next
+					e.lift()
+					e = head.next
+					continue
+				}
+				linearized.SetBit(&linearized, e.op, 0)
+			}
+			e = e.next
+		default:
+			// An operation returned before any order could place it.
+			if len(stack) == 0 {
+				return false
+			}
+			top := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			state = top.state
+			linearized.SetBit(&linearized, top.e.op, 0)
+			top.e.unlift()
+			e = top.e.next
+		}
+	}
+}
@@ -0,0 +1,72 @@
+package testcluster
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// at returns an instant n milliseconds into a test history.
+func at(n int) time.Time {
+	return time.Unix(0, 0).Add(time.Duration(n) * time.Millisecond)
+}
+
+func write(client int, v string, call, ret int) Op {
+	return Op{Client: client, Key: "k", Write: true, Value: v, Call: at(call), Return: at(ret)}
+}
+
+func read(client int, v string, call, ret int) Op {
+	return Op{Client: client, Key: "k", Value: v, Found: v != "", Call: at(call), Return: at(ret)}
+}
+
+func TestCheckLinearizable(t *testing.T) {
+	pendingWrite := func(v string, call int) Op {
+		op := write(9, v, call, 0)
+		op.Pending = true
+		return op
+	}
+
+	tests := []struct {
+		name string
+		ops  []Op
+		ok   bool
+	}{
+		{"empty", nil, true},
+		{"read of an absent key", []Op{read(1, "", 0, 1)}, true},
+		{"read after write", []Op{write(1, "a", 0, 1), read(2, "a", 2, 3)}, true},
+		{"stale read", []Op{write(1, "a", 0, 1), write(1, "b", 2, 3), read(2, "a", 4, 5)}, false},
+		{"read concurrent with write sees either", []Op{
+			write(1, "a", 0, 1), write(1, "b", 2, 6), read(2, "a", 3, 4), read(3, "b", 3, 5),
+		}, true},
+		{"reads may not go back in time", []Op{
+			write(1, "a", 0, 1), write(1, "b", 2, 10), read(2, "b", 3, 4), read(3, "a", 5, 6),
+		}, false},
+		{"lost acknowledged write", []Op{write(1, "a", 0, 1), read(2, "", 2, 3)}, false},
+		{"pending write may apply late", []Op{
+			pendingWrite("a", 0), read(1, "", 1, 2), read(2, "a", 3, 4),
+		}, true},
+		{"pending write may never apply", []Op{pendingWrite("a", 0), read(1, "", 5, 6)}, true},
+		{"pending write cannot apply before its call", []Op{
+			read(1, "a", 0, 1), pendingWrite("a", 2),
+		}, false},
+		{"value never written", []Op{read(1, "x", 0, 1)}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := CheckLinearizable(tt.ops)
+			if tt.ok {
+				assert.NoError(t, err)
+			} else {
+				assert.Error(t, err)
+			}
+		})
+	}
+}
+
+func TestCheckLinearizable_PerKey(t *testing.T) {
+	a := write(1, "a", 0, 1)
+	b := read(2, "", 2, 3)
+	b.Key = "other"
+	assert.NoError(t, CheckLinearizable([]Op{a, b}), "keys are independent registers")
+}
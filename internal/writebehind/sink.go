@@ -0,0 +1,96 @@
+package writebehind
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"distributed-cache-service/internal/kafkarest"
+)
+
+// ParseSink builds a Sink from a URI:
+//
+//	http://host/path, https://host/path   webhook receiving a JSON array of mutations
+//	kafka://rest-proxy:8082/topic          Kafka topic via a Confluent REST Proxy
+func ParseSink(uri string) (Sink, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, fmt.Errorf("invalid write-behind sink: %w", err)
+	}
+	switch u.Scheme {
+	case "http", "https":
+		return NewWebhook(uri), nil
+	case "kafka":
+		topic := strings.Trim(u.Path, "/")
+		if u.Host == "" || topic == "" {
+			return nil, fmt.Errorf("invalid kafka sink %q: expected kafka://proxy-host:port/topic", uri)
+		}
+		return NewKafkaREST("http://"+u.Host, topic), nil
+	default:
+		return nil, fmt.Errorf("unsupported write-behind sink scheme %q", u.Scheme)
+	}
+}
+
+// Webhook POSTs each batch as a JSON array to a URL. Any 2xx response is success.
+type Webhook struct {
+	url    string
+	client *http.Client
+}
+
+// NewWebhook creates a webhook sink.
+func NewWebhook(url string) *Webhook {
+	return &Webhook{url: url, client: http.DefaultClient}
+}
+
+// Deliver implements Sink.
+func (w *Webhook) Deliver(ctx context.Context, batch []Mutation) error {
+	body, err := json.Marshal(batch)
+	if err != nil {
+		return err
+	}
+	return post(ctx, w.client, w.url, "application/json", body)
+}
+
+// KafkaREST produces each mutation as a record keyed by cache key, so all
+// changes to a key land on the same partition in order.
+// It speaks the Confluent REST Proxy v2 API rather than the Kafka wire protocol.
+type KafkaREST struct {
+	producer *kafkarest.Producer
+}
+
+// NewKafkaREST creates a sink producing to topic through the REST proxy at baseURL.
+func NewKafkaREST(baseURL, topic string) *KafkaREST {
+	return &KafkaREST{producer: kafkarest.New(baseURL, topic)}
+}
+
+// Deliver implements Sink.
+func (k *KafkaREST) Deliver(ctx context.Context, batch []Mutation) error {
+	records := make([]kafkarest.Record, len(batch))
+	for i, m := range batch {
+		records[i] = kafkarest.Record{Key: m.Key, Value: m}
+	}
+	return k.producer.Produce(ctx, records)
+}
+
+func post(ctx context.Context, client *http.Client, url, contentType string, body []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", contentType)
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, resp.Body)
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("sink returned %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,205 @@
+// Package writebehind asynchronously forwards committed mutations to an
+// external system of record, so the cache can front a database.
+//
+// Mutations are enqueued from the FSM as they are applied and delivered in
+// batches, in commit order, by a single worker. Only the leader delivers, so a
+// cluster sends each mutation once in steady state; around leader changes a
+// mutation may be delivered twice or not at all. Sinks should be idempotent
+// (the Raft index is included for that purpose).
+package writebehind
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+
+	"distributed-cache-service/internal/encryption"
+	"distributed-cache-service/internal/observability"
+)
+
+// Mutation is a committed change to deliver to the sink.
+type Mutation struct {
+	Op        string    `json:"op"` // "SET", "DELETE", "EXPIRE", "PERSIST", "ZADD" or "ZREMRANGEBYSCORE"
+	Key       string    `json:"key"`
+	Value     string    `json:"value,omitempty"` // for sorted set ops, the JSON-encoded arguments
+	TTLMillis int64     `json:"ttl_ms,omitempty"`
+	Index     uint64    `json:"index"` // Raft log index, usable as an idempotency key
+	Timestamp time.Time `json:"ts"`    // When the leader appended the entry
+}
+
+// Sink receives batches of mutations. Deliver must not retain the slice.
+type Sink interface {
+	Deliver(ctx context.Context, batch []Mutation) error
+}
+
+// Queue buffers mutations and delivers them to a Sink with retries.
+// Mutations that cannot be delivered are counted as dead letters.
+type Queue struct {
+	sink          Sink
+	isLeader      func() bool
+	queueSize     int
+	batchSize     int
+	flushInterval time.Duration
+	maxRetries    int
+	retryBackoff  time.Duration
+	timeout       time.Duration
+	cipher        *encryption.Cipher
+
+	mu     sync.RWMutex
+	closed bool
+	in     chan Mutation
+	done   chan struct{}
+}
+
+// Option configures a Queue.
+type Option func(*Queue)
+
+// WithLeaderCheck makes the queue drop mutations applied while isLeader reports false.
+func WithLeaderCheck(isLeader func() bool) Option {
+	return func(q *Queue) {
+		q.isLeader = isLeader
+	}
+}
+
+// WithQueueSize sets how many mutations may wait for delivery. When the queue
+// is full, new mutations are dead-lettered rather than stalling the FSM.
+func WithQueueSize(n int) Option {
+	return func(q *Queue) {
+		q.queueSize = n
+	}
+}
+
+// WithBatch sets the maximum batch size and how long a partial batch may wait.
+func WithBatch(size int, flushInterval time.Duration) Option {
+	return func(q *Queue) {
+		q.batchSize = size
+		q.flushInterval = flushInterval
+	}
+}
+
+// WithRetries sets how many times a failed batch is retried, with exponential
+// backoff starting at backoff.
+func WithRetries(max int, backoff time.Duration) Option {
+	return func(q *Queue) {
+		q.maxRetries = max
+		q.retryBackoff = backoff
+	}
+}
+
+// WithEncryption opens values sealed with c before delivering them, so that
+// sinks receive the original values.
+func WithEncryption(c *encryption.Cipher) Option {
+	return func(q *Queue) {
+		q.cipher = c
+	}
+}
+
+// New creates a Queue delivering to sink and starts its worker.
+func New(sink Sink, opts ...Option) *Queue {
+	q := &Queue{
+		sink:          sink,
+		queueSize:     10000,
+		batchSize:     100,
+		flushInterval: 100 * time.Millisecond,
+		maxRetries:    5,
+		retryBackoff:  100 * time.Millisecond,
+		timeout:       10 * time.Second,
+		done:          make(chan struct{}),
+	}
+	for _, opt := range opts {
+		opt(q)
+	}
+	q.in = make(chan Mutation, q.queueSize)
+	go q.run()
+	return q
+}
+
+// Enqueue schedules m for delivery without blocking.
+func (q *Queue) Enqueue(m Mutation) {
+	if q.isLeader != nil && !q.isLeader() {
+		return
+	}
+	q.mu.RLock()
+	defer q.mu.RUnlock()
+	if q.closed {
+		return
+	}
+	select {
+	case q.in <- m:
+		observability.WriteBehindQueueDepth.Set(float64(len(q.in)))
+	default:
+		observability.WriteBehindDeadLettersTotal.WithLabelValues("overflow").Inc()
+	}
+}
+
+// Close stops accepting mutations and waits for queued ones to be delivered
+// (or dead-lettered).
+func (q *Queue) Close() {
+	q.mu.Lock()
+	if !q.closed {
+		q.closed = true
+		close(q.in)
+	}
+	q.mu.Unlock()
+	<-q.done
+}
+
+func (q *Queue) run() {
+	defer close(q.done)
+	ticker := time.NewTicker(q.flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]Mutation, 0, q.batchSize)
+	flush := func() {
+		if len(batch) > 0 {
+			q.deliver(batch)
+			batch = batch[:0]
+		}
+		observability.WriteBehindQueueDepth.Set(float64(len(q.in)))
+	}
+	for {
+		select {
+		case m, ok := <-q.in:
+			if !ok {
+				flush()
+				return
+			}
+			batch = append(batch, m)
+			if len(batch) >= q.batchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+func (q *Queue) deliver(batch []Mutation) {
+	for i := range batch {
+		// Values are stored (and replicated) compressed and encrypted; the
+		// sink wants the original.
+		if v, err := q.cipher.Decode(batch[i].Value); err == nil {
+			batch[i].Value = v
+		}
+	}
+
+	backoff := q.retryBackoff
+	for attempt := 0; ; attempt++ {
+		ctx, cancel := context.WithTimeout(context.Background(), q.timeout)
+		err := q.sink.Deliver(ctx, batch)
+		cancel()
+		if err == nil {
+			observability.WriteBehindDeliveredTotal.Add(float64(len(batch)))
+			return
+		}
+		if attempt >= q.maxRetries {
+			observability.WriteBehindDeadLettersTotal.WithLabelValues("retries_exhausted").Add(float64(len(batch)))
+			slog.Error("write-behind delivery failed, dropping batch",
+				"mutations", len(batch), "first_index", batch[0].Index, "error", err)
+			return
+		}
+		time.Sleep(backoff)
+		backoff = min(backoff*2, 5*time.Second)
+	}
+}
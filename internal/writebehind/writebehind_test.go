@@ -0,0 +1,122 @@
+package writebehind
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"distributed-cache-service/internal/compression"
+	"distributed-cache-service/internal/observability"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+type recordingSink struct {
+	mu       sync.Mutex
+	failures int
+	got      []Mutation
+}
+
+func (s *recordingSink) Deliver(ctx context.Context, batch []Mutation) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.failures > 0 {
+		s.failures--
+		return errors.New("sink down")
+	}
+	s.got = append(s.got, batch...)
+	return nil
+}
+
+func TestQueue_DeliversInOrderWithRetries(t *testing.T) {
+	sink := &recordingSink{failures: 2}
+	q := New(sink, WithBatch(2, time.Millisecond), WithRetries(3, time.Millisecond))
+
+	compressed, _ := compression.New(compression.Deflate, 0).Encode(string(make([]byte, 256)))
+	q.Enqueue(Mutation{Op: "SET", Key: "a", Value: "1", Index: 1})
+	q.Enqueue(Mutation{Op: "DELETE", Key: "a", Index: 2})
+	q.Enqueue(Mutation{Op: "SET", Key: "b", Value: compressed, Index: 3})
+	q.Close()
+
+	if len(sink.got) != 3 {
+		t.Fatalf("expected 3 mutations, got %d", len(sink.got))
+	}
+	for i, m := range sink.got {
+		if m.Index != uint64(i+1) {
+			t.Errorf("expected index %d at position %d, got %d", i+1, i, m.Index)
+		}
+	}
+	if len(sink.got[2].Value) != 256 {
+		t.Errorf("expected value to be decompressed, got %d bytes", len(sink.got[2].Value))
+	}
+}
+
+func TestQueue_DeadLettersAfterRetries(t *testing.T) {
+	before := testutil.ToFloat64(observability.WriteBehindDeadLettersTotal.WithLabelValues("retries_exhausted"))
+
+	sink := &recordingSink{failures: 100}
+	q := New(sink, WithRetries(1, time.Millisecond))
+	q.Enqueue(Mutation{Op: "SET", Key: "a", Value: "1", Index: 1})
+	q.Close()
+
+	after := testutil.ToFloat64(observability.WriteBehindDeadLettersTotal.WithLabelValues("retries_exhausted"))
+	if after != before+1 {
+		t.Errorf("expected 1 dead letter, got %v", after-before)
+	}
+}
+
+func TestQueue_OnlyLeaderDelivers(t *testing.T) {
+	sink := &recordingSink{}
+	q := New(sink, WithLeaderCheck(func() bool { return false }))
+	q.Enqueue(Mutation{Op: "SET", Key: "a", Index: 1})
+	q.Close()
+
+	if len(sink.got) != 0 {
+		t.Errorf("expected followers not to deliver, got %d", len(sink.got))
+	}
+}
+
+func TestParseSink(t *testing.T) {
+	var got struct {
+		path, contentType string
+		body              map[string][]struct {
+			Key   string
+			Value Mutation
+		}
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got.path = r.URL.Path
+		got.contentType = r.Header.Get("Content-Type")
+		json.NewDecoder(r.Body).Decode(&got.body)
+	}))
+	defer srv.Close()
+
+	sink, err := ParseSink("kafka://" + srv.Listener.Addr().String() + "/cache-changes")
+	if err != nil {
+		t.Fatalf("parse: %v", err)
+	}
+	if err := sink.Deliver(context.Background(), []Mutation{{Op: "SET", Key: "k", Value: "v", Index: 9}}); err != nil {
+		t.Fatalf("deliver: %v", err)
+	}
+	if got.path != "/topics/cache-changes" || got.contentType != "application/vnd.kafka.json.v2+json" {
+		t.Errorf("unexpected request %s (%s)", got.path, got.contentType)
+	}
+	if recs := got.body["records"]; len(recs) != 1 || recs[0].Key != "k" || recs[0].Value.Index != 9 {
+		t.Errorf("unexpected records %+v", recs)
+	}
+
+	if _, err := ParseSink(srv.URL + "/hook"); err != nil {
+		t.Errorf("expected webhook sink, got %v", err)
+	}
+	if _, err := ParseSink("kafka://broker:9092"); err == nil {
+		t.Error("expected error for kafka sink without topic")
+	}
+	if _, err := ParseSink("amqp://broker"); err == nil {
+		t.Error("expected error for unsupported scheme")
+	}
+}